@@ -2,13 +2,48 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/modules"
 )
 
 var (
+	daemonCmd = &cobra.Command{
+		Use:   "daemon",
+		Short: "Perform daemon actions",
+		Long:  "Manage the settings of the Sia daemon.",
+		// Run field not provided; daemon requires a subcommand.
+	}
+
+	daemonTokensCmd = &cobra.Command{
+		Use:   "tokens",
+		Short: "List the API tokens",
+		Long:  "List the scoped API tokens of the daemon.",
+		Run:   wrap(daemontokenscmd),
+	}
+
+	daemonTokensCreateCmd = &cobra.Command{
+		Use:   "create [name] [scopes]",
+		Short: "Create an API token",
+		Long: `Create an API token with a comma-separated list of scopes. The secret of
+the token is only printed once and can be used instead of the API password,
+e.g. with --apipassword. Available scopes: ` + strings.Join(modules.APIScopes, ", "),
+		Run: wrap(daemontokenscreatecmd),
+	}
+
+	daemonTokensRevokeCmd = &cobra.Command{
+		Use:   "revoke [id]",
+		Short: "Revoke an API token",
+		Long:  "Revoke an API token, requests using the token will be rejected.",
+		Run:   wrap(daemontokensrevokecmd),
+	}
+
 	stopCmd = &cobra.Command{
 		Use:   "stop",
 		Short: "Stop the Sia daemon",
@@ -108,3 +143,50 @@ func updatecheckcmd() {
 		fmt.Println("Up to date.")
 	}
 }
+
+// daemontokenscmd is the handler for the command `siac daemon tokens`.
+// Lists the API tokens of the daemon.
+func daemontokenscmd() {
+	dtg, err := httpClient.DaemonTokensGet()
+	if err != nil {
+		die("Could not get API tokens:", err)
+	}
+	if len(dtg.Tokens) == 0 {
+		fmt.Println("No API tokens.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tName\tScopes\tExpires")
+	for _, t := range dtg.Tokens {
+		expires := "never"
+		if t.Expires != 0 {
+			expires = time.Unix(int64(t.Expires), 0).Format(time.RFC822)
+		}
+		if t.Expired {
+			expires += " (expired)"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", t.ID, t.Name, strings.Join(t.Scopes, ","), expires)
+	}
+	w.Flush()
+}
+
+// daemontokenscreatecmd is the handler for the command `siac daemon tokens
+// create [name] [scopes]`. Creates a new API token and prints its secret.
+func daemontokenscreatecmd(name, scopes string) {
+	dtp, err := httpClient.DaemonTokensPost(name, strings.Split(scopes, ","), daemonTokenLifetime)
+	if err != nil {
+		die("Could not create API token:", err)
+	}
+	fmt.Printf("Created API token %v.\n", dtp.Token.ID)
+	fmt.Println("Secret:", dtp.Secret)
+	fmt.Println("The secret will not be shown again.")
+}
+
+// daemontokensrevokecmd is the handler for the command `siac daemon tokens
+// revoke [id]`. Revokes an API token.
+func daemontokensrevokecmd(id string) {
+	if err := httpClient.DaemonTokensRevokePost(id); err != nil {
+		die("Could not revoke API token:", err)
+	}
+	fmt.Printf("Revoked API token %v.\n", id)
+}
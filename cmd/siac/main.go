@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -15,28 +16,51 @@ import (
 
 var (
 	// Flags.
-	dictionaryLanguage      string // dictionary for seed utils
-	hostContractOutputType  string // output type for host contracts
-	hostVerbose             bool   // display additional host info
-	initForce               bool   // destroy and re-encrypt the wallet on init if it already exists
-	initPassword            bool   // supply a custom password when creating a wallet
-	renterAllContracts      bool   // Show all active and expired contracts
-	renterDownloadAsync     bool   // Downloads files asynchronously
-	renterDownloadRecursive bool   // Downloads folders recursively.
-	renterListVerbose       bool   // Show additional info about uploaded files.
-	renterListRecursive     bool   // List files of folder recursively.
-	renterShowHistory       bool   // Show download history in addition to download queue.
-	siaDir                  string // Path to sia data dir
-	walletRawTxn            bool   // Encode/decode transactions in base64-encoded binary.
-
-	allowanceFunds              string // amount of money to be used within a period
-	allowancePeriod             string // length of period
-	allowanceHosts              string // number of hosts to form contracts with
-	allowanceRenewWindow        string // renew window of allowance
-	allowanceExpectedStorage    string // expected storage stored on hosts before redundancy
-	allowanceExpectedUpload     string // expected data uploaded within period
-	allowanceExpectedDownload   string // expected data downloaded within period
-	allowanceExpectedRedundancy string // expected redundancy of most uploaded files
+	apiTLS                  bool          // connect to the API over TLS
+	apiTLSCA                string        // certificate authority of the API's TLS certificate
+	apiTLSCert              string        // client certificate for the API
+	apiTLSKey               string        // key of the client certificate for the API
+	daemonTokenLifetime     time.Duration // lifetime of a new API token
+	dictionaryLanguage      string        // dictionary for seed utils
+	hostContractOutputType  string        // output type for host contracts
+	hostVerbose             bool          // display additional host info
+	initForce               bool          // destroy and re-encrypt the wallet on init if it already exists
+	initPassword            bool          // supply a custom password when creating a wallet
+	renterAllContracts      bool          // Show all active and expired contracts
+	renterDownloadAsync     bool          // Downloads files asynchronously
+	renterDownloadFormat    string        // Downloads folders as an archive of this format.
+	renterDownloadRecursive bool          // Downloads folders recursively.
+	renterDrainAsync        bool          // Don't wait for a drain to finish.
+	renterListVerbose       bool          // Show additional info about uploaded files.
+	renterListRecursive     bool          // List files of folder recursively.
+	renterShareEncrypt      bool          // Prompt for a password to encrypt or decrypt a share.
+	renterShowHistory       bool          // Show download history in addition to download queue.
+	renterUploadPack        bool          // Pack small files into shared chunks.
+	renterVersionMaxAge     string        // Maximum age of old versions.
+	siaDir                  string        // Path to sia data dir
+	walletAccount           string        // Account of the wallet that funds a transaction.
+	walletExportAddresses   string        // Comma-separated addresses to export the ledger for.
+	walletExportEndDate     string        // Last day of the exported ledger.
+	walletExportEndHeight   uint64        // Last block height of the exported ledger.
+	walletExportFormat      string        // Format of the exported ledger.
+	walletExportStartDate   string        // First day of the exported ledger.
+	walletExportStartHeight uint64        // First block height of the exported ledger.
+	walletRawTxn            bool          // Encode/decode transactions in base64-encoded binary.
+
+	allowanceFunds                     string // amount of money to be used within a period
+	allowancePeriod                    string // length of period
+	allowanceHosts                     string // number of hosts to form contracts with
+	allowanceRenewWindow               string // renew window of allowance
+	allowanceExpectedStorage           string // expected storage stored on hosts before redundancy
+	allowanceExpectedUpload            string // expected data uploaded within period
+	allowanceExpectedDownload          string // expected data downloaded within period
+	allowanceExpectedRedundancy        string // expected redundancy of most uploaded files
+	allowanceMaxRPCPrice               string // maximum base RPC price of hosts
+	allowanceMaxContractPrice          string // maximum contract price of hosts
+	allowanceMaxDownloadBandwidthPrice string // maximum download bandwidth price of hosts
+	allowanceMaxSectorAccessPrice      string // maximum sector access price of hosts
+	allowanceMaxStoragePrice           string // maximum storage price of hosts
+	allowanceMaxUploadBandwidthPrice   string // maximum upload bandwidth price of hosts
 )
 
 var (
@@ -144,6 +168,11 @@ func main() {
 	root.AddCommand(updateCmd)
 	updateCmd.AddCommand(updateCheckCmd)
 
+	root.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonTokensCmd)
+	daemonTokensCmd.AddCommand(daemonTokensCreateCmd, daemonTokensRevokeCmd)
+	daemonTokensCreateCmd.Flags().DurationVar(&daemonTokenLifetime, "expires", 0, "lifetime of the token, e.g. 720h (never expires by default)")
+
 	root.AddCommand(hostCmd)
 	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostFolderCmd, hostContractCmd, hostSectorCmd)
 	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
@@ -157,17 +186,28 @@ func main() {
 	hostdbCmd.Flags().BoolVarP(&hostdbVerbose, "verbose", "v", false, "Display full hostdb information")
 
 	root.AddCommand(minerCmd)
-	minerCmd.AddCommand(minerStartCmd, minerStopCmd)
+	minerCmd.AddCommand(minerStartCmd, minerStopCmd, minerConfigCmd, minerStratumCmd)
+	minerStratumCmd.AddCommand(minerStratumStartCmd, minerStratumStopCmd)
 
 	root.AddCommand(walletCmd)
-	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletChangepasswordCmd, walletInitCmd, walletInitSeedCmd,
+	walletCmd.AddCommand(walletAccountsCmd, walletAddressCmd, walletAddressesCmd, walletChangepasswordCmd, walletInitCmd, walletInitSeedCmd,
 		walletLoadCmd, walletLockCmd, walletSeedsCmd, walletSendCmd, walletSweepCmd, walletSignCmd,
-		walletBalanceCmd, walletBroadcastCmd, walletTransactionsCmd, walletUnlockCmd)
+		walletBalanceCmd, walletBroadcastCmd, walletExportCmd, walletTransactionsCmd, walletUnlockCmd)
 	walletInitCmd.Flags().BoolVarP(&initPassword, "password", "p", false, "Prompt for a custom password")
 	walletInitCmd.Flags().BoolVarP(&initForce, "force", "", false, "destroy the existing wallet and re-encrypt")
 	walletInitSeedCmd.Flags().BoolVarP(&initForce, "force", "", false, "destroy the existing wallet")
 	walletLoadCmd.AddCommand(walletLoad033xCmd, walletLoadSeedCmd, walletLoadSiagCmd)
+	walletAccountsCmd.AddCommand(walletAccountsAddressCmd, walletAccountsAssignCmd, walletAccountsAssignRangeCmd,
+		walletAccountsCreateCmd, walletAccountsDeleteCmd)
 	walletSendCmd.AddCommand(walletSendSiacoinsCmd, walletSendSiafundsCmd)
+	walletSendSiacoinsCmd.Flags().StringVarP(&walletAccount, "account", "a", "", "Only fund the transaction from the given account")
+	walletExportCmd.Flags().StringVarP(&walletExportFormat, "format", "f", "csv", "Format of the ledger, 'csv' or 'json'")
+	walletExportCmd.Flags().Uint64Var(&walletExportStartHeight, "start-height", 0, "First block height of the ledger")
+	walletExportCmd.Flags().Uint64Var(&walletExportEndHeight, "end-height", 0, "Last block height of the ledger, 0 for the current height")
+	walletExportCmd.Flags().StringVar(&walletExportStartDate, "start-date", "", "First day of the ledger (YYYY-MM-DD, UTC)")
+	walletExportCmd.Flags().StringVar(&walletExportEndDate, "end-date", "", "Last day of the ledger (YYYY-MM-DD, UTC)")
+	walletExportCmd.Flags().StringVar(&walletExportAddresses, "addresses", "", "Comma-separated addresses to export the ledger for")
+	walletExportCmd.Flags().StringVar(&walletAccount, "account", "", "Account to export the ledger for")
 	walletUnlockCmd.Flags().BoolVarP(&initPassword, "password", "p", false, "Display interactive password prompt even if SIA_WALLET_PASSWORD is set")
 	walletBroadcastCmd.Flags().BoolVarP(&walletRawTxn, "raw", "", false, "Decode transaction as base64 instead of JSON")
 	walletSignCmd.Flags().BoolVarP(&walletRawTxn, "raw", "", false, "Encode signed transaction as base64 instead of JSON")
@@ -175,23 +215,41 @@ func main() {
 	root.AddCommand(renterCmd)
 	renterCmd.AddCommand(renterFilesDeleteCmd, renterFilesDownloadCmd,
 		renterDownloadsCmd, renterAllowanceCmd, renterSetAllowanceCmd,
-		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd,
+		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd, renterFilesCopyCmd,
 		renterFilesUploadCmd, renterUploadsCmd, renterExportCmd,
 		renterPricesCmd, renterBackupCreateCmd, renterBackupLoadCmd,
 		renterBackupListCmd, renterTriggerContractRecoveryScanCmd, renterFilesUnstuckCmd,
-		renterContractsRecoveryScanProgressCmd, renterDownloadCancelCmd)
+		renterContractsRecoveryScanProgressCmd, renterDownloadCancelCmd,
+		renterSyncCmd, renterShareCmd, renterShareASCIICmd, renterLoadCmd,
+		renterLoadASCIICmd, renterVersionsCmd, renterRestoreCmd,
+		renterVersionPolicyCmd, renterTrashCmd, renterDrainCmd, renterChunkCacheCmd,
+		renterMetadataCmd, renterFindCmd, renterFileCmd)
 
 	renterContractsCmd.AddCommand(renterContractsViewCmd)
 	renterAllowanceCmd.AddCommand(renterAllowanceCancelCmd)
+	renterSyncCmd.AddCommand(renterSyncAddCmd, renterSyncRemoveCmd)
+	renterVersionPolicyCmd.AddCommand(renterVersionPolicySetCmd, renterVersionPolicyRemoveCmd)
+	renterTrashCmd.AddCommand(renterTrashRestoreCmd, renterTrashPurgeCmd, renterTrashEmptyCmd, renterTrashRetentionCmd)
+	renterChunkCacheCmd.AddCommand(renterChunkCacheSizeCmd)
+	renterMetadataCmd.AddCommand(renterMetadataSetCmd, renterMetadataRemoveCmd)
+	renterFileCmd.AddCommand(renterFileChecksumCmd)
 
 	renterCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
 	renterContractsCmd.Flags().BoolVarP(&renterAllContracts, "all", "A", false, "Show all expired contracts in addition to active contracts")
 	renterDownloadsCmd.Flags().BoolVarP(&renterShowHistory, "history", "H", false, "Show download history in addition to the download queue")
 	renterFilesDownloadCmd.Flags().BoolVarP(&renterDownloadAsync, "async", "A", false, "Download file asynchronously")
+	renterDrainCmd.Flags().BoolVarP(&renterDrainAsync, "async", "A", false, "Start the drain without waiting for it to finish")
 	renterFilesDownloadCmd.Flags().BoolVarP(&renterDownloadRecursive, "recursive", "R", false, "Download folder recursively")
+	renterFilesDownloadCmd.Flags().StringVarP(&renterDownloadFormat, "format", "f", "", "Download folder and its subfolders as a 'tar' or 'zip' archive")
 	renterFilesListCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
 	renterFilesListCmd.Flags().BoolVarP(&renterListRecursive, "recursive", "R", false, "Recursively list files and folders")
+	renterFilesUploadCmd.Flags().BoolVar(&renterUploadPack, "pack", false, "Pack small files into chunks shared with other small files")
+	renterVersionPolicySetCmd.Flags().StringVar(&renterVersionMaxAge, "max-age", "", "maximum age of old versions, e.g. 720h (default: no maximum)")
 	renterExportCmd.AddCommand(renterExportContractTxnsCmd)
+	renterShareCmd.Flags().BoolVarP(&renterShareEncrypt, "password", "p", false, "Prompt for a password to encrypt the share")
+	renterShareASCIICmd.Flags().BoolVarP(&renterShareEncrypt, "password", "p", false, "Prompt for a password to encrypt the share")
+	renterLoadCmd.Flags().BoolVarP(&renterShareEncrypt, "password", "p", false, "Prompt for the password of an encrypted share")
+	renterLoadASCIICmd.Flags().BoolVarP(&renterShareEncrypt, "password", "p", false, "Prompt for the password of an encrypted share")
 
 	renterSetAllowanceCmd.Flags().StringVar(&allowanceFunds, "amount", "", "amount of money in allowance, specified in currency units")
 	renterSetAllowanceCmd.Flags().StringVar(&allowancePeriod, "period", "", "period of allowance in blocks (b), hours (h), days (d) or weeks (w)")
@@ -201,6 +259,12 @@ func main() {
 	renterSetAllowanceCmd.Flags().StringVar(&allowanceExpectedUpload, "expected-upload", "", "expected upload in period in bytes (B), kilobytes (KB), megabytes (MB) etc. up to yottabytes (YB)")
 	renterSetAllowanceCmd.Flags().StringVar(&allowanceExpectedDownload, "expected-download", "", "expected download in period in bytes (B), kilobytes (KB), megabytes (MB) etc. up to yottabytes (YB)")
 	renterSetAllowanceCmd.Flags().StringVar(&allowanceExpectedRedundancy, "expected-redundancy", "", "expected redundancy of most uploaded files")
+	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxRPCPrice, "max-rpc-price", "", "maximum base RPC price of hosts, specified in currency units")
+	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxContractPrice, "max-contract-price", "", "maximum contract price of hosts, specified in currency units")
+	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxDownloadBandwidthPrice, "max-download-bandwidth-price", "", "maximum download bandwidth price of hosts, specified in currency units per TB")
+	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxSectorAccessPrice, "max-sector-access-price", "", "maximum sector access price of hosts, specified in currency units")
+	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxStoragePrice, "max-storage-price", "", "maximum storage price of hosts, specified in currency units per TB per month")
+	renterSetAllowanceCmd.Flags().StringVar(&allowanceMaxUploadBandwidthPrice, "max-upload-bandwidth-price", "", "maximum upload bandwidth price of hosts, specified in currency units per TB")
 
 	root.AddCommand(gatewayCmd)
 	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayAddressCmd, gatewayListCmd, gatewayRatelimitCmd)
@@ -218,6 +282,11 @@ func main() {
 	root.PersistentFlags().StringVarP(&httpClient.Password, "apipassword", "", "", "the password for the API's http authentication")
 	root.PersistentFlags().StringVarP(&siaDir, "sia-directory", "d", build.DefaultSiaDir(), "location of the sia directory")
 	root.PersistentFlags().StringVarP(&httpClient.UserAgent, "useragent", "", "Sia-Agent", "the useragent used by siac to connect to the daemon's API")
+	root.PersistentFlags().BoolVarP(&apiTLS, "api-tls", "", false, "connect to the daemon's API over TLS")
+	root.PersistentFlags().StringVarP(&apiTLSCA, "api-tls-ca", "", "", "certificate authority of the API's TLS certificate, implies --api-tls")
+	root.PersistentFlags().StringVarP(&apiTLSCert, "api-tls-cert", "", "", "client certificate for the API, implies --api-tls")
+	root.PersistentFlags().StringVarP(&apiTLSKey, "api-tls-key", "", "", "key of the client certificate for the API")
+	root.PersistentFlags().StringVarP(&httpClient.UnixSocket, "api-unix-socket", "", "", "unix socket of the daemon's API, overrides --addr")
 
 	// Check if the api password environment variable is set.
 	apiPassword := os.Getenv("SIA_API_PASSWORD")
@@ -240,6 +309,13 @@ func main() {
 			}
 
 		}
+		if apiTLS || apiTLSCA != "" || apiTLSCert != "" {
+			tlsConfig, err := client.NewTLSConfig(apiTLSCA, apiTLSCert, apiTLSKey)
+			if err != nil {
+				die("Could not load TLS configuration:", err)
+			}
+			httpClient.TLSConfig = tlsConfig
+		}
 	})
 
 	// run
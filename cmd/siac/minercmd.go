@@ -2,6 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -27,6 +31,45 @@ var (
 		Long:  "Stop mining (this may take a few moments).",
 		Run:   wrap(minerstopcmd),
 	}
+
+	minerConfigCmd = &cobra.Command{
+		Use:   "config [setting] [value]",
+		Short: "Modify miner settings",
+		Long: `Modify miner settings.
+
+Available settings:
+     payouts:      comma-separated list of address:percentage pairs, e.g.
+                   "<address1>:60,<address2>:40". The percentages must add up
+                   to 100. An empty list pays the block reward to the wallet.
+     coinbasedata: string that is included in every mined block
+
+Example:
+     siac miner config coinbasedata "/mined by me/"`,
+		Run: wrap(minerconfigcmd),
+	}
+
+	minerStratumCmd = &cobra.Command{
+		Use:   "stratum",
+		Short: "View the status of the stratum server",
+		Long:  "View the status of the stratum server and the statistics of its workers.",
+		Run:   wrap(minerstratumcmd),
+	}
+
+	minerStratumStartCmd = &cobra.Command{
+		Use:   "start [address] [difficulty]",
+		Short: "Start the stratum server",
+		Long: `Start a stratum server for external miners on the given address, e.g.
+":9983". Workers are sent shares of the given difficulty, which is lowered to
+the block difficulty if the blocks are easier.`,
+		Run: wrap(minerstratumstartcmd),
+	}
+
+	minerStratumStopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the stratum server",
+		Long:  "Stop the stratum server and disconnect all of its workers.",
+		Run:   wrap(minerstratumstopcmd),
+	}
 )
 
 // minerstartcmd is the handler for the command `siac miner start`.
@@ -56,6 +99,32 @@ CPU Mining:   %s
 CPU Hashrate: %v KH/s
 Blocks Mined: %d (%d stale)
 `, miningStr, status.CPUHashrate/1000, status.BlocksMined, status.StaleBlocksMined)
+	if len(status.Payouts) == 0 {
+		fmt.Println("Payouts:      wallet")
+	} else {
+		fmt.Println("Payouts:")
+		for _, payout := range status.Payouts {
+			fmt.Printf("  %v: %v%%\n", payout.UnlockHash, payout.Percentage)
+		}
+	}
+	if status.CoinbaseData != "" {
+		fmt.Printf("Coinbase:     %q\n", status.CoinbaseData)
+	}
+}
+
+// minerconfigcmd is the handler for the command `siac miner config [setting]
+// [value]`. Changes a setting of the miner.
+func minerconfigcmd(param, value string) {
+	switch param {
+	case "payouts", "coinbasedata":
+	default:
+		die("\"" + param + "\" is not a miner setting")
+	}
+	err := httpClient.MinerModifySettingPost(param, value)
+	if err != nil {
+		die("Failed to update miner settings:", err)
+	}
+	fmt.Println("Miner settings updated.")
 }
 
 // minerstopcmd is the handler for the command `siac miner stop`.
@@ -67,3 +136,60 @@ func minerstopcmd() {
 	}
 	fmt.Println("Stopped mining.")
 }
+
+// minerstratumcmd is the handler for the command `siac miner stratum`.
+// Prints the status of the stratum server.
+func minerstratumcmd() {
+	status, err := httpClient.MinerStratumGet()
+	if err != nil {
+		die("Could not get stratum status:", err)
+	}
+	if !status.Running {
+		fmt.Println("Stratum server is not running.")
+		return
+	}
+	fmt.Printf(`Stratum status:
+Address:          %s
+Share Difficulty: %d
+Connections:      %d
+`, status.Address, status.ShareDifficulty, status.Connections)
+	if len(status.Workers) == 0 {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Worker\tHashrate\tAccepted\tStale\tRejected\tBlocks\tLast Share")
+	for _, worker := range status.Workers {
+		lastShare := "-"
+		if worker.LastShare != 0 {
+			lastShare = time.Unix(int64(worker.LastShare), 0).Format(time.RFC822)
+		}
+		fmt.Fprintf(w, "%s\t%v KH/s\t%d\t%d\t%d\t%d\t%s\n", worker.Name, worker.Hashrate/1000,
+			worker.AcceptedShares, worker.StaleShares, worker.RejectedShares, worker.BlocksFound, lastShare)
+	}
+	w.Flush()
+}
+
+// minerstratumstartcmd is the handler for the command `siac miner stratum
+// start [address] [difficulty]`. Starts the stratum server.
+func minerstratumstartcmd(address, difficultyStr string) {
+	difficulty, err := strconv.ParseUint(difficultyStr, 10, 64)
+	if err != nil {
+		die("Could not parse difficulty:", err)
+	}
+	err = httpClient.MinerStratumStartPost(address, difficulty)
+	if err != nil {
+		die("Could not start stratum server:", err)
+	}
+	fmt.Println("Stratum server is now running.")
+}
+
+// minerstratumstopcmd is the handler for the command `siac miner stratum
+// stop`. Stops the stratum server.
+func minerstratumstopcmd() {
+	err := httpClient.MinerStratumStopPost()
+	if err != nil {
+		die("Could not stop stratum server:", err)
+	}
+	fmt.Println("Stopped stratum server.")
+}
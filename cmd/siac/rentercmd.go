@@ -6,6 +6,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -107,15 +108,18 @@ var (
 		Use:     "delete [path]",
 		Aliases: []string{"rm"},
 		Short:   "Delete a file or folder",
-		Long:    "Delete a file or folder. Does not delete the file/folder on disk.",
+		Long:    "Delete a file or folder. Does not delete the file/folder on disk. If the trash is enabled, the file or folder is moved to the trash, see 'siac renter trash'.",
 		Run:     wrap(renterfilesdeletecmd),
 	}
 
 	renterFilesDownloadCmd = &cobra.Command{
 		Use:   "download [path] [destination]",
 		Short: "Download a file or folder",
-		Long:  "Download a previously-uploaded file or folder to a specified destination.",
-		Run:   wrap(renterfilesdownloadcmd),
+		Long: `Download a previously-uploaded file or folder to a specified destination.
+
+Use --recursive to recreate the subfolders of a folder on disk, or --format to
+download a folder and its subfolders as a single 'tar' or 'zip' archive.`,
+		Run: wrap(renterfilesdownloadcmd),
 	}
 
 	renterFilesListCmd = &cobra.Command{
@@ -133,6 +137,16 @@ var (
 		Run:     wrap(renterfilesrenamecmd),
 	}
 
+	renterFilesCopyCmd = &cobra.Command{
+		Use:     "copy [path] [newpath]",
+		Aliases: []string{"cp"},
+		Short:   "Copy a file",
+		Long: `Copy a file without reuploading it. The copy shares the data stored on the
+hosts with the original file, so no additional storage is used until one of
+the files is repaired.`,
+		Run: wrap(renterfilescopycmd),
+	}
+
 	renterFilesUnstuckCmd = &cobra.Command{
 		Use:   "unstuckall",
 		Short: "Set all files to unstuck",
@@ -143,8 +157,11 @@ var (
 	renterFilesUploadCmd = &cobra.Command{
 		Use:   "upload [source] [path]",
 		Short: "Upload a file or folder",
-		Long:  "Upload a file or folder to [path] on the Sia network.",
-		Run:   wrap(renterfilesuploadcmd),
+		Long: `Upload a file or folder to [path] on the Sia network.
+
+With --pack, small files are packed into chunks that are shared with other
+small files, which reduces the cost of storing them.`,
+		Run: wrap(renterfilesuploadcmd),
 	}
 
 	renterPricesCmd = &cobra.Command{
@@ -166,6 +183,12 @@ If no flags are set you will be walked through the interactive allowance
 setting. To update only certain fields, pass in those values with the
 corresponding field flag, for example '--amount 500SC'.
 
+The optional price caps protect against hosts that raise their prices. New
+contracts are not formed with hosts whose prices exceed a cap, and existing
+contracts with such hosts are not renewed. Caps are specified in the same units
+as the host settings, for example '--max-storage-price 200SC' for a maximum of
+200SC / TB / Month. A cap of 0 removes it.
+
 Allowance can be automatically renewed periodically. If the current
 blockheight + the renew window >= the end height the contract,
 then the contract is renewed automatically.
@@ -176,6 +199,225 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run: rentersetallowancecmd,
 	}
 
+	renterLoadCmd = &cobra.Command{
+		Use:   "load [source]",
+		Short: "Load shared files",
+		Long:  "Load the files of the .sia file at [source] into the renter. Use --password if the share is encrypted.",
+		Run:   wrap(renterloadcmd),
+	}
+
+	renterLoadASCIICmd = &cobra.Command{
+		Use:   "loadascii [asciisia]",
+		Short: "Load shared files from a string",
+		Long:  "Load the files of a share created with 'siac renter shareascii' into the renter. Use --password if the share is encrypted.",
+		Run:   wrap(renterloadasciicmd),
+	}
+
+	renterShareCmd = &cobra.Command{
+		Use:   "share [siapaths] [destination]",
+		Short: "Share files with another renter",
+		Long: `Write the files at the comma-separated [siapaths] to the .sia file at
+[destination]. Another renter can load the file with 'siac renter load' and
+download the files using its own contracts.
+
+The share contains the keys of the files. Use --password to encrypt it.`,
+		Run: wrap(rentersharecmd),
+	}
+
+	renterShareASCIICmd = &cobra.Command{
+		Use:   "shareascii [siapaths]",
+		Short: "Share files with another renter as a string",
+		Long: `Print the files at the comma-separated [siapaths] as a string that can be
+loaded by another renter with 'siac renter loadascii'.
+
+The share contains the keys of the files. Use --password to encrypt it.`,
+		Run: wrap(rentershareasciicmd),
+	}
+
+	renterSyncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "View the local directories that are synced",
+		Long: `View the local directories that are synced with the Sia network.
+
+New and modified files in a synced directory are uploaded automatically, and
+files that are deleted locally are deleted from the renter.`,
+		Run: wrap(rentersynccmd),
+	}
+
+	renterSyncAddCmd = &cobra.Command{
+		Use:   "add [localpath] [siapath]",
+		Short: "Sync a local directory",
+		Long:  "Keep [siapath] in sync with the contents of the local directory [localpath].",
+		Run:   wrap(rentersyncaddcmd),
+	}
+
+	renterSyncRemoveCmd = &cobra.Command{
+		Use:   "remove [siapath]",
+		Short: "Stop syncing a local directory",
+		Long:  "Stop syncing the local directory that is linked to [siapath]. Files that were already uploaded are not deleted.",
+		Run:   wrap(rentersyncremovecmd),
+	}
+
+	renterVersionsCmd = &cobra.Command{
+		Use:   "versions [path]",
+		Short: "List the old versions of a file",
+		Long: `List the old versions of a file. When a file is overwritten, the previous
+version is kept according to the version policy of the file's directory.`,
+		Run: wrap(renterversionscmd),
+	}
+
+	renterRestoreCmd = &cobra.Command{
+		Use:   "restore [path] [id]",
+		Short: "Restore an old version of a file",
+		Long: `Restore the old version [id] of a file. The current version of the file
+is kept as an old version itself.`,
+		Run: wrap(renterrestorecmd),
+	}
+
+	renterVersionPolicyCmd = &cobra.Command{
+		Use:   "versionpolicy",
+		Short: "View the version policies",
+		Long: `View the version policies of the renter. A version policy determines how
+many old versions of overwritten files are kept, and for how long. A policy
+applies to a directory and all of its subdirectories that don't have a policy
+of their own. Old versions are stored on the network and count towards the
+renter's storage costs. By default 3 versions are kept.`,
+		Run: wrap(renterversionpolicycmd),
+	}
+
+	renterVersionPolicySetCmd = &cobra.Command{
+		Use:   "set [path] [maxversions]",
+		Short: "Set the version policy of a directory",
+		Long: `Keep up to [maxversions] old versions of the files in the directory
+[path] and its subdirectories. Use / for the root directory. A [maxversions]
+of 0 disables versioning. Use --max-age to also delete old versions once they
+reach a certain age.`,
+		Run: wrap(renterversionpolicysetcmd),
+	}
+
+	renterVersionPolicyRemoveCmd = &cobra.Command{
+		Use:   "remove [path]",
+		Short: "Remove the version policy of a directory",
+		Long:  "Remove the version policy of the directory [path], so that the policy of its parent directory applies again.",
+		Run:   wrap(renterversionpolicyremovecmd),
+	}
+
+	renterTrashCmd = &cobra.Command{
+		Use:   "trash",
+		Short: "View the trash",
+		Long: `View the files and folders in the trash. Deleted files and folders are
+moved to the trash, where they are kept and repaired until the trash retention
+has passed. Files in the trash count towards the renter's storage costs. A
+retention of 0 disables the trash.`,
+		Run: wrap(rentertrashcmd),
+	}
+
+	renterTrashRestoreCmd = &cobra.Command{
+		Use:   "restore [id]",
+		Short: "Restore a file or folder from the trash",
+		Long:  "Move the file or folder with the trash id [id] back to its original path.",
+		Run:   wrap(rentertrashrestorecmd),
+	}
+
+	renterTrashPurgeCmd = &cobra.Command{
+		Use:   "purge [id]",
+		Short: "Permanently delete a file or folder from the trash",
+		Long:  "Permanently delete the file or folder with the trash id [id].",
+		Run:   wrap(rentertrashpurgecmd),
+	}
+
+	renterTrashEmptyCmd = &cobra.Command{
+		Use:   "empty",
+		Short: "Permanently delete all files and folders in the trash",
+		Long:  "Permanently delete all files and folders in the trash.",
+		Run:   wrap(rentertrashemptycmd),
+	}
+
+	renterTrashRetentionCmd = &cobra.Command{
+		Use:   "retention [duration]",
+		Short: "Set the trash retention",
+		Long: `Set how long deleted files and folders are kept in the trash, e.g. 168h.
+A retention of 0 disables the trash and permanently deletes all files and
+folders in it. By default the trash keeps deleted files and folders for a week
+(168h).`,
+		Run: wrap(rentertrashretentioncmd),
+	}
+
+	renterDrainCmd = &cobra.Command{
+		Use:   "drain [hostkey]",
+		Short: "Migrate all data away from a host",
+		Long: `Migrate every piece stored on the host with the public key [hostkey] to
+other hosts and cancel the contract with the host once all pieces were
+migrated. The pieces are read from the local files if available and downloaded
+otherwise. Use --async to return right away instead of reporting the progress
+until the drain is finished.`,
+		Run: wrap(renterdraincmd),
+	}
+
+	renterChunkCacheCmd = &cobra.Command{
+		Use:   "chunkcache",
+		Short: "View the chunk cache",
+		Long: `View the usage of the on-disk cache of downloaded chunks. Downloads,
+streams and repairs serve chunks from the cache instead of fetching them from
+the hosts again.`,
+		Run: wrap(renterchunkcachecmd),
+	}
+
+	renterChunkCacheSizeCmd = &cobra.Command{
+		Use:   "setsize [size]",
+		Short: "Set the maximum size of the chunk cache",
+		Long: `Set the maximum size of the chunk cache, e.g. 10GB. The least recently
+used chunks are evicted once the cache is full. A size of 0B disables the cache.`,
+		Run: wrap(renterchunkcachesizecmd),
+	}
+
+	renterMetadataCmd = &cobra.Command{
+		Use:   "metadata [path]",
+		Short: "View the user metadata of a file",
+		Long: `View the custom key/value metadata of a file. The key 'content-type' is
+used as the Content-Type when streaming the file and the key 'tags' holds a
+comma-separated list of tags.`,
+		Run: wrap(rentermetadatacmd),
+	}
+
+	renterMetadataSetCmd = &cobra.Command{
+		Use:   "set [path] [key] [value]",
+		Short: "Set a user metadata key of a file",
+		Long:  "Set the user metadata [key] of a file to [value], overwriting the previous value of the key.",
+		Run:   wrap(rentermetadatasetcmd),
+	}
+
+	renterMetadataRemoveCmd = &cobra.Command{
+		Use:   "remove [path] [key]",
+		Short: "Remove a user metadata key of a file",
+		Long:  "Remove the user metadata [key] of a file.",
+		Run:   wrap(rentermetadataremovecmd),
+	}
+
+	renterFileCmd = &cobra.Command{
+		Use:   "file",
+		Short: "Perform actions on a file",
+		Long:  "Perform actions on a single file.",
+		// Run field not provided; file requires a subcommand.
+	}
+
+	renterFileChecksumCmd = &cobra.Command{
+		Use:   "checksum [path]",
+		Short: "Print the SHA-256 checksum of a file",
+		Long: `Print the SHA-256 checksum of the uploaded data of a file in the format of
+sha256sum. Downloads are verified against the SHA-256 checksums of the file's
+chunks. The checksum of a file that is uploaded from disk is known once every
+chunk was read for the upload.`,
+		Run: wrap(renterfilechecksumcmd),
+	}
+
+	renterFindCmd = &cobra.Command{
+		Use:   "find [tag]",
+		Short: "Find files by tag",
+		Long:  "List all files whose 'tags' user metadata contains [tag].",
+		Run:   wrap(renterfindcmd),
+	}
+
 	renterTriggerContractRecoveryScanCmd = &cobra.Command{
 		Use:   "triggerrecoveryscan",
 		Short: "Triggers a recovery scan.",
@@ -245,14 +487,39 @@ func renterFilesAndContractSummary() error {
 	if err != nil {
 		return err
 	}
+	rg, err := httpClient.RenterGet()
+	if err != nil {
+		return err
+	}
+	rt, err := httpClient.RenterTrashGet()
+	if err != nil {
+		return err
+	}
 
+	// Old versions and the trash are stored in hidden directories, but they
+	// are included in the totals of the root directory.
+	vs := rg.VersionStorage
+	var trashFiles, trashSize uint64
+	for _, e := range rt.Entries {
+		trashFiles += e.NumFiles
+		trashSize += e.Size
+	}
+	numFiles := rf.Directories[0].AggregateNumFiles
+	if numFiles >= vs.NumVersions+trashFiles {
+		numFiles -= vs.NumVersions + trashFiles
+	}
 	fmt.Printf(`
   Files:          %v
   Total Stored:   %v
+  Old Versions:   %v (%v, %v uploaded)
+  Trash:          %v files (%v)
   Min Redundancy: %v
   Contracts:      %v
 
-`, rf.Directories[0].AggregateNumFiles, filesizeUnits(rf.Directories[0].AggregateSize), rf.Directories[0].AggregateMinRedundancy, len(rc.ActiveContracts))
+`, numFiles, filesizeUnits(rf.Directories[0].AggregateSize),
+		vs.NumVersions, filesizeUnits(vs.Size), filesizeUnits(vs.UploadedBytes),
+		trashFiles, filesizeUnits(trashSize),
+		rf.Directories[0].AggregateMinRedundancy, len(rc.ActiveContracts))
 
 	return nil
 }
@@ -359,6 +626,28 @@ Expectations for period:
 `, currencyUnits(allowance.Funds), allowance.Period, allowance.RenewWindow, allowance.Hosts, filesizeUnits(allowance.ExpectedStorage),
 		filesizeUnits(allowance.ExpectedUpload), filesizeUnits(allowance.ExpectedDownload), allowance.ExpectedRedundancy)
 
+	// Show the price caps, converted to the units of the host settings.
+	priceCap := func(price types.Currency, unit types.Currency, suffix string) string {
+		if price.IsZero() {
+			return "none"
+		}
+		return currencyUnits(price.Mul(unit)) + suffix
+	}
+	one := types.NewCurrency64(1)
+	fmt.Printf(`
+Price caps:
+	Max RPC Price:                %v
+	Max Contract Price:           %v
+	Max Download Bandwidth Price: %v
+	Max Sector Access Price:      %v
+	Max Storage Price:            %v
+	Max Upload Bandwidth Price:   %v
+`, priceCap(allowance.MaxRPCPrice, one, ""), priceCap(allowance.MaxContractPrice, one, ""),
+		priceCap(allowance.MaxDownloadBandwidthPrice, modules.BytesPerTerabyte, " / TB"),
+		priceCap(allowance.MaxSectorAccessPrice, one, ""),
+		priceCap(allowance.MaxStoragePrice, modules.BlockBytesPerMonthTerabyte, " / TB / Month"),
+		priceCap(allowance.MaxUploadBandwidthPrice, modules.BytesPerTerabyte, " / TB"))
+
 	// Show spending detail
 	fm := rg.FinancialMetrics
 	totalSpent := fm.ContractFees.Add(fm.UploadSpending).
@@ -552,6 +841,37 @@ func rentersetallowancecmd(cmd *cobra.Command, args []string) {
 		req = req.WithExpectedRedundancy(expectedRedundancy)
 		changedFields++
 	}
+	// parse price caps, converting them from the units of the host settings.
+	one := types.NewCurrency64(1)
+	priceCaps := []struct {
+		name  string
+		value string
+		unit  types.Currency
+		set   func(types.Currency) *client.AllowanceRequestPost
+	}{
+		{"max RPC price", allowanceMaxRPCPrice, one, req.WithMaxRPCPrice},
+		{"max contract price", allowanceMaxContractPrice, one, req.WithMaxContractPrice},
+		{"max download bandwidth price", allowanceMaxDownloadBandwidthPrice, modules.BytesPerTerabyte, req.WithMaxDownloadBandwidthPrice},
+		{"max sector access price", allowanceMaxSectorAccessPrice, one, req.WithMaxSectorAccessPrice},
+		{"max storage price", allowanceMaxStoragePrice, modules.BlockBytesPerMonthTerabyte, req.WithMaxStoragePrice},
+		{"max upload bandwidth price", allowanceMaxUploadBandwidthPrice, modules.BytesPerTerabyte, req.WithMaxUploadBandwidthPrice},
+	}
+	for _, pc := range priceCaps {
+		if pc.value == "" {
+			continue
+		}
+		hastings, err := parseCurrency(pc.value)
+		if err != nil {
+			die("Could not parse "+pc.name+":", err)
+		}
+		var price types.Currency
+		_, err = fmt.Sscan(hastings, &price)
+		if err != nil {
+			die("Could not parse "+pc.name+":", err)
+		}
+		pc.set(price.Div(pc.unit))
+		changedFields++
+	}
 	// check if any fields were updated.
 	if changedFields == 0 {
 		// If no fields were set then walk the user through the interactive
@@ -1450,6 +1770,11 @@ func renterdirdownload(path, destination string) {
 	if err != nil {
 		die("Failed to parse SiaPath:", err)
 	}
+	// Download the dir as an archive if a format was specified.
+	if renterDownloadFormat != "" {
+		renterdirdownloadarchive(siaPath, destination)
+		return
+	}
 	// Download dir.
 	start := time.Now()
 	tfs, skipped, totalSize, downloadErr := downloadDir(siaPath, destination)
@@ -1482,6 +1807,36 @@ func renterdirdownload(path, destination string) {
 	os.Exit(1)
 }
 
+// renterdirdownloadarchive downloads the dir at the given path and its subdirs
+// as an archive to the local specified destination.
+func renterdirdownloadarchive(siaPath modules.SiaPath, destination string) {
+	// If the destination is a folder, download the archive to that folder.
+	fi, err := os.Stat(destination)
+	if err == nil && fi.IsDir() {
+		name := siaPath.Name()
+		if siaPath.IsRoot() {
+			name = "sia"
+		}
+		destination = filepath.Join(destination, name+"."+renterDownloadFormat)
+	}
+	start := time.Now()
+	archive, err := httpClient.RenterDownloadArchiveGet(siaPath, renterDownloadFormat)
+	if err != nil {
+		die("Failed to download folder:", err)
+	}
+	defer archive.Close()
+	f, err := os.Create(destination)
+	if err != nil {
+		die("Failed to create destination:", err)
+	}
+	n, err := io.Copy(f, archive)
+	if err = errors.Compose(err, f.Close()); err != nil {
+		os.Remove(destination)
+		die("Failed to download folder:", err)
+	}
+	fmt.Printf("Downloaded '%s' to '%s - %v in %v'.\n", siaPath, destination, filesizeUnits(uint64(n)), time.Since(start).Round(time.Millisecond))
+}
+
 // renterdownloadcancelcmd is the handler for the command `siac renter download cancel [cancelID]`
 // Cancels the ongoing download.
 func renterdownloadcancelcmd(cancelID string) {
@@ -1944,6 +2299,20 @@ func renterfilesrenamecmd(path, newpath string) {
 	fmt.Printf("Renamed %s to %s\n", path, newpath)
 }
 
+// renterfilescopycmd is the handler for the command `siac renter copy [path]
+// [newpath]`. It copies a file without reuploading it.
+func renterfilescopycmd(path, newpath string) {
+	siaPath, err1 := modules.NewSiaPath(path)
+	newSiaPath, err2 := modules.NewSiaPath(newpath)
+	if err := errors.Compose(err1, err2); err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	if err := httpClient.RenterCopyPost(siaPath, newSiaPath); err != nil {
+		die("Could not copy file:", err)
+	}
+	fmt.Printf("Copied %s to %s\n", path, newpath)
+}
+
 // renterfilesunstuckcmd is the handler for the command `siac renter
 // unstuckall`. Sets all files to unstuck.
 func renterfilesunstuckcmd() {
@@ -1999,7 +2368,7 @@ func renterfilesuploadcmd(source, path string) {
 			if err != nil {
 				die("Couldn't parse SiaPath:", err)
 			}
-			err = httpClient.RenterUploadDefaultPost(abs(file), fSiaPath)
+			err = renterUploadDefaultPost(abs(file), fSiaPath)
 			if err != nil {
 				failed++
 				fmt.Printf("Could not upload file %s :%v\n", file, err)
@@ -2013,7 +2382,7 @@ func renterfilesuploadcmd(source, path string) {
 		if err != nil {
 			die("Couldn't parse SiaPath:", err)
 		}
-		err = httpClient.RenterUploadDefaultPost(abs(source), siaPath)
+		err = renterUploadDefaultPost(abs(source), siaPath)
 		if err != nil {
 			die("Could not upload file:", err)
 		}
@@ -2021,6 +2390,15 @@ func renterfilesuploadcmd(source, path string) {
 	}
 }
 
+// renterUploadDefaultPost uploads a file with the default redundancy settings,
+// packing it if the --pack flag was supplied.
+func renterUploadDefaultPost(source string, siaPath modules.SiaPath) error {
+	if renterUploadPack {
+		return httpClient.RenterUploadPackPost(source, siaPath)
+	}
+	return httpClient.RenterUploadDefaultPost(source, siaPath)
+}
+
 // renterpricescmd is the handler for the command `siac renter prices`, which
 // displays the prices of various storage operations. The user can submit an
 // allowance to have the estimate reflect those settings or the user can submit
@@ -2089,3 +2467,525 @@ func renterpricescmd(cmd *cobra.Command, args []string) {
 	fmt.Fprintln(w, "\tRenew Window:\t", rpg.Allowance.RenewWindow)
 	w.Flush()
 }
+
+// rentersynccmd is the handler for the command `siac renter sync`. It lists
+// the local directories that are synced by the renter.
+func rentersynccmd() {
+	rsd, err := httpClient.RenterSyncDirsGet()
+	if err != nil {
+		die("Could not get sync dirs:", err)
+	}
+	if len(rsd.SyncDirs) == 0 {
+		fmt.Println("No directories are being synced.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Local Path\tSia Path\tFiles\tPending\tUploads\tDeletions\tLast Sync")
+	for _, sd := range rsd.SyncDirs {
+		lastSync := "never"
+		if !sd.LastSync.IsZero() {
+			lastSync = sd.LastSync.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", sd.LocalPath, sd.SiaPath, sd.Files, sd.PendingFiles, sd.Uploads, sd.Deletions, lastSync)
+	}
+	w.Flush()
+	for _, sd := range rsd.SyncDirs {
+		if sd.LastError != "" {
+			fmt.Printf("\nError syncing %v: %v\n", sd.LocalPath, sd.LastError)
+		}
+	}
+}
+
+// rentersyncaddcmd is the handler for the command `siac renter sync add
+// [localpath] [siapath]`. It links a local directory to a siapath.
+func rentersyncaddcmd(localPath, path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	err = httpClient.RenterSyncDirsAddPost(abs(localPath), siaPath)
+	if err != nil {
+		die("Could not sync directory:", err)
+	}
+	fmt.Printf("Syncing %v with %v.\n", abs(localPath), siaPath)
+}
+
+// rentersyncremovecmd is the handler for the command `siac renter sync remove
+// [siapath]`. It stops syncing the local directory linked to the siapath.
+func rentersyncremovecmd(path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	err = httpClient.RenterSyncDirsRemovePost(siaPath)
+	if err != nil {
+		die("Could not stop syncing directory:", err)
+	}
+	fmt.Printf("Stopped syncing %v.\n", siaPath)
+}
+
+// sharePassword prompts for the password of a share if --password was
+// provided.
+func sharePassword() string {
+	if !renterShareEncrypt {
+		return ""
+	}
+	password, err := passwordPrompt("Share password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	return password
+}
+
+// parseSharePaths parses the comma-separated siapaths of a share command.
+func parseSharePaths(paths string) []modules.SiaPath {
+	var siaPaths []modules.SiaPath
+	for _, path := range strings.Split(paths, ",") {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			die("Couldn't parse SiaPath:", err)
+		}
+		siaPaths = append(siaPaths, siaPath)
+	}
+	return siaPaths
+}
+
+// rentersharecmd is the handler for the command `siac renter share [siapaths]
+// [destination]`. It writes the files to a .sia file.
+func rentersharecmd(paths, destination string) {
+	err := httpClient.RenterShareGet(parseSharePaths(paths), abs(destination), sharePassword())
+	if err != nil {
+		die("Could not share files:", err)
+	}
+	fmt.Printf("Shared files to %v.\n", abs(destination))
+}
+
+// rentershareasciicmd is the handler for the command `siac renter shareascii
+// [siapaths]`. It prints the files as a base64 encoded string.
+func rentershareasciicmd(paths string) {
+	rsa, err := httpClient.RenterShareASCIIGet(parseSharePaths(paths), sharePassword())
+	if err != nil {
+		die("Could not share files:", err)
+	}
+	fmt.Println(rsa.ASCIIsia)
+}
+
+// renterloadcmd is the handler for the command `siac renter load [source]`. It
+// loads the files of a .sia file into the renter.
+func renterloadcmd(source string) {
+	rl, err := httpClient.RenterLoadPost(abs(source), sharePassword())
+	if err != nil {
+		die("Could not load files:", err)
+	}
+	printLoadedFiles(rl)
+}
+
+// renterloadasciicmd is the handler for the command `siac renter loadascii
+// [asciisia]`. It loads the files of a base64 encoded share into the renter.
+func renterloadasciicmd(asciisia string) {
+	rl, err := httpClient.RenterLoadASCIIPost(asciisia, sharePassword())
+	if err != nil {
+		die("Could not load files:", err)
+	}
+	printLoadedFiles(rl)
+}
+
+// printLoadedFiles prints the siapaths of the files added by a load command.
+func printLoadedFiles(rl api.RenterLoad) {
+	fmt.Printf("Loaded %v files:\n", len(rl.FilesAdded))
+	for _, path := range rl.FilesAdded {
+		fmt.Println("  " + path)
+	}
+}
+
+// parseDirSiaPath parses the siapath of a directory. The root directory can
+// be specified as / or an empty path.
+func parseDirSiaPath(path string) (modules.SiaPath, error) {
+	if path == "." || path == "" || path == "/" {
+		return modules.RootSiaPath(), nil
+	}
+	return modules.NewSiaPath(path)
+}
+
+// renterversionscmd is the handler for the command `siac renter versions
+// [path]`. It lists the old versions of a file.
+func renterversionscmd(path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	rv, err := httpClient.RenterVersionsGet(siaPath)
+	if err != nil {
+		die("Could not get versions:", err)
+	}
+	if len(rv.Versions) == 0 {
+		fmt.Println("No old versions of", siaPath)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tOverwritten\tSize\tUploaded\tRedundancy")
+	for _, v := range rv.Versions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.2f\n", v.ID, v.Created.Format("2006-01-02 15:04:05"),
+			filesizeUnits(v.Filesize), filesizeUnits(v.UploadedBytes), v.Redundancy)
+	}
+	w.Flush()
+}
+
+// renterrestorecmd is the handler for the command `siac renter restore [path]
+// [id]`. It restores an old version of a file.
+func renterrestorecmd(path, idStr string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		die("Couldn't parse version id:", err)
+	}
+	if err := httpClient.RenterRestoreVersionPost(siaPath, id); err != nil {
+		die("Could not restore version:", err)
+	}
+	fmt.Printf("Restored version %v of %v.\n", id, siaPath)
+}
+
+// renterversionpolicycmd is the handler for the command `siac renter
+// versionpolicy`. It lists the version policies of the renter.
+func renterversionpolicycmd() {
+	rvp, err := httpClient.RenterVersionPoliciesGet()
+	if err != nil {
+		die("Could not get version policies:", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Directory\tMax Versions\tMax Age")
+	for _, p := range rvp.Policies {
+		dir := "/" + p.SiaPath.String()
+		maxAge := "none"
+		if p.MaxAge > 0 {
+			maxAge = p.MaxAge.String()
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", dir, p.MaxVersions, maxAge)
+	}
+	w.Flush()
+}
+
+// renterversionpolicysetcmd is the handler for the command `siac renter
+// versionpolicy set [path] [maxversions]`. It sets the version policy of a
+// directory.
+func renterversionpolicysetcmd(path, maxVersions string) {
+	siaPath, err := parseDirSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	var policy modules.VersionPolicy
+	policy.MaxVersions, err = strconv.ParseUint(maxVersions, 10, 64)
+	if err != nil {
+		die("Couldn't parse maxversions:", err)
+	}
+	if renterVersionMaxAge != "" {
+		policy.MaxAge, err = time.ParseDuration(renterVersionMaxAge)
+		if err != nil {
+			die("Couldn't parse max age:", err)
+		}
+	}
+	if err := httpClient.RenterSetVersionPolicyPost(siaPath, policy); err != nil {
+		die("Could not set version policy:", err)
+	}
+	fmt.Printf("Set the version policy of /%v.\n", siaPath)
+}
+
+// renterversionpolicyremovecmd is the handler for the command `siac renter
+// versionpolicy remove [path]`. It removes the version policy of a directory.
+func renterversionpolicyremovecmd(path string) {
+	siaPath, err := parseDirSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	if err := httpClient.RenterRemoveVersionPolicyPost(siaPath); err != nil {
+		die("Could not remove version policy:", err)
+	}
+	fmt.Printf("Removed the version policy of /%v.\n", siaPath)
+}
+
+// rentertrashcmd is the handler for the command `siac renter trash`. It lists
+// the files and folders in the trash.
+func rentertrashcmd() {
+	rt, err := httpClient.RenterTrashGet()
+	if err != nil {
+		die("Could not get trash:", err)
+	}
+	if rt.Retention == 0 {
+		fmt.Println("Trash is disabled.")
+	} else {
+		fmt.Println("Retention:", rt.Retention)
+	}
+	if len(rt.Entries) == 0 {
+		fmt.Println("Trash is empty.")
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPath\tDeleted\tExpires\tSize")
+	for _, e := range rt.Entries {
+		path := e.SiaPath.String()
+		if e.IsDir {
+			path += "/"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.ID, path, e.Deleted.Format("2006-01-02 15:04:05"),
+			e.Expires.Format("2006-01-02 15:04:05"), filesizeUnits(e.Size))
+	}
+	w.Flush()
+}
+
+// parseTrashID parses the id of a trash entry.
+func parseTrashID(idStr string) uint64 {
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		die("Couldn't parse trash id:", err)
+	}
+	return id
+}
+
+// rentertrashrestorecmd is the handler for the command `siac renter trash
+// restore [id]`. It restores a file or folder from the trash.
+func rentertrashrestorecmd(idStr string) {
+	id := parseTrashID(idStr)
+	if err := httpClient.RenterTrashRestorePost(id); err != nil {
+		die("Could not restore trash entry:", err)
+	}
+	fmt.Printf("Restored trash entry %v.\n", id)
+}
+
+// rentertrashpurgecmd is the handler for the command `siac renter trash purge
+// [id]`. It permanently deletes a file or folder from the trash.
+func rentertrashpurgecmd(idStr string) {
+	id := parseTrashID(idStr)
+	if err := httpClient.RenterTrashPurgePost(id); err != nil {
+		die("Could not purge trash entry:", err)
+	}
+	fmt.Printf("Purged trash entry %v.\n", id)
+}
+
+// rentertrashemptycmd is the handler for the command `siac renter trash
+// empty`. It permanently deletes all files and folders in the trash.
+func rentertrashemptycmd() {
+	if err := httpClient.RenterTrashPurgeAllPost(); err != nil {
+		die("Could not empty trash:", err)
+	}
+	fmt.Println("Emptied the trash.")
+}
+
+// rentertrashretentioncmd is the handler for the command `siac renter trash
+// retention [duration]`. It sets how long deleted files and folders are kept
+// in the trash.
+func rentertrashretentioncmd(retentionStr string) {
+	retention, err := time.ParseDuration(retentionStr)
+	if err != nil {
+		die("Couldn't parse retention:", err)
+	}
+	if err := httpClient.RenterTrashRetentionPost(retention); err != nil {
+		die("Could not set trash retention:", err)
+	}
+	if retention == 0 {
+		fmt.Println("Disabled the trash.")
+		return
+	}
+	fmt.Println("Set the trash retention to", retention)
+}
+
+// renterdraincmd is the handler for the command `siac renter drain
+// [hostkey]`. It migrates all pieces away from a host and reports the
+// progress of the drain.
+func renterdraincmd(hostKeyStr string) {
+	var hostKey types.SiaPublicKey
+	hostKey.LoadString(hostKeyStr)
+	if hostKey.Key == nil {
+		die("Couldn't parse host key")
+	}
+	if err := httpClient.RenterDrainPost(hostKey); err != nil {
+		die("Could not drain host:", err)
+	}
+	if renterDrainAsync {
+		fmt.Println("Started draining the host.")
+		return
+	}
+	for range time.Tick(OutputRefreshRate) {
+		rd, err := httpClient.RenterDrainGet()
+		if err != nil {
+			continue // benign
+		}
+		var drain modules.HostDrainInfo
+		for _, d := range rd.Drains {
+			if d.HostPublicKey.String() == hostKey.String() {
+				drain = d
+			}
+		}
+		fmt.Printf("\rMigrated %v of %v pieces, %v failed", drain.MigratedPieces, drain.Pieces, drain.FailedPieces)
+		if !drain.Finished {
+			continue
+		}
+		fmt.Println()
+		if drain.Error != "" {
+			die("Drain failed:", drain.Error)
+		}
+		if drain.ContractCanceled {
+			fmt.Println("Drained the host and canceled its contract.")
+		}
+		return
+	}
+}
+
+// renterchunkcachecmd is the handler for the command `siac renter
+// chunkcache`. It prints the usage of the chunk cache.
+func renterchunkcachecmd() {
+	rg, err := httpClient.RenterGet()
+	if err != nil {
+		die("Could not get renter info:", err)
+	}
+	cc := rg.ChunkCache
+	if cc.MaxSize == 0 {
+		fmt.Println("The chunk cache is disabled.")
+		return
+	}
+	var hitRate float64
+	if cc.Hits+cc.Misses > 0 {
+		hitRate = 100 * float64(cc.Hits) / float64(cc.Hits+cc.Misses)
+	}
+	fmt.Printf(`Chunk Cache:
+  Chunks:   %v
+  Size:     %v / %v
+  Hits:     %v
+  Misses:   %v
+  Hit Rate: %.2f%%
+`, cc.Chunks, filesizeUnits(cc.Size), filesizeUnits(cc.MaxSize), cc.Hits, cc.Misses, hitRate)
+}
+
+// renterchunkcachesizecmd is the handler for the command `siac renter
+// chunkcache setsize [size]`. It sets the maximum size of the chunk cache.
+func renterchunkcachesizecmd(sizeStr string) {
+	sizeStr, err := parseFilesize(sizeStr)
+	if err != nil {
+		die("Couldn't parse size:", err)
+	}
+	size, err := strconv.ParseUint(sizeStr, 10, 64)
+	if err != nil {
+		die("Couldn't parse size:", err)
+	}
+	if err := httpClient.RenterPostChunkCacheSize(size); err != nil {
+		die("Could not set chunk cache size:", err)
+	}
+	if size == 0 {
+		fmt.Println("Disabled the chunk cache.")
+		return
+	}
+	fmt.Println("Set the chunk cache size to", filesizeUnits(size))
+}
+
+// rentermetadatacmd is the handler for the command `siac renter metadata
+// [path]`. It prints the user metadata of a file.
+func rentermetadatacmd(path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	rf, err := httpClient.RenterFileGet(siaPath)
+	if err != nil {
+		die("Could not get file info:", err)
+	}
+	if len(rf.File.UserMetadata) == 0 {
+		fmt.Println("No user metadata for", siaPath)
+		return
+	}
+	keys := make([]string, 0, len(rf.File.UserMetadata))
+	for key := range rf.File.UserMetadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Key\tValue")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", key, rf.File.UserMetadata[key])
+	}
+	w.Flush()
+}
+
+// rentermetadatasetcmd is the handler for the command `siac renter metadata
+// set [path] [key] [value]`. It sets a user metadata key of a file.
+func rentermetadatasetcmd(path, key, value string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	rf, err := httpClient.RenterFileGet(siaPath)
+	if err != nil {
+		die("Could not get file info:", err)
+	}
+	userMetadata := rf.File.UserMetadata
+	if userMetadata == nil {
+		userMetadata = make(map[string]string)
+	}
+	userMetadata[key] = value
+	if err := httpClient.RenterSetFileUserMetadataPost(siaPath, userMetadata); err != nil {
+		die("Could not set user metadata:", err)
+	}
+	fmt.Printf("Set %v of %v to %v.\n", key, siaPath, value)
+}
+
+// rentermetadataremovecmd is the handler for the command `siac renter
+// metadata remove [path] [key]`. It removes a user metadata key of a file.
+func rentermetadataremovecmd(path, key string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	rf, err := httpClient.RenterFileGet(siaPath)
+	if err != nil {
+		die("Could not get file info:", err)
+	}
+	if _, exists := rf.File.UserMetadata[key]; !exists {
+		die(fmt.Sprintf("%v has no user metadata key %v", siaPath, key))
+	}
+	delete(rf.File.UserMetadata, key)
+	if err := httpClient.RenterSetFileUserMetadataPost(siaPath, rf.File.UserMetadata); err != nil {
+		die("Could not remove user metadata:", err)
+	}
+	fmt.Printf("Removed %v of %v.\n", key, siaPath)
+}
+
+// renterfindcmd is the handler for the command `siac renter find [tag]`. It
+// lists the files with the given tag.
+func renterfindcmd(tag string) {
+	rf, err := httpClient.RenterFindGet(tag, nil)
+	if err != nil {
+		die("Could not find files:", err)
+	}
+	if len(rf.Files) == 0 {
+		fmt.Println("No files with tag", tag)
+		return
+	}
+	sort.Slice(rf.Files, func(i, j int) bool {
+		return rf.Files[i].SiaPath.String() < rf.Files[j].SiaPath.String()
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Path\tSize\tTags")
+	for _, file := range rf.Files {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", file.SiaPath, filesizeUnits(file.Filesize), strings.Join(modules.FileTags(file.UserMetadata), ", "))
+	}
+	w.Flush()
+}
+
+// renterfilechecksumcmd is the handler for the command `siac renter file
+// checksum [path]`. It prints the checksum of a file.
+func renterfilechecksumcmd(path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	rf, err := httpClient.RenterFileGet(siaPath)
+	if err != nil {
+		die("Could not get file info:", err)
+	}
+	if rf.File.Checksum == "" {
+		die("The checksum of", siaPath, "is unknown.")
+	}
+	fmt.Printf("%v  %v\n", rf.File.Checksum, siaPath)
+}
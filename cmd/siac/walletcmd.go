@@ -8,9 +8,11 @@ import (
 	"math"
 	"math/big"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -21,10 +23,56 @@ import (
 	"gitlab.com/NebulousLabs/Sia/encoding"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/modules/wallet"
+	"gitlab.com/NebulousLabs/Sia/node/api"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
 var (
+	walletAccountsCmd = &cobra.Command{
+		Use:   "accounts",
+		Short: "List wallet accounts",
+		Long:  "List the accounts of the wallet with their addresses and confirmed balances.",
+		Run:   wrap(walletaccountscmd),
+	}
+
+	walletAccountsAddressCmd = &cobra.Command{
+		Use:   "address [name]",
+		Short: "Get a new account address",
+		Long:  "Generate a new address from the wallet's primary seed that belongs to the account.",
+		Run:   wrap(walletaccountsaddresscmd),
+	}
+
+	walletAccountsAssignCmd = &cobra.Command{
+		Use:   "assign [name] [addresses]",
+		Short: "Assign addresses to an account",
+		Long: `Assign a comma-separated list of wallet addresses to an account. The addresses
+must be spendable by the wallet, e.g. addresses of the primary seed or of
+imported keys. An address can only belong to one account.`,
+		Run: wrap(walletaccountsassigncmd),
+	}
+
+	walletAccountsAssignRangeCmd = &cobra.Command{
+		Use:   "assignrange [name] [start] [end]",
+		Short: "Assign a range of seed indices to an account",
+		Long: `Assign the addresses of the primary seed indices [start, end) to an account.
+Only addresses that the wallet has already generated can be assigned.`,
+		Run: wrap(walletaccountsassignrangecmd),
+	}
+
+	walletAccountsCreateCmd = &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a new account",
+		Long:  "Create a new, empty account in the wallet.",
+		Run:   wrap(walletaccountscreatecmd),
+	}
+
+	walletAccountsDeleteCmd = &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Delete an account",
+		Long:  "Delete an account. The addresses of the account and their funds remain in the wallet.",
+		Run:   wrap(walletaccountsdeletecmd),
+	}
+
 	walletAddressCmd = &cobra.Command{
 		Use:   "address",
 		Short: "Get a new wallet address",
@@ -54,6 +102,18 @@ be valid. txn may be either JSON, base64, or a file containing either.`,
 		Run: wrap(walletbroadcastcmd),
 	}
 
+	walletExportCmd = &cobra.Command{
+		Use:   "export [file]",
+		Short: "Export the transaction history",
+		Long: `Export the confirmed transactions of the wallet as a CSV or JSON ledger. Every
+entry has its confirmation time, incoming, outgoing and net value, the fees paid
+by the wallet and a category (contractformation, contractpayout, hostpayout,
+minerpayout, siafundclaim or transfer). The ledger can be restricted to a range
+of heights or dates and to a set of addresses or an account. A summary of the
+totals per category is printed after the export.`,
+		Run: wrap(walletexportcmd),
+	}
+
 	walletChangepasswordCmd = &cobra.Command{
 		Use:   "change-password",
 		Short: "Change the wallet password",
@@ -153,7 +213,8 @@ By default the wallet encryption / unlock password is the same as the generated
 'amount' can be specified in units, e.g. 1.23KS. Run 'wallet --help' for a list of units.
 If no unit is supplied, hastings will be assumed.
 
-A dynamic transaction fee is applied depending on the size of the transaction and how busy the network is.`,
+A dynamic transaction fee is applied depending on the size of the transaction and how busy the network is.
+If an account is supplied, the transaction is only funded by the account and the change is returned to it.`,
 		Run: wrap(walletsendsiacoinscmd),
 	}
 
@@ -245,6 +306,84 @@ func walletaddresscmd() {
 	fmt.Printf("Created new address: %s\n", addr.Address)
 }
 
+// walletaccountscmd lists the accounts of the wallet.
+func walletaccountscmd() {
+	wag, err := httpClient.WalletAccountsGet()
+	if err != nil {
+		die("Could not get accounts:", err)
+	}
+	if len(wag.Accounts) == 0 {
+		fmt.Println("No accounts.")
+		return
+	}
+	for _, acc := range wag.Accounts {
+		fmt.Printf(`%v:
+  Addresses:           %v
+  Confirmed Balance:   %v
+  Siafunds:            %v SF
+  Siafund Claims:      %v H
+`, acc.Name, len(acc.Addresses), currencyUnits(acc.ConfirmedSiacoinBalance),
+			acc.ConfirmedSiafundBalance, acc.ConfirmedSiafundClaimBalance)
+	}
+}
+
+// walletaccountsaddresscmd generates a new address for an account.
+func walletaccountsaddresscmd(name string) {
+	addr, err := httpClient.WalletAccountAddressGet(name)
+	if err != nil {
+		die("Could not generate new address:", err)
+	}
+	fmt.Printf("Created new address for account %v: %s\n", name, addr.Address)
+}
+
+// walletaccountsassigncmd assigns a set of addresses to an account.
+func walletaccountsassigncmd(name, addrs string) {
+	var uhs []types.UnlockHash
+	for _, addr := range strings.Split(addrs, ",") {
+		var uh types.UnlockHash
+		if err := uh.LoadString(strings.TrimSpace(addr)); err != nil {
+			die("Could not parse address:", err)
+		}
+		uhs = append(uhs, uh)
+	}
+	if err := httpClient.WalletAccountAssignPost(name, uhs); err != nil {
+		die("Could not assign addresses:", err)
+	}
+	fmt.Printf("Assigned %v addresses to account %v\n", len(uhs), name)
+}
+
+// walletaccountsassignrangecmd assigns a range of seed indices to an account.
+func walletaccountsassignrangecmd(name, start, end string) {
+	s, err := strconv.ParseUint(start, 10, 64)
+	if err != nil {
+		die("Could not parse start:", err)
+	}
+	e, err := strconv.ParseUint(end, 10, 64)
+	if err != nil {
+		die("Could not parse end:", err)
+	}
+	if err := httpClient.WalletAccountAssignRangePost(name, s, e); err != nil {
+		die("Could not assign seed range:", err)
+	}
+	fmt.Printf("Assigned seed indices [%v, %v) to account %v\n", s, e, name)
+}
+
+// walletaccountscreatecmd creates a new account.
+func walletaccountscreatecmd(name string) {
+	if err := httpClient.WalletAccountCreatePost(name); err != nil {
+		die("Could not create account:", err)
+	}
+	fmt.Println("Created account", name)
+}
+
+// walletaccountsdeletecmd deletes an account.
+func walletaccountsdeletecmd(name string) {
+	if err := httpClient.WalletAccountDeletePost(name); err != nil {
+		die("Could not delete account:", err)
+	}
+	fmt.Println("Deleted account", name)
+}
+
 // walletaddressescmd fetches the list of addresses that the wallet knows.
 func walletaddressescmd() {
 	addrs, err := httpClient.WalletAddressesGet()
@@ -413,7 +552,11 @@ func walletsendsiacoinscmd(amount, dest string) {
 	if _, err := fmt.Sscan(dest, &hash); err != nil {
 		die("Failed to parse destination address", err)
 	}
-	_, err = httpClient.WalletSiacoinsPost(value, hash)
+	if walletAccount != "" {
+		_, err = httpClient.WalletSiacoinsAccountPost(walletAccount, value, hash)
+	} else {
+		_, err = httpClient.WalletSiacoinsPost(value, hash)
+	}
 	if err != nil {
 		die("Could not send siacoins:", err)
 	}
@@ -495,6 +638,99 @@ func walletbroadcastcmd(txnStr string) {
 	fmt.Println("Transaction has been broadcast successfully")
 }
 
+// walletexportcmd exports the transaction history of the wallet to a file.
+func walletexportcmd(path string) {
+	params := modules.WalletLedgerParams{
+		StartHeight: types.BlockHeight(walletExportStartHeight),
+		EndHeight:   types.BlockHeight(walletExportEndHeight),
+		Account:     walletAccount,
+	}
+	if walletExportStartDate != "" {
+		t, err := time.Parse("2006-01-02", walletExportStartDate)
+		if err != nil {
+			die("Could not parse start date:", err)
+		}
+		params.StartTime = types.Timestamp(t.Unix())
+	}
+	if walletExportEndDate != "" {
+		t, err := time.Parse("2006-01-02", walletExportEndDate)
+		if err != nil {
+			die("Could not parse end date:", err)
+		}
+		// Include the whole last day.
+		params.EndTime = types.Timestamp(t.AddDate(0, 0, 1).Unix() - 1)
+	}
+	if walletExportAddresses != "" {
+		for _, addr := range strings.Split(walletExportAddresses, ",") {
+			var uh types.UnlockHash
+			if err := uh.LoadString(strings.TrimSpace(addr)); err != nil {
+				die("Could not parse address:", err)
+			}
+			params.Addresses = append(params.Addresses, uh)
+		}
+	}
+	if walletExportFormat != "csv" && walletExportFormat != "json" {
+		die("Format must be 'csv' or 'json'")
+	}
+
+	wteg, err := httpClient.WalletTransactionsExportGet(params)
+	if err != nil {
+		die("Could not export transaction history:", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		die("Could not create file:", err)
+	}
+	if walletExportFormat == "csv" {
+		err = api.WriteLedgerCSV(f, wteg.Entries)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(wteg.Entries)
+	}
+	if err != nil {
+		f.Close()
+		die("Could not write ledger:", err)
+	}
+	if err := f.Close(); err != nil {
+		die("Could not write ledger:", err)
+	}
+	fmt.Printf("Exported %v transactions to %v\n", len(wteg.Entries), path)
+
+	// Print the totals per category.
+	type total struct {
+		count              int
+		incoming, outgoing types.Currency
+		fees               types.Currency
+	}
+	totals := make(map[string]*total)
+	var categories []string
+	for _, e := range wteg.Entries {
+		t, exists := totals[e.Category]
+		if !exists {
+			t = new(total)
+			totals[e.Category] = t
+			categories = append(categories, e.Category)
+		}
+		t.count++
+		t.incoming = t.incoming.Add(e.IncomingValue)
+		t.outgoing = t.outgoing.Add(e.OutgoingValue)
+		t.fees = t.fees.Add(e.Fees)
+	}
+	if len(categories) == 0 {
+		return
+	}
+	sort.Strings(categories)
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Category\tTransactions\tIncoming\tOutgoing\tFees")
+	for _, c := range categories {
+		t := totals[c]
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", c, t.count, currencyUnits(t.incoming), currencyUnits(t.outgoing), currencyUnits(t.fees))
+	}
+	w.Flush()
+}
+
 // walletsweepcmd sweeps coins and funds from a seed.
 func walletsweepcmd() {
 	seed, err := passwordPrompt("Seed: ")
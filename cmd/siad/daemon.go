@@ -34,6 +34,30 @@ func passwordPrompt(prompt string) (string, error) {
 // verifyAPISecurity checks that the security values are consistent with a
 // sane, secure system.
 func verifyAPISecurity(config Config) error {
+	// An empty address disables the TCP listener, in which case the API is
+	// only reachable through the unix socket.
+	if config.Siad.APIaddr == "" {
+		if config.Siad.APIUnixSocket == "" {
+			return errors.New("an --api-unix-socket is required when --api-addr is empty")
+		}
+		return nil
+	}
+
+	// Make sure that TLS is configured completely.
+	tlsEnabled := config.Siad.APITLSCert != "" || config.Siad.APITLSKey != ""
+	if tlsEnabled && (config.Siad.APITLSCert == "" || config.Siad.APITLSKey == "") {
+		return errors.New("--api-tls-cert and --api-tls-key must be used together")
+	}
+	if !tlsEnabled && config.Siad.APITLSClientCA != "" {
+		return errors.New("--api-tls-client-ca requires --api-tls-cert and --api-tls-key")
+	}
+
+	// A non-loopback address is allowed without the --disable-api-security
+	// flag if the API is served over TLS and requires authentication.
+	if tlsEnabled && (config.Siad.AuthenticateAPI || config.Siad.APITLSClientCA != "") {
+		return nil
+	}
+
 	// Make sure that only the loopback address is allowed unless the
 	// --disable-api-security flag has been used.
 	if !config.Siad.AllowAPIBind {
@@ -65,12 +89,33 @@ func processNetAddr(addr string) string {
 	return addr
 }
 
+// processSocketMode checks that the file permissions of the unix socket are a
+// valid octal mode.
+func processSocketMode(mode string) (string, error) {
+	if _, err := strconv.ParseUint(mode, 8, 32); err != nil {
+		return "", errors.New("Unable to parse --api-unix-socket-mode flag, must be an octal file mode like 0600")
+	}
+	return mode, nil
+}
+
+// listenerParams creates the parameters of the API listeners from the config.
+func listenerParams(config Config) server.ListenerParams {
+	mode, _ := strconv.ParseUint(config.Siad.APIUnixSocketMode, 8, 32)
+	return server.ListenerParams{
+		TLSCertFile:     config.Siad.APITLSCert,
+		TLSKeyFile:      config.Siad.APITLSKey,
+		TLSClientCAFile: config.Siad.APITLSClientCA,
+		UnixSocket:      config.Siad.APIUnixSocket,
+		UnixSocketMode:  os.FileMode(mode),
+	}
+}
+
 // processModules makes the modules string lowercase to make checking if a
 // module in the string easier, and returns an error if the string contains an
 // invalid module character.
 func processModules(modules string) (string, error) {
 	modules = strings.ToLower(modules)
-	validModules := "cghmrtwe"
+	validModules := "cghmrtwel"
 	invalidModules := modules
 	for _, m := range validModules {
 		invalidModules = strings.Replace(invalidModules, string(m), "", 1)
@@ -106,7 +151,11 @@ func processConfig(config Config) (Config, error) {
 	config.Siad.Modules, err1 = processModules(config.Siad.Modules)
 	config.Siad.Profile, err2 = processProfileFlags(config.Siad.Profile)
 	err3 := verifyAPISecurity(config)
-	err := build.JoinErrors([]error{err1, err2, err3}, ", and ")
+	var err4 error
+	if config.Siad.APIUnixSocket != "" {
+		config.Siad.APIUnixSocketMode, err4 = processSocketMode(config.Siad.APIUnixSocketMode)
+	}
+	err := build.JoinErrors([]error{err1, err2, err3, err4}, ", and ")
 	if err != nil {
 		return Config{}, err
 	}
@@ -249,7 +298,7 @@ func startDaemon(config Config) (err error) {
 	nodeParams := parseModules(config)
 
 	// Start and run the server.
-	srv, err := server.New(config.Siad.APIaddr, config.Siad.RequiredUserAgent, config.APIPassword, nodeParams)
+	srv, err := server.NewWithListeners(config.Siad.APIaddr, config.Siad.RequiredUserAgent, config.APIPassword, listenerParams(config), nodeParams)
 	if err != nil {
 		return err
 	}
@@ -42,6 +42,7 @@ func TestUnitProcessModules(t *testing.T) {
 		{"t", "t"},
 		{"w", "w"},
 		{"e", "e"},
+		{"l", "l"},
 		{"C", "c"},
 		{"G", "g"},
 		{"H", "h"},
@@ -50,6 +51,7 @@ func TestUnitProcessModules(t *testing.T) {
 		{"T", "t"},
 		{"W", "w"},
 		{"E", "e"},
+		{"L", "l"},
 	}
 	for _, testVal := range testVals {
 		out, err := processModules(testVal.in)
@@ -239,4 +241,53 @@ func TestVerifyAPISecurity(t *testing.T) {
 	if err != nil {
 		t.Error("public + securityOff with authentication was rejected:", err)
 	}
+	// Check that a public hostname is accepted without disabling security if
+	// the API is served over TLS and requires authentication.
+	var tlsPublicAuthenticated Config
+	tlsPublicAuthenticated.Siad.APIaddr = "sia.tech:9980"
+	tlsPublicAuthenticated.Siad.APITLSCert = "api.crt"
+	tlsPublicAuthenticated.Siad.APITLSKey = "api.key"
+	tlsPublicAuthenticated.Siad.AuthenticateAPI = true
+	err = verifyAPISecurity(tlsPublicAuthenticated)
+	if err != nil {
+		t.Error("public + TLS with authentication was rejected:", err)
+	}
+
+	// Check that a public hostname is rejected over TLS without
+	// authentication.
+	tlsPublic := tlsPublicAuthenticated
+	tlsPublic.Siad.AuthenticateAPI = false
+	err = verifyAPISecurity(tlsPublic)
+	if err == nil {
+		t.Error("public + TLS was accepted without authentication")
+	}
+
+	// Check that client certificates count as authentication.
+	tlsPublicClientCA := tlsPublic
+	tlsPublicClientCA.Siad.APITLSClientCA = "ca.crt"
+	err = verifyAPISecurity(tlsPublicClientCA)
+	if err != nil {
+		t.Error("public + TLS with client certificates was rejected:", err)
+	}
+
+	// Check that an incomplete TLS configuration is rejected.
+	var tlsNoKey Config
+	tlsNoKey.Siad.APIaddr = "127.0.0.1:9980"
+	tlsNoKey.Siad.APITLSCert = "api.crt"
+	err = verifyAPISecurity(tlsNoKey)
+	if err == nil {
+		t.Error("TLS certificate without key was accepted")
+	}
+
+	// Check that an empty address requires a unix socket.
+	var socketOnly Config
+	err = verifyAPISecurity(socketOnly)
+	if err == nil {
+		t.Error("empty address without unix socket was accepted")
+	}
+	socketOnly.Siad.APIUnixSocket = "siad.sock"
+	err = verifyAPISecurity(socketOnly)
+	if err != nil {
+		t.Error("empty address with unix socket was rejected:", err)
+	}
 }
@@ -37,6 +37,12 @@ type Config struct {
 		HostAddr     string
 		AllowAPIBind bool
 
+		APITLSCert        string
+		APITLSKey         string
+		APITLSClientCA    string
+		APIUnixSocket     string
+		APIUnixSocketMode string
+
 		Modules           string
 		NoBootstrap       bool
 		RequiredUserAgent string
@@ -98,6 +104,15 @@ Consensus Set (c):
 	The consensus set requires the gateway.
 	Example:
 		siad -M gc
+Light Consensus Set (l):
+	The light consensus set is used instead of the consensus set by nodes
+	that only need a wallet. It only downloads the headers of the blockchain
+	and the transactions relevant to the wallet, which are proven to be part
+	of the blockchain by full nodes, and uses much less disk space.
+	The light consensus set requires the gateway, and can not be used with
+	the renter, host, miner or explorer.
+	Example:
+		siad -M gltw
 Transaction Pool (t):
 	The transaction pool manages unconfirmed transactions.
 	The transaction pool requires the consensus set.
@@ -174,6 +189,11 @@ func main() {
 	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPI, "authenticate-api", "", true, "enable API password protection")
 	root.Flags().BoolVarP(&globalConfig.Siad.TempPassword, "temp-password", "", false, "enter a temporary API password during startup")
 	root.Flags().BoolVarP(&globalConfig.Siad.AllowAPIBind, "disable-api-security", "", false, "allow siad to listen on a non-localhost address (DANGEROUS)")
+	root.Flags().StringVarP(&globalConfig.Siad.APITLSCert, "api-tls-cert", "", "", "PEM certificate that enables TLS for the API")
+	root.Flags().StringVarP(&globalConfig.Siad.APITLSKey, "api-tls-key", "", "", "PEM key of the API's TLS certificate")
+	root.Flags().StringVarP(&globalConfig.Siad.APITLSClientCA, "api-tls-client-ca", "", "", "PEM certificate authority that API clients must present a certificate of")
+	root.Flags().StringVarP(&globalConfig.Siad.APIUnixSocket, "api-unix-socket", "", "", "path of a unix socket the API listens on in addition to --api-addr")
+	root.Flags().StringVarP(&globalConfig.Siad.APIUnixSocketMode, "api-unix-socket-mode", "", "0600", "file permissions of the API's unix socket")
 
 	// Parse cmdline flags, overwriting both the default values and the config
 	// file values.
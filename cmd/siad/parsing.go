@@ -17,6 +17,10 @@ func parseModules(config Config) node.NodeParams {
 	if strings.Contains(config.Siad.Modules, "c") {
 		params.CreateConsensusSet = true
 	}
+	if strings.Contains(config.Siad.Modules, "l") {
+		params.CreateConsensusSet = true
+		params.LightConsensus = true
+	}
 	if strings.Contains(config.Siad.Modules, "e") {
 		params.CreateExplorer = true
 	}
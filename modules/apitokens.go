@@ -0,0 +1,190 @@
+package modules
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// The scopes of API tokens. Every privileged API route requires one of these
+// scopes.
+const (
+	// APIScopeDaemonAdmin grants access to stopping the daemon and managing
+	// API tokens.
+	APIScopeDaemonAdmin = "daemon:admin"
+
+	// APIScopeGatewayAdmin grants access to connecting and disconnecting
+	// peers.
+	APIScopeGatewayAdmin = "gateway:admin"
+
+	// APIScopeHostAdmin grants access to changing the host settings and its
+	// storage.
+	APIScopeHostAdmin = "host:admin"
+
+	// APIScopeMinerAdmin grants access to the miner.
+	APIScopeMinerAdmin = "miner:admin"
+
+	// APIScopeRenterRead grants access to the privileged renter routes that
+	// don't change the renter.
+	APIScopeRenterRead = "renter:read"
+
+	// APIScopeRenterWrite grants access to uploading, downloading and
+	// changing files and to changing the renter settings.
+	APIScopeRenterWrite = "renter:write"
+
+	// APIScopeWalletRead grants access to the privileged wallet routes that
+	// don't spend funds, e.g. generating addresses.
+	APIScopeWalletRead = "wallet:read"
+
+	// APIScopeWalletSpend grants access to spending and signing with the
+	// wallet.
+	APIScopeWalletSpend = "wallet:spend"
+
+	// APIScopeWalletAdmin grants access to the seeds and the encryption of
+	// the wallet.
+	APIScopeWalletAdmin = "wallet:admin"
+)
+
+var (
+	// APIScopes are all valid scopes of API tokens.
+	APIScopes = []string{
+		APIScopeDaemonAdmin,
+		APIScopeGatewayAdmin,
+		APIScopeHostAdmin,
+		APIScopeMinerAdmin,
+		APIScopeRenterRead,
+		APIScopeRenterWrite,
+		APIScopeWalletRead,
+		APIScopeWalletSpend,
+		APIScopeWalletAdmin,
+	}
+
+	// ErrUnknownAPIScope is returned when creating an API token with a scope
+	// that doesn't exist.
+	ErrUnknownAPIScope = errors.New("unknown API token scope")
+
+	// errNoAPIScopes is returned when creating an API token without scopes.
+	errNoAPIScopes = errors.New("API token needs at least one scope")
+
+	// errUnknownAPIToken is returned when revoking an API token that doesn't
+	// exist.
+	errUnknownAPIToken = errors.New("API token does not exist")
+)
+
+// An APIToken grants access to the API routes that require one of its scopes.
+// Only the hash of the secret of a token is stored, the secret itself is only
+// returned when the token is created.
+type APIToken struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Scopes  []string        `json:"scopes"`
+	Expires types.Timestamp `json:"expires"`
+	Hash    crypto.Hash     `json:"hash"`
+}
+
+// Expired returns true if the token has an expiry that has passed.
+func (t APIToken) Expired() bool {
+	return t.Expires != 0 && types.CurrentTimestamp() > t.Expires
+}
+
+// HasScope returns true if the token has the provided scope.
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validAPIScope returns true if the scope is one of the APIScopes.
+func validAPIScope(scope string) bool {
+	for _, s := range APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAPIToken creates a new API token with the provided scopes and persists
+// it. An expiry of zero means that the token never expires. The secret of the
+// token is returned, it can't be retrieved later.
+func (cfg *SiadConfig) AddAPIToken(name string, scopes []string, expires types.Timestamp) (APIToken, string, error) {
+	if len(scopes) == 0 {
+		return APIToken{}, "", errNoAPIScopes
+	}
+	for _, scope := range scopes {
+		if !validAPIScope(scope) {
+			return APIToken{}, "", ErrUnknownAPIScope
+		}
+	}
+	secret := hex.EncodeToString(fastrand.Bytes(32))
+	hash := crypto.HashBytes([]byte(secret))
+	token := APIToken{
+		ID:      hex.EncodeToString(hash[:8]),
+		Name:    name,
+		Scopes:  scopes,
+		Expires: expires,
+		Hash:    hash,
+	}
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.APITokens = append(cfg.APITokens, token)
+	if err := cfg.save(); err != nil {
+		cfg.APITokens = cfg.APITokens[:len(cfg.APITokens)-1]
+		return APIToken{}, "", err
+	}
+	return token, secret, nil
+}
+
+// RevokeAPIToken removes the API token with the provided id.
+func (cfg *SiadConfig) RevokeAPIToken(id string) error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	for i, token := range cfg.APITokens {
+		if token.ID != id {
+			continue
+		}
+		tokens := append([]APIToken{}, cfg.APITokens[:i]...)
+		cfg.APITokens = append(tokens, cfg.APITokens[i+1:]...)
+		return cfg.save()
+	}
+	return errUnknownAPIToken
+}
+
+// APITokensList returns all API tokens, including the expired ones.
+func (cfg *SiadConfig) APITokensList() []APIToken {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return append([]APIToken{}, cfg.APITokens...)
+}
+
+// AuthenticateAPIToken returns the API token with the provided secret. The
+// bool is false if no such token exists or if it has expired.
+func (cfg *SiadConfig) AuthenticateAPIToken(secret string) (APIToken, bool) {
+	hash := crypto.HashBytes([]byte(secret))
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	for _, token := range cfg.APITokens {
+		if token.Hash == hash {
+			return token, !token.Expired()
+		}
+	}
+	return APIToken{}, false
+}
+
+// APITokenExpiry converts a duration into the expiry of an API token that is
+// created now. A zero duration means that the token never expires.
+func APITokenExpiry(d time.Duration) types.Timestamp {
+	if d == 0 {
+		return 0
+	}
+	return types.Timestamp(time.Now().Add(d).Unix())
+}
@@ -0,0 +1,85 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// TestAPITokens probes the creation, authentication and revocation of API
+// tokens and checks that they are persisted.
+func TestAPITokens(t *testing.T) {
+	dir := build.TempDir("modules", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "siad.config")
+	cfg, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens need valid scopes.
+	if _, _, err := cfg.AddAPIToken("none", nil, 0); err != errNoAPIScopes {
+		t.Fatalf("expected %v, got %v", errNoAPIScopes, err)
+	}
+	if _, _, err := cfg.AddAPIToken("bad", []string{"wallet:steal"}, 0); err != ErrUnknownAPIScope {
+		t.Fatalf("expected %v, got %v", ErrUnknownAPIScope, err)
+	}
+
+	// Create a token and authenticate with its secret.
+	token, secret, err := cfg.AddAPIToken("dashboard", []string{APIScopeWalletRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authToken, ok := cfg.AuthenticateAPIToken(secret)
+	if !ok || authToken.ID != token.ID {
+		t.Fatal("token could not be authenticated")
+	}
+	if !authToken.HasScope(APIScopeWalletRead) || authToken.HasScope(APIScopeWalletSpend) {
+		t.Fatal("token has the wrong scopes")
+	}
+	if _, ok := cfg.AuthenticateAPIToken("wrong secret"); ok {
+		t.Fatal("wrong secret was authenticated")
+	}
+
+	// Expired tokens are rejected.
+	_, expiredSecret, err := cfg.AddAPIToken("expired", []string{APIScopeRenterRead}, types.CurrentTimestamp()-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.AuthenticateAPIToken(expiredSecret); ok {
+		t.Fatal("expired token was authenticated")
+	}
+	if APITokenExpiry(0) != 0 || APITokenExpiry(time.Hour) <= types.CurrentTimestamp() {
+		t.Fatal("wrong token expiry")
+	}
+
+	// The tokens are persisted, the secret is not.
+	cfg2, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := cfg2.APITokensList()
+	if len(tokens) != 2 || tokens[0].ID != token.ID {
+		t.Fatal("tokens were not persisted", tokens)
+	}
+	if _, ok := cfg2.AuthenticateAPIToken(secret); !ok {
+		t.Fatal("persisted token could not be authenticated")
+	}
+
+	// Revoked tokens are rejected.
+	if err := cfg2.RevokeAPIToken(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg2.RevokeAPIToken(token.ID); err != errUnknownAPIToken {
+		t.Fatalf("expected %v, got %v", errUnknownAPIToken, err)
+	}
+	if _, ok := cfg2.AuthenticateAPIToken(secret); ok {
+		t.Fatal("revoked token was authenticated")
+	}
+}
@@ -12,6 +12,10 @@ const (
 	// persistence files.
 	ConsensusDir = "consensus"
 
+	// LightConsensusDir is the name of the directory used for the persistence
+	// files of a light consensus set.
+	LightConsensusDir = "lightconsensus"
+
 	// DiffApply indicates that a diff is being applied to the consensus set.
 	DiffApply DiffDirection = true
 
@@ -70,6 +74,20 @@ type (
 		ProcessConsensusChange(ConsensusChange)
 	}
 
+	// A LightConsensusSetSubscriber is a ConsensusSetSubscriber that is only
+	// interested in the parts of the blockchain that are relevant to a set of
+	// addresses. A light consensus set only downloads the miner payouts and
+	// the transactions of a block that are relevant to the addresses of its
+	// subscribers.
+	LightConsensusSetSubscriber interface {
+		ConsensusSetSubscriber
+
+		// RelevantAddresses returns the addresses whose outputs and
+		// transactions should be included in the consensus changes sent to
+		// the subscriber.
+		RelevantAddresses() []types.UnlockHash
+	}
+
 	// A ConsensusChange enumerates a set of changes that occurred to the consensus set.
 	ConsensusChange struct {
 		// ID is a unique id for the consensus change derived from the reverted
@@ -88,6 +106,15 @@ type (
 		// applied.
 		AppliedBlocks []types.Block
 
+		// RevertedBlockIDs and AppliedBlockIDs are the IDs of the blocks in
+		// RevertedBlocks and AppliedBlocks. The blocks sent by a light
+		// consensus set only contain the miner payouts and the transactions
+		// that are relevant to its subscribers, so their IDs can not be
+		// computed from the blocks themselves. Use RevertedBlockID and
+		// AppliedBlockID to access them.
+		RevertedBlockIDs []types.BlockID
+		AppliedBlockIDs  []types.BlockID
+
 		// SiacoinOutputDiffs contains the set of siacoin diffs that were applied
 		// to the consensus set in the recent change. The direction for the set of
 		// diffs is 'DiffApply'.
@@ -251,14 +278,43 @@ type (
 	}
 )
 
+// RevertedBlockID returns the ID of the i'th reverted block of the consensus
+// change.
+func (cc ConsensusChange) RevertedBlockID(i int) types.BlockID {
+	if i < len(cc.RevertedBlockIDs) {
+		return cc.RevertedBlockIDs[i]
+	}
+	return cc.RevertedBlocks[i].ID()
+}
+
+// AppliedBlockID returns the ID of the i'th applied block of the consensus
+// change.
+func (cc ConsensusChange) AppliedBlockID(i int) types.BlockID {
+	if i < len(cc.AppliedBlockIDs) {
+		return cc.AppliedBlockIDs[i]
+	}
+	return cc.AppliedBlocks[i].ID()
+}
+
 // Append takes to ConsensusChange objects and adds all of their diffs together.
 //
 // NOTE: It is possible for diffs to overlap or be inconsistent. This function
 // should only be used with consecutive or disjoint consensus change objects.
 func (cc ConsensusChange) Append(cc2 ConsensusChange) ConsensusChange {
+	var revertedIDs, appliedIDs []types.BlockID
+	for _, c := range []ConsensusChange{cc, cc2} {
+		for i := range c.RevertedBlocks {
+			revertedIDs = append(revertedIDs, c.RevertedBlockID(i))
+		}
+		for i := range c.AppliedBlocks {
+			appliedIDs = append(appliedIDs, c.AppliedBlockID(i))
+		}
+	}
 	return ConsensusChange{
 		RevertedBlocks:            append(cc.RevertedBlocks, cc2.RevertedBlocks...),
 		AppliedBlocks:             append(cc.AppliedBlocks, cc2.AppliedBlocks...),
+		RevertedBlockIDs:          revertedIDs,
+		AppliedBlockIDs:           appliedIDs,
 		SiacoinOutputDiffs:        append(cc.SiacoinOutputDiffs, cc2.SiacoinOutputDiffs...),
 		FileContractDiffs:         append(cc.FileContractDiffs, cc2.FileContractDiffs...),
 		SiafundOutputDiffs:        append(cc.SiafundOutputDiffs, cc2.SiafundOutputDiffs...),
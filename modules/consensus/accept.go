@@ -59,6 +59,12 @@ func (cs *ConsensusSet) validateHeaderAndBlock(tx dbTx, b types.Block, id types.
 	if err != nil {
 		return nil, err
 	}
+	// Check that the block does not conflict with a checkpoint.
+	err = cs.validateCheckpoints(tx, id, parent.Height+1)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check that the timestamp is not too far in the past to be acceptable.
 	minTimestamp := cs.blockRuleHelper.minimumValidChildTimestamp(blockMap, parent)
 
@@ -108,6 +114,12 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 		return err
 	}
 
+	// Check that the header does not conflict with a checkpoint.
+	err = cs.validateCheckpoints(tx, id, parent.Height+1)
+	if err != nil {
+		return err
+	}
+
 	// Check that the nonce is a legal nonce.
 	if parent.Height+1 >= types.ASICHardforkHeight && binary.LittleEndian.Uint64(h.Nonce[:])%types.ASICHardforkFactor != 0 {
 		return errors.New("block does not meet nonce requirements")
@@ -322,6 +334,9 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 // without error, it will be relayed to all connected peers. This function
 // should only be called for new blocks.
 func (cs *ConsensusSet) AcceptBlock(b types.Block) error {
+	if cs.light {
+		return errLightConsensusSet
+	}
 	err := cs.tg.Add()
 	if err != nil {
 		return err
@@ -0,0 +1,92 @@
+package consensus
+
+import (
+	"errors"
+
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errCheckpointMismatch   = errors.New("block conflicts with a hardcoded checkpoint")
+	errForkBeforeCheckpoint = errors.New("block forks the blockchain before a hardcoded checkpoint")
+)
+
+// onCheckpointPath returns true if the block with the given id and height is
+// a checkpoint or an ancestor of a checkpoint. The IDs of blocks commit to
+// their parents, so such blocks are known to be part of the canonical chain
+// and their signatures are not verified.
+func (cs *ConsensusSet) onCheckpointPath(id types.BlockID, height types.BlockHeight) bool {
+	if height > cs.lastCheckpoint {
+		return false
+	}
+	if checkpointID, exists := cs.checkpoints[height]; exists && checkpointID == id {
+		return true
+	}
+	_, exists := cs.checkpointPath[id]
+	return exists
+}
+
+// addCheckpointPath adds the headers of a header chain that lead up to a
+// checkpoint to the checkpoint path. tipHeight is the height of the last
+// header of the chain. The IDs of the added blocks are returned.
+func (cs *ConsensusSet) addCheckpointPath(headers []types.BlockHeader, tipHeight types.BlockHeight) []types.BlockID {
+	var added []types.BlockID
+	onPath := false
+	for i := len(headers) - 1; i >= 0; i-- {
+		height := tipHeight - types.BlockHeight(len(headers)-1-i)
+		id := headers[i].ID()
+		if checkpointID, exists := cs.checkpoints[height]; exists && checkpointID == id {
+			onPath = true
+		}
+		if onPath {
+			cs.checkpointPath[id] = struct{}{}
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+// removeCheckpointPath removes the blocks with the given IDs from the
+// checkpoint path.
+func (cs *ConsensusSet) removeCheckpointPath(ids []types.BlockID) {
+	for _, id := range ids {
+		delete(cs.checkpointPath, id)
+	}
+}
+
+// validateCheckpoints checks that a block with the given id and height does
+// not conflict with the checkpoints of the consensus set. A block conflicts
+// with a checkpoint if it is at the height of a checkpoint but has a
+// different id, or if it forks the blockchain below a checkpoint that the
+// current path has already passed.
+func (cs *ConsensusSet) validateCheckpoints(tx dbTx, id types.BlockID, height types.BlockHeight) error {
+	checkpointID, exists := cs.checkpoints[height]
+	if exists && checkpointID != id {
+		return errCheckpointMismatch
+	}
+	if exists || height > cs.lastCheckpoint {
+		return nil
+	}
+
+	// The block is below the last checkpoint. Reject it if the current path
+	// has already passed a checkpoint at or above the block's height, as the
+	// block would fork the blockchain before that checkpoint. Known blocks
+	// are rejected earlier in validation, so the block cannot be part of the
+	// current path.
+	heightBucket := tx.Bucket(BlockHeight)
+	if heightBucket == nil {
+		return errNilBucket
+	}
+	var currentHeight types.BlockHeight
+	err := encoding.Unmarshal(heightBucket.Get(BlockHeight), &currentHeight)
+	if err != nil {
+		return err
+	}
+	for checkpointHeight := range cs.checkpoints {
+		if checkpointHeight >= height && checkpointHeight <= currentHeight {
+			return errForkBeforeCheckpoint
+		}
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package consensus
+
+import (
+	"testing"
+
+	bolt "github.com/coreos/bbolt"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// setCheckpoints replaces the checkpoints of the consensus set.
+func (cs *ConsensusSet) setCheckpoints(checkpoints map[types.BlockHeight]types.BlockID) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.checkpoints = checkpoints
+	cs.lastCheckpoint = 0
+	for height := range checkpoints {
+		if height > cs.lastCheckpoint {
+			cs.lastCheckpoint = height
+		}
+	}
+}
+
+// currentPathBlocks returns the blocks of the current path of the consensus
+// set, excluding the genesis block.
+func (cs *ConsensusSet) currentPathBlocks() ([]types.Block, error) {
+	var blocks []types.Block
+	for i := types.BlockHeight(1); i <= cs.dbBlockHeight(); i++ {
+		id, err := cs.dbGetPath(i)
+		if err != nil {
+			return nil, err
+		}
+		pb, err := cs.dbGetBlockMap(id)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, pb.Block)
+	}
+	return blocks, nil
+}
+
+// TestCheckpointsDefault checks that the genesis block is always a
+// checkpoint.
+func TestCheckpointsDefault(t *testing.T) {
+	id, exists := types.Checkpoints[0]
+	if !exists || id != types.GenesisID {
+		t.Fatal("genesis block is not a checkpoint")
+	}
+	for height := range types.Checkpoints {
+		if height > types.LastCheckpointHeight() {
+			t.Fatal("LastCheckpointHeight is not the highest checkpoint")
+		}
+	}
+}
+
+// TestCheckpointRejectsConflictingFork feeds a syncing consensus set a
+// heavier fork that conflicts with a checkpoint and checks that the fork is
+// rejected while the checkpointed chain is accepted.
+func TestCheckpointRejectsConflictingFork(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cstMain, err := blankConsensusSetTester(t.Name()+"-main", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstMain.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name()+"-alt", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+	cstSync, err := blankConsensusSetTester(t.Name()+"-sync", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstSync.Close()
+
+	// Mine a short canonical chain and a longer, conflicting fork.
+	for i := 0; i < 4; i++ {
+		if _, err := cstMain.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := cstAlt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mainBlocks, err := cstMain.cs.currentPathBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	altBlocks, err := cstAlt.cs.currentPathBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Checkpoint the third block of the canonical chain.
+	checkpointHeight := types.BlockHeight(3)
+	cstSync.cs.setCheckpoints(map[types.BlockHeight]types.BlockID{
+		0:                types.GenesisID,
+		checkpointHeight: mainBlocks[checkpointHeight-1].ID(),
+	})
+
+	// The fork is rejected once it reaches the checkpoint.
+	_, err = cstSync.cs.managedAcceptBlocks(altBlocks)
+	if err != errCheckpointMismatch {
+		t.Fatalf("expected %v, got %v", errCheckpointMismatch, err)
+	}
+	if cstSync.cs.Height() >= checkpointHeight {
+		t.Fatal("consensus set extended the conflicting fork past the checkpoint")
+	}
+
+	// The canonical chain is accepted despite being lighter than the fork.
+	_, err = cstSync.cs.managedAcceptBlocks(mainBlocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cstSync.cs.CurrentBlock().ID() != mainBlocks[len(mainBlocks)-1].ID() {
+		t.Fatal("consensus set did not switch to the checkpointed chain")
+	}
+
+	// Headers of the fork are rejected as well now that the consensus set has
+	// passed the checkpoint.
+	err = cstSync.cs.managedValidateHeader(altBlocks[0].Header())
+	if err != errForkBeforeCheckpoint {
+		t.Fatalf("expected %v, got %v", errForkBeforeCheckpoint, err)
+	}
+}
+
+// managedValidateHeader runs validateHeader on the given header.
+func (cs *ConsensusSet) managedValidateHeader(h types.BlockHeader) error {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.db.View(func(tx *bolt.Tx) error {
+		return cs.validateHeader(boltTxWrapper{tx}, h)
+	})
+}
+
+// TestCheckpointRejectsForkBeforeCheckpoint checks that a consensus set that
+// has passed a checkpoint refuses blocks that fork the blockchain below it.
+func TestCheckpointRejectsForkBeforeCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cstMain, err := blankConsensusSetTester(t.Name()+"-main", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstMain.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name()+"-alt", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := cstMain.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cstAlt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mainBlocks, err := cstMain.cs.currentPathBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	altBlocks, err := cstAlt.cs.currentPathBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cstMain.cs.setCheckpoints(map[types.BlockHeight]types.BlockID{
+		0: types.GenesisID,
+		3: mainBlocks[2].ID(),
+	})
+
+	// Any block of the fork below the checkpoint is rejected, even though it
+	// does not conflict with the checkpoint directly.
+	err = cstMain.cs.AcceptBlock(altBlocks[0])
+	if err != errForkBeforeCheckpoint {
+		t.Fatalf("expected %v, got %v", errForkBeforeCheckpoint, err)
+	}
+
+	// Blocks above the checkpoint are still accepted.
+	if _, err := cstMain.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCheckpointSkipsSignatures checks that signatures are not verified for
+// blocks at or below the last checkpoint, and are verified otherwise.
+func TestCheckpointSkipsSignatures(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	pb := cst.cs.dbCurrentProcessedBlock()
+
+	// Create a transaction with a corrupt signature.
+	txnValue := types.NewCurrency64(1200)
+	txnBuilder, err := cst.wallet.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = txnBuilder.FundSiacoins(txnValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: txnValue})
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badTxn := txnSet[len(txnSet)-1]
+	if len(badTxn.TransactionSignatures) == 0 {
+		t.Fatal("transaction has no signatures")
+	}
+	badSig := append([]byte(nil), badTxn.TransactionSignatures[0].Signature...)
+	badSig[0]++
+	badTxn.TransactionSignatures[0].Signature = badSig
+	txnSet[len(txnSet)-1] = badTxn
+
+	block := types.Block{
+		ParentID:     pb.Block.ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(pb.Height + 1)}},
+		Transactions: txnSet,
+	}
+	block, _ = cst.miner.SolveBlock(block, pb.ChildTarget)
+
+	// Without a checkpoint the block is rejected.
+	err = cst.cs.AcceptBlock(block)
+	if err != crypto.ErrInvalidSignature {
+		t.Fatalf("expected %v, got %v", crypto.ErrInvalidSignature, err)
+	}
+
+	// Once the block is checkpointed, the signatures are no longer verified.
+	// The block must be removed from the DoS blocks first, as its previous
+	// rejection marked it as invalid.
+	cst.cs.mu.Lock()
+	delete(cst.cs.dosBlocks, block.ID())
+	cst.cs.mu.Unlock()
+	cst.cs.setCheckpoints(map[types.BlockHeight]types.BlockID{
+		0:             types.GenesisID,
+		pb.Height + 1: block.ID(),
+	})
+	err = cst.cs.AcceptBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCheckpointPathSignatures checks that signatures are only skipped for
+// blocks whose headers lead up to a checkpoint, not for every block below the
+// height of the last checkpoint.
+func TestCheckpointPathSignatures(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	pb := cst.cs.dbCurrentProcessedBlock()
+
+	// Create a transaction with a corrupt signature.
+	txnValue := types.NewCurrency64(1200)
+	txnBuilder, err := cst.wallet.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = txnBuilder.FundSiacoins(txnValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: txnValue})
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badTxn := txnSet[len(txnSet)-1]
+	badSig := append([]byte(nil), badTxn.TransactionSignatures[0].Signature...)
+	badSig[0]++
+	badTxn.TransactionSignatures[0].Signature = badSig
+	txnSet[len(txnSet)-1] = badTxn
+
+	// Create a block with the transaction and a child of that block.
+	block := types.Block{
+		ParentID:     pb.Block.ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(pb.Height + 1)}},
+		Transactions: txnSet,
+	}
+	block, _ = cst.miner.SolveBlock(block, pb.ChildTarget)
+	child := types.Block{
+		ParentID:     block.ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(pb.Height + 2)}},
+	}
+	child, _ = cst.miner.SolveBlock(child, pb.ChildTarget)
+
+	// Checkpoint a block at the height of the child that is not the child.
+	// The block is below the last checkpoint but not on the path to it, so
+	// its signatures are verified.
+	var otherID types.BlockID
+	otherID[0] = 1
+	cst.cs.setCheckpoints(map[types.BlockHeight]types.BlockID{
+		0:             types.GenesisID,
+		pb.Height + 2: otherID,
+	})
+	err = cst.cs.AcceptBlock(block)
+	if err != crypto.ErrInvalidSignature {
+		t.Fatalf("expected %v, got %v", crypto.ErrInvalidSignature, err)
+	}
+
+	// Checkpoint the child and add the headers that lead up to it to the
+	// checkpoint path. The signatures of the block are no longer verified.
+	cst.cs.mu.Lock()
+	delete(cst.cs.dosBlocks, block.ID())
+	cst.cs.mu.Unlock()
+	cst.cs.setCheckpoints(map[types.BlockHeight]types.BlockID{
+		0:             types.GenesisID,
+		pb.Height + 2: child.ID(),
+	})
+	cst.cs.mu.Lock()
+	cst.cs.addCheckpointPath([]types.BlockHeader{block.Header(), child.Header()}, pb.Height+2)
+	cst.cs.mu.Unlock()
+	if _, err := cst.cs.managedAcceptBlocks([]types.Block{block, child}); err != nil {
+		t.Fatal(err)
+	}
+	cst.cs.mu.Lock()
+	defer cst.cs.mu.Unlock()
+	if len(cst.cs.checkpointPath) != 0 {
+		t.Fatal("applied blocks weren't removed from the checkpoint path")
+	}
+}
+
+// TestStandardCheckpoints checks that blocks which conflict with the
+// checkpoints of the Standard build are rejected.
+func TestStandardCheckpoints(t *testing.T) {
+	if len(types.StandardCheckpoints) == 0 {
+		t.Fatal("Standard build has no checkpoints")
+	}
+	checkpoints := map[types.BlockHeight]types.BlockID{0: types.GenesisID}
+	for height, id := range types.StandardCheckpoints {
+		checkpoints[height] = id
+	}
+	cs := new(ConsensusSet)
+	cs.setCheckpoints(checkpoints)
+	for height, id := range types.StandardCheckpoints {
+		if err := cs.validateCheckpoints(nil, id, height); err != nil {
+			t.Fatalf("checkpoint at height %v was rejected: %v", height, err)
+		}
+		forkID := id
+		forkID[len(forkID)-1]++
+		if err := cs.validateCheckpoints(nil, forkID, height); err != errCheckpointMismatch {
+			t.Fatalf("expected %v, got %v", errCheckpointMismatch, err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ package consensus
 
 import (
 	"errors"
+	"sync"
 
 	bolt "github.com/coreos/bbolt"
 	"gitlab.com/NebulousLabs/demotemutex"
@@ -69,6 +70,17 @@ type ConsensusSet struct {
 	// the genesis block, meaning the PoW is not very expensive.
 	dosBlocks map[types.BlockID]struct{}
 
+	// checkpoints are the blocks that are known to be part of the canonical
+	// chain. Blocks that conflict with a checkpoint are rejected, and the
+	// signatures of transactions in blocks on the path to a checkpoint are
+	// not verified. checkpointPath contains the IDs of the blocks whose
+	// headers were downloaded and lead up to a checkpoint. Blocks are removed
+	// from checkpointPath once they are applied or at the end of the
+	// headers-first round that added them.
+	checkpoints    map[types.BlockHeight]types.BlockID
+	checkpointPath map[types.BlockID]struct{}
+	lastCheckpoint types.BlockHeight
+
 	// checkingConsistency is a bool indicating whether or not a consistency
 	// check is in progress. The consistency check logic call itself, resulting
 	// in infinite loops. This bool prevents that while still allowing for full
@@ -81,6 +93,14 @@ type ConsensusSet struct {
 	// whether the consensus set is synced with the network.
 	synced bool
 
+	// light indicates that the consensus set is a light consensus set. A
+	// light consensus set only stores the headers of the blockchain, and the
+	// miner payouts and transactions that are relevant to the addresses of
+	// its subscribers. syncMu serializes the synchronization rounds and
+	// rescans of a light consensus set.
+	light  bool
+	syncMu sync.Mutex
+
 	// Interfaces to abstract the dependencies of the ConsensusSet.
 	marshaler       marshaler
 	blockRuleHelper blockRuleHelper
@@ -106,6 +126,61 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 // there is an existing block database present in the persist directory, it
 // will be loaded.
 func NewCustomConsensusSet(gateway modules.Gateway, bootstrap bool, persistDir string, deps modules.Dependencies) (*ConsensusSet, error) {
+	cs, err := newConsensusSet(gateway, persistDir, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		// Sync with the network. Don't sync if we are testing because
+		// typically we don't have any mock peers to synchronize with in
+		// testing.
+		if bootstrap {
+			// We are in a virgin goroutine right now, so calling the threaded
+			// function without a goroutine is okay.
+			err = cs.threadedInitialBlockchainDownload()
+			if err != nil {
+				return
+			}
+		}
+
+		// threadedInitialBlockchainDownload will release the threadgroup 'Add'
+		// it was holding, so another needs to be grabbed to finish off this
+		// goroutine.
+		err = cs.tg.Add()
+		if err != nil {
+			return
+		}
+		defer cs.tg.Done()
+
+		// Register RPCs
+		gateway.RegisterRPC("SendBlocks", cs.rpcSendBlocks)
+		gateway.RegisterRPC("RelayHeader", cs.threadedRPCRelayHeader)
+		gateway.RegisterRPC("SendBlk", cs.rpcSendBlk)
+		gateway.RegisterRPC("SendHeaders", cs.rpcSendHeaders)
+		gateway.RegisterRPC("SendFilteredBlocks", cs.rpcSendFilteredBlocks)
+		gateway.RegisterConnectCall("SendBlocks", cs.threadedReceiveBlocks)
+		cs.tg.OnStop(func() {
+			cs.gateway.UnregisterRPC("SendBlocks")
+			cs.gateway.UnregisterRPC("RelayHeader")
+			cs.gateway.UnregisterRPC("SendBlk")
+			cs.gateway.UnregisterRPC("SendHeaders")
+			cs.gateway.UnregisterRPC("SendFilteredBlocks")
+			cs.gateway.UnregisterConnectCall("SendBlocks")
+		})
+
+		// Mark that we are synced with the network.
+		cs.mu.Lock()
+		cs.synced = true
+		cs.mu.Unlock()
+	}()
+
+	return cs, nil
+}
+
+// newConsensusSet creates a ConsensusSet and initializes its persistence
+// structures, without starting to synchronize with the network.
+func newConsensusSet(gateway modules.Gateway, persistDir string, deps modules.Dependencies) (*ConsensusSet, error) {
 	// Check for nil dependencies.
 	if gateway == nil {
 		return nil, errNilGateway
@@ -125,6 +200,10 @@ func NewCustomConsensusSet(gateway modules.Gateway, bootstrap bool, persistDir s
 
 		dosBlocks: make(map[types.BlockID]struct{}),
 
+		checkpoints:    types.Checkpoints,
+		checkpointPath: make(map[types.BlockID]struct{}),
+		lastCheckpoint: types.LastCheckpointHeight(),
+
 		marshaler:       stdMarshaler{},
 		blockRuleHelper: stdBlockRuleHelper{},
 		blockValidator:  NewBlockValidator(),
@@ -162,47 +241,6 @@ func NewCustomConsensusSet(gateway modules.Gateway, bootstrap bool, persistDir s
 	if err != nil {
 		return nil, err
 	}
-
-	go func() {
-		// Sync with the network. Don't sync if we are testing because
-		// typically we don't have any mock peers to synchronize with in
-		// testing.
-		if bootstrap {
-			// We are in a virgin goroutine right now, so calling the threaded
-			// function without a goroutine is okay.
-			err = cs.threadedInitialBlockchainDownload()
-			if err != nil {
-				return
-			}
-		}
-
-		// threadedInitialBlockchainDownload will release the threadgroup 'Add'
-		// it was holding, so another needs to be grabbed to finish off this
-		// goroutine.
-		err = cs.tg.Add()
-		if err != nil {
-			return
-		}
-		defer cs.tg.Done()
-
-		// Register RPCs
-		gateway.RegisterRPC("SendBlocks", cs.rpcSendBlocks)
-		gateway.RegisterRPC("RelayHeader", cs.threadedRPCRelayHeader)
-		gateway.RegisterRPC("SendBlk", cs.rpcSendBlk)
-		gateway.RegisterConnectCall("SendBlocks", cs.threadedReceiveBlocks)
-		cs.tg.OnStop(func() {
-			cs.gateway.UnregisterRPC("SendBlocks")
-			cs.gateway.UnregisterRPC("RelayHeader")
-			cs.gateway.UnregisterRPC("SendBlk")
-			cs.gateway.UnregisterConnectCall("SendBlocks")
-		})
-
-		// Mark that we are synced with the network.
-		cs.mu.Lock()
-		cs.synced = true
-		cs.mu.Unlock()
-	}()
-
 	return cs, nil
 }
 
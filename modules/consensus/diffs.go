@@ -201,7 +201,7 @@ func commitDiffSet(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
 // transactions are allowed to depend on each other. We can't be sure that a
 // transaction is valid unless we have applied all of the previous transactions
 // in the block, which means we need to apply while we verify.
-func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
+func (cs *ConsensusSet) generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
 	// Sanity check - the block being applied should have the current block as
 	// a parent.
 	if build.DEBUG && pb.Block.ParentID != currentBlockID(tx) {
@@ -215,9 +215,17 @@ func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
 
 	// Validate and apply each transaction in the block. They cannot be
 	// validated all at once because some transactions may not be valid until
-	// previous transactions have been applied.
+	// previous transactions have been applied. Signatures are not verified
+	// for blocks on the path to a checkpoint, because those blocks are known
+	// to be valid.
+	skipSignatures := cs.onCheckpointPath(pb.Block.ID(), pb.Height)
 	for _, txn := range pb.Block.Transactions {
-		err := validTransaction(tx, txn)
+		var err error
+		if skipSignatures {
+			err = validTransactionWithoutSignatures(tx, txn)
+		} else {
+			err = validTransaction(tx, txn)
+		}
 		if err != nil {
 			return err
 		}
@@ -239,8 +247,10 @@ func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
 	// true on fully validated blocks.
 	pb.DiffsGenerated = true
 
-	// Add the block to the current path and block map.
+	// Add the block to the current path and block map. The block no longer
+	// needs to be tracked on the checkpoint path once it is applied.
 	bid := pb.Block.ID()
+	delete(cs.checkpointPath, bid)
 	blockMap := tx.Bucket(BlockMap)
 	updateCurrentPath(tx, pb, modules.DiffApply)
 
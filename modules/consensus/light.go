@@ -0,0 +1,463 @@
+package consensus
+
+// light.go implements the light mode of the consensus set. A light consensus
+// set validates the headers of the blockchain with the same rules as a full
+// consensus set, but instead of full blocks it only stores the miner payouts
+// and the transactions that are relevant to the addresses of its subscribers.
+// These are downloaded from full nodes along with Merkle proofs, see
+// synchronize_light.go.
+//
+// Only the siacoin and siafund outputs of the relevant addresses are tracked.
+// Inputs are applied when the output they spend is tracked, and outputs are
+// applied when they belong to a relevant address. Siafund claims, file
+// contracts and their payouts are not tracked. Because the stored blocks are
+// incomplete, their ids can not be computed from the blocks, and subscribers
+// need to use the block ids of the consensus changes instead. For the same
+// reason, the blocks returned by CurrentBlock and BlockAtHeight are
+// incomplete.
+
+import (
+	"errors"
+
+	bolt "github.com/coreos/bbolt"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// LightAddresses is a database bucket of a light consensus set that
+	// contains the addresses whose transactions are tracked.
+	LightAddresses = []byte("LightAddresses")
+
+	errLightConsensusSet = errors.New("operation is not supported by a light consensus set")
+)
+
+// NewLight returns a new light ConsensusSet, containing at least the genesis
+// block. If there is an existing database present in the persist directory, it
+// will be loaded. The light consensus set synchronizes with the outbound peers
+// of the gateway in the background.
+func NewLight(gateway modules.Gateway, persistDir string) (*ConsensusSet, error) {
+	return NewCustomLightConsensusSet(gateway, persistDir, modules.ProdDependencies)
+}
+
+// NewCustomLightConsensusSet returns a new light ConsensusSet using the
+// provided dependencies.
+func NewCustomLightConsensusSet(gateway modules.Gateway, persistDir string, deps modules.Dependencies) (*ConsensusSet, error) {
+	cs, err := newConsensusSet(gateway, persistDir, deps)
+	if err != nil {
+		return nil, err
+	}
+	cs.light = true
+	err = cs.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(LightAddresses)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go cs.threadedSynchronizeLight()
+	return cs, nil
+}
+
+// isLightAddress returns true if the address is tracked by the light
+// consensus set.
+func isLightAddress(tx *bolt.Tx, uh types.UnlockHash) bool {
+	return tx.Bucket(LightAddresses).Get(uh[:]) != nil
+}
+
+// lightAddresses returns all addresses tracked by the light consensus set.
+func lightAddresses(tx *bolt.Tx) []types.UnlockHash {
+	var addrs []types.UnlockHash
+	tx.Bucket(LightAddresses).ForEach(func(k, _ []byte) error {
+		var uh types.UnlockHash
+		copy(uh[:], k)
+		addrs = append(addrs, uh)
+		return nil
+	})
+	return addrs
+}
+
+// addLightAddresses adds addresses to the set of tracked addresses.
+func addLightAddresses(tx *bolt.Tx, addrs []types.UnlockHash) error {
+	bucket := tx.Bucket(LightAddresses)
+	for _, uh := range addrs {
+		if err := bucket.Put(uh[:], []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedLightAddresses adds the relevant addresses of the subscribers to the
+// set of tracked addresses and returns the whole set. Addresses added this way
+// are only tracked from the current height onwards, which is sufficient for
+// addresses that have not been used yet, such as the lookahead of a wallet.
+func (cs *ConsensusSet) managedLightAddresses() ([]types.UnlockHash, error) {
+	// The subscribers are called without holding the lock, as they may call
+	// into the consensus set.
+	cs.mu.RLock()
+	subscribers := append([]modules.ConsensusSetSubscriber(nil), cs.subscribers...)
+	cs.mu.RUnlock()
+	var addrs []types.UnlockHash
+	for _, subscriber := range subscribers {
+		if ls, ok := subscriber.(modules.LightConsensusSetSubscriber); ok {
+			addrs = append(addrs, ls.RelevantAddresses()...)
+		}
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	var all []types.UnlockHash
+	err := cs.db.Update(func(tx *bolt.Tx) error {
+		if err := addLightAddresses(tx, addrs); err != nil {
+			return err
+		}
+		all = lightAddresses(tx)
+		return nil
+	})
+	return all, err
+}
+
+// applyLightTransaction applies the parts of a transaction that affect the
+// tracked outputs of a light consensus set, and adds the corresponding diffs
+// to the processed block.
+func applyLightTransaction(tx *bolt.Tx, pb *processedBlock, t types.Transaction) {
+	for _, sci := range t.SiacoinInputs {
+		sco, err := getSiacoinOutput(tx, sci.ParentID)
+		if err != nil {
+			continue
+		}
+		scod := modules.SiacoinOutputDiff{
+			Direction:     modules.DiffRevert,
+			ID:            sci.ParentID,
+			SiacoinOutput: sco,
+		}
+		pb.SiacoinOutputDiffs = append(pb.SiacoinOutputDiffs, scod)
+		commitSiacoinOutputDiff(tx, scod, modules.DiffApply)
+	}
+	for i, sco := range t.SiacoinOutputs {
+		if !isLightAddress(tx, sco.UnlockHash) {
+			continue
+		}
+		scod := modules.SiacoinOutputDiff{
+			Direction:     modules.DiffApply,
+			ID:            t.SiacoinOutputID(uint64(i)),
+			SiacoinOutput: sco,
+		}
+		pb.SiacoinOutputDiffs = append(pb.SiacoinOutputDiffs, scod)
+		commitSiacoinOutputDiff(tx, scod, modules.DiffApply)
+	}
+	for _, sfi := range t.SiafundInputs {
+		sfo, err := getSiafundOutput(tx, sfi.ParentID)
+		if err != nil {
+			continue
+		}
+		sfod := modules.SiafundOutputDiff{
+			Direction:     modules.DiffRevert,
+			ID:            sfi.ParentID,
+			SiafundOutput: sfo,
+		}
+		pb.SiafundOutputDiffs = append(pb.SiafundOutputDiffs, sfod)
+		commitSiafundOutputDiff(tx, sfod, modules.DiffApply)
+	}
+	for i, sfo := range t.SiafundOutputs {
+		if !isLightAddress(tx, sfo.UnlockHash) {
+			continue
+		}
+		sfod := modules.SiafundOutputDiff{
+			Direction:     modules.DiffApply,
+			ID:            t.SiafundOutputID(uint64(i)),
+			SiafundOutput: sfo,
+		}
+		pb.SiafundOutputDiffs = append(pb.SiafundOutputDiffs, sfod)
+		commitSiafundOutputDiff(tx, sfod, modules.DiffApply)
+	}
+}
+
+// applyLightBlock generates and applies the diffs of a block with the
+// provided id on top of the current path of a light consensus set. Unlike
+// generateAndApplyDiff, the transactions are not validated, as only the
+// header of the block can be validated without the full block.
+func applyLightBlock(tx *bolt.Tx, id types.BlockID, pb *processedBlock) {
+	createDSCOBucket(tx, pb.Height+types.MaturityDelay)
+	for _, t := range pb.Block.Transactions {
+		applyLightTransaction(tx, pb, t)
+	}
+	for i, payout := range pb.Block.MinerPayouts {
+		if !isLightAddress(tx, payout.UnlockHash) {
+			continue
+		}
+		dscod := modules.DelayedSiacoinOutputDiff{
+			Direction:      modules.DiffApply,
+			ID:             id.MinerPayoutID(uint64(i)),
+			SiacoinOutput:  payout,
+			MaturityHeight: pb.Height + types.MaturityDelay,
+		}
+		pb.DelayedSiacoinOutputDiffs = append(pb.DelayedSiacoinOutputDiffs, dscod)
+		commitDelayedSiacoinOutputDiff(tx, dscod, modules.DiffApply)
+	}
+	applyMaturedSiacoinOutputs(tx, pb)
+	pb.DiffsGenerated = true
+
+	pushPath(tx, id)
+	err := tx.Bucket(BlockMap).Put(id[:], encoding.Marshal(*pb))
+	if build.DEBUG && err != nil {
+		panic(err)
+	}
+}
+
+// commitLightDiffSet applies or reverts the diffs of a block with the
+// provided id. It is the equivalent of commitDiffSet for a light consensus
+// set.
+func commitLightDiffSet(tx *bolt.Tx, id types.BlockID, pb *processedBlock, dir modules.DiffDirection) {
+	createUpcomingDelayedOutputMaps(tx, pb, dir)
+	commitNodeDiffs(tx, pb, dir)
+	deleteObsoleteDelayedOutputMaps(tx, pb, dir)
+	if dir == modules.DiffApply {
+		pushPath(tx, id)
+	} else {
+		popPath(tx)
+	}
+}
+
+// forkLightBlockchain makes the block with the provided id the tip of the
+// current path of a light consensus set, reverting and applying blocks as
+// necessary. It is the equivalent of forkBlockchain, using explicit block ids.
+func forkLightBlockchain(tx *bolt.Tx, id types.BlockID, pb *processedBlock) (ce changeEntry, err error) {
+	// Trace back from the new block to the current path.
+	ids := []types.BlockID{id}
+	path := []*processedBlock{pb}
+	for {
+		pathID, err := getPath(tx, path[0].Height)
+		if err == nil && pathID == ids[0] {
+			break
+		}
+		parentID := path[0].Block.ParentID
+		parent, err := getBlockMap(tx, parentID)
+		if err != nil {
+			return changeEntry{}, err
+		}
+		ids = append([]types.BlockID{parentID}, ids...)
+		path = append([]*processedBlock{parent}, path...)
+	}
+
+	// Revert the current path down to the common parent.
+	for currentBlockID(tx) != ids[0] {
+		revertedID := currentBlockID(tx)
+		commitLightDiffSet(tx, revertedID, currentProcessedBlock(tx), modules.DiffRevert)
+		ce.RevertedBlocks = append(ce.RevertedBlocks, revertedID)
+	}
+
+	// Apply the blocks of the new path.
+	for i := 1; i < len(path); i++ {
+		if path[i].DiffsGenerated {
+			commitLightDiffSet(tx, ids[i], path[i], modules.DiffApply)
+		} else {
+			applyLightBlock(tx, ids[i], path[i])
+		}
+		ce.AppliedBlocks = append(ce.AppliedBlocks, ids[i])
+	}
+	return ce, nil
+}
+
+// managedAcceptFilteredBlocks adds the filtered blocks of the provided headers
+// to a light consensus set. The headers are validated with the same rules as
+// the headers of full blocks. The returned bool indicates whether the current
+// path was extended.
+func (cs *ConsensusSet) managedAcceptFilteredBlocks(headers []types.BlockHeader, blocks []types.Block) (chainExtended bool, err error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var changes []changeEntry
+	err = cs.db.Update(func(tx *bolt.Tx) error {
+		for i, h := range headers {
+			err := cs.validateHeader(boltTxWrapper{tx}, h)
+			if err == modules.ErrBlockKnown {
+				continue
+			} else if err != nil {
+				return err
+			}
+			// Blocks from the near future are accepted by a full consensus
+			// set once their timestamp is reached. A light consensus set
+			// downloads them again in a later round instead.
+			if h.Timestamp > types.CurrentTimestamp()+types.FutureThreshold {
+				return errFutureTimestamp
+			}
+
+			parent, err := getBlockMap(tx, h.ParentID)
+			if err != nil {
+				return err
+			}
+			id := h.ID()
+			child := cs.newChildWithID(tx, parent, blocks[i], id)
+			if !child.heavierThan(currentProcessedBlock(tx)) {
+				continue
+			}
+			ce, err := forkLightBlockchain(tx, id, child)
+			if err != nil {
+				return err
+			}
+			if err := appendChangeLog(tx, ce); err != nil {
+				return err
+			}
+			changes = append(changes, ce)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(changes) == 0 {
+		return false, modules.ErrNonExtendingBlock
+	}
+	for _, ce := range changes {
+		cs.updateSubscribers(ce)
+	}
+	return true, nil
+}
+
+// regenerateLightPath reverts the current path of a light consensus set down
+// to the parent of the first of the provided blocks and applies the blocks
+// again, replacing their transactions with the provided ones.
+func regenerateLightPath(tx *bolt.Tx, ids []types.BlockID, txns map[types.BlockID][]types.Transaction) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	first, err := getBlockMap(tx, ids[0])
+	if err != nil {
+		return err
+	}
+	for blockHeight(tx) >= first.Height {
+		commitLightDiffSet(tx, currentBlockID(tx), currentProcessedBlock(tx), modules.DiffRevert)
+	}
+	for _, id := range ids {
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		pb.Block.Transactions = txns[id]
+		pb.SiacoinOutputDiffs = nil
+		pb.FileContractDiffs = nil
+		pb.SiafundOutputDiffs = nil
+		pb.DelayedSiacoinOutputDiffs = nil
+		pb.SiafundPoolDiffs = nil
+		pb.DiffsGenerated = false
+		applyLightBlock(tx, id, pb)
+	}
+	return nil
+}
+
+// managedLightBlockChanged returns true if the filtered transactions of a
+// block of the current path differ from the transactions that are stored for
+// it. The filtered transactions are a superset of the stored ones, so
+// comparing their number is enough.
+func (cs *ConsensusSet) managedLightBlockChanged(id types.BlockID, txns []types.Transaction) (changed bool, err error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		changed = len(pb.Block.Transactions) != len(txns)
+		return nil
+	})
+	return changed, err
+}
+
+// managedRescanLight adds the relevant addresses of a subscriber to the
+// tracked addresses of a light consensus set. If any of them were not tracked
+// before, the relevant transactions of the current path are downloaded again
+// and the diffs of the current path are regenerated from the first block that
+// contains a transaction of the new addresses, so that the history of the new
+// addresses is part of the consensus changes sent to the subscriber. The
+// regenerated diffs are a superset of the previous ones, so subscribers that
+// have already seen the previous diffs stay consistent.
+func (cs *ConsensusSet) managedRescanLight(subscriber modules.ConsensusSetSubscriber) error {
+	ls, ok := subscriber.(modules.LightConsensusSetSubscriber)
+	if !ok {
+		return nil
+	}
+	addrs := ls.RelevantAddresses()
+
+	// Synchronization must not change the current path during the rescan.
+	cs.syncMu.Lock()
+	defer cs.syncMu.Unlock()
+
+	// Check whether there are any new addresses.
+	var newAddrs []types.UnlockHash
+	var tracked []types.UnlockHash
+	var ids []types.BlockID
+	cs.mu.RLock()
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		for _, uh := range addrs {
+			if !isLightAddress(tx, uh) {
+				newAddrs = append(newAddrs, uh)
+			}
+		}
+		if len(newAddrs) == 0 {
+			return nil
+		}
+		tracked = lightAddresses(tx)
+		for height := types.BlockHeight(1); height <= blockHeight(tx); height++ {
+			id, err := getPath(tx, height)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil || len(newAddrs) == 0 {
+		return err
+	}
+
+	// Download the relevant transactions of the current path. Only the
+	// blocks from the first block that contains a transaction of the new
+	// addresses onwards need to be regenerated, so the transactions of the
+	// blocks before it are not kept.
+	start := len(ids)
+	txns := make(map[types.BlockID][]types.Transaction)
+	if len(ids) > 0 {
+		peers := cs.managedOutboundPeers()
+		all := append(tracked, newAddrs...)
+		for i := 0; i < len(ids); i += maxFilteredBlocks {
+			end := i + maxFilteredBlocks
+			if end > len(ids) {
+				end = len(ids)
+			}
+			blocks, err := cs.managedDownloadFilteredBlocks(ids[i:end], peers, all)
+			if err != nil {
+				return err
+			}
+			for j, b := range blocks {
+				if start == len(ids) {
+					changed, err := cs.managedLightBlockChanged(ids[i+j], b.Transactions)
+					if err != nil {
+						return err
+					} else if !changed {
+						continue
+					}
+					start = i + j
+				}
+				if len(b.Transactions) > 0 {
+					txns[ids[i+j]] = b.Transactions
+				}
+			}
+		}
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.db.Update(func(tx *bolt.Tx) error {
+		if err := addLightAddresses(tx, newAddrs); err != nil {
+			return err
+		}
+		return regenerateLightPath(tx, ids[start:], txns)
+	})
+}
@@ -0,0 +1,343 @@
+package consensus
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bolt "github.com/coreos/bbolt"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/gateway"
+	"gitlab.com/NebulousLabs/Sia/modules/transactionpool"
+	"gitlab.com/NebulousLabs/Sia/modules/wallet"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// mockLightSubscriber is a light consensus set subscriber that tracks the
+// siacoin outputs of a set of addresses.
+type mockLightSubscriber struct {
+	addrs   []types.UnlockHash
+	outputs map[types.SiacoinOutputID]types.SiacoinOutput
+	mu      sync.Mutex
+}
+
+func newMockLightSubscriber(addrs ...types.UnlockHash) *mockLightSubscriber {
+	return &mockLightSubscriber{
+		addrs:   addrs,
+		outputs: make(map[types.SiacoinOutputID]types.SiacoinOutput),
+	}
+}
+
+func (s *mockLightSubscriber) ProcessConsensusChange(cc modules.ConsensusChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, scod := range cc.SiacoinOutputDiffs {
+		if scod.Direction == modules.DiffApply {
+			s.outputs[scod.ID] = scod.SiacoinOutput
+		} else {
+			delete(s.outputs, scod.ID)
+		}
+	}
+}
+
+func (s *mockLightSubscriber) RelevantAddresses() []types.UnlockHash {
+	return s.addrs
+}
+
+// hasOutput returns true if the subscriber has an output with the provided id.
+func (s *mockLightSubscriber) hasOutput(id types.SiacoinOutputID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.outputs[id]
+	return exists
+}
+
+// sendSiacoins sends siacoins from the wallet of the tester to an address and
+// mines the transaction into a block. The id of the output is returned.
+func (cst *consensusSetTester) sendSiacoins(value types.Currency, uh types.UnlockHash) (types.SiacoinOutputID, error) {
+	txns, err := cst.wallet.SendSiacoins(value, uh)
+	if err != nil {
+		return types.SiacoinOutputID{}, err
+	}
+	if _, err := cst.miner.AddBlock(); err != nil {
+		return types.SiacoinOutputID{}, err
+	}
+	txn := txns[len(txns)-1]
+	for i, sco := range txn.SiacoinOutputs {
+		if sco.UnlockHash == uh {
+			return txn.SiacoinOutputID(uint64(i)), nil
+		}
+	}
+	panic("transaction does not pay the address")
+}
+
+// newLightTester creates a light consensus set that is connected to the
+// provided full consensus set tester.
+func newLightTester(name string, full *consensusSetTester) (*ConsensusSet, modules.Gateway, error) {
+	testdir := build.TempDir(modules.ConsensusDir, name)
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	if err != nil {
+		return nil, nil, err
+	}
+	cs, err := NewLight(g, filepath.Join(testdir, modules.LightConsensusDir))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := g.Connect(full.gateway.Address()); err != nil {
+		return nil, nil, err
+	}
+	return cs, g, nil
+}
+
+// syncLight synchronizes a light consensus set with the provided peers until
+// its current path stops being extended.
+func syncLight(cs *ConsensusSet, peers []modules.NetAddress) error {
+	for {
+		extended, err := cs.managedSyncLight(peers)
+		if err != nil && err != errNoHeaderChain {
+			return err
+		}
+		if !extended {
+			return nil
+		}
+	}
+}
+
+// TestFilteredBlock checks that a filtered block only contains the relevant
+// transactions of a block, and that tampered filtered blocks are rejected.
+func TestFilteredBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	uh := randAddress()
+	if _, err := cst.sendSiacoins(types.SiacoinPrecision, uh); err != nil {
+		t.Fatal(err)
+	}
+	b := cst.cs.dbCurrentProcessedBlock().Block
+	id := b.ID()
+
+	// Only the transaction paying the address is part of the filtered block.
+	relevant := map[types.UnlockHash]struct{}{uh: {}}
+	fb := newFilteredBlock(b, relevant)
+	filtered, err := fb.block(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered.Transactions) != 1 || !relevantTransaction(filtered.Transactions[0], relevant) {
+		t.Fatal("filtered block does not contain the relevant transaction")
+	}
+	if len(filtered.MinerPayouts) != len(b.MinerPayouts) {
+		t.Fatal("filtered block does not contain the miner payouts")
+	}
+
+	// A filtered block for no addresses contains no transactions.
+	empty, err := newFilteredBlock(b, nil).block(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Transactions) != 0 {
+		t.Fatal("filtered block contains irrelevant transactions")
+	}
+
+	// A filtered block is rejected for a different id.
+	if _, err := fb.block(types.BlockID{}); err != errBlockMismatch {
+		t.Fatalf("expected %v, got %v", errBlockMismatch, err)
+	}
+
+	// Tampered transactions and miner payouts are rejected. The filtered
+	// blocks share their slices with the block, so every filtered block is
+	// created from a fresh copy of the block.
+	filter := func(addrs map[types.UnlockHash]struct{}) filteredBlock {
+		return newFilteredBlock(cst.cs.dbCurrentProcessedBlock().Block, addrs)
+	}
+	tampered := filter(relevant)
+	tampered.Transactions[0].Transaction.SiacoinOutputs[0].Value = types.SiacoinPrecision.Mul64(1e6)
+	if _, err := tampered.block(id); err != errInvalidFilteredBlock {
+		t.Fatalf("expected %v, got %v", errInvalidFilteredBlock, err)
+	}
+	tampered = filter(nil)
+	tampered.MinerPayouts[0].UnlockHash = uh
+	if _, err := tampered.block(id); err != errInvalidFilteredBlock {
+		t.Fatalf("expected %v, got %v", errInvalidFilteredBlock, err)
+	}
+	tampered = filter(relevant)
+	tampered.Transactions[0].Proof = []crypto.Hash{{}}
+	if _, err := tampered.block(id); err != errInvalidFilteredBlock {
+		t.Fatalf("expected %v, got %v", errInvalidFilteredBlock, err)
+	}
+}
+
+// TestBlockMerkleTree checks that the cached Merkle tree of a block has the
+// same root as the trees of the crypto package, and that its proofs are
+// valid for every leaf.
+func TestBlockMerkleTree(t *testing.T) {
+	for numLeaves := 1; numLeaves <= 33; numLeaves++ {
+		leaves := make([][]byte, numLeaves)
+		ct := crypto.NewTree()
+		for i := range leaves {
+			leaves[i] = fastrand.Bytes(fastrand.Intn(100) + 1)
+			ct.Push(leaves[i])
+		}
+		root := ct.Root()
+		tree := newBlockMerkleTree(leaves)
+		if tree.nodes[[2]uint64{0, uint64(numLeaves)}] != root {
+			t.Fatalf("wrong root for %v leaves", numLeaves)
+		}
+		for i := range leaves {
+			if !crypto.VerifySegment(leaves[i], tree.proof(uint64(i)), uint64(numLeaves), uint64(i), root) {
+				t.Fatalf("invalid proof for leaf %v of %v", i, numLeaves)
+			}
+		}
+	}
+}
+
+// TestLightSync checks that a light consensus set follows the blockchain of a
+// full node, tracks the outputs of the addresses of its subscribers, and
+// rescans the blockchain for the addresses of new subscribers.
+func TestLightSync(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	full, err := createConsensusSetTester(t.Name() + "-full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer full.Close()
+	light, g, err := newLightTester(t.Name()+"-light", full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	defer light.Close()
+	peers := []modules.NetAddress{full.gateway.Address()}
+
+	// Subscribe before synchronizing and check that an output sent to the
+	// address of the subscriber is tracked.
+	uh1 := randAddress()
+	sub1 := newMockLightSubscriber(uh1)
+	if err := light.ConsensusSetSubscribe(sub1, modules.ConsensusChangeBeginning, nil); err != nil {
+		t.Fatal(err)
+	}
+	id1, err := full.sendSiacoins(types.SiacoinPrecision, uh1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncLight(light, peers); err != nil {
+		t.Fatal(err)
+	}
+	if light.dbCurrentBlockID() != full.cs.dbCurrentBlockID() {
+		t.Fatal("light consensus set did not synchronize to the tip of the full node")
+	}
+	if !sub1.hasOutput(id1) {
+		t.Fatal("subscriber did not receive the output of its address")
+	}
+
+	// An address that was paid before it was tracked is found by the rescan
+	// of a new subscriber.
+	uh2 := randAddress()
+	id2, err := full.sendSiacoins(types.SiacoinPrecision, uh2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := full.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncLight(light, peers); err != nil {
+		t.Fatal(err)
+	}
+	sub2 := newMockLightSubscriber(uh2)
+	if err := light.ConsensusSetSubscribe(sub2, modules.ConsensusChangeBeginning, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !sub2.hasOutput(id2) {
+		t.Fatal("rescan did not find the output of the new address")
+	}
+	err = light.db.View(func(tx *bolt.Tx) error {
+		if !isSiacoinOutput(tx, id1) || !isSiacoinOutput(tx, id2) {
+			t.Error("light consensus set does not contain the tracked outputs")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks can not be submitted to a light consensus set.
+	if err := light.AcceptBlock(types.Block{}); err != errLightConsensusSet {
+		t.Fatalf("expected %v, got %v", errLightConsensusSet, err)
+	}
+}
+
+// TestLightWallet checks that a wallet running on a light consensus set sees
+// the siacoins sent to it.
+func TestLightWallet(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	full, err := createConsensusSetTester(t.Name() + "-full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer full.Close()
+	light, g, err := newLightTester(t.Name()+"-light", full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	defer light.Close()
+
+	testdir := build.TempDir(modules.ConsensusDir, t.Name()+"-light")
+	tp, err := transactionpool.New(light, g, filepath.Join(testdir, modules.TransactionPoolDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close()
+	w, err := wallet.New(light, tp, filepath.Join(testdir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	key := crypto.GenerateSiaKey(crypto.TypeDefaultWallet)
+	if _, err := w.Encrypt(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+	uc, err := w.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := types.SiacoinPrecision.Mul64(10)
+	if _, err := full.sendSiacoins(value, uc.UnlockHash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncLight(light, []modules.NetAddress{full.gateway.Address()}); err != nil {
+		t.Fatal(err)
+	}
+	balance, _, _, err := w.ConfirmedBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !balance.Equals(value) {
+		t.Fatalf("expected wallet balance %v, got %v", value, balance)
+	}
+	if light.Height() != full.cs.Height() {
+		t.Fatal("light consensus set did not synchronize to the height of the full node")
+	}
+}
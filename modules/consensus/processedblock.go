@@ -58,7 +58,7 @@ func (pb *processedBlock) childDepth() types.Target {
 
 // targetAdjustmentBase returns the magnitude that the target should be
 // adjusted by before a clamp is applied.
-func (cs *ConsensusSet) targetAdjustmentBase(blockMap *bolt.Bucket, pb *processedBlock) *big.Rat {
+func (cs *ConsensusSet) targetAdjustmentBase(blockMap dbBucket, pb *processedBlock) *big.Rat {
 	// Grab the block that was generated 'TargetWindow' blocks prior to the
 	// parent. If there are not 'TargetWindow' blocks yet, stop at the genesis
 	// block.
@@ -101,7 +101,7 @@ func clampTargetAdjustment(base *big.Rat) *big.Rat {
 
 // setChildTarget computes the target of a blockNode's child. All children of a node
 // have the same target.
-func (cs *ConsensusSet) setChildTarget(blockMap *bolt.Bucket, pb *processedBlock) {
+func (cs *ConsensusSet) setChildTarget(blockMap dbBucket, pb *processedBlock) {
 	// Fetch the parent block.
 	var parent processedBlock
 	parentBytes := blockMap.Get(pb.Block.ParentID[:])
@@ -122,8 +122,14 @@ func (cs *ConsensusSet) setChildTarget(blockMap *bolt.Bucket, pb *processedBlock
 // newChild creates a blockNode from a block and adds it to the parent's set of
 // children. The new node is also returned. It necessarily modifies the database
 func (cs *ConsensusSet) newChild(tx *bolt.Tx, pb *processedBlock, b types.Block) *processedBlock {
+	return cs.newChildWithID(tx, pb, b, b.ID())
+}
+
+// newChildWithID is newChild for a block whose id is provided separately. The
+// blocks of a light consensus set only contain the relevant transactions, so
+// their ids can not be computed from the blocks themselves.
+func (cs *ConsensusSet) newChildWithID(tx *bolt.Tx, pb *processedBlock, b types.Block, childID types.BlockID) *processedBlock {
 	// Create the child node.
-	childID := b.ID()
 	child := &processedBlock{
 		Block:  b,
 		Height: pb.Height + 1,
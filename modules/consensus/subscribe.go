@@ -28,6 +28,7 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 		// Because the direction is 'revert', the order of the diffs needs to
 		// be flipped and the direction of the diffs also needs to be flipped.
 		cc.RevertedBlocks = append(cc.RevertedBlocks, revertedBlock.Block)
+		cc.RevertedBlockIDs = append(cc.RevertedBlockIDs, revertedBlockID)
 		for i := len(revertedBlock.SiacoinOutputDiffs) - 1; i >= 0; i-- {
 			scod := revertedBlock.SiacoinOutputDiffs[i]
 			scod.Direction = !scod.Direction
@@ -62,6 +63,7 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 		}
 
 		cc.AppliedBlocks = append(cc.AppliedBlocks, appliedBlock.Block)
+		cc.AppliedBlockIDs = append(cc.AppliedBlockIDs, appliedBlockID)
 		for _, scod := range appliedBlock.SiacoinOutputDiffs {
 			cc.SiacoinOutputDiffs = append(cc.SiacoinOutputDiffs, scod)
 		}
@@ -239,6 +241,15 @@ func (cs *ConsensusSet) ConsensusSetSubscribe(subscriber modules.ConsensusSetSub
 	}
 	defer cs.tg.Done()
 
+	// A light consensus set needs to download the history of any new
+	// addresses of the subscriber before the subscriber can be caught up.
+	if cs.light {
+		err = cs.managedRescanLight(subscriber)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Call managedInitializeSubscribe until the new module is up-to-date.
 	for {
 		start, err = cs.managedInitializeSubscribe(subscriber, start, cancel)
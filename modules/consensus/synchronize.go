@@ -132,6 +132,33 @@ func blockHistory(tx *bolt.Tx) (blockIDs [32]types.BlockID) {
 	return blockIDs
 }
 
+// syncStartHeight returns the height of the first block in the current path
+// that is missing from a peer with the provided block history. The returned
+// bool is false if none of the blocks in the history are in the current path,
+// or if the peer already has all blocks of the current path.
+func syncStartHeight(tx *bolt.Tx, knownBlocks [32]types.BlockID) (types.BlockHeight, bool) {
+	csHeight := blockHeight(tx)
+	for _, id := range knownBlocks {
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			continue
+		}
+		pathID, err := getPath(tx, pb.Height)
+		if err != nil {
+			continue
+		}
+		if pathID != pb.Block.ID() {
+			continue
+		}
+		if pb.Height == csHeight {
+			return 0, false
+		}
+		// Start from the child of the common block.
+		return pb.Height + 1, true
+	}
+	return 0, false
+}
+
 // managedReceiveBlocks is the calling end of the SendBlocks RPC, without the
 // threadgroup wrapping.
 func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr error) {
@@ -293,32 +320,11 @@ func (cs *ConsensusSet) rpcSendBlocks(conn modules.PeerConn) error {
 	}
 
 	// Find the most recent block from knownBlocks in the current path.
-	found := false
+	var found bool
 	var start types.BlockHeight
-	var csHeight types.BlockHeight
 	cs.mu.RLock()
 	err = cs.db.View(func(tx *bolt.Tx) error {
-		csHeight = blockHeight(tx)
-		for _, id := range knownBlocks {
-			pb, err := getBlockMap(tx, id)
-			if err != nil {
-				continue
-			}
-			pathID, err := getPath(tx, pb.Height)
-			if err != nil {
-				continue
-			}
-			if pathID != pb.Block.ID() {
-				continue
-			}
-			if pb.Height == csHeight {
-				break
-			}
-			found = true
-			// Start from the child of the common block.
-			start = pb.Height + 1
-			break
-		}
+		start, found = syncStartHeight(tx, knownBlocks)
 		return nil
 	})
 	cs.mu.RUnlock()
@@ -537,6 +543,44 @@ func (cs *ConsensusSet) managedReceiveBlock(id types.BlockID) modules.RPCFunc {
 	}
 }
 
+// managedInitialHeadersFirstSync performs rounds of headers-first
+// synchronization with the outbound peers until the current path stops being
+// extended. Errors of individual rounds are logged but not returned, as the
+// SendBlocks RPC is used as a fallback for peers that do not support
+// headers-first synchronization. An error is only returned if the consensus
+// set is shutting down.
+func (cs *ConsensusSet) managedInitialHeadersFirstSync() error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	var outbound []modules.NetAddress
+	for _, p := range cs.gateway.Peers() {
+		if !p.Inbound {
+			outbound = append(outbound, p.NetAddress)
+		}
+	}
+	if len(outbound) == 0 {
+		return nil
+	}
+	for {
+		select {
+		case <-cs.tg.StopChan():
+			return errEarlyStop
+		default:
+		}
+		extended, err := cs.managedSyncHeadersFirst(outbound)
+		if err != nil && err != errNoHeaderChain {
+			cs.log.Debugln("WARN: headers-first synchronization failed:", err)
+		}
+		if !extended {
+			return nil
+		}
+	}
+}
+
 // threadedInitialBlockchainDownload performs the IBD on outbound peers. Blocks
 // are downloaded from one peer at a time in 5 minute intervals, so as to
 // prevent any one peer from significantly slowing down IBD.
@@ -557,6 +601,15 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 	numOutboundSynced := 0
 	numOutboundNotSynced := 0
 	for {
+		// Synchronize with the outbound peers headers-first. This downloads
+		// the blocks of the heaviest valid header chain from several peers in
+		// parallel. The SendBlocks RPC below then determines whether the
+		// consensus set is synced with each peer.
+		err := cs.managedInitialHeadersFirstSync()
+		if err != nil {
+			return err
+		}
+
 		numOutboundSynced = 0
 		numOutboundNotSynced = 0
 		for _, p := range cs.gateway.Peers() {
@@ -0,0 +1,649 @@
+package consensus
+
+// synchronize_headers.go implements headers-first synchronization. The header
+// chains of several peers are downloaded and validated first, without their
+// blocks. The blocks of the heaviest valid header chain are then downloaded in
+// parallel from all of the peers that reported that chain, and accepted in
+// order as they arrive.
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errBlockMismatch   = errors.New("peer sent a block that does not match the requested block")
+	errNoBlockSources  = errors.New("no peers are able to provide the blocks of the header chain")
+	errNoHeaderChain   = errors.New("no peer provided a valid header chain")
+	errHeaderChainSize = errors.New("peer sent more headers than requested")
+
+	// maxCatchUpHeaders is the maximum number of headers that are sent in
+	// response to a single SendHeaders RPC.
+	maxCatchUpHeaders = build.Select(build.Var{
+		Standard: 2000,
+		Dev:      500,
+		Testing:  20,
+	}).(int)
+
+	// maxHeaderRequests is the maximum number of SendHeaders RPCs that are
+	// made to a single peer during one round of headers-first
+	// synchronization.
+	maxHeaderRequests = build.Select(build.Var{
+		Standard: 10,
+		Dev:      5,
+		Testing:  3,
+	}).(int)
+
+	// maxPendingBatches is the number of batches per source peer that may be
+	// downloaded ahead of the next batch that is accepted.
+	maxPendingBatches = 2
+
+	// sendHeadersTimeout is the timeout for the SendHeaders RPC.
+	sendHeadersTimeout = build.Select(build.Var{
+		Standard: 120 * time.Second,
+		Dev:      40 * time.Second,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// headerChainBucket is a dbBucket that overlays the processed blocks of a
+	// header chain on top of the block map of the database.
+	headerChainBucket struct {
+		base dbBucket
+		pbs  map[types.BlockID][]byte
+	}
+
+	// headerChainTx is a dbTx that allows a chain of headers to be validated
+	// with validateHeader before the corresponding blocks are known. Every
+	// validated header is added to the block map of the headerChainTx as a
+	// processed block that only contains the fields of the header which are
+	// relevant for validating its children.
+	headerChainTx struct {
+		tx       *bolt.Tx
+		blockMap headerChainBucket
+		totals   map[types.BlockID]headerChainTotals
+	}
+
+	// headerChainTotals are the oak totals of a header in a header chain.
+	headerChainTotals struct {
+		totalTime   int64
+		totalTarget types.Target
+	}
+
+	// headerChain is a chain of headers reported by a peer. err is set if
+	// the peer failed to respond.
+	headerChain struct {
+		peer    modules.NetAddress
+		headers []types.BlockHeader
+		tip     *processedBlock
+		err     error
+	}
+)
+
+// newHeaderChainTx creates a headerChainTx on top of the provided bolt
+// transaction.
+func newHeaderChainTx(tx *bolt.Tx) headerChainTx {
+	return headerChainTx{
+		tx: tx,
+		blockMap: headerChainBucket{
+			base: tx.Bucket(BlockMap),
+			pbs:  make(map[types.BlockID][]byte),
+		},
+		totals: make(map[types.BlockID]headerChainTotals),
+	}
+}
+
+// Get returns the processed block of a header in the header chain, or the
+// processed block stored in the database if the header is not part of the
+// header chain.
+func (b headerChainBucket) Get(key []byte) []byte {
+	var id types.BlockID
+	copy(id[:], key)
+	if pb, exists := b.pbs[id]; exists {
+		return pb
+	}
+	return b.base.Get(key)
+}
+
+// Bucket returns the dbBucket associated with the given bucket name.
+func (htx headerChainTx) Bucket(name []byte) dbBucket {
+	if bytes.Equal(name, BlockMap) {
+		return htx.blockMap
+	}
+	return boltTxWrapper{htx.tx}.Bucket(name)
+}
+
+// headerChainBlockTotals returns the oak totals of the block or header with
+// the given id.
+func (cs *ConsensusSet) headerChainBlockTotals(htx headerChainTx, id types.BlockID) (int64, types.Target) {
+	if totals, exists := htx.totals[id]; exists {
+		return totals.totalTime, totals.totalTarget
+	}
+	return cs.getBlockTotals(htx.tx, id)
+}
+
+// newHeaderChild creates the processed block of a header, using the same
+// difficulty adjustment rules as newChild, and adds it to the header chain.
+func (cs *ConsensusSet) newHeaderChild(htx headerChainTx, pb *processedBlock, h types.BlockHeader) *processedBlock {
+	childID := h.ID()
+	child := &processedBlock{
+		Block: types.Block{
+			ParentID:  h.ParentID,
+			Nonce:     h.Nonce,
+			Timestamp: h.Timestamp,
+		},
+		Height: pb.Height + 1,
+		Depth:  pb.childDepth(),
+	}
+
+	prevTotalTime, prevTotalTarget := cs.headerChainBlockTotals(htx, h.ParentID)
+	totalTime, totalTarget := blockTotals(child.Height, prevTotalTime, pb.Block.Timestamp, h.Timestamp, prevTotalTarget, pb.ChildTarget)
+	htx.totals[childID] = headerChainTotals{
+		totalTime:   totalTime,
+		totalTarget: totalTarget,
+	}
+
+	if pb.Height < types.OakHardforkBlock {
+		cs.setChildTarget(htx.blockMap, child)
+	} else {
+		child.ChildTarget = cs.childTargetOak(prevTotalTime, prevTotalTarget, pb.ChildTarget, pb.Height, pb.Block.Timestamp)
+	}
+	htx.blockMap.pbs[childID] = encoding.Marshal(*child)
+	return child
+}
+
+// managedValidateHeaderChain validates a contiguous chain of headers using the
+// target and timestamp rules of validateHeader. The first header must extend
+// a block known to the consensus set. The processed block of the last header
+// is returned, which contains the depth of the header chain. If the header
+// chain is not heavier than the current path, ErrNonExtendingBlock is
+// returned.
+func (cs *ConsensusSet) managedValidateHeaderChain(headers []types.BlockHeader) (tip *processedBlock, err error) {
+	if len(headers) == 0 {
+		return nil, modules.ErrNonExtendingBlock
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		htx := newHeaderChainTx(tx)
+		for i, h := range headers {
+			id := h.ID()
+			if i > 0 && h.ParentID != headers[i-1].ID() {
+				return errNonLinearChain
+			}
+			err := cs.validateHeader(htx, h)
+			if err == modules.ErrBlockKnown {
+				// The block is already in the database, its processed block
+				// can be used as the parent of the next header.
+				tip, err = getBlockMap(tx, id)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var parent processedBlock
+			err = encoding.Unmarshal(htx.blockMap.Get(h.ParentID[:]), &parent)
+			if err != nil {
+				return err
+			}
+			tip = cs.newHeaderChild(htx, &parent, h)
+		}
+		if !tip.heavierThan(currentProcessedBlock(tx)) {
+			return modules.ErrNonExtendingBlock
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tip, nil
+}
+
+// managedReceiveHeaders returns an RPCFunc that is the calling end of the
+// SendHeaders RPC. The headers sent by the peer and whether more headers are
+// available are written to the provided pointers.
+func (cs *ConsensusSet) managedReceiveHeaders(history [32]types.BlockID, headers *[]types.BlockHeader, moreAvailable *bool) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		err := conn.SetDeadline(time.Now().Add(sendHeadersTimeout))
+		if err != nil {
+			return err
+		}
+		if err := encoding.WriteObject(conn, history); err != nil {
+			return err
+		}
+		maxLen := uint64(maxCatchUpHeaders)*types.BlockHeaderSize + 8
+		if err := encoding.ReadObject(conn, headers, maxLen); err != nil {
+			return err
+		}
+		if len(*headers) > maxCatchUpHeaders {
+			return errHeaderChainSize
+		}
+		return encoding.ReadObject(conn, moreAvailable, 1)
+	}
+}
+
+// rpcSendHeaders is the receiving end of the SendHeaders RPC. It reads the
+// block history of the caller and responds with up to maxCatchUpHeaders
+// headers of the current path, starting at the child of the most recent block
+// that the caller and the consensus set have in common. It also sends a
+// boolean indicating whether more headers are available.
+func (cs *ConsensusSet) rpcSendHeaders(conn modules.PeerConn) error {
+	err := conn.SetDeadline(time.Now().Add(sendHeadersTimeout))
+	if err != nil {
+		return err
+	}
+	finishedChan := make(chan struct{})
+	defer close(finishedChan)
+	go func() {
+		select {
+		case <-cs.tg.StopChan():
+		case <-finishedChan:
+		}
+		conn.Close()
+	}()
+	err = cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	// Read the block history of the caller.
+	var knownBlocks [32]types.BlockID
+	err = encoding.ReadObject(conn, &knownBlocks, 32*crypto.HashSize)
+	if err != nil {
+		return err
+	}
+
+	// Collect the headers that the caller is missing.
+	var headers []types.BlockHeader
+	var moreAvailable bool
+	cs.mu.RLock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		start, found := syncStartHeight(tx, knownBlocks)
+		if !found {
+			return nil
+		}
+		height := blockHeight(tx)
+		for i := start; i <= height && len(headers) < maxCatchUpHeaders; i++ {
+			id, err := getPath(tx, i)
+			if err != nil {
+				return err
+			}
+			pb, err := getBlockMap(tx, id)
+			if err != nil {
+				return err
+			}
+			headers = append(headers, pb.Block.Header())
+		}
+		moreAvailable = start+types.BlockHeight(len(headers)) <= height
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := encoding.WriteObject(conn, headers); err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, moreAvailable)
+}
+
+// managedDownloadBlock returns an RPCFunc that is the calling end of the
+// SendBlk RPC. Unlike managedReceiveBlock, it does not accept the block, but
+// writes it to the provided pointer after checking that it matches the
+// requested id.
+func (cs *ConsensusSet) managedDownloadBlock(id types.BlockID, block *types.Block) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		if err := encoding.WriteObject(conn, id); err != nil {
+			return err
+		}
+		if err := encoding.ReadObject(conn, block, types.BlockSizeLimit); err != nil {
+			return err
+		}
+		if block.ID() != id {
+			return errBlockMismatch
+		}
+		return nil
+	}
+}
+
+// blockBatchDownload tracks the download of the blocks of a header chain in
+// batches of MaxCatchUpBlocks. Batches are downloaded in parallel, but
+// accepted in order. Batches that a peer failed to provide are requeued for
+// the remaining peers.
+type blockBatchDownload struct {
+	batches [][]types.BlockID
+
+	// queue contains the indices of the batches that still need to be
+	// downloaded in ascending order. downloaded contains the batches that
+	// were downloaded but not accepted yet. next is the index of the next
+	// batch that is accepted.
+	queue      []int
+	downloaded map[int][]types.Block
+	inFlight   int
+	next       int
+	stopped    bool
+	workers    int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+// newBlockBatchDownload creates a blockBatchDownload for the blocks of the
+// provided headers.
+func newBlockBatchDownload(headers []types.BlockHeader) *blockBatchDownload {
+	d := &blockBatchDownload{
+		downloaded: make(map[int][]types.Block),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	for i := 0; i < len(headers); i += int(MaxCatchUpBlocks) {
+		var batch []types.BlockID
+		for j := i; j < len(headers) && j < i+int(MaxCatchUpBlocks); j++ {
+			batch = append(batch, headers[j].ID())
+		}
+		d.queue = append(d.queue, len(d.batches))
+		d.batches = append(d.batches, batch)
+	}
+	return d
+}
+
+// managedNextBatch returns the index of the next batch that a peer should
+// download. It blocks while the next queued batch is too far ahead of the
+// batches that were accepted, or while the queue is empty but batches that
+// are being downloaded might still be requeued. false is returned once there
+// are no batches left to download.
+func (d *blockBatchDownload) managedNextBatch() (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		if d.stopped || (len(d.queue) == 0 && d.inFlight == 0) {
+			return 0, false
+		}
+		if len(d.queue) > 0 && d.queue[0] < d.next+maxPendingBatches*d.workers {
+			i := d.queue[0]
+			d.queue = d.queue[1:]
+			d.inFlight++
+			return i, true
+		}
+		d.cond.Wait()
+	}
+}
+
+// managedFinishBatch records the result of downloading a batch. If the
+// download failed, the batch is requeued.
+func (d *blockBatchDownload) managedFinishBatch(i int, blocks []types.Block, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight--
+	if err != nil {
+		j := sort.SearchInts(d.queue, i)
+		d.queue = append(d.queue[:j], append([]int{i}, d.queue[j:]...)...)
+	} else {
+		d.downloaded[i] = blocks
+	}
+	d.cond.Broadcast()
+}
+
+// managedRemoveWorker is called when a peer stops downloading batches.
+func (d *blockBatchDownload) managedRemoveWorker() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.workers--
+	d.cond.Broadcast()
+}
+
+// managedNextDownloaded blocks until the next batch was downloaded and
+// returns it. false is returned if all peers stopped before the batch was
+// downloaded, or if all batches were accepted.
+func (d *blockBatchDownload) managedNextDownloaded() ([]types.Block, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		if blocks, exists := d.downloaded[d.next]; exists {
+			delete(d.downloaded, d.next)
+			d.next++
+			d.cond.Broadcast()
+			return blocks, true
+		}
+		if d.stopped || d.workers == 0 || d.next == len(d.batches) {
+			return nil, false
+		}
+		d.cond.Wait()
+	}
+}
+
+// managedStop stops the download.
+func (d *blockBatchDownload) managedStop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopped = true
+	d.cond.Broadcast()
+}
+
+// managedDownloadBatch downloads the blocks of a batch from a peer.
+func (cs *ConsensusSet) managedDownloadBatch(peer modules.NetAddress, ids []types.BlockID) ([]types.Block, error) {
+	batch := make([]types.Block, len(ids))
+	for j, id := range ids {
+		select {
+		case <-cs.tg.StopChan():
+			return nil, errEarlyStop
+		default:
+		}
+		err := cs.gateway.RPC(peer, "SendBlk", cs.managedDownloadBlock(id, &batch[j]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// managedDownloadAndAcceptBlocks downloads the blocks of a header chain in
+// batches of MaxCatchUpBlocks and accepts them in order as they arrive. Every
+// source peer downloads batches in parallel until it fails to provide a
+// batch, in which case the batch is requeued for the remaining peers. Only a
+// limited number of batches is held in memory while waiting for an earlier
+// batch. The returned bool indicates whether the current path was extended.
+func (cs *ConsensusSet) managedDownloadAndAcceptBlocks(headers []types.BlockHeader, sources []modules.NetAddress) (chainExtended bool, err error) {
+	d := newBlockBatchDownload(headers)
+	d.workers = len(sources)
+	var wg sync.WaitGroup
+	for _, peer := range sources {
+		wg.Add(1)
+		go func(peer modules.NetAddress) {
+			defer wg.Done()
+			defer d.managedRemoveWorker()
+			for {
+				i, ok := d.managedNextBatch()
+				if !ok {
+					return
+				}
+				blocks, err := cs.managedDownloadBatch(peer, d.batches[i])
+				d.managedFinishBatch(i, blocks, err)
+				if err != nil {
+					cs.log.Debugf("WARN: failed to download batch %v from %v: %v", i, peer, err)
+					return
+				}
+			}
+		}(peer)
+	}
+	defer wg.Wait()
+	defer d.managedStop()
+
+	for {
+		blocks, ok := d.managedNextDownloaded()
+		if !ok {
+			return chainExtended, nil
+		}
+		extended, acceptErr := cs.managedAcceptBlocks(blocks)
+		if extended {
+			chainExtended = true
+		}
+		if acceptErr != nil && acceptErr != modules.ErrNonExtendingBlock && acceptErr != modules.ErrBlockKnown {
+			return chainExtended, acceptErr
+		}
+	}
+}
+
+// continueHistory returns a block history that starts with the provided id,
+// followed by the provided history. It is used to request the headers that
+// follow the headers that a peer already sent.
+func continueHistory(history [32]types.BlockID, id types.BlockID) (h [32]types.BlockID) {
+	h[0] = id
+	copy(h[1:31], history[:30])
+	h[31] = history[31]
+	return h
+}
+
+// managedRequestHeaderChains requests the header chains of the provided peers
+// in parallel, starting at the block history of the current path. The header
+// chain of a peer that failed to respond is empty.
+func (cs *ConsensusSet) managedRequestHeaderChains(peers []modules.NetAddress) ([]headerChain, error) {
+	// Get the block history to send.
+	var history [32]types.BlockID
+	cs.mu.RLock()
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		history = blockHistory(tx)
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	chains := make([]headerChain, len(peers))
+	var wg sync.WaitGroup
+	for i := range peers {
+		chains[i].peer = peers[i]
+		wg.Add(1)
+		go func(chain *headerChain) {
+			defer wg.Done()
+			// Keep requesting headers while the peer has more available,
+			// continuing from the last header it sent.
+			h := history
+			for j := 0; j < maxHeaderRequests; j++ {
+				var headers []types.BlockHeader
+				var moreAvailable bool
+				err := cs.gateway.RPC(chain.peer, "SendHeaders", cs.managedReceiveHeaders(h, &headers, &moreAvailable))
+				if err != nil {
+					cs.log.Debugf("WARN: failed to get headers from %v: %v", chain.peer, err)
+					chain.headers = nil
+					chain.err = err
+					return
+				}
+				chain.headers = append(chain.headers, headers...)
+				if !moreAvailable || len(headers) == 0 {
+					return
+				}
+				h = continueHistory(history, headers[len(headers)-1].ID())
+			}
+		}(&chains[i])
+	}
+	wg.Wait()
+	return chains, nil
+}
+
+// managedHeaviestHeaderChain validates the provided header chains and returns
+// the heaviest valid one, along with every peer that reported the tip of that
+// chain and can therefore provide its blocks.
+func (cs *ConsensusSet) managedHeaviestHeaderChain(chains []headerChain) (*headerChain, []modules.NetAddress, error) {
+	var best *headerChain
+	for i := range chains {
+		if len(chains[i].headers) == 0 {
+			continue
+		}
+		tip, err := cs.managedValidateHeaderChain(chains[i].headers)
+		if err == modules.ErrNonExtendingBlock {
+			continue
+		} else if err != nil {
+			cs.log.Debugf("WARN: peer %v sent an invalid header chain: %v", chains[i].peer, err)
+			continue
+		}
+		chains[i].tip = tip
+		if best == nil || tip.Depth.Cmp(best.tip.Depth) < 0 {
+			best = &chains[i]
+		}
+	}
+	if best == nil {
+		return nil, nil, errNoHeaderChain
+	}
+
+	// Every peer that reported the tip of the heaviest chain can provide its
+	// blocks, since the header chains all start at the same block history.
+	bestTip := best.headers[len(best.headers)-1].ID()
+	var sources []modules.NetAddress
+	for _, chain := range chains {
+		if chain.tip == nil {
+			continue
+		}
+		for _, h := range chain.headers {
+			if h.ID() == bestTip {
+				sources = append(sources, chain.peer)
+				break
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil, errNoBlockSources
+	}
+	return best, sources, nil
+}
+
+// managedSyncHeadersFirst performs a single round of headers-first
+// synchronization with the provided peers. The header chains of all peers are
+// requested and validated, and the blocks of the heaviest valid chain are
+// downloaded in parallel from every peer that reported that chain. The
+// returned bool indicates whether the current path was extended.
+func (cs *ConsensusSet) managedSyncHeadersFirst(peers []modules.NetAddress) (chainExtended bool, err error) {
+	chains, err := cs.managedRequestHeaderChains(peers)
+	if err != nil {
+		return false, err
+	}
+	best, sources, err := cs.managedHeaviestHeaderChain(chains)
+	if err != nil {
+		return false, err
+	}
+
+	// The signatures of blocks that lead up to a checkpoint don't need to be
+	// verified. The blocks are removed from the checkpoint path at the end of
+	// the round, so that blocks that never arrive don't stay on it.
+	cs.mu.Lock()
+	checkpointPath := cs.addCheckpointPath(best.headers, best.tip.Height)
+	cs.mu.Unlock()
+	defer func() {
+		cs.mu.Lock()
+		cs.removeCheckpointPath(checkpointPath)
+		cs.mu.Unlock()
+	}()
+
+	// Download the blocks and add them to the consensus set in order.
+	chainExtended, err = cs.managedDownloadAndAcceptBlocks(best.headers, sources)
+	if err != nil {
+		return chainExtended, err
+	}
+
+	// Broadcast the new tip if the consensus set is already synced. During
+	// IBD, blocks are not broadcast.
+	cs.mu.RLock()
+	synced := cs.synced
+	cs.mu.RUnlock()
+	if synced && chainExtended {
+		cs.managedBroadcastBlock(cs.managedCurrentBlock())
+	}
+	return chainExtended, nil
+}
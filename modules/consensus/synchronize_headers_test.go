@@ -0,0 +1,430 @@
+package consensus
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/gateway"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// mockGatewayCountBlockRPCs is a gateway that counts the SendBlk RPCs made to
+// each peer. It does not call any RPCs upon connecting to a peer, so that
+// synchronization only happens when it is triggered by the test. SendBlk RPCs
+// to failPeer fail once failAfter RPCs were made to it.
+type mockGatewayCountBlockRPCs struct {
+	modules.Gateway
+	sendBlkCalls map[modules.NetAddress]int
+	failPeer     modules.NetAddress
+	failAfter    int
+	mu           sync.Mutex
+}
+
+func (g *mockGatewayCountBlockRPCs) RPC(addr modules.NetAddress, name string, fn modules.RPCFunc) error {
+	if name == "SendBlk" {
+		g.mu.Lock()
+		g.sendBlkCalls[addr]++
+		fail := addr == g.failPeer && g.sendBlkCalls[addr] > g.failAfter
+		g.mu.Unlock()
+		if fail {
+			return errors.New("mock SendBlk failure")
+		}
+	}
+	return g.Gateway.RPC(addr, name, fn)
+}
+
+func (g *mockGatewayCountBlockRPCs) RegisterConnectCall(string, modules.RPCFunc) {}
+func (g *mockGatewayCountBlockRPCs) UnregisterConnectCall(string)                {}
+
+// mineHeaders mines n blocks on the tester and returns their headers.
+func (cst *consensusSetTester) mineHeaders(n int) ([]types.BlockHeader, error) {
+	var headers []types.BlockHeader
+	for i := 0; i < n; i++ {
+		b, err := cst.miner.AddBlock()
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, b.Header())
+	}
+	return headers, nil
+}
+
+// TestValidateHeaderChain checks that managedValidateHeaderChain computes the
+// same depth and child target for a header chain as the consensus set that
+// accepted the corresponding blocks, and that it rejects invalid header
+// chains.
+func TestValidateHeaderChain(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	local, err := blankConsensusSetTester(t.Name()+"-local", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	remote, err := blankConsensusSetTester(t.Name()+"-remote", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	// Mine past the oak and ASIC hardfork heights so that every difficulty
+	// adjustment rule is exercised.
+	headers, err := remote.mineHeaders(int(types.OakHardforkFixBlock) + 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip, err := local.cs.managedValidateHeaderChain(headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteTip := remote.cs.dbCurrentProcessedBlock()
+	if tip.Height != remoteTip.Height {
+		t.Fatalf("expected height %v, got %v", remoteTip.Height, tip.Height)
+	}
+	if tip.Depth != remoteTip.Depth {
+		t.Fatal("header chain depth does not match the depth of the blocks")
+	}
+	if tip.ChildTarget != remoteTip.ChildTarget {
+		t.Fatal("header chain child target does not match the child target of the blocks")
+	}
+
+	// A header chain with a gap is rejected.
+	gapped := append(append([]types.BlockHeader(nil), headers[:5]...), headers[6:]...)
+	_, err = local.cs.managedValidateHeaderChain(gapped)
+	if err != errNonLinearChain {
+		t.Fatalf("expected %v, got %v", errNonLinearChain, err)
+	}
+
+	// A header chain with an invalid header is rejected.
+	tampered := append([]types.BlockHeader(nil), headers...)
+	tampered[len(tampered)-1].Timestamp = 0
+	_, err = local.cs.managedValidateHeaderChain(tampered)
+	if err != errEarlyTimestamp && err != modules.ErrBlockUnsolved {
+		t.Fatalf("expected invalid header error, got %v", err)
+	}
+
+	// A header chain that does not extend a known block is rejected.
+	_, err = local.cs.managedValidateHeaderChain(headers[1:])
+	if err != errOrphan {
+		t.Fatalf("expected %v, got %v", errOrphan, err)
+	}
+
+	// A header chain that is not heavier than the current path is rejected.
+	_, err = remote.cs.managedValidateHeaderChain(headers)
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrNonExtendingBlock, err)
+	}
+}
+
+// TestRPCSendHeaders checks that the SendHeaders RPC sends the headers that the
+// caller is missing in batches of maxCatchUpHeaders.
+func TestRPCSendHeaders(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, "local", modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	mg := &mockGatewayCountBlockRPCs{
+		Gateway:      g,
+		sendBlkCalls: make(map[modules.NetAddress]int),
+	}
+	local, err := New(mg, false, filepath.Join(testdir, "local", modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	remote, err := blankConsensusSetTester(t.Name()+"-remote", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	headers, err := remote.mineHeaders(maxCatchUpHeaders + 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mg.Connect(remote.gateway.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Request the headers using the genesis block as history.
+	var history [32]types.BlockID
+	history[31] = types.GenesisID
+	var received []types.BlockHeader
+	var moreAvailable bool
+	err = mg.RPC(remote.gateway.Address(), "SendHeaders", local.managedReceiveHeaders(history, &received, &moreAvailable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != maxCatchUpHeaders || !moreAvailable {
+		t.Fatalf("expected %v headers and more available, got %v headers and %v", maxCatchUpHeaders, len(received), moreAvailable)
+	}
+	for i := range received {
+		if received[i] != headers[i] {
+			t.Fatal("received headers do not match the remote chain")
+		}
+	}
+
+	// Request the remaining headers.
+	history[0] = received[len(received)-1].ID()
+	received = nil
+	err = mg.RPC(remote.gateway.Address(), "SendHeaders", local.managedReceiveHeaders(history, &received, &moreAvailable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 5 || moreAvailable {
+		t.Fatalf("expected 5 headers and no more available, got %v headers and %v", len(received), moreAvailable)
+	}
+	if received[4] != headers[len(headers)-1] {
+		t.Fatal("received headers do not match the remote chain")
+	}
+}
+
+// TestHeadersFirstSync checks that a consensus set synchronizes to the
+// heaviest chain of its peers headers-first, downloading the blocks from
+// multiple peers.
+func TestHeadersFirstSync(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, "local", modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	mg := &mockGatewayCountBlockRPCs{
+		Gateway:      g,
+		sendBlkCalls: make(map[modules.NetAddress]int),
+	}
+	local, err := New(mg, false, filepath.Join(testdir, "local", modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	// Create three peers that share the heavy chain.
+	var heavy []*consensusSetTester
+	for i := 0; i < 3; i++ {
+		cst, err := blankConsensusSetTester(t.Name()+"-heavy"+strconv.Itoa(i), modules.ProdDependencies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cst.Close()
+		heavy = append(heavy, cst)
+	}
+	if _, err := heavy[0].mineHeaders(maxCatchUpHeaders + 10); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := heavy[0].cs.currentPathBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cst := range heavy[1:] {
+		if _, err := cst.cs.managedAcceptBlocks(blocks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create a peer with a lighter chain.
+	light, err := blankConsensusSetTester(t.Name()+"-light", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer light.Close()
+	if _, err := light.mineHeaders(10); err != nil {
+		t.Fatal(err)
+	}
+
+	peers := []modules.NetAddress{light.gateway.Address()}
+	for _, cst := range heavy {
+		peers = append(peers, cst.gateway.Address())
+	}
+	for _, addr := range peers {
+		if err := mg.Connect(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Synchronize until the current path stops being extended.
+	for i := 0; ; i++ {
+		if i > 10 {
+			t.Fatal("headers-first synchronization did not finish")
+		}
+		extended, err := local.managedSyncHeadersFirst(peers)
+		if err != nil && err != errNoHeaderChain {
+			t.Fatal(err)
+		}
+		if !extended {
+			break
+		}
+	}
+	if local.CurrentBlock().ID() != heavy[0].cs.CurrentBlock().ID() {
+		t.Fatal("consensus set did not synchronize to the heaviest chain")
+	}
+
+	// The blocks were downloaded from more than one of the heavy peers, and
+	// none were downloaded from the light peer.
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.sendBlkCalls[light.gateway.Address()] != 0 {
+		t.Fatal("blocks were downloaded from the peer with the lighter chain")
+	}
+	sources := 0
+	for _, cst := range heavy {
+		if mg.sendBlkCalls[cst.gateway.Address()] > 0 {
+			sources++
+		}
+	}
+	if sources < 2 {
+		t.Fatalf("blocks were downloaded from %v peers, expected at least 2", sources)
+	}
+}
+
+// TestHeadersFirstSyncPeerFailure checks that the batches of a peer that fails
+// to provide its blocks are downloaded from the other peers, and that more
+// headers are requested from peers that have more available.
+func TestHeadersFirstSyncPeerFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, "local", modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	mg := &mockGatewayCountBlockRPCs{
+		Gateway:      g,
+		sendBlkCalls: make(map[modules.NetAddress]int),
+		failAfter:    1,
+	}
+	local, err := New(mg, false, filepath.Join(testdir, "local", modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	// Create two peers that share a chain that is longer than the headers
+	// sent in response to a single SendHeaders RPC.
+	var peers []*consensusSetTester
+	for i := 0; i < 2; i++ {
+		cst, err := blankConsensusSetTester(t.Name()+strconv.Itoa(i), modules.ProdDependencies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cst.Close()
+		peers = append(peers, cst)
+	}
+	if _, err := peers[0].mineHeaders(2*maxCatchUpHeaders + 5); err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := peers[0].cs.currentPathBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peers[1].cs.managedAcceptBlocks(blocks); err != nil {
+		t.Fatal(err)
+	}
+	var addrs []modules.NetAddress
+	for _, cst := range peers {
+		if err := mg.Connect(cst.gateway.Address()); err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, cst.gateway.Address())
+	}
+	mg.mu.Lock()
+	mg.failPeer = addrs[0]
+	mg.mu.Unlock()
+
+	// A single round of synchronization should download all headers and all
+	// blocks, even though the first peer fails after its first block.
+	extended, err := local.managedSyncHeadersFirst(addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extended || local.CurrentBlock().ID() != peers[0].cs.CurrentBlock().ID() {
+		t.Fatal("consensus set did not synchronize in a single round")
+	}
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	if mg.sendBlkCalls[addrs[0]] <= mg.failAfter {
+		t.Fatal("first peer never failed to provide a block")
+	}
+}
+
+// TestHeadersFirstSyncCheckpointPath checks that the blocks of a header chain
+// are removed from the checkpoint path at the end of a headers-first round,
+// even if the blocks never arrive.
+func TestHeadersFirstSyncCheckpointPath(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, "local", modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	mg := &mockGatewayCountBlockRPCs{
+		Gateway:      g,
+		sendBlkCalls: make(map[modules.NetAddress]int),
+	}
+	local, err := New(mg, false, filepath.Join(testdir, "local", modules.ConsensusDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	// Create a peer whose chain leads up to a checkpoint but which fails to
+	// provide any blocks.
+	peer, err := blankConsensusSetTester(t.Name()+"-peer", modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	headers, err := peer.mineHeaders(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local.setCheckpoints(map[types.BlockHeight]types.BlockID{
+		0:                    types.GenesisID,
+		types.BlockHeight(5): headers[len(headers)-1].ID(),
+	})
+	if err := mg.Connect(peer.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+	mg.mu.Lock()
+	mg.failPeer = peer.gateway.Address()
+	mg.mu.Unlock()
+
+	// The round doesn't extend the chain, and the checkpoint path is empty
+	// afterwards.
+	extended, err := local.managedSyncHeadersFirst([]modules.NetAddress{peer.gateway.Address()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extended || local.Height() != 0 {
+		t.Fatal("consensus set was extended without any blocks")
+	}
+	local.mu.Lock()
+	defer local.mu.Unlock()
+	if len(local.checkpointPath) != 0 {
+		t.Fatalf("checkpoint path contains %v blocks after the round", len(local.checkpointPath))
+	}
+}
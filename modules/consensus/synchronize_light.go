@@ -0,0 +1,507 @@
+package consensus
+
+// synchronize_light.go implements the synchronization of a light consensus
+// set. Full nodes serve the SendFilteredBlocks RPC, which responds with the
+// headers and miner payouts of the requested blocks and the transactions that
+// are relevant to a set of addresses. Every miner payout and transaction is
+// accompanied by a Merkle proof that it is part of the block, so a light
+// consensus set only needs to trust its peers not to omit transactions.
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errFilteredBlocksMissing     = errors.New("peer did not send all of the requested filtered blocks")
+	errFilteredBlocksRequestSize = errors.New("filtered blocks request exceeds the size limits")
+	errInvalidFilteredBlock      = errors.New("filtered block contains an invalid Merkle proof")
+	errNoSyncPeers               = errors.New("no peer responded to the header request")
+
+	// maxFilteredBlocks is the maximum number of blocks that can be requested
+	// in a single SendFilteredBlocks RPC.
+	maxFilteredBlocks = build.Select(build.Var{
+		Standard: 100,
+		Dev:      50,
+		Testing:  10,
+	}).(int)
+
+	// maxFilterAddresses is the maximum number of addresses that can be sent
+	// in a single SendFilteredBlocks RPC. It is large enough for the lookahead
+	// of a wallet with tens of thousands of used addresses, while limiting
+	// the memory and bandwidth a peer can make a full node spend on a single
+	// request.
+	maxFilterAddresses = build.Select(build.Var{
+		Standard: 100000,
+		Dev:      20000,
+		Testing:  2000,
+	}).(int)
+
+	// sendFilteredBlocksTimeout is the timeout for the SendFilteredBlocks RPC.
+	sendFilteredBlocksTimeout = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Dev:      2 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// lightSyncInterval is the time that a light consensus set waits between
+	// synchronization rounds once it has caught up with its peers.
+	lightSyncInterval = build.Select(build.Var{
+		Standard: 30 * time.Second,
+		Dev:      10 * time.Second,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
+type (
+	// filteredBlocksRequest is the request of the SendFilteredBlocks RPC.
+	filteredBlocksRequest struct {
+		IDs       []types.BlockID
+		Addresses []types.UnlockHash
+	}
+
+	// filteredBlock is a block that only contains the transactions that are
+	// relevant to a set of addresses. The miner payouts and transactions are
+	// proven to be part of the block with Merkle proofs against the Merkle
+	// root of the header.
+	filteredBlock struct {
+		Header          types.BlockHeader
+		MinerPayouts    []types.SiacoinOutput
+		PayoutProofs    [][]crypto.Hash
+		NumTransactions uint64
+		Transactions    []filteredTransaction
+	}
+
+	// filteredTransaction is a transaction of a filtered block, along with its
+	// index in the block and its Merkle proof.
+	filteredTransaction struct {
+		Index       uint64
+		Transaction types.Transaction
+		Proof       []crypto.Hash
+	}
+)
+
+// blockLeaves returns the leaves of the Merkle tree of a block, which are the
+// encoded miner payouts followed by the encoded transactions.
+func blockLeaves(b types.Block) [][]byte {
+	var leaves [][]byte
+	var buf bytes.Buffer
+	e := encoding.NewEncoder(&buf)
+	for _, payout := range b.MinerPayouts {
+		payout.MarshalSia(e)
+		leaves = append(leaves, append([]byte(nil), buf.Bytes()...))
+		buf.Reset()
+	}
+	for _, txn := range b.Transactions {
+		txn.MarshalSia(e)
+		leaves = append(leaves, append([]byte(nil), buf.Bytes()...))
+		buf.Reset()
+	}
+	return leaves
+}
+
+// blockMerkleTree caches every node of the Merkle tree of a block, so that the
+// proofs of multiple leaves can be created without rebuilding the tree. The
+// tree has the same structure as the trees of the crypto package: the left
+// subtree of a node with n leaves is the largest perfect subtree with fewer
+// than n leaves.
+type blockMerkleTree struct {
+	numLeaves uint64
+	nodes     map[[2]uint64]crypto.Hash // Maps {start, numLeaves} of a subtree to its root.
+}
+
+// newBlockMerkleTree builds the Merkle tree of the provided leaves.
+func newBlockMerkleTree(leaves [][]byte) *blockMerkleTree {
+	t := &blockMerkleTree{
+		numLeaves: uint64(len(leaves)),
+		nodes:     make(map[[2]uint64]crypto.Hash, 2*len(leaves)),
+	}
+	if len(leaves) > 0 {
+		t.build(leaves, 0, t.numLeaves)
+	}
+	return t
+}
+
+// leftSubtreeSize returns the number of leaves in the left subtree of a node
+// with n leaves, which is the largest power of 2 smaller than n.
+func leftSubtreeSize(n uint64) uint64 {
+	k := uint64(1)
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// build computes the root of the subtree with n leaves starting at the leaf
+// with index start, caching the roots of all of its subtrees.
+func (t *blockMerkleTree) build(leaves [][]byte, start, n uint64) crypto.Hash {
+	var root crypto.Hash
+	if n == 1 {
+		root = crypto.HashBytes(append([]byte{0}, leaves[start]...))
+	} else {
+		k := leftSubtreeSize(n)
+		left := t.build(leaves, start, k)
+		right := t.build(leaves, start+k, n-k)
+		root = crypto.HashBytes(append(append([]byte{1}, left[:]...), right[:]...))
+	}
+	t.nodes[[2]uint64{start, n}] = root
+	return root
+}
+
+// proof returns the Merkle proof of the leaf at index i, excluding the leaf
+// itself. The proof contains the siblings of the path from the leaf to the
+// root, starting at the leaf.
+func (t *blockMerkleTree) proof(i uint64) []crypto.Hash {
+	var proof []crypto.Hash
+	start, n := uint64(0), t.numLeaves
+	for n > 1 {
+		k := leftSubtreeSize(n)
+		if i < start+k {
+			proof = append(proof, t.nodes[[2]uint64{start + k, n - k}])
+			n = k
+		} else {
+			proof = append(proof, t.nodes[[2]uint64{start, k}])
+			start += k
+			n -= k
+		}
+	}
+	for l, r := 0, len(proof)-1; l < r; l, r = l+1, r-1 {
+		proof[l], proof[r] = proof[r], proof[l]
+	}
+	return proof
+}
+
+// relevantTransaction returns true if any of the inputs or outputs of a
+// transaction belong to one of the provided addresses.
+func relevantTransaction(txn types.Transaction, addrs map[types.UnlockHash]struct{}) bool {
+	relevant := func(uh types.UnlockHash) bool {
+		_, exists := addrs[uh]
+		return exists
+	}
+	for _, sci := range txn.SiacoinInputs {
+		if relevant(sci.UnlockConditions.UnlockHash()) {
+			return true
+		}
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		if relevant(sco.UnlockHash) {
+			return true
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		if relevant(sfi.UnlockConditions.UnlockHash()) {
+			return true
+		}
+	}
+	for _, sfo := range txn.SiafundOutputs {
+		if relevant(sfo.UnlockHash) {
+			return true
+		}
+	}
+	for _, fc := range txn.FileContracts {
+		for _, sco := range append(fc.ValidProofOutputs, fc.MissedProofOutputs...) {
+			if relevant(sco.UnlockHash) {
+				return true
+			}
+		}
+	}
+	for _, fcr := range txn.FileContractRevisions {
+		for _, sco := range append(fcr.NewValidProofOutputs, fcr.NewMissedProofOutputs...) {
+			if relevant(sco.UnlockHash) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newFilteredBlock creates the filtered block of a block for the provided
+// addresses.
+func newFilteredBlock(b types.Block, addrs map[types.UnlockHash]struct{}) filteredBlock {
+	tree := newBlockMerkleTree(blockLeaves(b))
+	fb := filteredBlock{
+		Header:          b.Header(),
+		MinerPayouts:    b.MinerPayouts,
+		NumTransactions: uint64(len(b.Transactions)),
+	}
+	for i := range b.MinerPayouts {
+		fb.PayoutProofs = append(fb.PayoutProofs, tree.proof(uint64(i)))
+	}
+	for i, txn := range b.Transactions {
+		if !relevantTransaction(txn, addrs) {
+			continue
+		}
+		fb.Transactions = append(fb.Transactions, filteredTransaction{
+			Index:       uint64(i),
+			Transaction: txn,
+			Proof:       tree.proof(uint64(len(b.MinerPayouts) + i)),
+		})
+	}
+	return fb
+}
+
+// block verifies that the filtered block belongs to the block with the
+// provided id and returns a block containing its miner payouts and
+// transactions. The id of the returned block does not match the provided id
+// unless every transaction of the block is relevant.
+func (fb filteredBlock) block(id types.BlockID) (types.Block, error) {
+	if fb.Header.ID() != id {
+		return types.Block{}, errBlockMismatch
+	}
+	if len(fb.PayoutProofs) != len(fb.MinerPayouts) {
+		return types.Block{}, errInvalidFilteredBlock
+	}
+	numLeaves := uint64(len(fb.MinerPayouts)) + fb.NumTransactions
+	for i, payout := range fb.MinerPayouts {
+		if !crypto.VerifySegment(encoding.Marshal(payout), fb.PayoutProofs[i], numLeaves, uint64(i), fb.Header.MerkleRoot) {
+			return types.Block{}, errInvalidFilteredBlock
+		}
+	}
+	b := types.Block{
+		ParentID:     fb.Header.ParentID,
+		Nonce:        fb.Header.Nonce,
+		Timestamp:    fb.Header.Timestamp,
+		MinerPayouts: fb.MinerPayouts,
+	}
+	for i, ft := range fb.Transactions {
+		// The transactions must be sent in the order of the block, so that
+		// transactions depending on each other are applied correctly.
+		if ft.Index >= fb.NumTransactions || (i > 0 && ft.Index <= fb.Transactions[i-1].Index) {
+			return types.Block{}, errInvalidFilteredBlock
+		}
+		leafIndex := uint64(len(fb.MinerPayouts)) + ft.Index
+		if !crypto.VerifySegment(encoding.Marshal(ft.Transaction), ft.Proof, numLeaves, leafIndex, fb.Header.MerkleRoot) {
+			return types.Block{}, errInvalidFilteredBlock
+		}
+		b.Transactions = append(b.Transactions, ft.Transaction)
+	}
+	return b, nil
+}
+
+// rpcSendFilteredBlocks is the receiving end of the SendFilteredBlocks RPC. It
+// reads a list of block ids and addresses, and responds with the filtered
+// blocks of the requested blocks. The response stops at the first block that
+// is unknown to the consensus set.
+func (cs *ConsensusSet) rpcSendFilteredBlocks(conn modules.PeerConn) error {
+	err := conn.SetDeadline(time.Now().Add(sendFilteredBlocksTimeout))
+	if err != nil {
+		return err
+	}
+	finishedChan := make(chan struct{})
+	defer close(finishedChan)
+	go func() {
+		select {
+		case <-cs.tg.StopChan():
+		case <-finishedChan:
+		}
+		conn.Close()
+	}()
+	err = cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	// Read the request.
+	var req filteredBlocksRequest
+	maxLen := 16 + uint64(maxFilteredBlocks)*crypto.HashSize + uint64(maxFilterAddresses)*crypto.HashSize
+	err = encoding.ReadObject(conn, &req, maxLen)
+	if err != nil {
+		return err
+	}
+	if len(req.IDs) > maxFilteredBlocks || len(req.Addresses) > maxFilterAddresses {
+		return errFilteredBlocksRequestSize
+	}
+	addrs := make(map[types.UnlockHash]struct{}, len(req.Addresses))
+	for _, uh := range req.Addresses {
+		addrs[uh] = struct{}{}
+	}
+
+	// Filter the requested blocks.
+	var fbs []filteredBlock
+	cs.mu.RLock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		for _, id := range req.IDs {
+			pb, err := getBlockMap(tx, id)
+			if err == errNilItem {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			fbs = append(fbs, newFilteredBlock(pb.Block, addrs))
+		}
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, fbs)
+}
+
+// managedReceiveFilteredBlocks returns an RPCFunc that is the calling end of
+// the SendFilteredBlocks RPC. The filtered blocks are verified against the
+// requested ids, and the resulting blocks are written to the provided pointer.
+func (cs *ConsensusSet) managedReceiveFilteredBlocks(ids []types.BlockID, addrs []types.UnlockHash, blocks *[]types.Block) modules.RPCFunc {
+	return func(conn modules.PeerConn) error {
+		err := conn.SetDeadline(time.Now().Add(sendFilteredBlocksTimeout))
+		if err != nil {
+			return err
+		}
+		req := filteredBlocksRequest{
+			IDs:       ids,
+			Addresses: addrs,
+		}
+		if err := encoding.WriteObject(conn, req); err != nil {
+			return err
+		}
+		var fbs []filteredBlock
+		if err := encoding.ReadObject(conn, &fbs, uint64(len(ids))*2*types.BlockSizeLimit+8); err != nil {
+			return err
+		}
+		if len(fbs) != len(ids) {
+			return errFilteredBlocksMissing
+		}
+		received := make([]types.Block, len(fbs))
+		for i := range fbs {
+			received[i], err = fbs[i].block(ids[i])
+			if err != nil {
+				return err
+			}
+		}
+		*blocks = received
+		return nil
+	}
+}
+
+// managedDownloadFilteredBlocks downloads the filtered blocks with the
+// provided ids for the provided addresses, trying each of the sources in turn
+// until one of them responds with valid filtered blocks.
+func (cs *ConsensusSet) managedDownloadFilteredBlocks(ids []types.BlockID, sources []modules.NetAddress, addrs []types.UnlockHash) ([]types.Block, error) {
+	if len(addrs) > maxFilterAddresses {
+		return nil, errFilteredBlocksRequestSize
+	}
+	for _, peer := range sources {
+		var blocks []types.Block
+		err := cs.gateway.RPC(peer, "SendFilteredBlocks", cs.managedReceiveFilteredBlocks(ids, addrs, &blocks))
+		if err == nil {
+			return blocks, nil
+		}
+		cs.log.Debugf("WARN: failed to download filtered blocks from %v: %v", peer, err)
+	}
+	return nil, errNoBlockSources
+}
+
+// managedOutboundPeers returns the addresses of the outbound peers of the
+// gateway.
+func (cs *ConsensusSet) managedOutboundPeers() []modules.NetAddress {
+	var peers []modules.NetAddress
+	for _, p := range cs.gateway.Peers() {
+		if !p.Inbound {
+			peers = append(peers, p.NetAddress)
+		}
+	}
+	return peers
+}
+
+// managedSyncLight performs a single round of synchronization of a light
+// consensus set with the provided peers. The header chains of all peers are
+// requested and validated, and the filtered blocks of the heaviest valid chain
+// are downloaded and added to the consensus set in batches. The returned bool
+// indicates whether the current path was extended.
+func (cs *ConsensusSet) managedSyncLight(peers []modules.NetAddress) (chainExtended bool, err error) {
+	cs.syncMu.Lock()
+	defer cs.syncMu.Unlock()
+
+	chains, err := cs.managedRequestHeaderChains(peers)
+	if err != nil {
+		return false, err
+	}
+	responded := false
+	for _, chain := range chains {
+		responded = responded || chain.err == nil
+	}
+	if !responded {
+		return false, errNoSyncPeers
+	}
+	best, sources, err := cs.managedHeaviestHeaderChain(chains)
+	if err != nil {
+		return false, err
+	}
+	addrs, err := cs.managedLightAddresses()
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(best.headers); i += maxFilteredBlocks {
+		end := i + maxFilteredBlocks
+		if end > len(best.headers) {
+			end = len(best.headers)
+		}
+		headers := best.headers[i:end]
+		ids := make([]types.BlockID, len(headers))
+		for j := range headers {
+			ids[j] = headers[j].ID()
+		}
+		blocks, err := cs.managedDownloadFilteredBlocks(ids, sources, addrs)
+		if err != nil {
+			return chainExtended, err
+		}
+		extended, err := cs.managedAcceptFilteredBlocks(headers, blocks)
+		if extended {
+			chainExtended = true
+		}
+		if err != nil && err != modules.ErrNonExtendingBlock {
+			return chainExtended, err
+		}
+	}
+	return chainExtended, nil
+}
+
+// threadedSynchronizeLight keeps a light consensus set synchronized with its
+// outbound peers. The consensus set is marked as synced after the first round
+// in which a peer responded and the current path was not extended.
+func (cs *ConsensusSet) threadedSynchronizeLight() {
+	if err := cs.tg.Add(); err != nil {
+		return
+	}
+	defer cs.tg.Done()
+
+	for {
+		select {
+		case <-cs.tg.StopChan():
+			return
+		default:
+		}
+
+		if peers := cs.managedOutboundPeers(); len(peers) > 0 {
+			extended, err := cs.managedSyncLight(peers)
+			if extended {
+				// There may be more blocks to download.
+				continue
+			}
+			if err == nil || err == errNoHeaderChain {
+				cs.mu.Lock()
+				cs.synced = true
+				cs.mu.Unlock()
+			} else {
+				cs.log.Debugln("WARN: light synchronization failed:", err)
+			}
+		}
+
+		select {
+		case <-cs.tg.StopChan():
+			return
+		case <-time.After(lightSyncInterval):
+		}
+	}
+}
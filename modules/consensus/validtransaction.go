@@ -291,10 +291,24 @@ func validTransaction(tx *bolt.Tx, t types.Transaction) error {
 	if err != nil {
 		return err
 	}
+	return validTransactionState(tx, t)
+}
+
+// validTransactionWithoutSignatures performs the same checks as
+// validTransaction, except for the verification of the signatures. It should
+// only be used for transactions in blocks that are protected by a checkpoint.
+func validTransactionWithoutSignatures(tx *bolt.Tx, t types.Transaction) error {
+	err := t.StandaloneValidWithoutSignatures(blockHeight(tx))
+	if err != nil {
+		return err
+	}
+	return validTransactionState(tx, t)
+}
 
-	// Check that each portion of the transaction is legal given the current
-	// consensus set.
-	err = validSiacoins(tx, t)
+// validTransactionState checks that each portion of the transaction is legal
+// given the current consensus set.
+func validTransactionState(tx *bolt.Tx, t types.Transaction) error {
+	err := validSiacoins(tx, t)
 	if err != nil {
 		return err
 	}
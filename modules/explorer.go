@@ -11,6 +11,18 @@ const (
 )
 
 type (
+	// AddressBalance reports the spendable siacoins and siafunds owned by an
+	// unlock hash, along with the number of unspent outputs holding them.
+	// Immature miner payouts and file contract outputs are not counted until
+	// they mature.
+	AddressBalance struct {
+		UnlockHash         types.UnlockHash `json:"unlockhash"`
+		SiacoinBalance     types.Currency   `json:"siacoinbalance"`
+		SiafundBalance     types.Currency   `json:"siafundbalance"`
+		SiacoinOutputCount uint64           `json:"siacoinoutputcount"`
+		SiafundOutputCount uint64           `json:"siafundoutputcount"`
+	}
+
 	// BlockFacts returns a bunch of statistics about the consensus set as they
 	// were at a specific block.
 	BlockFacts struct {
@@ -45,6 +57,50 @@ type (
 		TotalRevisionVolume types.Currency `json:"totalrevisionvolume"`
 	}
 
+	// MarketFacts contains statistics about the storage market as they were
+	// at a specific block. All counts and totals are cumulative from the
+	// genesis block, except for the active host and active contract fields
+	// which describe the hosts and contracts that were active at the block.
+	MarketFacts struct {
+		BlockID   types.BlockID     `json:"blockid"`
+		Height    types.BlockHeight `json:"height"`
+		Timestamp types.Timestamp   `json:"timestamp"`
+
+		// Host announcements.
+		HostAnnouncementCount uint64 `json:"hostannouncementcount"`
+		ActiveHostCount       uint64 `json:"activehostcount"`
+
+		// File contracts and their outcomes.
+		ContractCount           uint64            `json:"contractcount"`
+		TotalContractPayout     types.Currency    `json:"totalcontractpayout"`
+		AverageContractDuration types.BlockHeight `json:"averagecontractduration"`
+		StorageProofCount       uint64            `json:"storageproofcount"`
+		MissedProofCount        uint64            `json:"missedproofcount"`
+
+		// Contracts that are currently open.
+		ActiveContractCount uint64         `json:"activecontractcount"`
+		ActiveContractCost  types.Currency `json:"activecontractcost"`
+		ActiveContractSize  types.Currency `json:"activecontractsize"`
+	}
+
+	// RichListEntry is a single unlock hash and its balance in a rich list.
+	RichListEntry struct {
+		UnlockHash types.UnlockHash `json:"unlockhash"`
+		Balance    types.Currency   `json:"balance"`
+	}
+
+	// UnspentSiacoinOutput is a spendable siacoin output and its id.
+	UnspentSiacoinOutput struct {
+		ID types.SiacoinOutputID `json:"id"`
+		types.SiacoinOutput
+	}
+
+	// UnspentSiafundOutput is a spendable siafund output and its id.
+	UnspentSiafundOutput struct {
+		ID types.SiafundOutputID `json:"id"`
+		types.SiafundOutput
+	}
+
 	// Explorer tracks the blockchain and provides tools for gathering
 	// statistics and finding objects or patterns within the blockchain.
 	Explorer interface {
@@ -60,6 +116,14 @@ type (
 		// in the explorer's database.
 		LatestBlockFacts() BlockFacts
 
+		// MarketFacts returns a set of storage market statistics as they
+		// appeared at a given block.
+		MarketFacts(types.BlockHeight) (MarketFacts, bool)
+
+		// LatestMarketFacts returns the storage market statistics of the last
+		// block in the explorer's database.
+		LatestMarketFacts() MarketFacts
+
 		// Transaction returns the block that contains the input transaction
 		// id. The transaction itself is either the block (indicating the miner
 		// payouts are somehow involved), or it is a transaction inside of the
@@ -71,6 +135,26 @@ type (
 		// provided unlock hash.
 		UnlockHash(types.UnlockHash) []types.TransactionID
 
+		// AddressBalance returns the spendable siacoin and siafund balance of
+		// the provided unlock hash.
+		AddressBalance(types.UnlockHash) AddressBalance
+
+		// AddressSiacoinOutputs returns the unspent siacoin outputs owned by
+		// the provided unlock hash.
+		AddressSiacoinOutputs(types.UnlockHash) []UnspentSiacoinOutput
+
+		// AddressSiafundOutputs returns the unspent siafund outputs owned by
+		// the provided unlock hash.
+		AddressSiafundOutputs(types.UnlockHash) []UnspentSiafundOutput
+
+		// SiacoinRichList returns up to limit unlock hashes ordered by their
+		// siacoin balance, largest first, skipping the first offset entries.
+		SiacoinRichList(offset, limit uint64) []RichListEntry
+
+		// SiafundRichList returns up to limit unlock hashes ordered by their
+		// siafund balance, largest first, skipping the first offset entries.
+		SiafundRichList(offset, limit uint64) []RichListEntry
+
 		// SiacoinOutput will return the siacoin output associated with the
 		// input id.
 		SiacoinOutput(types.SiacoinOutputID) (types.SiacoinOutput, bool)
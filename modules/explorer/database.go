@@ -2,35 +2,49 @@ package explorer
 
 import (
 	"errors"
+	"math/big"
 
 	bolt "github.com/coreos/bbolt"
 
 	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
 var (
 	// database buckets
+	bucketAddressBalances       = []byte("AddressBalances")
+	bucketAddressSiacoinOutputs = []byte("AddressSiacoinOutputs")
+	bucketAddressSiafundOutputs = []byte("AddressSiafundOutputs")
 	bucketBlockFacts            = []byte("BlockFacts")
 	bucketBlockIDs              = []byte("BlockIDs")
 	bucketBlocksDifficulty      = []byte("BlocksDifficulty")
 	bucketBlockTargets          = []byte("BlockTargets")
 	bucketFileContractHistories = []byte("FileContractHistories")
+	bucketContractHosts         = []byte("ContractHosts")
 	bucketFileContractIDs       = []byte("FileContractIDs")
+	bucketHostActivity          = []byte("HostActivity")
+	// bucketHostActivityExpirations maps a height to the hosts whose
+	// activity ends at that height
+	bucketHostActivityExpirations = []byte("HostActivityExpirations")
 	// bucketInternal is used to store values internal to the explorer
 	bucketInternal         = []byte("Internal")
+	bucketMarketFacts      = []byte("MarketFacts")
 	bucketSiacoinOutputIDs = []byte("SiacoinOutputIDs")
 	bucketSiacoinOutputs   = []byte("SiacoinOutputs")
+	bucketSiacoinRichList  = []byte("SiacoinRichList")
 	bucketSiafundOutputIDs = []byte("SiafundOutputIDs")
 	bucketSiafundOutputs   = []byte("SiafundOutputs")
+	bucketSiafundRichList  = []byte("SiafundRichList")
 	bucketTransactionIDs   = []byte("TransactionIDs")
 	bucketUnlockHashes     = []byte("UnlockHashes")
 
 	errNotExist = errors.New("entry does not exist")
 
 	// keys for bucketInternal
-	internalBlockHeight  = []byte("BlockHeight")
-	internalRecentChange = []byte("RecentChange")
+	internalActiveHostCount = []byte("ActiveHostCount")
+	internalBlockHeight     = []byte("BlockHeight")
+	internalRecentChange    = []byte("RecentChange")
 )
 
 // These functions all return a 'func(*bolt.Tx) error', which, allows them to
@@ -89,6 +103,60 @@ func dbGetTransactionIDSet(bucket []byte, key interface{}, ids *[]types.Transact
 	}
 }
 
+// richListKey returns the key under which an unlock hash is stored in one of
+// the rich list buckets. The balance is encoded as a fixed width big-endian
+// integer so that bolt's byte ordering matches the ordering of the balances.
+func richListKey(balance types.Currency, uh types.UnlockHash) []byte {
+	b := balance.Big().Bytes()
+	if len(b) > richListBalanceWidth {
+		panic("balance is too large to be stored in the rich list")
+	}
+	key := make([]byte, richListBalanceWidth+len(uh))
+	copy(key[richListBalanceWidth-len(b):], b)
+	copy(key[richListBalanceWidth:], uh[:])
+	return key
+}
+
+// dbGetAddressBalance returns a 'func(*bolt.Tx) error' that decodes the
+// balance of an unlock hash. If the unlock hash does not own any unspent
+// outputs, the balance is left empty.
+func dbGetAddressBalance(uh types.UnlockHash, ab *addressBalance) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		err := dbGetAndDecode(bucketAddressBalances, uh, ab)(tx)
+		if err == errNotExist {
+			*ab = addressBalance{}
+			return nil
+		}
+		return err
+	}
+}
+
+// dbGetRichList returns a 'func(*bolt.Tx) error' that walks a rich list
+// bucket from the largest balance to the smallest, skipping the first offset
+// entries and decoding at most limit entries.
+func dbGetRichList(bucket []byte, offset, limit uint64, entries *[]modules.RichListEntry) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		var list []modules.RichListEntry
+		c := tx.Bucket(bucket).Cursor()
+		var skipped uint64
+		for k, _ := c.Last(); k != nil && uint64(len(list)) < limit; k, _ = c.Prev() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if len(k) != richListBalanceWidth+len(types.UnlockHash{}) {
+				return errors.New("rich list contains a malformed key")
+			}
+			var entry modules.RichListEntry
+			entry.Balance = types.NewCurrency(new(big.Int).SetBytes(k[:richListBalanceWidth]))
+			copy(entry.UnlockHash[:], k[richListBalanceWidth:])
+			list = append(list, entry)
+		}
+		*entries = list
+		return nil
+	}
+}
+
 // dbGetBlockFacts returns a 'func(*bolt.Tx) error' that decodes
 // the block facts for `height` into blockfacts
 func (e *Explorer) dbGetBlockFacts(height types.BlockHeight, bf *blockFacts) func(*bolt.Tx) error {
@@ -101,6 +169,18 @@ func (e *Explorer) dbGetBlockFacts(height types.BlockHeight, bf *blockFacts) fun
 	}
 }
 
+// dbGetMarketFacts returns a 'func(*bolt.Tx) error' that decodes the block
+// facts and the market facts for `height`.
+func (e *Explorer) dbGetMarketFacts(height types.BlockHeight, bf *blockFacts, mf *marketFacts) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		err := e.dbGetBlockFacts(height, bf)(tx)
+		if err != nil {
+			return err
+		}
+		return dbGetAndDecode(bucketMarketFacts, bf.BlockID, mf)(tx)
+	}
+}
+
 // dbSetInternal sets the specified key of bucketInternal to the encoded value.
 func dbSetInternal(key []byte, val interface{}) func(*bolt.Tx) error {
 	return func(tx *bolt.Tx) error {
@@ -5,6 +5,7 @@ package explorer
 import (
 	"errors"
 
+	"gitlab.com/NebulousLabs/Sia/build"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/persist"
 	"gitlab.com/NebulousLabs/Sia/types"
@@ -14,13 +15,38 @@ const (
 	// hashrateEstimationBlocks is the number of blocks that are used to
 	// estimate the current hashrate.
 	hashrateEstimationBlocks = 200 // 33 hours
+
+	// richListBalanceWidth is the number of bytes used to encode a balance in
+	// a rich list key. 32 bytes comfortably exceeds the total supply of both
+	// siacoins and siafunds.
+	richListBalanceWidth = 32
 )
 
 var (
+	// activeHostWindow is the number of blocks for which a host announcement
+	// counts the host as active. Hosts rarely re-announce, so hosts with an
+	// open file contract are counted as active as well.
+	activeHostWindow = build.Select(build.Var{
+		Standard: types.BlockHeight(types.BlocksPerMonth),
+		Dev:      types.BlockHeight(100),
+		Testing:  types.BlockHeight(10),
+	}).(types.BlockHeight)
+
 	errNilCS = errors.New("explorer cannot use a nil consensus set")
 )
 
 type (
+	// addressBalance is the record kept for every unlock hash that owns at
+	// least one unspent output. Only outputs that are spendable are counted,
+	// which means that immature miner payouts and file contract outputs are
+	// not included until they mature.
+	addressBalance struct {
+		SiacoinBalance     types.Currency
+		SiafundBalance     types.Currency
+		SiacoinOutputCount uint64
+		SiafundOutputCount uint64
+	}
+
 	// fileContractHistory stores the original file contract and the chain of
 	// revisions that have affected a file contract through the life of the
 	// blockchain.
@@ -30,6 +56,17 @@ type (
 		StorageProof types.StorageProof
 	}
 
+	// contractHost links a file contract to the host that it was formed
+	// with. The host is only known once the contract has been revised, since
+	// the unlock conditions of the revision contain the host's key. Height is
+	// the height of the block that established the link and Expiration is the
+	// end of the contract's proof window.
+	contractHost struct {
+		Host       types.SiaPublicKey
+		Height     types.BlockHeight
+		Expiration types.BlockHeight
+	}
+
 	// blockFacts contains a set of facts about the consensus set related to a
 	// certain block. The explorer needs some additional information in the
 	// history so that it can calculate certain values, which is one of the
@@ -41,6 +78,28 @@ type (
 		Timestamp types.Timestamp
 	}
 
+	// marketFacts contains the storage market statistics that the explorer
+	// keeps for every block in addition to the blockFacts. All of the fields
+	// are cumulative from the genesis block.
+	marketFacts struct {
+		// HostAnnouncementCount is the number of valid host announcements.
+		HostAnnouncementCount uint64
+
+		// ActiveHostCount is the number of hosts that announced within the
+		// last activeHostWindow blocks or that have an open file contract.
+		// Unlike the other fields it is not cumulative.
+		ActiveHostCount uint64
+
+		// MissedProofCount is the number of file contracts that expired
+		// without a storage proof.
+		MissedProofCount uint64
+
+		// TotalContractDuration is the sum of the number of blocks between
+		// the formation of each file contract and the end of its proof
+		// window.
+		TotalContractDuration types.BlockHeight
+	}
+
 	// An Explorer contains a more comprehensive view of the blockchain,
 	// including various statistics and metrics.
 	Explorer struct {
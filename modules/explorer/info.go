@@ -4,6 +4,7 @@ import (
 	bolt "github.com/coreos/bbolt"
 
 	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/encoding"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
@@ -54,6 +55,65 @@ func (e *Explorer) LatestBlockFacts() modules.BlockFacts {
 	return bf.BlockFacts
 }
 
+// MarketFacts returns a set of storage market statistics as they appeared at
+// a given block height, and a bool indicating whether facts exist for the
+// given height.
+func (e *Explorer) MarketFacts(height types.BlockHeight) (modules.MarketFacts, bool) {
+	var bf blockFacts
+	var mf marketFacts
+	err := e.db.View(e.dbGetMarketFacts(height, &bf, &mf))
+	if err != nil {
+		return modules.MarketFacts{}, false
+	}
+	return buildMarketFacts(bf, mf), true
+}
+
+// LatestMarketFacts returns the storage market statistics as they appeared at
+// the latest block height in the explorer's consensus set.
+func (e *Explorer) LatestMarketFacts() modules.MarketFacts {
+	var bf blockFacts
+	var mf marketFacts
+	err := e.db.View(func(tx *bolt.Tx) error {
+		var height types.BlockHeight
+		err := dbGetInternal(internalBlockHeight, &height)(tx)
+		if err != nil {
+			return err
+		}
+		return e.dbGetMarketFacts(height, &bf, &mf)(tx)
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	return buildMarketFacts(bf, mf)
+}
+
+// buildMarketFacts combines the block facts and market facts of a block into
+// the statistics reported to callers.
+func buildMarketFacts(bf blockFacts, mf marketFacts) modules.MarketFacts {
+	var avgDuration types.BlockHeight
+	if bf.FileContractCount > 0 {
+		avgDuration = mf.TotalContractDuration / types.BlockHeight(bf.FileContractCount)
+	}
+	return modules.MarketFacts{
+		BlockID:   bf.BlockID,
+		Height:    bf.Height,
+		Timestamp: bf.Timestamp,
+
+		HostAnnouncementCount: mf.HostAnnouncementCount,
+		ActiveHostCount:       mf.ActiveHostCount,
+
+		ContractCount:           bf.FileContractCount,
+		TotalContractPayout:     bf.TotalContractCost,
+		AverageContractDuration: avgDuration,
+		StorageProofCount:       bf.StorageProofCount,
+		MissedProofCount:        mf.MissedProofCount,
+
+		ActiveContractCount: bf.ActiveContractCount,
+		ActiveContractCost:  bf.ActiveContractCost,
+		ActiveContractSize:  bf.ActiveContractSize,
+	}
+}
+
 // Transaction takes a transaction ID and finds the block containing the
 // transaction. Because of the miner payouts, the transaction ID might be a
 // block ID. To find the transaction, iterate through the block.
@@ -185,3 +245,101 @@ func (e *Explorer) SiafundOutputID(id types.SiafundOutputID) []types.Transaction
 	}
 	return ids
 }
+
+// AddressBalance returns the spendable siacoin and siafund balance of the
+// specified unlock hash. An unlock hash that owns no unspent outputs has a
+// zero balance.
+func (e *Explorer) AddressBalance(uh types.UnlockHash) modules.AddressBalance {
+	var ab addressBalance
+	err := e.db.View(dbGetAddressBalance(uh, &ab))
+	if err != nil {
+		build.Critical(err)
+	}
+	return modules.AddressBalance{
+		UnlockHash:         uh,
+		SiacoinBalance:     ab.SiacoinBalance,
+		SiafundBalance:     ab.SiafundBalance,
+		SiacoinOutputCount: ab.SiacoinOutputCount,
+		SiafundOutputCount: ab.SiafundOutputCount,
+	}
+}
+
+// AddressSiacoinOutputs returns the unspent siacoin outputs owned by the
+// specified unlock hash.
+func (e *Explorer) AddressSiacoinOutputs(uh types.UnlockHash) []modules.UnspentSiacoinOutput {
+	var outputs []modules.UnspentSiacoinOutput
+	err := e.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAddressSiacoinOutputs).Bucket(encoding.Marshal(uh))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var uso modules.UnspentSiacoinOutput
+			err := encoding.Unmarshal(k, &uso.ID)
+			if err != nil {
+				return err
+			}
+			err = encoding.Unmarshal(v, &uso.SiacoinOutput)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, uso)
+			return nil
+		})
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	return outputs
+}
+
+// AddressSiafundOutputs returns the unspent siafund outputs owned by the
+// specified unlock hash.
+func (e *Explorer) AddressSiafundOutputs(uh types.UnlockHash) []modules.UnspentSiafundOutput {
+	var outputs []modules.UnspentSiafundOutput
+	err := e.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAddressSiafundOutputs).Bucket(encoding.Marshal(uh))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var uso modules.UnspentSiafundOutput
+			err := encoding.Unmarshal(k, &uso.ID)
+			if err != nil {
+				return err
+			}
+			err = encoding.Unmarshal(v, &uso.SiafundOutput)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, uso)
+			return nil
+		})
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	return outputs
+}
+
+// SiacoinRichList returns up to limit unlock hashes ordered by their siacoin
+// balance, largest first, after skipping the first offset entries.
+func (e *Explorer) SiacoinRichList(offset, limit uint64) []modules.RichListEntry {
+	var entries []modules.RichListEntry
+	err := e.db.View(dbGetRichList(bucketSiacoinRichList, offset, limit, &entries))
+	if err != nil {
+		build.Critical(err)
+	}
+	return entries
+}
+
+// SiafundRichList returns up to limit unlock hashes ordered by their siafund
+// balance, largest first, after skipping the first offset entries.
+func (e *Explorer) SiafundRichList(offset, limit uint64) []modules.RichListEntry {
+	var entries []modules.RichListEntry
+	err := e.db.View(dbGetRichList(bucketSiafundRichList, offset, limit, &entries))
+	if err != nil {
+		build.Critical(err)
+	}
+	return entries
+}
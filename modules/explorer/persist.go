@@ -35,20 +35,49 @@ func (e *Explorer) initPersist() error {
 	// Initialize the database
 	err = e.db.Update(func(tx *bolt.Tx) error {
 		buckets := [][]byte{
+			bucketAddressBalances,
+			bucketAddressSiacoinOutputs,
+			bucketAddressSiafundOutputs,
 			bucketBlockFacts,
 			bucketBlockIDs,
 			bucketBlocksDifficulty,
 			bucketBlockTargets,
+			bucketContractHosts,
 			bucketFileContractHistories,
 			bucketFileContractIDs,
+			bucketHostActivity,
+			bucketHostActivityExpirations,
 			bucketInternal,
+			bucketMarketFacts,
 			bucketSiacoinOutputIDs,
 			bucketSiacoinOutputs,
+			bucketSiacoinRichList,
 			bucketSiafundOutputIDs,
 			bucketSiafundOutputs,
+			bucketSiafundRichList,
 			bucketTransactionIDs,
 			bucketUnlockHashes,
 		}
+		// Databases created by an older explorer that is missing some of the
+		// buckets cannot be upgraded in place, because the new statistics
+		// depend on every block that was ever processed. Drop the existing
+		// buckets so that the explorer rescans the blockchain from the
+		// genesis block.
+		missingBucket := false
+		for _, b := range buckets {
+			missingBucket = missingBucket || tx.Bucket(b) == nil
+		}
+		if tx.Bucket(bucketInternal) != nil && missingBucket {
+			for _, b := range buckets {
+				if tx.Bucket(b) == nil {
+					continue
+				}
+				err := tx.DeleteBucket(b)
+				if err != nil {
+					return err
+				}
+			}
+		}
 		for _, b := range buckets {
 			_, err := tx.CreateBucketIfNotExists(b)
 			if err != nil {
@@ -60,6 +89,7 @@ func (e *Explorer) initPersist() error {
 		internalDefaults := []struct {
 			key, val []byte
 		}{
+			{internalActiveHostCount, encoding.Marshal(uint64(0))},
 			{internalBlockHeight, encoding.Marshal(types.BlockHeight(0))},
 			{internalRecentChange, encoding.Marshal(modules.ConsensusChangeID{})},
 		}
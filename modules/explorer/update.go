@@ -38,6 +38,9 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			bid := block.ID()
 			tbid := types.TransactionID(bid)
 
+			// Restore the host activity that expired in this block.
+			dbRestoreHostActivity(tx, blockheight)
+
 			blockheight--
 			dbRemoveBlockID(tx, bid)
 			dbRemoveTransactionID(tx, tbid) // Miner payouts are a transaction
@@ -102,6 +105,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 					}
 					// Remove the file contract revision from the revision chain.
 					dbRemoveFileContractRevision(tx, fcr.ParentID)
+					dbRemoveContractHost(tx, fcr.ParentID, blockheight+1)
 				}
 				for _, sp := range txn.StorageProofs {
 					dbRemoveStorageProof(tx, sp.ParentID)
@@ -116,10 +120,17 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 					dbRemoveSiafundOutputID(tx, sfoid, txid)
 					dbRemoveUnlockHash(tx, sfo.UnlockHash, txid)
 				}
+				for _, arb := range txn.ArbitraryData {
+					_, spk, err := modules.DecodeAnnouncement(arb)
+					if err == nil {
+						dbRemoveHostActivity(tx, spk, blockheight+1+activeHostWindow)
+					}
+				}
 			}
 
 			// remove the associated block facts
 			dbRemoveBlockFacts(tx, bid)
+			dbRemoveMarketFacts(tx, bid)
 		}
 
 		// Update cumulative stats for applied blocks.
@@ -196,6 +207,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 						dbAddUnlockHash(tx, sco.UnlockHash, txid)
 					}
 					dbAddFileContractRevision(tx, fcr.ParentID, fcr)
+					dbAddContractHost(tx, fcr, blockheight)
 				}
 				for _, sp := range txn.StorageProofs {
 					dbAddFileContractID(tx, sp.ParentID, txid)
@@ -211,26 +223,45 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 					dbAddSiafundOutputID(tx, sfoid, txid)
 					dbAddUnlockHash(tx, sfo.UnlockHash, txid)
 				}
+				for _, arb := range txn.ArbitraryData {
+					_, spk, err := modules.DecodeAnnouncement(arb)
+					if err == nil {
+						dbAddHostActivity(tx, spk, blockheight+activeHostWindow)
+					}
+				}
 			}
 
+			// Expire the host activity that ends in this block.
+			dbExpireHostActivity(tx, blockheight)
+
 			// calculate and add new block facts, if possible
 			if tx.Bucket(bucketBlockFacts).Get(encoding.Marshal(block.ParentID)) != nil {
 				facts := dbCalculateBlockFacts(tx, e.cs, block)
 				dbAddBlockFacts(tx, facts)
+				dbAddMarketFacts(tx, bid, dbCalculateMarketFacts(tx, block, facts.Height))
 			}
 		}
 
-		// Update stats according to SiacoinOutputDiffs
+		// Update stats and address balances according to SiacoinOutputDiffs.
+		// The diffs of the reverted blocks come first and are already
+		// inverted, so processing them in order keeps the balances exact
+		// across reorgs.
 		for _, scod := range cc.SiacoinOutputDiffs {
 			if scod.Direction == modules.DiffApply {
 				dbAddSiacoinOutput(tx, scod.ID, scod.SiacoinOutput)
+				dbAddAddressSiacoinOutput(tx, scod.ID, scod.SiacoinOutput)
+			} else {
+				dbRemoveAddressSiacoinOutput(tx, scod.ID, scod.SiacoinOutput)
 			}
 		}
 
-		// Update stats according to SiafundOutputDiffs
+		// Update stats and address balances according to SiafundOutputDiffs
 		for _, sfod := range cc.SiafundOutputDiffs {
 			if sfod.Direction == modules.DiffApply {
 				dbAddSiafundOutput(tx, sfod.ID, sfod.SiafundOutput)
+				dbAddAddressSiafundOutput(tx, sfod.ID, sfod.SiafundOutput)
+			} else {
+				dbRemoveAddressSiafundOutput(tx, sfod.ID, sfod.SiafundOutput)
 			}
 		}
 
@@ -264,6 +295,31 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			}
 		}
 
+		// Count the file contracts that were closed without a storage proof.
+		// Revisions revert and reapply a contract within the same change, so
+		// only contracts whose final diff is a revert have been closed.
+		// Contracts that were created in reverted blocks no longer have a
+		// history and are skipped. As with the active set, large reorgs may
+		// attribute missed proofs to the wrong block.
+		var mf marketFacts
+		err = dbGetAndDecode(bucketMarketFacts, currentID, &mf)(tx)
+		if err == nil {
+			closed := make(map[types.FileContractID]bool)
+			for _, diff := range cc.FileContractDiffs {
+				closed[diff.ID] = diff.Direction == modules.DiffRevert
+			}
+			for fcid, isClosed := range closed {
+				var history fileContractHistory
+				if !isClosed || dbGetAndDecode(bucketFileContractHistories, fcid, &history)(tx) != nil {
+					continue
+				}
+				if history.StorageProof.ParentID != fcid {
+					mf.MissedProofCount++
+				}
+			}
+			dbAddMarketFacts(tx, currentID, mf)
+		}
+
 		// set final blockheight
 		err = dbSetInternal(internalBlockHeight, blockheight)(tx)
 		if err != nil {
@@ -306,6 +362,81 @@ func bucketIsEmpty(bucket *bolt.Bucket) bool {
 // These functions panic on error. The panic will be caught by
 // ProcessConsensusChange.
 
+// Set the balance of an unlock hash, keeping the rich lists in sync
+func dbSetAddressBalance(tx *bolt.Tx, uh types.UnlockHash, ab addressBalance) {
+	var prev addressBalance
+	assertNil(dbGetAddressBalance(uh, &prev)(tx))
+	if !prev.SiacoinBalance.IsZero() {
+		assertNil(tx.Bucket(bucketSiacoinRichList).Delete(richListKey(prev.SiacoinBalance, uh)))
+	}
+	if !prev.SiafundBalance.IsZero() {
+		assertNil(tx.Bucket(bucketSiafundRichList).Delete(richListKey(prev.SiafundBalance, uh)))
+	}
+	if !ab.SiacoinBalance.IsZero() {
+		assertNil(tx.Bucket(bucketSiacoinRichList).Put(richListKey(ab.SiacoinBalance, uh), nil))
+	}
+	if !ab.SiafundBalance.IsZero() {
+		assertNil(tx.Bucket(bucketSiafundRichList).Put(richListKey(ab.SiafundBalance, uh), nil))
+	}
+	if ab.SiacoinOutputCount == 0 && ab.SiafundOutputCount == 0 {
+		mustDelete(tx.Bucket(bucketAddressBalances), uh)
+		return
+	}
+	mustPut(tx.Bucket(bucketAddressBalances), uh, ab)
+}
+
+// Add/Remove unspent siacoin output of an unlock hash
+func dbAddAddressSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID, sco types.SiacoinOutput) {
+	b, err := tx.Bucket(bucketAddressSiacoinOutputs).CreateBucketIfNotExists(encoding.Marshal(sco.UnlockHash))
+	assertNil(err)
+	mustPut(b, id, sco)
+
+	var ab addressBalance
+	assertNil(dbGetAddressBalance(sco.UnlockHash, &ab)(tx))
+	ab.SiacoinBalance = ab.SiacoinBalance.Add(sco.Value)
+	ab.SiacoinOutputCount++
+	dbSetAddressBalance(tx, sco.UnlockHash, ab)
+}
+func dbRemoveAddressSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID, sco types.SiacoinOutput) {
+	bucket := tx.Bucket(bucketAddressSiacoinOutputs).Bucket(encoding.Marshal(sco.UnlockHash))
+	mustDelete(bucket, id)
+	if bucketIsEmpty(bucket) {
+		tx.Bucket(bucketAddressSiacoinOutputs).DeleteBucket(encoding.Marshal(sco.UnlockHash))
+	}
+
+	var ab addressBalance
+	assertNil(dbGetAddressBalance(sco.UnlockHash, &ab)(tx))
+	ab.SiacoinBalance = ab.SiacoinBalance.Sub(sco.Value)
+	ab.SiacoinOutputCount--
+	dbSetAddressBalance(tx, sco.UnlockHash, ab)
+}
+
+// Add/Remove unspent siafund output of an unlock hash
+func dbAddAddressSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID, sfo types.SiafundOutput) {
+	b, err := tx.Bucket(bucketAddressSiafundOutputs).CreateBucketIfNotExists(encoding.Marshal(sfo.UnlockHash))
+	assertNil(err)
+	mustPut(b, id, sfo)
+
+	var ab addressBalance
+	assertNil(dbGetAddressBalance(sfo.UnlockHash, &ab)(tx))
+	ab.SiafundBalance = ab.SiafundBalance.Add(sfo.Value)
+	ab.SiafundOutputCount++
+	dbSetAddressBalance(tx, sfo.UnlockHash, ab)
+}
+func dbRemoveAddressSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID, sfo types.SiafundOutput) {
+	bucket := tx.Bucket(bucketAddressSiafundOutputs).Bucket(encoding.Marshal(sfo.UnlockHash))
+	mustDelete(bucket, id)
+	if bucketIsEmpty(bucket) {
+		tx.Bucket(bucketAddressSiafundOutputs).DeleteBucket(encoding.Marshal(sfo.UnlockHash))
+	}
+
+	var ab addressBalance
+	assertNil(dbGetAddressBalance(sfo.UnlockHash, &ab)(tx))
+	ab.SiafundBalance = ab.SiafundBalance.Sub(sfo.Value)
+	ab.SiafundOutputCount--
+	dbSetAddressBalance(tx, sfo.UnlockHash, ab)
+}
+
 // Add/Remove block ID
 func dbAddBlockID(tx *bolt.Tx, id types.BlockID, height types.BlockHeight) {
 	mustPut(tx.Bucket(bucketBlockIDs), id, height)
@@ -322,6 +453,126 @@ func dbRemoveBlockFacts(tx *bolt.Tx, id types.BlockID) {
 	mustDelete(tx.Bucket(bucketBlockFacts), id)
 }
 
+// Add/Remove market facts
+func dbAddMarketFacts(tx *bolt.Tx, id types.BlockID, mf marketFacts) {
+	mustPut(tx.Bucket(bucketMarketFacts), id, mf)
+}
+func dbRemoveMarketFacts(tx *bolt.Tx, id types.BlockID) {
+	mustDelete(tx.Bucket(bucketMarketFacts), id)
+}
+
+// Add/Remove host activity. Every announcement and every file contract that
+// is linked to a host keeps the host active until the expiration height.
+// Hosts with at least one reason to be active are counted as active hosts.
+func dbAddHostActivity(tx *bolt.Tx, spk types.SiaPublicKey, expiration types.BlockHeight) {
+	dbIncrementHostActivity(tx, spk)
+	b, err := tx.Bucket(bucketHostActivityExpirations).CreateBucketIfNotExists(encoding.Marshal(expiration))
+	assertNil(err)
+	var count uint64
+	if v := b.Get(encoding.Marshal(spk)); v != nil {
+		assertNil(encoding.Unmarshal(v, &count))
+	}
+	mustPut(b, spk, count+1)
+}
+func dbRemoveHostActivity(tx *bolt.Tx, spk types.SiaPublicKey, expiration types.BlockHeight) {
+	dbDecrementHostActivity(tx, spk)
+	b := tx.Bucket(bucketHostActivityExpirations).Bucket(encoding.Marshal(expiration))
+	var count uint64
+	assertNil(encoding.Unmarshal(b.Get(encoding.Marshal(spk)), &count))
+	if count > 1 {
+		mustPut(b, spk, count-1)
+		return
+	}
+	mustDelete(b, spk)
+	if bucketIsEmpty(b) {
+		assertNil(tx.Bucket(bucketHostActivityExpirations).DeleteBucket(encoding.Marshal(expiration)))
+	}
+}
+
+// Expire/Restore the host activity that ends at a height. The expirations are
+// kept so that reverting the block can restore them.
+func dbExpireHostActivity(tx *bolt.Tx, height types.BlockHeight) {
+	forEachHostActivityExpiration(tx, height, dbDecrementHostActivity)
+}
+func dbRestoreHostActivity(tx *bolt.Tx, height types.BlockHeight) {
+	forEachHostActivityExpiration(tx, height, dbIncrementHostActivity)
+}
+func forEachHostActivityExpiration(tx *bolt.Tx, height types.BlockHeight, fn func(*bolt.Tx, types.SiaPublicKey)) {
+	b := tx.Bucket(bucketHostActivityExpirations).Bucket(encoding.Marshal(height))
+	if b == nil {
+		return
+	}
+	assertNil(b.ForEach(func(k, v []byte) error {
+		var spk types.SiaPublicKey
+		var count uint64
+		assertNil(encoding.Unmarshal(k, &spk))
+		assertNil(encoding.Unmarshal(v, &count))
+		for i := uint64(0); i < count; i++ {
+			fn(tx, spk)
+		}
+		return nil
+	}))
+}
+
+// Increment/Decrement the number of reasons for a host to be active, keeping
+// track of the number of active hosts
+func dbIncrementHostActivity(tx *bolt.Tx, spk types.SiaPublicKey) {
+	var refs uint64
+	err := dbGetAndDecode(bucketHostActivity, spk, &refs)(tx)
+	if err == errNotExist {
+		var hosts uint64
+		assertNil(dbGetInternal(internalActiveHostCount, &hosts)(tx))
+		assertNil(dbSetInternal(internalActiveHostCount, hosts+1)(tx))
+	} else {
+		assertNil(err)
+	}
+	mustPut(tx.Bucket(bucketHostActivity), spk, refs+1)
+}
+func dbDecrementHostActivity(tx *bolt.Tx, spk types.SiaPublicKey) {
+	var refs uint64
+	assertNil(dbGetAndDecode(bucketHostActivity, spk, &refs)(tx))
+	if refs > 1 {
+		mustPut(tx.Bucket(bucketHostActivity), spk, refs-1)
+		return
+	}
+	mustDelete(tx.Bucket(bucketHostActivity), spk)
+	var hosts uint64
+	assertNil(dbGetInternal(internalActiveHostCount, &hosts)(tx))
+	assertNil(dbSetInternal(internalActiveHostCount, hosts-1)(tx))
+}
+
+// Add/Remove the link between a file contract and its host. Renter-host
+// contracts are revised with unlock conditions that list the renter's key
+// followed by the host's key. The first revision that is seen links the
+// contract, which keeps the host active until the end of the proof window.
+// Contracts that end early with a storage proof are still counted until
+// then.
+func dbAddContractHost(tx *bolt.Tx, fcr types.FileContractRevision, height types.BlockHeight) {
+	if len(fcr.UnlockConditions.PublicKeys) != 2 || fcr.NewWindowEnd <= height {
+		return
+	}
+	if tx.Bucket(bucketContractHosts).Get(encoding.Marshal(fcr.ParentID)) != nil {
+		return
+	}
+	ch := contractHost{
+		Host:       fcr.UnlockConditions.PublicKeys[1],
+		Height:     height,
+		Expiration: fcr.NewWindowEnd,
+	}
+	mustPut(tx.Bucket(bucketContractHosts), fcr.ParentID, ch)
+	dbAddHostActivity(tx, ch.Host, ch.Expiration)
+}
+func dbRemoveContractHost(tx *bolt.Tx, fcid types.FileContractID, height types.BlockHeight) {
+	var ch contractHost
+	err := dbGetAndDecode(bucketContractHosts, fcid, &ch)(tx)
+	if err == errNotExist || (err == nil && ch.Height != height) {
+		return
+	}
+	assertNil(err)
+	mustDelete(tx.Bucket(bucketContractHosts), fcid)
+	dbRemoveHostActivity(tx, ch.Host, ch.Expiration)
+}
+
 // Add/Remove block target
 func dbAddBlockTarget(tx *bolt.Tx, id types.BlockID, target types.Target) {
 	mustPut(tx.Bucket(bucketBlockTargets), id, target)
@@ -437,7 +688,12 @@ func dbAddUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID)
 	mustPutSet(b, txid)
 }
 func dbRemoveUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
+	// A transaction can reference the same unlock hash several times, in
+	// which case the set may already have been removed.
 	bucket := tx.Bucket(bucketUnlockHashes).Bucket(encoding.Marshal(uh))
+	if bucket == nil {
+		return
+	}
 	mustDelete(bucket, txid)
 	if bucketIsEmpty(bucket) {
 		tx.Bucket(bucketUnlockHashes).DeleteBucket(encoding.Marshal(uh))
@@ -524,6 +780,29 @@ func dbCalculateBlockFacts(tx *bolt.Tx, cs modules.ConsensusSet, block types.Blo
 	return bf
 }
 
+// dbCalculateMarketFacts calculates the market facts of a block at the given
+// height from the market facts of its parent. The host activity of the block
+// must already have been added to the database.
+func dbCalculateMarketFacts(tx *bolt.Tx, block types.Block, height types.BlockHeight) marketFacts {
+	var mf marketFacts
+	assertNil(dbGetAndDecode(bucketMarketFacts, block.ParentID, &mf)(tx))
+	assertNil(dbGetInternal(internalActiveHostCount, &mf.ActiveHostCount)(tx))
+	for _, txn := range block.Transactions {
+		for _, arb := range txn.ArbitraryData {
+			_, _, err := modules.DecodeAnnouncement(arb)
+			if err == nil {
+				mf.HostAnnouncementCount++
+			}
+		}
+		for _, fc := range txn.FileContracts {
+			if fc.WindowEnd > height {
+				mf.TotalContractDuration += fc.WindowEnd - height
+			}
+		}
+	}
+	return mf
+}
+
 // Special handling for the genesis block. No other functions are called on it.
 func dbAddGenesisBlock(tx *bolt.Tx) {
 	id := types.GenesisID
@@ -564,4 +843,5 @@ func dbAddGenesisBlock(tx *bolt.Tx) {
 		},
 		Timestamp: types.GenesisBlock.Timestamp,
 	})
+	dbAddMarketFacts(tx, id, marketFacts{})
 }
@@ -3,6 +3,7 @@ package explorer
 import (
 	"testing"
 
+	"gitlab.com/NebulousLabs/Sia/crypto"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
@@ -213,3 +214,286 @@ func TestIntegrationExplorerFileContractMetrics(t *testing.T) {
 	// 	t.Error("post reorg file contract count should be zero, got", facts.FileContractCount)
 	// }
 }
+
+// TestExplorerAddressBalances checks that the explorer tracks the balances and
+// unspent outputs of addresses, and that the rich lists stay ordered, as
+// blocks are applied and reverted.
+func TestExplorerAddressBalances(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The siafund rich list should account for every siafund in existence.
+	sumRichList := func(entries []modules.RichListEntry) (sum types.Currency) {
+		for i, entry := range entries {
+			if i > 0 && entry.Balance.Cmp(entries[i-1].Balance) > 0 {
+				t.Fatal("rich list is not ordered by balance")
+			}
+			sum = sum.Add(entry.Balance)
+		}
+		return sum
+	}
+	if sum := sumRichList(et.explorer.SiafundRichList(0, 1000)); !sum.Equals(types.SiafundCount) {
+		t.Fatal("siafund rich list does not add up to the siafund count:", sum)
+	}
+
+	// Send coins to a fresh address and check that the explorer picks up the
+	// new output once it is confirmed.
+	uh := types.UnlockHash{1, 2, 3}
+	amount := types.SiacoinPrecision.Mul64(100)
+	_, err = et.wallet.SendSiacoins(amount, uh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ab := et.explorer.AddressBalance(uh); !ab.SiacoinBalance.IsZero() {
+		t.Fatal("unconfirmed output was counted towards the balance")
+	}
+	_, err = et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab := et.explorer.AddressBalance(uh)
+	if !ab.SiacoinBalance.Equals(amount) || ab.SiacoinOutputCount != 1 {
+		t.Fatal("wrong balance reported for address:", ab.SiacoinBalance, ab.SiacoinOutputCount)
+	}
+	outputs := et.explorer.AddressSiacoinOutputs(uh)
+	if len(outputs) != 1 || !outputs[0].Value.Equals(amount) || outputs[0].UnlockHash != uh {
+		t.Fatal("wrong unspent outputs reported for address:", outputs)
+	}
+	if _, exists := et.explorer.SiacoinOutput(outputs[0].ID); !exists {
+		t.Fatal("unspent output id is unknown to the explorer")
+	}
+	found := false
+	for _, entry := range et.explorer.SiacoinRichList(0, 1000) {
+		found = found || (entry.UnlockHash == uh && entry.Balance.Equals(amount))
+	}
+	if !found {
+		t.Fatal("address is missing from the siacoin rich list")
+	}
+
+	// Check that the rich list can be paginated.
+	page := et.explorer.SiacoinRichList(0, 2)
+	if len(page) != 2 {
+		t.Fatal("expected two rich list entries, got", len(page))
+	}
+	next := et.explorer.SiacoinRichList(1, 1)
+	if len(next) != 1 || next[0].UnlockHash != page[1].UnlockHash {
+		t.Fatal("rich list offset is not applied correctly")
+	}
+
+	// Reorg the explorer onto a blank chain, which reverts the transaction.
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ab := et.explorer.AddressBalance(uh); !ab.SiacoinBalance.IsZero() || ab.SiacoinOutputCount != 0 {
+		t.Fatal("reverted output is still counted towards the balance")
+	}
+	if outputs := et.explorer.AddressSiacoinOutputs(uh); len(outputs) != 0 {
+		t.Fatal("reverted output is still reported as unspent")
+	}
+	if sum := sumRichList(et.explorer.SiafundRichList(0, 1000)); !sum.Equals(types.SiafundCount) {
+		t.Fatal("siafund rich list does not add up to the siafund count after a reorg:", sum)
+	}
+}
+
+// TestExplorerMarketFacts checks that the explorer tracks host announcements,
+// active hosts and file contract outcomes in its market facts.
+func TestExplorerMarketFacts(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf := et.explorer.LatestMarketFacts()
+	if mf.ActiveHostCount != 0 || mf.HostAnnouncementCount != 0 || mf.ContractCount != 0 {
+		t.Fatal("fresh explorer has nonzero market facts:", mf)
+	}
+
+	// Announce one host twice and another host once.
+	sk1, pk1 := crypto.GenerateKeyPair()
+	sk2, pk2 := crypto.GenerateKeyPair()
+	announce := func(addr modules.NetAddress, sk crypto.SecretKey, pk crypto.PublicKey) {
+		ann, err := modules.CreateAnnouncement(addr, types.Ed25519PublicKey(pk), sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder, err := et.wallet.StartTransaction()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fee := types.SiacoinPrecision
+		err = builder.FundSiacoins(fee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder.AddMinerFee(fee)
+		builder.AddArbitraryData(ann)
+		txns, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = et.tpool.AcceptTransactionSet(txns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = et.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	announce("foo.com:1234", sk1, pk1)
+	announce("bar.com:1234", sk1, pk1)
+	announce("baz.com:1234", sk2, pk2)
+	lastAnnouncement := et.cs.Height()
+	mf = et.explorer.LatestMarketFacts()
+	if mf.HostAnnouncementCount != 3 || mf.ActiveHostCount != 2 {
+		t.Fatal("wrong host statistics:", mf.HostAnnouncementCount, mf.ActiveHostCount)
+	}
+
+	// Form a file contract with a third host that never announced. The
+	// contract never receives a storage proof.
+	_, renterPK := crypto.GenerateKeyPair()
+	_, hostPK := crypto.GenerateKeyPair()
+	uc := types.UnlockConditions{
+		PublicKeys: []types.SiaPublicKey{
+			types.Ed25519PublicKey(renterPK),
+			types.Ed25519PublicKey(hostPK),
+		},
+	}
+	builder, err := et.wallet.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout := types.NewCurrency64(1e9)
+	err = builder.FundSiacoins(payout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formed := et.cs.Height() + 1
+	fc := types.FileContract{
+		FileSize:           10,
+		WindowStart:        formed + 2,
+		WindowEnd:          formed + 4,
+		Payout:             payout,
+		ValidProofOutputs:  []types.SiacoinOutput{{Value: types.PostTax(et.cs.Height(), payout)}},
+		MissedProofOutputs: []types.SiacoinOutput{{Value: types.PostTax(et.cs.Height(), payout)}},
+		UnlockHash:         uc.UnlockHash(),
+	}
+	builder.AddFileContract(fc)
+	txns, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = et.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf = et.explorer.LatestMarketFacts()
+	if mf.ContractCount != 1 || !mf.TotalContractPayout.Equals(payout) || mf.AverageContractDuration != 4 {
+		t.Fatal("wrong contract statistics:", mf.ContractCount, mf.TotalContractPayout, mf.AverageContractDuration)
+	}
+	if mf.ActiveContractCount != 1 || mf.MissedProofCount != 0 {
+		t.Fatal("contract should be active:", mf.ActiveContractCount, mf.MissedProofCount)
+	}
+	if mf.ActiveHostCount != 2 {
+		t.Fatal("contract should not be linked to its host before a revision:", mf.ActiveHostCount)
+	}
+
+	// Revise the contract, which reveals the host's key.
+	builder, err = et.wallet.StartTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee := types.SiacoinPrecision
+	err = builder.FundSiacoins(fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddMinerFee(fee)
+	builder.AddFileContractRevision(types.FileContractRevision{
+		ParentID:              txns[len(txns)-1].FileContractID(0),
+		UnlockConditions:      uc,
+		NewRevisionNumber:     1,
+		NewFileSize:           fc.FileSize,
+		NewWindowStart:        fc.WindowStart,
+		NewWindowEnd:          fc.WindowEnd,
+		NewValidProofOutputs:  fc.ValidProofOutputs,
+		NewMissedProofOutputs: fc.MissedProofOutputs,
+		NewUnlockHash:         fc.UnlockHash,
+	})
+	txns, err = builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = et.tpool.AcceptTransactionSet(txns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revised := et.cs.Height()
+	mf = et.explorer.LatestMarketFacts()
+	if mf.ActiveHostCount != 3 {
+		t.Fatal("revised contract should count its host as active:", mf.ActiveHostCount)
+	}
+
+	// Mine past the end of the proof window.
+	for et.cs.Height() <= fc.WindowEnd {
+		_, err = et.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mf = et.explorer.LatestMarketFacts()
+	if mf.ActiveContractCount != 0 || mf.MissedProofCount != 1 || mf.StorageProofCount != 0 {
+		t.Fatal("contract should have missed its proof:", mf.ActiveContractCount, mf.MissedProofCount, mf.StorageProofCount)
+	}
+	if mf.ActiveHostCount != 2 {
+		t.Fatal("expired contract should not keep its host active:", mf.ActiveHostCount)
+	}
+
+	// Mine until the announcements are too old to count.
+	for et.cs.Height() < lastAnnouncement+activeHostWindow {
+		_, err = et.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mf = et.explorer.LatestMarketFacts()
+	if mf.ActiveHostCount != 0 || mf.HostAnnouncementCount != 3 {
+		t.Fatal("old announcements should not count as active hosts:", mf.ActiveHostCount, mf.HostAnnouncementCount)
+	}
+
+	// Historical facts should still report the contract and hosts as active.
+	old, exists := et.explorer.MarketFacts(formed)
+	if !exists || old.ActiveContractCount != 1 || old.MissedProofCount != 0 || old.ActiveHostCount != 2 {
+		t.Fatal("wrong historical market facts:", old)
+	}
+	old, exists = et.explorer.MarketFacts(revised)
+	if !exists || old.ActiveHostCount != 3 {
+		t.Fatal("wrong historical market facts:", old)
+	}
+
+	// Reverting the announcements should remove the hosts again.
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf = et.explorer.LatestMarketFacts()
+	if mf.ActiveHostCount != 0 || mf.HostAnnouncementCount != 0 || mf.ContractCount != 0 {
+		t.Fatal("reverted blocks are still counted in the market facts:", mf)
+	}
+}
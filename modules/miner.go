@@ -27,6 +27,67 @@ type BlockManager interface {
 	// BlocksMined returns the number of blocks and stale blocks that have been
 	// mined using this miner.
 	BlocksMined() (goodBlocks, staleBlocks int)
+
+	// Settings returns the payout and coinbase settings of the miner.
+	Settings() MinerSettings
+
+	// SetSettings changes the payout and coinbase settings of the miner.
+	// Blocks that are created afterwards use the new settings.
+	SetSettings(MinerSettings) error
+}
+
+// MinerPayout is an address that receives a share of the block reward of
+// every block mined by the miner.
+type MinerPayout struct {
+	UnlockHash types.UnlockHash `json:"unlockhash"`
+	Percentage uint64           `json:"percentage"`
+}
+
+// MinerSettings control how the miner constructs blocks. If no payouts are
+// set, the block reward is paid to an address of the wallet. The percentages
+// of the payouts must add up to 100. The coinbase data is included in the
+// arbitrary data of every block mined.
+type MinerSettings struct {
+	Payouts      []MinerPayout `json:"payouts"`
+	CoinbaseData string        `json:"coinbasedata"`
+}
+
+// StratumServer pushes work to external miners over a Stratum-style TCP
+// protocol, so that they do not need to poll the BlockManager for headers.
+type StratumServer interface {
+	// StartStratum starts accepting Stratum connections on the provided
+	// address. Shares are accepted at the provided difficulty, which is
+	// usually far below the difficulty of a block.
+	StartStratum(addr string, shareDifficulty uint64) error
+
+	// StopStratum closes the Stratum listener and all of its connections.
+	StopStratum() error
+
+	// StratumStatus returns the state of the Stratum server and the
+	// statistics of its workers.
+	StratumStatus() StratumStatus
+}
+
+// StratumStatus describes the Stratum server of the miner.
+type StratumStatus struct {
+	Running         bool            `json:"running"`
+	Address         string          `json:"address"`
+	ShareDifficulty uint64          `json:"sharedifficulty"`
+	Connections     int             `json:"connections"`
+	Workers         []StratumWorker `json:"workers"`
+}
+
+// StratumWorker contains the statistics of a single worker that has
+// authorized with the Stratum server. The hashrate is estimated from the
+// difficulty of the shares that were accepted recently.
+type StratumWorker struct {
+	Name           string          `json:"name"`
+	Hashrate       uint64          `json:"hashrate"`
+	AcceptedShares uint64          `json:"acceptedshares"`
+	StaleShares    uint64          `json:"staleshares"`
+	RejectedShares uint64          `json:"rejectedshares"`
+	BlocksFound    uint64          `json:"blocksfound"`
+	LastShare      types.Timestamp `json:"lastshare"`
 }
 
 // CPUMiner provides access to a single-threaded cpu miner.
@@ -77,5 +138,6 @@ type TestMiner interface {
 type Miner interface {
 	BlockManager
 	CPUMiner
+	StratumServer
 	io.Closer
 }
@@ -27,21 +27,26 @@ func (m *Miner) blockForWork() types.Block {
 		b.Timestamp = types.CurrentTimestamp()
 	}
 
-	// Update the address + payouts.
-	err := m.checkAddress()
-	if err != nil {
-		m.log.Println(err)
+	// Update the address + payouts. The wallet address is only needed if no
+	// payout addresses have been configured.
+	if len(m.persist.Payouts) == 0 {
+		err := m.checkAddress()
+		if err != nil {
+			m.log.Println(err)
+		}
 	}
-	b.MinerPayouts = []types.SiacoinOutput{{
-		Value:      b.CalculateSubsidy(m.persist.Height + 1),
-		UnlockHash: m.persist.Address,
-	}}
+	b.MinerPayouts = m.minerPayouts(b.CalculateSubsidy(m.persist.Height + 1))
 
-	// Add an arb-data txn to the block to create a unique merkle root.
+	// Add an arb-data txn to the block to create a unique merkle root. The
+	// coinbase data, if any, is added to the same transaction.
 	randBytes := fastrand.Bytes(types.SpecifierLen)
 	randTxn := types.Transaction{
 		ArbitraryData: [][]byte{append(modules.PrefixNonSia[:], randBytes...)},
 	}
+	if m.persist.CoinbaseData != "" {
+		coinbase := append(modules.PrefixNonSia[:], m.persist.CoinbaseData...)
+		randTxn.ArbitraryData = append(randTxn.ArbitraryData, coinbase)
+	}
 	b.Transactions = append([]types.Transaction{randTxn}, b.Transactions...)
 
 	return b
@@ -65,6 +70,7 @@ func (m *Miner) newSourceBlock() {
 	block := m.blockForWork()
 	m.sourceBlock = &block
 	m.sourceBlockTime = time.Now()
+	m.signalNewWork()
 }
 
 // HeaderForWork returns a header that is ready for nonce grinding. The miner
@@ -80,7 +86,12 @@ func (m *Miner) HeaderForWork() (types.BlockHeader, types.Target, error) {
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.headerForWork()
+}
 
+// headerForWork creates a new header from the source block and remembers it
+// so that the block can be reconstructed when the header is submitted.
+func (m *Miner) headerForWork() (types.BlockHeader, types.Target, error) {
 	// Return a blank header with an error if the wallet is locked.
 	unlocked, err := m.wallet.Unlocked()
 	if err != nil {
@@ -172,40 +183,10 @@ func (m *Miner) SubmitHeader(bh types.BlockHeader) error {
 	}
 	defer m.tg.Done()
 
-	// Because a call to managedSubmitBlock is required at the end of this
-	// function, the first part needs to be wrapped in an anonymous function
-	// for lock safety.
-	var b types.Block
-	err := func() error {
-		m.mu.Lock()
-		defer m.mu.Unlock()
-
-		// Lookup the block that corresponds to the provided header.
-		nonce := bh.Nonce
-		bh.Nonce = [8]byte{}
-		bPointer, bExists := m.blockMem[bh]
-		arbData, arbExists := m.arbDataMem[bh]
-		if !bExists || !arbExists {
-			return errLateHeader
-		}
-
-		// Block is going to be passed to external memory, but the memory pointed
-		// to by the transactions slice is still being modified - needs to be
-		// copied. Same with the memory being pointed to by the arb data slice.
-		b = *bPointer
-		txns := make([]types.Transaction, len(b.Transactions))
-		copy(txns, b.Transactions)
-		b.Transactions = txns
-		b.Transactions[0].ArbitraryData = [][]byte{arbData[:]}
-		b.Nonce = nonce
-
-		// Sanity check - block should have same id as header.
-		bh.Nonce = nonce
-		if types.BlockID(crypto.HashObject(bh)) != b.ID() {
-			m.log.Critical("block reconstruction failed")
-		}
-		return nil
-	}()
+	// The lock must be released before calling managedSubmitBlock.
+	m.mu.Lock()
+	b, err := m.blockForHeader(bh)
+	m.mu.Unlock()
 	if err != nil {
 		m.log.Println("ERROR during call to SubmitHeader, pre SubmitBlock:", err)
 		return err
@@ -217,3 +198,36 @@ func (m *Miner) SubmitHeader(bh types.BlockHeader) error {
 	}
 	return nil
 }
+
+// blockForHeader reconstructs the block that corresponds to a header which
+// was previously handed out by headerForWork.
+func (m *Miner) blockForHeader(bh types.BlockHeader) (types.Block, error) {
+	// Lookup the block that corresponds to the provided header.
+	nonce := bh.Nonce
+	bh.Nonce = [8]byte{}
+	bPointer, bExists := m.blockMem[bh]
+	arbData, arbExists := m.arbDataMem[bh]
+	if !bExists || !arbExists {
+		return types.Block{}, errLateHeader
+	}
+
+	// Block is going to be passed to external memory, but the memory pointed
+	// to by the transactions slice is still being modified - needs to be
+	// copied. Same with the memory being pointed to by the arb data slice.
+	b := *bPointer
+	txns := make([]types.Transaction, len(b.Transactions))
+	copy(txns, b.Transactions)
+	b.Transactions = txns
+	arbitraryData := make([][]byte, len(b.Transactions[0].ArbitraryData))
+	copy(arbitraryData, b.Transactions[0].ArbitraryData)
+	arbitraryData[0] = arbData[:]
+	b.Transactions[0].ArbitraryData = arbitraryData
+	b.Nonce = nonce
+
+	// Sanity check - block should have same id as header.
+	bh.Nonce = nonce
+	if types.BlockID(crypto.HashObject(bh)) != b.ID() {
+		m.log.Critical("block reconstruction failed")
+	}
+	return b, nil
+}
@@ -101,6 +101,9 @@ type Miner struct {
 	mining   bool  // indicates if the miner is actually running
 	hashRate int64 // indicates hashes per second
 
+	// Stratum variables. The stratum server is nil when it is not running.
+	stratum *stratumServer
+
 	// Utils
 	log        *persist.Logger
 	mu         sync.RWMutex
@@ -221,6 +224,26 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, w modules.Walle
 		return nil, errors.New("miner could not save during startup: " + err.Error())
 	}
 
+	// Close the stratum server that is running at shutdown, if any.
+	m.tg.OnStop(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.stratum != nil {
+			m.stratum.close()
+			m.stratum = nil
+		}
+	})
+
+	// Restart the stratum server if it was running when the miner was last
+	// closed. Failing to do so is not fatal, the server can be started
+	// again through the API.
+	if m.persist.StratumAddress != "" {
+		err = m.StartStratum(m.persist.StratumAddress, m.persist.StratumShareDifficulty)
+		if err != nil {
+			m.log.Println("WARN: unable to restart the stratum server:", err)
+		}
+	}
+
 	return m, nil
 }
 
@@ -0,0 +1,105 @@
+package miner
+
+import (
+	"errors"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// maxCoinbaseDataSize is the largest amount of coinbase data that can be
+	// added to mined blocks.
+	maxCoinbaseDataSize = 256
+)
+
+var (
+	errCoinbaseDataTooLarge   = fmt.Errorf("coinbase data cannot be larger than %v bytes", maxCoinbaseDataSize)
+	errDuplicatePayoutAddress = errors.New("payout addresses must be unique")
+	errPayoutPercentageSum    = errors.New("payout percentages must add up to 100")
+	errZeroPayoutPercentage   = errors.New("payout percentages must be greater than zero")
+)
+
+// validateSettings checks that the miner settings can be used to construct
+// valid blocks.
+func validateSettings(settings modules.MinerSettings) error {
+	if len(settings.CoinbaseData) > maxCoinbaseDataSize {
+		return errCoinbaseDataTooLarge
+	}
+	if len(settings.Payouts) == 0 {
+		return nil
+	}
+	var sum uint64
+	seen := make(map[types.UnlockHash]struct{})
+	for _, payout := range settings.Payouts {
+		if payout.Percentage == 0 {
+			return errZeroPayoutPercentage
+		}
+		if _, exists := seen[payout.UnlockHash]; exists {
+			return errDuplicatePayoutAddress
+		}
+		seen[payout.UnlockHash] = struct{}{}
+		sum += payout.Percentage
+	}
+	if sum != 100 {
+		return errPayoutPercentageSum
+	}
+	return nil
+}
+
+// minerPayouts splits the block subsidy between the configured payout
+// addresses. Any remainder from rounding is paid to the last address, so that
+// the payouts always add up to the subsidy. If no payouts are configured, the
+// whole subsidy is paid to the wallet address of the miner.
+func (m *Miner) minerPayouts(subsidy types.Currency) []types.SiacoinOutput {
+	if len(m.persist.Payouts) == 0 {
+		return []types.SiacoinOutput{{
+			Value:      subsidy,
+			UnlockHash: m.persist.Address,
+		}}
+	}
+	outputs := make([]types.SiacoinOutput, len(m.persist.Payouts))
+	remaining := subsidy
+	for i, payout := range m.persist.Payouts {
+		value := remaining
+		if i < len(m.persist.Payouts)-1 {
+			value = subsidy.Mul64(payout.Percentage).Div64(100)
+			remaining = remaining.Sub(value)
+		}
+		outputs[i] = types.SiacoinOutput{
+			Value:      value,
+			UnlockHash: payout.UnlockHash,
+		}
+	}
+	return outputs
+}
+
+// Settings returns the payout and coinbase settings of the miner.
+func (m *Miner) Settings() modules.MinerSettings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return modules.MinerSettings{
+		Payouts:      append([]modules.MinerPayout(nil), m.persist.Payouts...),
+		CoinbaseData: m.persist.CoinbaseData,
+	}
+}
+
+// SetSettings changes the payout and coinbase settings of the miner. A new
+// source block is created so that new work uses the settings immediately.
+func (m *Miner) SetSettings(settings modules.MinerSettings) error {
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+	if err := validateSettings(settings); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.persist.Payouts = append([]modules.MinerPayout(nil), settings.Payouts...)
+	m.persist.CoinbaseData = settings.CoinbaseData
+	m.newSourceBlock()
+	return m.saveSync()
+}
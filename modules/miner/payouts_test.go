@@ -0,0 +1,150 @@
+package miner
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// TestValidateSettings checks that invalid miner settings are rejected.
+func TestValidateSettings(t *testing.T) {
+	addr1 := types.UnlockHash{1}
+	addr2 := types.UnlockHash{2}
+	tests := []struct {
+		settings modules.MinerSettings
+		err      error
+	}{
+		{modules.MinerSettings{}, nil},
+		{modules.MinerSettings{Payouts: []modules.MinerPayout{{UnlockHash: addr1, Percentage: 100}}}, nil},
+		{modules.MinerSettings{Payouts: []modules.MinerPayout{{UnlockHash: addr1, Percentage: 30}, {UnlockHash: addr2, Percentage: 70}}}, nil},
+		{modules.MinerSettings{Payouts: []modules.MinerPayout{{UnlockHash: addr1, Percentage: 30}, {UnlockHash: addr2, Percentage: 60}}}, errPayoutPercentageSum},
+		{modules.MinerSettings{Payouts: []modules.MinerPayout{{UnlockHash: addr1, Percentage: 100}, {UnlockHash: addr2, Percentage: 0}}}, errZeroPayoutPercentage},
+		{modules.MinerSettings{Payouts: []modules.MinerPayout{{UnlockHash: addr1, Percentage: 50}, {UnlockHash: addr1, Percentage: 50}}}, errDuplicatePayoutAddress},
+		{modules.MinerSettings{CoinbaseData: string(make([]byte, maxCoinbaseDataSize))}, nil},
+		{modules.MinerSettings{CoinbaseData: string(make([]byte, maxCoinbaseDataSize+1))}, errCoinbaseDataTooLarge},
+	}
+	for i, test := range tests {
+		if err := validateSettings(test.settings); err != test.err {
+			t.Errorf("%v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// checkMinedBlock checks that the payouts and the arbitrary data of a mined
+// block match the miner settings.
+func checkMinedBlock(b types.Block, height types.BlockHeight, settings modules.MinerSettings) error {
+	subsidy := b.CalculateSubsidy(height)
+	if len(b.MinerPayouts) != len(settings.Payouts) {
+		return errors.New("wrong number of miner payouts")
+	}
+	var sum types.Currency
+	for i, payout := range settings.Payouts {
+		mp := b.MinerPayouts[i]
+		if mp.UnlockHash != payout.UnlockHash {
+			return errors.New("miner payout has the wrong address")
+		}
+		// Rounding may only affect the last payout.
+		expected := subsidy.Mul64(payout.Percentage).Div64(100)
+		if i < len(settings.Payouts)-1 && !mp.Value.Equals(expected) {
+			return errors.New("miner payout has the wrong value")
+		}
+		sum = sum.Add(mp.Value)
+	}
+	if !sum.Equals(subsidy) {
+		return errors.New("miner payouts do not add up to the subsidy")
+	}
+	coinbase := append(modules.PrefixNonSia[:], settings.CoinbaseData...)
+	arbData := b.Transactions[0].ArbitraryData
+	if len(arbData) != 2 || !bytes.Equal(arbData[1], coinbase) {
+		return errors.New("block does not contain the coinbase data")
+	}
+	return nil
+}
+
+// TestMinerSettings checks that blocks mined by the miner pay out to the
+// configured addresses and contain the configured coinbase data.
+func TestMinerSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := modules.MinerSettings{
+		Payouts: []modules.MinerPayout{
+			{UnlockHash: types.UnlockHash{1}, Percentage: 33},
+			{UnlockHash: types.UnlockHash{2}, Percentage: 33},
+			{UnlockHash: types.UnlockHash{3}, Percentage: 34},
+		},
+		CoinbaseData: "/mined by test/",
+	}
+	if err := mt.miner.SetSettings(modules.MinerSettings{CoinbaseData: string(make([]byte, maxCoinbaseDataSize+1))}); err != errCoinbaseDataTooLarge {
+		t.Fatal("expected errCoinbaseDataTooLarge, got", err)
+	}
+	if err := mt.miner.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a block through the block manager.
+	header, target, err := mt.miner.HeaderForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.miner.SubmitHeader(solveHeader(header, target)); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkMinedBlock(mt.cs.CurrentBlock(), mt.cs.Height(), settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a block through the test miner.
+	b, err := mt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkMinedBlock(b, mt.cs.Height(), settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// The settings should persist across restarts.
+	if err := mt.miner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(mt.cs, mt.tpool, mt.wallet, filepath.Join(mt.persistDir, modules.MinerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if s := m.Settings(); !reflect.DeepEqual(s, settings) {
+		t.Fatalf("settings were not persisted: %+v", s)
+	}
+	b, err = m.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkMinedBlock(b, mt.cs.Height(), settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Clearing the payouts pays the reward to the wallet again.
+	if err := m.SetSettings(modules.MinerSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err = m.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.MinerPayouts) != 1 || b.MinerPayouts[0].UnlockHash != m.persist.Address {
+		t.Fatal("block reward was not paid to the wallet")
+	}
+	if len(b.Transactions[0].ArbitraryData) != 1 {
+		t.Fatal("block still contains coinbase data")
+	}
+}
@@ -32,6 +32,15 @@ type (
 		Address       types.UnlockHash
 		BlocksFound   []types.BlockID
 		UnsolvedBlock types.Block
+
+		// Payouts and CoinbaseData are set by the operator to control how
+		// blocks are constructed.
+		Payouts      []modules.MinerPayout
+		CoinbaseData string
+
+		// The stratum server is restarted on startup if an address is set.
+		StratumAddress         string
+		StratumShareDifficulty uint64
 	}
 )
 
@@ -0,0 +1,643 @@
+package miner
+
+// stratum.go implements a Stratum-style mining server. Messages are
+// newline-delimited JSON-RPC objects. A worker subscribes with
+// 'mining.subscribe', authorizes with 'mining.authorize', and is then pushed a
+// job with 'mining.notify' whenever the source block of the block manager
+// changes. Each job contains an 80 byte header with a zero nonce and the share
+// target. The worker grinds the nonce and returns it with 'mining.submit'.
+// Shares that also meet the block target are submitted to consensus as full
+// blocks.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// stratumJobMemory is the number of jobs per connection that are
+	// remembered, so that shares for slightly older jobs can still be
+	// matched to their header.
+	stratumJobMemory = 8
+
+	// stratumMaxMessageSize is the largest message that a worker may send.
+	stratumMaxMessageSize = 4096
+
+	// stratumWriteTimeout is the amount of time a worker has to read a
+	// message before the connection is closed.
+	stratumWriteTimeout = 10 * time.Second
+)
+
+// Error codes returned to workers, following the conventions of other Stratum
+// servers.
+const (
+	stratumErrOther         = 20
+	stratumErrStale         = 21
+	stratumErrDuplicate     = 22
+	stratumErrLowDifficulty = 23
+	stratumErrUnauthorized  = 24
+	stratumErrNotSubscribed = 25
+)
+
+var (
+	// stratumHashrateWindow is the window over which the hashrate of a
+	// worker is estimated.
+	stratumHashrateWindow = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      2 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	errStratumNotRunning   = errors.New("stratum server is not running")
+	errStratumRunning      = errors.New("stratum server is already running")
+	errZeroShareDifficulty = errors.New("share difficulty must be greater than zero")
+)
+
+type (
+	// stratumServer is a running Stratum server. Unless noted otherwise, the
+	// fields are protected by the miner's lock.
+	stratumServer struct {
+		listener        net.Listener
+		shareDifficulty uint64
+		conns           map[*stratumConn]struct{}
+		workers         map[string]*stratumWorker
+
+		// lastParent and lastNotify are used to decide whether a change of
+		// the source block is worth pushing to the workers. New parents are
+		// always pushed, other changes at most once every MaxSourceBlockAge.
+		lastParent types.BlockID
+		lastNotify time.Time
+
+		// newWork is signaled whenever the source block changes. closeChan
+		// is closed when the server is stopped.
+		newWork   chan struct{}
+		closeChan chan struct{}
+		closeOnce sync.Once
+	}
+
+	// stratumConn is a single connection to the Stratum server. The
+	// connection may authorize several workers.
+	stratumConn struct {
+		conn    net.Conn
+		writeMu sync.Mutex
+
+		subscribed bool
+		authorized map[string]struct{}
+		difficulty uint64
+		jobs       map[string]*stratumJob
+		jobOrder   []string
+		jobCounter uint64
+	}
+
+	// stratumJob is a header that was pushed to a connection.
+	stratumJob struct {
+		header      types.BlockHeader
+		blockTarget types.Target
+		shareTarget types.Target
+		difficulty  uint64
+		submitted   map[[8]byte]struct{}
+	}
+
+	// stratumWorker tracks the statistics of a worker across connections.
+	stratumWorker struct {
+		modules.StratumWorker
+		shares []stratumShare
+	}
+
+	// stratumShare is an accepted share, used to estimate hashrates.
+	stratumShare struct {
+		time       time.Time
+		difficulty uint64
+	}
+
+	// stratumRequest is a message sent by a worker.
+	stratumRequest struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	// stratumResponse is the reply to a stratumRequest.
+	stratumResponse struct {
+		ID     json.RawMessage `json:"id"`
+		Result interface{}     `json:"result"`
+		Error  interface{}     `json:"error"`
+	}
+
+	// stratumNotification is a message pushed to a worker.
+	stratumNotification struct {
+		ID     interface{}   `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+)
+
+// stratumError creates the error field of a stratumResponse.
+func stratumError(code int, msg string) []interface{} {
+	return []interface{}{code, msg, nil}
+}
+
+// stratumShareTarget returns the target that shares for the given difficulty
+// must meet. Shares are never harder than blocks, so if the block target is
+// easier than the share target, the block target and its difficulty are used
+// instead.
+func stratumShareTarget(difficulty uint64, blockTarget types.Target) (types.Target, uint64) {
+	shareTarget := types.IntToTarget(new(big.Int).Div(types.RootDepth.Int(), new(big.Int).SetUint64(difficulty)))
+	if shareTarget.Cmp(blockTarget) >= 0 {
+		return shareTarget, difficulty
+	}
+	blockDifficulty := blockTarget.Difficulty().Big()
+	if blockDifficulty.IsUint64() && blockDifficulty.Uint64() > 0 {
+		difficulty = blockDifficulty.Uint64()
+	}
+	return blockTarget, difficulty
+}
+
+// hashrate estimates the hashrate of the worker from the shares that were
+// accepted within the hashrate window.
+func (w *stratumWorker) hashrate() uint64 {
+	w.pruneShares()
+	var total float64
+	for _, share := range w.shares {
+		total += float64(share.difficulty)
+	}
+	return uint64(total / stratumHashrateWindow.Seconds())
+}
+
+// pruneShares removes the shares that are older than the hashrate window.
+func (w *stratumWorker) pruneShares() {
+	cutoff := time.Now().Add(-stratumHashrateWindow)
+	i := 0
+	for i < len(w.shares) && w.shares[i].time.Before(cutoff) {
+		i++
+	}
+	w.shares = w.shares[i:]
+}
+
+// write sends a message to the connection.
+func (sc *stratumConn) write(msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	err = sc.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	if err != nil {
+		return err
+	}
+	_, err = sc.conn.Write(append(b, '\n'))
+	return err
+}
+
+// close stops the listener of the server and closes all of its connections.
+// The miner's lock must be held.
+func (s *stratumServer) close() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+		s.listener.Close()
+		for sc := range s.conns {
+			sc.conn.Close()
+		}
+	})
+}
+
+// signalNewWork notifies the Stratum server, if one is running, that the
+// source block has changed.
+func (m *Miner) signalNewWork() {
+	if m.stratum == nil {
+		return
+	}
+	select {
+	case m.stratum.newWork <- struct{}{}:
+	default:
+	}
+}
+
+// stratumJob creates a new job for a connection, returning the notifications
+// that need to be sent to the worker.
+func (m *Miner) stratumJob(s *stratumServer, sc *stratumConn, clean bool) ([]stratumNotification, error) {
+	header, target, err := m.headerForWork()
+	if err != nil {
+		return nil, err
+	}
+	shareTarget, difficulty := stratumShareTarget(s.shareDifficulty, target)
+
+	// Remember the job, forgetting the oldest one if necessary.
+	sc.jobCounter++
+	id := strconv.FormatUint(sc.jobCounter, 16)
+	sc.jobs[id] = &stratumJob{
+		header:      header,
+		blockTarget: target,
+		shareTarget: shareTarget,
+		difficulty:  difficulty,
+		submitted:   make(map[[8]byte]struct{}),
+	}
+	sc.jobOrder = append(sc.jobOrder, id)
+	if len(sc.jobOrder) > stratumJobMemory {
+		delete(sc.jobs, sc.jobOrder[0])
+		sc.jobOrder = sc.jobOrder[1:]
+	}
+
+	var notifications []stratumNotification
+	if difficulty != sc.difficulty {
+		sc.difficulty = difficulty
+		notifications = append(notifications, stratumNotification{
+			Method: "mining.set_difficulty",
+			Params: []interface{}{difficulty},
+		})
+	}
+	notifications = append(notifications, stratumNotification{
+		Method: "mining.notify",
+		Params: []interface{}{id, hex.EncodeToString(encoding.Marshal(header)), hex.EncodeToString(shareTarget[:]), clean},
+	})
+	return notifications, nil
+}
+
+// managedSendStratumJob sends a new job to a single connection.
+func (m *Miner) managedSendStratumJob(s *stratumServer, sc *stratumConn) error {
+	m.mu.Lock()
+	notifications, err := m.stratumJob(s, sc, true)
+	m.mu.Unlock()
+	if err != nil {
+		m.log.Println("WARN: unable to create stratum job:", err)
+		return nil
+	}
+	for _, n := range notifications {
+		err := sc.write(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedStratumBroadcast pushes new jobs to all subscribed and authorized
+// connections if the source block has changed in a meaningful way.
+func (m *Miner) managedStratumBroadcast(s *stratumServer) {
+	type pendingJob struct {
+		sc            *stratumConn
+		notifications []stratumNotification
+	}
+	var pending []pendingJob
+
+	m.mu.Lock()
+	parent := m.persist.UnsolvedBlock.ParentID
+	clean := parent != s.lastParent
+	if !clean && time.Since(s.lastNotify) < MaxSourceBlockAge {
+		m.mu.Unlock()
+		return
+	}
+	s.lastParent = parent
+	s.lastNotify = time.Now()
+	for sc := range s.conns {
+		if !sc.subscribed || len(sc.authorized) == 0 {
+			continue
+		}
+		notifications, err := m.stratumJob(s, sc, clean)
+		if err != nil {
+			m.log.Println("WARN: unable to create stratum job:", err)
+			break
+		}
+		pending = append(pending, pendingJob{sc, notifications})
+	}
+	m.mu.Unlock()
+
+	for _, p := range pending {
+		for _, n := range p.notifications {
+			err := p.sc.write(n)
+			if err != nil {
+				p.sc.conn.Close()
+				break
+			}
+		}
+	}
+}
+
+// managedStratumSubmit handles a share submitted by a worker. Full solutions
+// are submitted to consensus.
+func (m *Miner) managedStratumSubmit(s *stratumServer, sc *stratumConn, params []json.RawMessage) (interface{}, []interface{}) {
+	// Parse the worker name, job id and nonce.
+	if len(params) < 3 {
+		return nil, stratumError(stratumErrOther, "mining.submit requires a worker, a job id and a nonce")
+	}
+	var name, jobID, nonceHex string
+	for i, p := range []*string{&name, &jobID, &nonceHex} {
+		if err := json.Unmarshal(params[i], p); err != nil {
+			return nil, stratumError(stratumErrOther, "malformed mining.submit parameters")
+		}
+	}
+	nonceBytes, err := hex.DecodeString(nonceHex)
+	if err != nil || len(nonceBytes) != 8 {
+		return nil, stratumError(stratumErrOther, "nonce must be 8 hex-encoded bytes")
+	}
+	var nonce [8]byte
+	copy(nonce[:], nonceBytes)
+
+	m.mu.Lock()
+	if _, ok := sc.authorized[name]; !ok {
+		m.mu.Unlock()
+		return nil, stratumError(stratumErrUnauthorized, "worker is not authorized")
+	}
+	w := s.workers[name]
+
+	// Shares for unknown jobs or jobs that build on an old parent are stale.
+	job, exists := sc.jobs[jobID]
+	if !exists || job.header.ParentID != m.persist.UnsolvedBlock.ParentID {
+		w.StaleShares++
+		m.mu.Unlock()
+		return nil, stratumError(stratumErrStale, "job not found")
+	}
+	if _, dup := job.submitted[nonce]; dup {
+		w.RejectedShares++
+		m.mu.Unlock()
+		return nil, stratumError(stratumErrDuplicate, "duplicate share")
+	}
+	job.submitted[nonce] = struct{}{}
+	if m.persist.Height+1 >= types.ASICHardforkHeight && binary.LittleEndian.Uint64(nonce[:])%types.ASICHardforkFactor != 0 {
+		w.RejectedShares++
+		m.mu.Unlock()
+		return nil, stratumError(stratumErrOther, "nonce is not a multiple of the ASIC hardfork factor")
+	}
+
+	// Check the share against the share target and the block target.
+	header := job.header
+	header.Nonce = nonce
+	id := header.ID()
+	if bytes.Compare(job.shareTarget[:], id[:]) < 0 {
+		w.RejectedShares++
+		m.mu.Unlock()
+		return nil, stratumError(stratumErrLowDifficulty, "low difficulty share")
+	}
+	w.AcceptedShares++
+	w.LastShare = types.CurrentTimestamp()
+	w.shares = append(w.shares, stratumShare{time: time.Now(), difficulty: job.difficulty})
+	w.pruneShares()
+	if bytes.Compare(job.blockTarget[:], id[:]) < 0 {
+		m.mu.Unlock()
+		return true, nil
+	}
+	b, err := m.blockForHeader(header)
+	m.mu.Unlock()
+	if err != nil {
+		m.log.Println("WARN: solved block submitted over stratum could not be recovered:", err)
+		return true, nil
+	}
+
+	err = m.managedSubmitBlock(b)
+	if err != nil {
+		m.log.Println("ERROR: block submitted over stratum was rejected:", err)
+		return true, nil
+	}
+	m.mu.Lock()
+	w.BlocksFound++
+	m.mu.Unlock()
+	return true, nil
+}
+
+// managedHandleStratumRequest responds to a single request. An error is only
+// returned if the connection should be closed.
+func (m *Miner) managedHandleStratumRequest(s *stratumServer, sc *stratumConn, req stratumRequest) error {
+	resp := stratumResponse{ID: req.ID}
+	sendJob := false
+	switch req.Method {
+	case "mining.subscribe":
+		m.mu.Lock()
+		sc.subscribed = true
+		sendJob = len(sc.authorized) > 0
+		m.mu.Unlock()
+		resp.Result = []interface{}{hex.EncodeToString(fastrand.Bytes(8)), s.shareDifficulty}
+
+	case "mining.authorize":
+		var name string
+		if len(req.Params) == 0 || json.Unmarshal(req.Params[0], &name) != nil || name == "" {
+			resp.Error = stratumError(stratumErrOther, "mining.authorize requires a worker name")
+			break
+		}
+		m.mu.Lock()
+		sendJob = sc.subscribed && len(sc.authorized) == 0
+		sc.authorized[name] = struct{}{}
+		if _, exists := s.workers[name]; !exists {
+			s.workers[name] = &stratumWorker{StratumWorker: modules.StratumWorker{Name: name}}
+		}
+		m.mu.Unlock()
+		resp.Result = true
+
+	case "mining.submit":
+		m.mu.RLock()
+		subscribed := sc.subscribed
+		m.mu.RUnlock()
+		if !subscribed {
+			resp.Error = stratumError(stratumErrNotSubscribed, "not subscribed")
+			break
+		}
+		resp.Result, resp.Error = m.managedStratumSubmit(s, sc, req.Params)
+
+	default:
+		resp.Error = stratumError(stratumErrOther, "unknown method "+req.Method)
+	}
+	if resp.Result == nil && resp.Error == nil {
+		resp.Result = false
+	}
+
+	err := sc.write(resp)
+	if err != nil {
+		return err
+	}
+	if sendJob {
+		return m.managedSendStratumJob(s, sc)
+	}
+	return nil
+}
+
+// threadedHandleStratumConn reads and handles the requests of a single
+// connection until it is closed.
+func (m *Miner) threadedHandleStratumConn(s *stratumServer, conn net.Conn) {
+	if err := m.tg.Add(); err != nil {
+		conn.Close()
+		return
+	}
+	defer m.tg.Done()
+
+	sc := &stratumConn{
+		conn:       conn,
+		authorized: make(map[string]struct{}),
+		jobs:       make(map[string]*stratumJob),
+	}
+	m.mu.Lock()
+	select {
+	case <-s.closeChan:
+		m.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	s.conns[sc] = struct{}{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(s.conns, sc)
+		m.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, stratumMaxMessageSize), stratumMaxMessageSize)
+	for scanner.Scan() {
+		var req stratumRequest
+		err := json.Unmarshal(scanner.Bytes(), &req)
+		if err != nil {
+			sc.write(stratumResponse{Error: stratumError(stratumErrOther, "malformed request")})
+			return
+		}
+		err = m.managedHandleStratumRequest(s, sc, req)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// threadedStratumListen accepts connections until the listener is closed.
+func (m *Miner) threadedStratumListen(s *stratumServer) {
+	if err := m.tg.Add(); err != nil {
+		return
+	}
+	defer m.tg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go m.threadedHandleStratumConn(s, conn)
+	}
+}
+
+// threadedStratumNotify pushes new work to the workers when the source block
+// changes, and periodically so that new transactions are picked up.
+func (m *Miner) threadedStratumNotify(s *stratumServer) {
+	if err := m.tg.Add(); err != nil {
+		return
+	}
+	defer m.tg.Done()
+
+	for {
+		select {
+		case <-s.newWork:
+		case <-time.After(MaxSourceBlockAge):
+		case <-s.closeChan:
+			return
+		case <-m.tg.StopChan():
+			return
+		}
+		m.managedStratumBroadcast(s)
+	}
+}
+
+// StartStratum starts a Stratum server on the provided address. Shares are
+// accepted at the provided difficulty. The server is restarted automatically
+// when the miner is loaded until StopStratum is called.
+func (m *Miner) StartStratum(addr string, shareDifficulty uint64) error {
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+	if shareDifficulty == 0 {
+		return errZeroShareDifficulty
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stratum != nil {
+		return errStratumRunning
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := &stratumServer{
+		listener:        l,
+		shareDifficulty: shareDifficulty,
+		conns:           make(map[*stratumConn]struct{}),
+		workers:         make(map[string]*stratumWorker),
+		lastParent:      m.persist.UnsolvedBlock.ParentID,
+		lastNotify:      time.Now(),
+		newWork:         make(chan struct{}, 1),
+		closeChan:       make(chan struct{}),
+	}
+	m.stratum = s
+	go m.threadedStratumListen(s)
+	go m.threadedStratumNotify(s)
+
+	m.persist.StratumAddress = addr
+	m.persist.StratumShareDifficulty = shareDifficulty
+	return m.saveSync()
+}
+
+// StopStratum stops the Stratum server and closes all of its connections.
+func (m *Miner) StopStratum() error {
+	if err := m.tg.Add(); err != nil {
+		return err
+	}
+	defer m.tg.Done()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stratum == nil {
+		return errStratumNotRunning
+	}
+	m.stratum.close()
+	m.stratum = nil
+
+	m.persist.StratumAddress = ""
+	m.persist.StratumShareDifficulty = 0
+	return m.saveSync()
+}
+
+// StratumStatus returns the state of the Stratum server and the statistics of
+// the workers that have authorized with it.
+func (m *Miner) StratumStatus() modules.StratumStatus {
+	if err := m.tg.Add(); err != nil {
+		return modules.StratumStatus{}
+	}
+	defer m.tg.Done()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stratum
+	if s == nil {
+		return modules.StratumStatus{}
+	}
+	status := modules.StratumStatus{
+		Running:         true,
+		Address:         s.listener.Addr().String(),
+		ShareDifficulty: s.shareDifficulty,
+		Connections:     len(s.conns),
+	}
+	for _, w := range s.workers {
+		sw := w.StratumWorker
+		sw.Hashrate = w.hashrate()
+		status.Workers = append(status.Workers, sw)
+	}
+	sort.Slice(status.Workers, func(i, j int) bool {
+		return status.Workers[i].Name < status.Workers[j].Name
+	})
+	return status
+}
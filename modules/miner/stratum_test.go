@@ -0,0 +1,278 @@
+package miner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// stratumTestMessage is any message received from the stratum server.
+type stratumTestMessage struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  []interface{}     `json:"error"`
+}
+
+// stratumTestClient is a minimal stratum worker.
+type stratumTestClient struct {
+	conn          net.Conn
+	r             *bufio.Reader
+	nextID        uint64
+	notifications []stratumTestMessage
+}
+
+// call sends a request and waits for its response, queueing any
+// notifications that arrive in the meantime.
+func (c *stratumTestClient) call(t *testing.T, method string, params ...interface{}) stratumTestMessage {
+	c.nextID++
+	b, _ := json.Marshal(map[string]interface{}{"id": c.nextID, "method": method, "params": params})
+	_, err := c.conn.Write(append(b, '\n'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		msg := c.read(t)
+		if msg.ID != nil && *msg.ID == c.nextID {
+			return msg
+		}
+		c.notifications = append(c.notifications, msg)
+	}
+}
+
+// read reads the next message from the server.
+func (c *stratumTestClient) read(t *testing.T) stratumTestMessage {
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg stratumTestMessage
+	err = json.Unmarshal(line, &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// nextJob returns the next 'mining.notify' notification.
+func (c *stratumTestClient) nextJob(t *testing.T) (id string, header []byte, target types.Target, clean bool) {
+	for {
+		var msg stratumTestMessage
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.read(t)
+		}
+		if msg.Method != "mining.notify" {
+			continue
+		}
+		var headerHex, targetHex string
+		json.Unmarshal(msg.Params[0], &id)
+		json.Unmarshal(msg.Params[1], &headerHex)
+		json.Unmarshal(msg.Params[2], &targetHex)
+		json.Unmarshal(msg.Params[3], &clean)
+		header, _ = hex.DecodeString(headerHex)
+		targetBytes, _ := hex.DecodeString(targetHex)
+		copy(target[:], targetBytes)
+		return
+	}
+}
+
+// TestStratum checks that a worker can mine blocks through the stratum
+// server, and that shares are accounted for correctly.
+func TestStratum(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.miner.Close()
+
+	if err := mt.miner.StartStratum("localhost:0", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.miner.StartStratum("localhost:0", 1); err != errStratumRunning {
+		t.Fatal("expected errStratumRunning, got", err)
+	}
+	status := mt.miner.StratumStatus()
+	if !status.Running || status.ShareDifficulty != 1 {
+		t.Fatal("unexpected stratum status:", status)
+	}
+
+	conn, err := net.Dial("tcp", status.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &stratumTestClient{conn: conn, r: bufio.NewReader(conn)}
+
+	// Shares cannot be submitted before subscribing.
+	if resp := c.call(t, "mining.submit", "worker", "1", "0000000000000000"); resp.Error == nil {
+		t.Fatal("share was accepted before subscribing")
+	}
+	if resp := c.call(t, "mining.subscribe", "test"); resp.Error != nil {
+		t.Fatal("subscribe failed:", resp.Error)
+	}
+	if resp := c.call(t, "mining.authorize", "worker", "x"); string(resp.Result) != "true" {
+		t.Fatal("authorize failed:", resp.Error)
+	}
+
+	// Grind the first job until it solves a block.
+	startHeight := mt.cs.Height()
+	jobID, header, target, _ := c.nextJob(t)
+	if len(header) != 80 {
+		t.Fatal("header has the wrong length:", len(header))
+	}
+	var shares uint64
+	for nonce := uint64(0); mt.cs.Height() == startHeight; nonce += types.ASICHardforkFactor {
+		binary.LittleEndian.PutUint64(header[32:40], nonce)
+		id := crypto.HashBytes(header)
+		if bytes.Compare(target[:], id[:]) < 0 {
+			continue
+		}
+		resp := c.call(t, "mining.submit", "worker", jobID, hex.EncodeToString(header[32:40]))
+		if resp.Error != nil {
+			t.Fatal("share was rejected:", resp.Error)
+		}
+		shares++
+		if shares > 1000 {
+			t.Fatal("no block was found")
+		}
+	}
+
+	// The same share is now stale, because the parent has changed.
+	resp := c.call(t, "mining.submit", "worker", jobID, hex.EncodeToString(header[32:40]))
+	if resp.Error == nil || resp.Error[0].(float64) != stratumErrStale {
+		t.Fatal("expected a stale share, got", resp.Error)
+	}
+
+	// The new block should cause a clean job to be pushed.
+	for {
+		newJobID, newHeader, _, clean := c.nextJob(t)
+		currentID := mt.cs.CurrentBlock().ID()
+		if clean && bytes.Equal(newHeader[:32], currentID[:]) {
+			// Submitting the same share twice is rejected as a duplicate. A
+			// share that does not solve a block is used so that the job does
+			// not become stale.
+			blockTarget, _ := mt.cs.ChildTarget(currentID)
+			var nonce uint64
+			for ; ; nonce += types.ASICHardforkFactor {
+				binary.LittleEndian.PutUint64(newHeader[32:40], nonce)
+				id := crypto.HashBytes(newHeader)
+				if bytes.Compare(blockTarget[:], id[:]) < 0 {
+					break
+				}
+			}
+			nonceHex := hex.EncodeToString(newHeader[32:40])
+			if resp := c.call(t, "mining.submit", "worker", newJobID, nonceHex); resp.Error != nil {
+				t.Fatal("share was rejected:", resp.Error)
+			}
+			resp := c.call(t, "mining.submit", "worker", newJobID, nonceHex)
+			if resp.Error == nil || resp.Error[0].(float64) != stratumErrDuplicate {
+				t.Fatal("expected a duplicate share, got", resp.Error)
+			}
+			break
+		}
+	}
+
+	// Shares for unauthorized workers are rejected.
+	resp = c.call(t, "mining.submit", "other", jobID, "0000000000000000")
+	if resp.Error == nil || resp.Error[0].(float64) != stratumErrUnauthorized {
+		t.Fatal("expected an unauthorized share, got", resp.Error)
+	}
+
+	status = mt.miner.StratumStatus()
+	if status.Connections != 1 || len(status.Workers) != 1 {
+		t.Fatal("unexpected stratum status:", status)
+	}
+	w := status.Workers[0]
+	if w.Name != "worker" || w.BlocksFound != 1 || w.StaleShares != 1 || w.RejectedShares != 1 {
+		t.Fatalf("unexpected worker statistics: %+v", w)
+	}
+	if w.AcceptedShares != shares+1 {
+		t.Fatalf("unexpected worker statistics: %+v", w)
+	}
+
+	// Stopping the server closes the connection.
+	if err := mt.miner.StopStratum(); err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.StratumStatus().Running {
+		t.Fatal("stratum server is still running")
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		_, err := c.r.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+	}
+	if err := mt.miner.StopStratum(); err != errStratumNotRunning {
+		t.Fatal("expected errStratumNotRunning, got", err)
+	}
+}
+
+// TestStratumWorkerHashrate checks that worker hashrates are estimated from the
+// shares within the hashrate window.
+func TestStratumWorkerHashrate(t *testing.T) {
+	window := uint64(stratumHashrateWindow.Seconds())
+	w := &stratumWorker{
+		shares: []stratumShare{
+			{time: time.Now().Add(-2 * stratumHashrateWindow), difficulty: 1e6 * window},
+			{time: time.Now(), difficulty: 300 * window},
+			{time: time.Now(), difficulty: 200 * window},
+		},
+	}
+	if hr := w.hashrate(); hr != 500 {
+		t.Fatal("expected a hashrate of 500, got", hr)
+	}
+	if len(w.shares) != 2 {
+		t.Fatal("old shares were not pruned")
+	}
+}
+
+// TestStratumCloseMiner checks that the stratum server that is running when the
+// miner is closed is shut down, even after it was restarted.
+func TestStratumCloseMiner(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := mt.miner.StartStratum("localhost:0", 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := mt.miner.StopStratum(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mt.miner.StartStratum("localhost:0", 1); err != nil {
+		t.Fatal(err)
+	}
+	addr := mt.miner.StratumStatus().Address
+	if err := mt.miner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if mt.miner.stratum != nil {
+		t.Fatal("stratum server wasn't closed")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Fatal("stratum server is still listening")
+	}
+}
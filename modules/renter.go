@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -123,6 +124,22 @@ const (
 	// estimated blockchain size of a transaction set used by the host to
 	// provide the storage proof at the end of the contract duration.
 	EstimatedFileContractRevisionAndProofTransactionSetSize = 5000
+
+	// ArchiveFormatTar is the archive format for directory downloads that
+	// produces a tar archive.
+	ArchiveFormatTar = "tar"
+
+	// ArchiveFormatZip is the archive format for directory downloads that
+	// produces a zip archive.
+	ArchiveFormatZip = "zip"
+
+	// UserMetadataContentType is the user metadata key of the MIME type of a
+	// file. It is used as the Content-Type when the file is streamed.
+	UserMetadataContentType = "content-type"
+
+	// UserMetadataTags is the user metadata key of the tags of a file. Tags
+	// are separated by commas.
+	UserMetadataTags = "tags"
 )
 
 type (
@@ -189,6 +206,41 @@ type Allowance struct {
 
 	// ExpectedRedundancy is the average redundancy of files being uploaded.
 	ExpectedRedundancy float64 `json:"expectedredundancy"`
+
+	// The following fields are optional price caps. Hosts whose prices
+	// exceed one of the caps are not selected for new contracts and existing
+	// contracts with such hosts are not renewed. A zero value means that the
+	// price isn't capped. The caps use the same units as the prices in
+	// HostExternalSettings.
+	MaxRPCPrice               types.Currency `json:"maxrpcprice"`
+	MaxContractPrice          types.Currency `json:"maxcontractprice"`
+	MaxDownloadBandwidthPrice types.Currency `json:"maxdownloadbandwidthprice"`
+	MaxSectorAccessPrice      types.Currency `json:"maxsectoraccessprice"`
+	MaxStoragePrice           types.Currency `json:"maxstorageprice"`
+	MaxUploadBandwidthPrice   types.Currency `json:"maxuploadbandwidthprice"`
+}
+
+// CheckPriceCaps returns an error if one of the host's prices exceeds the
+// corresponding price cap of the allowance.
+func CheckPriceCaps(a Allowance, host HostDBEntry) error {
+	caps := []struct {
+		name  string
+		price types.Currency
+		max   types.Currency
+	}{
+		{"base RPC price", host.BaseRPCPrice, a.MaxRPCPrice},
+		{"contract price", host.ContractPrice, a.MaxContractPrice},
+		{"download bandwidth price", host.DownloadBandwidthPrice, a.MaxDownloadBandwidthPrice},
+		{"sector access price", host.SectorAccessPrice, a.MaxSectorAccessPrice},
+		{"storage price", host.StoragePrice, a.MaxStoragePrice},
+		{"upload bandwidth price", host.UploadBandwidthPrice, a.MaxUploadBandwidthPrice},
+	}
+	for _, pc := range caps {
+		if !pc.max.IsZero() && pc.price.Cmp(pc.max) > 0 {
+			return fmt.Errorf("%v %v exceeds the allowance's maximum of %v", pc.name, pc.price, pc.max)
+		}
+	}
+	return nil
 }
 
 // ContractUtility contains metrics internal to the contractor that reflect the
@@ -259,6 +311,15 @@ type FileUploadParams struct {
 	ErasureCode ErasureCoder
 	Force       bool
 	Repair      bool
+
+	// Pack indicates that a small file should be packed into a chunk that is
+	// shared with other small files instead of being uploaded to its own
+	// chunk.
+	Pack bool
+
+	// UserMetadata holds arbitrary key/value pairs that are stored in the
+	// siafile, see UserMetadataContentType and UserMetadataTags.
+	UserMetadata map[string]string
 }
 
 // FileInfo provides information about a file.
@@ -266,6 +327,7 @@ type FileInfo struct {
 	AccessTime       time.Time         `json:"accesstime"`
 	Available        bool              `json:"available"`
 	ChangeTime       time.Time         `json:"changetime"`
+	Checksum         string            `json:"checksum"`
 	CipherType       string            `json:"ciphertype"`
 	CreateTime       time.Time         `json:"createtime"`
 	Expiration       types.BlockHeight `json:"expiration"`
@@ -277,6 +339,7 @@ type FileInfo struct {
 	ModTime          time.Time         `json:"modtime"`
 	NumStuckChunks   uint64            `json:"numstuckchunks"`
 	OnDisk           bool              `json:"ondisk"`
+	Packed           bool              `json:"packed"`
 	Recoverable      bool              `json:"recoverable"`
 	Redundancy       float64           `json:"redundancy"`
 	Renewing         bool              `json:"renewing"`
@@ -285,6 +348,18 @@ type FileInfo struct {
 	StuckHealth      float64           `json:"stuckhealth"`
 	UploadedBytes    uint64            `json:"uploadedbytes"`
 	UploadProgress   float64           `json:"uploadprogress"`
+	UserMetadata     map[string]string `json:"usermetadata"`
+}
+
+// FileTags returns the tags stored in the user metadata of a file.
+func FileTags(userMetadata map[string]string) []string {
+	var tags []string
+	for _, tag := range strings.Split(userMetadata[UserMetadataTags], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
 }
 
 // A HostDBEntry represents one host entry in the Renter's host DB. It
@@ -374,6 +449,7 @@ type RenterSettings struct {
 	IPViolationsCheck bool      `json:"ipviolationcheck"`
 	MaxUploadSpeed    int64     `json:"maxuploadspeed"`
 	MaxDownloadSpeed  int64     `json:"maxdownloadspeed"`
+	ChunkCacheSize    uint64    `json:"chunkcachesize"`
 }
 
 // HostDBScans represents a sortable slice of scans.
@@ -527,6 +603,96 @@ type UploadedBackup struct {
 	UploadProgress float64
 }
 
+// SyncDirInfo contains information about a local directory that the renter
+// keeps in sync with a siapath. New and modified files are uploaded, and files
+// that are deleted locally are deleted from the renter.
+type SyncDirInfo struct {
+	LocalPath    string    `json:"localpath"`
+	SiaPath      SiaPath   `json:"siapath"`
+	Files        uint64    `json:"files"`
+	PendingFiles uint64    `json:"pendingfiles"`
+	Uploads      uint64    `json:"uploads"`
+	Deletions    uint64    `json:"deletions"`
+	LastSync     time.Time `json:"lastsync"`
+	LastError    string    `json:"lasterror"`
+}
+
+// FileVersion contains information about an old version of a file that was
+// overwritten.
+type FileVersion struct {
+	ID            uint64    `json:"id"`
+	SiaPath       SiaPath   `json:"siapath"`
+	Created       time.Time `json:"created"`
+	Filesize      uint64    `json:"filesize"`
+	UploadedBytes uint64    `json:"uploadedbytes"`
+	Redundancy    float64   `json:"redundancy"`
+}
+
+// VersionPolicy determines which old versions of the files in a directory are
+// kept. Versions beyond the newest MaxVersions versions and versions that were
+// overwritten more than MaxAge ago are deleted. A MaxVersions of 0 disables
+// versioning and a MaxAge of 0 keeps versions regardless of their age.
+type VersionPolicy struct {
+	MaxVersions uint64        `json:"maxversions"`
+	MaxAge      time.Duration `json:"maxage"`
+}
+
+// VersionPolicyInfo contains the version policy of a directory. The policy
+// applies to the directory and all of its subdirectories that don't have a
+// policy of their own.
+type VersionPolicyInfo struct {
+	SiaPath SiaPath `json:"siapath"`
+	VersionPolicy
+}
+
+// VersionStorage summarizes the storage used by old file versions.
+type VersionStorage struct {
+	NumVersions   uint64 `json:"numversions"`
+	Size          uint64 `json:"size"`
+	UploadedBytes uint64 `json:"uploadedbytes"`
+}
+
+// ChunkCacheStats reports the usage of the renter's on-disk cache of
+// downloaded chunks. A MaxSize of 0 means that the cache is disabled.
+type ChunkCacheStats struct {
+	Chunks  uint64 `json:"chunks"`
+	Size    uint64 `json:"size"`
+	MaxSize uint64 `json:"maxsize"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// TrashEntry contains information about a deleted file or directory in the
+// trash. The entry is purged once the Expires time has passed.
+type TrashEntry struct {
+	ID       uint64    `json:"id"`
+	SiaPath  SiaPath   `json:"siapath"`
+	IsDir    bool      `json:"isdir"`
+	Deleted  time.Time `json:"deleted"`
+	Expires  time.Time `json:"expires"`
+	NumFiles uint64    `json:"numfiles"`
+	Size     uint64    `json:"size"`
+}
+
+// HostDrainInfo contains the progress of migrating all pieces away from a
+// host. Once every piece was migrated, the contract with the host is
+// canceled.
+type HostDrainInfo struct {
+	HostPublicKey    types.SiaPublicKey `json:"hostpublickey"`
+	Started          time.Time          `json:"started"`
+	Finished         bool               `json:"finished"`
+	ContractCanceled bool               `json:"contractcanceled"`
+	Error            string             `json:"error"`
+
+	// Pieces is the number of pieces that were stored on the host when the
+	// drain was started. MigratedPieces and FailedPieces are the number of
+	// those pieces that were migrated to other hosts or that couldn't be
+	// migrated.
+	Pieces         uint64 `json:"pieces"`
+	MigratedPieces uint64 `json:"migratedpieces"`
+	FailedPieces   uint64 `json:"failedpieces"`
+}
+
 // A Renter uploads, tracks, repairs, and downloads a set of files for the
 // user.
 type Renter interface {
@@ -593,6 +759,16 @@ type Renter interface {
 	// SetFileStuck sets the 'stuck' status of a file.
 	SetFileStuck(siaPath SiaPath, stuck bool) error
 
+	// SetFileUserMetadata replaces the user metadata of a file.
+	SetFileUserMetadata(siaPath SiaPath, userMetadata map[string]string) error
+
+	// FileUserMetadata returns the user metadata of a file.
+	FileUserMetadata(siaPath SiaPath) (map[string]string, error)
+
+	// FindFiles returns the files whose user metadata contains the tag and
+	// all of the key/value pairs of query. An empty tag matches every file.
+	FindFiles(tag string, query map[string]string) ([]FileInfo, error)
+
 	// UploadBackup uploads a backup to hosts, such that it can be retrieved
 	// using only the seed.
 	UploadBackup(src string, name string) error
@@ -607,6 +783,9 @@ type Renter interface {
 	// BackupsOnHost returns the backups stored on the specified host.
 	BackupsOnHost(hostKey types.SiaPublicKey) ([]UploadedBackup, error)
 
+	// CopyFile creates a copy of a file without reuploading its data.
+	CopyFile(src, dst SiaPath) error
+
 	// DeleteFile deletes a file entry from the renter.
 	DeleteFile(siaPath SiaPath) error
 
@@ -614,6 +793,10 @@ type Renter interface {
 	// downloads of `offset` and `length` type.
 	Download(params RenterDownloadParameters) error
 
+	// DownloadArchive downloads the files of the directory at siaPath and its
+	// subdirectories and writes them to w as an archive of the given format.
+	DownloadArchive(w io.Writer, siaPath SiaPath, format string) error
+
 	// Download performs a download according to the parameters passed without
 	// blocking, including downloads of `offset` and `length` type.
 	DownloadAsync(params RenterDownloadParameters, onComplete func(error) error) (cancel func(), err error)
@@ -686,6 +869,81 @@ type Renter interface {
 	// upload the data to the Sia network.
 	UploadStreamFromReader(up FileUploadParams, reader io.Reader) error
 
+	// AddSyncDir links a local directory to a siapath. The renter will keep
+	// the siapath in sync with the contents of the directory.
+	AddSyncDir(localPath string, siaPath SiaPath) error
+
+	// RemoveSyncDir stops syncing the local directory that is linked to the
+	// siapath. Files that were already uploaded are not deleted.
+	RemoveSyncDir(siaPath SiaPath) error
+
+	// SyncDirs returns the local directories that are synced by the renter.
+	SyncDirs() []SyncDirInfo
+
+	// ShareFiles writes the siafiles at the provided siapaths to w, so that
+	// they can be loaded by another renter. If a password is provided, the
+	// share is encrypted.
+	ShareFiles(w io.Writer, siaPaths []SiaPath, password string) error
+
+	// LoadSharedFiles loads the siafiles of a share into the renter and
+	// returns their siapaths.
+	LoadSharedFiles(r io.Reader, password string) ([]SiaPath, error)
+
+	// FileVersions returns the old versions of the file at siaPath, oldest
+	// first.
+	FileVersions(siaPath SiaPath) ([]FileVersion, error)
+
+	// RestoreFileVersion restores an old version of the file at siaPath. The
+	// current version of the file becomes an old version itself.
+	RestoreFileVersion(siaPath SiaPath, id uint64) error
+
+	// SetVersionPolicy sets the version policy of the directory at siaPath.
+	SetVersionPolicy(siaPath SiaPath, policy VersionPolicy) error
+
+	// RemoveVersionPolicy removes the version policy of the directory at
+	// siaPath, so that the policy of its parent directory applies again.
+	RemoveVersionPolicy(siaPath SiaPath) error
+
+	// VersionPolicies returns the version policies of the renter. The policy
+	// of the root directory is always included.
+	VersionPolicies() []VersionPolicyInfo
+
+	// VersionStorage returns the storage used by old file versions.
+	VersionStorage() VersionStorage
+
+	// ChunkCacheStats returns the usage statistics of the chunk cache.
+	ChunkCacheStats() ChunkCacheStats
+
+	// Trash returns the entries of the trash, oldest first.
+	Trash() []TrashEntry
+
+	// RestoreTrashEntry moves a deleted file or directory back from the trash
+	// to its original siapath.
+	RestoreTrashEntry(id uint64) error
+
+	// PurgeTrashEntry deletes an entry of the trash from the network.
+	PurgeTrashEntry(id uint64) error
+
+	// PurgeTrash deletes all entries of the trash from the network.
+	PurgeTrash() error
+
+	// TrashRetention returns how long deleted files and directories are kept
+	// in the trash. A retention of 0 means that the trash is disabled.
+	TrashRetention() time.Duration
+
+	// SetTrashRetention sets how long deleted files and directories are kept
+	// in the trash. A retention of 0 disables the trash.
+	SetTrashRetention(retention time.Duration) error
+
+	// DrainHost migrates all pieces stored on the host to other hosts and
+	// cancels the contract with the host once it is done. The drain runs in
+	// the background.
+	DrainHost(hostKey types.SiaPublicKey) error
+
+	// HostDrains returns the progress of the drains started since the renter
+	// was started.
+	HostDrains() []HostDrainInfo
+
 	// CreateDir creates a directory for the renter
 	CreateDir(siaPath SiaPath) error
 
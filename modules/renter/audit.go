@@ -0,0 +1,213 @@
+package renter
+
+// audit.go proactively audits the hosts that store the renter's data. Instead
+// of waiting for a download or repair to fail, the renter periodically picks
+// random pieces of random siafiles and downloads a single segment of each
+// piece together with a Merkle proof. The proof is verified against the
+// Merkle root stored in the siafile by proto.Session.Read.
+//
+// Every read is recorded as an interaction with the host by the proto
+// package. A piece is only marked as lost if the host sends data which doesn't
+// match the stored root, in which case it is penalized further, or if the host
+// replies that it doesn't store the sector. Other failures, such as timeouts
+// or dropped connections, only affect the score of the host. Once a piece is
+// removed from the siafile, the health of the file drops and the repair loop
+// uploads the piece to a different host.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/proto"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// auditBadProofPenalty is the number of additional failed interactions
+	// that are recorded for a host that sent data which doesn't match the
+	// Merkle root of the piece.
+	auditBadProofPenalty = 10
+
+	// hostSectorNotFoundErr is the description of the error that a host
+	// returns when it doesn't store a requested sector.
+	hostSectorNotFoundErr = "could not find the desired sector"
+)
+
+var (
+	// auditInterval is the amount of time between two audit rounds.
+	auditInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 30 * time.Minute,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// auditPiecesPerRound is the number of pieces that are audited in each
+	// audit round.
+	auditPiecesPerRound = build.Select(build.Var{
+		Dev:      10,
+		Standard: 50,
+		Testing:  5,
+	}).(int)
+)
+
+// allSiaPaths returns the siapaths of all the siafiles of the renter.
+func (r *Renter) allSiaPaths() ([]modules.SiaPath, error) {
+	var siaPaths []modules.SiaPath
+	err := filepath.Walk(r.staticFilesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != modules.SiaFileExtension {
+			return nil
+		}
+		relPath, err := filepath.Rel(r.staticFilesDir, path)
+		if err != nil {
+			return err
+		}
+		siaPath, err := modules.NewSiaPath(strings.TrimSuffix(filepath.ToSlash(relPath), modules.SiaFileExtension))
+		if err != nil {
+			return err
+		}
+		siaPaths = append(siaPaths, siaPath)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return siaPaths, err
+}
+
+// managedAuditPiece downloads a random segment of the piece with the given
+// root from the host and verifies it. The returned bool indicates whether the
+// piece should be considered lost.
+func (r *Renter) managedAuditPiece(pk types.SiaPublicKey, root crypto.Hash) (bool, error) {
+	// Only audit hosts that we have a contract with and that are online.
+	// Offline hosts are already accounted for by the health of the file.
+	if _, ok := r.hostContractor.ContractByPublicKey(pk); !ok || r.hostContractor.IsOffline(pk) {
+		return false, nil
+	}
+	session, err := r.hostContractor.Session(pk, r.tg.StopChan())
+	if err != nil {
+		return false, errors.AddContext(err, "unable to create session")
+	}
+	defer session.Close()
+
+	// Download a random segment. The session always requests a Merkle proof
+	// and verifies it against the root.
+	segment := fastrand.Uint64n(modules.SectorSize / crypto.SegmentSize)
+	_, err = session.Download(root, uint32(segment*crypto.SegmentSize), crypto.SegmentSize)
+	if errors.Contains(err, proto.ErrBadSectorData) {
+		for i := 0; i < auditBadProofPenalty; i++ {
+			r.hostDB.IncrementFailedInteractions(pk)
+		}
+		return true, err
+	}
+	return isSectorNotFoundErr(err), err
+}
+
+// isSectorNotFoundErr returns true if err is the reply of a host that doesn't
+// store the requested sector.
+func isSectorNotFoundErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), hostSectorNotFoundErr)
+}
+
+// managedAuditHosts audits random pieces of random siafiles and marks the
+// pieces that failed their audit as lost.
+func (r *Renter) managedAuditHosts() error {
+	siaPaths, err := r.allSiaPaths()
+	if err != nil {
+		return errors.AddContext(err, "unable to list siafiles")
+	}
+	if len(siaPaths) == 0 {
+		return nil
+	}
+	for i := 0; i < auditPiecesPerRound; i++ {
+		select {
+		case <-r.tg.StopChan():
+			return nil
+		default:
+		}
+		siaPath := siaPaths[fastrand.Intn(len(siaPaths))]
+		if err := r.managedAuditRandomPiece(siaPath); err != nil {
+			r.log.Debugf("audit of %v failed: %v", siaPath, err)
+		}
+	}
+	return nil
+}
+
+// managedAuditRandomPiece audits a random piece of the file at siaPath.
+func (r *Renter) managedAuditRandomPiece(siaPath modules.SiaPath) error {
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	if entry.NumChunks() == 0 {
+		return nil
+	}
+
+	// Pick a random piece of a random chunk.
+	chunkIndex := fastrand.Uint64n(entry.NumChunks())
+	pieces, err := entry.Pieces(chunkIndex)
+	if err != nil {
+		return err
+	}
+	var candidates []siafile.Piece
+	var pieceIndices []uint64
+	for pieceIndex, pieceSet := range pieces {
+		for _, piece := range pieceSet {
+			candidates = append(candidates, piece)
+			pieceIndices = append(pieceIndices, uint64(pieceIndex))
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	i := fastrand.Intn(len(candidates))
+	piece, pieceIndex := candidates[i], pieceIndices[i]
+
+	// Audit the piece and mark it as lost if necessary.
+	lost, auditErr := r.managedAuditPiece(piece.HostPubKey, piece.MerkleRoot)
+	if !lost {
+		return auditErr
+	}
+	r.log.Printf("WARN: host %v failed the audit of %v chunk %v piece %v: %v", piece.HostPubKey, siaPath, chunkIndex, pieceIndex, auditErr)
+	if err := entry.RemovePiece(piece.HostPubKey, chunkIndex, pieceIndex, piece.MerkleRoot); err != nil {
+		return errors.AddContext(err, "unable to mark piece as lost")
+	}
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		return err
+	}
+	go r.threadedBubbleMetadata(dirSiaPath)
+	return nil
+}
+
+// threadedAuditHosts periodically audits the hosts that store the renter's
+// data.
+func (r *Renter) threadedAuditHosts() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(auditInterval):
+		}
+		if err := r.managedAuditHosts(); err != nil {
+			r.log.Println("WARN: unable to audit hosts:", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package renter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/host/contractmanager"
+)
+
+// TestAllSiaPaths checks that the auditor finds the siafiles of all
+// directories and that auditing files without pieces is a no-op.
+func TestAllSiaPaths(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	// Upload files to the root and to a nested directory.
+	source := filepath.Join(rt.dir, "source")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[modules.SiaPath]bool{
+		{Path: "a"}:     true,
+		{Path: "b/c/d"}: true,
+	}
+	for siaPath := range expected {
+		err := r.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	siaPaths, err := r.allSiaPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != len(expected) {
+		t.Fatalf("expected %v siapaths, got %v", len(expected), len(siaPaths))
+	}
+	for _, siaPath := range siaPaths {
+		if !expected[siaPath] {
+			t.Fatal("unexpected siapath", siaPath)
+		}
+		if err := r.managedAuditRandomPiece(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.managedAuditHosts(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestIsSectorNotFoundErr checks that only a host's reply about a missing
+// sector is treated as a lost piece.
+func TestIsSectorNotFoundErr(t *testing.T) {
+	tests := []struct {
+		err  error
+		lost bool
+	}{
+		{nil, false},
+		{errors.New("connection reset by peer"), false},
+		{errors.New("i/o timeout"), false},
+		{errors.New("host responded with error: could not find the desired sector"), true},
+		{errors.AddContext(errors.New(hostSectorNotFoundErr), "download failed"), true},
+	}
+	for _, test := range tests {
+		if lost := isSectorNotFoundErr(test.err); lost != test.lost {
+			t.Errorf("isSectorNotFoundErr(%v) = %v, expected %v", test.err, lost, test.lost)
+		}
+	}
+}
+
+// TestHostSectorNotFoundErr checks that hostSectorNotFoundErr matches the error
+// that the contract manager of a host returns for a missing sector.
+func TestHostSectorNotFoundErr(t *testing.T) {
+	if contractmanager.ErrSectorNotFound.Error() != hostSectorNotFoundErr {
+		t.Fatalf("hostSectorNotFoundErr %q doesn't match the error of the host %q", hostSectorNotFoundErr, contractmanager.ErrSectorNotFound)
+	}
+	if !isSectorNotFoundErr(errors.AddContext(contractmanager.ErrSectorNotFound, "host returned error")) {
+		t.Fatal("missing sector error of the host isn't detected")
+	}
+}
@@ -0,0 +1,160 @@
+package renter
+
+// checksum.go verifies downloaded data end-to-end. The Merkle roots of the
+// pieces only guarantee that the hosts return what the renter uploaded, not
+// that the renter uploaded and reassembled the right data. Therefore the
+// SHA-256 checksum of the plaintext of every chunk is stored with the chunk in
+// the siafile when the chunk is first read from its source. The SHA-256
+// checksum of the plaintext of the whole file, which is what sha256sum prints,
+// is stored in the metadata of the siafile.
+//
+// Streams are hashed while they are uploaded. Files that are uploaded from disk
+// are read in parallel and out of order, so their checksum is computed by
+// reading the file from start to end once every chunk has a checksum. It is
+// only stored if the file still matches the checksums of the chunks.
+//
+// Chunks with a checksum are always fetched in full and verified before any of
+// their data is written to the destination, including ranged downloads and
+// streams. A mismatch fails the download.
+//
+// Files that were uploaded before checksums were computed aren't verified.
+// Their chunks get a checksum when they are repaired from disk, the file once
+// all of its chunks have one.
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+)
+
+var (
+	// errChecksumMismatch is returned if data doesn't match the checksum of
+	// the data that was uploaded.
+	errChecksumMismatch = errors.New("data doesn't match the checksum of the uploaded data")
+)
+
+// checksumShards returns the checksum of the first n bytes of the data that is
+// split into shards.
+func checksumShards(shards [][]byte, n uint64) siafile.Checksum {
+	h := sha256.New()
+	for _, shard := range shards {
+		if n < uint64(len(shard)) {
+			shard = shard[:n]
+		}
+		h.Write(shard)
+		n -= uint64(len(shard))
+	}
+	var checksum siafile.Checksum
+	copy(checksum[:], h.Sum(nil))
+	return checksum
+}
+
+// readChecksum reads a file with the given size from r and returns its
+// checksum. The chunks of the file are verified against chunkChecksums while
+// they are read.
+func readChecksum(r io.Reader, size, chunkSize uint64, chunkChecksums []siafile.Checksum) (siafile.Checksum, error) {
+	fileHasher := sha256.New()
+	chunkHasher := sha256.New()
+	w := io.MultiWriter(fileHasher, chunkHasher)
+	var checksum siafile.Checksum
+	for chunkIndex, chunkChecksum := range chunkChecksums {
+		chunkHasher.Reset()
+		_, err := io.CopyN(w, r, int64(chunkDataSize(size, chunkSize, uint64(chunkIndex))))
+		if err != nil {
+			return siafile.Checksum{}, err
+		}
+		copy(checksum[:], chunkHasher.Sum(nil))
+		if checksum != chunkChecksum {
+			return siafile.Checksum{}, errChecksumMismatch
+		}
+	}
+	copy(checksum[:], fileHasher.Sum(nil))
+	return checksum, nil
+}
+
+// chunkDataSize returns the size of the data of the chunk with the given
+// index, which is smaller than the chunk size for the last chunk of a file.
+func chunkDataSize(fileSize, chunkSize, chunkIndex uint64) uint64 {
+	offset := chunkIndex * chunkSize
+	if offset >= fileSize {
+		return 0
+	}
+	if fileSize-offset < chunkSize {
+		return fileSize - offset
+	}
+	return chunkSize
+}
+
+// managedUpdateChunkChecksum compares the checksum of the logical data of the
+// chunk that was read from its source with the checksum that is stored in the
+// siafile. If the siafile doesn't have a checksum for the chunk yet, the
+// checksum is stored instead.
+func (uc *unfinishedUploadChunk) managedUpdateChunkChecksum() error {
+	checksum, err := uc.fileEntry.ChunkChecksum(uc.index)
+	if err != nil {
+		return err
+	}
+	if !checksum.Known() {
+		return uc.fileEntry.SetChunkChecksum(uc.index, uc.checksum)
+	}
+	if checksum != uc.checksum {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+// managedComputeFileChecksum sets the checksum of a file that is uploaded from
+// disk once every chunk of the file has a checksum. The checksum isn't set if
+// the file on disk doesn't match the checksums of the chunks anymore.
+func (uc *unfinishedUploadChunk) managedComputeFileChecksum() error {
+	if uc.fileEntry.Checksum().Known() {
+		return nil
+	}
+	chunkChecksums, err := uc.fileEntry.ChunkChecksums()
+	if err != nil || chunkChecksums == nil {
+		return err
+	}
+	f, err := os.Open(uc.fileEntry.LocalPath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	checksum, err := readChecksum(f, uc.fileEntry.Size(), uc.fileEntry.ChunkSize(), chunkChecksums)
+	if err != nil {
+		return err
+	}
+	return uc.fileEntry.SetChecksum(checksum)
+}
+
+// managedUpdateFileChecksum compares the checksum of the data that was read
+// from the source of an upload with the checksum of the whole file that is
+// stored in the siafile. If the siafile doesn't have a checksum yet, the
+// checksum is stored instead.
+func (r *Renter) managedUpdateFileChecksum(entry *siafile.SiaFileSetEntry, checksum siafile.Checksum) error {
+	if !entry.Checksum().Known() {
+		return entry.SetChecksum(checksum)
+	}
+	if entry.Checksum() != checksum {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+// verifyChecksum verifies the recovered data of the chunk against the checksum
+// of the chunk. Chunks without a checksum aren't verified.
+func (udc *unfinishedDownloadChunk) verifyChecksum(data []byte) error {
+	if !udc.staticChecksum.Known() {
+		return nil
+	}
+	if uint64(len(data)) < udc.staticChunkDataSize {
+		return errors.New("not enough data to verify the checksum of the chunk")
+	}
+	if siafile.NewChecksum(data[:udc.staticChunkDataSize]) != udc.staticChecksum {
+		return errChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package renter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+)
+
+// TestReadChecksum checks that readChecksum returns the checksum of the whole
+// file and verifies the chunks of the file.
+func TestReadChecksum(t *testing.T) {
+	data := fastrand.Bytes(250)
+	chunkChecksums := []siafile.Checksum{
+		siafile.NewChecksum(data[:100]),
+		siafile.NewChecksum(data[100:200]),
+		siafile.NewChecksum(data[200:]),
+	}
+	checksum, err := readChecksum(bytes.NewReader(data), 250, 100, chunkChecksums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != sha256.Sum256(data) {
+		t.Fatal("wrong checksum of file")
+	}
+	checksum, err = readChecksum(bytes.NewReader(nil), 0, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != sha256.Sum256(nil) {
+		t.Fatal("wrong checksum of empty file")
+	}
+
+	// A file that doesn't match the checksums of its chunks is rejected.
+	corrupt := append([]byte{}, data...)
+	corrupt[150]++
+	if _, err := readChecksum(bytes.NewReader(corrupt), 250, 100, chunkChecksums); err != errChecksumMismatch {
+		t.Fatal("expected errChecksumMismatch but got", err)
+	}
+	if _, err := readChecksum(bytes.NewReader(data[:200]), 250, 100, chunkChecksums); err != io.EOF {
+		t.Fatal("expected io.EOF but got", err)
+	}
+}
+
+// TestChecksumShards checks that checksumShards only hashes the data of the
+// shards and not their padding.
+func TestChecksumShards(t *testing.T) {
+	data := fastrand.Bytes(250)
+	shards := [][]byte{data[:100], data[100:200], append(data[200:], make([]byte, 50)...)}
+	if checksumShards(shards, 250) != siafile.NewChecksum(data) {
+		t.Fatal("wrong checksum of shards")
+	}
+	if checksumShards(shards, 150) != siafile.NewChecksum(data[:150]) {
+		t.Fatal("wrong checksum of partial shards")
+	}
+}
+
+// TestChunkDataSize probes chunkDataSize.
+func TestChunkDataSize(t *testing.T) {
+	tests := []struct {
+		fileSize, chunkIndex, size uint64
+	}{
+		{0, 0, 0},
+		{100, 0, 100},
+		{250, 1, 100},
+		{250, 2, 50},
+		{250, 3, 0},
+		{300, 2, 100},
+	}
+	for _, test := range tests {
+		if size := chunkDataSize(test.fileSize, 100, test.chunkIndex); size != test.size {
+			t.Errorf("chunk %v of file with size %v: expected %v but got %v", test.chunkIndex, test.fileSize, test.size, size)
+		}
+	}
+}
+
+// TestVerifyChunkChecksum checks that recovered chunks are verified against
+// their checksum, ignoring the padding of the chunk.
+func TestVerifyChunkChecksum(t *testing.T) {
+	data := fastrand.Bytes(100)
+	udc := &unfinishedDownloadChunk{
+		staticChecksum:      siafile.NewChecksum(data[:80]),
+		staticChunkDataSize: 80,
+	}
+	if err := udc.verifyChecksum(data); err != nil {
+		t.Fatal(err)
+	}
+	data[0]++
+	if err := udc.verifyChecksum(data); err != errChecksumMismatch {
+		t.Fatal("expected errChecksumMismatch but got", err)
+	}
+	if err := udc.verifyChecksum(data[:50]); err == nil {
+		t.Fatal("verifying partial chunk should fail")
+	}
+	udc.staticChecksum = siafile.Checksum{}
+	if err := udc.verifyChecksum(data); err != nil {
+		t.Fatal("chunk without checksum shouldn't be verified", err)
+	}
+}
@@ -0,0 +1,331 @@
+package renter
+
+// chunkcache.go implements a persistent LRU cache of downloaded chunks. Every
+// download, including the downloads of the streamer and of the repair loop,
+// checks the cache before fetching a chunk from the hosts. If the chunk is
+// cached, the requested data is served from disk. Otherwise the whole chunk is
+// fetched instead of only the requested range, so that it can be added to the
+// cache once it is recovered.
+//
+// Chunks are cached decrypted and keyed by the UID of their siafile and their
+// index within the file. The data of a chunk never changes for a given UID, a
+// file that is overwritten gets a new UID. The chunks of a file are removed
+// from the cache when the file is deleted, files in the trash keep their
+// chunks until they are purged. Once the cache exceeds its maximum size, the
+// least recently used chunks are evicted.
+//
+// The cache is disabled by default. Its maximum size is set through the
+// ChunkCacheSize of the renter settings.
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+)
+
+const (
+	// chunkCacheDir is the directory within the renter's persist dir that
+	// holds the cached chunks.
+	chunkCacheDir = "chunkcache"
+
+	// chunkCacheTempSuffix is the suffix of a cached chunk that is still
+	// being written.
+	chunkCacheTempSuffix = ".tmp"
+)
+
+var (
+	// errChunkCacheCorrupt is returned if the checksum of a cached chunk
+	// doesn't match its data.
+	errChunkCacheCorrupt = errors.New("cached chunk is corrupt")
+)
+
+type (
+	// chunkCache is an on-disk LRU cache of the logical data of chunks. The
+	// most recently used chunk is at the front of the list.
+	chunkCache struct {
+		entries map[string]*list.Element
+		lru     *list.List
+		maxSize uint64
+		size    uint64
+
+		hits   uint64
+		misses uint64
+
+		staticDir string
+		mu        sync.Mutex
+	}
+
+	// chunkCacheEntry is an element of the chunk cache's list.
+	chunkCacheEntry struct {
+		key  string
+		uid  siafile.SiafileUID
+		size uint64
+	}
+)
+
+// chunkCacheKey returns the key of the chunk with the given index of the file
+// with the given UID.
+func chunkCacheKey(uid siafile.SiafileUID, chunkIndex uint64) string {
+	return fmt.Sprintf("%v_%v", uid, chunkIndex)
+}
+
+// newChunkCache loads the cached chunks in dir and evicts chunks until the
+// cache doesn't exceed maxSize.
+func newChunkCache(dir string, maxSize uint64) (*chunkCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	cc := &chunkCache{
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+		maxSize:   maxSize,
+		staticDir: dir,
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	// The modification time of a cached chunk is updated whenever it is
+	// used, which restores the order of the list.
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		key := info.Name()
+		sep := strings.LastIndex(key, "_")
+		if strings.HasSuffix(key, chunkCacheTempSuffix) || sep == -1 || info.Size() < crypto.HashSize {
+			// Remove chunks that were not written completely.
+			if err := os.Remove(filepath.Join(dir, key)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		size := uint64(info.Size() - crypto.HashSize)
+		cc.entries[key] = cc.lru.PushBack(&chunkCacheEntry{
+			key:  key,
+			uid:  siafile.SiafileUID(key[:sep]),
+			size: size,
+		})
+		cc.size += size
+	}
+	return cc, cc.evict()
+}
+
+// evict removes the least recently used chunks until the cache doesn't exceed
+// its maximum size. The mutex must be held.
+func (cc *chunkCache) evict() error {
+	for cc.size > cc.maxSize {
+		if err := cc.remove(cc.lru.Back()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the path of the cached chunk with the given key.
+func (cc *chunkCache) path(key string) string {
+	return filepath.Join(cc.staticDir, key)
+}
+
+// remove removes a chunk from the cache. The mutex must be held.
+func (cc *chunkCache) remove(e *list.Element) error {
+	entry := cc.lru.Remove(e).(*chunkCacheEntry)
+	delete(cc.entries, entry.key)
+	cc.size -= entry.size
+	err := os.Remove(cc.path(entry.key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// managedAdd adds the logical data of a chunk to the cache. Chunks are only
+// added if the cache is enabled and the chunk fits into the cache.
+func (cc *chunkCache) managedAdd(key string, data []byte) error {
+	cc.mu.Lock()
+	_, exists := cc.entries[key]
+	maxSize := cc.maxSize
+	cc.mu.Unlock()
+	if exists || uint64(len(data)) > maxSize {
+		return nil
+	}
+	sep := strings.LastIndex(key, "_")
+	if sep == -1 {
+		return fmt.Errorf("invalid chunk cache key %v", key)
+	}
+
+	// Write the chunk to a temporary file first to avoid serving partially
+	// written chunks.
+	checksum := crypto.HashBytes(data)
+	tmpPath := cc.path(key) + chunkCacheTempSuffix
+	if err := ioutil.WriteFile(tmpPath, append(checksum[:], data...), 0600); err != nil {
+		return errors.Compose(err, os.Remove(tmpPath))
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if _, exists := cc.entries[key]; exists {
+		return os.Remove(tmpPath)
+	}
+	if err := os.Rename(tmpPath, cc.path(key)); err != nil {
+		return errors.Compose(err, os.Remove(tmpPath))
+	}
+	cc.entries[key] = cc.lru.PushFront(&chunkCacheEntry{
+		key:  key,
+		uid:  siafile.SiafileUID(key[:sep]),
+		size: uint64(len(data)),
+	})
+	cc.size += uint64(len(data))
+	return cc.evict()
+}
+
+// managedEnabled returns whether the cache is enabled.
+func (cc *chunkCache) managedEnabled() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.maxSize > 0
+}
+
+// managedGet returns the logical data of the chunk with the given key. The
+// returned bool indicates whether the chunk was cached.
+func (cc *chunkCache) managedGet(key string) ([]byte, bool) {
+	cc.mu.Lock()
+	e, exists := cc.entries[key]
+	if !exists {
+		cc.misses++
+		cc.mu.Unlock()
+		return nil, false
+	}
+	cc.lru.MoveToFront(e)
+	cc.mu.Unlock()
+
+	// Read the chunk without holding the lock. If the chunk is evicted in the
+	// meantime, the read fails and the chunk is treated as a miss.
+	data, err := ioutil.ReadFile(cc.path(key))
+	if err == nil && len(data) < crypto.HashSize {
+		err = errChunkCacheCorrupt
+	} else if err == nil {
+		checksum := crypto.HashBytes(data[crypto.HashSize:])
+		if !bytes.Equal(data[:crypto.HashSize], checksum[:]) {
+			err = errChunkCacheCorrupt
+		}
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if err != nil {
+		cc.misses++
+		if e, exists := cc.entries[key]; exists && err == errChunkCacheCorrupt {
+			cc.remove(e)
+		}
+		return nil, false
+	}
+	cc.hits++
+	now := time.Now()
+	_ = os.Chtimes(cc.path(key), now, now)
+	return data[crypto.HashSize:], true
+}
+
+// managedInvalidate removes all chunks of the file with the given UID from the
+// cache.
+func (cc *chunkCache) managedInvalidate(uid siafile.SiafileUID) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	var err error
+	for e := cc.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*chunkCacheEntry).uid == uid {
+			err = errors.Compose(err, cc.remove(e))
+		}
+		e = next
+	}
+	return err
+}
+
+// managedRemove removes the chunk with the given key from the cache.
+func (cc *chunkCache) managedRemove(key string) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	e, exists := cc.entries[key]
+	if !exists {
+		return nil
+	}
+	return cc.remove(e)
+}
+
+// managedSetMaxSize sets the maximum size of the cache and evicts chunks if
+// necessary. A maximum size of 0 disables the cache.
+func (cc *chunkCache) managedSetMaxSize(maxSize uint64) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.maxSize = maxSize
+	return cc.evict()
+}
+
+// managedStats returns the usage statistics of the cache.
+func (cc *chunkCache) managedStats() modules.ChunkCacheStats {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return modules.ChunkCacheStats{
+		Chunks:  uint64(len(cc.entries)),
+		Size:    cc.size,
+		MaxSize: cc.maxSize,
+		Hits:    cc.hits,
+		Misses:  cc.misses,
+	}
+}
+
+// siaFileUIDs returns the UIDs of the files within the directory at siaPath
+// and its subdirectories.
+func (r *Renter) siaFileUIDs(siaPath modules.SiaPath) ([]siafile.SiafileUID, error) {
+	var uids []siafile.SiafileUID
+	err := filepath.Walk(siaPath.SiaDirSysPath(r.staticFilesDir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != modules.SiaFileExtension {
+			return nil
+		}
+		md, err := siafile.LoadSiaFileMetadata(path)
+		if err != nil {
+			return errors.AddContext(err, "unable to load metadata of "+path)
+		}
+		uids = append(uids, md.UniqueID)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return uids, err
+}
+
+// managedLoadChunkCache loads the chunk cache from disk.
+func (r *Renter) managedLoadChunkCache() error {
+	id := r.mu.RLock()
+	maxSize := r.persist.ChunkCacheSize
+	r.mu.RUnlock(id)
+	cc, err := newChunkCache(filepath.Join(r.persistDir, chunkCacheDir), maxSize)
+	if err != nil {
+		return errors.AddContext(err, "unable to load chunk cache")
+	}
+	r.staticChunkCache = cc
+	return nil
+}
+
+// ChunkCacheStats returns the usage statistics of the chunk cache.
+func (r *Renter) ChunkCacheStats() modules.ChunkCacheStats {
+	return r.staticChunkCache.managedStats()
+}
@@ -0,0 +1,97 @@
+package renter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+)
+
+// TestChunkCache checks that the chunk cache evicts the least recently used
+// chunks, survives a restart and drops corrupt and invalidated chunks.
+func TestChunkCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	dir := build.TempDir("renter", t.Name())
+	cc, err := newChunkCache(dir, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding chunks to a disabled cache is a no-op.
+	uid1, uid2 := siafile.SiafileUID("a"), siafile.SiafileUID("b")
+	data := fastrand.Bytes(10)
+	if err := cc.managedSetMaxSize(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.managedAdd(chunkCacheKey(uid1, 0), data); err != nil {
+		t.Fatal(err)
+	}
+	if cc.managedEnabled() || cc.managedStats().Chunks != 0 {
+		t.Fatal("disabled cache shouldn't contain chunks")
+	}
+	if err := cc.managedSetMaxSize(30); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the cache and use the first chunk, which should evict the second
+	// chunk once another chunk is added.
+	for i := uint64(0); i < 3; i++ {
+		if err := cc.managedAdd(chunkCacheKey(uid1, i), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cached, ok := cc.managedGet(chunkCacheKey(uid1, 0)); !ok || !bytes.Equal(cached, data) {
+		t.Fatal("chunk wasn't cached")
+	}
+	if err := cc.managedAdd(chunkCacheKey(uid2, 0), data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cc.managedGet(chunkCacheKey(uid1, 1)); ok {
+		t.Fatal("least recently used chunk wasn't evicted")
+	}
+	stats := cc.managedStats()
+	if stats.Chunks != 3 || stats.Size != 30 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatal("wrong stats", stats)
+	}
+
+	// Reload the cache with a smaller size. The least recently used chunk
+	// should be evicted.
+	cc, err = newChunkCache(dir, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.managedStats().Chunks != 2 {
+		t.Fatal("wrong number of chunks after reload", cc.managedStats())
+	}
+	if _, ok := cc.managedGet(chunkCacheKey(uid2, 0)); !ok {
+		t.Fatal("most recently used chunk wasn't loaded")
+	}
+
+	// Corrupt chunks are treated as misses and removed.
+	if err := ioutil.WriteFile(filepath.Join(dir, chunkCacheKey(uid2, 0)), fastrand.Bytes(42), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cc.managedGet(chunkCacheKey(uid2, 0)); ok {
+		t.Fatal("corrupt chunk was returned")
+	}
+	if _, err := os.Stat(filepath.Join(dir, chunkCacheKey(uid2, 0))); !os.IsNotExist(err) {
+		t.Fatal("corrupt chunk wasn't removed", err)
+	}
+
+	// Invalidating a file removes all of its chunks.
+	if err := cc.managedInvalidate(uid1); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cc.managedStats(); stats.Chunks != 0 || stats.Size != 0 {
+		t.Fatal("chunks weren't invalidated", stats)
+	}
+}
@@ -189,6 +189,57 @@ var (
 		Testing:  5 * time.Second,
 	}).(time.Duration)
 
+	// syncDirDebounce is the amount of time that must pass after a file in a
+	// sync dir was last modified before it is uploaded. This prevents files
+	// that are still being written from being uploaded over and over.
+	syncDirDebounce = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  1 * time.Second,
+	}).(time.Duration)
+
+	// syncDirInterval is the amount of time between scans of the sync dirs.
+	syncDirInterval = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: 1 * time.Minute,
+		Testing:  1 * time.Second,
+	}).(time.Duration)
+
+	// packFlushInterval is the amount of time after which a pack that isn't
+	// full yet is uploaded anyway, so that small files don't remain pending
+	// forever.
+	packFlushInterval = build.Select(build.Var{
+		Dev:      1 * time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// versionPruneInterval is the amount of time between the periodic
+	// pruning of old versions that exceed the maximum age of their version
+	// policy.
+	versionPruneInterval = build.Select(build.Var{
+		Dev:      1 * time.Minute,
+		Standard: 1 * time.Hour,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// trashPurgeInterval is the amount of time between the periodic purging
+	// of trash entries that exceed the trash retention.
+	trashPurgeInterval = build.Select(build.Var{
+		Dev:      1 * time.Minute,
+		Standard: 1 * time.Hour,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// defaultTrashRetention is how long deleted files and directories are
+	// kept in the trash by default. A week gives the user enough time to
+	// notice and undo an accidental delete.
+	defaultTrashRetention = build.Select(build.Var{
+		Dev:      1 * time.Hour,
+		Standard: 7 * 24 * time.Hour,
+		Testing:  1 * time.Hour,
+	}).(time.Duration)
+
 	// stuckLoopErrorSleepDuration indicates how long the stuck loop should
 	// sleep before retrying if there is an error preventing progress.
 	stuckLoopErrorSleepDuration = build.Select(build.Var{
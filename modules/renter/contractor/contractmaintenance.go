@@ -33,6 +33,15 @@ type (
 	}
 )
 
+// checkPriceCaps returns errTooExpensive if one of the host's prices exceeds
+// the corresponding price cap of the allowance.
+func checkPriceCaps(a modules.Allowance, host modules.HostDBEntry) error {
+	if err := modules.CheckPriceCaps(a, host); err != nil {
+		return errors.AddContext(errTooExpensive, err.Error())
+	}
+	return nil
+}
+
 // managedCheckForDuplicates checks for static contracts that have the same host
 // key and moves the older one to old contracts.
 func (c *Contractor) managedCheckForDuplicates() {
@@ -241,6 +250,7 @@ func (c *Contractor) managedMarkContractsUtility() error {
 	// be used as a baseline for determining whether our existing contracts are
 	// worthwhile.
 	c.mu.RLock()
+	allowance := c.allowance
 	hostCount := int(c.allowance.Hosts)
 	period := c.allowance.Period
 	height := c.blockHeight
@@ -297,6 +307,18 @@ func (c *Contractor) managedMarkContractsUtility() error {
 				return u, nil
 			}
 
+			// Contract has no utility if the host's prices exceed the price
+			// caps of the allowance.
+			if err := checkPriceCaps(allowance, host); err != nil {
+				// Log if the utility has changed.
+				if u.GoodForUpload || u.GoodForRenew {
+					c.log.Printf("Marking contract as having no utility because of host prices: %v - %v", err, contract.ID)
+				}
+				u.GoodForUpload = false
+				u.GoodForRenew = false
+				return u, nil
+			}
+
 			// Contract has no utility if the score is poor.
 			sb, err := c.hdb.ScoreBreakdown(host)
 			if err != nil {
@@ -443,9 +465,13 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 		c.mu.Unlock()
 		return types.ZeroCurrency, modules.RenterContract{}, errors.New("called managedNewContract but allowance wasn't set")
 	}
+	allowance := c.allowance
 	period := c.allowance.Period
 	c.mu.Unlock()
 
+	if err := checkPriceCaps(allowance, host); err != nil {
+		return types.ZeroCurrency, modules.RenterContract{}, err
+	}
 	if host.MaxDuration < period {
 		err := errors.New("unable to form contract with host due to insufficient MaxDuration of host")
 		return types.ZeroCurrency, modules.RenterContract{}, err
@@ -585,6 +611,7 @@ func (c *Contractor) managedRenew(sc *proto.SafeContract, contractFunding types.
 		c.mu.Unlock()
 		return modules.RenterContract{}, errors.New("called managedRenew but allowance isn't set")
 	}
+	allowance := c.allowance
 	period := c.allowance.Period
 	c.mu.Unlock()
 	if !ok {
@@ -593,6 +620,8 @@ func (c *Contractor) managedRenew(sc *proto.SafeContract, contractFunding types.
 		return modules.RenterContract{}, errors.New("host is blacklisted")
 	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
 		return modules.RenterContract{}, errTooExpensive
+	} else if err := checkPriceCaps(allowance, host); err != nil {
+		return modules.RenterContract{}, err
 	} else if host.MaxDuration < period {
 		return modules.RenterContract{}, errors.New("insufficient MaxDuration of host")
 	}
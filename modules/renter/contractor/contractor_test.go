@@ -1,12 +1,13 @@
 package contractor
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"gitlab.com/NebulousLabs/errors"
+
 	"gitlab.com/NebulousLabs/Sia/build"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/types"
@@ -582,6 +583,129 @@ func TestHostMaxDuration(t *testing.T) {
 	}
 }
 
+// TestCheckPriceCaps probes checkPriceCaps.
+func TestCheckPriceCaps(t *testing.T) {
+	host := modules.HostDBEntry{}
+	host.BaseRPCPrice = types.NewCurrency64(10)
+	host.ContractPrice = types.NewCurrency64(10)
+	host.DownloadBandwidthPrice = types.NewCurrency64(10)
+	host.SectorAccessPrice = types.NewCurrency64(10)
+	host.StoragePrice = types.NewCurrency64(10)
+	host.UploadBandwidthPrice = types.NewCurrency64(10)
+
+	// Without caps every host is acceptable.
+	var a modules.Allowance
+	if err := checkPriceCaps(a, host); err != nil {
+		t.Fatal("host should be acceptable without caps:", err)
+	}
+
+	// Caps that are at least the host's prices are fine.
+	a.MaxRPCPrice = types.NewCurrency64(10)
+	a.MaxContractPrice = types.NewCurrency64(10)
+	a.MaxDownloadBandwidthPrice = types.NewCurrency64(10)
+	a.MaxSectorAccessPrice = types.NewCurrency64(10)
+	a.MaxStoragePrice = types.NewCurrency64(11)
+	a.MaxUploadBandwidthPrice = types.NewCurrency64(10)
+	if err := checkPriceCaps(a, host); err != nil {
+		t.Fatal("host should be acceptable within the caps:", err)
+	}
+
+	// Exceeding any of the caps makes the host too expensive.
+	caps := []*types.Currency{&a.MaxRPCPrice, &a.MaxContractPrice, &a.MaxDownloadBandwidthPrice, &a.MaxSectorAccessPrice, &a.MaxStoragePrice, &a.MaxUploadBandwidthPrice}
+	for i, c := range caps {
+		old := *c
+		*c = types.NewCurrency64(9)
+		if err := checkPriceCaps(a, host); !errors.Contains(err, errTooExpensive) {
+			t.Fatalf("%v: expected errTooExpensive, got %v", i, err)
+		}
+		*c = old
+	}
+}
+
+// TestAllowancePriceCaps checks that contracts aren't formed with hosts that
+// exceed the price caps of the allowance and that existing contracts with
+// such hosts aren't good for renew.
+func TestAllowancePriceCaps(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create testing trio
+	h, c, m, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, ok := c.hdb.Host(h.PublicKey())
+	if !ok {
+		t.Fatal("host not found in hostdb")
+	}
+	if host.StoragePrice.IsZero() {
+		t.Fatal("host should have a storage price")
+	}
+
+	// Set an allowance with a storage price cap below the host's price.
+	a := modules.Allowance{
+		Funds:              types.SiacoinPrecision.Mul64(100),
+		Hosts:              1,
+		Period:             30,
+		RenewWindow:        20,
+		ExpectedStorage:    modules.DefaultAllowance.ExpectedStorage,
+		ExpectedUpload:     modules.DefaultAllowance.ExpectedUpload,
+		ExpectedDownload:   modules.DefaultAllowance.ExpectedDownload,
+		ExpectedRedundancy: modules.DefaultAllowance.ExpectedRedundancy,
+		MaxStoragePrice:    host.StoragePrice.Sub(types.NewCurrency64(1)),
+	}
+	if err := c.SetAllowance(a); err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm that no contract is formed.
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if len(c.Contracts()) == 0 {
+			return errors.New("no contract created")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Contract should not have been created")
+	}
+
+	// Raise the cap above the host's price and wait for the contract.
+	a.MaxStoragePrice = host.StoragePrice
+	if err := c.SetAllowance(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(600, 100*time.Millisecond, func() error {
+		if len(c.Contracts()) != 1 {
+			return errors.New("no contract created")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lower the cap again. The existing contract is no longer good for renew.
+	a.MaxStoragePrice = host.StoragePrice.Sub(types.NewCurrency64(1))
+	if err := c.SetAllowance(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.managedMarkContractsUtility(); err != nil {
+		t.Fatal(err)
+	}
+	utility, ok := c.ContractUtility(h.PublicKey())
+	if !ok {
+		t.Fatal("contract utility not found")
+	}
+	if utility.GoodForRenew || utility.GoodForUpload {
+		t.Fatal("contract with an over-priced host should have no utility", utility)
+	}
+}
+
 // TestLinkedContracts tests that the contractors maps are updated correctly
 // when renewing contracts
 func TestLinkedContracts(t *testing.T) {
@@ -1,6 +1,8 @@
 package renter
 
 import (
+	"gitlab.com/NebulousLabs/errors"
+
 	"gitlab.com/NebulousLabs/Sia/modules"
 )
 
@@ -19,13 +21,53 @@ func (r *Renter) CreateDir(siaPath modules.SiaPath) error {
 }
 
 // DeleteDir removes a directory from the renter and deletes all its sub
-// directories and files
+// directories and files. If the trash is enabled, the directory is moved to
+// the trash instead.
 func (r *Renter) DeleteDir(siaPath modules.SiaPath) error {
 	if err := r.tg.Add(); err != nil {
 		return err
 	}
 	defer r.tg.Done()
-	return r.staticFileSet.DeleteDir(siaPath, r.staticDirSet.Delete)
+	if err := checkReservedSiaPaths(siaPath); err != nil {
+		return err
+	}
+	// Move the directory to the trash if the trash is enabled.
+	if trashed, err := r.managedMoveToTrash(siaPath, true); err != nil || trashed {
+		return err
+	}
+	if err := r.managedDeleteDir(siaPath); err != nil {
+		return err
+	}
+	return r.managedDeleteDirVersions(siaPath)
+}
+
+// managedDeleteDir deletes the siadir at siaPath and all of its files and
+// releases the packs of the packed files within the directory.
+func (r *Renter) managedDeleteDir(siaPath modules.SiaPath) error {
+	// Collect the packs of the packed files within the directory, so that
+	// they can be released after the files were deleted.
+	packs, err := r.packedSiaPaths(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to find packed files")
+	}
+	uids, err := r.siaFileUIDs(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to find files")
+	}
+	if err := r.staticFileSet.DeleteDir(siaPath, r.staticDirSet.Delete); err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		if err := r.staticChunkCache.managedInvalidate(uid); err != nil {
+			r.log.Println("WARN: unable to remove deleted file from the chunk cache:", err)
+		}
+	}
+	for _, packSiaPath := range packs {
+		if err := r.managedReleasePack(packSiaPath); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // DirList lists the directories in a siadir
@@ -34,7 +76,18 @@ func (r *Renter) DirList(siaPath modules.SiaPath) ([]modules.DirectoryInfo, erro
 		return nil, err
 	}
 	defer r.tg.Done()
-	return r.staticDirSet.DirList(siaPath)
+	dirs, err := r.staticDirSet.DirList(siaPath)
+	if err != nil || isHiddenSiaPath(siaPath) {
+		return dirs, err
+	}
+	// Hide the directories of old versions and the trash.
+	visible := dirs[:0]
+	for _, dir := range dirs {
+		if !isHiddenSiaPath(dir.SiaPath) {
+			visible = append(visible, dir)
+		}
+	}
+	return visible, nil
 }
 
 // RenameDir takes an existing directory and changes the path. The original
@@ -45,5 +98,11 @@ func (r *Renter) RenameDir(oldPath, newPath modules.SiaPath) error {
 		return err
 	}
 	defer r.tg.Done()
-	return r.staticFileSet.RenameDir(oldPath, newPath, r.staticDirSet.Rename)
+	if err := checkReservedSiaPaths(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := r.staticFileSet.RenameDir(oldPath, newPath, r.staticDirSet.Rename); err != nil {
+		return err
+	}
+	return r.managedRenameDirVersions(oldPath, newPath)
 }
@@ -201,6 +201,19 @@ func (d *download) markComplete() {
 	d.downloadCompleteFuncs = nil
 }
 
+// managedChunkComplete signals the download that a chunk was written to the
+// destination and marks the download as complete once all chunks were
+// written.
+func (d *download) managedChunkComplete() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.chunksRemaining--
+	if d.chunksRemaining == 0 {
+		// Download is complete, send out a notification.
+		d.markComplete()
+	}
+}
+
 // onComplete registers a function to be called when the download is completed.
 // This can either mean that the download succeeded or failed. The registered
 // functions are executed in the same order as they are registered and waiting
@@ -326,6 +339,20 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (*download,
 		return nil, fmt.Errorf("offset and length combination invalid, max byte is at index %d", entry.Size()-1)
 	}
 
+	// The data of a packed file is downloaded from the chunk of its pack.
+	dataEntry := entry
+	if packSiaPath, packOffset, packed := entry.Packed(); packed {
+		packEntry, err := r.staticFileSet.Open(packSiaPath)
+		if err == siafile.ErrUnknownPath {
+			return nil, errPackPending
+		} else if err != nil {
+			return nil, errors.AddContext(err, "unable to open pack")
+		}
+		defer packEntry.Close()
+		dataEntry = packEntry
+		p.Offset += packOffset
+	}
+
 	// Instantiate the correct downloadWriter implementation.
 	var dw downloadDestination
 	var destinationType string
@@ -351,7 +378,7 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (*download,
 	}
 
 	// Prepare snapshot.
-	snap, err := entry.Snapshot()
+	snap, err := dataEntry.Snapshot()
 	if err != nil {
 		return nil, err
 	}
@@ -487,6 +514,13 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 		}
 	}
 
+	// Chunks that are in the chunk cache are served from disk. If the cache is
+	// enabled, the other chunks are fetched in full so that they can be added
+	// to the cache once they are recovered. Chunks with a checksum are always
+	// fetched in full so that they can be verified before the requested range
+	// is written.
+	cacheEnabled := r.staticChunkCache.managedEnabled()
+
 	// Queue the downloads for each chunk.
 	writeOffset := int64(0) // where to write a chunk within the download destination.
 	d.chunksRemaining += maxChunk - minChunk + 1
@@ -496,11 +530,13 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 			erasureCode: params.file.ErasureCode(),
 			masterKey:   params.file.MasterKey(),
 
-			staticChunkIndex: i,
-			staticCacheID:    fmt.Sprintf("%v:%v", d.staticSiaPath, i),
-			staticChunkMap:   chunkMaps[i-minChunk],
-			staticChunkSize:  params.file.ChunkSize(),
-			staticPieceSize:  params.file.PieceSize(),
+			staticChunkIndex:    i,
+			staticCacheID:       chunkCacheKey(params.file.UID(), i),
+			staticChecksum:      params.file.ChunkChecksum(i),
+			staticChunkDataSize: chunkDataSize(params.file.Size(), params.file.ChunkSize(), i),
+			staticChunkMap:      chunkMaps[i-minChunk],
+			staticChunkSize:     params.file.ChunkSize(),
+			staticPieceSize:     params.file.PieceSize(),
 
 			// TODO: 25ms is just a guess for a good default. Really, we want to
 			// set the latency target such that slower workers will pick up the
@@ -541,6 +577,30 @@ func (r *Renter) managedNewDownload(params downloadParams) (*download, error) {
 		// be written.
 		udc.staticWriteOffset = writeOffset
 		writeOffset += int64(udc.staticFetchLength)
+		// Set the range of the logical chunk that is written to the
+		// destination. It only differs from the fetched range if the whole
+		// chunk is fetched for the chunk cache.
+		udc.staticDataOffset = udc.staticFetchOffset
+		udc.staticDataLength = udc.staticFetchLength
+		if cacheEnabled {
+			if data, cached := r.staticChunkCache.managedGet(udc.staticCacheID); cached {
+				if err := udc.verifyChecksum(data); err == nil {
+					go udc.threadedWriteCachedData(data)
+					continue
+				}
+				// Fetch chunks that don't match their checksum from the
+				// hosts again.
+				r.log.Println("WARN: cached chunk doesn't match its checksum:", udc.staticCacheID)
+				if err := r.staticChunkCache.managedRemove(udc.staticCacheID); err != nil {
+					r.log.Println("WARN: unable to remove chunk from the chunk cache:", err)
+				}
+			}
+			udc.staticChunkCache = r.staticChunkCache
+		}
+		if udc.staticChunkCache != nil || udc.staticChecksum.Known() {
+			udc.staticFetchOffset = 0
+			udc.staticFetchLength = params.file.ChunkSize()
+		}
 
 		// TODO: Currently all chunks are given overdrive. This should probably
 		// be changed once the hostdb knows how to measure host speed/latency
@@ -0,0 +1,168 @@
+package renter
+
+// downloadarchive.go implements downloading a directory and its
+// subdirectories as a single tar or zip archive. The files are written to the
+// archive one after another, since archives are written sequentially, but the
+// chunks of each file are downloaded in parallel like any other download. The
+// memory used by the downloads is bounded by the renter's memory manager.
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+)
+
+const (
+	// archiveFileMode is the mode of the files in a downloaded archive.
+	archiveFileMode = 0644
+)
+
+var (
+	// errUnknownArchiveFormat is returned if a directory is downloaded in an
+	// archive format that is not supported.
+	errUnknownArchiveFormat = errors.New("unknown archive format, must be 'tar' or 'zip'")
+)
+
+// archiveWriter is the interface of the writers that files of a directory
+// download are added to.
+type archiveWriter interface {
+	// CreateFile adds a file to the archive and returns the writer for its
+	// contents.
+	CreateFile(file modules.FileInfo, name string) (io.Writer, error)
+
+	// Close finishes the archive.
+	Close() error
+}
+
+type (
+	// tarArchiveWriter adds files to a tar archive.
+	tarArchiveWriter struct {
+		tw *tar.Writer
+	}
+
+	// zipArchiveWriter adds files to a zip archive.
+	zipArchiveWriter struct {
+		zw *zip.Writer
+	}
+)
+
+// CreateFile implements the archiveWriter interface.
+func (taw tarArchiveWriter) CreateFile(file modules.FileInfo, name string) (io.Writer, error) {
+	err := taw.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    archiveFileMode,
+		Size:    int64(file.Filesize),
+		ModTime: file.ModTime,
+	})
+	return taw.tw, err
+}
+
+// Close implements the archiveWriter interface.
+func (taw tarArchiveWriter) Close() error {
+	return taw.tw.Close()
+}
+
+// CreateFile implements the archiveWriter interface.
+func (zaw zipArchiveWriter) CreateFile(file modules.FileInfo, name string) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: file.ModTime,
+	}
+	header.SetMode(archiveFileMode)
+	return zaw.zw.CreateHeader(header)
+}
+
+// Close implements the archiveWriter interface.
+func (zaw zipArchiveWriter) Close() error {
+	return zaw.zw.Close()
+}
+
+// newArchiveWriter creates an archiveWriter for the given format that writes
+// to w.
+func newArchiveWriter(w io.Writer, format string) (archiveWriter, error) {
+	switch format {
+	case modules.ArchiveFormatTar:
+		return tarArchiveWriter{tw: tar.NewWriter(w)}, nil
+	case modules.ArchiveFormatZip:
+		return zipArchiveWriter{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, errUnknownArchiveFormat
+	}
+}
+
+// archiveName returns the name of a file within the archive of the directory
+// at dirSiaPath.
+func archiveName(dirSiaPath, fileSiaPath modules.SiaPath) string {
+	name := strings.TrimPrefix(fileSiaPath.String(), dirSiaPath.String())
+	return strings.TrimPrefix(name, "/")
+}
+
+// DownloadArchive downloads the files of the directory at siaPath and its
+// subdirectories and writes them to w as an archive of the given format. The
+// names of the files in the archive are relative to the directory. Nothing is
+// written to w if the download fails before the first file is added. If a file
+// fails to download, the archive is left unfinished so that it isn't mistaken
+// for a complete one.
+func (r *Renter) DownloadArchive(w io.Writer, siaPath modules.SiaPath, format string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	// Validate the parameters before anything is written.
+	if format != modules.ArchiveFormatTar && format != modules.ArchiveFormatZip {
+		return errUnknownArchiveFormat
+	}
+	exists, err := r.staticDirSet.Exists(siaPath)
+	if os.IsNotExist(err) {
+		return siadir.ErrUnknownPath
+	} else if err != nil {
+		return err
+	} else if !exists {
+		return siadir.ErrUnknownPath
+	}
+	files, err := r.FileList(siaPath, true, false)
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].SiaPath.String() < files[j].SiaPath.String()
+	})
+
+	// Download the files into the archive.
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		// The packs are skipped since their data is downloaded as part of
+		// the packed files.
+		if isPackSiaPath(file.SiaPath) {
+			continue
+		}
+		fw, err := aw.CreateFile(file, archiveName(siaPath, file.SiaPath))
+		if err != nil {
+			return err
+		}
+		if file.Filesize == 0 {
+			continue
+		}
+		err = r.Download(modules.RenterDownloadParameters{
+			Httpwriter: fw,
+			SiaPath:    file.SiaPath,
+		})
+		if err != nil {
+			return errors.AddContext(err, "unable to download "+file.SiaPath.String())
+		}
+	}
+	return aw.Close()
+}
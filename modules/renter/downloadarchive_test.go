@@ -0,0 +1,115 @@
+package renter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+)
+
+// TestDownloadArchive checks that the files of a directory and its
+// subdirectories are added to a downloaded archive.
+func TestDownloadArchive(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Upload empty files, which can be downloaded without any hosts.
+	source := filepath.Join(rt.dir, "empty")
+	f, err := os.Create(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"dir/a", "dir/sub/b", "other"} {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirSiaPath, err := modules.NewSiaPath("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a", "sub/b"}
+
+	// Unknown formats and directories are rejected before anything is
+	// written.
+	var buf bytes.Buffer
+	if err := rt.renter.DownloadArchive(&buf, dirSiaPath, "rar"); err != errUnknownArchiveFormat {
+		t.Fatal("expected errUnknownArchiveFormat, got", err)
+	}
+	if err := rt.renter.DownloadArchive(&buf, modules.RandomSiaPath(), modules.ArchiveFormatTar); err != siadir.ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("data was written for a failed download")
+	}
+
+	// Download the directory as a tar archive.
+	if err := rt.renter.DownloadArchive(&buf, dirSiaPath, modules.ArchiveFormatTar); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatal("unexpected files in tar archive:", names)
+	}
+
+	// Download the directory as a zip archive.
+	buf.Reset()
+	if err := rt.renter.DownloadArchive(&buf, dirSiaPath, modules.ArchiveFormatZip); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = names[:0]
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatal("unexpected files in zip archive:", names)
+	}
+
+	// Downloading the root directory includes all files.
+	buf.Reset()
+	if err := rt.renter.DownloadArchive(&buf, modules.RootSiaPath(), modules.ArchiveFormatZip); err != nil {
+		t.Fatal(err)
+	}
+	zr, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 3 || zr.File[2].Name != "other" {
+		t.Fatal("unexpected files in root archive")
+	}
+}
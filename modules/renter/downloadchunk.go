@@ -1,8 +1,10 @@
 package renter
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -41,14 +43,22 @@ type unfinishedDownloadChunk struct {
 	masterKey   crypto.CipherKey
 
 	// Fetch + Write instructions - read only or otherwise thread safe.
-	staticChunkIndex  uint64                       // Required for deriving the encryption keys for each piece.
-	staticCacheID     string                       // Used to uniquely identify a chunk in the chunk cache.
-	staticChunkMap    map[string]downloadPieceInfo // Maps from host PubKey to the info for the piece associated with that host
-	staticChunkSize   uint64
-	staticFetchLength uint64 // Length within the logical chunk to fetch.
-	staticFetchOffset uint64 // Offset within the logical chunk that is being downloaded.
-	staticPieceSize   uint64
-	staticWriteOffset int64 // Offset within the writer to write the completed data.
+	staticChunkIndex    uint64                       // Required for deriving the encryption keys for each piece.
+	staticCacheID       string                       // Used to uniquely identify a chunk in the chunk cache.
+	staticChecksum      siafile.Checksum             // Checksum of the chunk's data, zero if unknown.
+	staticChunkDataSize uint64                       // Size of the chunk's data, excluding padding.
+	staticChunkMap      map[string]downloadPieceInfo // Maps from host PubKey to the info for the piece associated with that host
+	staticChunkSize     uint64
+	staticFetchLength   uint64 // Length within the logical chunk to fetch.
+	staticFetchOffset   uint64 // Offset within the logical chunk that is being downloaded.
+	staticDataLength    uint64 // Length within the logical chunk to write to the destination.
+	staticDataOffset    uint64 // Offset within the logical chunk to write to the destination.
+	staticPieceSize     uint64
+	staticWriteOffset   int64 // Offset within the writer to write the completed data.
+
+	// staticChunkCache is set if the recovered chunk should be added to the
+	// chunk cache.
+	staticChunkCache *chunkCache
 
 	// Fetch + Write instructions - read only or otherwise thread safe.
 	staticLatencyTarget time.Duration
@@ -199,9 +209,36 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 	// succeeds or fails.
 	defer udc.managedCleanUp()
 
+	// Chunks with a checksum and chunks for the chunk cache are fetched in
+	// full. Verify them against their checksum and add them to the chunk
+	// cache before any data is written. Failing to cache the chunk doesn't
+	// fail the download.
+	if udc.staticChecksum.Known() || udc.staticChunkCache != nil {
+		recoverSize := udc.staticChunkDataSize
+		if udc.staticChunkCache != nil {
+			recoverSize = udc.staticChunkSize
+		}
+		buf := bytes.NewBuffer(make([]byte, 0, recoverSize))
+		err := udc.erasureCode.Recover(udc.physicalChunkData, recoverSize, buf)
+		if err == nil {
+			err = udc.verifyChecksum(buf.Bytes())
+		}
+		if err != nil {
+			udc.mu.Lock()
+			udc.fail(err)
+			udc.mu.Unlock()
+			return errors.AddContext(err, "unable to verify recovered chunk")
+		}
+		if udc.staticChunkCache != nil {
+			if err := udc.staticChunkCache.managedAdd(udc.staticCacheID, buf.Bytes()); err != nil {
+				udc.download.log.Println("WARN: unable to add chunk to the chunk cache:", err)
+			}
+		}
+	}
+
 	// Write the pieces to the requested output.
-	dataOffset := recoveredDataOffset(udc.staticFetchOffset, udc.erasureCode)
-	err := udc.destination.WritePieces(udc.erasureCode, udc.physicalChunkData, dataOffset, udc.staticWriteOffset, udc.staticFetchLength)
+	dataOffset := recoveredDataOffset(udc.staticFetchOffset, udc.erasureCode) + udc.staticDataOffset - udc.staticFetchOffset
+	err := udc.destination.WritePieces(udc.erasureCode, udc.physicalChunkData, dataOffset, udc.staticWriteOffset, udc.staticDataLength)
 	if err != nil {
 		udc.mu.Lock()
 		udc.fail(err)
@@ -217,18 +254,32 @@ func (udc *unfinishedDownloadChunk) threadedRecoverLogicalData() error {
 	udc.recoveryComplete = true
 	udc.mu.Unlock()
 
-	// Update the download and signal completion of this chunk.
-	udc.download.mu.Lock()
-	defer udc.download.mu.Unlock()
-	udc.download.chunksRemaining--
-	if udc.download.chunksRemaining == 0 {
-		// Download is complete, send out a notification.
-		udc.download.markComplete()
-		return err
-	}
+	// Signal completion of this chunk.
+	udc.download.managedChunkComplete()
 	return nil
 }
 
+// threadedWriteCachedData writes the requested data of the chunk to the
+// destination, using the logical data of the chunk from the chunk cache
+// instead of fetching the chunk from the hosts.
+func (udc *unfinishedDownloadChunk) threadedWriteCachedData(data []byte) {
+	pieces, err := udc.erasureCode.Encode(data)
+	if err == nil {
+		err = udc.destination.WritePieces(udc.erasureCode, pieces, udc.staticDataOffset, udc.staticWriteOffset, udc.staticDataLength)
+	}
+	udc.mu.Lock()
+	if err != nil {
+		udc.fail(errors.AddContext(err, "unable to write cached chunk to download destination"))
+		udc.mu.Unlock()
+		return
+	}
+	udc.recoveryComplete = true
+	udc.mu.Unlock()
+
+	atomic.AddUint64(&udc.download.atomicDataReceived, udc.staticDataLength)
+	udc.download.managedChunkComplete()
+}
+
 // bytesToRecover returns the number of bytes we need to recover from the
 // erasure coded segments. The number of bytes we need to recover doesn't
 // always match the chunkFetchLength. e.g. a user might want to fetch 500 bytes
@@ -478,6 +478,31 @@ func (r *Renter) Streamer(siaPath modules.SiaPath) (string, modules.Streamer, er
 	}
 	defer entry.Close()
 
+	// The data of a packed file is streamed from the chunk of its pack.
+	packSiaPath, packOffset, packed := entry.Packed()
+	if packed {
+		packEntry, err := r.staticFileSet.Open(packSiaPath)
+		if err == siafile.ErrUnknownPath {
+			return "", nil, errPackPending
+		} else if err != nil {
+			return "", nil, err
+		}
+		defer packEntry.Close()
+		snap, err := packEntry.Snapshot()
+		if err != nil {
+			return "", nil, err
+		}
+		ps := &packedStreamer{
+			Streamer:     r.managedStreamer(snap),
+			staticOffset: int64(packOffset),
+			staticSize:   int64(entry.Size()),
+		}
+		if _, err := ps.Seek(0, io.SeekStart); err != nil {
+			return "", nil, errors.Compose(err, ps.Close())
+		}
+		return r.staticFileSet.SiaPath(entry).String(), ps, nil
+	}
+
 	// Create the streamer
 	snap, err := entry.Snapshot()
 	if err != nil {
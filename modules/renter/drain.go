@@ -0,0 +1,302 @@
+package renter
+
+// drain.go migrates all pieces stored on a host to other hosts. For every
+// chunk that has pieces on the drained host, an unfinished chunk is built that
+// doesn't count the drained host towards the redundancy of the chunk and
+// excludes it from the hosts the chunk can be uploaded to. The chunk is pushed
+// onto the upload heap, which fetches the logical data either from the local
+// file or by downloading the chunk, and uploads the missing pieces to other
+// hosts. Once a piece is stored on another host, the piece on the drained
+// host is removed from the siafile. After all pieces were migrated, the
+// contract with the host is canceled.
+//
+// Drains are not persisted. If the renter is restarted during a drain, the
+// drain has to be started again. Pieces that were migrated already won't be
+// migrated a second time.
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// drainMaxAttempts is the number of times the renter tries to migrate the
+	// pieces of a file before the remaining pieces are counted as failed.
+	drainMaxAttempts = 3
+)
+
+var (
+	// drainPollInterval is the interval at which a drain checks whether the
+	// chunks it pushed onto the upload heap were repaired.
+	drainPollInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+)
+
+var (
+	// errDrainInProgress is returned if a host is already being drained.
+	errDrainInProgress = errors.New("host is already being drained")
+
+	// errNoContractWithHost is returned if a host can't be drained because
+	// the renter doesn't have a contract with it.
+	errNoContractWithHost = errors.New("no contract with host")
+)
+
+// drainHostPieces returns the pieces of the chunk that are stored on the
+// drained host, keyed by piece index. A piece is considered migrated if
+// another usable host stores a piece with the same index.
+func drainHostPieces(pieces [][]siafile.Piece, pk types.SiaPublicKey, offline, goodForRenew map[string]bool) (migrated, pending map[uint64]siafile.Piece) {
+	migrated = make(map[uint64]siafile.Piece)
+	pending = make(map[uint64]siafile.Piece)
+	for pieceIndex, pieceSet := range pieces {
+		var onHost []siafile.Piece
+		var elsewhere bool
+		for _, piece := range pieceSet {
+			hpk := piece.HostPubKey.String()
+			if hpk == pk.String() {
+				onHost = append(onHost, piece)
+			} else if goodForRenew[hpk] && !offline[hpk] {
+				elsewhere = true
+			}
+		}
+		for _, piece := range onHost {
+			if elsewhere {
+				migrated[uint64(pieceIndex)] = piece
+			} else {
+				pending[uint64(pieceIndex)] = piece
+			}
+		}
+	}
+	return migrated, pending
+}
+
+// managedCountHostPieces returns the number of pieces stored on the host.
+func (r *Renter) managedCountHostPieces(siaPaths []modules.SiaPath, pk types.SiaPublicKey) (uint64, error) {
+	var n uint64
+	for _, siaPath := range siaPaths {
+		entry, err := r.staticFileSet.Open(siaPath)
+		if err != nil {
+			return 0, err
+		}
+		for chunkIndex := uint64(0); chunkIndex < entry.NumChunks(); chunkIndex++ {
+			pieces, err := entry.Pieces(chunkIndex)
+			if err != nil {
+				entry.Close()
+				return 0, err
+			}
+			for _, pieceSet := range pieces {
+				for _, piece := range pieceSet {
+					if piece.HostPubKey.String() == pk.String() {
+						n++
+					}
+				}
+			}
+		}
+		entry.Close()
+	}
+	return n, nil
+}
+
+// managedDrainFile migrates the pieces of the file at siaPath away from the
+// host. It returns the number of pieces that were migrated and the number of
+// pieces that couldn't be migrated.
+func (r *Renter) managedDrainFile(siaPath modules.SiaPath, pk types.SiaPublicKey) (migrated, failed uint64, err error) {
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer entry.Close()
+	defer func() {
+		if migrated > 0 {
+			dirSiaPath, err := siaPath.Dir()
+			if err == nil {
+				go r.threadedBubbleMetadata(dirSiaPath)
+			}
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		// Remove the pieces that were migrated already and collect the chunks
+		// that still need to be migrated.
+		offline, goodForRenew, _ := r.managedContractUtilityMaps()
+		var pendingChunks []uint64
+		failed = 0
+		for chunkIndex := uint64(0); chunkIndex < entry.NumChunks(); chunkIndex++ {
+			pieces, err := entry.Pieces(chunkIndex)
+			if err != nil {
+				return migrated, failed, err
+			}
+			done, pending := drainHostPieces(pieces, pk, offline, goodForRenew)
+			for pieceIndex, piece := range done {
+				if err := entry.RemovePiece(pk, chunkIndex, pieceIndex, piece.MerkleRoot); err != nil {
+					return migrated, failed, errors.AddContext(err, "unable to remove migrated piece")
+				}
+				migrated++
+			}
+			if len(pending) > 0 {
+				pendingChunks = append(pendingChunks, chunkIndex)
+				failed += uint64(len(pending))
+			}
+		}
+		if len(pendingChunks) == 0 || attempt == drainMaxAttempts {
+			return migrated, failed, nil
+		}
+
+		// Push the chunks onto the upload heap. The drained host neither
+		// counts towards the redundancy of the chunks nor is it used to
+		// upload the missing pieces.
+		hosts := r.managedRefreshHostsAndWorkers()
+		delete(hosts, pk.String())
+		goodForRenew[pk.String()] = false
+		var ids []uploadChunkID
+		for _, chunkIndex := range pendingChunks {
+			uuc, err := r.managedBuildUnfinishedChunk(entry, chunkIndex, hosts, nil, true, offline, goodForRenew)
+			if err != nil {
+				return migrated, failed, errors.AddContext(err, "unable to build chunk")
+			}
+			if !r.uploadHeap.managedPush(uuc) {
+				// The chunk is already being repaired. Wait for the repair to
+				// finish instead.
+				if err := uuc.fileEntry.Close(); err != nil {
+					return migrated, failed, err
+				}
+			}
+			ids = append(ids, uuc.id)
+		}
+		select {
+		case r.uploadHeap.newUploads <- struct{}{}:
+		default:
+		}
+
+		// Wait for the chunks to be repaired.
+		for _, id := range ids {
+			for r.uploadHeap.managedExists(id) {
+				select {
+				case <-r.tg.StopChan():
+					return migrated, failed, errors.New("interrupted by shutdown")
+				case <-time.After(drainPollInterval):
+				}
+			}
+		}
+	}
+}
+
+// managedUpdateHostDrain updates the drain info of the host.
+func (r *Renter) managedUpdateHostDrain(pk types.SiaPublicKey, update func(*modules.HostDrainInfo)) {
+	r.drainsMu.Lock()
+	defer r.drainsMu.Unlock()
+	update(r.drains[pk.String()])
+}
+
+// threadedDrainHost migrates all pieces away from the host and cancels the
+// contract with the host afterwards.
+func (r *Renter) threadedDrainHost(pk types.SiaPublicKey) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	err := func() error {
+		siaPaths, err := r.allSiaPaths()
+		if err != nil {
+			return errors.AddContext(err, "unable to list siafiles")
+		}
+		pieces, err := r.managedCountHostPieces(siaPaths, pk)
+		if err != nil {
+			return errors.AddContext(err, "unable to count pieces")
+		}
+		r.managedUpdateHostDrain(pk, func(d *modules.HostDrainInfo) {
+			d.Pieces = pieces
+		})
+
+		var failed uint64
+		for _, siaPath := range siaPaths {
+			migratedFile, failedFile, err := r.managedDrainFile(siaPath, pk)
+			failed += failedFile
+			r.managedUpdateHostDrain(pk, func(d *modules.HostDrainInfo) {
+				d.MigratedPieces += migratedFile
+				d.FailedPieces += failedFile
+			})
+			if err != nil && !r.staticFileSet.Exists(siaPath) {
+				// The file was deleted during the drain.
+				continue
+			} else if err != nil {
+				return errors.AddContext(err, "unable to drain "+siaPath.String())
+			}
+		}
+		if failed > 0 {
+			return errors.New("not all pieces could be migrated, the contract was not canceled")
+		}
+
+		// Cancel the contract.
+		contract, ok := r.hostContractor.ContractByPublicKey(pk)
+		if !ok {
+			return errNoContractWithHost
+		}
+		if err := r.hostContractor.CancelContract(contract.ID); err != nil {
+			return errors.AddContext(err, "unable to cancel contract")
+		}
+		r.managedUpdateHostDrain(pk, func(d *modules.HostDrainInfo) {
+			d.ContractCanceled = true
+		})
+		return nil
+	}()
+	if err != nil {
+		r.log.Printf("WARN: drain of host %v failed: %v", pk, err)
+	}
+	r.managedUpdateHostDrain(pk, func(d *modules.HostDrainInfo) {
+		d.Finished = true
+		if err != nil {
+			d.Error = err.Error()
+		}
+	})
+}
+
+// DrainHost migrates all pieces stored on the host to other hosts and cancels
+// the contract with the host once it is done. The drain runs in the
+// background, its progress is reported by HostDrains.
+func (r *Renter) DrainHost(pk types.SiaPublicKey) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if _, ok := r.hostContractor.ContractByPublicKey(pk); !ok {
+		return errNoContractWithHost
+	}
+	r.drainsMu.Lock()
+	if d, exists := r.drains[pk.String()]; exists && !d.Finished {
+		r.drainsMu.Unlock()
+		return errDrainInProgress
+	}
+	r.drains[pk.String()] = &modules.HostDrainInfo{
+		HostPublicKey: pk,
+		Started:       time.Now(),
+	}
+	r.drainsMu.Unlock()
+	go r.threadedDrainHost(pk)
+	return nil
+}
+
+// HostDrains returns the progress of the drains started since the renter was
+// started, oldest first.
+func (r *Renter) HostDrains() []modules.HostDrainInfo {
+	r.drainsMu.Lock()
+	defer r.drainsMu.Unlock()
+	drains := make([]modules.HostDrainInfo, 0, len(r.drains))
+	for _, d := range r.drains {
+		drains = append(drains, *d)
+	}
+	sort.Slice(drains, func(i, j int) bool {
+		return drains[i].Started.Before(drains[j].Started)
+	})
+	return drains
+}
@@ -0,0 +1,41 @@
+package renter
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// TestDrainHostPieces checks that pieces of a drained host are only
+// considered migrated if a usable host stores the same piece.
+func TestDrainHostPieces(t *testing.T) {
+	drained := types.SiaPublicKey{Key: []byte{1}}
+	good := types.SiaPublicKey{Key: []byte{2}}
+	bad := types.SiaPublicKey{Key: []byte{3}}
+	offline := map[string]bool{
+		good.String(): false,
+		bad.String():  true,
+	}
+	goodForRenew := map[string]bool{
+		drained.String(): true,
+		good.String():    true,
+		bad.String():     true,
+	}
+	pieces := [][]siafile.Piece{
+		// Migrated to a good host.
+		{{HostPubKey: drained, MerkleRoot: crypto.Hash{1}}, {HostPubKey: good, MerkleRoot: crypto.Hash{1}}},
+		// Only stored on an offline host.
+		{{HostPubKey: drained, MerkleRoot: crypto.Hash{2}}, {HostPubKey: bad, MerkleRoot: crypto.Hash{2}}},
+		// Not stored on the drained host.
+		{{HostPubKey: good, MerkleRoot: crypto.Hash{3}}},
+	}
+	migrated, pending := drainHostPieces(pieces, drained, offline, goodForRenew)
+	if len(migrated) != 1 || migrated[0].MerkleRoot != (crypto.Hash{1}) {
+		t.Fatal("wrong migrated pieces", migrated)
+	}
+	if len(pending) != 1 || pending[1].MerkleRoot != (crypto.Hash{2}) {
+		t.Fatal("wrong pending pieces", pending)
+	}
+}
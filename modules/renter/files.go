@@ -1,18 +1,63 @@
 package renter
 
 import (
+	"bytes"
+	"io/ioutil"
+
+	"gitlab.com/NebulousLabs/errors"
+
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
 )
 
-// DeleteFile removes a file entry from the renter and deletes its data from
-// the hosts it is stored on.
+// checkReservedSiaPaths returns an error if one of the siapaths is within a
+// siadir that is reserved for the renter's own siafiles.
+func checkReservedSiaPaths(siaPaths ...modules.SiaPath) error {
+	for _, siaPath := range siaPaths {
+		if isPackSiaPath(siaPath) {
+			return errPackPathReserved
+		}
+		if isVersionSiaPath(siaPath) {
+			return errVersionPathReserved
+		}
+		if isTrashSiaPath(siaPath) {
+			return errTrashPathReserved
+		}
+	}
+	return nil
+}
+
+// isHiddenSiaPath returns true if siaPath is within a reserved siadir that is
+// hidden from file and directory listings.
+func isHiddenSiaPath(siaPath modules.SiaPath) bool {
+	return isVersionSiaPath(siaPath) || isTrashSiaPath(siaPath)
+}
+
+// DeleteFile removes a file entry and its old versions from the renter and
+// deletes their data from the hosts they are stored on. If the trash is
+// enabled, the file is moved to the trash instead.
 func (r *Renter) DeleteFile(siaPath modules.SiaPath) error {
 	if err := r.tg.Add(); err != nil {
 		return err
 	}
 	defer r.tg.Done()
+	if err := checkReservedSiaPaths(siaPath); err != nil {
+		return err
+	}
+	// Move the file to the trash if the trash is enabled.
+	if trashed, err := r.managedMoveToTrash(siaPath, false); err != nil || trashed {
+		return err
+	}
+	if err := r.managedDeleteFile(siaPath); err != nil {
+		return err
+	}
+	return r.managedDeleteVersions(siaPath)
+}
 
+// managedDeleteFile deletes the siafile at siaPath and releases its pack if
+// the file is packed.
+func (r *Renter) managedDeleteFile(siaPath modules.SiaPath) error {
 	// Call threadedBubbleMetadata on the old directory to make sure the system
 	// metadata is updated to reflect the move
 	defer func() error {
@@ -24,7 +69,25 @@ func (r *Renter) DeleteFile(siaPath modules.SiaPath) error {
 		return nil
 	}()
 
-	return r.staticFileSet.Delete(siaPath)
+	// Check whether the file is packed before deleting it. The pack is deleted
+	// once all of its files are deleted.
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return err
+	}
+	packSiaPath, _, packed := entry.Packed()
+	uid := entry.UID()
+	entry.Close()
+	if err := r.staticFileSet.Delete(siaPath); err != nil {
+		return err
+	}
+	if err := r.staticChunkCache.managedInvalidate(uid); err != nil {
+		r.log.Println("WARN: unable to remove deleted file from the chunk cache:", err)
+	}
+	if packed {
+		return r.managedReleasePack(packSiaPath)
+	}
+	return nil
 }
 
 // FileList returns all of the files that the renter has.
@@ -34,7 +97,18 @@ func (r *Renter) FileList(siaPath modules.SiaPath, recursive, cached bool) ([]mo
 	}
 	defer r.tg.Done()
 	offlineMap, goodForRenewMap, contractsMap := r.managedContractUtilityMaps()
-	return r.staticFileSet.FileList(siaPath, recursive, cached, offlineMap, goodForRenewMap, contractsMap)
+	files, err := r.staticFileSet.FileList(siaPath, recursive, cached, offlineMap, goodForRenewMap, contractsMap)
+	if err != nil || isHiddenSiaPath(siaPath) {
+		return files, err
+	}
+	// Hide the old versions of files and the trash.
+	visible := files[:0]
+	for _, file := range files {
+		if !isHiddenSiaPath(file.SiaPath) {
+			visible = append(visible, file)
+		}
+	}
+	return visible, nil
 }
 
 // File returns file from siaPath queried by user.
@@ -56,11 +130,18 @@ func (r *Renter) RenameFile(currentName, newName modules.SiaPath) error {
 		return err
 	}
 	defer r.tg.Done()
+	if err := checkReservedSiaPaths(currentName, newName); err != nil {
+		return err
+	}
 	// Rename file
 	err := r.staticFileSet.Rename(currentName, newName)
 	if err != nil {
 		return err
 	}
+	// Move the old versions of the file along with it.
+	if err := r.managedRenameVersions(currentName, newName); err != nil {
+		return err
+	}
 	// Call threadedBubbleMetadata on the old directory to make sure the system
 	// metadata is updated to reflect the move
 	dirSiaPath, err := currentName.Dir()
@@ -85,6 +166,94 @@ func (r *Renter) RenameFile(currentName, newName modules.SiaPath) error {
 	return nil
 }
 
+// CopyFile creates a copy of the file at src at dst without reuploading its
+// data. The copy shares the pieces, keys and contracts of the original file.
+// Deleting a file never removes its sectors from the hosts, so the copy stays
+// available when the original is deleted and vice versa. A copy of a packed
+// file counts as an additional file of the pack, so that the pack is only
+// deleted once all copies were deleted. Old versions of the file aren't
+// copied.
+func (r *Renter) CopyFile(src, dst modules.SiaPath) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if err := checkReservedSiaPaths(src, dst); err != nil {
+		return err
+	}
+	if r.staticFileSet.Exists(dst) {
+		return siafile.ErrPathOverload
+	}
+
+	// Read the siafile of the source.
+	entry, err := r.staticFileSet.Open(src)
+	if err != nil {
+		return err
+	}
+	packSiaPath, _, packed := entry.Packed()
+	sr, err := entry.SnapshotReader()
+	if err != nil {
+		return errors.Compose(err, entry.Close())
+	}
+	b, err := ioutil.ReadAll(sr)
+	err = errors.Compose(err, sr.Close(), entry.Close())
+	if err != nil {
+		return errors.AddContext(err, "unable to read siafile")
+	}
+
+	// Add the copy to the renter.
+	if packed {
+		if err := r.managedRetainPack(packSiaPath); err != nil {
+			return err
+		}
+	}
+	if err := r.managedAddSiaFileCopy(dst, b); err != nil {
+		if packed {
+			err = errors.Compose(err, r.managedReleasePack(packSiaPath))
+		}
+		return errors.AddContext(err, "unable to add copy")
+	}
+	return nil
+}
+
+// managedAddSiaFileCopy adds the siafile b at siaPath with a new UID.
+func (r *Renter) managedAddSiaFileCopy(siaPath modules.SiaPath, b []byte) error {
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		return err
+	}
+	siaDirEntry, err := r.staticDirSet.NewSiaDir(dirSiaPath)
+	if err != siadir.ErrPathOverload && err != nil {
+		return err
+	} else if err == nil {
+		siaDirEntry.Close()
+	}
+	br := bytes.NewReader(b)
+	sf, err := siafile.LoadSiaFileFromReader(br, siaPath.SiaFileSysPath(r.staticFilesDir), r.wal)
+	if err != nil {
+		return err
+	}
+	chunks, err := ioutil.ReadAll(br)
+	if err != nil {
+		return err
+	}
+	if err := r.staticFileSet.AddExistingSiaFile(sf, chunks); err != nil {
+		return err
+	}
+	// The file set adds a suffix to the siapath if a file was created at
+	// siaPath in the meantime.
+	if sf.SiaFilePath() != siaPath.SiaFileSysPath(r.staticFilesDir) {
+		var added modules.SiaPath
+		err := added.LoadSysPath(r.staticFilesDir, sf.SiaFilePath())
+		if err == nil {
+			err = r.staticFileSet.Delete(added)
+		}
+		return errors.Compose(siafile.ErrPathOverload, err)
+	}
+	go r.threadedBubbleMetadata(dirSiaPath)
+	return nil
+}
+
 // SetFileStuck sets the Stuck field of the whole siafile to stuck.
 func (r *Renter) SetFileStuck(siaPath modules.SiaPath, stuck bool) error {
 	if err := r.tg.Add(); err != nil {
@@ -100,3 +269,62 @@ func (r *Renter) SetFileStuck(siaPath modules.SiaPath, stuck bool) error {
 	// Update the file.
 	return entry.SetAllStuck(stuck)
 }
+
+// SetFileUserMetadata replaces the user metadata of the siafile.
+func (r *Renter) SetFileUserMetadata(siaPath modules.SiaPath, userMetadata map[string]string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	return entry.SetUserMetadata(userMetadata)
+}
+
+// FileUserMetadata returns the user metadata of the siafile.
+func (r *Renter) FileUserMetadata(siaPath modules.SiaPath) (map[string]string, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer entry.Close()
+	return entry.UserMetadata(), nil
+}
+
+// FindFiles returns the files whose user metadata contains the tag and all of
+// the key/value pairs of query. The files are looked up in the user metadata
+// index of the file set, the health and redundancy of the returned files are
+// cached values.
+func (r *Renter) FindFiles(tag string, query map[string]string) ([]modules.FileInfo, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	siaPaths, err := r.staticFileSet.FindFiles(tag, query)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to find siafiles")
+	}
+	offline, goodForRenew, contracts := r.managedContractUtilityMaps()
+	files := []modules.FileInfo{}
+	for _, siaPath := range siaPaths {
+		// Old versions and the trash are hidden.
+		if isHiddenSiaPath(siaPath) {
+			continue
+		}
+		file, err := r.staticFileSet.CachedFileInfo(siaPath, offline, goodForRenew, contracts)
+		if err == siafile.ErrUnknownPath {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gitlab.com/NebulousLabs/fastrand"
@@ -546,13 +547,15 @@ func TestRenterDeleteFile(t *testing.T) {
 		t.Error(err)
 	}
 
-	// Check that all .sia files have been deleted.
+	// Check that all .sia files have been moved to the trash.
 	var walkStr string
 	filepath.Walk(rt.renter.staticFilesDir, func(path string, _ os.FileInfo, _ error) error {
 		// capture only .sia files
 		if filepath.Ext(path) == ".sia" {
 			rel, _ := filepath.Rel(rt.renter.staticFilesDir, path) // strip testdir prefix
-			walkStr += rel
+			if !strings.HasPrefix(filepath.ToSlash(rel), trashSiaDir+"/") {
+				walkStr += rel
+			}
 		}
 		return nil
 	})
@@ -560,6 +563,9 @@ func TestRenterDeleteFile(t *testing.T) {
 	if walkStr != expWalkStr {
 		t.Fatalf("Bad walk string: expected %q, got %q", expWalkStr, walkStr)
 	}
+	if len(rt.renter.Trash()) != 2 {
+		t.Fatal("expected 2 trash entries, got", len(rt.renter.Trash()))
+	}
 }
 
 // TestRenterFileList probes the FileList method of the renter type.
@@ -735,6 +741,76 @@ func TestRenterRenameFile(t *testing.T) {
 	}
 }
 
+// TestRenterCopyFile tests that copies of files share the pieces of the
+// original file and remain available when the original is deleted.
+func TestRenterCopyFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	// Create a file with a piece.
+	entry, err := r.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hpk := types.SiaPublicKey{Key: fastrand.Bytes(32)}
+	if err := entry.AddPiece(hpk, 0, 0, crypto.Hash{1}); err != nil {
+		t.Fatal(err)
+	}
+	src := r.staticFileSet.SiaPath(entry)
+	uid := entry.UID()
+	entry.Close()
+
+	// Copy the file into a new directory.
+	dst := modules.SiaPath{Path: "dir/copy"}
+	if err := r.CopyFile(dst, modules.RandomSiaPath()); err != siafile.ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	if err := r.CopyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CopyFile(src, dst); err != siafile.ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if err := r.CopyFile(src, modules.SiaPath{Path: packsSiaDir + "/copy"}); err != errPackPathReserved {
+		t.Fatal("expected errPackPathReserved, got", err)
+	}
+
+	// Deleting the original doesn't affect the copy, which has the same
+	// pieces but a different UID.
+	if err := r.DeleteFile(src); err != nil {
+		t.Fatal(err)
+	}
+	entry, err = r.staticFileSet.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer entry.Close()
+	if entry.UID() == uid {
+		t.Fatal("copy should have a new UID")
+	}
+	pieces, err := entry.Pieces(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces[0]) != 1 || pieces[0][0].MerkleRoot != (crypto.Hash{1}) || pieces[0][0].HostPubKey.String() != hpk.String() {
+		t.Fatal("pieces weren't copied", pieces[0])
+	}
+	files, err := r.FileList(modules.RootSiaPath(), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !files[0].SiaPath.Equals(dst) {
+		t.Fatal("unexpected files after deleting the original", files)
+	}
+}
+
 // TestRenterFileDir tests that the renter files are uploaded to the files
 // directory and not the root directory of the renter.
 func TestRenterFileDir(t *testing.T) {
@@ -209,6 +209,12 @@ func (hdb *HostDB) interactionAdjustments(entry modules.HostDBEntry) float64 {
 // are on a per-block basis, meaning you need to multiply be the allowance
 // period when working with these values.
 func (hdb *HostDB) priceAdjustments(entry modules.HostDBEntry, allowance modules.Allowance, txnFees types.Currency) float64 {
+	// Hosts whose prices exceed one of the price caps of the allowance are
+	// not acceptable. The minimal weight keeps them from being selected.
+	if modules.CheckPriceCaps(allowance, entry) != nil {
+		return math.SmallestNonzeroFloat64
+	}
+
 	// Divide by zero mitigation.
 	if allowance.Hosts == 0 {
 		allowance.Hosts = 1
@@ -197,6 +197,32 @@ func TestHostWeightMaxDuration(t *testing.T) {
 	}
 }
 
+// TestHostWeightPriceCaps checks that hosts whose prices exceed a price cap of
+// the allowance get the minimum weight, which prevents them from being
+// selected.
+func TestHostWeightPriceCaps(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdb := bareHostDB()
+	allowance := DefaultTestAllowance
+	allowance.MaxStoragePrice = DefaultHostDBEntry.StoragePrice
+	hdb.SetAllowance(allowance)
+
+	entry := DefaultHostDBEntry
+	entry2 := DefaultHostDBEntry
+	entry2.StoragePrice = entry2.StoragePrice.Add(types.NewCurrency64(1))
+
+	w1 := hdb.weightFunc(entry).Score()
+	w2 := hdb.weightFunc(entry2).Score()
+	if w1.Cmp64(1) <= 0 {
+		t.Error("Host at the price cap should be selectable", w1)
+	}
+	if w2.Cmp64(1) > 0 {
+		t.Error("Host above the price cap should have the minimum weight", w2)
+	}
+}
+
 // TestHostWeightStorageRemainingDifferences checks that the host with more
 // collateral has more weight.
 func TestHostWeightCollateralDifferences(t *testing.T) {
@@ -229,6 +229,14 @@ func (r *Renter) managedCalculateAndUpdateFileMetadata(siaPath modules.SiaPath)
 	if err != nil {
 		return siafile.BubbledMetadata{}, err
 	}
+	// The health and redundancy of a packed file are those of its pack.
+	if packSiaPath, _, packed := sf.Packed(); packed {
+		offline, goodForRenew, _ := r.managedContractUtilityMaps()
+		health, stuckHealth, numStuckChunks, redundancy, err = r.staticFileSet.PackHealth(packSiaPath, offline, goodForRenew)
+		if err != nil {
+			return siafile.BubbledMetadata{}, err
+		}
+	}
 	if _, err := os.Stat(sf.LocalPath()); os.IsNotExist(err) && redundancy < 1 {
 		r.log.Debugln("File not found on disk and possibly unrecoverable:", sf.LocalPath())
 	}
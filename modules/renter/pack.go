@@ -0,0 +1,440 @@
+package renter
+
+// pack.go packs small files into shared chunks. A siafile always occupies at
+// least one full chunk on the network, which makes storing small files very
+// expensive. When a small file is uploaded with the Pack flag, its data is
+// appended to a pack instead, a local file that is uploaded as a regular
+// single-chunk siafile in the reserved .packed directory once it is full or
+// once it has been pending for packFlushInterval. The siafile of the packed
+// file has no pieces of its own, instead its metadata records the siapath of
+// the pack and the offset of its data within the pack's chunk.
+//
+// Downloads of a packed file download the corresponding range of the pack.
+// Packs are repaired like any other file, and the health and redundancy of a
+// packed file are those of its pack. The local data of a pack is kept so that
+// it can be repaired from disk. Once all files of a pack are deleted, the pack
+// is deleted as well.
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// packsFilename is the filename of the file that persists the packs.
+	packsFilename = "packs.json"
+
+	// packsDir is the directory within the renter's persist dir that holds
+	// the local data of the packs.
+	packsDir = "packs"
+
+	// packsSiaDir is the reserved siadir that holds the siafiles of the
+	// packs.
+	packsSiaDir = ".packed"
+
+	// packedFileSizeDivisor determines the maximum size of a packed file. A
+	// file is only packed if it is at most 1/packedFileSizeDivisor of the
+	// size of a pack.
+	packedFileSizeDivisor = 4
+)
+
+var (
+	packsMetadata = persist.Metadata{
+		Header:  "Renter Packs",
+		Version: persistVersion,
+	}
+
+	// packCapacity is the size of a pack, which is the size of a single chunk
+	// of the default erasure code.
+	packCapacity = uint64(defaultDataPieces) * (modules.SectorSize - crypto.TypeDefaultRenter.Overhead())
+
+	// errPackErasureCode is returned if a file is packed with a custom
+	// erasure code. All packs use the default erasure code.
+	errPackErasureCode = errors.New("packed files can't use a custom erasure code")
+
+	// errPackPathReserved is returned if a user tries to modify the siafiles
+	// of the packs directly.
+	errPackPathReserved = errors.New("siapath is reserved for packed files")
+
+	// errUnknownPack is returned if the pack of a packed file is unknown.
+	errUnknownPack = errors.New("pack of the packed file is unknown")
+
+	// errPackPending is returned if a packed file is downloaded before its
+	// pack was uploaded.
+	errPackPending = errors.New("the file's pack hasn't been uploaded yet")
+)
+
+type (
+	// pack is a chunk that stores the data of several small files.
+	pack struct {
+		Name    string
+		Size    uint64
+		Files   uint64
+		Pending bool
+		Created time.Time
+	}
+
+	// packsPersist is the on-disk representation of the packs.
+	packsPersist struct {
+		Packs []pack
+	}
+
+	// packedStreamer is a streamer for a packed file. It wraps the streamer
+	// of the file's pack and limits it to the file's data.
+	packedStreamer struct {
+		modules.Streamer
+		staticOffset int64
+		staticSize   int64
+		pos          int64
+	}
+)
+
+// Read implements the io.Reader interface.
+func (ps *packedStreamer) Read(p []byte) (int, error) {
+	if ps.pos >= ps.staticSize {
+		return 0, io.EOF
+	}
+	if remaining := ps.staticSize - ps.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := ps.Streamer.Read(p)
+	ps.pos += int64(n)
+	return n, err
+}
+
+// Seek implements the io.Seeker interface.
+func (ps *packedStreamer) Seek(offset int64, whence int) (int64, error) {
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = ps.pos + offset
+	case io.SeekEnd:
+		newPos = ps.staticSize + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if newPos < 0 {
+		return 0, errors.New("cannot seek to negative offset")
+	}
+	if _, err := ps.Streamer.Seek(ps.staticOffset+newPos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	ps.pos = newPos
+	return newPos, nil
+}
+
+// isPackSiaPath returns true if siaPath is within the reserved siadir of the
+// packs.
+func isPackSiaPath(siaPath modules.SiaPath) bool {
+	return siaPath.Path == packsSiaDir || strings.HasPrefix(siaPath.Path, packsSiaDir+"/")
+}
+
+// packSiaPath returns the siapath of the pack with the given name.
+func packSiaPath(name string) modules.SiaPath {
+	return modules.SiaPath{Path: packsSiaDir + "/" + name}
+}
+
+// packDataPath returns the path of the local data of the pack with the given
+// name.
+func (r *Renter) packDataPath(name string) string {
+	return filepath.Join(r.persistDir, packsDir, name)
+}
+
+// savePacks saves the packs to disk. The packsMu must be held.
+func (r *Renter) savePacks() error {
+	var pp packsPersist
+	for _, p := range r.packs {
+		pp.Packs = append(pp.Packs, *p)
+	}
+	return persist.SaveJSON(packsMetadata, pp, filepath.Join(r.persistDir, packsFilename))
+}
+
+// managedLoadPacks loads the packs from disk.
+func (r *Renter) managedLoadPacks() error {
+	var pp packsPersist
+	err := persist.LoadJSON(packsMetadata, &pp, filepath.Join(r.persistDir, packsFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	for i := range pp.Packs {
+		p := pp.Packs[i]
+		r.packs[p.Name] = &p
+		if p.Pending {
+			r.pendingPack = &p
+		}
+	}
+	return nil
+}
+
+// newPack creates a new pending pack. The packsMu must be held.
+func (r *Renter) newPack() (*pack, error) {
+	p := &pack{
+		Name:    persist.RandomSuffix(),
+		Pending: true,
+		Created: time.Now(),
+	}
+	if err := os.MkdirAll(filepath.Join(r.persistDir, packsDir), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(r.packDataPath(p.Name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	r.packs[p.Name] = p
+	r.pendingPack = p
+	return p, nil
+}
+
+// flushPack uploads a pending pack. A pack without files is discarded
+// instead. If the upload fails, the pack remains pending. The packsMu must be
+// held.
+func (r *Renter) flushPack(p *pack) error {
+	if p.Files == 0 {
+		delete(r.packs, p.Name)
+		r.pendingPack = nil
+		err := os.Remove(r.packDataPath(p.Name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.savePacks()
+	}
+
+	// Upload the pack unless it was uploaded before the pack was marked as
+	// uploaded, e.g. because of an unclean shutdown.
+	siaPath := packSiaPath(p.Name)
+	if !r.staticFileSet.Exists(siaPath) {
+		path := r.packDataPath(p.Name)
+		if err := os.Truncate(path, int64(p.Size)); err != nil {
+			return errors.AddContext(err, "unable to truncate pack")
+		}
+		sourceInfo, err := os.Stat(path)
+		if err != nil {
+			return errors.AddContext(err, "unable to stat pack")
+		}
+		err = r.managedUpload(modules.FileUploadParams{
+			Source:  path,
+			SiaPath: siaPath,
+		}, sourceInfo)
+		if err != nil {
+			return errors.AddContext(err, "unable to upload pack")
+		}
+	}
+	p.Pending = false
+	r.pendingPack = nil
+	return r.savePacks()
+}
+
+// managedPackFile adds the data of a small file to the pending pack and
+// creates the siafile of the packed file. If the file doesn't fit into the
+// pending pack, the pending pack is uploaded and a new one is created.
+func (r *Renter) managedPackFile(up modules.FileUploadParams, sourceInfo os.FileInfo) error {
+	data, err := ioutil.ReadFile(up.Source)
+	if err != nil {
+		return errors.AddContext(err, "unable to read the source file")
+	}
+	size := uint64(len(data))
+
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+
+	// Get a pack with enough space left.
+	p := r.pendingPack
+	if p != nil && p.Size+size > packCapacity {
+		if err := r.flushPack(p); err != nil {
+			return err
+		}
+		p = nil
+	}
+	if p == nil {
+		p, err = r.newPack()
+		if err != nil {
+			return errors.AddContext(err, "unable to create pack")
+		}
+	}
+
+	// Append the data to the pack.
+	f, err := os.OpenFile(r.packDataPath(p.Name), os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.AddContext(err, "unable to open pack")
+	}
+	_, err = f.WriteAt(data, int64(p.Size))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err := errors.Compose(err, f.Close()); err != nil {
+		return errors.AddContext(err, "unable to write to pack")
+	}
+	offset := p.Size
+	p.Size += size
+	p.Files++
+	if err := r.savePacks(); err != nil {
+		return err
+	}
+
+	// Create the directory of the file and the siafile.
+	dirSiaPath, err := up.SiaPath.Dir()
+	if err != nil {
+		return err
+	}
+	siaDirEntry, err := r.staticDirSet.NewSiaDir(dirSiaPath)
+	if err != siadir.ErrPathOverload && err != nil {
+		return errors.AddContext(err, "unable to create sia directory for new file")
+	} else if err == nil {
+		siaDirEntry.Close()
+	}
+	up.ErasureCode, _ = siafile.NewRSSubCode(defaultDataPieces, defaultParityPieces, crypto.SegmentSize)
+	entry, err := r.staticFileSet.NewSiaFile(up, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), size, sourceInfo.Mode())
+	if err != nil {
+		p.Files--
+		return errors.Compose(errors.AddContext(err, "could not create a new sia file"), r.savePacks())
+	}
+	defer entry.Close()
+	if err := entry.SetPacked(packSiaPath(p.Name), offset); err != nil {
+		return errors.Compose(err, r.staticFileSet.Delete(up.SiaPath))
+	}
+	if err := entry.SetChecksum(siafile.NewChecksum(data)); err != nil {
+		return errors.Compose(err, r.staticFileSet.Delete(up.SiaPath))
+	}
+	go r.threadedBubbleMetadata(dirSiaPath)
+
+	// Upload the pack right away if it is full.
+	if p.Size == packCapacity {
+		if err := r.flushPack(p); err != nil {
+			r.log.Println("WARN: unable to upload full pack:", err)
+		}
+	}
+	return nil
+}
+
+// managedFlushPendingPack uploads the pending pack if it has been pending for
+// at least packFlushInterval.
+func (r *Renter) managedFlushPendingPack() {
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	p := r.pendingPack
+	if p == nil || time.Since(p.Created) < packFlushInterval {
+		return
+	}
+	if err := r.flushPack(p); err != nil {
+		r.log.Println("WARN: unable to upload pending pack:", err)
+	}
+}
+
+// threadedPackFlush periodically uploads the pending pack, so that packed
+// files don't remain pending if no more small files are uploaded.
+func (r *Renter) threadedPackFlush() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(packFlushInterval):
+		}
+		r.managedFlushPendingPack()
+	}
+}
+
+// managedRetainPack counts an additional file of the pack at siaPath, e.g. a
+// copy of a packed file.
+func (r *Renter) managedRetainPack(siaPath modules.SiaPath) error {
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	p, exists := r.packs[siaPath.Name()]
+	if !exists {
+		return errUnknownPack
+	}
+	p.Files++
+	return r.savePacks()
+}
+
+// managedReleasePack is called after a packed file was deleted. Once all
+// files of an uploaded pack were deleted, the pack and its local data are
+// deleted as well. Pending packs are discarded when they are flushed instead.
+func (r *Renter) managedReleasePack(siaPath modules.SiaPath) error {
+	r.packsMu.Lock()
+	defer r.packsMu.Unlock()
+	p, exists := r.packs[siaPath.Name()]
+	if !exists {
+		return nil
+	}
+	if p.Files > 0 {
+		p.Files--
+	}
+	if p.Files > 0 || p.Pending {
+		return r.savePacks()
+	}
+	if entry, err := r.staticFileSet.Open(siaPath); err == nil {
+		uid := entry.UID()
+		entry.Close()
+		if err := r.staticChunkCache.managedInvalidate(uid); err != nil {
+			r.log.Println("WARN: unable to remove deleted pack from the chunk cache:", err)
+		}
+	}
+	err := r.staticFileSet.Delete(siaPath)
+	if err != nil && err != siafile.ErrUnknownPath {
+		return errors.AddContext(err, "unable to delete pack")
+	}
+	err = os.Remove(r.packDataPath(p.Name))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.AddContext(err, "unable to delete pack data")
+	}
+	delete(r.packs, p.Name)
+	go r.threadedBubbleMetadata(modules.SiaPath{Path: packsSiaDir})
+	return r.savePacks()
+}
+
+// packedSiaPaths returns the siapaths of the packs referenced by the files
+// within the directory at siaPath and its subdirectories.
+func (r *Renter) packedSiaPaths(siaPath modules.SiaPath) ([]modules.SiaPath, error) {
+	var packs []modules.SiaPath
+	err := filepath.Walk(siaPath.SiaDirSysPath(r.staticFilesDir), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != modules.SiaFileExtension {
+			return nil
+		}
+		md, err := siafile.LoadSiaFileMetadata(path)
+		if err != nil {
+			return errors.AddContext(err, "unable to load metadata of "+path)
+		}
+		if md.PackedSiaPath == "" {
+			return nil
+		}
+		packSiaPath, err := modules.NewSiaPath(md.PackedSiaPath)
+		if err != nil {
+			return err
+		}
+		packs = append(packs, packSiaPath)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return packs, err
+}
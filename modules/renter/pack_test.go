@@ -0,0 +1,259 @@
+package renter
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/persist"
+)
+
+// nopCloseReadSeeker turns an io.ReadSeeker into a modules.Streamer.
+type nopCloseReadSeeker struct {
+	io.ReadSeeker
+}
+
+// Close implements the io.Closer interface.
+func (nopCloseReadSeeker) Close() error { return nil }
+
+// TestPackedStreamer checks that a packedStreamer only exposes the data of the
+// packed file.
+func TestPackedStreamer(t *testing.T) {
+	data := fastrand.Bytes(100)
+	ps := &packedStreamer{
+		Streamer:     nopCloseReadSeeker{bytes.NewReader(data)},
+		staticOffset: 20,
+		staticSize:   30,
+	}
+	if _, err := ps.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ioutil.ReadAll(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data[20:50]) {
+		t.Fatal("read data doesn't match packed file")
+	}
+
+	// Seek relative to the end and the current position.
+	if size, err := ps.Seek(0, io.SeekEnd); err != nil || size != 30 {
+		t.Fatal("unexpected size", size, err)
+	}
+	if _, err := ps.Seek(-10, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := ps.Seek(5, io.SeekCurrent); err != nil || pos != 25 {
+		t.Fatal("unexpected position", pos, err)
+	}
+	read, err = ioutil.ReadAll(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, data[45:50]) {
+		t.Fatal("read data doesn't match packed file")
+	}
+	if _, err := ps.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("seeking to a negative offset should fail")
+	}
+}
+
+// TestPackFiles checks that small files are packed into shared packs and that
+// the packs are uploaded once they are full and deleted once all of their
+// files were deleted.
+func TestPackFiles(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	// Create local files of the maximum size of packed files.
+	maxSize := packCapacity / packedFileSizeDivisor
+	newFile := func(name string, size uint64) string {
+		path := filepath.Join(rt.dir, name)
+		if err := ioutil.WriteFile(path, fastrand.Bytes(int(size)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	upload := func(source, path string) error {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath, Pack: true})
+	}
+	packed := func(path string) (modules.SiaPath, uint64, bool) {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := r.staticFileSet.Open(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer entry.Close()
+		return entry.Packed()
+	}
+
+	// Packs can't be modified directly and can't use custom erasure codes.
+	source := newFile("a", maxSize)
+	if err := upload(source, packsSiaDir+"/a"); err != errPackPathReserved {
+		t.Fatal("expected errPackPathReserved, got", err)
+	}
+	ec, _ := siafile.NewRSSubCode(1, 1, 64)
+	err = r.Upload(modules.FileUploadParams{Source: source, SiaPath: modules.RandomSiaPath(), ErasureCode: ec, Pack: true})
+	if err != errPackErasureCode {
+		t.Fatal("expected errPackErasureCode, got", err)
+	}
+
+	// Pack three files into the pending pack.
+	for i, path := range []string{"a", "b", "dir/c"} {
+		if err := upload(source, path); err != nil {
+			t.Fatal(err)
+		}
+		_, offset, ok := packed(path)
+		if !ok || offset != uint64(i)*maxSize {
+			t.Fatalf("file %v wasn't packed at the expected offset: %v %v", path, ok, offset)
+		}
+	}
+	p := r.pendingPack
+	if p == nil || p.Files != 3 || p.Size != 3*maxSize || !p.Pending {
+		t.Fatalf("unexpected pending pack: %+v", p)
+	}
+	pack1 := packSiaPath(p.Name)
+	if r.staticFileSet.Exists(pack1) {
+		t.Fatal("pending pack shouldn't have been uploaded")
+	}
+	fi, err := r.File(modules.SiaPath{Path: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Packed || fi.Available || fi.Filesize != maxSize {
+		t.Fatalf("unexpected file info of packed file: %+v", fi)
+	}
+
+	// Packed files can't be downloaded before their pack was uploaded.
+	_, err = r.managedDownload(modules.RenterDownloadParameters{
+		Destination: filepath.Join(rt.dir, "download"),
+		SiaPath:     modules.SiaPath{Path: "a"},
+	})
+	if err != errPackPending {
+		t.Fatal("expected errPackPending, got", err)
+	}
+
+	// The fourth file fills the pack, which is then uploaded.
+	if err := upload(source, "dir/d"); err != nil {
+		t.Fatal(err)
+	}
+	if r.pendingPack != nil || p.Pending || !r.staticFileSet.Exists(pack1) {
+		t.Fatal("full pack wasn't uploaded")
+	}
+
+	// Larger files aren't packed.
+	if err := upload(newFile("large", maxSize+1), "large"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := packed("large"); ok {
+		t.Fatal("large file shouldn't be packed")
+	}
+
+	// The next small file is packed into a new pack.
+	if err := upload(source, "e"); err != nil {
+		t.Fatal(err)
+	}
+	pack2, _, ok := packed("e")
+	if !ok || pack2.Equals(pack1) || r.pendingPack == nil {
+		t.Fatal("file wasn't packed into a new pack")
+	}
+
+	// The packs are persisted.
+	var pp packsPersist
+	if err := persist.LoadJSON(packsMetadata, &pp, filepath.Join(r.persistDir, packsFilename)); err != nil {
+		t.Fatal(err)
+	}
+	if len(pp.Packs) != 2 {
+		t.Fatal("expected 2 persisted packs, got", len(pp.Packs))
+	}
+
+	// Renaming a packed file keeps it packed.
+	if err := r.RenameFile(modules.SiaPath{Path: "a"}, modules.SiaPath{Path: "a2"}); err != nil {
+		t.Fatal(err)
+	}
+	if packSiaPath, offset, ok := packed("a2"); !ok || !packSiaPath.Equals(pack1) || offset != 0 {
+		t.Fatal("renamed file isn't packed anymore")
+	}
+	// Copies of a packed file count as files of the pack.
+	filesBefore := r.packs[pack1.Name()].Files
+	if err := r.CopyFile(modules.SiaPath{Path: "a2"}, modules.SiaPath{Path: "a3"}); err != nil {
+		t.Fatal(err)
+	}
+	if packSiaPath, offset, ok := packed("a3"); !ok || !packSiaPath.Equals(pack1) || offset != 0 {
+		t.Fatal("copy of packed file isn't packed")
+	}
+	if r.packs[pack1.Name()].Files != filesBefore+1 {
+		t.Fatal("copy wasn't counted as a file of the pack")
+	}
+	if err := r.RenameFile(pack1, modules.RandomSiaPath()); err != errPackPathReserved {
+		t.Fatal("expected errPackPathReserved, got", err)
+	}
+	if err := r.DeleteFile(pack1); err != errPackPathReserved {
+		t.Fatal("expected errPackPathReserved, got", err)
+	}
+
+	// The pack is deleted once all of its files are deleted and purged from
+	// the trash.
+	for _, path := range []string{"a2", "a3", "b"} {
+		if err := r.DeleteFile(modules.SiaPath{Path: path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !r.staticFileSet.Exists(pack1) {
+		t.Fatal("pack was deleted while it still had files")
+	}
+	if err := r.DeleteDir(modules.SiaPath{Path: "dir"}); err != nil {
+		t.Fatal(err)
+	}
+	if !r.staticFileSet.Exists(pack1) {
+		t.Fatal("pack was deleted while its files were in the trash")
+	}
+	if err := r.PurgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if r.staticFileSet.Exists(pack1) {
+		t.Fatal("pack wasn't deleted after all of its files were deleted")
+	}
+	if _, err := os.Stat(r.packDataPath(pack1.Name())); !os.IsNotExist(err) {
+		t.Fatal("local data of the pack wasn't deleted", err)
+	}
+
+	// A pending pack without files is discarded when it is flushed.
+	if err := r.DeleteFile(modules.SiaPath{Path: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PurgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	r.packsMu.Lock()
+	err = r.flushPack(r.pendingPack)
+	r.packsMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.packs) != 0 || r.pendingPack != nil || r.staticFileSet.Exists(pack2) {
+		t.Fatal("empty pending pack wasn't discarded")
+	}
+}
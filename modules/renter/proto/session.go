@@ -20,6 +20,10 @@ import (
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
+// ErrBadSectorData is returned by Read if the sector data sent by the host
+// doesn't match the Merkle proof for the requested root.
+var ErrBadSectorData = errors.New("host provided incorrect sector data or Merkle proof")
+
 // A Session is an ongoing exchange of RPCs via the renter-host protocol.
 //
 // TODO: The session type needs access to a logger. Probably the renter logger.
@@ -520,7 +524,7 @@ func (s *Session) Read(w io.Writer, req modules.LoopReadRequest, cancel <-chan s
 				proofStart := int(sec.Offset) / crypto.SegmentSize
 				proofEnd := int(sec.Offset+sec.Length) / crypto.SegmentSize
 				if !crypto.VerifyRangeProof(resp.Data, resp.MerkleProof, proofStart, proofEnd, sec.MerkleRoot) {
-					return modules.RenterContract{}, errors.New("host provided incorrect sector data or Merkle proof")
+					return modules.RenterContract{}, ErrBadSectorData
 				}
 			}
 			// write sector data
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/threadgroup"
@@ -74,6 +75,14 @@ type hostDB interface {
 	// Host returns the HostDBEntry for a given host.
 	Host(pk types.SiaPublicKey) (modules.HostDBEntry, bool)
 
+	// IncrementSuccessfulInteractions increments the number of successful
+	// interactions with a host.
+	IncrementSuccessfulInteractions(types.SiaPublicKey)
+
+	// IncrementFailedInteractions increments the number of failed
+	// interactions with a host.
+	IncrementFailedInteractions(types.SiaPublicKey)
+
 	// initialScanComplete returns a boolean indicating if the initial scan of the
 	// hostdb is completed.
 	InitialScanComplete() (bool, error)
@@ -226,6 +235,44 @@ type Renter struct {
 	bubbleUpdates   map[string]bubbleStatus
 	bubbleUpdatesMu sync.Mutex
 
+	// syncDirs are local directories that are kept in sync with a siapath.
+	// syncDirsScanMu is held while the sync dirs are scanned, so that
+	// concurrent scans don't upload the same changes twice.
+	syncDirs       map[modules.SiaPath]*syncDir
+	syncDirsMu     sync.Mutex
+	syncDirsScanMu sync.Mutex
+	syncDirsNotify chan struct{}
+
+	// packs are the chunks that store the data of small files. pendingPack
+	// is the pack that new small files are added to until it is uploaded.
+	packs       map[string]*pack
+	pendingPack *pack
+	packsMu     sync.Mutex
+
+	// versions are the old versions of overwritten files, keyed by the
+	// siapath of the file. versionPolicies are the version policies of
+	// directories, keyed by the siapath of the directory.
+	versions        map[modules.SiaPath][]fileVersion
+	versionPolicies map[modules.SiaPath]modules.VersionPolicy
+	nextVersionID   uint64
+	versionsMu      sync.Mutex
+
+	// trash holds the deleted files and directories that can still be
+	// restored, sorted by id. trashRetention is how long they are kept before
+	// they are purged.
+	trash          []trashEntry
+	trashRetention time.Duration
+	nextTrashID    uint64
+	trashMu        sync.Mutex
+
+	// drains are the hosts whose pieces are being migrated to other hosts,
+	// keyed by the host's public key.
+	drains   map[string]*modules.HostDrainInfo
+	drainsMu sync.Mutex
+
+	// staticChunkCache caches the data of downloaded chunks on disk.
+	staticChunkCache *chunkCache
+
 	// Utilities.
 	cs               modules.ConsensusSet
 	deps             modules.Dependencies
@@ -562,10 +609,16 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	if err != nil {
 		return err
 	}
+	// Set the size of the chunk cache.
+	err = r.staticChunkCache.managedSetMaxSize(s.ChunkCacheSize)
+	if err != nil {
+		return err
+	}
 	// Save the changes.
 	id := r.mu.Lock()
 	r.persist.MaxDownloadSpeed = s.MaxDownloadSpeed
 	r.persist.MaxUploadSpeed = s.MaxUploadSpeed
+	r.persist.ChunkCacheSize = s.ChunkCacheSize
 	err = r.saveSync()
 	r.mu.Unlock(id)
 	if err != nil {
@@ -727,6 +780,7 @@ func (r *Renter) Settings() modules.RenterSettings {
 		IPViolationsCheck: r.hostDB.IPViolationsCheck(),
 		MaxDownloadSpeed:  download,
 		MaxUploadSpeed:    upload,
+		ChunkCacheSize:    r.staticChunkCache.managedStats().MaxSize,
 	}
 }
 
@@ -792,6 +846,17 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 
 		bubbleUpdates: make(map[string]bubbleStatus),
 
+		syncDirs:       make(map[modules.SiaPath]*syncDir),
+		syncDirsNotify: make(chan struct{}, 1),
+
+		packs: make(map[string]*pack),
+
+		versions:        make(map[modules.SiaPath][]fileVersion),
+		versionPolicies: make(map[modules.SiaPath]modules.VersionPolicy),
+		trashRetention:  defaultTrashRetention,
+
+		drains: make(map[string]*modules.HostDrainInfo),
+
 		cs:               cs,
 		deps:             deps,
 		g:                g,
@@ -810,6 +875,21 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 	if err := r.managedInitPersist(); err != nil {
 		return nil, err
 	}
+	if err := r.managedLoadSyncDirs(); err != nil {
+		return nil, err
+	}
+	if err := r.managedLoadPacks(); err != nil {
+		return nil, err
+	}
+	if err := r.managedLoadVersions(); err != nil {
+		return nil, err
+	}
+	if err := r.managedLoadTrash(); err != nil {
+		return nil, err
+	}
+	if err := r.managedLoadChunkCache(); err != nil {
+		return nil, err
+	}
 	// After persist is initialized, push the root directory onto the directory
 	// heap for the repair process.
 	r.managedPushUnexploredDirectory(modules.RootSiaPath())
@@ -833,6 +913,21 @@ func NewCustomRenter(g modules.Gateway, cs modules.ConsensusSet, tpool modules.T
 	// Spin up the snapshot synchronization thread.
 	go r.threadedSynchronizeSnapshots()
 
+	// Spin up the sync dir thread.
+	go r.threadedSyncDirs()
+
+	// Spin up the thread that uploads pending packs.
+	go r.threadedPackFlush()
+
+	// Spin up the thread that prunes old versions.
+	go r.threadedPruneVersions()
+
+	// Spin up the thread that purges the trash.
+	go r.threadedPurgeTrash()
+
+	// Spin up the thread that audits the hosts storing the renter's data.
+	go r.threadedAuditHosts()
+
 	return r, nil
 }
 
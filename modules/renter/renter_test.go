@@ -189,6 +189,8 @@ func (stubHostDB) EstimateHostScore(modules.HostDBEntry, modules.Allowance) (mod
 func (stubHostDB) Host(types.SiaPublicKey) (modules.HostDBEntry, bool) {
 	return modules.HostDBEntry{}, false
 }
+func (stubHostDB) IncrementSuccessfulInteractions(types.SiaPublicKey) {}
+func (stubHostDB) IncrementFailedInteractions(types.SiaPublicKey)     {}
 func (stubHostDB) ScoreBreakdown(modules.HostDBEntry) (modules.HostScoreBreakdown, error) {
 	return modules.HostScoreBreakdown{}, nil
 }
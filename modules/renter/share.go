@@ -0,0 +1,286 @@
+package renter
+
+// share.go implements sharing of siafiles between renters. A share contains
+// the complete siafiles, including the keys needed to decrypt the pieces and
+// the public keys of the hosts storing them, so that another renter can
+// download the files using its own contracts with those hosts.
+//
+// A share starts with a Sia-encoded header, consisting of the share header,
+// the share version, the encryption type and the IV. The header is followed
+// by a gzipped tar archive of the siafiles, which is encrypted with a key
+// derived from a password if one was provided.
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/cipher"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/twofish"
+
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+)
+
+const (
+	// shareVersion140 is the version of shares that contain siafiles in the
+	// 1.4.0 format.
+	shareVersion140 = "1.4.0"
+
+	// maxSharedFileSize is the largest siafile that is accepted from a share.
+	maxSharedFileSize = 1 << 30
+
+	// shareKeyTime, shareKeyMemory and shareKeyThreads are the argon2id
+	// parameters used to derive the key of a share from its password. Shares
+	// are usually sent over untrusted channels, so the key derivation needs
+	// to make guessing the password expensive.
+	shareKeyTime    = 1
+	shareKeyMemory  = 64 * 1024 // KiB
+	shareKeyThreads = 4
+
+	// shareKeySize is the size of the twofish key of an encrypted share.
+	shareKeySize = 32
+)
+
+var (
+	// errSharePasswordRequired is returned when loading an encrypted share
+	// without a password.
+	errSharePasswordRequired = errors.New("share is encrypted, a password is required")
+
+	// errSharedFileTooLarge is returned when a share contains a siafile that
+	// is larger than maxSharedFileSize.
+	errSharedFileTooLarge = errors.New("shared siafile is too large")
+
+	// errSharedFilePacked is returned when a share contains a siafile whose
+	// data is stored in a pack.
+	errSharedFilePacked = errors.New("shared siafile is packed")
+)
+
+// shareKey derives the encryption key of a share from its password, using the
+// IV as the salt.
+func shareKey(password string, iv []byte) []byte {
+	return argon2.IDKey([]byte(password), iv, shareKeyTime, shareKeyMemory, shareKeyThreads, shareKeySize)
+}
+
+// ShareFiles writes the siafiles at the provided siapaths to w, so that they
+// can be loaded by another renter. If password is not empty, the share is
+// encrypted with it. The siafiles within reserved siadirs can't be shared,
+// since sharing a pack would export the key of every file in it.
+func (r *Renter) ShareFiles(w io.Writer, siaPaths []modules.SiaPath, password string) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if len(siaPaths) == 0 {
+		return ErrNoNicknames
+	}
+	if err := checkReservedSiaPaths(siaPaths...); err != nil {
+		return err
+	}
+
+	// Write the header.
+	bh := backupHeader{
+		Version:    shareVersion140,
+		Encryption: encryptionPlaintext,
+	}
+	archive := w
+	if password != "" {
+		bh.Encryption = encryptionTwofish
+		bh.IV = fastrand.Bytes(twofish.BlockSize)
+		c, err := twofish.NewCipher(shareKey(password, bh.IV))
+		if err != nil {
+			return err
+		}
+		archive = cipher.StreamWriter{
+			S: cipher.NewCTR(c, bh.IV),
+			W: w,
+		}
+	}
+	err := encoding.NewEncoder(w).EncodeAll(shareHeader, bh.Version, bh.Encryption, bh.IV)
+	if err != nil {
+		return err
+	}
+
+	// Add the siafiles to the archive.
+	gzw := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gzw)
+	for _, siaPath := range siaPaths {
+		if err := r.managedTarSharedFile(tw, siaPath); err != nil {
+			return errors.Compose(err, tw.Close(), gzw.Close())
+		}
+	}
+	return errors.Compose(tw.Close(), gzw.Close())
+}
+
+// managedTarSharedFile adds the siafile at siaPath to the tar archive of a
+// share.
+func (r *Renter) managedTarSharedFile(tw *tar.Writer, siaPath modules.SiaPath) error {
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to open "+siaPath.String())
+	}
+	defer entry.Close()
+	if _, _, packed := entry.Packed(); packed {
+		return errors.New("packed files can't be shared: " + siaPath.String())
+	}
+	sr, err := entry.SnapshotReader()
+	if err != nil {
+		return err
+	}
+	defer sr.Close()
+	info, err := sr.Stat()
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name: siaPath.String() + modules.SiaFileExtension,
+		Mode: 0600,
+		Size: info.Size(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, sr)
+	return err
+}
+
+// LoadSharedFiles loads the siafiles of a share into the renter. The password
+// is only required if the share is encrypted. If a file already exists at the
+// siapath of a shared file, a suffix is added to the siapath of the shared
+// file. Shares with siafiles at reserved siapaths or with packed siafiles are
+// rejected. The siapaths of the loaded files are returned.
+func (r *Renter) LoadSharedFiles(reader io.Reader, password string) ([]modules.SiaPath, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+
+	// Read the header.
+	var header [15]byte
+	var bh backupHeader
+	err := encoding.NewDecoder(reader, encoding.DefaultAllocLimit).DecodeAll(&header, &bh.Version, &bh.Encryption, &bh.IV)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to read header")
+	} else if header != shareHeader {
+		return nil, ErrBadFile
+	} else if bh.Version != shareVersion140 {
+		return nil, ErrIncompatible
+	}
+	if bh.Encryption != encryptionPlaintext && password == "" {
+		return nil, errSharePasswordRequired
+	}
+	archive, err := wrapReaderInCipher(reader, bh, shareKey(password, bh.IV))
+	if err != nil {
+		return nil, err
+	}
+	gzr, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to decompress share, the password might be wrong")
+	}
+	defer gzr.Close()
+
+	// Load the siafiles.
+	var siaPaths []modules.SiaPath
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return siaPaths, err
+		}
+		if !strings.HasSuffix(header.Name, modules.SiaFileExtension) {
+			return siaPaths, ErrNonShareSuffix
+		}
+		if header.Size > maxSharedFileSize {
+			return siaPaths, errSharedFileTooLarge
+		}
+		siaPath, err := modules.NewSiaPath(strings.TrimSuffix(header.Name, modules.SiaFileExtension))
+		if err != nil {
+			return siaPaths, err
+		}
+		if err := checkReservedSiaPaths(siaPath); err != nil {
+			return siaPaths, err
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return siaPaths, err
+		}
+		siaPath, err = r.managedLoadSharedFile(siaPath, b)
+		if err != nil {
+			return siaPaths, errors.AddContext(err, "unable to load "+header.Name)
+		}
+		siaPaths = append(siaPaths, siaPath)
+	}
+	// The tar reader stops at the end of the archive, before the gzip
+	// trailer. Read the remainder so that the checksum is verified.
+	_, err = io.Copy(ioutil.Discard, gzr)
+	if err != nil {
+		return siaPaths, errors.AddContext(err, "share is corrupted")
+	}
+	return siaPaths, nil
+}
+
+// managedLoadSharedFile adds a shared siafile to the renter and returns the
+// siapath it was added at.
+func (r *Renter) managedLoadSharedFile(siaPath modules.SiaPath, b []byte) (modules.SiaPath, error) {
+	// Load the file. The data of a packed file is stored in a pack that isn't
+	// part of the share.
+	br := bytes.NewReader(b)
+	sf, err := siafile.LoadSiaFileFromReader(br, siaPath.SiaFileSysPath(r.staticFilesDir), r.wal)
+	if err != nil {
+		return modules.SiaPath{}, err
+	}
+	if sf.Metadata().PackedSiaPath != "" {
+		return modules.SiaPath{}, errSharedFilePacked
+	}
+
+	// Create the directory of the file and add the file to the file set.
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		return modules.SiaPath{}, err
+	}
+	siaDirEntry, err := r.staticDirSet.NewSiaDir(dirSiaPath)
+	if err != siadir.ErrPathOverload && err != nil {
+		return modules.SiaPath{}, errors.AddContext(err, "unable to create sia directory for shared file")
+	} else if err == nil {
+		siaDirEntry.Close()
+	}
+	chunks, err := ioutil.ReadAll(br)
+	if err != nil {
+		return modules.SiaPath{}, err
+	}
+	uid := sf.UID()
+	if err := r.staticFileSet.AddExistingSiaFile(sf, chunks); err != nil {
+		return modules.SiaPath{}, err
+	}
+	if err := siaPath.LoadSysPath(r.staticFilesDir, sf.SiaFilePath()); err != nil {
+		return modules.SiaPath{}, err
+	}
+	// New files are assigned a new UID. If the UID didn't change, the renter
+	// already has the file and it is left untouched.
+	if sf.UID() == uid {
+		return siaPath, nil
+	}
+
+	// The local path refers to the file system of the sharing renter, so it
+	// is cleared.
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return modules.SiaPath{}, err
+	}
+	err = entry.SetLocalPath("")
+	if err := errors.Compose(err, entry.Close()); err != nil {
+		return modules.SiaPath{}, err
+	}
+	go r.threadedBubbleMetadata(dirSiaPath)
+	return siaPath, nil
+}
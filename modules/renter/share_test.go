@@ -0,0 +1,220 @@
+package renter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// TestShareFiles checks that files shared by one renter can be loaded by
+// another renter.
+func TestShareFiles(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	rt2, err := newRenterTester(filepath.Join(t.Name(), "recipient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt2.Close()
+
+	// Upload a file.
+	source := filepath.Join(rt.dir, "shared")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+	siaPath, err := modules.NewSiaPath("dir/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sharing requires at least one file.
+	var buf bytes.Buffer
+	if err := rt.renter.ShareFiles(&buf, nil, ""); err != ErrNoNicknames {
+		t.Fatal("expected ErrNoNicknames, got", err)
+	}
+
+	// Share the file with a password.
+	buf.Reset()
+	if err := rt.renter.ShareFiles(&buf, []modules.SiaPath{siaPath}, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	share := buf.Bytes()
+
+	// Loading the share requires the right password.
+	if _, err := rt2.renter.LoadSharedFiles(bytes.NewReader(share), ""); err != errSharePasswordRequired {
+		t.Fatal("expected errSharePasswordRequired, got", err)
+	}
+	if _, err := rt2.renter.LoadSharedFiles(bytes.NewReader(share), "bar"); err == nil {
+		t.Fatal("share was loaded with the wrong password")
+	}
+	if _, err := rt2.renter.LoadSharedFiles(bytes.NewReader(fastrand.Bytes(100)), ""); err == nil {
+		t.Fatal("random data was loaded as a share")
+	}
+	// Loading the share into the sharing renter leaves its file untouched.
+	siaPaths, err := rt.renter.LoadSharedFiles(bytes.NewReader(share), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != 1 || !siaPaths[0].Equals(siaPath) {
+		t.Fatal("unexpected siapaths:", siaPaths)
+	}
+	if fi, err := rt.renter.File(siaPath); err != nil || fi.LocalPath != source {
+		t.Fatal("shared file was modified", err)
+	}
+
+	siaPaths, err = rt2.renter.LoadSharedFiles(bytes.NewReader(share), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != 1 || !siaPaths[0].Equals(siaPath) {
+		t.Fatal("unexpected siapaths:", siaPaths)
+	}
+	fi, err := rt2.renter.File(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.LocalPath != "" {
+		t.Fatal("local path of the shared file was not cleared:", fi.LocalPath)
+	}
+	orig, err := rt.renter.File(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Filesize != 1000 || fi.Filesize != orig.Filesize || fi.CipherType != orig.CipherType {
+		t.Fatal("shared file doesn't match the original")
+	}
+
+	// Loading the share again adds the file with a suffix.
+	siaPaths, err = rt2.renter.LoadSharedFiles(bytes.NewReader(share), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != 1 || !siaPaths[0].Equals(siaPath.AddSuffix(1)) {
+		t.Fatal("unexpected siapaths:", siaPaths)
+	}
+
+	// An unencrypted share can be loaded without a password.
+	buf.Reset()
+	if err := rt.renter.ShareFiles(&buf, []modules.SiaPath{siaPath}, ""); err != nil {
+		t.Fatal(err)
+	}
+	unencrypted := buf.Bytes()
+	siaPaths, err = rt2.renter.LoadSharedFiles(bytes.NewReader(unencrypted), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siaPaths) != 1 || !siaPaths[0].Equals(siaPath.AddSuffix(2)) {
+		t.Fatal("unexpected siapaths:", siaPaths)
+	}
+
+	// Corrupting the gzip checksum at the end of the share is detected.
+	corrupted := append([]byte(nil), unencrypted...)
+	corrupted[len(corrupted)-5] ^= 1
+	if _, err := rt2.renter.LoadSharedFiles(bytes.NewReader(corrupted), ""); err == nil {
+		t.Fatal("share with a corrupted checksum was loaded")
+	}
+}
+
+// TestShareFilesReserved checks that siafiles at reserved siapaths and packed
+// siafiles can neither be shared nor loaded from a share.
+func TestShareFilesReserved(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	// Files within reserved siadirs can't be shared.
+	var buf bytes.Buffer
+	packSiaPath := modules.SiaPath{Path: packsSiaDir + "/pack"}
+	if err := r.ShareFiles(&buf, []modules.SiaPath{packSiaPath}, ""); err != errPackPathReserved {
+		t.Fatal("expected errPackPathReserved, got", err)
+	}
+	versionSiaPath := modules.SiaPath{Path: versionsSiaDir + "/file/0"}
+	if err := r.ShareFiles(&buf, []modules.SiaPath{versionSiaPath}, ""); err != errVersionPathReserved {
+		t.Fatal("expected errVersionPathReserved, got", err)
+	}
+
+	// Shares with files at reserved siapaths are rejected.
+	buf.Reset()
+	err = encoding.NewEncoder(&buf).EncodeAll(shareHeader, shareVersion140, encryptionPlaintext, []byte(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	data := fastrand.Bytes(100)
+	err = tw.WriteHeader(&tar.Header{
+		Name: trashSiaDir + "/file" + modules.SiaFileExtension,
+		Mode: 0600,
+		Size: int64(len(data)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.LoadSharedFiles(&buf, ""); err != errTrashPathReserved {
+		t.Fatal("expected errTrashPathReserved, got", err)
+	}
+
+	// Packed files are rejected.
+	source := filepath.Join(rt.dir, "packed")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	siaPath := modules.RandomSiaPath()
+	if err := r.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath}); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer entry.Close()
+	if err := entry.SetPacked(packSiaPath, 0); err != nil {
+		t.Fatal(err)
+	}
+	sr, err := entry.SnapshotReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(sr)
+	if err := errors.Compose(err, sr.Close()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.managedLoadSharedFile(modules.RandomSiaPath(), b); err != errSharedFilePacked {
+		t.Fatal("expected errSharedFilePacked, got", err)
+	}
+}
@@ -0,0 +1,167 @@
+package siafile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+)
+
+var (
+	// ErrChecksumWrongLen is returned when a checksum with the wrong length is
+	// decoded.
+	ErrChecksumWrongLen = errors.New("encoded checksum has the wrong length")
+)
+
+// Checksum is the SHA-256 checksum of the plaintext data of a file or chunk.
+// The zero value is an unknown checksum, e.g. the checksum of a file that was
+// uploaded before checksums were computed.
+type Checksum [sha256.Size]byte
+
+// NewChecksum returns the checksum of data.
+func NewChecksum(data []byte) Checksum {
+	return sha256.Sum256(data)
+}
+
+// Known returns whether the checksum is known.
+func (c Checksum) Known() bool {
+	return c != Checksum{}
+}
+
+// LoadString loads the checksum from its hex representation.
+func (c *Checksum) LoadString(s string) error {
+	if len(s) != sha256.Size*2 {
+		return ErrChecksumWrongLen
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return errors.AddContext(err, "could not decode checksum")
+	}
+	copy(c[:], b)
+	return nil
+}
+
+// MarshalJSON marshals the checksum as a hex string.
+func (c Checksum) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// String returns the hex representation of the checksum. Unknown checksums are
+// represented by the empty string.
+func (c Checksum) String() string {
+	if !c.Known() {
+		return ""
+	}
+	return hex.EncodeToString(c[:])
+}
+
+// UnmarshalJSON decodes the hex string of the checksum.
+func (c *Checksum) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*c = Checksum{}
+		return nil
+	}
+	return c.LoadString(s)
+}
+
+// Checksum returns the checksum of the plaintext of the whole file.
+func (sf *SiaFile) Checksum() Checksum {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.staticMetadata.Checksum
+}
+
+// ChunkChecksum returns the checksum of the plaintext of the chunk with the
+// given index.
+func (sf *SiaFile) ChunkChecksum(chunkIndex uint64) (Checksum, error) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	if chunkIndex >= uint64(sf.numChunks) {
+		return Checksum{}, errors.New("chunk index out of bounds")
+	}
+	chunk, err := sf.chunk(int(chunkIndex))
+	if err != nil {
+		return Checksum{}, err
+	}
+	return chunk.Checksum, nil
+}
+
+// ChunkChecksums returns the checksums of the chunks that contain data of the
+// file in order. It returns nil if the checksum of one of the chunks is still
+// unknown.
+func (sf *SiaFile) ChunkChecksums() ([]Checksum, error) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	chunkSize := sf.staticChunkSize()
+	numDataChunks := (uint64(sf.staticMetadata.FileSize) + chunkSize - 1) / chunkSize
+	if sf.staticMetadata.NumChunkChecksums < numDataChunks {
+		return nil, nil
+	}
+	checksums := make([]Checksum, 0, numDataChunks)
+	err := sf.iterateChunksReadonly(func(chunk chunk) error {
+		if uint64(chunk.Index) < numDataChunks {
+			checksums = append(checksums, chunk.Checksum)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, checksum := range checksums {
+		if !checksum.Known() {
+			return nil, nil
+		}
+	}
+	return checksums, nil
+}
+
+// SetChecksum sets the checksum of the plaintext of the whole file.
+func (sf *SiaFile) SetChecksum(c Checksum) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted {
+		return errors.New("can't set checksum of deleted file")
+	}
+	sf.staticMetadata.Checksum = c
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
+// SetChunkChecksum sets the checksum of the plaintext of the chunk with the
+// given index. The checksum is stored with the chunk.
+func (sf *SiaFile) SetChunkChecksum(chunkIndex uint64, c Checksum) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted {
+		return errors.New("can't set checksum of deleted file")
+	}
+	if chunkIndex >= uint64(sf.numChunks) {
+		return errors.New("chunk index out of bounds")
+	}
+	chunk, err := sf.chunk(int(chunkIndex))
+	if err != nil {
+		return err
+	}
+	var updates []writeaheadlog.Update
+	if !chunk.Checksum.Known() && c.Known() {
+		sf.staticMetadata.NumChunkChecksums++
+		updates, err = sf.saveMetadataUpdates()
+		if err != nil {
+			return err
+		}
+	}
+	chunk.Checksum = c
+	updates = append(updates, sf.saveChunkUpdate(chunk))
+	return sf.createAndApplyTransaction(updates...)
+}
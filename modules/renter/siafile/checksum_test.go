@@ -0,0 +1,139 @@
+package siafile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// TestChecksumJSON checks that checksums are encoded as hex strings and that
+// unknown checksums are encoded as empty strings.
+func TestChecksumJSON(t *testing.T) {
+	checksums := []Checksum{NewChecksum(fastrand.Bytes(100)), {}}
+	for _, checksum := range checksums {
+		b, err := json.Marshal(checksum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != `"`+checksum.String()+`"` {
+			t.Fatal("checksum wasn't encoded as hex string", string(b))
+		}
+		var decoded Checksum
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != checksum {
+			t.Fatal("decoded checksum doesn't match", decoded, checksum)
+		}
+	}
+	var c Checksum
+	if err := c.LoadString("abcd"); err != ErrChecksumWrongLen {
+		t.Fatal("expected ErrChecksumWrongLen but got", err)
+	}
+}
+
+// TestSetChecksums checks that the checksums of a file and its chunks are
+// persisted and copied into snapshots.
+func TestSetChecksums(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	entry, _, err := newTestSiaFileSetWithFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastChunk := entry.NumChunks() - 1
+	checksum := NewChecksum(fastrand.Bytes(100))
+	chunkChecksum := NewChecksum(fastrand.Bytes(100))
+	if err := entry.SetChecksum(checksum); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.SetChunkChecksum(lastChunk, chunkChecksum); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.SetChunkChecksum(lastChunk+1, chunkChecksum); err == nil {
+		t.Fatal("checksum of chunk out of bounds was set")
+	}
+	// Adding a piece shouldn't drop the checksum of the chunk.
+	pk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+	if err := entry.AddPiece(pk, lastChunk, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load the file again. The checksums should be persisted and the checksums
+	// of the other chunks should be unknown.
+	sf, err := LoadSiaFile(entry.siaFilePath, entry.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf.Checksum() != checksum {
+		t.Fatal("checksum wasn't persisted")
+	}
+	if sf.staticMetadata.NumChunkChecksums != 1 {
+		t.Fatal("wrong number of chunk checksums", sf.staticMetadata.NumChunkChecksums)
+	}
+	if c, err := sf.ChunkChecksum(lastChunk); err != nil || c != chunkChecksum {
+		t.Fatal("chunk checksum wasn't persisted", err)
+	}
+	for i := uint64(0); i < lastChunk; i++ {
+		if c, err := sf.ChunkChecksum(i); err != nil || c.Known() {
+			t.Fatal("checksum of chunk", i, "shouldn't be known", err)
+		}
+	}
+
+	// The snapshot should contain the checksums.
+	snap, err := entry.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Checksum() != checksum || snap.ChunkChecksum(lastChunk) != chunkChecksum || snap.ChunkChecksum(lastChunk+1).Known() {
+		t.Fatal("snapshot has wrong checksums")
+	}
+}
+
+// TestChunkChecksums checks that the checksums of the chunks of a file are
+// only returned once all of them are known.
+func TestChunkChecksums(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	entry, _, err := newTestSiaFileSetWithFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	numChunks := entry.NumChunks()
+	checksums := make([]Checksum, numChunks)
+	for i := range checksums {
+		checksums[i] = NewChecksum(fastrand.Bytes(100))
+	}
+	// Set the checksums in reverse order. The checksums shouldn't be returned
+	// before the last one is set.
+	for i := int(numChunks) - 1; i >= 0; i-- {
+		chunkChecksums, err := entry.ChunkChecksums()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunkChecksums != nil {
+			t.Fatal("checksums were returned before all chunks had a checksum")
+		}
+		if err := entry.SetChunkChecksum(uint64(i), checksums[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunkChecksums, err := entry.ChunkChecksums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chunkChecksums, checksums) {
+		t.Fatal("wrong checksums")
+	}
+}
@@ -1,6 +1,8 @@
 package siafile
 
 import (
+	"crypto/sha256"
+
 	"gitlab.com/NebulousLabs/writeaheadlog"
 
 	"gitlab.com/NebulousLabs/Sia/crypto"
@@ -29,8 +31,17 @@ const (
 
 	// marshaledChunkOverhead is the size of a marshaled chunk on disk minus the
 	// encoded pieces. It consists of the 16 byte extension info, a 2 byte
-	// length prefix for the pieces, and a 1 byte length for the Stuck field.
-	marshaledChunkOverhead = 16 + 2 + 1
+	// length prefix for the pieces, a 1 byte length for the Stuck field and
+	// the checksum of the chunk.
+	marshaledChunkOverhead = 16 + 2 + 1 + checksumSize
+
+	// checksumSize is the size of a marshaled checksum.
+	checksumSize = sha256.Size
+
+	// extensionChecksum is set in the first byte of the extension info of
+	// chunks that store a checksum in front of their pieces. Chunks of
+	// siafiles that were created before checksums were introduced don't.
+	extensionChecksum = 1 << 0
 
 	// pubKeyTablePruneThreshold is the number of unused hosts a SiaFile can
 	// store in its host key table before it is pruned.
@@ -21,6 +21,7 @@ func marshalChunk(chunk chunk) []byte {
 	// Write the extension info.
 	ei := buf.Next(len(chunk.ExtensionInfo))
 	copy(ei, chunk.ExtensionInfo[:])
+	ei[0] |= extensionChecksum
 
 	// Write Stuck bool
 	stuck := buf.Next(1)
@@ -34,6 +35,9 @@ func marshalChunk(chunk chunk) []byte {
 	np := buf.Next(2)
 	binary.LittleEndian.PutUint16(np[:], uint16(chunk.numPieces()))
 
+	// Write the checksum.
+	copy(buf.Next(checksumSize), chunk.Checksum[:])
+
 	// Write the pieces.
 	for pieceIndex, pieceSet := range chunk.Pieces {
 		for _, piece := range pieceSet {
@@ -122,6 +126,14 @@ func unmarshalChunk(numPieces uint32, raw []byte) (chunk chunk, err error) {
 	}
 	piecesToLoad := binary.LittleEndian.Uint16(prefixBytes)
 
+	// read the checksum if the chunk has one.
+	if chunk.ExtensionInfo[0]&extensionChecksum != 0 {
+		chunk.ExtensionInfo[0] &^= extensionChecksum
+		if _, err = io.ReadFull(buf, chunk.Checksum[:]); err != nil {
+			return chunk, errors.AddContext(err, "failed to unmarshal Checksum")
+		}
+	}
+
 	// read the pieces one by one.
 	var loadedPieces uint16
 	for pieceBytes := buf.Next(marshaledPieceSize); loadedPieces < piecesToLoad; pieceBytes = buf.Next(marshaledPieceSize) {
@@ -53,6 +53,30 @@ func TestMarshalUnmarshalChunk(t *testing.T) {
 	}
 }
 
+// TestUnmarshalChunkWithoutChecksum tests unmarshaling a chunk of a siafile
+// that was created before the checksums of chunks were stored.
+func TestUnmarshalChunkWithoutChecksum(t *testing.T) {
+	chunk := randomChunk()
+	chunk.Checksum = Checksum{}
+	numPieces := uint32(len(chunk.Pieces))
+
+	// Remove the checksum and the flag from the marshaled chunk.
+	chunkBytes := marshalChunk(chunk)
+	chunkBytes[0] &^= extensionChecksum
+	headerLen := len(chunk.ExtensionInfo) + 1 + 2
+	chunkBytes = append(chunkBytes[:headerLen], chunkBytes[headerLen+checksumSize:]...)
+
+	unmarshaledChunk, err := unmarshalChunk(numPieces, chunkBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chunk, unmarshaledChunk) {
+		t.Log("original", chunk)
+		t.Log("unmarshaled", unmarshaledChunk)
+		t.Fatal("Unmarshaled chunk doesn't equal marshaled chunk")
+	}
+}
+
 // TestMarshalUnmarshalErasureCoder tests marshaling and unmarshaling an
 // ErasureCoder.
 func TestMarshalUnmarshalErasureCoder(t *testing.T) {
@@ -10,6 +10,7 @@ import (
 	"gitlab.com/NebulousLabs/fastrand"
 	"gitlab.com/NebulousLabs/writeaheadlog"
 
+	"gitlab.com/NebulousLabs/Sia/build"
 	"gitlab.com/NebulousLabs/Sia/crypto"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/types"
@@ -97,6 +98,30 @@ type (
 		Redundancy          float64   `json:"redundancy"`
 		StuckHealth         float64   `json:"stuckhealth"`
 
+		// Packing fields. The data of a packed file isn't stored in its own
+		// chunks but within the single chunk of another siafile, its pack.
+		//
+		// PackedSiaPath is the siapath of the pack. It is empty if the file
+		// isn't packed.
+		//
+		// PackedOffset is the offset of the file's data within the pack's
+		// chunk. The length of the data is the size of the file.
+		//
+		PackedSiaPath string `json:"packedsiapath,omitempty"`
+		PackedOffset  uint64 `json:"packedoffset,omitempty"`
+
+		// UserMetadata holds arbitrary key/value pairs set by the user, e.g.
+		// the content type or the tags of the file. The siafile doesn't
+		// interpret them.
+		UserMetadata map[string]string `json:"usermetadata,omitempty"`
+
+		// Checksum is the SHA-256 checksum of the plaintext of the whole
+		// file. The checksums of the chunks are stored with the chunks and
+		// are used to verify downloaded data end-to-end. NumChunkChecksums is
+		// the number of chunks that have a checksum.
+		Checksum          Checksum `json:"checksum"`
+		NumChunkChecksums uint64   `json:"numchunkchecksums"`
+
 		// File ownership/permission fields.
 		Mode    os.FileMode `json:"mode"`    // unix filemode of the sia file - uint32
 		UserID  int         `json:"userid"`  // id of the user who owns the file
@@ -233,6 +258,23 @@ func (sf *SiaFile) NumStuckChunks() uint64 {
 	return sf.staticMetadata.NumStuckChunks
 }
 
+// Packed returns the siapath of the pack that stores the data of the file and
+// the offset of the data within the pack's chunk. The returned bool is false
+// if the file isn't packed.
+func (sf *SiaFile) Packed() (modules.SiaPath, uint64, bool) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	if sf.staticMetadata.PackedSiaPath == "" {
+		return modules.SiaPath{}, 0, false
+	}
+	packSiaPath, err := modules.NewSiaPath(sf.staticMetadata.PackedSiaPath)
+	if err != nil {
+		build.Critical("invalid pack siapath", err)
+		return modules.SiaPath{}, 0, false
+	}
+	return packSiaPath, sf.staticMetadata.PackedOffset, true
+}
+
 // PieceSize returns the size of a single piece of the file.
 func (sf *SiaFile) PieceSize() uint64 {
 	return sf.staticMetadata.StaticPieceSize
@@ -316,6 +358,43 @@ func (sf *SiaFile) SetLocalPath(path string) error {
 	return sf.createAndApplyTransaction(updates...)
 }
 
+// SetPacked marks the file as packed. Its data is stored at offset within the
+// chunk of the pack at packSiaPath.
+func (sf *SiaFile) SetPacked(packSiaPath modules.SiaPath, offset uint64) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.staticMetadata.PackedSiaPath = packSiaPath.String()
+	sf.staticMetadata.PackedOffset = offset
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
+// SetUserMetadata replaces the user metadata of the file.
+func (sf *SiaFile) SetUserMetadata(md map[string]string) error {
+	if err := ValidateUserMetadata(md); err != nil {
+		return err
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.deleted {
+		return errors.New("can't set user metadata of deleted file")
+	}
+	sf.staticMetadata.UserMetadata = copyUserMetadata(md)
+	sf.staticMetadata.ChangeTime = time.Now()
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
 // Size returns the file's size.
 func (sf *SiaFile) Size() uint64 {
 	sf.mu.RLock()
@@ -323,6 +402,13 @@ func (sf *SiaFile) Size() uint64 {
 	return uint64(sf.staticMetadata.FileSize)
 }
 
+// UserMetadata returns a copy of the user metadata of the file.
+func (sf *SiaFile) UserMetadata() map[string]string {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return copyUserMetadata(sf.staticMetadata.UserMetadata)
+}
+
 // UpdateUniqueID creates a new random uid for the SiaFile.
 func (sf *SiaFile) UpdateUniqueID() {
 	sf.staticMetadata.UniqueID = uniqueID()
@@ -351,3 +437,32 @@ func (sf *SiaFile) staticChunkSize() uint64 {
 func uniqueID() SiafileUID {
 	return SiafileUID(hex.EncodeToString(fastrand.Bytes(20)))
 }
+
+// copyUserMetadata returns a copy of the user metadata md. It returns nil if md
+// is empty.
+func copyUserMetadata(md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	cpy := make(map[string]string, len(md))
+	for k, v := range md {
+		cpy[k] = v
+	}
+	return cpy
+}
+
+// ValidateUserMetadata checks that the user metadata md can be stored in a
+// siafile.
+func ValidateUserMetadata(md map[string]string) error {
+	var size int
+	for k, v := range md {
+		if k == "" {
+			return ErrEmptyUserMetadataKey
+		}
+		size += len(k) + len(v)
+	}
+	if size > MaxUserMetadataSize {
+		return ErrUserMetadataTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package siafile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+type (
+	// userMetadataIndex is an in-memory index of the user metadata of the
+	// siafiles of a SiaFileSet. It maps every tag and every key/value pair to
+	// the siapaths of the files that have it, so that files can be found
+	// without loading the metadata of every siafile. Files without user
+	// metadata are not part of the index.
+	userMetadataIndex struct {
+		metadata map[modules.SiaPath]map[string]string
+		tags     map[string]map[modules.SiaPath]struct{}
+		pairs    map[userMetadataPair]map[modules.SiaPath]struct{}
+	}
+
+	// userMetadataPair is a single key/value pair of the user metadata of a
+	// file.
+	userMetadataPair struct {
+		key   string
+		value string
+	}
+)
+
+// newUserMetadataIndex returns an empty userMetadataIndex.
+func newUserMetadataIndex() *userMetadataIndex {
+	return &userMetadataIndex{
+		metadata: make(map[modules.SiaPath]map[string]string),
+		tags:     make(map[string]map[modules.SiaPath]struct{}),
+		pairs:    make(map[userMetadataPair]map[modules.SiaPath]struct{}),
+	}
+}
+
+// add sets the user metadata of the file at siaPath, replacing any metadata
+// that was indexed for the file before.
+func (idx *userMetadataIndex) add(siaPath modules.SiaPath, md map[string]string) {
+	idx.remove(siaPath)
+	if len(md) == 0 {
+		return
+	}
+	md = copyUserMetadata(md)
+	idx.metadata[siaPath] = md
+	for k, v := range md {
+		pair := userMetadataPair{key: k, value: v}
+		if idx.pairs[pair] == nil {
+			idx.pairs[pair] = make(map[modules.SiaPath]struct{})
+		}
+		idx.pairs[pair][siaPath] = struct{}{}
+	}
+	for _, tag := range modules.FileTags(md) {
+		if idx.tags[tag] == nil {
+			idx.tags[tag] = make(map[modules.SiaPath]struct{})
+		}
+		idx.tags[tag][siaPath] = struct{}{}
+	}
+}
+
+// remove removes the file at siaPath from the index.
+func (idx *userMetadataIndex) remove(siaPath modules.SiaPath) {
+	md, exists := idx.metadata[siaPath]
+	if !exists {
+		return
+	}
+	delete(idx.metadata, siaPath)
+	for k, v := range md {
+		pair := userMetadataPair{key: k, value: v}
+		delete(idx.pairs[pair], siaPath)
+		if len(idx.pairs[pair]) == 0 {
+			delete(idx.pairs, pair)
+		}
+	}
+	for _, tag := range modules.FileTags(md) {
+		delete(idx.tags[tag], siaPath)
+		if len(idx.tags[tag]) == 0 {
+			delete(idx.tags, tag)
+		}
+	}
+}
+
+// rename moves the metadata of the file at siaPath to newSiaPath.
+func (idx *userMetadataIndex) rename(siaPath, newSiaPath modules.SiaPath) {
+	md, exists := idx.metadata[siaPath]
+	if !exists {
+		return
+	}
+	idx.remove(siaPath)
+	idx.add(newSiaPath, md)
+}
+
+// removeDir removes all files within the directory at dir from the index.
+func (idx *userMetadataIndex) removeDir(dir modules.SiaPath) {
+	for siaPath := range idx.metadata {
+		if siaPath.IsWithin(dir) {
+			idx.remove(siaPath)
+		}
+	}
+}
+
+// renameDir moves the metadata of all files within the directory at oldPath
+// to newPath.
+func (idx *userMetadataIndex) renameDir(oldPath, newPath modules.SiaPath) {
+	var siaPaths []modules.SiaPath
+	for siaPath := range idx.metadata {
+		if siaPath.IsWithin(oldPath) {
+			siaPaths = append(siaPaths, siaPath)
+		}
+	}
+	for _, siaPath := range siaPaths {
+		newSiaPath, err := siaPath.Rebase(oldPath, newPath)
+		if err != nil {
+			idx.remove(siaPath)
+			continue
+		}
+		idx.rename(siaPath, newSiaPath)
+	}
+}
+
+// find returns the siapaths of the files whose user metadata contains the tag
+// and all of the key/value pairs of query, sorted by siapath. An empty tag
+// matches any metadata. The smallest of the matching sets is scanned.
+func (idx *userMetadataIndex) find(tag string, query map[string]string) []modules.SiaPath {
+	var candidates map[modules.SiaPath]struct{}
+	if tag != "" {
+		candidates = idx.tags[tag]
+	}
+	for k, v := range query {
+		set := idx.pairs[userMetadataPair{key: k, value: v}]
+		if candidates == nil || len(set) < len(candidates) {
+			candidates = set
+		}
+	}
+	var siaPaths []modules.SiaPath
+	for siaPath := range candidates {
+		if userMetadataMatches(idx.metadata[siaPath], tag, query) {
+			siaPaths = append(siaPaths, siaPath)
+		}
+	}
+	sort.Slice(siaPaths, func(i, j int) bool {
+		return siaPaths[i].Path < siaPaths[j].Path
+	})
+	return siaPaths
+}
+
+// userMetadataMatches returns true if the user metadata contains the tag and
+// all of the key/value pairs of query. An empty tag matches any metadata.
+func userMetadataMatches(userMetadata map[string]string, tag string, query map[string]string) bool {
+	for k, v := range query {
+		if value, exists := userMetadata[k]; !exists || value != v {
+			return false
+		}
+	}
+	if tag == "" {
+		return true
+	}
+	for _, t := range modules.FileTags(userMetadata) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// buildUserMetadataIndex creates the user metadata index from the siafiles on
+// disk. The metadata of files that are open is taken from memory. The
+// SiaFileSet lock must be held.
+func (sfs *SiaFileSet) buildUserMetadataIndex() error {
+	idx := newUserMetadataIndex()
+	err := filepath.Walk(sfs.staticSiaFileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != modules.SiaFileExtension {
+			return nil
+		}
+		var siaPath modules.SiaPath
+		if err := siaPath.FromSysPath(path, sfs.staticSiaFileDir); err != nil {
+			return err
+		}
+		if entry, _, exists := sfs.siaPathToEntryAndUID(siaPath); exists {
+			idx.add(siaPath, entry.UserMetadata())
+			return nil
+		}
+		md, err := LoadSiaFileMetadata(path)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		idx.add(siaPath, md.UserMetadata)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sfs.userMetadataIndex = idx
+	return nil
+}
+
+// FindFiles returns the siapaths of the files whose user metadata contains the
+// tag and all of the key/value pairs of query, sorted by siapath. The index is
+// built from disk the first time FindFiles is called and kept up to date
+// afterwards.
+func (sfs *SiaFileSet) FindFiles(tag string, query map[string]string) ([]modules.SiaPath, error) {
+	sfs.mu.Lock()
+	defer sfs.mu.Unlock()
+	if sfs.userMetadataIndex == nil {
+		if err := sfs.buildUserMetadataIndex(); err != nil {
+			return nil, err
+		}
+	}
+	return sfs.userMetadataIndex.find(tag, query), nil
+}
+
+// addToUserMetadataIndex sets the user metadata of the file at siaPath in the
+// index if the index was built already. The SiaFileSet lock must be held.
+func (sfs *SiaFileSet) addToUserMetadataIndex(siaPath modules.SiaPath, md map[string]string) {
+	if sfs.userMetadataIndex != nil {
+		sfs.userMetadataIndex.add(siaPath, md)
+	}
+}
+
+// removeFromUserMetadataIndex removes the file at siaPath from the index if
+// the index was built already. The SiaFileSet lock must be held.
+func (sfs *SiaFileSet) removeFromUserMetadataIndex(siaPath modules.SiaPath) {
+	if sfs.userMetadataIndex != nil {
+		sfs.userMetadataIndex.remove(siaPath)
+	}
+}
+
+// SetUserMetadata replaces the user metadata of the file and updates the user
+// metadata index of the SiaFileSet.
+func (entry *SiaFileSetEntry) SetUserMetadata(md map[string]string) error {
+	sfs := entry.staticSiaFileSet
+	sfs.mu.Lock()
+	defer sfs.mu.Unlock()
+	if err := entry.SiaFile.SetUserMetadata(md); err != nil {
+		return err
+	}
+	sfs.addToUserMetadataIndex(sfs.siaPath(entry.siaFileSetEntry), md)
+	return nil
+}
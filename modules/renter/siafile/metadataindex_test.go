@@ -0,0 +1,175 @@
+package siafile
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// TestUserMetadataMatches probes the matching of user metadata against tags and
+// queries.
+func TestUserMetadataMatches(t *testing.T) {
+	md := map[string]string{
+		modules.UserMetadataContentType: "text/plain",
+		modules.UserMetadataTags:        " docs, backup ,,",
+	}
+	tests := []struct {
+		tag     string
+		query   map[string]string
+		matches bool
+	}{
+		{"", nil, true},
+		{"docs", nil, true},
+		{"backup", nil, true},
+		{"back", nil, false},
+		{"", map[string]string{modules.UserMetadataContentType: "text/plain"}, true},
+		{"docs", map[string]string{modules.UserMetadataContentType: "text/plain"}, true},
+		{"docs", map[string]string{modules.UserMetadataContentType: "image/png"}, false},
+		{"", map[string]string{"app": ""}, false},
+	}
+	for _, test := range tests {
+		if userMetadataMatches(md, test.tag, test.query) != test.matches {
+			t.Errorf("tag %q and query %v: expected %v", test.tag, test.query, test.matches)
+		}
+	}
+	if tags := modules.FileTags(md); len(tags) != 2 || tags[0] != "docs" || tags[1] != "backup" {
+		t.Fatal("wrong tags", tags)
+	}
+}
+
+// TestSiaFileSetFindFiles checks that the user metadata index of the
+// SiaFileSet is built from disk and kept up to date when files are created,
+// deleted and renamed and when their metadata changes.
+func TestSiaFileSetFindFiles(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	entry, sfs, err := newTestSiaFileSetWithFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPath := sfs.SiaPath(entry)
+	if err := entry.SetUserMetadata(map[string]string{modules.UserMetadataTags: "docs"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// newFile creates a file with the provided user metadata.
+	newFile := func(path string, md map[string]string) modules.SiaPath {
+		sp, err := modules.NewSiaPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, source, rc, sk, fileSize, _, fileMode := newTestFileParams()
+		up := modules.FileUploadParams{Source: source, SiaPath: sp, ErasureCode: rc, UserMetadata: md}
+		entry, err := sfs.NewSiaFile(up, sk, fileSize, fileMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := entry.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return sp
+	}
+	// find returns the files that match tag and query.
+	find := func(tag string, query map[string]string) []modules.SiaPath {
+		siaPaths, err := sfs.FindFiles(tag, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return siaPaths
+	}
+	// The file was created before the index, so the index needs to be built
+	// from disk.
+	if sfs.userMetadataIndex != nil {
+		t.Fatal("index shouldn't exist yet")
+	}
+	if siaPaths := find("docs", nil); !reflect.DeepEqual(siaPaths, []modules.SiaPath{siaPath}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// New files are added to the index.
+	png := map[string]string{modules.UserMetadataContentType: "image/png"}
+	a := newFile("dir/a", map[string]string{modules.UserMetadataContentType: "image/png", modules.UserMetadataTags: "docs"})
+	b := newFile("dir/sub/b", png)
+	newFile("c", nil)
+	if siaPaths := find("docs", nil); len(siaPaths) != 2 {
+		t.Fatal("wrong files", siaPaths)
+	}
+	if siaPaths := find("docs", png); !reflect.DeepEqual(siaPaths, []modules.SiaPath{a}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	if siaPaths := find("", png); !reflect.DeepEqual(siaPaths, []modules.SiaPath{a, b}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// Changing the metadata updates the index.
+	entry, err = sfs.Open(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.SetUserMetadata(png); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if siaPaths := find("docs", nil); !reflect.DeepEqual(siaPaths, []modules.SiaPath{a}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// Deleting a file removes it from the index.
+	if err := sfs.Delete(siaPath); err != nil {
+		t.Fatal(err)
+	}
+	if siaPaths := find("", png); !reflect.DeepEqual(siaPaths, []modules.SiaPath{a, b}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// Renaming a file moves it within the index.
+	renamed, err := modules.NewSiaPath("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sfs.Rename(a, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if siaPaths := find("docs", nil); !reflect.DeepEqual(siaPaths, []modules.SiaPath{renamed}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// Renaming a dir moves the files within it.
+	dir, err1 := modules.NewSiaPath("dir")
+	newDir, err2 := modules.NewSiaPath("newdir")
+	newB, err3 := modules.NewSiaPath("newdir/sub/b")
+	if err1 != nil || err2 != nil || err3 != nil {
+		t.Fatal(err1, err2, err3)
+	}
+	rename := func(oldPath, newPath modules.SiaPath) error {
+		return os.Rename(oldPath.SiaDirSysPath(sfs.staticSiaFileDir), newPath.SiaDirSysPath(sfs.staticSiaFileDir))
+	}
+	if err := sfs.RenameDir(dir, newDir, rename); err != nil {
+		t.Fatal(err)
+	}
+	if siaPaths := find("", png); !reflect.DeepEqual(siaPaths, []modules.SiaPath{newB, renamed}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// Deleting a dir removes the files within it.
+	deleteDir := func(siaPath modules.SiaPath) error {
+		return os.RemoveAll(siaPath.SiaDirSysPath(sfs.staticSiaFileDir))
+	}
+	if err := sfs.DeleteDir(newDir, deleteDir); err != nil {
+		t.Fatal(err)
+	}
+	if siaPaths := find("", png); !reflect.DeepEqual(siaPaths, []modules.SiaPath{renamed}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	// A rebuilt index matches the updated one.
+	updated := sfs.userMetadataIndex
+	sfs.userMetadataIndex = nil
+	if siaPaths := find("", png); !reflect.DeepEqual(siaPaths, []modules.SiaPath{renamed}) {
+		t.Fatal("wrong files", siaPaths)
+	}
+	if !reflect.DeepEqual(updated.metadata, sfs.userMetadataIndex.metadata) {
+		t.Fatal("rebuilt index doesn't match", updated.metadata, sfs.userMetadataIndex.metadata)
+	}
+}
@@ -825,3 +825,51 @@ func TestUniqueIDMissing(t *testing.T) {
 		t.Fatal("unique ID wasn't set after loading file")
 	}
 }
+
+// TestUserMetadata checks that the user metadata of a file is validated and
+// persisted.
+func TestUserMetadata(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a new file and set its user metadata.
+	sf, wal, _ := newBlankTestFileAndWAL()
+	md := map[string]string{"content-type": "text/plain", "tags": "a,b"}
+	if err := sf.SetUserMetadata(md); err != nil {
+		t.Fatal(err)
+	}
+	// Changing the map afterwards shouldn't change the metadata of the file.
+	md["tags"] = "c"
+	if sf.UserMetadata()["tags"] != "a,b" {
+		t.Fatal("user metadata of file was changed", sf.UserMetadata())
+	}
+	// Invalid metadata should be rejected.
+	if err := sf.SetUserMetadata(map[string]string{"": "foo"}); err != ErrEmptyUserMetadataKey {
+		t.Fatal("expected ErrEmptyUserMetadataKey but got", err)
+	}
+	tooLarge := map[string]string{"foo": string(make([]byte, MaxUserMetadataSize))}
+	if err := sf.SetUserMetadata(tooLarge); err != ErrUserMetadataTooLarge {
+		t.Fatal("expected ErrUserMetadataTooLarge but got", err)
+	}
+	// Load the file again. The metadata should be persisted.
+	sf, err := LoadSiaFile(sf.siaFilePath, wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md := sf.UserMetadata(); len(md) != 2 || md["content-type"] != "text/plain" || md["tags"] != "a,b" {
+		t.Fatal("user metadata wasn't persisted", md)
+	}
+	// Clearing the metadata should remove it.
+	if err := sf.SetUserMetadata(nil); err != nil {
+		t.Fatal(err)
+	}
+	sf, err = LoadSiaFile(sf.siaFilePath, wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf.UserMetadata() != nil {
+		t.Fatal("user metadata wasn't cleared", sf.UserMetadata())
+	}
+}
@@ -27,6 +27,18 @@ var (
 	// ErrUnknownThread is an error when a SiaFile is trying to be closed by a
 	// thread that is not in the threadMap
 	ErrUnknownThread = errors.New("thread should not be calling Close(), does not have control of the siafile")
+	// ErrEmptyUserMetadataKey is returned if the user metadata of a file
+	// contains an empty key.
+	ErrEmptyUserMetadataKey = errors.New("user metadata keys can't be empty")
+	// ErrUserMetadataTooLarge is returned if the keys and values of the user
+	// metadata of a file exceed MaxUserMetadataSize.
+	ErrUserMetadataTooLarge = fmt.Errorf("user metadata can't be larger than %v bytes", MaxUserMetadataSize)
+)
+
+const (
+	// MaxUserMetadataSize is the maximum combined size of the keys and values
+	// of the user metadata of a file.
+	MaxUserMetadataSize = 4096
 )
 
 type (
@@ -71,6 +83,10 @@ type (
 		// Pieces are the Pieces of the file the chunk consists of.
 		Pieces [][]piece
 
+		// Checksum is the checksum of the plaintext of the chunk, excluding
+		// the padding of the last chunk.
+		Checksum Checksum
+
 		// Stuck indicates if the chunk was not repaired as expected by the
 		// repair loop
 		Stuck bool
@@ -326,6 +342,60 @@ func (sf *SiaFile) AddPiece(pk types.SiaPublicKey, chunkIndex, pieceIndex uint64
 	return sf.createAndApplyTransaction(append(updates, chunkUpdate)...)
 }
 
+// RemovePiece removes the piece with the given Merkle root that is stored on
+// the host with the given public key from the chunk. This is used to mark a
+// piece as lost so that the repair code uploads it again. Removing a piece
+// that doesn't exist is a no-op.
+func (sf *SiaFile) RemovePiece(pk types.SiaPublicKey, chunkIndex, pieceIndex uint64, merkleRoot crypto.Hash) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// If the file was deleted we can't remove a piece since it would write
+	// the file to disk again.
+	if sf.deleted {
+		return errors.New("can't remove piece from deleted file")
+	}
+	// Check if the chunkIndex is valid.
+	if chunkIndex >= uint64(sf.numChunks) {
+		return fmt.Errorf("chunkIndex %v out of bounds (%v)", chunkIndex, sf.numChunks)
+	}
+	// Get the chunk from disk.
+	chunk, err := sf.chunk(int(chunkIndex))
+	if err != nil {
+		return errors.AddContext(err, "failed to get chunk")
+	}
+	// Check if the pieceIndex is valid.
+	if pieceIndex >= uint64(len(chunk.Pieces)) {
+		return fmt.Errorf("pieceIndex %v out of bounds (%v)", pieceIndex, len(chunk.Pieces))
+	}
+	// Filter out the piece.
+	var pieceSet []piece
+	for _, p := range chunk.Pieces[pieceIndex] {
+		hpk := sf.hostKey(p.HostTableOffset).PublicKey
+		if p.MerkleRoot == merkleRoot && hpk.Algorithm == pk.Algorithm && bytes.Equal(hpk.Key, pk.Key) {
+			continue
+		}
+		pieceSet = append(pieceSet, p)
+	}
+	if len(pieceSet) == len(chunk.Pieces[pieceIndex]) {
+		return nil
+	}
+	chunk.Pieces[pieceIndex] = pieceSet
+
+	// Update cache.
+	defer sf.uploadProgressAndBytes()
+
+	// Update the ChangeTime.
+	sf.staticMetadata.ChangeTime = time.Now()
+
+	// Update the file atomically.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	chunkUpdate := sf.saveChunkUpdate(chunk)
+	return sf.createAndApplyTransaction(append(updates, chunkUpdate)...)
+}
+
 // chunkHealth returns the health of the chunk which is defined as the percent
 // of parity pieces remaining.
 //
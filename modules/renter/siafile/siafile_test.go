@@ -1,6 +1,7 @@
 package siafile
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -36,6 +37,8 @@ func randomChunk() chunk {
 	chunk := chunk{}
 	chunk.Pieces = make([][]piece, numPieces)
 	fastrand.Read(chunk.ExtensionInfo[:])
+	chunk.ExtensionInfo[0] &^= extensionChecksum
+	fastrand.Read(chunk.Checksum[:])
 
 	// Add 0-3 pieces for each pieceIndex within the file.
 	for pieceIndex := range chunk.Pieces {
@@ -577,6 +580,62 @@ func TestUploadedBytes(t *testing.T) {
 	}
 }
 
+// TestRemovePiece tests that RemovePiece only removes the piece of the given
+// host with the given root and that the removal is persisted.
+func TestRemovePiece(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	sf, sfs, err := newTestSiaFileSetWithFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk1 := types.SiaPublicKey{Key: []byte{byte(1)}}
+	pk2 := types.SiaPublicKey{Key: []byte{byte(2)}}
+	root1, root2 := crypto.Hash{1}, crypto.Hash{2}
+	err1 := sf.AddPiece(pk1, 0, 0, root1)
+	err2 := sf.AddPiece(pk1, 0, 0, root2)
+	err3 := sf.AddPiece(pk2, 0, 0, root1)
+	if err := errors.Compose(err1, err2, err3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the first piece of the first host. Removing it a second time is
+	// a no-op.
+	for i := 0; i < 2; i++ {
+		if err := sf.RemovePiece(pk1, 0, 0, root1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sf.RemovePiece(pk1, sf.NumChunks(), 0, root1); err == nil {
+		t.Fatal("expected error for out of bounds chunk")
+	}
+
+	// Reload the file and check the remaining pieces.
+	siaPath := sfs.SiaPath(sf)
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sf, err = sfs.Open(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces, err := sf.Pieces(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces[0]) != 2 {
+		t.Fatal("expected 2 remaining pieces, got", len(pieces[0]))
+	}
+	for _, p := range pieces[0] {
+		if p.MerkleRoot == root1 && bytes.Equal(p.HostPubKey.Key, pk1.Key) {
+			t.Fatal("piece wasn't removed")
+		}
+	}
+}
+
 // TestFileUploadProgressPinning verifies that uploadProgress() returns at most
 // 100%, even if more pieces have been uploaded,
 func TestFileUploadProgressPinning(t *testing.T) {
@@ -20,6 +20,7 @@ import (
 	"gitlab.com/NebulousLabs/Sia/crypto"
 	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+	"gitlab.com/NebulousLabs/Sia/types"
 )
 
 // The SiaFileSet structure helps track the number of threads using a siafile
@@ -34,6 +35,10 @@ type (
 		siaFileMap       map[SiafileUID]*siaFileSetEntry
 		siapathToUID     map[modules.SiaPath]SiafileUID
 
+		// userMetadataIndex indexes the user metadata of the siafiles. It is
+		// built on the first call to FindFiles and nil until then.
+		userMetadataIndex *userMetadataIndex
+
 		// utilities
 		mu  sync.Mutex
 		wal *writeaheadlog.WAL
@@ -243,7 +248,11 @@ func (sfs *SiaFileSet) deleteFile(siaPath modules.SiaPath) error {
 		// If the entry does not exist then we want to just remove the entry from disk
 		// without loading it from disk to avoid errors due to corrupt siafiles
 		update := createDeleteUpdate(siaFilePath)
-		return sfs.createAndApplyTransaction(update)
+		if err := sfs.createAndApplyTransaction(update); err != nil {
+			return err
+		}
+		sfs.removeFromUserMetadataIndex(siaPath)
+		return nil
 	}
 
 	// Delete SiaFile
@@ -251,6 +260,7 @@ func (sfs *SiaFileSet) deleteFile(siaPath modules.SiaPath) error {
 	if err != nil {
 		return err
 	}
+	sfs.removeFromUserMetadataIndex(siaPath)
 	// Remove the siafile from the set maps so that other threads can't find
 	// it.
 	delete(sfs.siaFileMap, entry.UID())
@@ -320,6 +330,7 @@ func (sfs *SiaFileSet) readLockCachedFileInfo(siaPath modules.SiaPath, offline m
 		AccessTime:       md.AccessTime,
 		Available:        md.CachedRedundancy >= 1,
 		ChangeTime:       md.ChangeTime,
+		Checksum:         md.Checksum.String(),
 		CipherType:       md.StaticMasterKeyType.String(),
 		CreateTime:       md.CreateTime,
 		Expiration:       md.CachedExpiration,
@@ -339,6 +350,21 @@ func (sfs *SiaFileSet) readLockCachedFileInfo(siaPath modules.SiaPath, offline m
 		StuckHealth:      md.CachedStuckHealth,
 		UploadedBytes:    md.CachedUploadedBytes,
 		UploadProgress:   md.CachedUploadProgress,
+		UserMetadata:     copyUserMetadata(md.UserMetadata),
+	}
+	if md.PackedSiaPath != "" {
+		packSiaPath, err := modules.NewSiaPath(md.PackedSiaPath)
+		if err != nil {
+			return modules.FileInfo{}, err
+		}
+		packMD, err := sfs.readLockMetadata(packSiaPath)
+		if err == ErrUnknownPath {
+			setPendingPackedFileInfo(&fileInfo)
+		} else if err != nil {
+			return modules.FileInfo{}, errors.AddContext(err, "failed to get pack metadata")
+		} else {
+			setPackedFileInfo(&fileInfo, packMD.CachedHealth, packMD.CachedStuckHealth, packMD.NumStuckChunks, packMD.CachedRedundancy, packMD.CachedUploadProgress, packMD.CachedUploadedBytes, uint64(packMD.FileSize), packMD.CachedExpiration)
+		}
 	}
 	return fileInfo, nil
 }
@@ -471,10 +497,14 @@ func (sfs *SiaFileSet) addExistingSiaFile(sf *SiaFile, chunks []byte, suffix uin
 	if !exists {
 		sf.UpdateUniqueID()
 		if suffix > 0 {
-			siaFilePath := siaPath.AddSuffix(suffix).SiaFileSysPath(sfs.staticSiaFileDir)
-			sf.SetSiaFilePath(siaFilePath)
+			siaPath = siaPath.AddSuffix(suffix)
+			sf.SetSiaFilePath(siaPath.SiaFileSysPath(sfs.staticSiaFileDir))
+		}
+		if err := sf.SaveWithChunks(chunks); err != nil {
+			return err
 		}
-		return sf.SaveWithChunks(chunks)
+		sfs.addToUserMetadataIndex(siaPath, sf.UserMetadata())
+		return nil
 	}
 	// If it exists and the UID matches too, skip the file.
 	if sf.UID() == oldFile.UID() {
@@ -530,6 +560,7 @@ func (sfs *SiaFileSet) FileInfo(siaPath modules.SiaPath, offline map[string]bool
 		AccessTime:       entry.AccessTime(),
 		Available:        redundancy >= 1,
 		ChangeTime:       entry.ChangeTime(),
+		Checksum:         entry.Checksum().String(),
 		CipherType:       entry.MasterKey().Type().String(),
 		CreateTime:       entry.CreateTime(),
 		Expiration:       entry.Expiration(contracts),
@@ -549,10 +580,87 @@ func (sfs *SiaFileSet) FileInfo(siaPath modules.SiaPath, offline map[string]bool
 		StuckHealth:      stuckHealth,
 		UploadedBytes:    uploadedBytes,
 		UploadProgress:   uploadProgress,
+		UserMetadata:     entry.UserMetadata(),
+	}
+	if packSiaPath, _, packed := entry.Packed(); packed {
+		if err := sfs.updatePackedFileInfo(&fileInfo, packSiaPath, offline, goodForRenew, contracts); err != nil {
+			return modules.FileInfo{}, errors.AddContext(err, "failed to get pack info")
+		}
 	}
 	return fileInfo, nil
 }
 
+// updatePackedFileInfo replaces the health, redundancy and upload progress in
+// the info of a packed file with those of its pack.
+func (sfs *SiaFileSet) updatePackedFileInfo(fi *modules.FileInfo, packSiaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) error {
+	pack, err := sfs.Open(packSiaPath)
+	if err == ErrUnknownPath {
+		setPendingPackedFileInfo(fi)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer pack.Close()
+	health, stuckHealth, numStuckChunks := pack.Health(offline, goodForRenew)
+	redundancy, err := pack.Redundancy(offline, goodForRenew)
+	if err != nil {
+		return err
+	}
+	uploadProgress, uploadedBytes, err := pack.UploadProgressAndBytes()
+	if err != nil {
+		return err
+	}
+	setPackedFileInfo(fi, health, stuckHealth, numStuckChunks, redundancy, uploadProgress, uploadedBytes, pack.Size(), pack.Expiration(contracts))
+	return nil
+}
+
+// setPackedFileInfo sets the health, redundancy and upload progress of a
+// packed file's info to those of its pack. The uploaded bytes of the pack are
+// attributed to its files in proportion to their size.
+func setPackedFileInfo(fi *modules.FileInfo, health, stuckHealth float64, numStuckChunks uint64, redundancy, uploadProgress float64, packUploadedBytes, packSize uint64, expiration types.BlockHeight) {
+	maxHealth := math.Max(health, stuckHealth)
+	fi.Packed = true
+	fi.Available = redundancy >= 1
+	fi.Expiration = expiration
+	fi.Health = health
+	fi.MaxHealth = maxHealth
+	fi.MaxHealthPercent = siadir.HealthPercentage(maxHealth)
+	fi.NumStuckChunks = numStuckChunks
+	fi.Recoverable = fi.OnDisk || redundancy >= 1
+	fi.Redundancy = redundancy
+	fi.Stuck = numStuckChunks > 0
+	fi.StuckHealth = stuckHealth
+	fi.UploadProgress = uploadProgress
+	fi.UploadedBytes = 0
+	if packSize > 0 {
+		fi.UploadedBytes = packUploadedBytes * fi.Filesize / packSize
+	}
+}
+
+// setPendingPackedFileInfo sets the info of a packed file whose pack hasn't
+// been uploaded yet. The file isn't available, but it doesn't need to be
+// repaired either since the pack is uploaded once it's full.
+func setPendingPackedFileInfo(fi *modules.FileInfo) {
+	setPackedFileInfo(fi, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+// PackHealth returns the health, stuck health, number of stuck chunks and
+// redundancy of the pack at packSiaPath, which are also the health and
+// redundancy of the files stored in the pack. A pack that hasn't been uploaded
+// yet has a redundancy of 0 but doesn't need to be repaired.
+func (sfs *SiaFileSet) PackHealth(packSiaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool) (float64, float64, uint64, float64, error) {
+	pack, err := sfs.Open(packSiaPath)
+	if err == ErrUnknownPath {
+		return 0, 0, 0, 0, nil
+	} else if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	defer pack.Close()
+	health, stuckHealth, numStuckChunks := pack.Health(offline, goodForRenew)
+	redundancy, err := pack.Redundancy(offline, goodForRenew)
+	return health, stuckHealth, numStuckChunks, redundancy, err
+}
+
 // CachedFileInfo returns a modules.FileInfo for a given file like FileInfo but
 // instead of computing redundancy, health etc. it uses cached values.
 func (sfs *SiaFileSet) CachedFileInfo(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) (modules.FileInfo, error) {
@@ -661,16 +769,25 @@ func (sfs *SiaFileSet) NewSiaFile(up modules.FileUploadParams, masterKey crypto.
 	if exists && !up.Force {
 		return nil, ErrPathOverload
 	}
+	if err := ValidateUserMetadata(up.UserMetadata); err != nil {
+		return nil, err
+	}
 	// Make sure there are no leading slashes
 	siaFilePath := up.SiaPath.SiaFileSysPath(sfs.staticSiaFileDir)
 	sf, err := New(up.SiaPath, siaFilePath, up.Source, sfs.wal, up.ErasureCode, masterKey, fileSize, fileMode)
 	if err != nil {
 		return nil, err
 	}
+	if len(up.UserMetadata) > 0 {
+		if err := sf.SetUserMetadata(up.UserMetadata); err != nil {
+			return nil, errors.Compose(err, sf.Delete())
+		}
+	}
 	entry, err := sfs.newSiaFileSetEntry(sf)
 	if err != nil {
 		return nil, err
 	}
+	sfs.addToUserMetadataIndex(up.SiaPath, up.UserMetadata)
 	threadUID := randomThreadUID()
 	entry.threadMap[threadUID] = newThreadInfo()
 	return &SiaFileSetEntry{
@@ -714,7 +831,14 @@ func (sfs *SiaFileSet) Rename(siaPath, newSiaPath modules.SiaPath) error {
 	delete(sfs.siapathToUID, siaPath)
 
 	// Update the siafile to have a new name.
-	return entry.Rename(newSiaPath, newSiaPath.SiaFileSysPath(sfs.staticSiaFileDir))
+	err = entry.Rename(newSiaPath, newSiaPath.SiaFileSysPath(sfs.staticSiaFileDir))
+	if err != nil {
+		return err
+	}
+	if sfs.userMetadataIndex != nil {
+		sfs.userMetadataIndex.rename(siaPath, newSiaPath)
+	}
+	return nil
 }
 
 // DeleteDir deletes a siadir and all the siadirs and siafiles within it
@@ -744,6 +868,9 @@ func (sfs *SiaFileSet) DeleteDir(siaPath modules.SiaPath, deleteDir siadir.Delet
 	if err := deleteDir(siaPath); err != nil {
 		return errors.AddContext(err, "failed to delete dir")
 	}
+	if sfs.userMetadataIndex != nil {
+		sfs.userMetadataIndex.removeDir(siaPath)
+	}
 	// Delete was successful. Delete the siafiles in memory before they are being
 	// unlocked again.
 	for _, entry := range lockedFiles {
@@ -796,6 +923,9 @@ func (sfs *SiaFileSet) RenameDir(oldPath, newPath modules.SiaPath, rename siadir
 	if err := rename(oldPath, newPath); err != nil {
 		return errors.AddContext(err, "failed to rename dir")
 	}
+	if sfs.userMetadataIndex != nil {
+		sfs.userMetadataIndex.renameDir(oldPath, newPath)
+	}
 	// Rename was successful. Rename the siafiles in memory before they are being
 	// unlocked again.
 	for _, entry := range lockedFiles {
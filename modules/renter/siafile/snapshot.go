@@ -14,14 +14,17 @@ type (
 	// can be accessed without locking at the cost of being a frozen readonly
 	// representation of a siafile which only exists in memory.
 	Snapshot struct {
-		staticChunks      []Chunk
-		staticFileSize    int64
-		staticPieceSize   uint64
-		staticErasureCode modules.ErasureCoder
-		staticMasterKey   crypto.CipherKey
-		staticMode        os.FileMode
-		staticPubKeyTable []HostPublicKey
-		staticSiaPath     modules.SiaPath
+		staticChecksum       Checksum
+		staticChunkChecksums []Checksum
+		staticChunks         []Chunk
+		staticFileSize       int64
+		staticPieceSize      uint64
+		staticErasureCode    modules.ErasureCoder
+		staticMasterKey      crypto.CipherKey
+		staticMode           os.FileMode
+		staticPubKeyTable    []HostPublicKey
+		staticSiaPath        modules.SiaPath
+		staticUID            SiafileUID
 	}
 )
 
@@ -80,6 +83,20 @@ func (sf *SiaFile) SnapshotReader() (*SnapshotReader, error) {
 	}, nil
 }
 
+// Checksum returns the checksum of the plaintext of the whole file.
+func (s *Snapshot) Checksum() Checksum {
+	return s.staticChecksum
+}
+
+// ChunkChecksum returns the checksum of the plaintext of the chunk with the
+// given index.
+func (s *Snapshot) ChunkChecksum(chunkIndex uint64) Checksum {
+	if chunkIndex >= uint64(len(s.staticChunkChecksums)) {
+		return Checksum{}
+	}
+	return s.staticChunkChecksums[chunkIndex]
+}
+
 // ChunkIndexByOffset will return the chunkIndex that contains the provided
 // offset of a file and also the relative offset within the chunk. If the
 // offset is out of bounds, chunkIndex will be equal to NumChunk().
@@ -139,6 +156,11 @@ func (s *Snapshot) Size() uint64 {
 	return uint64(s.staticFileSize)
 }
 
+// UID returns the UID of the file.
+func (s *Snapshot) UID() SiafileUID {
+	return s.staticUID
+}
+
 // Snapshot creates a snapshot of the SiaFile.
 func (sf *siaFileSetEntry) Snapshot() (*Snapshot, error) {
 	mk := sf.MasterKey()
@@ -154,6 +176,7 @@ func (sf *siaFileSetEntry) Snapshot() (*Snapshot, error) {
 	copy(pkt, sf.pubKeyTable)
 
 	chunks := make([]Chunk, 0, sf.numChunks)
+	chunkChecksums := make([]Checksum, 0, sf.numChunks)
 	// Figure out how much memory we need to allocate for the piece sets and
 	// pieces.
 	var numPieceSets, numPieces int
@@ -188,6 +211,7 @@ func (sf *siaFileSetEntry) Snapshot() (*Snapshot, error) {
 		chunks = append(chunks, Chunk{
 			Pieces: pieces,
 		})
+		chunkChecksums = append(chunkChecksums, chunk.Checksum)
 		return nil
 	})
 	if err != nil {
@@ -196,6 +220,8 @@ func (sf *siaFileSetEntry) Snapshot() (*Snapshot, error) {
 	// Get non-static metadata fields under lock.
 	fileSize := sf.staticMetadata.FileSize
 	mode := sf.staticMetadata.Mode
+	uid := sf.staticMetadata.UniqueID
+	checksum := sf.staticMetadata.Checksum
 
 	sf.mu.RUnlock()
 	//////////////////////////////////////////////////////////////////////////////
@@ -207,13 +233,16 @@ func (sf *siaFileSetEntry) Snapshot() (*Snapshot, error) {
 	sf.staticSiaFileSet.mu.Unlock()
 
 	return &Snapshot{
-		staticChunks:      chunks,
-		staticFileSize:    fileSize,
-		staticPieceSize:   sf.staticMetadata.StaticPieceSize,
-		staticErasureCode: sf.staticMetadata.staticErasureCode,
-		staticMasterKey:   mk,
-		staticMode:        mode,
-		staticPubKeyTable: pkt,
-		staticSiaPath:     sp,
+		staticChecksum:       checksum,
+		staticChunkChecksums: chunkChecksums,
+		staticChunks:         chunks,
+		staticFileSize:       fileSize,
+		staticPieceSize:      sf.staticMetadata.StaticPieceSize,
+		staticErasureCode:    sf.staticMetadata.staticErasureCode,
+		staticMasterKey:      mk,
+		staticMode:           mode,
+		staticPubKeyTable:    pkt,
+		staticSiaPath:        sp,
+		staticUID:            uid,
 	}, nil
 }
@@ -0,0 +1,389 @@
+package renter
+
+// syncdirs.go keeps local directories in sync with a siapath. The renter
+// periodically scans every sync dir, uploads files that are new or were
+// modified, and deletes files from the renter that were deleted locally. A
+// file is considered modified if its modification time or size changed and
+// the hash of its contents differs from the hash of the uploaded version.
+// Files that were modified recently are skipped until they have not been
+// modified for a while, so that files which are still being written are not
+// uploaded repeatedly.
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// syncDirsFilename is the filename of the file that persists the sync
+	// dirs and the state of their files.
+	syncDirsFilename = "syncdirs.json"
+)
+
+var (
+	syncDirsMetadata = persist.Metadata{
+		Header:  "Renter Sync Dirs",
+		Version: persistVersion,
+	}
+
+	// errSyncDirExists is returned if a local directory or siapath is already
+	// being synced.
+	errSyncDirExists = errors.New("directory is already being synced")
+
+	// errSyncDirNotAbs is returned if the local path of a sync dir is not an
+	// absolute path.
+	errSyncDirNotAbs = errors.New("local path must be absolute")
+
+	// errSyncDirNotDir is returned if the local path of a sync dir is not a
+	// directory.
+	errSyncDirNotDir = errors.New("local path is not a directory")
+
+	// errSyncDirNotFound is returned if no local directory is synced with the
+	// siapath.
+	errSyncDirNotFound = errors.New("no directory is synced with that siapath")
+)
+
+type (
+	// syncDir is a local directory that is kept in sync with a siapath.
+	syncDir struct {
+		LocalPath string
+		SiaPath   modules.SiaPath
+		Files     map[string]syncFile // keyed by slash-separated relative path
+		Uploads   uint64
+		Deletions uint64
+		LastSync  time.Time
+		LastError string
+
+		// pendingFiles is the number of files that were modified during the
+		// last scan, but were not uploaded yet because they were modified too
+		// recently.
+		pendingFiles uint64
+	}
+
+	// syncFile is the state of a local file at the time it was uploaded.
+	syncFile struct {
+		ModTime time.Time
+		Size    int64
+		Hash    crypto.Hash
+	}
+
+	// syncDirsPersist is the on-disk representation of the sync dirs.
+	syncDirsPersist struct {
+		SyncDirs []syncDir
+	}
+)
+
+// info returns the public information of the sync dir.
+func (sd *syncDir) info() modules.SyncDirInfo {
+	return modules.SyncDirInfo{
+		LocalPath:    sd.LocalPath,
+		SiaPath:      sd.SiaPath,
+		Files:        uint64(len(sd.Files)),
+		PendingFiles: sd.pendingFiles,
+		Uploads:      sd.Uploads,
+		Deletions:    sd.Deletions,
+		LastSync:     sd.LastSync,
+		LastError:    sd.LastError,
+	}
+}
+
+// hashSyncFile returns the hash of the contents of a local file.
+func hashSyncFile(path string) (crypto.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	defer f.Close()
+	h := crypto.NewHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return crypto.Hash{}, err
+	}
+	var hash crypto.Hash
+	copy(hash[:], h.Sum(nil))
+	return hash, nil
+}
+
+// saveSyncDirs saves the sync dirs to disk. The syncDirsMu must be held.
+func (r *Renter) saveSyncDirs() error {
+	var sdp syncDirsPersist
+	for _, sd := range r.syncDirs {
+		sdp.SyncDirs = append(sdp.SyncDirs, *sd)
+	}
+	return persist.SaveJSON(syncDirsMetadata, sdp, filepath.Join(r.persistDir, syncDirsFilename))
+}
+
+// managedLoadSyncDirs loads the sync dirs from disk.
+func (r *Renter) managedLoadSyncDirs() error {
+	var sdp syncDirsPersist
+	err := persist.LoadJSON(syncDirsMetadata, &sdp, filepath.Join(r.persistDir, syncDirsFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.syncDirsMu.Lock()
+	defer r.syncDirsMu.Unlock()
+	for i := range sdp.SyncDirs {
+		sd := sdp.SyncDirs[i]
+		if sd.Files == nil {
+			sd.Files = make(map[string]syncFile)
+		}
+		r.syncDirs[sd.SiaPath] = &sd
+	}
+	return nil
+}
+
+// AddSyncDir links a local directory to a siapath. The renter will upload the
+// files of the directory and keep the siapath in sync with it.
+func (r *Renter) AddSyncDir(localPath string, siaPath modules.SiaPath) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	if !filepath.IsAbs(localPath) {
+		return errSyncDirNotAbs
+	}
+	localPath = filepath.Clean(localPath)
+	if err := siaPath.Validate(false); err != nil {
+		return err
+	}
+	fi, err := os.Stat(localPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to stat local path")
+	}
+	if !fi.IsDir() {
+		return errSyncDirNotDir
+	}
+
+	r.syncDirsMu.Lock()
+	defer r.syncDirsMu.Unlock()
+	for _, sd := range r.syncDirs {
+		if sd.LocalPath == localPath || sd.SiaPath.Equals(siaPath) {
+			return errSyncDirExists
+		}
+	}
+	r.syncDirs[siaPath] = &syncDir{
+		LocalPath: localPath,
+		SiaPath:   siaPath,
+		Files:     make(map[string]syncFile),
+	}
+	if err := r.saveSyncDirs(); err != nil {
+		delete(r.syncDirs, siaPath)
+		return err
+	}
+
+	// Sync the new directory right away.
+	select {
+	case r.syncDirsNotify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RemoveSyncDir stops syncing the local directory that is linked to the
+// siapath. Files that were already uploaded are left untouched.
+func (r *Renter) RemoveSyncDir(siaPath modules.SiaPath) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	r.syncDirsMu.Lock()
+	defer r.syncDirsMu.Unlock()
+	sd, exists := r.syncDirs[siaPath]
+	if !exists {
+		return errSyncDirNotFound
+	}
+	delete(r.syncDirs, siaPath)
+	if err := r.saveSyncDirs(); err != nil {
+		r.syncDirs[siaPath] = sd
+		return err
+	}
+	return nil
+}
+
+// SyncDirs returns the local directories that are synced by the renter,
+// sorted by siapath.
+func (r *Renter) SyncDirs() []modules.SyncDirInfo {
+	r.syncDirsMu.Lock()
+	defer r.syncDirsMu.Unlock()
+	infos := make([]modules.SyncDirInfo, 0, len(r.syncDirs))
+	for _, sd := range r.syncDirs {
+		infos = append(infos, sd.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].SiaPath.String() < infos[j].SiaPath.String()
+	})
+	return infos
+}
+
+// managedSyncDir scans a single sync dir, uploading new and modified files
+// and deleting files that no longer exist locally.
+func (r *Renter) managedSyncDir(siaPath modules.SiaPath) {
+	// Copy the state of the sync dir so that the lock doesn't need to be held
+	// while scanning.
+	r.syncDirsMu.Lock()
+	sd, exists := r.syncDirs[siaPath]
+	if !exists {
+		r.syncDirsMu.Unlock()
+		return
+	}
+	localPath := sd.LocalPath
+	files := make(map[string]syncFile, len(sd.Files))
+	for rel, f := range sd.Files {
+		files[rel] = f
+	}
+	r.syncDirsMu.Unlock()
+
+	var uploads, deletions, pending uint64
+	var syncErr error
+	seen := make(map[string]struct{})
+	// If the directory itself is missing, e.g. because a drive was unmounted,
+	// nothing is deleted.
+	_, err := os.Stat(localPath)
+	if err != nil {
+		syncErr = errors.AddContext(err, "unable to stat local path")
+	} else {
+		err = filepath.Walk(localPath, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				syncErr = errors.Compose(syncErr, err)
+				return nil
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(localPath, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			seen[rel] = struct{}{}
+
+			// Skip files that are unchanged or that were modified too
+			// recently.
+			old, known := files[rel]
+			if known && old.ModTime.Equal(fi.ModTime()) && old.Size == fi.Size() {
+				return nil
+			}
+			if time.Since(fi.ModTime()) < syncDirDebounce {
+				pending++
+				return nil
+			}
+			hash, err := hashSyncFile(path)
+			if err != nil {
+				syncErr = errors.Compose(syncErr, err)
+				return nil
+			}
+			sf := syncFile{
+				ModTime: fi.ModTime(),
+				Size:    fi.Size(),
+				Hash:    hash,
+			}
+			if known && old.Hash == hash {
+				files[rel] = sf
+				return nil
+			}
+			fileSiaPath, err := siaPath.Join(rel)
+			if err != nil {
+				syncErr = errors.Compose(syncErr, err)
+				return nil
+			}
+			err = r.Upload(modules.FileUploadParams{
+				Source:  path,
+				SiaPath: fileSiaPath,
+				Force:   true,
+			})
+			if err != nil {
+				syncErr = errors.Compose(syncErr, errors.AddContext(err, "unable to upload "+rel))
+				return nil
+			}
+			files[rel] = sf
+			uploads++
+			return nil
+		})
+		syncErr = errors.Compose(syncErr, err)
+
+		// Delete the files that no longer exist locally.
+		for rel := range files {
+			if _, ok := seen[rel]; ok {
+				continue
+			}
+			fileSiaPath, err := siaPath.Join(rel)
+			if err == nil {
+				err = r.DeleteFile(fileSiaPath)
+			}
+			if err != nil && err != siafile.ErrUnknownPath {
+				syncErr = errors.Compose(syncErr, errors.AddContext(err, "unable to delete "+rel))
+				continue
+			}
+			delete(files, rel)
+			deletions++
+		}
+	}
+
+	// Update the sync dir, unless it was removed in the meantime.
+	r.syncDirsMu.Lock()
+	defer r.syncDirsMu.Unlock()
+	if r.syncDirs[siaPath] != sd {
+		return
+	}
+	sd.Files = files
+	sd.Uploads += uploads
+	sd.Deletions += deletions
+	sd.pendingFiles = pending
+	sd.LastSync = time.Now()
+	sd.LastError = ""
+	if syncErr != nil {
+		sd.LastError = syncErr.Error()
+		r.log.Printf("WARN: unable to sync %v with %v: %v", sd.LocalPath, siaPath, syncErr)
+	}
+	if err := r.saveSyncDirs(); err != nil {
+		r.log.Println("WARN: unable to save sync dirs:", err)
+	}
+}
+
+// managedSyncDirs scans all sync dirs.
+func (r *Renter) managedSyncDirs() {
+	r.syncDirsScanMu.Lock()
+	defer r.syncDirsScanMu.Unlock()
+
+	r.syncDirsMu.Lock()
+	siaPaths := make([]modules.SiaPath, 0, len(r.syncDirs))
+	for siaPath := range r.syncDirs {
+		siaPaths = append(siaPaths, siaPath)
+	}
+	r.syncDirsMu.Unlock()
+
+	for _, siaPath := range siaPaths {
+		r.managedSyncDir(siaPath)
+	}
+}
+
+// threadedSyncDirs periodically scans the sync dirs.
+func (r *Renter) threadedSyncDirs() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-r.syncDirsNotify:
+		case <-time.After(syncDirInterval):
+		}
+		r.managedSyncDirs()
+	}
+}
@@ -0,0 +1,150 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// TestSyncDirs checks that new, modified, and deleted files in a sync dir are
+// reflected in the renter.
+func TestSyncDirs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a local directory with two files that were last modified long
+	// enough ago.
+	localDir := filepath.Join(rt.dir, "local")
+	if err := os.MkdirAll(filepath.Join(localDir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	writeFile := func(name string, data []byte, modTime time.Time) {
+		path := filepath.Join(localDir, name)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("a", fastrand.Bytes(100), past)
+	writeFile(filepath.Join("sub", "b"), fastrand.Bytes(100), past)
+
+	// Check the validation of new sync dirs.
+	siaPath, err := modules.NewSiaPath("backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.AddSyncDir("local", siaPath); err != errSyncDirNotAbs {
+		t.Fatal("expected errSyncDirNotAbs, got", err)
+	}
+	if err := rt.renter.AddSyncDir(filepath.Join(localDir, "a"), siaPath); err != errSyncDirNotDir {
+		t.Fatal("expected errSyncDirNotDir, got", err)
+	}
+	if err := rt.renter.AddSyncDir(localDir, siaPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.AddSyncDir(localDir, modules.RandomSiaPath()); err != errSyncDirExists {
+		t.Fatal("expected errSyncDirExists, got", err)
+	}
+
+	// syncAndCheck syncs the dirs and checks the statistics of the sync dir.
+	syncAndCheck := func(files, pending, uploads, deletions uint64) {
+		t.Helper()
+		rt.renter.managedSyncDirs()
+		infos := rt.renter.SyncDirs()
+		if len(infos) != 1 {
+			t.Fatal("expected 1 sync dir, got", len(infos))
+		}
+		info := infos[0]
+		if info.LocalPath != localDir || !info.SiaPath.Equals(siaPath) || info.LastError != "" {
+			t.Fatalf("unexpected sync dir: %+v", info)
+		}
+		if info.Files != files || info.PendingFiles != pending || info.Uploads != uploads || info.Deletions != deletions {
+			t.Fatalf("unexpected sync dir statistics: %+v", info)
+		}
+	}
+	fileExists := func(name string) bool {
+		_, err := rt.renter.File(mustJoin(t, siaPath, name))
+		return err == nil
+	}
+
+	// Both files should be uploaded.
+	syncAndCheck(2, 0, 2, 0)
+	if !fileExists("a") || !fileExists("sub/b") {
+		t.Fatal("files were not uploaded")
+	}
+
+	// A file that was just modified is not uploaded until it hasn't been
+	// modified for a while.
+	writeFile("a", fastrand.Bytes(200), time.Now())
+	syncAndCheck(2, 1, 2, 0)
+	writeFile("a", fastrand.Bytes(200), past.Add(time.Minute))
+	syncAndCheck(2, 0, 3, 0)
+	if fi, err := rt.renter.File(mustJoin(t, siaPath, "a")); err != nil || fi.Filesize != 200 {
+		t.Fatal("modified file was not uploaded", err)
+	}
+
+	// A file whose modification time changed but whose contents did not is
+	// not uploaded again.
+	if err := os.Chtimes(filepath.Join(localDir, "sub", "b"), past.Add(time.Minute), past.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	syncAndCheck(2, 0, 3, 0)
+
+	// A file that is deleted locally is deleted from the renter.
+	if err := os.Remove(filepath.Join(localDir, "sub", "b")); err != nil {
+		t.Fatal(err)
+	}
+	syncAndCheck(1, 0, 3, 1)
+	if fileExists("sub/b") {
+		t.Fatal("deleted file still exists")
+	}
+
+	// The sync dir should persist across restarts.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, filepath.Join(rt.dir, modules.RenterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncAndCheck(1, 0, 3, 1)
+
+	// Removing the sync dir keeps the uploaded files.
+	if err := rt.renter.RemoveSyncDir(siaPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.RemoveSyncDir(siaPath); err != errSyncDirNotFound {
+		t.Fatal("expected errSyncDirNotFound, got", err)
+	}
+	if len(rt.renter.SyncDirs()) != 0 {
+		t.Fatal("sync dir was not removed")
+	}
+	if !fileExists("a") {
+		t.Fatal("uploaded file was deleted")
+	}
+}
+
+// mustJoin joins a siapath with a relative path, failing the test on error.
+func mustJoin(t *testing.T, sp modules.SiaPath, s string) modules.SiaPath {
+	joined, err := sp.Join(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return joined
+}
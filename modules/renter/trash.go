@@ -0,0 +1,413 @@
+package renter
+
+// trash.go implements the recycle bin of the renter. While the trash retention
+// is non-zero, deleted files and directories are moved to the reserved .trash
+// directory instead of being deleted, where they are stored as
+// .trash/<id>/<siapath>. Trashed siafiles stay on the network and are repaired
+// like any other file until they are purged, either explicitly or by the
+// background thread once they have been in the trash for longer than the
+// retention.
+//
+// The old versions and version policies of trashed files stay at their
+// original siapath, so that they are available again once the file is
+// restored. They are deleted when the trash entry is purged, unless a new file
+// or directory was created at the original siapath in the meantime.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// trashFilename is the filename of the file that persists the trash.
+	trashFilename = "trash.json"
+
+	// trashSiaDir is the reserved siadir that holds the trashed siafiles and
+	// siadirs.
+	trashSiaDir = ".trash"
+)
+
+var (
+	trashMetadata = persist.Metadata{
+		Header:  "Renter Trash",
+		Version: persistVersion,
+	}
+
+	// errNegativeTrashRetention is returned if the trash retention is set to
+	// a negative duration.
+	errNegativeTrashRetention = errors.New("trash retention can't be negative")
+
+	// errTrashPathReserved is returned if a user tries to modify the trash
+	// directly.
+	errTrashPathReserved = errors.New("siapath is reserved for the trash")
+
+	// errUnknownTrashEntry is returned if the trash has no entry with the
+	// requested id.
+	errUnknownTrashEntry = errors.New("no trash entry known with that id")
+)
+
+type (
+	// trashEntry is a file or directory in the trash.
+	trashEntry struct {
+		ID       uint64
+		SiaPath  modules.SiaPath
+		IsDir    bool
+		Deleted  time.Time
+		NumFiles uint64
+		Size     uint64
+	}
+
+	// trashPersist is the on-disk representation of the trash.
+	trashPersist struct {
+		NextID    uint64
+		Retention time.Duration
+		Entries   []trashEntry
+	}
+)
+
+// isTrashSiaPath returns true if siaPath is within the reserved siadir of the
+// trash.
+func isTrashSiaPath(siaPath modules.SiaPath) bool {
+	return siaPath.Path == trashSiaDir || strings.HasPrefix(siaPath.Path, trashSiaDir+"/")
+}
+
+// trashEntrySiaDir returns the siapath of the siadir that holds the trash
+// entry with the given id. The id is zero-padded, so that the siapath of an
+// entry is never a prefix of the siapath of another entry.
+func trashEntrySiaDir(id uint64) modules.SiaPath {
+	return modules.SiaPath{Path: fmt.Sprintf("%s/%020d", trashSiaDir, id)}
+}
+
+// trashSiaPath returns the siapath of the trashed file or directory at
+// siaPath within the trash entry with the given id.
+func trashSiaPath(id uint64, siaPath modules.SiaPath) modules.SiaPath {
+	return modules.SiaPath{Path: trashEntrySiaDir(id).Path + "/" + siaPath.Path}
+}
+
+// saveTrash saves the trash to disk. The trashMu must be held.
+func (r *Renter) saveTrash() error {
+	tp := trashPersist{
+		NextID:    r.nextTrashID,
+		Retention: r.trashRetention,
+		Entries:   r.trash,
+	}
+	return persist.SaveJSON(trashMetadata, tp, filepath.Join(r.persistDir, trashFilename))
+}
+
+// managedLoadTrash loads the trash from disk.
+func (r *Renter) managedLoadTrash() error {
+	var tp trashPersist
+	err := persist.LoadJSON(trashMetadata, &tp, filepath.Join(r.persistDir, trashFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	r.nextTrashID = tp.NextID
+	r.trashRetention = tp.Retention
+	r.trash = tp.Entries
+	return nil
+}
+
+// trashEntryIndex returns the index of the trash entry with the given id. The
+// trashMu must be held.
+func (r *Renter) trashEntryIndex(id uint64) (int, error) {
+	i := sort.Search(len(r.trash), func(i int) bool { return r.trash[i].ID >= id })
+	if i == len(r.trash) || r.trash[i].ID != id {
+		return 0, errUnknownTrashEntry
+	}
+	return i, nil
+}
+
+// managedMoveDir renames the siadir at siaPath to newSiaPath, creates the
+// parent siadir of newSiaPath if necessary and updates the metadata of both
+// parent directories.
+func (r *Renter) managedMoveDir(siaPath, newSiaPath modules.SiaPath) error {
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		return err
+	}
+	newDirSiaPath, err := newSiaPath.Dir()
+	if err != nil {
+		return err
+	}
+	siaDirEntry, err := r.staticDirSet.NewSiaDir(newDirSiaPath)
+	if err != siadir.ErrPathOverload && err != nil {
+		return err
+	} else if err == nil {
+		siaDirEntry.Close()
+	}
+	if err := r.staticFileSet.RenameDir(siaPath, newSiaPath, r.staticDirSet.Rename); err != nil {
+		return err
+	}
+	go r.threadedBubbleMetadata(dirSiaPath)
+	go r.threadedBubbleMetadata(newDirSiaPath)
+	return nil
+}
+
+// managedMoveToTrash moves the file or directory at siaPath to the trash. If
+// the trash is disabled, nothing is moved and false is returned.
+func (r *Renter) managedMoveToTrash(siaPath modules.SiaPath, isDir bool) (bool, error) {
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	if r.trashRetention == 0 {
+		return false, nil
+	}
+
+	// Determine the number of files and the size of the file or directory.
+	numFiles, size := uint64(1), uint64(0)
+	if isDir {
+		if _, err := os.Stat(siaPath.SiaDirSysPath(r.staticFilesDir)); err != nil {
+			return false, siadir.ErrUnknownPath
+		}
+		offline, goodForRenew, contracts := r.managedContractUtilityMaps()
+		files, err := r.staticFileSet.FileList(siaPath, true, true, offline, goodForRenew, contracts)
+		if err != nil {
+			return false, err
+		}
+		numFiles = uint64(len(files))
+		for _, file := range files {
+			size += file.Filesize
+		}
+	} else {
+		entry, err := r.staticFileSet.Open(siaPath)
+		if err != nil {
+			return false, err
+		}
+		size = entry.Size()
+		entry.Close()
+	}
+
+	// Move the file or directory to the trash.
+	id := r.nextTrashID
+	var err error
+	if isDir {
+		err = r.managedMoveDir(siaPath, trashSiaPath(id, siaPath))
+	} else {
+		err = r.managedMoveFile(siaPath, trashSiaPath(id, siaPath))
+	}
+	if err != nil {
+		return false, errors.AddContext(err, "unable to move to the trash")
+	}
+	r.nextTrashID++
+	r.trash = append(r.trash, trashEntry{
+		ID:       id,
+		SiaPath:  siaPath,
+		IsDir:    isDir,
+		Deleted:  time.Now(),
+		NumFiles: numFiles,
+		Size:     size,
+	})
+	return true, r.saveTrash()
+}
+
+// purgeTrashEntry deletes the trash entry at index i from the network and
+// removes it from the trash. The old versions of the entry are deleted as
+// well unless a new file or directory was created at its siapath. The trashMu
+// must be held.
+func (r *Renter) purgeTrashEntry(i int) error {
+	e := r.trash[i]
+	if err := r.managedDeleteDir(trashEntrySiaDir(e.ID)); err != nil {
+		return errors.AddContext(err, "unable to delete trash entry")
+	}
+	r.trash = append(r.trash[:i], r.trash[i+1:]...)
+	if e.IsDir {
+		if _, err := os.Stat(e.SiaPath.SiaDirSysPath(r.staticFilesDir)); os.IsNotExist(err) {
+			return r.managedDeleteDirVersions(e.SiaPath)
+		}
+	} else if !r.staticFileSet.Exists(e.SiaPath) {
+		return r.managedDeleteVersions(e.SiaPath)
+	}
+	return nil
+}
+
+// managedPurgeExpiredTrash purges the trash entries that have been in the
+// trash for longer than the trash retention. It also removes the siadirs of
+// entries that were restored.
+func (r *Renter) managedPurgeExpiredTrash() error {
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	var errs error
+	for i := 0; i < len(r.trash); {
+		if time.Since(r.trash[i].Deleted) <= r.trashRetention {
+			i++
+			continue
+		}
+		if err := r.purgeTrashEntry(i); err != nil {
+			errs = errors.Compose(errs, err)
+			i++
+		}
+	}
+	errs = errors.Compose(errs, r.saveTrash())
+
+	// Remove the siadirs that are left behind by restored entries. This isn't
+	// done when an entry is restored, to give the metadata updates of the
+	// restore time to finish.
+	fis, err := ioutil.ReadDir(modules.SiaPath{Path: trashSiaDir}.SiaDirSysPath(r.staticFilesDir))
+	if os.IsNotExist(err) {
+		return errs
+	} else if err != nil {
+		return errors.Compose(errs, err)
+	}
+	for _, fi := range fis {
+		id, err := strconv.ParseUint(fi.Name(), 10, 64)
+		if !fi.IsDir() || err != nil {
+			continue
+		}
+		if _, err := r.trashEntryIndex(id); err == nil {
+			continue
+		}
+		errs = errors.Compose(errs, r.managedDeleteDir(trashEntrySiaDir(id)))
+	}
+	return errs
+}
+
+// threadedPurgeTrash periodically purges the trash entries that have been in
+// the trash for longer than the trash retention.
+func (r *Renter) threadedPurgeTrash() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(trashPurgeInterval):
+		}
+		if err := r.managedPurgeExpiredTrash(); err != nil {
+			r.log.Println("WARN: unable to purge trash:", err)
+		}
+	}
+}
+
+// Trash returns the entries of the trash, oldest first.
+func (r *Renter) Trash() []modules.TrashEntry {
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	entries := make([]modules.TrashEntry, 0, len(r.trash))
+	for _, e := range r.trash {
+		entries = append(entries, modules.TrashEntry{
+			ID:       e.ID,
+			SiaPath:  e.SiaPath,
+			IsDir:    e.IsDir,
+			Deleted:  e.Deleted,
+			Expires:  e.Deleted.Add(r.trashRetention),
+			NumFiles: e.NumFiles,
+			Size:     e.Size,
+		})
+	}
+	return entries
+}
+
+// RestoreTrashEntry moves the trash entry with the given id back to its
+// original siapath. The restore fails if a file or directory exists at the
+// siapath.
+func (r *Renter) RestoreTrashEntry(id uint64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	i, err := r.trashEntryIndex(id)
+	if err != nil {
+		return err
+	}
+	e := r.trash[i]
+	if e.IsDir {
+		if _, err := os.Stat(e.SiaPath.SiaDirSysPath(r.staticFilesDir)); err == nil {
+			return siadir.ErrPathOverload
+		}
+		err = r.managedMoveDir(trashSiaPath(e.ID, e.SiaPath), e.SiaPath)
+	} else {
+		if r.staticFileSet.Exists(e.SiaPath) {
+			return siafile.ErrPathOverload
+		}
+		err = r.managedMoveFile(trashSiaPath(e.ID, e.SiaPath), e.SiaPath)
+	}
+	if err != nil {
+		return errors.AddContext(err, "unable to restore trash entry")
+	}
+	r.trash = append(r.trash[:i], r.trash[i+1:]...)
+	return r.saveTrash()
+}
+
+// PurgeTrashEntry deletes the trash entry with the given id from the network.
+func (r *Renter) PurgeTrashEntry(id uint64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	i, err := r.trashEntryIndex(id)
+	if err != nil {
+		return err
+	}
+	err = r.purgeTrashEntry(i)
+	return errors.Compose(err, r.saveTrash())
+}
+
+// PurgeTrash deletes all entries of the trash from the network.
+func (r *Renter) PurgeTrash() error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	var errs error
+	for i := 0; i < len(r.trash); {
+		if err := r.purgeTrashEntry(i); err != nil {
+			errs = errors.Compose(errs, err)
+			i++
+		}
+	}
+	return errors.Compose(errs, r.saveTrash())
+}
+
+// TrashRetention returns how long deleted files and directories are kept in
+// the trash. A retention of 0 means that the trash is disabled.
+func (r *Renter) TrashRetention() time.Duration {
+	r.trashMu.Lock()
+	defer r.trashMu.Unlock()
+	return r.trashRetention
+}
+
+// SetTrashRetention sets how long deleted files and directories are kept in
+// the trash and purges the entries that are no longer kept. A retention of 0
+// disables the trash.
+func (r *Renter) SetTrashRetention(retention time.Duration) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if retention < 0 {
+		return errNegativeTrashRetention
+	}
+	r.trashMu.Lock()
+	r.trashRetention = retention
+	err := r.saveTrash()
+	r.trashMu.Unlock()
+	if err != nil {
+		return err
+	}
+	return r.managedPurgeExpiredTrash()
+}
@@ -0,0 +1,182 @@
+package renter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+)
+
+// TestTrashSiaPath checks that the siapath of a trash entry is never a prefix
+// of the siapath of another entry.
+func TestTrashSiaPath(t *testing.T) {
+	if sp := trashEntrySiaDir(1); !isTrashSiaPath(sp) {
+		t.Fatal("siapath of trash entry isn't within the trash", sp)
+	}
+	sp := trashSiaPath(10, modules.SiaPath{Path: "a/b"})
+	if sp.IsWithin(trashEntrySiaDir(1)) || !sp.IsWithin(trashEntrySiaDir(10)) {
+		t.Fatal("siapath of trash entry has the wrong prefix", sp)
+	}
+}
+
+// TestTrash checks that deleted files and directories are moved to the trash
+// when the trash is enabled and that they can be restored and purged.
+func TestTrash(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	upload := func(path string, size int) modules.SiaPath {
+		source := filepath.Join(rt.dir, "source")
+		if err := ioutil.WriteFile(source, fastrand.Bytes(size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		siaPath := modules.SiaPath{Path: path}
+		err := r.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath, Force: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return siaPath
+	}
+	numFiles := func() int {
+		files, err := r.FileList(modules.RootSiaPath(), true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(files)
+	}
+
+	// The trash is enabled by default.
+	if r.TrashRetention() != defaultTrashRetention || r.TrashRetention() == 0 {
+		t.Fatal("trash should be enabled by default", r.TrashRetention())
+	}
+
+	// A retention of 0 disables the trash.
+	if err := r.SetTrashRetention(0); err != nil {
+		t.Fatal(err)
+	}
+	file := upload("file", 100)
+	if err := r.DeleteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Trash()) != 0 || numFiles() != 0 {
+		t.Fatal("file should have been deleted right away")
+	}
+	if err := r.SetTrashRetention(-time.Second); err != errNegativeTrashRetention {
+		t.Fatal("expected errNegativeTrashRetention, got", err)
+	}
+	if err := r.SetTrashRetention(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting a file moves it and keeps its old versions.
+	file = upload("file", 100)
+	upload("file", 200)
+	if err := r.DeleteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	trash := r.Trash()
+	if len(trash) != 1 || !trash[0].SiaPath.Equals(file) || trash[0].IsDir || trash[0].Size != 200 {
+		t.Fatalf("unexpected trash: %+v", trash)
+	}
+	if !trash[0].Expires.Equal(trash[0].Deleted.Add(time.Hour)) {
+		t.Fatal("wrong expiry of trash entry")
+	}
+	if r.staticFileSet.Exists(file) || !r.staticFileSet.Exists(trashSiaPath(trash[0].ID, file)) {
+		t.Fatal("file wasn't moved to the trash")
+	}
+	if numFiles() != 0 {
+		t.Fatal("trashed file shouldn't be listed")
+	}
+	if versions, _ := r.FileVersions(file); len(versions) != 1 {
+		t.Fatal("versions of trashed file should be kept", len(versions))
+	}
+	if err := r.DeleteDir(modules.SiaPath{Path: trashSiaDir}); err != errTrashPathReserved {
+		t.Fatal("expected errTrashPathReserved, got", err)
+	}
+
+	// The file can be restored, but not over an existing file.
+	upload("file", 300)
+	if err := r.RestoreTrashEntry(trash[0].ID); err != siafile.ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if err := r.DeleteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RestoreTrashEntry(trash[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := r.File(file); err != nil || fi.Filesize != 200 {
+		t.Fatal("file wasn't restored", err)
+	}
+	if err := r.RestoreTrashEntry(trash[0].ID); err != errUnknownTrashEntry {
+		t.Fatal("expected errUnknownTrashEntry, got", err)
+	}
+
+	// Purging a file deletes its old versions.
+	if err := r.DeleteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PurgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Trash()) != 0 {
+		t.Fatal("trash wasn't emptied")
+	}
+	if versions, _ := r.FileVersions(file); len(versions) != 0 {
+		t.Fatal("versions of purged file weren't deleted", len(versions))
+	}
+
+	// Deleting a directory moves the whole directory.
+	upload("dir/a", 100)
+	upload("dir/sub/b", 200)
+	dir := modules.SiaPath{Path: "dir"}
+	if err := r.DeleteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	trash = r.Trash()
+	if len(trash) != 1 || !trash[0].IsDir || trash[0].NumFiles != 2 || trash[0].Size != 300 {
+		t.Fatalf("unexpected trash: %+v", trash)
+	}
+	if numFiles() != 0 {
+		t.Fatal("files of trashed directory shouldn't be listed")
+	}
+	if err := r.RestoreTrashEntry(trash[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if numFiles() != 2 {
+		t.Fatal("directory wasn't restored")
+	}
+
+	// The trash is persisted.
+	if err := r.DeleteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	r2 := &Renter{persistDir: r.persistDir}
+	if err := r2.managedLoadTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r2.trash) != 1 || r2.trashRetention != time.Hour || r2.nextTrashID != r.nextTrashID {
+		t.Fatal("trash wasn't persisted")
+	}
+
+	// Entries are purged once they expire.
+	if err := r.SetTrashRetention(time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Trash()) != 0 || r.staticFileSet.Exists(trashSiaPath(trash[0].ID, modules.SiaPath{Path: "dir/a"})) {
+		t.Fatal("expired entry wasn't purged")
+	}
+}
@@ -34,6 +34,18 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	}
 	defer r.tg.Done()
 
+	// Check that the siapath isn't reserved, that packed files use the
+	// default erasure code and that the user metadata is valid.
+	if err := checkReservedSiaPaths(up.SiaPath); err != nil {
+		return err
+	}
+	if up.Pack && up.ErasureCode != nil {
+		return errPackErasureCode
+	}
+	if err := siafile.ValidateUserMetadata(up.UserMetadata); err != nil {
+		return err
+	}
+
 	// Check if the file is a directory.
 	sourceInfo, err := os.Stat(up.Source)
 	if err != nil {
@@ -50,13 +62,26 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	}
 	file.Close()
 
-	// Delete existing file if overwrite flag is set. Ignore ErrUnknownPath.
+	// Keep the existing file as an old version if overwrite flag is set.
+	// Ignore ErrUnknownPath.
 	if up.Force {
-		if err := r.DeleteFile(up.SiaPath); err != nil && err != siafile.ErrUnknownPath {
-			return errors.AddContext(err, "unable to delete existing file")
+		if err := r.managedArchiveFile(up.SiaPath); err != nil && err != siafile.ErrUnknownPath {
+			return errors.AddContext(err, "unable to replace existing file")
 		}
 	}
 
+	// Pack the file if it is small enough. Larger files are uploaded
+	// normally.
+	size := uint64(sourceInfo.Size())
+	if up.Pack && size > 0 && size <= packCapacity/packedFileSizeDivisor {
+		return r.managedPackFile(up, sourceInfo)
+	}
+	return r.managedUpload(up, sourceInfo)
+}
+
+// managedUpload creates the siafile of a file and pushes its chunks onto the
+// upload heap.
+func (r *Renter) managedUpload(up modules.FileUploadParams, sourceInfo os.FileInfo) error {
 	// Fill in any missing upload params with sensible defaults.
 	if up.ErasureCode == nil {
 		up.ErasureCode, _ = siafile.NewRSSubCode(defaultDataPieces, defaultParityPieces, crypto.SegmentSize)
@@ -95,7 +120,7 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 
 	// No need to upload zero-byte files.
 	if sourceInfo.Size() == 0 {
-		return nil
+		return entry.SetChecksum(siafile.NewChecksum(nil))
 	}
 
 	// Bubble the health of the SiaFile directory to ensure the health is
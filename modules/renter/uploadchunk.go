@@ -52,6 +52,10 @@ type unfinishedUploadChunk struct {
 	logicalChunkData  [][]byte
 	physicalChunkData [][]byte
 
+	// checksum is the checksum of the logical data if it was read from a
+	// source reader or from disk.
+	checksum siafile.Checksum
+
 	// sourceReader is an optional source for the logical chunk data. If
 	// available it will be tried before the repair path or remote repair.
 	sourceReader io.ReadCloser
@@ -140,6 +144,7 @@ func (uc *unfinishedUploadChunk) readLogicalData(r io.Reader) (uint64, error) {
 			return total, errors.AddContext(err, "failed to read chunk from source reader")
 		}
 	}
+	uc.checksum = checksumShards(dataPieces, total)
 	// Encode the data pieces, forming the chunk's logical data.
 	uc.logicalChunkData, _ = ec.EncodeShards(dataPieces)
 	return total, nil
@@ -377,7 +382,9 @@ func (r *Renter) managedFetchLogicalChunkData(chunk *unfinishedUploadChunk) erro
 		if errSize := chunk.fileEntry.SetFileSize(adjustedSize); errSize != nil {
 			return errors.AddContext(errSize, "failed to adjust FileSize")
 		}
-		return nil
+		// When repairing a file from a stream, the stream needs to contain
+		// the data that was uploaded.
+		return errors.AddContext(chunk.managedUpdateChunkChecksum(), "stream doesn't match uploaded data")
 	}
 
 	// Download the chunk if it's not on disk.
@@ -393,13 +400,21 @@ func (r *Renter) managedFetchLogicalChunkData(chunk *unfinishedUploadChunk) erro
 		}
 		defer osFile.Close()
 		sr := io.NewSectionReader(osFile, chunk.offset, int64(chunk.length))
-		_, err = chunk.readLogicalData(sr)
-		return err
+		if _, err = chunk.readLogicalData(sr); err != nil {
+			return err
+		}
+		// The local file might have been modified since it was uploaded.
+		return chunk.managedUpdateChunkChecksum()
 	}()
 	if err != nil {
 		r.log.Debugln("failed to read file, downloading instead:", err)
 		return r.managedDownloadLogicalChunkData(chunk)
 	}
+	// The checksum of the file can be computed once every chunk was read.
+	// Failing to compute it doesn't fail the chunk.
+	if err := chunk.managedComputeFileChecksum(); err != nil {
+		r.log.Println("WARN: unable to compute checksum of file:", err)
+	}
 	return nil
 }
 
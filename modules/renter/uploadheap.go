@@ -304,6 +304,12 @@ func (r *Renter) managedBuildUnfinishedChunk(entry *siafile.SiaFileSetEntry, chu
 // finish would then close the Entry and consequentially impact the remaining
 // chunks.
 func (r *Renter) managedBuildUnfinishedChunks(entry *siafile.SiaFileSetEntry, hosts map[string]struct{}, target repairTarget, offline, goodForRenew map[string]bool) []*unfinishedUploadChunk {
+	// Packed files don't have chunks of their own, their data is repaired
+	// with their pack.
+	if _, _, packed := entry.Packed(); packed {
+		return nil
+	}
+
 	// If we don't have enough workers for the file, don't repair it right now.
 	minPieces := entry.ErasureCode().MinPieces()
 	r.staticWorkerPool.mu.RLock()
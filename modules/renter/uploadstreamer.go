@@ -1,6 +1,7 @@
 package renter
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"sync"
@@ -87,6 +88,9 @@ func (r *Renter) UploadStreamFromReader(up modules.FileUploadParams, reader io.R
 		return err
 	}
 	defer r.tg.Done()
+	if err := checkReservedSiaPaths(up.SiaPath); err != nil {
+		return err
+	}
 	return r.managedUploadStreamFromReader(up, reader, false)
 }
 
@@ -110,10 +114,14 @@ func (r *Renter) managedInitUploadStream(up modules.FileUploadParams, backup boo
 	if force && repair {
 		return nil, errors.New("'force' and 'repair' can't both be set")
 	}
+	if err := siafile.ValidateUserMetadata(up.UserMetadata); err != nil {
+		return nil, err
+	}
 
-	// Delete existing file if overwrite flag is set. Ignore ErrUnknownPath.
+	// Keep the existing file as an old version if overwrite flag is set.
+	// Ignore ErrUnknownPath.
 	if force {
-		if err := r.DeleteFile(siaPath); err != nil && err != siafile.ErrUnknownPath {
+		if err := r.managedArchiveFile(siaPath); err != nil && err != siafile.ErrUnknownPath {
 			return nil, err
 		}
 	}
@@ -123,6 +131,10 @@ func (r *Renter) managedInitUploadStream(up modules.FileUploadParams, backup boo
 		if err != nil {
 			return nil, err
 		}
+		if _, _, packed := entry.Packed(); packed {
+			entry.Close()
+			return nil, errors.New("packed files are repaired with their pack")
+		}
 		return entry, nil
 	}
 	// Check that we have contracts to upload to. We need at least data +
@@ -194,6 +206,10 @@ func (r *Renter) managedUploadStreamFromReader(up modules.FileUploadParams, read
 			minWorkers, availableWorkers)
 	}
 
+	// Compute the checksum of the stream while it is read.
+	hasher := sha256.New()
+	teeReader := io.TeeReader(reader, hasher)
+
 	// Read the chunks we want to upload one by one from the input stream using
 	// shards. A shard will signal completion after reading the input but
 	// before the upload is done.
@@ -220,7 +236,7 @@ func (r *Renter) managedUploadStreamFromReader(up modules.FileUploadParams, read
 		}
 
 		// Create a new shard set it to be the source reader of the chunk.
-		ss := NewStreamShard(reader)
+		ss := NewStreamShard(teeReader)
 		uuc.sourceReader = ss
 
 		// Check if the chunk needs any work or if we can skip it.
@@ -258,9 +274,11 @@ func (r *Renter) managedUploadStreamFromReader(up modules.FileUploadParams, read
 
 		// If an io.EOF error occurred or less than chunkSize was read, we are
 		// done. Otherwise we report the error.
-		if _, err := ss.Result(); err == io.EOF {
-			// Adjust the fileSize
-			return nil
+		_, err = ss.Result()
+		if err == io.EOF {
+			var checksum siafile.Checksum
+			copy(checksum[:], hasher.Sum(nil))
+			return r.managedUpdateFileChecksum(entry, checksum)
 		} else if ss.err != nil {
 			return ss.err
 		}
@@ -0,0 +1,571 @@
+package renter
+
+// versions.go keeps old versions of files that are overwritten. When a file is
+// uploaded with the Force flag, the existing siafile is moved to the reserved
+// .versions directory instead of being deleted, where it is stored as
+// .versions/<siapath>/<id>. The renter keeps an index of the versions of every
+// siapath. The data of old versions stays on the network and is repaired like
+// any other file until the version is pruned, which means that old versions
+// count towards the renter's storage costs.
+//
+// Which versions are kept is determined by the version policy of the file's
+// directory. A policy applies to a directory and all of its subdirectories
+// unless they have a policy of their own. If no policy applies, the
+// defaultVersionPolicy is used. Versions are pruned whenever a new version is
+// created, whenever a policy changes and periodically to enforce the maximum
+// age of versions.
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siadir"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
+	"gitlab.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// versionsFilename is the filename of the file that persists the versions
+	// and the version policies.
+	versionsFilename = "versions.json"
+
+	// versionsSiaDir is the reserved siadir that holds the siafiles of old
+	// versions.
+	versionsSiaDir = ".versions"
+)
+
+var (
+	versionsMetadata = persist.Metadata{
+		Header:  "Renter Versions",
+		Version: persistVersion,
+	}
+
+	// defaultVersionPolicy is the version policy of directories without a
+	// policy of their own. The storage used by the versions it keeps is
+	// reported by VersionStorage.
+	defaultVersionPolicy = modules.VersionPolicy{
+		MaxVersions: 3,
+	}
+
+	// errNegativeMaxAge is returned if a version policy has a negative
+	// maximum age.
+	errNegativeMaxAge = errors.New("maximum age of versions can't be negative")
+
+	// errUnknownVersion is returned if a file has no version with the
+	// requested id.
+	errUnknownVersion = errors.New("no version known with that id")
+
+	// errVersionPathReserved is returned if a user tries to modify the
+	// siafiles of old versions directly.
+	errVersionPathReserved = errors.New("siapath is reserved for file versions")
+)
+
+type (
+	// fileVersion is an old version of a file.
+	fileVersion struct {
+		ID       uint64
+		Created  time.Time
+		Filesize uint64
+	}
+
+	// versionedFile is the on-disk representation of the versions of a file.
+	versionedFile struct {
+		SiaPath  modules.SiaPath
+		Versions []fileVersion
+	}
+
+	// versionsPersist is the on-disk representation of the versions and the
+	// version policies.
+	versionsPersist struct {
+		NextID   uint64
+		Policies []modules.VersionPolicyInfo
+		Files    []versionedFile
+	}
+)
+
+// isVersionSiaPath returns true if siaPath is within the reserved siadir of
+// old versions.
+func isVersionSiaPath(siaPath modules.SiaPath) bool {
+	return siaPath.Path == versionsSiaDir || strings.HasPrefix(siaPath.Path, versionsSiaDir+"/")
+}
+
+// versionSiaPath returns the siapath of the version of the file at siaPath
+// with the given id.
+func versionSiaPath(siaPath modules.SiaPath, id uint64) modules.SiaPath {
+	return modules.SiaPath{Path: versionsSiaDir + "/" + siaPath.Path + "/" + strconv.FormatUint(id, 10)}
+}
+
+// saveVersions saves the versions and version policies to disk. The versionsMu
+// must be held.
+func (r *Renter) saveVersions() error {
+	vp := versionsPersist{
+		NextID: r.nextVersionID,
+	}
+	for siaPath, policy := range r.versionPolicies {
+		vp.Policies = append(vp.Policies, modules.VersionPolicyInfo{SiaPath: siaPath, VersionPolicy: policy})
+	}
+	for siaPath, versions := range r.versions {
+		vp.Files = append(vp.Files, versionedFile{SiaPath: siaPath, Versions: versions})
+	}
+	return persist.SaveJSON(versionsMetadata, vp, filepath.Join(r.persistDir, versionsFilename))
+}
+
+// managedLoadVersions loads the versions and version policies from disk.
+func (r *Renter) managedLoadVersions() error {
+	var vp versionsPersist
+	err := persist.LoadJSON(versionsMetadata, &vp, filepath.Join(r.persistDir, versionsFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	r.nextVersionID = vp.NextID
+	for _, p := range vp.Policies {
+		r.versionPolicies[p.SiaPath] = p.VersionPolicy
+	}
+	for _, vf := range vp.Files {
+		r.versions[vf.SiaPath] = vf.Versions
+	}
+	return nil
+}
+
+// versionPolicy returns the version policy that applies to the file at
+// siaPath. The versionsMu must be held.
+func (r *Renter) versionPolicy(siaPath modules.SiaPath) modules.VersionPolicy {
+	dir, err := siaPath.Dir()
+	for err == nil {
+		if policy, exists := r.versionPolicies[dir]; exists {
+			return policy
+		}
+		if dir.IsRoot() {
+			break
+		}
+		dir, err = dir.Dir()
+	}
+	return defaultVersionPolicy
+}
+
+// archiveFile is called before the file at siaPath is overwritten. It moves
+// the siafile to the versions directory and prunes the versions of the file.
+// If the version policy of the file doesn't keep any versions, the file is
+// deleted instead. siafile.ErrUnknownPath is returned if the file doesn't
+// exist. The versionsMu must be held.
+func (r *Renter) archiveFile(siaPath modules.SiaPath) error {
+	policy := r.versionPolicy(siaPath)
+	if policy.MaxVersions == 0 {
+		return r.managedDeleteFile(siaPath)
+	}
+	entry, err := r.staticFileSet.Open(siaPath)
+	if err != nil {
+		return err
+	}
+	size := entry.Size()
+	// The file at the local path is about to be replaced by the new version,
+	// so it can't be used to repair the old version anymore.
+	err = entry.SetLocalPath("")
+	entry.Close()
+	if err != nil {
+		return errors.AddContext(err, "unable to clear the local path of the old version")
+	}
+
+	// Move the siafile to the versions directory.
+	id := r.nextVersionID
+	newSiaPath := versionSiaPath(siaPath, id)
+	if err := r.managedMoveFile(siaPath, newSiaPath); err != nil {
+		return errors.AddContext(err, "unable to move file to the versions directory")
+	}
+	r.nextVersionID++
+	r.versions[siaPath] = append(r.versions[siaPath], fileVersion{
+		ID:       id,
+		Created:  time.Now(),
+		Filesize: size,
+	})
+	err = r.pruneVersions(siaPath, policy)
+	return errors.Compose(err, r.saveVersions())
+}
+
+// managedArchiveFile is called before the file at siaPath is overwritten. See
+// archiveFile.
+func (r *Renter) managedArchiveFile(siaPath modules.SiaPath) error {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	return r.archiveFile(siaPath)
+}
+
+// managedMoveFile renames the siafile at siaPath to newSiaPath, creates the
+// siadir of newSiaPath if necessary and updates the metadata of both
+// directories.
+func (r *Renter) managedMoveFile(siaPath, newSiaPath modules.SiaPath) error {
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		return err
+	}
+	newDirSiaPath, err := newSiaPath.Dir()
+	if err != nil {
+		return err
+	}
+	siaDirEntry, err := r.staticDirSet.NewSiaDir(newDirSiaPath)
+	if err != siadir.ErrPathOverload && err != nil {
+		return err
+	} else if err == nil {
+		siaDirEntry.Close()
+	}
+	if err := r.staticFileSet.Rename(siaPath, newSiaPath); err != nil {
+		return err
+	}
+	go r.threadedBubbleMetadata(dirSiaPath)
+	go r.threadedBubbleMetadata(newDirSiaPath)
+	return nil
+}
+
+// pruneVersions deletes the versions of the file at siaPath that aren't kept
+// by the policy. Versions that can't be deleted are kept in the index. The
+// versionsMu must be held.
+func (r *Renter) pruneVersions(siaPath modules.SiaPath, policy modules.VersionPolicy) error {
+	versions := r.versions[siaPath]
+	var kept []fileVersion
+	var errs error
+	for i, v := range versions {
+		expired := policy.MaxAge > 0 && time.Since(v.Created) > policy.MaxAge
+		excess := uint64(len(versions)-i) > policy.MaxVersions
+		if !expired && !excess {
+			kept = append(kept, v)
+			continue
+		}
+		err := r.managedDeleteFile(versionSiaPath(siaPath, v.ID))
+		if err != nil && err != siafile.ErrUnknownPath {
+			errs = errors.Compose(errs, errors.AddContext(err, "unable to delete version"))
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.versions, siaPath)
+	} else {
+		r.versions[siaPath] = kept
+	}
+	return errs
+}
+
+// managedDeleteVersions deletes all versions of the files at the provided
+// siapaths.
+func (r *Renter) managedDeleteVersions(siaPaths ...modules.SiaPath) error {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	var errs error
+	var changed bool
+	for _, siaPath := range siaPaths {
+		if _, exists := r.versions[siaPath]; exists {
+			errs = errors.Compose(errs, r.pruneVersions(siaPath, modules.VersionPolicy{}))
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return errors.Compose(errs, r.saveVersions())
+}
+
+// managedRenameVersions moves the versions of the file at siaPath to the file
+// at newSiaPath.
+func (r *Renter) managedRenameVersions(siaPath, newSiaPath modules.SiaPath) error {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	if _, exists := r.versions[siaPath]; !exists {
+		return nil
+	}
+	err := r.renameVersions(siaPath, newSiaPath)
+	return errors.Compose(err, r.saveVersions())
+}
+
+// renameVersions moves the versions of the file at siaPath to the file at
+// newSiaPath. The versionsMu must be held.
+func (r *Renter) renameVersions(siaPath, newSiaPath modules.SiaPath) error {
+	versions, exists := r.versions[siaPath]
+	if !exists {
+		return nil
+	}
+	for i, v := range versions {
+		err := r.managedMoveFile(versionSiaPath(siaPath, v.ID), versionSiaPath(newSiaPath, v.ID))
+		if err != nil {
+			// Keep the versions that weren't moved at the old siapath.
+			r.versions[siaPath] = versions[i:]
+			for _, moved := range versions[:i] {
+				r.insertVersion(newSiaPath, moved)
+			}
+			return errors.AddContext(err, "unable to move version")
+		}
+	}
+	delete(r.versions, siaPath)
+	for _, v := range versions {
+		r.insertVersion(newSiaPath, v)
+	}
+	return nil
+}
+
+// insertVersion adds a version to the versions of the file at siaPath,
+// keeping them sorted by id. The versionsMu must be held.
+func (r *Renter) insertVersion(siaPath modules.SiaPath, v fileVersion) {
+	versions := append(r.versions[siaPath], v)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ID < versions[j].ID
+	})
+	r.versions[siaPath] = versions
+}
+
+// managedDeleteDirVersions deletes the versions of all files within the
+// directory at dir and the version policies of the directory and its
+// subdirectories.
+func (r *Renter) managedDeleteDirVersions(dir modules.SiaPath) error {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	var errs error
+	for siaPath := range r.versions {
+		if siaPath.IsWithin(dir) {
+			errs = errors.Compose(errs, r.pruneVersions(siaPath, modules.VersionPolicy{}))
+		}
+	}
+	for siaPath := range r.versionPolicies {
+		if siaPath.Equals(dir) || siaPath.IsWithin(dir) {
+			delete(r.versionPolicies, siaPath)
+		}
+	}
+	return errors.Compose(errs, r.saveVersions())
+}
+
+// managedRenameDirVersions moves the versions of all files within the
+// directory at oldPath and the version policies of the directory and its
+// subdirectories to newPath.
+func (r *Renter) managedRenameDirVersions(oldPath, newPath modules.SiaPath) error {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	var errs error
+	for siaPath := range r.versions {
+		if !siaPath.IsWithin(oldPath) {
+			continue
+		}
+		newSiaPath, err := siaPath.Rebase(oldPath, newPath)
+		if err == nil {
+			err = r.renameVersions(siaPath, newSiaPath)
+		}
+		errs = errors.Compose(errs, err)
+	}
+	for siaPath, policy := range r.versionPolicies {
+		if !siaPath.Equals(oldPath) && !siaPath.IsWithin(oldPath) {
+			continue
+		}
+		newSiaPath, err := siaPath.Rebase(oldPath, newPath)
+		if err != nil {
+			errs = errors.Compose(errs, err)
+			continue
+		}
+		delete(r.versionPolicies, siaPath)
+		r.versionPolicies[newSiaPath] = policy
+	}
+	return errors.Compose(errs, r.saveVersions())
+}
+
+// managedPruneVersions prunes the versions of all files according to their
+// version policies.
+func (r *Renter) managedPruneVersions() error {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	var errs error
+	for siaPath := range r.versions {
+		errs = errors.Compose(errs, r.pruneVersions(siaPath, r.versionPolicy(siaPath)))
+	}
+	return errors.Compose(errs, r.saveVersions())
+}
+
+// threadedPruneVersions periodically prunes old versions, so that versions
+// are deleted once they exceed the maximum age of their version policy.
+func (r *Renter) threadedPruneVersions() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(versionPruneInterval):
+		}
+		if err := r.managedPruneVersions(); err != nil {
+			r.log.Println("WARN: unable to prune old versions:", err)
+		}
+	}
+}
+
+// FileVersions returns the old versions of the file at siaPath, oldest first.
+func (r *Renter) FileVersions(siaPath modules.SiaPath) ([]modules.FileVersion, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	if err := checkReservedSiaPaths(siaPath); err != nil {
+		return nil, err
+	}
+	r.versionsMu.Lock()
+	versions := append([]fileVersion(nil), r.versions[siaPath]...)
+	r.versionsMu.Unlock()
+
+	offline, goodForRenew, contracts := r.managedContractUtilityMaps()
+	fvs := make([]modules.FileVersion, 0, len(versions))
+	for _, v := range versions {
+		fv := modules.FileVersion{
+			ID:       v.ID,
+			SiaPath:  siaPath,
+			Created:  v.Created,
+			Filesize: v.Filesize,
+		}
+		fi, err := r.staticFileSet.CachedFileInfo(versionSiaPath(siaPath, v.ID), offline, goodForRenew, contracts)
+		if err == nil {
+			fv.UploadedBytes = fi.UploadedBytes
+			fv.Redundancy = fi.Redundancy
+		}
+		fvs = append(fvs, fv)
+	}
+	return fvs, nil
+}
+
+// RestoreFileVersion restores the version of the file at siaPath with the
+// given id. If the file exists, it is archived as a new version before the
+// old version is restored.
+func (r *Renter) RestoreFileVersion(siaPath modules.SiaPath, id uint64) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if err := checkReservedSiaPaths(siaPath); err != nil {
+		return err
+	}
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	versions := r.versions[siaPath]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].ID >= id })
+	if i == len(versions) || versions[i].ID != id {
+		return errUnknownVersion
+	}
+
+	// Remove the version from the index before archiving the current file,
+	// so that the version can't be pruned.
+	remaining := append(append([]fileVersion(nil), versions[:i]...), versions[i+1:]...)
+	if len(remaining) == 0 {
+		delete(r.versions, siaPath)
+	} else {
+		r.versions[siaPath] = remaining
+	}
+	if err := r.archiveFile(siaPath); err != nil && err != siafile.ErrUnknownPath {
+		r.insertVersion(siaPath, versions[i])
+		return errors.AddContext(err, "unable to archive current version")
+	}
+	if err := r.managedMoveFile(versionSiaPath(siaPath, id), siaPath); err != nil {
+		r.insertVersion(siaPath, versions[i])
+		return errors.Compose(errors.AddContext(err, "unable to restore version"), r.saveVersions())
+	}
+	return r.saveVersions()
+}
+
+// SetVersionPolicy sets the version policy of the directory at siaPath and
+// prunes the versions that are no longer kept.
+func (r *Renter) SetVersionPolicy(siaPath modules.SiaPath, policy modules.VersionPolicy) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if err := checkReservedSiaPaths(siaPath); err != nil {
+		return err
+	}
+	if policy.MaxAge < 0 {
+		return errNegativeMaxAge
+	}
+	if !siaPath.IsRoot() {
+		if _, err := os.Stat(siaPath.SiaDirSysPath(r.staticFilesDir)); err != nil {
+			return siadir.ErrUnknownPath
+		}
+	}
+	r.versionsMu.Lock()
+	r.versionPolicies[siaPath] = policy
+	err := r.saveVersions()
+	r.versionsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	return r.managedPruneVersions()
+}
+
+// RemoveVersionPolicy removes the version policy of the directory at siaPath,
+// so that the policy of its parent directory applies again.
+func (r *Renter) RemoveVersionPolicy(siaPath modules.SiaPath) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	if err := checkReservedSiaPaths(siaPath); err != nil {
+		return err
+	}
+	r.versionsMu.Lock()
+	delete(r.versionPolicies, siaPath)
+	err := r.saveVersions()
+	r.versionsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	return r.managedPruneVersions()
+}
+
+// VersionPolicies returns the version policies of the renter sorted by
+// siapath. The policy of the root directory is always included.
+func (r *Renter) VersionPolicies() []modules.VersionPolicyInfo {
+	r.versionsMu.Lock()
+	defer r.versionsMu.Unlock()
+	policies := []modules.VersionPolicyInfo{{
+		SiaPath:       modules.RootSiaPath(),
+		VersionPolicy: defaultVersionPolicy,
+	}}
+	for siaPath, policy := range r.versionPolicies {
+		if siaPath.IsRoot() {
+			policies[0].VersionPolicy = policy
+			continue
+		}
+		policies = append(policies, modules.VersionPolicyInfo{SiaPath: siaPath, VersionPolicy: policy})
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].SiaPath.Path < policies[j].SiaPath.Path
+	})
+	return policies
+}
+
+// VersionStorage returns the number and size of the old versions stored by
+// the renter.
+func (r *Renter) VersionStorage() modules.VersionStorage {
+	r.versionsMu.Lock()
+	var siaPaths []modules.SiaPath
+	var vs modules.VersionStorage
+	for siaPath, versions := range r.versions {
+		for _, v := range versions {
+			siaPaths = append(siaPaths, versionSiaPath(siaPath, v.ID))
+			vs.NumVersions++
+			vs.Size += v.Filesize
+		}
+	}
+	r.versionsMu.Unlock()
+
+	offline, goodForRenew, contracts := r.managedContractUtilityMaps()
+	for _, siaPath := range siaPaths {
+		fi, err := r.staticFileSet.CachedFileInfo(siaPath, offline, goodForRenew, contracts)
+		if err == nil {
+			vs.UploadedBytes += fi.UploadedBytes
+		}
+	}
+	return vs
+}
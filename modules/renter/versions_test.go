@@ -0,0 +1,230 @@
+package renter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+// TestVersionPolicy checks that the version policy of a file is inherited from
+// the closest directory with a policy.
+func TestVersionPolicy(t *testing.T) {
+	r := &Renter{
+		versionPolicies: make(map[modules.SiaPath]modules.VersionPolicy),
+	}
+	file := modules.SiaPath{Path: "a/b/c"}
+	if p := r.versionPolicy(file); p != defaultVersionPolicy {
+		t.Fatal("expected default policy, got", p)
+	}
+	root := modules.VersionPolicy{MaxVersions: 5}
+	r.versionPolicies[modules.RootSiaPath()] = root
+	if p := r.versionPolicy(file); p != root {
+		t.Fatal("expected root policy, got", p)
+	}
+	a := modules.VersionPolicy{MaxVersions: 1, MaxAge: time.Hour}
+	r.versionPolicies[modules.SiaPath{Path: "a"}] = a
+	if p := r.versionPolicy(file); p != a {
+		t.Fatal("expected policy of a, got", p)
+	}
+	if p := r.versionPolicy(modules.SiaPath{Path: "ab/c"}); p != root {
+		t.Fatal("expected root policy for sibling dir, got", p)
+	}
+	r.versionPolicies[modules.SiaPath{Path: "a/b/c"}] = modules.VersionPolicy{}
+	if p := r.versionPolicy(file); p != a {
+		t.Fatal("policy of a file's siapath shouldn't apply to the file", p)
+	}
+}
+
+// TestFileVersions checks that overwritten files are kept as old versions,
+// that versions can be restored and that they are pruned according to the
+// version policy.
+func TestFileVersions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	siaPath := modules.SiaPath{Path: "dir/file"}
+	upload := func(size int) {
+		source := filepath.Join(rt.dir, "source")
+		if err := ioutil.WriteFile(source, fastrand.Bytes(size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := r.Upload(modules.FileUploadParams{Source: source, SiaPath: siaPath, Force: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	versionSizes := func() []uint64 {
+		versions, err := r.FileVersions(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sizes []uint64
+		for _, v := range versions {
+			if !r.staticFileSet.Exists(versionSiaPath(siaPath, v.ID)) {
+				t.Fatal("siafile of version doesn't exist", v.ID)
+			}
+			sizes = append(sizes, v.Filesize)
+		}
+		return sizes
+	}
+	fileSize := func() uint64 {
+		fi, err := r.File(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Filesize
+	}
+
+	// Overwrite the file a few times. The default policy keeps 3 versions,
+	// and the storage they use is reported.
+	for size := 100; size <= 500; size += 100 {
+		upload(size)
+	}
+	if sizes := versionSizes(); len(sizes) != 3 || sizes[0] != 200 || sizes[2] != 400 {
+		t.Fatal("unexpected versions", sizes)
+	}
+	if fileSize() != 500 {
+		t.Fatal("file wasn't overwritten")
+	}
+	if vs := r.VersionStorage(); vs.NumVersions != 3 || vs.Size != 900 {
+		t.Fatalf("unexpected version storage: %+v", vs)
+	}
+
+	// The versions are hidden and can't be modified directly.
+	files, err := r.FileList(modules.RootSiaPath(), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatal("expected only the current version to be listed, got", len(files))
+	}
+	dirs, err := r.DirList(modules.RootSiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if isVersionSiaPath(dir.SiaPath) {
+			t.Fatal("versions directory shouldn't be listed")
+		}
+	}
+	versions, err := r.FileVersions(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The local file now belongs to the current version.
+	for _, v := range versions {
+		entry, err := r.staticFileSet.Open(versionSiaPath(siaPath, v.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		localPath := entry.LocalPath()
+		entry.Close()
+		if localPath != "" {
+			t.Fatal("local path of version wasn't cleared:", localPath)
+		}
+	}
+	if err := r.DeleteFile(versionSiaPath(siaPath, versions[0].ID)); err != errVersionPathReserved {
+		t.Fatal("expected errVersionPathReserved, got", err)
+	}
+
+	// Restore the oldest version. The current version becomes a version.
+	if err := r.RestoreFileVersion(siaPath, versions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if fileSize() != 200 {
+		t.Fatal("version wasn't restored")
+	}
+	if sizes := versionSizes(); len(sizes) != 3 || sizes[0] != 300 || sizes[2] != 500 {
+		t.Fatal("unexpected versions", sizes)
+	}
+	if err := r.RestoreFileVersion(siaPath, versions[0].ID); err != errUnknownVersion {
+		t.Fatal("expected errUnknownVersion, got", err)
+	}
+
+	// Renaming the file moves its versions.
+	newSiaPath := modules.SiaPath{Path: "dir2/file"}
+	if err := r.RenameFile(siaPath, newSiaPath); err != nil {
+		t.Fatal(err)
+	}
+	if sizes := versionSizes(); len(sizes) != 0 {
+		t.Fatal("versions weren't moved", sizes)
+	}
+	siaPath = newSiaPath
+	if sizes := versionSizes(); len(sizes) != 3 {
+		t.Fatal("versions weren't moved", sizes)
+	}
+
+	// A stricter policy prunes the versions right away.
+	if err := r.SetVersionPolicy(modules.SiaPath{Path: "dir2"}, modules.VersionPolicy{MaxVersions: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if sizes := versionSizes(); len(sizes) != 1 || sizes[0] != 500 {
+		t.Fatal("unexpected versions", sizes)
+	}
+	if err := r.SetVersionPolicy(modules.SiaPath{Path: "dir2"}, modules.VersionPolicy{MaxVersions: 1, MaxAge: time.Nanosecond}); err != nil {
+		t.Fatal(err)
+	}
+	if sizes := versionSizes(); len(sizes) != 0 {
+		t.Fatal("expired versions weren't pruned", sizes)
+	}
+
+	// Versioning can be disabled.
+	if err := r.SetVersionPolicy(modules.SiaPath{Path: "dir2"}, modules.VersionPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	upload(600)
+	if sizes := versionSizes(); len(sizes) != 0 {
+		t.Fatal("versions were kept although versioning is disabled", sizes)
+	}
+	if err := r.RemoveVersionPolicy(modules.SiaPath{Path: "dir2"}); err != nil {
+		t.Fatal(err)
+	}
+	upload(700)
+	if sizes := versionSizes(); len(sizes) != 1 {
+		t.Fatal("expected the default policy to apply again", sizes)
+	}
+
+	// The versions and policies are persisted.
+	if err := r.SetVersionPolicy(modules.SiaPath{Path: "dir2"}, modules.VersionPolicy{MaxVersions: 2}); err != nil {
+		t.Fatal(err)
+	}
+	r2 := &Renter{
+		persistDir:      r.persistDir,
+		versions:        make(map[modules.SiaPath][]fileVersion),
+		versionPolicies: make(map[modules.SiaPath]modules.VersionPolicy),
+	}
+	if err := r2.managedLoadVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r2.versions[siaPath]) != 1 || r2.versionPolicies[modules.SiaPath{Path: "dir2"}].MaxVersions != 2 || r2.nextVersionID != r.nextVersionID {
+		t.Fatal("versions weren't persisted")
+	}
+
+	// Deleting the directory and purging it from the trash deletes the
+	// versions and the policy.
+	if err := r.DeleteDir(modules.SiaPath{Path: "dir2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.PurgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := r.versionPolicies[modules.SiaPath{Path: "dir2"}]; len(r.versions) != 0 || exists {
+		t.Fatal("versions weren't deleted with their directory")
+	}
+	if r.staticFileSet.Exists(versionSiaPath(siaPath, r2.versions[siaPath][0].ID)) {
+		t.Fatal("siafile of version wasn't deleted")
+	}
+}
@@ -100,15 +100,15 @@ func (w *worker) managedDownload(udc *unfinishedDownloadChunk) {
 	udc.markPieceCompleted(pieceIndex)
 	udc.piecesRegistered--
 	if udc.piecesCompleted <= udc.erasureCode.MinPieces() {
-		atomic.AddUint64(&udc.download.atomicDataReceived, udc.staticFetchLength/uint64(udc.erasureCode.MinPieces()))
+		atomic.AddUint64(&udc.download.atomicDataReceived, udc.staticDataLength/uint64(udc.erasureCode.MinPieces()))
 		udc.physicalChunkData[pieceIndex] = decryptedPiece
 	}
 	if udc.piecesCompleted == udc.erasureCode.MinPieces() {
 		// Uint division might not always cause atomicDataReceived to cleanly
-		// add up to staticFetchLength so we need to figure out how much we
+		// add up to staticDataLength so we need to figure out how much we
 		// already added to the download and how much is missing.
-		addedReceivedData := uint64(udc.erasureCode.MinPieces()) * (udc.staticFetchLength / uint64(udc.erasureCode.MinPieces()))
-		atomic.AddUint64(&udc.download.atomicDataReceived, udc.staticFetchLength-addedReceivedData)
+		addedReceivedData := uint64(udc.erasureCode.MinPieces()) * (udc.staticDataLength / uint64(udc.erasureCode.MinPieces()))
+		atomic.AddUint64(&udc.download.atomicDataReceived, udc.staticDataLength-addedReceivedData)
 		// Recover the logical data.
 		if err := w.renter.tg.Add(); err != nil {
 			w.renter.log.Debugln("worker failed to decrypt piece:", err)
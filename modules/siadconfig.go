@@ -18,6 +18,10 @@ type (
 		WriteBPS   int64  `json:"writeps"`
 		PacketSize uint64 `json:"packetsize"`
 
+		// APITokens are the scoped tokens that grant access to the API in
+		// addition to the API password.
+		APITokens []APIToken `json:"apitokens"`
+
 		// path of config on disk.
 		path string
 		mu   sync.Mutex
@@ -95,6 +95,12 @@ func (sp SiaPath) IsRoot() bool {
 	return sp.Path == ""
 }
 
+// IsWithin returns true if the SiaPath is within the directory at dir or one of
+// its subdirectories.
+func (sp SiaPath) IsWithin(dir SiaPath) bool {
+	return dir.IsRoot() || strings.HasPrefix(sp.Path, dir.Path+"/")
+}
+
 // Join joins the string to the end of the SiaPath with a "/" and returns
 // the new SiaPath
 func (sp SiaPath) Join(s string) (SiaPath, error) {
@@ -189,3 +189,27 @@ func TestSiapathRebase(t *testing.T) {
 		}
 	}
 }
+
+// TestSiapathIsWithin probes the IsWithin method of SiaPath.
+func TestSiapathIsWithin(t *testing.T) {
+	var tests = []struct {
+		siaPath string
+		dir     string
+		within  bool
+	}{
+		{"a/b", "", true},    // dir is root
+		{"a/b", "a", true},   // direct child
+		{"a/b/c", "a", true}, // nested child
+		{"a", "a", false},    // the dir itself
+		{"ab/c", "a", false}, // dir is only a prefix
+		{"b/a", "a", false},  // different dir
+		{"a", "a/b", false},  // parent of the dir
+	}
+	for _, test := range tests {
+		siaPath := SiaPath{Path: test.siaPath}
+		dir := SiaPath{Path: test.dir}
+		if siaPath.IsWithin(dir) != test.within {
+			t.Errorf("'%v' within '%v': expected %v", test.siaPath, test.dir, test.within)
+		}
+	}
+}
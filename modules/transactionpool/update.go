@@ -190,15 +190,16 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 	}
 
 	// Update the database of confirmed transactions.
-	for _, block := range cc.RevertedBlocks {
+	for i, block := range cc.RevertedBlocks {
 		// Sanity check - the id of each reverted block should match the recent
 		// parent id.
-		if block.ID() != recentID && !resetSanityCheck {
-			panic(fmt.Sprintf("Consensus change series appears to be inconsistent - we are reverting the wrong block. bid: %v recent: %v", block.ID(), recentID))
+		blockID := cc.RevertedBlockID(i)
+		if blockID != recentID && !resetSanityCheck {
+			panic(fmt.Sprintf("Consensus change series appears to be inconsistent - we are reverting the wrong block. bid: %v recent: %v", blockID, recentID))
 		}
 		recentID = block.ParentID
 
-		if tp.blockHeight > 0 || block.ID() != types.GenesisID {
+		if tp.blockHeight > 0 || blockID != types.GenesisID {
 			tp.blockHeight--
 		}
 		for _, txn := range block.Transactions {
@@ -217,15 +218,15 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 			tp.recentMedians = tp.recentMedians[:len(tp.recentMedians)-1]
 		}
 	}
-	for _, block := range cc.AppliedBlocks {
+	for i, block := range cc.AppliedBlocks {
 		// Sanity check - the parent id of each block should match the current
 		// block id.
 		if block.ParentID != recentID && !resetSanityCheck {
 			panic(fmt.Sprintf("Consensus change series appears to be inconsistent - we are applying the wrong block. pid: %v recent: %v", block.ParentID, recentID))
 		}
-		recentID = block.ID()
+		recentID = cc.AppliedBlockID(i)
 
-		if tp.blockHeight > 0 || block.ID() != types.GenesisID {
+		if tp.blockHeight > 0 || recentID != types.GenesisID {
 			tp.blockHeight++
 		}
 		for _, txn := range block.Transactions {
@@ -27,6 +27,31 @@ const (
 	WalletDir = "wallet"
 )
 
+// The categories of the entries of an exported wallet ledger.
+const (
+	// LedgerCategoryContractFormation is the category of transactions that
+	// form file contracts.
+	LedgerCategoryContractFormation = "contractformation"
+
+	// LedgerCategoryContractPayout is the category of file contract revisions
+	// that pay out to the renter side of a contract.
+	LedgerCategoryContractPayout = "contractpayout"
+
+	// LedgerCategoryHostPayout is the category of file contract revisions
+	// that pay out to the host side of a contract.
+	LedgerCategoryHostPayout = "hostpayout"
+
+	// LedgerCategoryMinerPayout is the category of block rewards.
+	LedgerCategoryMinerPayout = "minerpayout"
+
+	// LedgerCategorySiafundClaim is the category of transactions that claim
+	// siacoins from the siafund pool.
+	LedgerCategorySiafundClaim = "siafundclaim"
+
+	// LedgerCategoryTransfer is the category of all other transactions.
+	LedgerCategoryTransfer = "transfer"
+)
+
 var (
 	// ErrBadEncryptionKey is returned if the incorrect encryption key to a
 	// file is provided.
@@ -59,6 +84,19 @@ type (
 	// WalletTransactionID is a unique identifier for a wallet transaction.
 	WalletTransactionID crypto.Hash
 
+	// A WalletAccount is a named group of wallet addresses. Accounts are used
+	// to separate the funds of the wallet, for example to keep the renter
+	// allowance apart from the host collateral. An address belongs to at most
+	// one account.
+	WalletAccount struct {
+		Name      string             `json:"name"`
+		Addresses []types.UnlockHash `json:"addresses"`
+
+		ConfirmedSiacoinBalance      types.Currency `json:"confirmedsiacoinbalance"`
+		ConfirmedSiafundBalance      types.Currency `json:"confirmedsiafundbalance"`
+		ConfirmedSiafundClaimBalance types.Currency `json:"confirmedsiafundclaimbalance"`
+	}
+
 	// A ProcessedInput represents funding to a transaction. The input is
 	// coming from an address and going to the outputs. The fund types are
 	// 'SiacoinInput', 'SiafundInput'.
@@ -117,6 +155,34 @@ type (
 		ConfirmedOutgoingValue types.Currency `json:"confirmedoutgoingvalue"`
 	}
 
+	// A WalletLedgerEntry is a single entry of an exported wallet ledger. The
+	// values are relative to the addresses the ledger was exported for. The
+	// net value is signed and given in hastings.
+	WalletLedgerEntry struct {
+		TransactionID         types.TransactionID `json:"transactionid"`
+		ConfirmationHeight    types.BlockHeight   `json:"confirmationheight"`
+		ConfirmationTimestamp types.Timestamp     `json:"confirmationtimestamp"`
+		Category              string              `json:"category"`
+
+		IncomingValue types.Currency `json:"incomingvalue"`
+		OutgoingValue types.Currency `json:"outgoingvalue"`
+		Fees          types.Currency `json:"fees"`
+		NetValue      string         `json:"netvalue"`
+	}
+
+	// WalletLedgerParams select the transactions of an exported wallet
+	// ledger. Zero heights and timestamps leave the range open on that side.
+	// If addresses or an account are given, only transactions related to
+	// them are exported and their values are computed relative to them.
+	WalletLedgerParams struct {
+		StartHeight types.BlockHeight
+		EndHeight   types.BlockHeight
+		StartTime   types.Timestamp
+		EndTime     types.Timestamp
+		Addresses   []types.UnlockHash
+		Account     string
+	}
+
 	// A UnspentOutput is a SiacoinOutput or SiafundOutput that the wallet
 	// is tracking.
 	UnspentOutput struct {
@@ -373,6 +439,18 @@ type (
 		EncryptionManager
 		KeyManager
 
+		// AccountAddress returns a new address from the primary seed that
+		// belongs to the account with the provided name.
+		AccountAddress(name string) (types.UnlockConditions, error)
+
+		// AccountBalance returns the account with the provided name, including
+		// its confirmed balance.
+		AccountBalance(name string) (WalletAccount, error)
+
+		// Accounts returns all of the accounts of the wallet, including their
+		// confirmed balances.
+		Accounts() ([]WalletAccount, error)
+
 		// AddUnlockConditions adds a set of UnlockConditions to the wallet database.
 		AddUnlockConditions(uc types.UnlockConditions) error
 
@@ -383,9 +461,25 @@ type (
 		// the blockchain to search for transactions containing the addresses.
 		AddWatchAddresses(addrs []types.UnlockHash, unused bool) error
 
+		// AssignAccountAddresses adds a set of spendable wallet addresses,
+		// such as the addresses of imported keys, to an account.
+		AssignAccountAddresses(name string, addrs []types.UnlockHash) error
+
+		// AssignAccountSeedRange adds the addresses of the primary seed
+		// indices [start, end) to an account. Only addresses that the wallet
+		// has already generated can be assigned.
+		AssignAccountSeedRange(name string, start, end uint64) error
+
 		// Close permits clean shutdown during testing and serving.
 		Close() error
 
+		// CreateAccount creates a new, empty account.
+		CreateAccount(name string) error
+
+		// DeleteAccount deletes an account. The addresses of the account are
+		// not removed from the wallet, they only stop being grouped.
+		DeleteAccount(name string) error
+
 		// ConfirmedBalance returns the confirmed balance of the wallet, minus
 		// any outgoing transactions. ConfirmedBalance will include unconfirmed
 		// refund transactions.
@@ -401,6 +495,10 @@ type (
 		// Height returns the wallet's internal processed consensus height
 		Height() (types.BlockHeight, error)
 
+		// Ledger returns the confirmed transactions of the wallet selected by
+		// the params as ledger entries, in the order they were confirmed.
+		Ledger(params WalletLedgerParams) ([]WalletLedgerEntry, error)
+
 		// AddressTransactions returns all of the transactions that are related
 		// to a given address.
 		AddressTransactions(types.UnlockHash) ([]ProcessedTransaction, error)
@@ -454,6 +552,11 @@ type (
 		// are also returned to the caller.
 		SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
 
+		// SendSiacoinsFromAccount is like SendSiacoins, but the transaction is
+		// only funded by the outputs of the provided account. Any change is
+		// returned to the account.
+		SendSiacoinsFromAccount(name string, amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
+
 		// SendSiacoinsMulti sends coins to multiple addresses.
 		SendSiacoinsMulti(outputs []types.SiacoinOutput) ([]types.Transaction, error)
 
@@ -0,0 +1,352 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	bolt "github.com/coreos/bbolt"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// maxAccountNameLen is the maximum length of the name of an account.
+	maxAccountNameLen = 64
+)
+
+var (
+	// errAccountExists is returned when creating an account with a name that
+	// is already in use.
+	errAccountExists = errors.New("an account with that name already exists")
+
+	// errAddressInAccount is returned when assigning an address that already
+	// belongs to a different account.
+	errAddressInAccount = errors.New("address already belongs to a different account")
+
+	// errAddressNotSpendable is returned when assigning an address to an
+	// account that the wallet does not have the keys for.
+	errAddressNotSpendable = errors.New("address is not a spendable address of the wallet")
+
+	// errInvalidAccountName is returned when the name of an account is empty
+	// or too long.
+	errInvalidAccountName = errors.New("account name must be between 1 and 64 characters")
+
+	// errInvalidSeedRange is returned when assigning a range of seed indices
+	// that is empty or that has not been generated yet.
+	errInvalidSeedRange = errors.New("seed range must be non-empty and can only contain addresses that were already generated")
+
+	// errUnknownAccount is returned when an account does not exist.
+	errUnknownAccount = errors.New("account does not exist")
+)
+
+// validateAccountName checks that an account name is valid.
+func validateAccountName(name string) error {
+	if len(name) == 0 || len(name) > maxAccountNameLen {
+		return errInvalidAccountName
+	}
+	return nil
+}
+
+// dbGetAccountAddrs returns the set of addresses of an account.
+func dbGetAccountAddrs(tx *bolt.Tx, name string) (map[types.UnlockHash]struct{}, error) {
+	addrs, err := dbGetAccount(tx, name)
+	if err == errNoKey {
+		return nil, errUnknownAccount
+	} else if err != nil {
+		return nil, err
+	}
+	set := make(map[types.UnlockHash]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set, nil
+}
+
+// dbAssignAccountAddrs adds a set of addresses to an account. Addresses that
+// already belong to the account are skipped, addresses that belong to a
+// different account are rejected.
+func dbAssignAccountAddrs(tx *bolt.Tx, name string, addrs []types.UnlockHash) error {
+	existing, err := dbGetAccount(tx, name)
+	if err == errNoKey {
+		return errUnknownAccount
+	} else if err != nil {
+		return err
+	}
+	owners := make(map[types.UnlockHash]string)
+	err = dbForEachAccount(tx, func(account string, accountAddrs []types.UnlockHash) {
+		for _, addr := range accountAddrs {
+			owners[addr] = account
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		owner, exists := owners[addr]
+		if exists && owner != name {
+			return errAddressInAccount
+		} else if exists {
+			continue
+		}
+		owners[addr] = name
+		existing = append(existing, addr)
+	}
+	return dbPutAccount(tx, name, existing)
+}
+
+// dbAddAccountAddr adds a single address to an account.
+func dbAddAccountAddr(tx *bolt.Tx, name string, addr types.UnlockHash) error {
+	return dbAssignAccountAddrs(tx, name, []types.UnlockHash{addr})
+}
+
+// managedAssignAccountAddrs adds a set of addresses to an account and saves the
+// change.
+func (w *Wallet) managedAssignAccountAddrs(name string, addrs []types.UnlockHash) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := dbAssignAccountAddrs(w.dbTx, name, addrs); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// accountBalance returns an account with its confirmed balance, computed the
+// same way as the confirmed balance of the whole wallet.
+func (w *Wallet) accountBalance(name string, addrs []types.UnlockHash, dustThreshold types.Currency) (modules.WalletAccount, error) {
+	acc := modules.WalletAccount{
+		Name:      name,
+		Addresses: addrs,
+	}
+	set := make(map[types.UnlockHash]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	err := dbForEachSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if _, exists := set[sco.UnlockHash]; exists && sco.Value.Cmp(dustThreshold) > 0 {
+			acc.ConfirmedSiacoinBalance = acc.ConfirmedSiacoinBalance.Add(sco.Value)
+		}
+	})
+	if err != nil {
+		return modules.WalletAccount{}, err
+	}
+	siafundPool, err := dbGetSiafundPool(w.dbTx)
+	if err != nil {
+		return modules.WalletAccount{}, err
+	}
+	err = dbForEachSiafundOutput(w.dbTx, func(_ types.SiafundOutputID, sfo types.SiafundOutput) {
+		if _, exists := set[sfo.UnlockHash]; !exists {
+			return
+		}
+		acc.ConfirmedSiafundBalance = acc.ConfirmedSiafundBalance.Add(sfo.Value)
+		if sfo.ClaimStart.Cmp(siafundPool) > 0 {
+			return
+		}
+		acc.ConfirmedSiafundClaimBalance = acc.ConfirmedSiafundClaimBalance.Add(siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value).Div(types.SiafundCount))
+	})
+	if err != nil {
+		return modules.WalletAccount{}, err
+	}
+	return acc, nil
+}
+
+// CreateAccount creates a new, empty account.
+func (w *Wallet) CreateAccount(name string) error {
+	if err := w.tg.Add(); err != nil {
+		return modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+	if err := validateAccountName(name); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := dbGetAccount(w.dbTx, name); err == nil {
+		return errAccountExists
+	} else if err != errNoKey {
+		return err
+	}
+	if err := dbPutAccount(w.dbTx, name, []types.UnlockHash{}); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// DeleteAccount deletes an account. The addresses of the account remain part
+// of the wallet.
+func (w *Wallet) DeleteAccount(name string) error {
+	if err := w.tg.Add(); err != nil {
+		return modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := dbGetAccount(w.dbTx, name); err == errNoKey {
+		return errUnknownAccount
+	} else if err != nil {
+		return err
+	}
+	if err := dbDeleteAccount(w.dbTx, name); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// Accounts returns all of the accounts of the wallet, sorted by name and
+// including their confirmed balances.
+func (w *Wallet) Accounts() ([]modules.WalletAccount, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold, err := w.DustThreshold()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var accounts []modules.WalletAccount
+	var accErr error
+	err = dbForEachAccount(w.dbTx, func(name string, addrs []types.UnlockHash) {
+		acc, err := w.accountBalance(name, addrs, dustThreshold)
+		if err != nil {
+			accErr = err
+			return
+		}
+		accounts = append(accounts, acc)
+	})
+	if err != nil {
+		return nil, err
+	} else if accErr != nil {
+		return nil, accErr
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts, nil
+}
+
+// AccountBalance returns the account with the provided name, including its
+// confirmed balance.
+func (w *Wallet) AccountBalance(name string) (modules.WalletAccount, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.WalletAccount{}, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold, err := w.DustThreshold()
+	if err != nil {
+		return modules.WalletAccount{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	addrs, err := dbGetAccount(w.dbTx, name)
+	if err == errNoKey {
+		return modules.WalletAccount{}, errUnknownAccount
+	} else if err != nil {
+		return modules.WalletAccount{}, err
+	}
+	return w.accountBalance(name, addrs, dustThreshold)
+}
+
+// AccountAddress returns a new address from the primary seed that belongs to
+// the account with the provided name.
+func (w *Wallet) AccountAddress(name string) (types.UnlockConditions, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.UnlockConditions{}, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := dbGetAccount(w.dbTx, name); err == errNoKey {
+		return types.UnlockConditions{}, errUnknownAccount
+	} else if err != nil {
+		return types.UnlockConditions{}, err
+	}
+	uc, err := w.nextPrimarySeedAddress(w.dbTx)
+	if err != nil {
+		return types.UnlockConditions{}, err
+	}
+	if err := dbAddAccountAddr(w.dbTx, name, uc.UnlockHash()); err != nil {
+		return types.UnlockConditions{}, err
+	}
+	return uc, w.syncDB()
+}
+
+// AssignAccountAddresses adds a set of spendable wallet addresses, such as the
+// addresses of imported keys, to an account.
+func (w *Wallet) AssignAccountAddresses(name string, addrs []types.UnlockHash) error {
+	if err := w.tg.Add(); err != nil {
+		return modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	}
+	for _, addr := range addrs {
+		if _, exists := w.keys[addr]; !exists {
+			return errAddressNotSpendable
+		}
+	}
+	if err := dbAssignAccountAddrs(w.dbTx, name, addrs); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// AssignAccountSeedRange adds the addresses of the primary seed indices
+// [start, end) to an account.
+func (w *Wallet) AssignAccountSeedRange(name string, start, end uint64) error {
+	if err := w.tg.Add(); err != nil {
+		return modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	}
+	progress, err := dbGetPrimarySeedProgress(w.dbTx)
+	if err != nil {
+		return err
+	}
+	if start >= end || end > progress {
+		return errInvalidSeedRange
+	}
+	keys := generateKeys(w.primarySeed, start, end-start)
+	addrs := make([]types.UnlockHash, 0, len(keys))
+	for _, key := range keys {
+		addrs = append(addrs, key.UnlockConditions.UnlockHash())
+	}
+	if err := dbAssignAccountAddrs(w.dbTx, name, addrs); err != nil {
+		return err
+	}
+	return w.syncDB()
+}
+
+// SendSiacoinsFromAccount creates a transaction sending 'amount' to 'dest'
+// that is only funded by the outputs of the provided account. The change of
+// the transaction is returned to the account. The transaction is submitted to
+// the transaction pool and is also returned.
+func (w *Wallet) SendSiacoinsFromAccount(name string, amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+	if err := validateAccountName(name); err != nil {
+		return nil, err
+	}
+	return w.managedSendSiacoins(name, amount, dest)
+}
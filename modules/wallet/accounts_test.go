@@ -0,0 +1,162 @@
+package wallet
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// TestAccounts probes the creation, assignment and deletion of wallet
+// accounts.
+func TestAccounts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	if err := wt.wallet.CreateAccount(""); err != errInvalidAccountName {
+		t.Fatalf("expected %v, got %v", errInvalidAccountName, err)
+	}
+	if err := wt.wallet.CreateAccount("renter"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.CreateAccount("renter"); err != errAccountExists {
+		t.Fatalf("expected %v, got %v", errAccountExists, err)
+	}
+	if err := wt.wallet.CreateAccount("host"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.AccountAddress("payroll"); err != errUnknownAccount {
+		t.Fatalf("expected %v, got %v", errUnknownAccount, err)
+	}
+
+	// Assign a range of seed indices to one account. The same addresses can
+	// not be assigned to a different account.
+	progress, err := dbGetPrimarySeedProgress(wt.wallet.dbTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.AssignAccountSeedRange("renter", 0, progress+1); err != errInvalidSeedRange {
+		t.Fatalf("expected %v, got %v", errInvalidSeedRange, err)
+	}
+	if err := wt.wallet.AssignAccountSeedRange("renter", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.AssignAccountSeedRange("host", 0, 1); err != errAddressInAccount {
+		t.Fatalf("expected %v, got %v", errAddressInAccount, err)
+	}
+	if err := wt.wallet.AssignAccountAddresses("host", []types.UnlockHash{{}}); err != errAddressNotSpendable {
+		t.Fatalf("expected %v, got %v", errAddressNotSpendable, err)
+	}
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.AssignAccountAddresses("host", []types.UnlockHash{uc.UnlockHash()}); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := wt.wallet.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || accounts[0].Name != "host" || accounts[1].Name != "renter" {
+		t.Fatal("unexpected accounts", accounts)
+	}
+	if len(accounts[0].Addresses) != 1 || accounts[0].Addresses[0] != uc.UnlockHash() {
+		t.Fatal("address was not assigned to the account")
+	}
+
+	// Deleting an account frees its addresses.
+	if err := wt.wallet.DeleteAccount("host"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.DeleteAccount("host"); err != errUnknownAccount {
+		t.Fatalf("expected %v, got %v", errUnknownAccount, err)
+	}
+	if err := wt.wallet.AssignAccountAddresses("renter", []types.UnlockHash{uc.UnlockHash()}); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := wt.wallet.AccountBalance("renter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acc.Addresses) != 2 {
+		t.Fatal("expected 2 addresses in the account, got", len(acc.Addresses))
+	}
+}
+
+// TestSendSiacoinsFromAccount checks that sends from an account are only
+// funded by the account and that the change returns to the account.
+func TestSendSiacoinsFromAccount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	if err := wt.waitForSync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wt.wallet.CreateAccount("payroll"); err != nil {
+		t.Fatal(err)
+	}
+	uc, err := wt.wallet.AccountAddress("payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty account can not fund a transaction.
+	if _, err := wt.wallet.SendSiacoinsFromAccount("payroll", types.SiacoinPrecision, types.UnlockHash{}); err == nil {
+		t.Fatal("empty account funded a transaction")
+	}
+
+	// Fund the account from the rest of the wallet.
+	fund := types.SiacoinPrecision.Mul64(100)
+	if _, err := wt.wallet.SendSiacoins(fund, uc.UnlockHash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := wt.wallet.AccountBalance("payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acc.ConfirmedSiacoinBalance.Equals(fund) {
+		t.Fatalf("expected account balance %v, got %v", fund, acc.ConfirmedSiacoinBalance)
+	}
+
+	// Sending more than the account holds fails, even though the wallet has
+	// enough coins.
+	if _, err := wt.wallet.SendSiacoinsFromAccount("payroll", fund.Mul64(2), types.UnlockHash{}); err == nil {
+		t.Fatal("account funded a transaction larger than its balance")
+	}
+
+	// Send from the account. The change is returned to the account.
+	sendValue := types.SiacoinPrecision.Mul64(30)
+	_, tpoolFee := wt.wallet.tpool.FeeEstimation()
+	tpoolFee = tpoolFee.Mul64(750)
+	if _, err := wt.wallet.SendSiacoinsFromAccount("payroll", sendValue, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	acc, err = wt.wallet.AccountBalance("payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fund.Sub(sendValue).Sub(tpoolFee)
+	if !acc.ConfirmedSiacoinBalance.Equals(expected) {
+		t.Fatalf("expected account balance %v, got %v", expected, acc.ConfirmedSiacoinBalance)
+	}
+}
@@ -16,6 +16,9 @@ import (
 )
 
 var (
+	// bucketAccounts maps the name of an account to the addresses that belong
+	// to it.
+	bucketAccounts = []byte("bucketAccounts")
 	// bucketProcessedTransactions stores ProcessedTransactions in
 	// chronological order. Only transactions relevant to the wallet are
 	// stored. The key of this bucket is an autoincrementing integer.
@@ -48,6 +51,7 @@ var (
 	bucketWallet = []byte("bucketWallet")
 
 	dbBuckets = [][]byte{
+		bucketAccounts,
 		bucketProcessedTransactions,
 		bucketProcessedTxnIndex,
 		bucketAddrTransactions,
@@ -255,6 +259,20 @@ func dbGetUnlockConditions(tx *bolt.Tx, addr types.UnlockHash) (uc types.UnlockC
 	return
 }
 
+func dbPutAccount(tx *bolt.Tx, name string, addrs []types.UnlockHash) error {
+	return dbPut(tx.Bucket(bucketAccounts), name, addrs)
+}
+func dbGetAccount(tx *bolt.Tx, name string) (addrs []types.UnlockHash, err error) {
+	err = dbGet(tx.Bucket(bucketAccounts), name, &addrs)
+	return
+}
+func dbDeleteAccount(tx *bolt.Tx, name string) error {
+	return dbDelete(tx.Bucket(bucketAccounts), name)
+}
+func dbForEachAccount(tx *bolt.Tx, fn func(string, []types.UnlockHash)) error {
+	return dbForEach(tx.Bucket(bucketAccounts), fn)
+}
+
 // dbAddAddrTransaction appends a single transaction index to the set of
 // transactions associated with addr. If the index is already in the set, it is
 // not added again.
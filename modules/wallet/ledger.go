@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"math/big"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// ledgerCategory determines the category of a valued transaction.
+func ledgerCategory(vt modules.ValuedTransaction) string {
+	txn := vt.Transaction
+	for _, output := range vt.Outputs {
+		if output.FundType == types.SpecifierMinerPayout {
+			return modules.LedgerCategoryMinerPayout
+		}
+	}
+	if len(txn.FileContracts) > 0 {
+		return modules.LedgerCategoryContractFormation
+	}
+	if len(txn.FileContractRevisions) > 0 {
+		// The second valid proof output of a contract pays the host.
+		rev := txn.FileContractRevisions[0]
+		if len(rev.NewValidProofOutputs) < 2 {
+			return modules.LedgerCategoryContractPayout
+		}
+		for _, output := range vt.Outputs {
+			if output.WalletAddress && output.RelatedAddress == rev.NewValidProofOutputs[1].UnlockHash {
+				return modules.LedgerCategoryHostPayout
+			}
+		}
+		return modules.LedgerCategoryContractPayout
+	}
+	for _, output := range vt.Outputs {
+		if output.FundType == types.SpecifierClaimOutput && output.WalletAddress {
+			return modules.LedgerCategorySiafundClaim
+		}
+	}
+	return modules.LedgerCategoryTransfer
+}
+
+// ledgerEntry converts a valued transaction into a ledger entry. Fees are only
+// attributed to transactions that spend coins of the related addresses. The
+// siacoins claimed by spending siafunds of the related addresses count towards
+// the incoming value of the entry.
+func ledgerEntry(vt modules.ValuedTransaction) modules.WalletLedgerEntry {
+	var fees types.Currency
+	if !vt.ConfirmedOutgoingValue.IsZero() {
+		for _, output := range vt.Outputs {
+			if output.FundType == types.SpecifierMinerFee {
+				fees = fees.Add(output.Value)
+			}
+		}
+	}
+	incoming := vt.ConfirmedIncomingValue
+	for _, output := range vt.Outputs {
+		if output.FundType == types.SpecifierClaimOutput && output.WalletAddress {
+			incoming = incoming.Add(output.Value)
+		}
+	}
+	net := new(big.Int).Sub(incoming.Big(), vt.ConfirmedOutgoingValue.Big())
+	return modules.WalletLedgerEntry{
+		TransactionID:         vt.TransactionID,
+		ConfirmationHeight:    vt.ConfirmationHeight,
+		ConfirmationTimestamp: vt.ConfirmationTimestamp,
+		Category:              ledgerCategory(vt),
+
+		IncomingValue: incoming,
+		OutgoingValue: vt.ConfirmedOutgoingValue,
+		Fees:          fees,
+		NetValue:      net.String(),
+	}
+}
+
+// relatedTransaction returns a copy of a processed transaction in which only
+// the inputs and outputs of the related addresses are marked as wallet
+// addresses. The bool is false if none of the inputs and outputs are related.
+func relatedTransaction(pt modules.ProcessedTransaction, related func(types.UnlockHash) bool) (modules.ProcessedTransaction, bool) {
+	var relevant bool
+	inputs := make([]modules.ProcessedInput, len(pt.Inputs))
+	for i, input := range pt.Inputs {
+		input.WalletAddress = related(input.RelatedAddress)
+		relevant = relevant || input.WalletAddress
+		inputs[i] = input
+	}
+	outputs := make([]modules.ProcessedOutput, len(pt.Outputs))
+	for i, output := range pt.Outputs {
+		output.WalletAddress = output.FundType != types.SpecifierMinerFee && related(output.RelatedAddress)
+		relevant = relevant || output.WalletAddress
+		outputs[i] = output
+	}
+	pt.Inputs, pt.Outputs = inputs, outputs
+	return pt, relevant
+}
+
+// Ledger returns the confirmed transactions of the wallet selected by the
+// params as ledger entries, in the order they were confirmed.
+func (w *Wallet) Ledger(params modules.WalletLedgerParams) ([]modules.WalletLedgerEntry, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, modules.ErrWalletShutdown
+	}
+	defer w.tg.Done()
+
+	// Collect the addresses the ledger is exported for. If there are none,
+	// the ledger covers the whole wallet.
+	var addrs map[types.UnlockHash]struct{}
+	if params.Account != "" || len(params.Addresses) > 0 {
+		addrs = make(map[types.UnlockHash]struct{})
+	}
+	if params.Account != "" {
+		w.mu.Lock()
+		accountAddrs, err := dbGetAccountAddrs(w.dbTx, params.Account)
+		w.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		for addr := range accountAddrs {
+			addrs[addr] = struct{}{}
+		}
+	}
+	for _, addr := range params.Addresses {
+		addrs[addr] = struct{}{}
+	}
+	related := func(uh types.UnlockHash) bool {
+		_, exists := addrs[uh]
+		return exists
+	}
+
+	height, err := w.Height()
+	if err != nil {
+		return nil, err
+	}
+	endHeight := params.EndHeight
+	if endHeight == 0 {
+		endHeight = height
+	}
+	pts, err := w.Transactions(params.StartHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+	var selected []modules.ProcessedTransaction
+	for _, pt := range pts {
+		if pt.ConfirmationTimestamp < params.StartTime {
+			continue
+		} else if params.EndTime != 0 && pt.ConfirmationTimestamp > params.EndTime {
+			continue
+		}
+		if addrs != nil {
+			var relevant bool
+			if pt, relevant = relatedTransaction(pt, related); !relevant {
+				continue
+			}
+		}
+		selected = append(selected, pt)
+	}
+	vts, err := ComputeValuedTransactions(selected, height)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]modules.WalletLedgerEntry, 0, len(vts))
+	for _, vt := range vts {
+		entries = append(entries, ledgerEntry(vt))
+	}
+	return entries, nil
+}
@@ -0,0 +1,147 @@
+package wallet
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// TestLedger probes the Ledger method of the wallet.
+func TestLedger(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name(), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	if err := wt.waitForSync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ledger of the whole wallet only contains the miner payouts.
+	entries, err := wt.wallet.Ledger(modules.WalletLedgerParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("ledger is empty")
+	}
+	for _, e := range entries {
+		if e.Category != modules.LedgerCategoryMinerPayout {
+			t.Fatal("unexpected category", e.Category)
+		}
+	}
+
+	// Send coins to an address of an account and to an external address.
+	if err := wt.wallet.CreateAccount("payroll"); err != nil {
+		t.Fatal(err)
+	}
+	uc, err := wt.wallet.AccountAddress("payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fund := types.SiacoinPrecision.Mul64(100)
+	if _, err := wt.wallet.SendSiacoins(fund, uc.UnlockHash()); err != nil {
+		t.Fatal(err)
+	}
+	sendValue := types.SiacoinPrecision.Mul64(10)
+	if _, err := wt.wallet.SendSiacoinsFromAccount("payroll", sendValue, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.addBlockNoPayout(); err != nil {
+		t.Fatal(err)
+	}
+	height, err := wt.wallet.Height()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ledger of the last block contains the transfers. The transfer to
+	// the void has a negative net value that includes the fees.
+	entries, err = wt.wallet.Ledger(modules.WalletLedgerParams{StartHeight: height})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("ledger does not contain the transfers")
+	}
+	var fees types.Currency
+	for _, e := range entries {
+		if e.Category != modules.LedgerCategoryTransfer {
+			t.Fatal("unexpected category", e.Category)
+		}
+		if e.ConfirmationHeight != height {
+			t.Fatal("ledger contains a transaction outside of the height range")
+		}
+		fees = fees.Add(e.Fees)
+	}
+	if fees.IsZero() {
+		t.Fatal("ledger does not contain the fees")
+	}
+
+	// The ledger of the account nets out to the balance of the account.
+	entries, err = wt.wallet.Ledger(modules.WalletLedgerParams{Account: "payroll"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var incoming, outgoing types.Currency
+	for _, e := range entries {
+		incoming = incoming.Add(e.IncomingValue)
+		outgoing = outgoing.Add(e.OutgoingValue)
+	}
+	acc, err := wt.wallet.AccountBalance("payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !incoming.Sub(outgoing).Equals(acc.ConfirmedSiacoinBalance) {
+		t.Fatalf("ledger nets out to %v, account balance is %v", incoming.Sub(outgoing), acc.ConfirmedSiacoinBalance)
+	}
+
+	// An address without transactions has an empty ledger.
+	entries, err = wt.wallet.Ledger(modules.WalletLedgerParams{Addresses: []types.UnlockHash{{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("ledger of an unused address is not empty")
+	}
+}
+
+// TestLedgerSiafundClaim checks that the claim output of a siafund transaction
+// counts towards the incoming value of its ledger entry.
+func TestLedgerSiafundClaim(t *testing.T) {
+	uh := types.UnlockHash{1}
+	claim := types.NewCurrency64(100)
+	pt := modules.ProcessedTransaction{
+		Outputs: []modules.ProcessedOutput{{
+			FundType:       types.SpecifierClaimOutput,
+			WalletAddress:  true,
+			RelatedAddress: uh,
+			Value:          claim,
+		}, {
+			FundType:       types.SpecifierSiafundOutput,
+			WalletAddress:  true,
+			RelatedAddress: uh,
+			Value:          types.NewCurrency64(1),
+		}},
+	}
+	vts, err := ComputeValuedTransactions([]modules.ProcessedTransaction{pt}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := ledgerEntry(vts[0])
+	if entry.Category != modules.LedgerCategorySiafundClaim {
+		t.Fatal("wrong category", entry.Category)
+	}
+	if !entry.IncomingValue.Equals(claim) || entry.NetValue != claim.String() {
+		t.Fatalf("expected incoming and net value %v, got %v and %v", claim, entry.IncomingValue, entry.NetValue)
+	}
+
+	// The claim doesn't change the value of the transaction itself.
+	if !vts[0].ConfirmedIncomingValue.IsZero() {
+		t.Fatal("claim output was counted as incoming value of the transaction", vts[0].ConfirmedIncomingValue)
+	}
+}
@@ -113,7 +113,14 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) (txn
 		return nil, err
 	}
 	defer w.tg.Done()
+	return w.managedSendSiacoins("", amount, dest)
+}
 
+// managedSendSiacoins creates a transaction sending 'amount' to 'dest' that is
+// funded by the outputs of 'account', or by all outputs of the wallet if
+// 'account' is empty. The transaction is submitted to the transaction pool and
+// is also returned.
+func (w *Wallet) managedSendSiacoins(account string, amount types.Currency, dest types.UnlockHash) (txns []types.Transaction, err error) {
 	// Check if consensus is synced
 	if !w.cs.Synced() || w.deps.Disrupt("UnsyncedConsensus") {
 		return nil, errors.New("cannot send siacoin until fully synced")
@@ -134,10 +141,10 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) (txn
 		UnlockHash: dest,
 	}
 
-	txnBuilder, err := w.StartTransaction()
-	if err != nil {
-		return nil, err
-	}
+	w.mu.Lock()
+	txnBuilder := w.registerTransaction(types.Transaction{}, nil)
+	w.mu.Unlock()
+	txnBuilder.account = account
 	defer func() {
 		if err != nil {
 			txnBuilder.Drop()
@@ -163,6 +170,13 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) (txn
 		w.log.Println("Attempt to send coins has failed - transaction pool rejected transaction:", err)
 		return nil, build.ExtendErr("unable to get transaction accepted", err)
 	}
+	// The change of the transaction belongs to the account now that the
+	// transaction was accepted.
+	if account != "" {
+		if err := w.managedAssignAccountAddrs(account, txnBuilder.accountAddrs); err != nil {
+			w.log.Println("WARN: unable to add the change addresses to account", account, err)
+		}
+	}
 	w.log.Println("Submitted a siacoin transfer transaction set for value", amount.HumanString(), "with fees", tpoolFee.HumanString(), "IDs:")
 	for _, txn := range txnSet {
 		w.log.Println("\t", txn.ID())
@@ -69,6 +69,16 @@ func (s *seedScanner) numKeys() uint64 {
 	return uint64(len(s.keys))
 }
 
+// RelevantAddresses returns the addresses of the generated keys, so that the
+// seed can also be scanned using a light consensus set.
+func (s *seedScanner) RelevantAddresses() []types.UnlockHash {
+	addrs := make([]types.UnlockHash, 0, len(s.keys))
+	for uh := range s.keys {
+		addrs = append(addrs, uh)
+	}
+	return addrs
+}
+
 // generateKeys generates n additional keys from the seedScanner's seed.
 func (s *seedScanner) generateKeys(n uint64) {
 	initialProgress := s.numKeys()
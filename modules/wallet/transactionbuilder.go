@@ -44,6 +44,14 @@ type transactionBuilder struct {
 	siafundInputs         []int
 	transactionSignatures []int
 
+	// 'account' restricts the funding of the transaction to the outputs of a
+	// wallet account. If it is empty, all outputs of the wallet are used.
+	// 'accountAddrs' are the addresses of the outputs of the parent
+	// transactions, they are added to the account once the transaction was
+	// accepted by the transaction pool.
+	account      string
+	accountAddrs []types.UnlockHash
+
 	wallet *Wallet
 }
 
@@ -131,9 +139,29 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 		return err
 	}
 
+	// If the transaction is funded by an account, only the outputs of the
+	// account's addresses may be used.
+	var accountAddrs map[types.UnlockHash]struct{}
+	if tb.account != "" {
+		accountAddrs, err = dbGetAccountAddrs(tb.wallet.dbTx, tb.account)
+		if err != nil {
+			return err
+		}
+	}
+	usable := func(uh types.UnlockHash) bool {
+		if accountAddrs == nil {
+			return true
+		}
+		_, exists := accountAddrs[uh]
+		return exists
+	}
+
 	// Collect a value-sorted set of siacoin outputs.
 	var so sortedOutputs
 	err = dbForEachSiacoinOutput(tb.wallet.dbTx, func(scoid types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if !usable(sco.UnlockHash) {
+			return
+		}
 		so.ids = append(so.ids, scoid)
 		so.outputs = append(so.outputs, sco)
 	})
@@ -145,7 +173,7 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 		for i, sco := range upt.Transaction.SiacoinOutputs {
 			// Determine if the output belongs to the wallet.
 			_, exists := tb.wallet.keys[sco.UnlockHash]
-			if !exists {
+			if !exists || !usable(sco.UnlockHash) {
 				continue
 			}
 			so.ids = append(so.ids, upt.Transaction.SiacoinOutputID(uint64(i)))
@@ -223,6 +251,14 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 		parentTxn.SiacoinOutputs = append(parentTxn.SiacoinOutputs, refundOutput)
 	}
 
+	// The outputs of the parent transaction belong to the account that funds
+	// it, so that the change stays in the account.
+	if tb.account != "" {
+		for _, sco := range parentTxn.SiacoinOutputs {
+			tb.accountAddrs = append(tb.accountAddrs, sco.UnlockHash)
+		}
+	}
+
 	// Sign all of the inputs to the parent transaction.
 	for _, sci := range parentTxn.SiacoinInputs {
 		addSignatures(&parentTxn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), tb.wallet.keys[sci.UnlockConditions.UnlockHash()], consensusHeight)
@@ -75,6 +75,25 @@ func (w *Wallet) isWalletAddress(uh types.UnlockHash) bool {
 	return spendable || watchonly
 }
 
+// RelevantAddresses returns the addresses whose transactions a light
+// consensus set needs to provide to the wallet, which are the addresses of the
+// spendable keys, the lookahead and the watched addresses.
+func (w *Wallet) RelevantAddresses() []types.UnlockHash {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	addrs := make([]types.UnlockHash, 0, len(w.keys)+len(w.lookahead)+len(w.watchedAddrs))
+	for uh := range w.keys {
+		addrs = append(addrs, uh)
+	}
+	for uh := range w.lookahead {
+		addrs = append(addrs, uh)
+	}
+	for uh := range w.watchedAddrs {
+		addrs = append(addrs, uh)
+	}
+	return addrs
+}
+
 // updateLookahead uses a consensus change to update the seed progress if one of the outputs
 // contains an unlock hash of the lookahead set. Returns true if a blockchain rescan is required
 func (w *Wallet) updateLookahead(tx *bolt.Tx, cc modules.ConsensusChange) (bool, error) {
@@ -158,8 +177,10 @@ func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) err
 
 // revertHistory reverts any transaction history that was destroyed by reverted
 // blocks in the consensus change.
-func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
-	for _, block := range reverted {
+func (w *Wallet) revertHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
+	for i, block := range cc.RevertedBlocks {
+		blockID := cc.RevertedBlockID(i)
+
 		// Remove any transactions that have been reverted.
 		for i := len(block.Transactions) - 1; i >= 0; i-- {
 			// If the transaction is relevant to the wallet, it will be the
@@ -186,8 +207,8 @@ func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
 			if err != nil {
 				break // bucket is empty
 			}
-			if types.TransactionID(block.ID()) == pt.TransactionID {
-				w.log.Println("Miner payout has been reverted due to a reorg:", block.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
+			if types.TransactionID(blockID) == pt.TransactionID {
+				w.log.Println("Miner payout has been reverted due to a reorg:", blockID.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
 				if err := dbDeleteLastProcessedTransaction(tx); err != nil {
 					w.log.Severe("Could not revert transaction:", err)
 					return err
@@ -197,7 +218,7 @@ func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
 		}
 
 		// decrement the consensus height
-		if block.ID() != types.GenesisID {
+		if blockID != types.GenesisID {
 			consensusHeight, err := dbGetConsensusHeight(tx)
 			if err != nil {
 				return err
@@ -239,7 +260,7 @@ func computeSpentSiafundOutputSet(diffs []modules.SiafundOutputDiff) spentSiafun
 // computeProcessedTransactionsFromBlock searches all the miner payouts and
 // transactions in a block and computes a ProcessedTransaction slice containing
 // all of the transactions processed for the given block.
-func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.Block, spentSiacoinOutputs spentSiacoinOutputSet, spentSiafundOutputs spentSiafundOutputSet, consensusHeight types.BlockHeight) []modules.ProcessedTransaction {
+func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.Block, blockID types.BlockID, spentSiacoinOutputs spentSiacoinOutputSet, spentSiafundOutputs spentSiafundOutputSet, consensusHeight types.BlockHeight) []modules.ProcessedTransaction {
 	var pts []modules.ProcessedTransaction
 
 	// Find ProcessedTransactions from miner payouts.
@@ -248,18 +269,18 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 		relevant = relevant || w.isWalletAddress(mp.UnlockHash)
 	}
 	if relevant {
-		w.log.Println("Wallet has received new miner payouts:", block.ID())
+		w.log.Println("Wallet has received new miner payouts:", blockID)
 		// Apply the miner payout transaction if applicable.
 		minerPT := modules.ProcessedTransaction{
 			Transaction:           types.Transaction{},
-			TransactionID:         types.TransactionID(block.ID()),
+			TransactionID:         types.TransactionID(blockID),
 			ConfirmationHeight:    consensusHeight,
 			ConfirmationTimestamp: block.Timestamp,
 		}
 		for i, mp := range block.MinerPayouts {
-			w.log.Println("\tminer payout:", block.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
+			w.log.Println("\tminer payout:", blockID.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
 			minerPT.Outputs = append(minerPT.Outputs, modules.ProcessedOutput{
-				ID:             types.OutputID(block.MinerPayoutID(uint64(i))),
+				ID:             types.OutputID(blockID.MinerPayoutID(uint64(i))),
 				FundType:       types.SpecifierMinerPayout,
 				MaturityHeight: consensusHeight + types.MaturityDelay,
 				WalletAddress:  w.isWalletAddress(mp.UnlockHash),
@@ -486,13 +507,14 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 	spentSiacoinOutputs := computeSpentSiacoinOutputSet(cc.SiacoinOutputDiffs)
 	spentSiafundOutputs := computeSpentSiafundOutputSet(cc.SiafundOutputDiffs)
 
-	for _, block := range cc.AppliedBlocks {
+	for i, block := range cc.AppliedBlocks {
+		blockID := cc.AppliedBlockID(i)
 		consensusHeight, err := dbGetConsensusHeight(tx)
 		if err != nil {
 			return errors.AddContext(err, "failed to consensus height")
 		}
 		// Increment the consensus height.
-		if block.ID() != types.GenesisID {
+		if blockID != types.GenesisID {
 			consensusHeight++
 			err = dbPutConsensusHeight(tx, consensusHeight)
 			if err != nil {
@@ -500,7 +522,7 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 			}
 		}
 
-		pts := w.computeProcessedTransactionsFromBlock(tx, block, spentSiacoinOutputs, spentSiafundOutputs, consensusHeight)
+		pts := w.computeProcessedTransactionsFromBlock(tx, block, blockID, spentSiacoinOutputs, spentSiafundOutputs, consensusHeight)
 		for _, pt := range pts {
 			err := dbAppendProcessedTransaction(tx, pt)
 			if err != nil {
@@ -533,7 +555,7 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 		w.log.Severe("ERROR: failed to update confirmed set:", err)
 		w.dbRollback = true
 	}
-	if err := w.revertHistory(w.dbTx, cc.RevertedBlocks); err != nil {
+	if err := w.revertHistory(w.dbTx, cc); err != nil {
 		w.log.Severe("ERROR: failed to revert consensus change:", err)
 		w.dbRollback = true
 	}
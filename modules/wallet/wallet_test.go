@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 	"time"
@@ -138,6 +139,17 @@ func (wt *walletTester) closeWt() error {
 	return build.JoinErrors(errs, "; ")
 }
 
+// waitForSync waits until the consensus set of the wallet tester is synced.
+// The wallet refuses to send coins before that.
+func (wt *walletTester) waitForSync() error {
+	return build.Retry(100, 100*time.Millisecond, func() error {
+		if !wt.cs.Synced() {
+			return errors.New("consensus set isn't synced")
+		}
+		return nil
+	})
+}
+
 // TestNilInputs tries starting the wallet using nil inputs.
 func TestNilInputs(t *testing.T) {
 	if testing.Short() {
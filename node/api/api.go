@@ -101,6 +101,7 @@ type API struct {
 	downloadMu sync.Mutex
 	downloads  map[string]func()
 	router     http.Handler
+	routes     []routeSpec
 	routerMu   sync.RWMutex
 
 	requiredUserAgent string
@@ -2,10 +2,14 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
 
@@ -25,6 +29,65 @@ type Client struct {
 	// UserAgent must match the User-Agent required by the siad server. If not
 	// set, it defaults to "Sia-Agent".
 	UserAgent string
+
+	// TLSConfig is used to connect to a siad server that serves the API over
+	// TLS. If it is nil, plain HTTP is used.
+	TLSConfig *tls.Config
+
+	// UnixSocket is the path of the Unix socket of the siad server. If it is
+	// set, requests are sent over the socket instead of to Address and
+	// TLSConfig is ignored.
+	UnixSocket string
+}
+
+// NewTLSConfig creates a TLS config for connecting to a siad server. caFile
+// is the certificate authority of the server's certificate and may be empty to
+// use the system's roots. certFile and keyFile are the client certificate and
+// key, they are only required if the server requires client certificates.
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to read CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA file does not contain any certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to load client certificate")
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// httpClient returns the http.Client used to send requests to siad.
+func (c *Client) httpClient() *http.Client {
+	if c.TLSConfig == nil && c.UnixSocket == "" {
+		return http.DefaultClient
+	}
+	transport := &http.Transport{
+		// The transport is not reused between requests.
+		DisableKeepAlives: true,
+	}
+	if c.UnixSocket == "" {
+		transport.TLSClientConfig = c.TLSConfig
+	} else {
+		socket := c.UnixSocket
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	}
+	return &http.Client{Transport: transport}
 }
 
 // New creates a new Client using the provided address.
@@ -37,7 +100,15 @@ func New(address string) *Client {
 // NewRequest constructs a request to the siad HTTP API, setting the correct
 // User-Agent and Basic Auth. The resource path must begin with /.
 func (c *Client) NewRequest(method, resource string, body io.Reader) (*http.Request, error) {
-	url := "http://" + c.Address + resource
+	scheme, address := "http://", c.Address
+	if c.UnixSocket != "" {
+		// The address is only used as the Host header of requests sent over
+		// the socket.
+		address = "localhost"
+	} else if c.TLSConfig != nil {
+		scheme = "https://"
+	}
+	url := scheme + address + resource
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
@@ -77,7 +148,7 @@ func (c *Client) getRawResponse(resource string) (http.Header, []byte, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, nil, errors.AddContext(err, "request failed")
 	}
@@ -110,7 +181,7 @@ func (c *Client) getRawPartialResponse(resource string, from, to uint64) ([]byte
 	}
 	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", from, to-1))
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, errors.AddContext(err, "request failed")
 	}
@@ -133,6 +204,32 @@ func (c *Client) getRawPartialResponse(resource string, from, to uint64) ([]byte
 	return ioutil.ReadAll(res.Body)
 }
 
+// getReaderResponse requests the specified resource and returns the body of
+// the response without reading it. The caller must close the body.
+func (c *Client) getReaderResponse(resource string) (io.ReadCloser, error) {
+	req, err := c.NewRequest("GET", resource, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, errors.AddContext(err, "request failed")
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		drainAndClose(res.Body)
+		return nil, errors.New("API call not recognized: " + resource)
+	}
+
+	// If the status code is not 2xx, decode and return the accompanying
+	// api.Error.
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer drainAndClose(res.Body)
+		return nil, readAPIError(res.Body)
+	}
+	return res.Body, nil
+}
+
 // get requests the specified resource. The response, if provided, will be
 // decoded into obj. The resource path must begin with /.
 func (c *Client) get(resource string, obj interface{}) error {
@@ -164,7 +261,7 @@ func (c *Client) postRawResponse(resource string, body io.Reader) ([]byte, error
 	}
 	// TODO: is this necessary?
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, errors.AddContext(err, "request failed")
 	}
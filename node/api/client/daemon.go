@@ -3,6 +3,8 @@ package client
 import (
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"gitlab.com/NebulousLabs/Sia/node/api"
 )
@@ -24,6 +26,13 @@ func (c *Client) DaemonVersionGet() (dvg api.DaemonVersionGet, err error) {
 	return
 }
 
+// DaemonOpenAPIGet requests the OpenAPI document of the API from the
+// /daemon/openapi.json endpoint.
+func (c *Client) DaemonOpenAPIGet() (doc api.OpenAPIDocument, err error) {
+	err = c.get("/daemon/openapi.json", &doc)
+	return
+}
+
 // DaemonSettingsGet requests the /daemon/settings api resource.
 func (c *Client) DaemonSettingsGet() (dsg api.DaemonSettingsGet, err error) {
 	err = c.get("/daemon/settings", &dsg)
@@ -47,3 +56,30 @@ func (c *Client) DaemonUpdatePost() (err error) {
 	err = c.post("/daemon/update", "", nil)
 	return
 }
+
+// DaemonTokensGet lists the API tokens using the /daemon/tokens endpoint.
+func (c *Client) DaemonTokensGet() (dtg api.DaemonTokensGET, err error) {
+	err = c.get("/daemon/tokens", &dtg)
+	return
+}
+
+// DaemonTokensPost creates a new API token with the provided scopes using the
+// /daemon/tokens endpoint. A zero lifetime creates a token that never
+// expires.
+func (c *Client) DaemonTokensPost(name string, scopes []string, lifetime time.Duration) (dtp api.DaemonTokensPOST, err error) {
+	values := url.Values{}
+	values.Set("name", name)
+	values.Set("scopes", strings.Join(scopes, ","))
+	if lifetime != 0 {
+		values.Set("expires", lifetime.String())
+	}
+	err = c.post("/daemon/tokens", values.Encode(), &dtp)
+	return
+}
+
+// DaemonTokensRevokePost revokes an API token using the
+// /daemon/tokens/:id/revoke endpoint.
+func (c *Client) DaemonTokensRevokePost(id string) (err error) {
+	err = c.post("/daemon/tokens/"+id+"/revoke", "", nil)
+	return
+}
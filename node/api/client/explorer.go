@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+
+	"gitlab.com/NebulousLabs/Sia/node/api"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// ExplorerGet requests the /explorer api resource
+func (c *Client) ExplorerGet() (eg api.ExplorerGET, err error) {
+	err = c.get("/explorer", &eg)
+	return
+}
+
+// ExplorerAddressGet requests the /explorer/addresses/:address api resource
+func (c *Client) ExplorerAddressGet(addr types.UnlockHash) (eag api.ExplorerAddressGET, err error) {
+	err = c.get("/explorer/addresses/"+addr.String(), &eag)
+	return
+}
+
+// ExplorerAddressOutputsGet requests the /explorer/addresses/:address/outputs
+// api resource
+func (c *Client) ExplorerAddressOutputsGet(addr types.UnlockHash) (eaog api.ExplorerAddressOutputsGET, err error) {
+	err = c.get("/explorer/addresses/"+addr.String()+"/outputs", &eaog)
+	return
+}
+
+// ExplorerMarketGet requests the /explorer/market api resource
+func (c *Client) ExplorerMarketGet() (emg api.ExplorerMarketGET, err error) {
+	err = c.get("/explorer/market", &emg)
+	return
+}
+
+// ExplorerMarketHistoryGet requests the /explorer/market api resource with a
+// range of block heights.
+func (c *Client) ExplorerMarketHistoryGet(start, end, step types.BlockHeight) (emg api.ExplorerMarketGET, err error) {
+	err = c.get(fmt.Sprintf("/explorer/market?start=%d&end=%d&step=%d", start, end, step), &emg)
+	return
+}
+
+// ExplorerRichListGet requests the /explorer/richlist api resource. listType
+// is either "siacoins" or "siafunds".
+func (c *Client) ExplorerRichListGet(listType string, offset, limit uint64) (erlg api.ExplorerRichListGET, err error) {
+	err = c.get(fmt.Sprintf("/explorer/richlist?type=%s&offset=%d&limit=%d", listType, offset, limit), &erlg)
+	return
+}
@@ -1,7 +1,13 @@
 package client
 
 import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
 	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/node/api"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
@@ -12,6 +18,29 @@ func (c *Client) MinerGet() (mg api.MinerGET, err error) {
 	return
 }
 
+// MinerPost uses the /miner endpoint to change the payout addresses and the
+// coinbase data of the miner.
+func (c *Client) MinerPost(payouts []modules.MinerPayout, coinbaseData string) (err error) {
+	pairs := make([]string, 0, len(payouts))
+	for _, payout := range payouts {
+		pairs = append(pairs, fmt.Sprintf("%v:%v", payout.UnlockHash, payout.Percentage))
+	}
+	values := url.Values{}
+	values.Set("payouts", strings.Join(pairs, ","))
+	values.Set("coinbasedata", coinbaseData)
+	err = c.post("/miner", values.Encode(), nil)
+	return
+}
+
+// MinerModifySettingPost uses the /miner endpoint to change a single setting
+// of the miner.
+func (c *Client) MinerModifySettingPost(param, value string) (err error) {
+	values := url.Values{}
+	values.Set(param, value)
+	err = c.post("/miner", values.Encode(), nil)
+	return
+}
+
 // MinerHeaderGet uses the /miner/header endpoint to get a header for work.
 func (c *Client) MinerHeaderGet() (target types.Target, bh types.BlockHeader, err error) {
 	_, targetAndHeader, err := c.getRawResponse("/miner/header")
@@ -40,3 +69,26 @@ func (c *Client) MinerStopGet() (err error) {
 	err = c.get("/miner/stop", nil)
 	return
 }
+
+// MinerStratumGet requests the /miner/stratum endpoint's resources.
+func (c *Client) MinerStratumGet() (msg api.MinerStratumGET, err error) {
+	err = c.get("/miner/stratum", &msg)
+	return
+}
+
+// MinerStratumStartPost uses the /miner/stratum/start endpoint to start the
+// stratum server on the given address.
+func (c *Client) MinerStratumStartPost(address string, difficulty uint64) (err error) {
+	values := url.Values{}
+	values.Set("address", address)
+	values.Set("difficulty", strconv.FormatUint(difficulty, 10))
+	err = c.post("/miner/stratum/start", values.Encode(), nil)
+	return
+}
+
+// MinerStratumStopPost uses the /miner/stratum/stop endpoint to stop the
+// stratum server.
+func (c *Client) MinerStratumStopPost() (err error) {
+	err = c.post("/miner/stratum/stop", "", nil)
+	return
+}
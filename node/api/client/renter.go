@@ -1,8 +1,10 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -79,6 +81,45 @@ func (a *AllowanceRequestPost) WithExpectedRedundancy(expectedRedundancy float64
 	return a
 }
 
+// WithMaxRPCPrice adds the maxrpcprice field to the request.
+func (a *AllowanceRequestPost) WithMaxRPCPrice(price types.Currency) *AllowanceRequestPost {
+	a.values.Set("maxrpcprice", price.String())
+	return a
+}
+
+// WithMaxContractPrice adds the maxcontractprice field to the request.
+func (a *AllowanceRequestPost) WithMaxContractPrice(price types.Currency) *AllowanceRequestPost {
+	a.values.Set("maxcontractprice", price.String())
+	return a
+}
+
+// WithMaxDownloadBandwidthPrice adds the maxdownloadbandwidthprice field to
+// the request.
+func (a *AllowanceRequestPost) WithMaxDownloadBandwidthPrice(price types.Currency) *AllowanceRequestPost {
+	a.values.Set("maxdownloadbandwidthprice", price.String())
+	return a
+}
+
+// WithMaxSectorAccessPrice adds the maxsectoraccessprice field to the
+// request.
+func (a *AllowanceRequestPost) WithMaxSectorAccessPrice(price types.Currency) *AllowanceRequestPost {
+	a.values.Set("maxsectoraccessprice", price.String())
+	return a
+}
+
+// WithMaxStoragePrice adds the maxstorageprice field to the request.
+func (a *AllowanceRequestPost) WithMaxStoragePrice(price types.Currency) *AllowanceRequestPost {
+	a.values.Set("maxstorageprice", price.String())
+	return a
+}
+
+// WithMaxUploadBandwidthPrice adds the maxuploadbandwidthprice field to the
+// request.
+func (a *AllowanceRequestPost) WithMaxUploadBandwidthPrice(price types.Currency) *AllowanceRequestPost {
+	a.values.Set("maxuploadbandwidthprice", price.String())
+	return a
+}
+
 // Send finalizes and sends the request.
 func (a *AllowanceRequestPost) Send() (err error) {
 	if a.sent {
@@ -112,6 +153,22 @@ func (c *Client) RenterContractCancelPost(id types.FileContractID) (err error) {
 	return
 }
 
+// RenterDrainGet uses the /renter/drain endpoint to get the progress of the
+// drains of the renter.
+func (c *Client) RenterDrainGet() (rd api.RenterDrainsGET, err error) {
+	err = c.get("/renter/drain", &rd)
+	return
+}
+
+// RenterDrainPost uses the /renter/drain endpoint to migrate all pieces away
+// from a host and cancel the contract with it afterwards.
+func (c *Client) RenterDrainPost(hostKey types.SiaPublicKey) (err error) {
+	values := url.Values{}
+	values.Set("hostkey", hostKey.String())
+	err = c.post("/renter/drain", values.Encode(), nil)
+	return
+}
+
 // RenterAllContractsGet requests the /renter/contracts resource with all
 // options set to true
 func (c *Client) RenterAllContractsGet() (rc api.RenterContracts, err error) {
@@ -232,6 +289,83 @@ func (c *Client) RenterRecoverBackupPost(name string) (err error) {
 	return
 }
 
+// RenterSyncDirsGet lists the local directories that are synced by the
+// renter.
+func (c *Client) RenterSyncDirsGet() (rsd api.RenterSyncDirsGET, err error) {
+	err = c.get("/renter/syncdirs", &rsd)
+	return
+}
+
+// RenterSyncDirsAddPost links a local directory to a siapath, so that the
+// renter keeps the siapath in sync with the directory.
+func (c *Client) RenterSyncDirsAddPost(localPath string, siaPath modules.SiaPath) (err error) {
+	values := url.Values{}
+	values.Set("localpath", localPath)
+	values.Set("siapath", siaPath.String())
+	err = c.post("/renter/syncdirs/add", values.Encode(), nil)
+	return
+}
+
+// RenterSyncDirsRemovePost stops syncing the local directory that is linked
+// to the siapath.
+func (c *Client) RenterSyncDirsRemovePost(siaPath modules.SiaPath) (err error) {
+	values := url.Values{}
+	values.Set("siapath", siaPath.String())
+	err = c.post("/renter/syncdirs/remove", values.Encode(), nil)
+	return
+}
+
+// shareSiaPathsValue joins siapaths into the comma-separated form expected by
+// the share endpoints.
+func shareSiaPathsValue(siaPaths []modules.SiaPath) string {
+	paths := make([]string, 0, len(siaPaths))
+	for _, siaPath := range siaPaths {
+		paths = append(paths, siaPath.String())
+	}
+	return strings.Join(paths, ",")
+}
+
+// RenterShareGet writes the siafiles at the provided siapaths to a .sia file
+// at dst. If password is not empty, the share is encrypted with it.
+func (c *Client) RenterShareGet(siaPaths []modules.SiaPath, dst, password string) (err error) {
+	values := url.Values{}
+	values.Set("siapaths", shareSiaPathsValue(siaPaths))
+	values.Set("destination", dst)
+	values.Set("password", password)
+	err = c.get("/renter/share?"+values.Encode(), nil)
+	return
+}
+
+// RenterShareASCIIGet returns the siafiles at the provided siapaths as a
+// base64 encoded share.
+func (c *Client) RenterShareASCIIGet(siaPaths []modules.SiaPath, password string) (rsa api.RenterShareASCII, err error) {
+	values := url.Values{}
+	values.Set("siapaths", shareSiaPathsValue(siaPaths))
+	values.Set("password", password)
+	err = c.get("/renter/shareascii?"+values.Encode(), &rsa)
+	return
+}
+
+// RenterLoadPost loads the siafiles of the .sia file at source into the
+// renter.
+func (c *Client) RenterLoadPost(source, password string) (rl api.RenterLoad, err error) {
+	values := url.Values{}
+	values.Set("source", source)
+	values.Set("password", password)
+	err = c.post("/renter/load", values.Encode(), &rl)
+	return
+}
+
+// RenterLoadASCIIPost loads the siafiles of a base64 encoded share into the
+// renter.
+func (c *Client) RenterLoadASCIIPost(asciisia, password string) (rl api.RenterLoad, err error) {
+	values := url.Values{}
+	values.Set("asciisia", asciisia)
+	values.Set("password", password)
+	err = c.post("/renter/loadascii", values.Encode(), &rl)
+	return
+}
+
 // RenterCreateLocalBackupPost creates a local backup of the SiaFiles of the
 // renter.
 //
@@ -318,6 +452,16 @@ func (c *Client) RenterDownloadHTTPResponseGet(siaPath modules.SiaPath, offset,
 	return
 }
 
+// RenterDownloadArchiveGet uses the /renter/download endpoint to download the
+// directory at siaPath as an archive of the given format. The caller must
+// close the returned reader.
+func (c *Client) RenterDownloadArchiveGet(siaPath modules.SiaPath, format string) (io.ReadCloser, error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("format", format)
+	return c.getReaderResponse(fmt.Sprintf("/renter/download/%s?%s", sp, values.Encode()))
+}
+
 // RenterFileGet uses the /renter/file/:siapath endpoint to query a file.
 func (c *Client) RenterFileGet(siaPath modules.SiaPath) (rf api.RenterFile, err error) {
 	sp := escapeSiaPath(siaPath)
@@ -331,6 +475,24 @@ func (c *Client) RenterFilesGet(cached bool) (rf api.RenterFiles, err error) {
 	return
 }
 
+// RenterFindGet requests the /renter/find resource to find the files with the
+// given tag whose user metadata contains all pairs of query.
+func (c *Client) RenterFindGet(tag string, query map[string]string) (rf api.RenterFiles, err error) {
+	values := url.Values{}
+	if tag != "" {
+		values.Set("tag", tag)
+	}
+	if len(query) > 0 {
+		md, err := json.Marshal(query)
+		if err != nil {
+			return api.RenterFiles{}, err
+		}
+		values.Set("usermetadata", string(md))
+	}
+	err = c.get("/renter/find?"+values.Encode(), &rf)
+	return
+}
+
 // RenterGet requests the /renter resource.
 func (c *Client) RenterGet() (rg api.RenterGET, err error) {
 	err = c.get("/renter", &rg)
@@ -348,6 +510,12 @@ func (c *Client) RenterPostAllowance(allowance modules.Allowance) error {
 	a = a.WithExpectedUpload(allowance.ExpectedUpload)
 	a = a.WithExpectedDownload(allowance.ExpectedDownload)
 	a = a.WithExpectedRedundancy(allowance.ExpectedRedundancy)
+	a = a.WithMaxRPCPrice(allowance.MaxRPCPrice)
+	a = a.WithMaxContractPrice(allowance.MaxContractPrice)
+	a = a.WithMaxDownloadBandwidthPrice(allowance.MaxDownloadBandwidthPrice)
+	a = a.WithMaxSectorAccessPrice(allowance.MaxSectorAccessPrice)
+	a = a.WithMaxStoragePrice(allowance.MaxStoragePrice)
+	a = a.WithMaxUploadBandwidthPrice(allowance.MaxUploadBandwidthPrice)
 	return a.Send()
 }
 
@@ -375,6 +543,15 @@ func (c *Client) RenterPostRateLimit(readBPS, writeBPS int64) (err error) {
 	return
 }
 
+// RenterPostChunkCacheSize uses the /renter endpoint to change the maximum
+// size of the renter's chunk cache.
+func (c *Client) RenterPostChunkCacheSize(size uint64) (err error) {
+	values := url.Values{}
+	values.Set("chunkcachesize", strconv.FormatUint(size, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterRenamePost uses the /renter/rename/:siapath endpoint to rename a file.
 func (c *Client) RenterRenamePost(siaPathOld, siaPathNew modules.SiaPath) (err error) {
 	spo := escapeSiaPath(siaPathOld)
@@ -384,6 +561,105 @@ func (c *Client) RenterRenamePost(siaPathOld, siaPathNew modules.SiaPath) (err e
 	return
 }
 
+// RenterCopyPost uses the /renter/copy endpoint to copy a file without
+// reuploading it.
+func (c *Client) RenterCopyPost(siaPath, newSiaPath modules.SiaPath) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("newsiapath", newSiaPath.String())
+	err = c.post(fmt.Sprintf("/renter/copy/%s", sp), values.Encode(), nil)
+	return
+}
+
+// RenterVersionsGet uses the /renter/versions endpoint to list the old
+// versions of a file.
+func (c *Client) RenterVersionsGet(siaPath modules.SiaPath) (rv api.RenterFileVersions, err error) {
+	sp := escapeSiaPath(siaPath)
+	err = c.get(fmt.Sprintf("/renter/versions/%s", sp), &rv)
+	return
+}
+
+// RenterRestoreVersionPost uses the /renter/restoreversion endpoint to
+// restore an old version of a file.
+func (c *Client) RenterRestoreVersionPost(siaPath modules.SiaPath, id uint64) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("id", fmt.Sprint(id))
+	err = c.post(fmt.Sprintf("/renter/restoreversion/%s", sp), values.Encode(), nil)
+	return
+}
+
+// RenterVersionPoliciesGet uses the /renter/versionpolicies endpoint to list
+// the version policies of the renter.
+func (c *Client) RenterVersionPoliciesGet() (rvp api.RenterVersionPoliciesGET, err error) {
+	err = c.get("/renter/versionpolicies", &rvp)
+	return
+}
+
+// RenterSetVersionPolicyPost uses the /renter/versionpolicy endpoint to set
+// the version policy of a directory.
+func (c *Client) RenterSetVersionPolicyPost(siaPath modules.SiaPath, policy modules.VersionPolicy) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("maxversions", fmt.Sprint(policy.MaxVersions))
+	values.Set("maxage", policy.MaxAge.String())
+	err = c.post(fmt.Sprintf("/renter/versionpolicy/%s", sp), values.Encode(), nil)
+	return
+}
+
+// RenterRemoveVersionPolicyPost uses the /renter/versionpolicy endpoint to
+// remove the version policy of a directory.
+func (c *Client) RenterRemoveVersionPolicyPost(siaPath modules.SiaPath) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("remove", "true")
+	err = c.post(fmt.Sprintf("/renter/versionpolicy/%s", sp), values.Encode(), nil)
+	return
+}
+
+// RenterTrashGet uses the /renter/trash endpoint to list the entries of the
+// trash.
+func (c *Client) RenterTrashGet() (rt api.RenterTrashGET, err error) {
+	err = c.get("/renter/trash", &rt)
+	return
+}
+
+// RenterTrashRestorePost uses the /renter/trash/restore endpoint to restore
+// an entry of the trash.
+func (c *Client) RenterTrashRestorePost(id uint64) (err error) {
+	values := url.Values{}
+	values.Set("id", fmt.Sprint(id))
+	err = c.post("/renter/trash/restore", values.Encode(), nil)
+	return
+}
+
+// RenterTrashPurgePost uses the /renter/trash/purge endpoint to purge an entry
+// of the trash.
+func (c *Client) RenterTrashPurgePost(id uint64) (err error) {
+	values := url.Values{}
+	values.Set("id", fmt.Sprint(id))
+	err = c.post("/renter/trash/purge", values.Encode(), nil)
+	return
+}
+
+// RenterTrashPurgeAllPost uses the /renter/trash/purge endpoint to purge all
+// entries of the trash.
+func (c *Client) RenterTrashPurgeAllPost() (err error) {
+	values := url.Values{}
+	values.Set("all", "true")
+	err = c.post("/renter/trash/purge", values.Encode(), nil)
+	return
+}
+
+// RenterTrashRetentionPost uses the /renter/trash/retention endpoint to set
+// how long deleted files and directories are kept in the trash.
+func (c *Client) RenterTrashRetentionPost(retention time.Duration) (err error) {
+	values := url.Values{}
+	values.Set("retention", retention.String())
+	err = c.post("/renter/trash/retention", values.Encode(), nil)
+	return
+}
+
 // RenterSetStreamCacheSizePost uses the /renter endpoint to change the renter's
 // streamCacheSize for streaming
 func (c *Client) RenterSetStreamCacheSizePost(cacheSize uint64) (err error) {
@@ -410,6 +686,13 @@ func (c *Client) RenterStreamGet(siaPath modules.SiaPath) (resp []byte, err erro
 	return
 }
 
+// RenterStreamHeaderGet uses the /renter/stream endpoint to download a file
+// as a stream and also returns the header of the response.
+func (c *Client) RenterStreamHeaderGet(siaPath modules.SiaPath) (http.Header, []byte, error) {
+	sp := escapeSiaPath(siaPath)
+	return c.getRawResponse(fmt.Sprintf("/renter/stream/%s", sp))
+}
+
 // RenterStreamPartialGet uses the /renter/stream endpoint to download a part
 // of data as a stream.
 func (c *Client) RenterStreamPartialGet(siaPath modules.SiaPath, start, end uint64) (resp []byte, err error) {
@@ -438,6 +721,20 @@ func (c *Client) RenterSetFileStuckPost(siaPath modules.SiaPath, stuck bool) (er
 	return
 }
 
+// RenterSetFileUserMetadataPost sets the custom user metadata of a file,
+// replacing its previous user metadata.
+func (c *Client) RenterSetFileUserMetadataPost(siaPath modules.SiaPath, userMetadata map[string]string) (err error) {
+	md, err := json.Marshal(userMetadata)
+	if err != nil {
+		return err
+	}
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("usermetadata", string(md))
+	err = c.post(fmt.Sprintf("/renter/file/%v", sp), values.Encode(), nil)
+	return
+}
+
 // RenterUploadPost uses the /renter/upload endpoint to upload a file
 func (c *Client) RenterUploadPost(path string, siaPath modules.SiaPath, dataPieces, parityPieces uint64) (err error) {
 	return c.RenterUploadForcePost(path, siaPath, dataPieces, parityPieces, false)
@@ -466,6 +763,33 @@ func (c *Client) RenterUploadDefaultPost(path string, siaPath modules.SiaPath) (
 	return
 }
 
+// RenterUploadPackPost uses the /renter/upload endpoint with default
+// redundancy settings to upload a small file that is packed into a chunk
+// shared with other small files.
+func (c *Client) RenterUploadPackPost(path string, siaPath modules.SiaPath) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("source", path)
+	values.Set("pack", strconv.FormatBool(true))
+	err = c.post(fmt.Sprintf("/renter/upload/%s", sp), values.Encode(), nil)
+	return
+}
+
+// RenterUploadMetadataPost uses the /renter/upload endpoint with default
+// redundancy settings to upload a file with custom user metadata.
+func (c *Client) RenterUploadMetadataPost(path string, siaPath modules.SiaPath, userMetadata map[string]string) (err error) {
+	md, err := json.Marshal(userMetadata)
+	if err != nil {
+		return err
+	}
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("source", path)
+	values.Set("usermetadata", string(md))
+	err = c.post(fmt.Sprintf("/renter/upload/%s", sp), values.Encode(), nil)
+	return
+}
+
 // RenterUploadStreamPost uploads data using a stream.
 func (c *Client) RenterUploadStreamPost(r io.Reader, siaPath modules.SiaPath, dataPieces, parityPieces uint64, force bool) error {
 	sp := escapeSiaPath(siaPath)
@@ -5,12 +5,74 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"gitlab.com/NebulousLabs/Sia/crypto"
+	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/node/api"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
+// WalletAccountsGet requests the accounts of the wallet from the
+// /wallet/accounts endpoint.
+func (c *Client) WalletAccountsGet() (wag api.WalletAccountsGET, err error) {
+	err = c.get("/wallet/accounts", &wag)
+	return
+}
+
+// WalletAccountGet requests a single account of the wallet from the
+// /wallet/accounts/:name endpoint.
+func (c *Client) WalletAccountGet(name string) (wa modules.WalletAccount, err error) {
+	err = c.get("/wallet/accounts/"+url.PathEscape(name), &wa)
+	return
+}
+
+// WalletAccountCreatePost uses the /wallet/accounts/:name endpoint to create a
+// new account.
+func (c *Client) WalletAccountCreatePost(name string) (err error) {
+	err = c.post("/wallet/accounts/"+url.PathEscape(name), "", nil)
+	return
+}
+
+// WalletAccountDeletePost uses the /wallet/accounts/:name/delete endpoint to
+// delete an account.
+func (c *Client) WalletAccountDeletePost(name string) (err error) {
+	err = c.post("/wallet/accounts/"+url.PathEscape(name)+"/delete", "", nil)
+	return
+}
+
+// WalletAccountAddressGet requests a new address for an account from the
+// /wallet/accounts/:name/address endpoint.
+func (c *Client) WalletAccountAddressGet(name string) (wag api.WalletAddressGET, err error) {
+	err = c.get("/wallet/accounts/"+url.PathEscape(name)+"/address", &wag)
+	return
+}
+
+// WalletAccountAssignPost uses the /wallet/accounts/:name/assign endpoint to
+// add a set of addresses to an account.
+func (c *Client) WalletAccountAssignPost(name string, addrs []types.UnlockHash) error {
+	json, err := json.Marshal(api.WalletAccountAssignPOST{
+		Addresses: addrs,
+	})
+	if err != nil {
+		return err
+	}
+	return c.post("/wallet/accounts/"+url.PathEscape(name)+"/assign", string(json), nil)
+}
+
+// WalletAccountAssignRangePost uses the /wallet/accounts/:name/assign endpoint
+// to add the addresses of the primary seed indices [start, end) to an account.
+func (c *Client) WalletAccountAssignRangePost(name string, start, end uint64) error {
+	json, err := json.Marshal(api.WalletAccountAssignPOST{
+		Start: start,
+		End:   end,
+	})
+	if err != nil {
+		return err
+	}
+	return c.post("/wallet/accounts/"+url.PathEscape(name)+"/assign", string(json), nil)
+}
+
 // WalletAddressGet requests a new address from the /wallet/address endpoint
 func (c *Client) WalletAddressGet() (wag api.WalletAddressGET, err error) {
 	err = c.get("/wallet/address", &wag)
@@ -115,6 +177,17 @@ func (c *Client) WalletSiacoinsPost(amount types.Currency, destination types.Unl
 	return
 }
 
+// WalletSiacoinsAccountPost uses the /wallet/siacoins api endpoint to send
+// money from an account of the wallet to a single address.
+func (c *Client) WalletSiacoinsAccountPost(account string, amount types.Currency, destination types.UnlockHash) (wsp api.WalletSiacoinsPOST, err error) {
+	values := url.Values{}
+	values.Set("account", account)
+	values.Set("amount", amount.String())
+	values.Set("destination", destination.String())
+	err = c.post("/wallet/siacoins", values.Encode(), &wsp)
+	return
+}
+
 // WalletSignPost uses the /wallet/sign api endpoint to sign a transaction.
 func (c *Client) WalletSignPost(txn types.Transaction, toSign []crypto.Hash) (wspr api.WalletSignPOSTResp, err error) {
 	json, err := json.Marshal(api.WalletSignPOSTParams{
@@ -165,6 +238,43 @@ func (c *Client) WalletTransactionsGet(startHeight types.BlockHeight, endHeight
 	return
 }
 
+// walletLedgerValues encodes the params of a wallet ledger export as query
+// string values.
+func walletLedgerValues(params modules.WalletLedgerParams) url.Values {
+	values := url.Values{}
+	values.Set("startheight", fmt.Sprint(params.StartHeight))
+	values.Set("endheight", fmt.Sprint(params.EndHeight))
+	values.Set("starttime", fmt.Sprint(params.StartTime))
+	values.Set("endtime", fmt.Sprint(params.EndTime))
+	if len(params.Addresses) > 0 {
+		addrs := make([]string, 0, len(params.Addresses))
+		for _, addr := range params.Addresses {
+			addrs = append(addrs, addr.String())
+		}
+		values.Set("addresses", strings.Join(addrs, ","))
+	}
+	if params.Account != "" {
+		values.Set("account", params.Account)
+	}
+	return values
+}
+
+// WalletTransactionsExportGet requests the /wallet/transactions/export api
+// resource and returns the ledger entries selected by the params.
+func (c *Client) WalletTransactionsExportGet(params modules.WalletLedgerParams) (wteg api.WalletTransactionsExportGET, err error) {
+	err = c.get("/wallet/transactions/export?"+walletLedgerValues(params).Encode(), &wteg)
+	return
+}
+
+// WalletTransactionsExportCSVGet requests the /wallet/transactions/export api
+// resource and returns the ledger entries selected by the params as CSV.
+func (c *Client) WalletTransactionsExportCSVGet(params modules.WalletLedgerParams) ([]byte, error) {
+	values := walletLedgerValues(params)
+	values.Set("format", "csv")
+	_, csv, err := c.getRawResponse("/wallet/transactions/export?" + values.Encode())
+	return csv, err
+}
+
 // WalletTransactionGet requests the /wallet/transaction/:id api resource for a
 // certain TransactionID.
 func (c *Client) WalletTransactionGet(id types.TransactionID) (wtg api.WalletTransactionGETid, err error) {
@@ -13,6 +13,8 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/inconshreveable/go-update"
 	"github.com/julienschmidt/httprouter"
@@ -357,3 +359,82 @@ func (api *API) daemonSettingsHandlerPOST(w http.ResponseWriter, req *http.Reque
 	}
 	WriteSuccess(w)
 }
+
+type (
+	// DaemonToken is an API token without the hash of its secret.
+	DaemonToken struct {
+		ID      string          `json:"id"`
+		Name    string          `json:"name"`
+		Scopes  []string        `json:"scopes"`
+		Expires types.Timestamp `json:"expires"`
+		Expired bool            `json:"expired"`
+	}
+
+	// DaemonTokensGET contains the API tokens of the daemon.
+	DaemonTokensGET struct {
+		Tokens []DaemonToken `json:"tokens"`
+	}
+
+	// DaemonTokensPOST contains a newly created API token and its secret. The
+	// secret can't be retrieved again.
+	DaemonTokensPOST struct {
+		Token  DaemonToken `json:"token"`
+		Secret string      `json:"secret"`
+	}
+)
+
+// daemonToken converts an API token into a DaemonToken.
+func daemonToken(t modules.APIToken) DaemonToken {
+	return DaemonToken{
+		ID:      t.ID,
+		Name:    t.Name,
+		Scopes:  t.Scopes,
+		Expires: t.Expires,
+		Expired: t.Expired(),
+	}
+}
+
+// daemonTokensHandlerGET handles the API call that lists the API tokens.
+func (api *API) daemonTokensHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	tokens := []DaemonToken{}
+	for _, t := range api.siadConfig.APITokensList() {
+		tokens = append(tokens, daemonToken(t))
+	}
+	WriteJSON(w, DaemonTokensGET{Tokens: tokens})
+}
+
+// daemonTokensHandlerPOST handles the API call that creates a new API token.
+func (api *API) daemonTokensHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	scopes := strings.Split(req.FormValue("scopes"), ",")
+	if req.FormValue("scopes") == "" {
+		scopes = nil
+	}
+	// Parse the lifetime of the token. (optional parameter)
+	var lifetime time.Duration
+	if e := req.FormValue("expires"); e != "" {
+		var err error
+		lifetime, err = time.ParseDuration(e)
+		if err != nil || lifetime < 0 {
+			WriteError(w, Error{"unable to parse expires: must be a positive duration like 720h"}, http.StatusBadRequest)
+			return
+		}
+	}
+	token, secret, err := api.siadConfig.AddAPIToken(req.FormValue("name"), scopes, modules.APITokenExpiry(lifetime))
+	if err != nil {
+		WriteError(w, Error{"unable to create token: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, DaemonTokensPOST{
+		Token:  daemonToken(token),
+		Secret: secret,
+	})
+}
+
+// daemonTokensRevokeHandler handles the API call that revokes an API token.
+func (api *API) daemonTokensRevokeHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	if err := api.siadConfig.RevokeAPIToken(ps.ByName("id")); err != nil {
+		WriteError(w, Error{"unable to revoke token: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
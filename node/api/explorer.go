@@ -12,7 +12,50 @@ import (
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
+const (
+	// defaultRichListLimit is the number of rich list entries returned when
+	// the caller does not specify a limit.
+	defaultRichListLimit = 100
+
+	// maxRichListLimit is the largest number of rich list entries that can
+	// be requested in a single call.
+	maxRichListLimit = 1000
+
+	// maxMarketHistory is the largest number of historical market facts that
+	// can be requested in a single call.
+	maxMarketHistory = 1000
+)
+
 type (
+	// ExplorerAddressGET is the object returned by a GET request to
+	// /explorer/addresses/:address.
+	ExplorerAddressGET struct {
+		modules.AddressBalance
+	}
+
+	// ExplorerAddressOutputsGET is the object returned by a GET request to
+	// /explorer/addresses/:address/outputs.
+	ExplorerAddressOutputsGET struct {
+		SiacoinOutputs []modules.UnspentSiacoinOutput `json:"siacoinoutputs"`
+		SiafundOutputs []modules.UnspentSiafundOutput `json:"siafundoutputs"`
+	}
+
+	// ExplorerMarketGET is the object returned by a GET request to
+	// /explorer/market. The embedded facts describe the latest block, and
+	// History contains the facts of the requested range of blocks.
+	ExplorerMarketGET struct {
+		modules.MarketFacts
+		History []modules.MarketFacts `json:"history"`
+	}
+
+	// ExplorerRichListGET is the object returned by a GET request to
+	// /explorer/richlist.
+	ExplorerRichListGET struct {
+		Type    string                  `json:"type"`
+		Offset  uint64                  `json:"offset"`
+		Entries []modules.RichListEntry `json:"entries"`
+	}
+
 	// ExplorerBlock is a block with some extra information such as the id and
 	// height. This information is provided for programs that may not be
 	// complex enough to compute the ID on their own.
@@ -358,3 +401,132 @@ func (api *API) explorerHandler(w http.ResponseWriter, req *http.Request, _ http
 		BlockFacts: facts,
 	})
 }
+
+// explorerAddressHandler handles GET requests to
+// /explorer/addresses/:address.
+func (api *API) explorerAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("address"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerAddressGET{
+		AddressBalance: api.explorer.AddressBalance(addr),
+	})
+}
+
+// explorerAddressOutputsHandler handles GET requests to
+// /explorer/addresses/:address/outputs.
+func (api *API) explorerAddressOutputsHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("address"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerAddressOutputsGET{
+		SiacoinOutputs: api.explorer.AddressSiacoinOutputs(addr),
+		SiafundOutputs: api.explorer.AddressSiafundOutputs(addr),
+	})
+}
+
+// explorerRichListHandler handles GET requests to /explorer/richlist.
+func (api *API) explorerRichListHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	// Parse the optional offset and limit.
+	var offset uint64
+	if o := req.FormValue("offset"); o != "" {
+		_, err := fmt.Sscan(o, &offset)
+		if err != nil {
+			WriteError(w, Error{"unable to parse offset: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	limit := uint64(defaultRichListLimit)
+	if l := req.FormValue("limit"); l != "" {
+		_, err := fmt.Sscan(l, &limit)
+		if err != nil {
+			WriteError(w, Error{"unable to parse limit: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if limit == 0 || limit > maxRichListLimit {
+		WriteError(w, Error{fmt.Sprintf("limit must be between 1 and %v", maxRichListLimit)}, http.StatusBadRequest)
+		return
+	}
+
+	// Fetch the requested rich list, defaulting to siacoins.
+	var entries []modules.RichListEntry
+	listType := req.FormValue("type")
+	switch listType {
+	case "", "siacoins":
+		listType = "siacoins"
+		entries = api.explorer.SiacoinRichList(offset, limit)
+	case "siafunds":
+		entries = api.explorer.SiafundRichList(offset, limit)
+	default:
+		WriteError(w, Error{"type must be 'siacoins' or 'siafunds'"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerRichListGET{
+		Type:    listType,
+		Offset:  offset,
+		Entries: entries,
+	})
+}
+
+// explorerMarketHandler handles GET requests to /explorer/market.
+func (api *API) explorerMarketHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	emg := ExplorerMarketGET{
+		MarketFacts: api.explorer.LatestMarketFacts(),
+	}
+	startStr, endStr, stepStr := req.FormValue("start"), req.FormValue("end"), req.FormValue("step")
+	if startStr == "" && endStr == "" {
+		WriteJSON(w, emg)
+		return
+	}
+
+	// Parse the range of block heights, which defaults to the whole
+	// blockchain.
+	var start types.BlockHeight
+	end := emg.Height
+	step := types.BlockHeight(1)
+	for _, p := range []struct {
+		name, value string
+		height      *types.BlockHeight
+	}{
+		{"start", startStr, &start},
+		{"end", endStr, &end},
+		{"step", stepStr, &step},
+	} {
+		if p.value == "" {
+			continue
+		}
+		_, err := fmt.Sscan(p.value, p.height)
+		if err != nil {
+			WriteError(w, Error{"unable to parse " + p.name + ": " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if end > emg.Height {
+		end = emg.Height
+	}
+	if start > end {
+		WriteError(w, Error{"start must not be greater than end"}, http.StatusBadRequest)
+		return
+	}
+	if step == 0 {
+		WriteError(w, Error{"step must be greater than zero"}, http.StatusBadRequest)
+		return
+	}
+	if (end-start)/step >= maxMarketHistory {
+		WriteError(w, Error{fmt.Sprintf("range contains more than %v blocks, increase the step", maxMarketHistory)}, http.StatusBadRequest)
+		return
+	}
+
+	for height := start; height <= end; height += step {
+		facts, exists := api.explorer.MarketFacts(height)
+		if exists {
+			emg.History = append(emg.History, facts)
+		}
+	}
+	WriteJSON(w, emg)
+}
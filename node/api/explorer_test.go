@@ -81,3 +81,99 @@ func TestIntegrationExplorerHashGet(t *testing.T) {
 		t.Error("wrong block type returned")
 	}
 }
+
+// TestIntegrationExplorerAddresses probes the GET calls to
+// /explorer/addresses/:address, /explorer/addresses/:address/outputs and
+// /explorer/richlist.
+func TestIntegrationExplorerAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createExplorerServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// The largest siafund holder should be reported with a matching balance
+	// and set of unspent outputs.
+	var erlg ExplorerRichListGET
+	err = st.getAPI("/explorer/richlist?type=siafunds&limit=1", &erlg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erlg.Type != "siafunds" || len(erlg.Entries) != 1 {
+		t.Fatal("unexpected siafund rich list:", erlg)
+	}
+	top := erlg.Entries[0]
+	var eag ExplorerAddressGET
+	err = st.getAPI("/explorer/addresses/"+top.UnlockHash.String(), &eag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !eag.SiafundBalance.Equals(top.Balance) || eag.SiafundOutputCount == 0 {
+		t.Error("address balance does not match the rich list:", eag.SiafundBalance, top.Balance)
+	}
+	var eaog ExplorerAddressOutputsGET
+	err = st.getAPI("/explorer/addresses/"+top.UnlockHash.String()+"/outputs", &eaog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(eaog.SiafundOutputs)) != eag.SiafundOutputCount {
+		t.Error("wrong number of siafund outputs returned:", len(eaog.SiafundOutputs))
+	}
+
+	// The siacoin rich list is the default and rejects bad parameters.
+	err = st.getAPI("/explorer/richlist", &erlg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erlg.Type != "siacoins" {
+		t.Error("unexpected siacoin rich list:", erlg)
+	}
+	for _, query := range []string{"type=foo", "limit=0", "limit=1001", "offset=x"} {
+		if err := st.getAPI("/explorer/richlist?"+query, &erlg); err == nil {
+			t.Error("expected an error for query", query)
+		}
+	}
+	if err := st.getAPI("/explorer/addresses/foo", &eag); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+// TestIntegrationExplorerMarket probes the GET call to /explorer/market.
+func TestIntegrationExplorerMarket(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	st, err := createExplorerServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Without a range only the latest facts are returned.
+	var emg ExplorerMarketGET
+	err = st.getAPI("/explorer/market", &emg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emg.BlockID != types.GenesisID || len(emg.History) != 0 {
+		t.Fatal("unexpected market facts:", emg)
+	}
+
+	// The range is clamped to the current height.
+	err = st.getAPI("/explorer/market?start=0&end=100", &emg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emg.History) != 1 || emg.History[0].BlockID != types.GenesisID {
+		t.Fatal("unexpected market history:", emg.History)
+	}
+
+	for _, query := range []string{"start=1&end=0", "start=0&step=0", "start=x"} {
+		if err := st.getAPI("/explorer/market?"+query, &emg); err == nil {
+			t.Error("expected an error for query", query)
+		}
+	}
+}
@@ -96,6 +96,25 @@ func (api *API) hostHandlerGET(w http.ResponseWriter, req *http.Request, _ httpr
 	WriteJSON(w, hg)
 }
 
+// hostSettingsParams are the parameters parsed by parseHostSettings.
+var hostSettingsParams = []string{
+	"acceptingcontracts",
+	"maxdownloadbatchsize",
+	"maxduration",
+	"maxrevisebatchsize",
+	"netaddress",
+	"windowsize",
+	"collateral",
+	"collateralbudget",
+	"maxcollateral",
+	"minbaserpcprice",
+	"mincontractprice",
+	"mindownloadbandwidthprice",
+	"minsectoraccessprice",
+	"minstorageprice",
+	"minuploadbandwidthprice",
+}
+
 // parseHostSettings a request's query strings and returns a
 // modules.HostInternalSettings configured with the request's query string
 // parameters.
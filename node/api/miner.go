@@ -1,11 +1,15 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 
 	"gitlab.com/NebulousLabs/Sia/encoding"
+	"gitlab.com/NebulousLabs/Sia/modules"
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
@@ -13,25 +17,89 @@ type (
 	// MinerGET contains the information that is returned after a GET request
 	// to /miner.
 	MinerGET struct {
-		BlocksMined      int  `json:"blocksmined"`
-		CPUHashrate      int  `json:"cpuhashrate"`
-		CPUMining        bool `json:"cpumining"`
-		StaleBlocksMined int  `json:"staleblocksmined"`
+		BlocksMined      int                   `json:"blocksmined"`
+		CPUHashrate      int                   `json:"cpuhashrate"`
+		CPUMining        bool                  `json:"cpumining"`
+		StaleBlocksMined int                   `json:"staleblocksmined"`
+		Payouts          []modules.MinerPayout `json:"payouts"`
+		CoinbaseData     string                `json:"coinbasedata"`
+	}
+
+	// MinerStratumGET contains the information that is returned after a GET
+	// request to /miner/stratum.
+	MinerStratumGET struct {
+		modules.StratumStatus
 	}
 )
 
 // minerHandler handles the API call that queries the miner's status.
 func (api *API) minerHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	blocksMined, staleMined := api.miner.BlocksMined()
+	settings := api.miner.Settings()
 	mg := MinerGET{
 		BlocksMined:      blocksMined,
 		CPUHashrate:      api.miner.CPUHashrate(),
 		CPUMining:        api.miner.CPUMining(),
 		StaleBlocksMined: staleMined,
+		Payouts:          settings.Payouts,
+		CoinbaseData:     settings.CoinbaseData,
 	}
 	WriteJSON(w, mg)
 }
 
+// parseMinerPayouts parses a comma-separated list of 'address:percentage'
+// pairs. An empty string clears the payouts.
+func parseMinerPayouts(str string) ([]modules.MinerPayout, error) {
+	var payouts []modules.MinerPayout
+	if str == "" {
+		return payouts, nil
+	}
+	for _, pair := range strings.Split(str, ",") {
+		fields := strings.Split(pair, ":")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("payout %q is not of the form 'address:percentage'", pair)
+		}
+		var payout modules.MinerPayout
+		if err := payout.UnlockHash.LoadString(fields[0]); err != nil {
+			return nil, fmt.Errorf("unable to parse payout address %q: %v", fields[0], err)
+		}
+		percentage, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse payout percentage %q: %v", fields[1], err)
+		}
+		payout.Percentage = percentage
+		payouts = append(payouts, payout)
+	}
+	return payouts, nil
+}
+
+// minerHandlerPOST handles the API call that changes the payout and coinbase
+// settings of the miner. Settings that are not specified are left unchanged.
+func (api *API) minerHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := req.ParseForm(); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	settings := api.miner.Settings()
+	if _, ok := req.Form["payouts"]; ok {
+		payouts, err := parseMinerPayouts(req.FormValue("payouts"))
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.Payouts = payouts
+	}
+	if _, ok := req.Form["coinbasedata"]; ok {
+		settings.CoinbaseData = req.FormValue("coinbasedata")
+	}
+	err := api.miner.SetSettings(settings)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // minerStartHandler handles the API call that starts the miner.
 func (api *API) minerStartHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	api.miner.StartCPUMining()
@@ -71,3 +139,40 @@ func (api *API) minerHeaderHandlerPOST(w http.ResponseWriter, req *http.Request,
 	}
 	WriteSuccess(w)
 }
+
+// minerStratumHandlerGET handles the API call that queries the status of the
+// stratum server.
+func (api *API) minerStratumHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, MinerStratumGET{api.miner.StratumStatus()})
+}
+
+// minerStratumStartHandler handles the API call that starts the stratum
+// server.
+func (api *API) minerStratumStartHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	addr := req.FormValue("address")
+	if addr == "" {
+		WriteError(w, Error{"address must be specified"}, http.StatusBadRequest)
+		return
+	}
+	difficulty, err := strconv.ParseUint(req.FormValue("difficulty"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"unable to parse difficulty: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.miner.StartStratum(addr, difficulty)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// minerStratumStopHandler handles the API call that stops the stratum server.
+func (api *API) minerStratumStopHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := api.miner.StopStratum()
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"io/ioutil"
+	"net/url"
 	"testing"
 	"time"
 	"unsafe"
@@ -151,3 +152,119 @@ func TestMinerHeader(t *testing.T) {
 		t.Errorf("block height did not increase after trying to mine a block through the api, started at %v and ended at %v", startingHeight, st.cs.Height())
 	}
 }
+
+// TestMinerStratum checks that the stratum endpoints start and stop the
+// stratum server.
+func TestMinerStratum(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var msg MinerStratumGET
+	if err := st.getAPI("/miner/stratum", &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Running {
+		t.Fatal("stratum server should not be running")
+	}
+
+	// Starting the server requires a valid difficulty.
+	values := url.Values{}
+	values.Set("address", "localhost:0")
+	values.Set("difficulty", "0")
+	if err := st.stdPostAPI("/miner/stratum/start", values); err == nil {
+		t.Fatal("stratum server was started with a zero difficulty")
+	}
+	values.Set("difficulty", "1000")
+	if err := st.stdPostAPI("/miner/stratum/start", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/miner/stratum", &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !msg.Running || msg.ShareDifficulty != 1000 || msg.Address == "" {
+		t.Fatal("unexpected stratum status:", msg)
+	}
+
+	if err := st.stdPostAPI("/miner/stratum/stop", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/miner/stratum", &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Running {
+		t.Fatal("stratum server should not be running")
+	}
+	if err := st.stdPostAPI("/miner/stratum/stop", nil); err == nil {
+		t.Fatal("stopping a stopped stratum server should fail")
+	}
+}
+
+// TestMinerSettingsPOST checks that the payouts and coinbase data of the miner
+// can be changed through the /miner endpoint.
+func TestMinerSettingsPOST(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	addr1 := types.UnlockHash{1}
+	addr2 := types.UnlockHash{2}
+
+	// Invalid payouts are rejected.
+	values := url.Values{}
+	values.Set("payouts", addr1.String()+":50,"+addr2.String()+":40")
+	if err := st.stdPostAPI("/miner", values); err == nil {
+		t.Fatal("payouts that do not add up to 100 were accepted")
+	}
+	values.Set("payouts", addr1.String())
+	if err := st.stdPostAPI("/miner", values); err == nil {
+		t.Fatal("payouts without a percentage were accepted")
+	}
+
+	values.Set("payouts", addr1.String()+":60,"+addr2.String()+":40")
+	values.Set("coinbasedata", "test")
+	if err := st.stdPostAPI("/miner", values); err != nil {
+		t.Fatal(err)
+	}
+	// Settings that are not specified are left unchanged.
+	values = url.Values{}
+	values.Set("coinbasedata", "updated")
+	if err := st.stdPostAPI("/miner", values); err != nil {
+		t.Fatal(err)
+	}
+	var mg MinerGET
+	if err := st.getAPI("/miner", &mg); err != nil {
+		t.Fatal(err)
+	}
+	if mg.CoinbaseData != "updated" || len(mg.Payouts) != 2 {
+		t.Fatalf("unexpected miner settings: %+v", mg)
+	}
+	if mg.Payouts[0].UnlockHash != addr1 || mg.Payouts[0].Percentage != 60 || mg.Payouts[1].UnlockHash != addr2 || mg.Payouts[1].Percentage != 40 {
+		t.Fatalf("unexpected miner payouts: %+v", mg.Payouts)
+	}
+
+	// Mined blocks should pay out to the configured addresses.
+	b, err := st.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.MinerPayouts) != 2 || b.MinerPayouts[0].UnlockHash != addr1 || b.MinerPayouts[1].UnlockHash != addr2 {
+		t.Fatal("block has the wrong miner payouts:", b.MinerPayouts)
+	}
+	subsidy := b.CalculateSubsidy(st.cs.Height())
+	if !b.MinerPayouts[0].Value.Equals(subsidy.Mul64(60).Div64(100)) {
+		t.Fatal("first payout has the wrong value")
+	}
+}
@@ -0,0 +1,495 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/modules"
+)
+
+type (
+	// routeSpec describes the request and response of a route of the API.
+	routeSpec struct {
+		method string
+		path   string
+
+		// params are the query string or form parameters of the route.
+		params []string
+
+		// request is the type of the JSON request body of the route, if any.
+		// requestData is the content type of a non-JSON request body.
+		request     reflect.Type
+		requestData string
+
+		// scope is the API token scope required by the route. Routes without
+		// a scope don't require authentication.
+		scope string
+
+		// response is the type of the JSON response of the route. If it is
+		// nil and responseData is empty, the route responds with the standard
+		// success response.
+		response     reflect.Type
+		responseData string
+	}
+
+	// routeOption adds metadata to the spec of a route.
+	routeOption func(*routeSpec)
+
+	// specRouter is a httprouter.Router that records the spec of every route
+	// it registers and enforces the scopes of the routes.
+	specRouter struct {
+		*httprouter.Router
+		cfg      *modules.SiadConfig
+		password string
+		routes   []routeSpec
+	}
+)
+
+// accepts declares the type of the JSON request body of a route.
+func accepts(obj interface{}) routeOption {
+	return func(rs *routeSpec) {
+		rs.request = reflect.TypeOf(obj)
+	}
+}
+
+// acceptsData declares that a route accepts a request body of the provided
+// content type.
+func acceptsData(contentType string) routeOption {
+	return func(rs *routeSpec) {
+		rs.requestData = contentType
+	}
+}
+
+// params declares the query string or form parameters of a route.
+func params(names ...string) routeOption {
+	return func(rs *routeSpec) {
+		rs.params = append(rs.params, names...)
+	}
+}
+
+// requires declares the API token scope required by a route. Requests to the
+// route have to authenticate with the API password or a token of that scope.
+func requires(scope string) routeOption {
+	return func(rs *routeSpec) {
+		rs.scope = scope
+	}
+}
+
+// returns declares the type of the JSON response of a route.
+func returns(obj interface{}) routeOption {
+	return func(rs *routeSpec) {
+		rs.response = reflect.TypeOf(obj)
+	}
+}
+
+// returnsData declares that a route responds with data of the provided
+// content type.
+func returnsData(contentType string) routeOption {
+	return func(rs *routeSpec) {
+		rs.responseData = contentType
+	}
+}
+
+// newSpecRouter creates a new specRouter. Scoped routes require the provided
+// password or an API token of the config.
+func newSpecRouter(password string, cfg *modules.SiadConfig) *specRouter {
+	return &specRouter{
+		Router:   httprouter.New(),
+		cfg:      cfg,
+		password: password,
+	}
+}
+
+// GET registers a GET route and records its spec.
+func (r *specRouter) GET(path string, h httprouter.Handle, opts ...routeOption) {
+	rs := r.document("GET", path, opts...)
+	r.Router.GET(path, r.handle(rs, h))
+}
+
+// POST registers a POST route and records its spec.
+func (r *specRouter) POST(path string, h httprouter.Handle, opts ...routeOption) {
+	rs := r.document("POST", path, opts...)
+	r.Router.POST(path, r.handle(rs, h))
+}
+
+// handle wraps the handler of a route in the middleware required by its spec.
+func (r *specRouter) handle(rs routeSpec, h httprouter.Handle) httprouter.Handle {
+	if rs.scope == "" {
+		return h
+	}
+	return RequireScope(h, r.password, r.cfg, rs.scope)
+}
+
+// document records the spec of a route without registering it. It is used for
+// routes that are dispatched by the handler of a different route.
+func (r *specRouter) document(method, path string, opts ...routeOption) routeSpec {
+	rs := routeSpec{
+		method: method,
+		path:   path,
+	}
+	for _, opt := range opts {
+		opt(&rs)
+	}
+	r.routes = append(r.routes, rs)
+	return rs
+}
+
+type (
+	// OpenAPIDocument is an OpenAPI 3.0 document describing the API.
+	OpenAPIDocument struct {
+		OpenAPI    string                                 `json:"openapi"`
+		Info       OpenAPIInfo                            `json:"info"`
+		Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+		Components OpenAPIComponents                      `json:"components"`
+	}
+
+	// OpenAPIInfo contains the title and version of the API.
+	OpenAPIInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	// OpenAPIComponents contains the schemas referenced by the operations of
+	// the document.
+	OpenAPIComponents struct {
+		Schemas         map[string]*JSONSchema           `json:"schemas"`
+		SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes"`
+	}
+
+	// OpenAPISecurityScheme describes how requests authenticate.
+	OpenAPISecurityScheme struct {
+		Type   string `json:"type"`
+		Scheme string `json:"scheme"`
+	}
+
+	// OpenAPIOperation describes a single method of a path.
+	OpenAPIOperation struct {
+		OperationID string                     `json:"operationId"`
+		Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+		RequestBody *OpenAPIBody               `json:"requestBody,omitempty"`
+		Responses   map[string]OpenAPIResponse `json:"responses"`
+		Security    []map[string][]string      `json:"security"`
+		Scope       string                     `json:"x-sia-scope,omitempty"`
+	}
+
+	// OpenAPIParameter describes a path or query string parameter.
+	OpenAPIParameter struct {
+		Name     string      `json:"name"`
+		In       string      `json:"in"`
+		Required bool        `json:"required"`
+		Schema   *JSONSchema `json:"schema"`
+	}
+
+	// OpenAPIBody describes a request body.
+	OpenAPIBody struct {
+		Required bool                        `json:"required"`
+		Content  map[string]OpenAPIMediaType `json:"content"`
+	}
+
+	// OpenAPIResponse describes a response.
+	OpenAPIResponse struct {
+		Description string                      `json:"description"`
+		Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+	}
+
+	// OpenAPIMediaType contains the schema of a request or response body.
+	OpenAPIMediaType struct {
+		Schema *JSONSchema `json:"schema"`
+	}
+
+	// JSONSchema is the subset of JSON schema used by OpenAPI documents.
+	JSONSchema struct {
+		Ref                  string                 `json:"$ref,omitempty"`
+		Type                 string                 `json:"type,omitempty"`
+		Format               string                 `json:"format,omitempty"`
+		Nullable             bool                   `json:"nullable,omitempty"`
+		Items                *JSONSchema            `json:"items,omitempty"`
+		Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+		AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+		Required             []string               `json:"required,omitempty"`
+	}
+)
+
+// schemaRefPrefix is the prefix of references to the component schemas.
+const schemaRefPrefix = "#/components/schemas/"
+
+var (
+	// marshalerType is the reflect.Type of json.Marshaler.
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	// textMarshalerType is the reflect.Type of encoding.TextMarshaler.
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGenerator derives JSON schemas from Go types. Named structs are added
+// to the component schemas and referenced.
+type schemaGenerator struct {
+	schemas map[string]*JSONSchema
+}
+
+// schemaName returns the component name of a named type.
+func schemaName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// marshalerSchema determines the schema of a type that implements
+// json.Marshaler by marshaling its zero value.
+func marshalerSchema(t reflect.Type) (s *JSONSchema) {
+	defer func() {
+		if recover() != nil {
+			s = &JSONSchema{}
+		}
+	}()
+	b, err := json.Marshal(reflect.New(t).Interface())
+	if err != nil || len(b) == 0 {
+		return &JSONSchema{}
+	}
+	switch b[0] {
+	case '"':
+		return &JSONSchema{Type: "string"}
+	case '[':
+		return &JSONSchema{Type: "array", Items: &JSONSchema{}}
+	case '{':
+		return &JSONSchema{Type: "object"}
+	case 't', 'f':
+		return &JSONSchema{Type: "boolean"}
+	case 'n':
+		return &JSONSchema{}
+	default:
+		return &JSONSchema{Type: "number"}
+	}
+}
+
+// schema returns the schema of a Go type.
+func (sg *schemaGenerator) schema(t reflect.Type) *JSONSchema {
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return marshalerSchema(t)
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		// encoding/json encodes text marshalers as strings.
+		return &JSONSchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := sg.schema(t.Elem())
+		if s.Ref != "" {
+			// OpenAPI 3.0 ignores siblings of $ref.
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Interface:
+		return &JSONSchema{}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &JSONSchema{Type: "array", Items: sg.schema(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &JSONSchema{Type: "array", Items: sg.schema(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: sg.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sg.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, exists := sg.schemas[name]; !exists {
+			// Reserve the name before generating the schema to support
+			// recursive types.
+			sg.schemas[name] = &JSONSchema{}
+			*sg.schemas[name] = *sg.structSchema(t)
+		}
+		return &JSONSchema{Ref: schemaRefPrefix + name}
+	default:
+		return &JSONSchema{}
+	}
+}
+
+// structSchema returns the schema of the fields of a struct, following the
+// rules of encoding/json for tags and embedded structs.
+func (sg *schemaGenerator) structSchema(t reflect.Type) *JSONSchema {
+	s := &JSONSchema{
+		Type:       "object",
+		Properties: make(map[string]*JSONSchema),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := sg.structSchema(ft)
+				for prop, ps := range embedded.Properties {
+					if _, exists := s.Properties[prop]; !exists {
+						s.Properties[prop] = ps
+					}
+				}
+				s.Required = append(s.Required, embedded.Required...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := sg.schema(ft)
+		if strings.Contains(opts, "string") {
+			fs = &JSONSchema{Type: "string"}
+		}
+		s.Properties[name] = fs
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// openAPIPath converts a httprouter path into an OpenAPI path and returns the
+// names of its path parameters.
+func openAPIPath(p string) (string, []string) {
+	var names []string
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			names = append(names, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// operationID derives the operation ID of a route from its method and path.
+func operationID(method, p string) string {
+	id := strings.ToLower(method)
+	for _, seg := range strings.Split(p, "/") {
+		seg = strings.TrimLeft(seg, ":*")
+		if seg == "" {
+			continue
+		}
+		id += strings.ToUpper(seg[:1]) + seg[1:]
+	}
+	return id
+}
+
+// buildOpenAPIDocument creates the OpenAPI document of a set of routes.
+func buildOpenAPIDocument(routes []routeSpec) OpenAPIDocument {
+	sg := &schemaGenerator{schemas: make(map[string]*JSONSchema)}
+	doc := OpenAPIDocument{
+		OpenAPI: "3.0.0",
+		Info: OpenAPIInfo{
+			Title:   "Sia API",
+			Version: build.Version,
+		},
+		Paths: make(map[string]map[string]OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: sg.schemas,
+			SecuritySchemes: map[string]OpenAPISecurityScheme{
+				"password": {Type: "http", Scheme: "basic"},
+				"token":    {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	errorSchema := sg.schema(reflect.TypeOf(Error{}))
+	for _, rs := range routes {
+		p, pathParams := openAPIPath(rs.path)
+		op := OpenAPIOperation{
+			OperationID: operationID(rs.method, rs.path),
+			Responses: map[string]OpenAPIResponse{
+				"default": {
+					Description: "error",
+					Content:     map[string]OpenAPIMediaType{"application/json": {Schema: errorSchema}},
+				},
+			},
+			Security: []map[string][]string{},
+			Scope:    rs.scope,
+		}
+		if rs.scope != "" {
+			op.Security = []map[string][]string{{"password": {}}, {"token": {}}}
+		}
+		for _, name := range pathParams {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &JSONSchema{Type: "string"},
+			})
+		}
+		for _, name := range rs.params {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:   name,
+				In:     "query",
+				Schema: &JSONSchema{Type: "string"},
+			})
+		}
+		switch {
+		case rs.request != nil:
+			op.RequestBody = &OpenAPIBody{
+				Required: true,
+				Content:  map[string]OpenAPIMediaType{"application/json": {Schema: sg.schema(rs.request)}},
+			}
+		case rs.requestData != "":
+			op.RequestBody = &OpenAPIBody{
+				Required: true,
+				Content:  map[string]OpenAPIMediaType{rs.requestData: {Schema: &JSONSchema{Type: "string", Format: "binary"}}},
+			}
+		}
+		switch {
+		case rs.response != nil:
+			op.Responses["200"] = OpenAPIResponse{
+				Description: "success",
+				Content:     map[string]OpenAPIMediaType{"application/json": {Schema: sg.schema(rs.response)}},
+			}
+		case rs.responseData != "":
+			op.Responses["200"] = OpenAPIResponse{
+				Description: "success",
+				Content:     map[string]OpenAPIMediaType{rs.responseData: {Schema: &JSONSchema{Type: "string", Format: "binary"}}},
+			}
+		default:
+			op.Responses["204"] = OpenAPIResponse{Description: "success"}
+		}
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = make(map[string]OpenAPIOperation)
+		}
+		doc.Paths[p][strings.ToLower(rs.method)] = op
+	}
+	return doc
+}
+
+// daemonOpenAPIHandler handles the API call that returns the OpenAPI document
+// of the API.
+func (api *API) daemonOpenAPIHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	// ServeHTTP holds a read lock on routerMu while the handler runs.
+	WriteJSON(w, buildOpenAPIDocument(api.routes))
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// validateSchema checks that a decoded JSON value matches a schema of the
+// document. Objects with properties may not contain unknown properties and
+// must contain all required properties.
+func validateSchema(doc OpenAPIDocument, s *JSONSchema, v interface{}, path string) error {
+	if s.Ref != "" {
+		ref, exists := doc.Components.Schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)]
+		if !exists {
+			return fmt.Errorf("%v: unknown schema %v", path, s.Ref)
+		}
+		s = ref
+	}
+	if v == nil {
+		if s.Type != "" && !s.Nullable {
+			return fmt.Errorf("%v: null is not nullable %v", path, s.Type)
+		}
+		return nil
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%v: expected string, got %T", path, v)
+		}
+	case "integer", "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%v: expected %v, got %T", path, s.Type, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v: expected boolean, got %T", path, v)
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v: expected array, got %T", path, v)
+		}
+		for i, elem := range arr {
+			if err := validateSchema(doc, s.Items, elem, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v: expected object, got %T", path, v)
+		}
+		if s.AdditionalProperties != nil {
+			for key, elem := range obj {
+				if err := validateSchema(doc, s.AdditionalProperties, elem, path+"."+key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if s.Properties == nil {
+			return nil
+		}
+		for key, elem := range obj {
+			prop, exists := s.Properties[key]
+			if !exists {
+				return fmt.Errorf("%v: property %v is not declared", path, key)
+			}
+			if err := validateSchema(doc, prop, elem, path+"."+key); err != nil {
+				return err
+			}
+		}
+		for _, key := range s.Required {
+			if _, exists := obj[key]; !exists {
+				return fmt.Errorf("%v: required property %v is missing", path, key)
+			}
+		}
+	}
+	return nil
+}
+
+// TestSchemaGenerator checks that the schemas of Go types follow the rules of
+// encoding/json.
+func TestSchemaGenerator(t *testing.T) {
+	type embedded struct {
+		Embedded string `json:"embedded"`
+	}
+	type example struct {
+		embedded
+		Plain     string
+		Tagged    int               `json:"tagged"`
+		Optional  []string          `json:"optional,omitempty"`
+		Ignored   bool              `json:"-"`
+		Quoted    uint64            `json:"quoted,string"`
+		Value     types.Currency    `json:"value"`
+		Pointer   *types.Block      `json:"pointer"`
+		Map       map[string]uint64 `json:"map"`
+		Bytes     []byte            `json:"bytes"`
+		unexposed int
+	}
+	sg := &schemaGenerator{schemas: make(map[string]*JSONSchema)}
+	ref := sg.schema(reflect.TypeOf(example{}))
+	s, exists := sg.schemas[strings.TrimPrefix(ref.Ref, schemaRefPrefix)]
+	if !exists {
+		t.Fatal("named struct was not added to the components", ref.Ref)
+	}
+	expected := map[string]string{
+		"embedded": "string",
+		"Plain":    "string",
+		"tagged":   "integer",
+		"optional": "array",
+		"quoted":   "string",
+		"value":    "string",
+		"pointer":  "",
+		"map":      "object",
+		"bytes":    "string",
+	}
+	if len(s.Properties) != len(expected) {
+		t.Fatal("unexpected properties", s.Properties)
+	}
+	for name, typ := range expected {
+		prop, exists := s.Properties[name]
+		if !exists {
+			t.Fatal("missing property", name)
+		}
+		if prop.Type != typ {
+			t.Fatalf("expected %v to have type %v, got %v", name, typ, prop.Type)
+		}
+	}
+	if s.Properties["pointer"].Ref == "" {
+		t.Fatal("pointer to a named struct should be a reference")
+	}
+	for _, name := range s.Required {
+		if name == "optional" {
+			t.Fatal("omitempty property is required")
+		}
+	}
+	if len(s.Required) != len(expected)-1 {
+		t.Fatal("unexpected required properties", s.Required)
+	}
+
+	// A valid value validates, a value with an unknown property doesn't.
+	doc := OpenAPIDocument{Components: OpenAPIComponents{Schemas: sg.schemas}}
+	var v interface{}
+	b, _ := json.Marshal(example{Pointer: &types.Block{}})
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateSchema(doc, ref, v, "example"); err != nil {
+		t.Fatal(err)
+	}
+	v.(map[string]interface{})["unknown"] = true
+	if err := validateSchema(doc, ref, v, "example"); err == nil {
+		t.Fatal("unknown property was accepted")
+	}
+}
+
+// TestOpenAPIDrift fetches the OpenAPI document of a server and checks that
+// the responses of all GET routes without parameters match the schemas
+// declared in the route table.
+func TestOpenAPIDrift(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var doc OpenAPIDocument
+	if err := st.getAPI("/daemon/openapi.json", &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) == 0 {
+		t.Fatal("document has no paths")
+	}
+
+	// Routes with side effects or that depend on external services are
+	// skipped.
+	skip := map[string]bool{
+		"/daemon/update": true,
+	}
+	var validated int
+	for path, ops := range doc.Paths {
+		op, exists := ops["get"]
+		if !exists || skip[path] || strings.Contains(path, "{") {
+			continue
+		}
+		res, exists := op.Responses["200"]
+		if !exists {
+			continue
+		}
+		media, exists := res.Content["application/json"]
+		if !exists {
+			continue
+		}
+		resp, err := HttpGET("http://" + st.server.listener.Addr().String() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if non2xx(resp.StatusCode) {
+			// Routes that require parameters can't be validated without
+			// them.
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		if err := validateSchema(doc, media.Schema, v, path); err != nil {
+			t.Error(err)
+		}
+		validated++
+	}
+	if validated < 20 {
+		t.Fatal("only validated", validated, "routes")
+	}
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -10,6 +14,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -19,6 +24,7 @@ import (
 	"gitlab.com/NebulousLabs/Sia/build"
 	"gitlab.com/NebulousLabs/Sia/crypto"
 	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/modules/renter"
 	"gitlab.com/NebulousLabs/Sia/modules/renter/proto"
 	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
 	"gitlab.com/NebulousLabs/Sia/types"
@@ -72,6 +78,8 @@ type (
 		Settings         modules.RenterSettings     `json:"settings"`
 		FinancialMetrics modules.ContractorSpending `json:"financialmetrics"`
 		CurrentPeriod    types.BlockHeight          `json:"currentperiod"`
+		VersionStorage   modules.VersionStorage     `json:"versionstorage"`
+		ChunkCache       modules.ChunkCacheStats    `json:"chunkcache"`
 	}
 
 	// RenterContract represents a contract formed by the renter.
@@ -156,6 +164,28 @@ type (
 		Files []modules.FileInfo `json:"files"`
 	}
 
+	// RenterFileVersions lists the old versions of a file.
+	RenterFileVersions struct {
+		Versions []modules.FileVersion `json:"versions"`
+	}
+
+	// RenterVersionPoliciesGET lists the version policies of the renter.
+	RenterVersionPoliciesGET struct {
+		Policies []modules.VersionPolicyInfo `json:"policies"`
+	}
+
+	// RenterTrashGET lists the entries of the trash and how long they are
+	// kept.
+	RenterTrashGET struct {
+		Retention time.Duration        `json:"retention"`
+		Entries   []modules.TrashEntry `json:"entries"`
+	}
+
+	// RenterDrainsGET lists the progress of the drains of the renter.
+	RenterDrainsGET struct {
+		Drains []modules.HostDrainInfo `json:"drains"`
+	}
+
 	// RenterLoad lists files that were loaded into the renter.
 	RenterLoad struct {
 		FilesAdded []string `json:"filesadded"`
@@ -194,6 +224,12 @@ type (
 		UnsyncedHosts []types.SiaPublicKey   `json:"unsyncedhosts"`
 	}
 
+	// RenterSyncDirsGET lists the local directories that are synced by the
+	// renter.
+	RenterSyncDirsGET struct {
+		SyncDirs []modules.SyncDirInfo `json:"syncdirs"`
+	}
+
 	// DownloadInfo contains all client-facing information of a file.
 	DownloadInfo struct {
 		Destination     string          `json:"destination"`     // The destination of the download.
@@ -345,6 +381,157 @@ func (api *API) renterBackupsRestoreHandlerGET(w http.ResponseWriter, req *http.
 	WriteSuccess(w)
 }
 
+// renterSyncDirsHandlerGET handles the API calls to /renter/syncdirs.
+func (api *API) renterSyncDirsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterSyncDirsGET{
+		SyncDirs: api.renter.SyncDirs(),
+	})
+}
+
+// renterSyncDirsAddHandlerPOST handles the API calls to /renter/syncdirs/add.
+func (api *API) renterSyncDirsAddHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	localPath := req.FormValue("localpath")
+	if localPath == "" {
+		WriteError(w, Error{"localpath not specified"}, http.StatusBadRequest)
+		return
+	}
+	siaPath, err := modules.NewSiaPath(req.FormValue("siapath"))
+	if err != nil {
+		WriteError(w, Error{"invalid siapath: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.AddSyncDir(localPath, siaPath); err != nil {
+		WriteError(w, Error{"failed to add sync dir: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterSyncDirsRemoveHandlerPOST handles the API calls to
+// /renter/syncdirs/remove.
+func (api *API) renterSyncDirsRemoveHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(req.FormValue("siapath"))
+	if err != nil {
+		WriteError(w, Error{"invalid siapath: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.RemoveSyncDir(siaPath); err != nil {
+		WriteError(w, Error{"failed to remove sync dir: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// parseShareSiaPaths parses the comma-separated siapaths of a share request.
+func parseShareSiaPaths(str string) ([]modules.SiaPath, error) {
+	if str == "" {
+		return nil, errors.New("siapaths not specified")
+	}
+	var siaPaths []modules.SiaPath
+	for _, path := range strings.Split(str, ",") {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			return nil, errors.AddContext(err, "invalid siapath "+path)
+		}
+		siaPaths = append(siaPaths, siaPath)
+	}
+	return siaPaths, nil
+}
+
+// renterLoadResponse converts the siapaths of loaded files to a RenterLoad.
+func renterLoadResponse(siaPaths []modules.SiaPath) RenterLoad {
+	rl := RenterLoad{FilesAdded: make([]string, 0, len(siaPaths))}
+	for _, siaPath := range siaPaths {
+		rl.FilesAdded = append(rl.FilesAdded, siaPath.String())
+	}
+	return rl
+}
+
+// renterShareHandler handles the API call to share files by writing them to
+// a .sia file on disk.
+func (api *API) renterShareHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	siaPaths, err := parseShareSiaPaths(req.FormValue("siapaths"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	dst := req.FormValue("destination")
+	if !filepath.IsAbs(dst) {
+		WriteError(w, Error{"destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	if filepath.Ext(dst) != modules.SiaFileExtension {
+		WriteError(w, Error{renter.ErrNonShareSuffix.Error()}, http.StatusBadRequest)
+		return
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		WriteError(w, Error{"failed to create destination: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.ShareFiles(f, siaPaths, req.FormValue("password"))
+	if err = errors.Compose(err, f.Close()); err != nil {
+		os.Remove(dst)
+		WriteError(w, Error{"failed to share files: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterShareASCIIHandler handles the API call to share files as a base64
+// encoded string.
+func (api *API) renterShareASCIIHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	siaPaths, err := parseShareSiaPaths(req.FormValue("siapaths"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	enc := base64.NewEncoder(base64.URLEncoding, &buf)
+	err = api.renter.ShareFiles(enc, siaPaths, req.FormValue("password"))
+	// Close flushes the final partial block of the encoding.
+	err = errors.Compose(err, enc.Close())
+	if err != nil {
+		WriteError(w, Error{"failed to share files: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterShareASCII{ASCIIsia: buf.String()})
+}
+
+// renterLoadHandler handles the API call to load the files of a .sia file on
+// disk into the renter.
+func (api *API) renterLoadHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		WriteError(w, Error{"failed to open source: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+	siaPaths, err := api.renter.LoadSharedFiles(f, req.FormValue("password"))
+	if err != nil {
+		WriteError(w, Error{"failed to load shared files: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, renterLoadResponse(siaPaths))
+}
+
+// renterLoadASCIIHandler handles the API call to load the files of a base64
+// encoded share into the renter.
+func (api *API) renterLoadASCIIHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	r := base64.NewDecoder(base64.URLEncoding, strings.NewReader(req.FormValue("asciisia")))
+	siaPaths, err := api.renter.LoadSharedFiles(r, req.FormValue("password"))
+	if err != nil {
+		WriteError(w, Error{"failed to load shared files: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, renterLoadResponse(siaPaths))
+}
+
 // renterBackupHandlerPOST handles the API calls to /renter/backup
 func (api *API) renterBackupHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Check that destination was specified.
@@ -411,6 +598,19 @@ func (api *API) renterLoadBackupHandlerPOST(w http.ResponseWriter, req *http.Req
 	WriteSuccess(w)
 }
 
+// parseUserMetadata parses the JSON encoded user metadata of a file. An empty
+// string results in nil metadata.
+func parseUserMetadata(str string) (map[string]string, error) {
+	if str == "" {
+		return nil, nil
+	}
+	var userMetadata map[string]string
+	if err := json.Unmarshal([]byte(str), &userMetadata); err != nil {
+		return nil, err
+	}
+	return userMetadata, nil
+}
+
 // parseErasureCodingParameters parses the supplied string values and creates
 // an erasure coder. If values haven't been supplied it will fill in sane
 // defaults.
@@ -460,6 +660,8 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, req *http.Request, _ htt
 		Settings:         api.renter.Settings(),
 		FinancialMetrics: api.renter.PeriodSpending(),
 		CurrentPeriod:    api.renter.CurrentPeriod(),
+		VersionStorage:   api.renter.VersionStorage(),
+		ChunkCache:       api.renter.ChunkCacheStats(),
 	})
 }
 
@@ -569,6 +771,28 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		// Sane defaults if it hasn't been set before.
 		settings.Allowance.ExpectedRedundancy = modules.DefaultAllowance.ExpectedRedundancy
 	}
+	// Scan the price caps. (optional parameters)
+	priceCaps := []struct {
+		name  string
+		price *types.Currency
+	}{
+		{"maxrpcprice", &settings.Allowance.MaxRPCPrice},
+		{"maxcontractprice", &settings.Allowance.MaxContractPrice},
+		{"maxdownloadbandwidthprice", &settings.Allowance.MaxDownloadBandwidthPrice},
+		{"maxsectoraccessprice", &settings.Allowance.MaxSectorAccessPrice},
+		{"maxstorageprice", &settings.Allowance.MaxStoragePrice},
+		{"maxuploadbandwidthprice", &settings.Allowance.MaxUploadBandwidthPrice},
+	}
+	for _, pc := range priceCaps {
+		if p := req.FormValue(pc.name); p != "" {
+			price, ok := scanAmount(p)
+			if !ok {
+				WriteError(w, Error{"unable to parse " + pc.name}, http.StatusBadRequest)
+				return
+			}
+			*pc.price = price
+		}
+	}
 	// Scan the download speed limit. (optional parameter)
 	if d := req.FormValue("maxdownloadspeed"); d != "" {
 		var downloadSpeed int64
@@ -587,6 +811,15 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		}
 		settings.MaxUploadSpeed = uploadSpeed
 	}
+	// Scan the chunk cache size. (optional parameter)
+	if c := req.FormValue("chunkcachesize"); c != "" {
+		var chunkCacheSize uint64
+		if _, err := fmt.Sscan(c, &chunkCacheSize); err != nil {
+			WriteError(w, Error{"unable to parse chunkcachesize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.ChunkCacheSize = chunkCacheSize
+	}
 	// Scan the checkforipviolation flag.
 	if ipc := req.FormValue("checkforipviolation"); ipc != "" {
 		var ipviolationcheck bool
@@ -621,6 +854,30 @@ func (api *API) renterContractCancelHandler(w http.ResponseWriter, req *http.Req
 	WriteSuccess(w)
 }
 
+// renterDrainHandlerGET handles GET requests to the /renter/drain API
+// endpoint.
+func (api *API) renterDrainHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterDrainsGET{
+		Drains: api.renter.HostDrains(),
+	})
+}
+
+// renterDrainHandlerPOST handles POST requests to the /renter/drain API
+// endpoint.
+func (api *API) renterDrainHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var pk types.SiaPublicKey
+	pk.LoadString(req.FormValue("hostkey"))
+	if pk.Key == nil {
+		WriteError(w, Error{"invalid host public key"}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.DrainHost(pk); err != nil {
+		WriteError(w, Error{"unable to drain host: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterContractsHandler handles the API call to request the Renter's
 // contracts. Active and renewed contracts are returned by default
 //
@@ -923,6 +1180,193 @@ func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteSuccess(w)
 }
 
+// renterCopyHandler handles POST requests to the /renter/copy/:siapath API
+// endpoint.
+func (api *API) renterCopyHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	newSiaPath, err := modules.NewSiaPath(req.FormValue("newsiapath"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.CopyFile(siaPath, newSiaPath); err != nil {
+		WriteError(w, Error{"failed to copy file: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterVersionsHandler handles GET requests to the /renter/versions/:siapath
+// API endpoint.
+func (api *API) renterVersionsHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	versions, err := api.renter.FileVersions(siaPath)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterFileVersions{
+		Versions: versions,
+	})
+}
+
+// renterRestoreVersionHandler handles POST requests to the
+// /renter/restoreversion/:siapath API endpoint.
+func (api *API) renterRestoreVersionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var id uint64
+	if _, err := fmt.Sscan(req.FormValue("id"), &id); err != nil {
+		WriteError(w, Error{"unable to parse id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.RestoreFileVersion(siaPath, id); err != nil {
+		WriteError(w, Error{"failed to restore version: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterVersionPoliciesHandler handles GET requests to the
+// /renter/versionpolicies API endpoint.
+func (api *API) renterVersionPoliciesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterVersionPoliciesGET{
+		Policies: api.renter.VersionPolicies(),
+	})
+}
+
+// renterVersionPolicyHandler handles POST requests to the
+// /renter/versionpolicy/:siapath API endpoint.
+func (api *API) renterVersionPolicyHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var siaPath modules.SiaPath
+	var err error
+	str := ps.ByName("siapath")
+	if str == "" || str == "/" {
+		siaPath = modules.RootSiaPath()
+	} else {
+		siaPath, err = modules.NewSiaPath(str)
+	}
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Remove the policy if requested.
+	if req.FormValue("remove") != "" {
+		var remove bool
+		if _, err := fmt.Sscan(req.FormValue("remove"), &remove); err != nil {
+			WriteError(w, Error{"unable to parse remove: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if remove {
+			if err := api.renter.RemoveVersionPolicy(siaPath); err != nil {
+				WriteError(w, Error{"failed to remove version policy: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			WriteSuccess(w)
+			return
+		}
+	}
+
+	var policy modules.VersionPolicy
+	if _, err := fmt.Sscan(req.FormValue("maxversions"), &policy.MaxVersions); err != nil {
+		WriteError(w, Error{"unable to parse maxversions: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if ma := req.FormValue("maxage"); ma != "" {
+		policy.MaxAge, err = time.ParseDuration(ma)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxage: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := api.renter.SetVersionPolicy(siaPath, policy); err != nil {
+		WriteError(w, Error{"failed to set version policy: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterTrashHandlerGET handles GET requests to the /renter/trash API
+// endpoint.
+func (api *API) renterTrashHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterTrashGET{
+		Retention: api.renter.TrashRetention(),
+		Entries:   api.renter.Trash(),
+	})
+}
+
+// renterTrashRestoreHandler handles POST requests to the /renter/trash/restore
+// API endpoint.
+func (api *API) renterTrashRestoreHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var id uint64
+	if _, err := fmt.Sscan(req.FormValue("id"), &id); err != nil {
+		WriteError(w, Error{"unable to parse id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.RestoreTrashEntry(id); err != nil {
+		WriteError(w, Error{"failed to restore trash entry: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterTrashPurgeHandler handles POST requests to the /renter/trash/purge API
+// endpoint.
+func (api *API) renterTrashPurgeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var all bool
+	if req.FormValue("all") != "" {
+		if _, err := fmt.Sscan(req.FormValue("all"), &all); err != nil {
+			WriteError(w, Error{"unable to parse all: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if all {
+		if err := api.renter.PurgeTrash(); err != nil {
+			WriteError(w, Error{"failed to purge trash: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		WriteSuccess(w)
+		return
+	}
+	var id uint64
+	if _, err := fmt.Sscan(req.FormValue("id"), &id); err != nil {
+		WriteError(w, Error{"unable to parse id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.PurgeTrashEntry(id); err != nil {
+		WriteError(w, Error{"failed to purge trash entry: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterTrashRetentionHandler handles POST requests to the
+// /renter/trash/retention API endpoint.
+func (api *API) renterTrashRetentionHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	retention, err := time.ParseDuration(req.FormValue("retention"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse retention: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.renter.SetTrashRetention(retention); err != nil {
+		WriteError(w, Error{"failed to set trash retention: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterFileHandler handles GET requests to the /renter/file/:siapath API endpoint.
 func (api *API) renterFileHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
@@ -956,6 +1400,18 @@ func (api *API) renterFileHandlerPOST(w http.ResponseWriter, req *http.Request,
 			return
 		}
 	}
+	// Handle changing the user metadata of a file.
+	if req.Form["usermetadata"] != nil {
+		userMetadata, err := parseUserMetadata(req.FormValue("usermetadata"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'usermetadata' arg: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if err := api.renter.SetFileUserMetadata(siaPath, userMetadata); err != nil {
+			WriteError(w, Error{"failed to set user metadata: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 	// Handle changing the 'stuck' status of a file.
 	if stuck != "" {
 		s, err := strconv.ParseBool(stuck)
@@ -971,6 +1427,29 @@ func (api *API) renterFileHandlerPOST(w http.ResponseWriter, req *http.Request,
 	WriteSuccess(w)
 }
 
+// renterFindHandler handles the API call to find files by their user
+// metadata.
+func (api *API) renterFindHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	query, err := parseUserMetadata(req.FormValue("usermetadata"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'usermetadata' arg: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	tag := req.FormValue("tag")
+	if tag == "" && len(query) == 0 {
+		WriteError(w, Error{"either 'tag' or 'usermetadata' has to be specified"}, http.StatusBadRequest)
+		return
+	}
+	files, err := api.renter.FindFiles(tag, query)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterFiles{
+		Files: files,
+	})
+}
+
 // renterFilesHandler handles the API call to list all of the files.
 func (api *API) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	var c bool
@@ -1114,6 +1593,10 @@ func (api *API) renterCancelDownloadHandler(w http.ResponseWriter, req *http.Req
 
 // renterDownloadHandler handles the API call to download a file.
 func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if req.FormValue("format") != "" {
+		api.renterDownloadArchiveHandler(w, req, ps)
+		return
+	}
 	params, err := parseDownloadParameters(w, req, ps)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
@@ -1150,6 +1633,53 @@ func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request,
 	}
 }
 
+// renterDownloadArchiveHandler handles the API call to download a directory
+// as an archive that is written to the response body.
+func (api *API) renterDownloadArchiveHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
+	if err != nil {
+		WriteError(w, Error{"error parsing the siapath: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	format := req.FormValue("format")
+	switch format {
+	case modules.ArchiveFormatTar:
+		w.Header().Set("Content-Type", "application/x-tar")
+	case modules.ArchiveFormatZip:
+		w.Header().Set("Content-Type", "application/zip")
+	default:
+		WriteError(w, Error{"format must be 'tar' or 'zip'"}, http.StatusBadRequest)
+		return
+	}
+	name := siaPath.Name()
+	if siaPath.IsRoot() {
+		name = "sia"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+
+	// Once the archive has been started, the status code has already been
+	// sent. A failed download then results in an unfinished archive.
+	aw := &archiveResponseWriter{w: w}
+	err = api.renter.DownloadArchive(aw, siaPath, format)
+	if err != nil && !aw.written {
+		w.Header().Del("Content-Disposition")
+		WriteError(w, Error{"download failed: " + err.Error()}, http.StatusBadRequest)
+	}
+}
+
+// archiveResponseWriter is an io.Writer that tracks whether an archive has
+// been written to the response.
+type archiveResponseWriter struct {
+	w       io.Writer
+	written bool
+}
+
+// Write implements the io.Writer interface.
+func (arw *archiveResponseWriter) Write(b []byte) (int, error) {
+	arw.written = true
+	return arw.w.Write(b)
+}
+
 // renterDownloadAsyncHandler handles the API call to download a file asynchronously.
 func (api *API) renterDownloadAsyncHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	req.ParseForm()
@@ -1234,6 +1764,12 @@ func (api *API) renterStreamHandler(w http.ResponseWriter, req *http.Request, ps
 		return
 	}
 	defer streamer.Close()
+	// Use the content type from the user metadata of the file if it was set.
+	// Otherwise ServeContent detects the content type itself.
+	userMetadata, err := api.renter.FileUserMetadata(siaPath)
+	if err == nil && userMetadata[modules.UserMetadataContentType] != "" {
+		w.Header().Set("Content-Type", userMetadata[modules.UserMetadataContentType])
+	}
 	http.ServeContent(w, req, fileName, time.Time{}, streamer)
 }
 
@@ -1256,12 +1792,31 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 			return
 		}
 	}
+	// Check whether a small file should be packed
+	pack := false
+	if p := req.FormValue("pack"); p != "" {
+		pack, err = strconv.ParseBool(p)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'pack' parameter: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 	// Parse the erasure coder.
 	ec, err := parseErasureCodingParameters(req.FormValue("datapieces"), req.FormValue("paritypieces"))
 	if err != nil {
 		WriteError(w, Error{"unable to parse erasure code settings" + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	if pack && ec != nil {
+		WriteError(w, Error{"can't provide erasure code settings when packing a file"}, http.StatusBadRequest)
+		return
+	}
+	// Parse the user metadata.
+	userMetadata, err := parseUserMetadata(req.FormValue("usermetadata"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'usermetadata' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 
 	// Call the renter to upload the file.
 	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
@@ -1270,10 +1825,12 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 		return
 	}
 	err = api.renter.Upload(modules.FileUploadParams{
-		Source:      source,
-		SiaPath:     siaPath,
-		ErasureCode: ec,
-		Force:       force,
+		Source:       source,
+		SiaPath:      siaPath,
+		ErasureCode:  ec,
+		Force:        force,
+		Pack:         pack,
+		UserMetadata: userMetadata,
 	})
 	if err != nil {
 		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
@@ -1319,6 +1876,12 @@ func (api *API) renterUploadStreamHandler(w http.ResponseWriter, req *http.Reque
 		WriteError(w, Error{"can't provide erasure code settings when doing a repair"}, http.StatusBadRequest)
 		return
 	}
+	// Parse the user metadata.
+	userMetadata, err := parseUserMetadata(queryForm.Get("usermetadata"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse 'usermetadata' parameter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 
 	// Call the renter to upload the file.
 	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
@@ -1327,10 +1890,11 @@ func (api *API) renterUploadStreamHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 	up := modules.FileUploadParams{
-		SiaPath:     siaPath,
-		ErasureCode: ec,
-		Force:       force,
-		Repair:      repair,
+		SiaPath:      siaPath,
+		ErasureCode:  ec,
+		Force:        force,
+		Repair:       repair,
+		UserMetadata: userMetadata,
 	}
 	err = api.renter.UploadStreamFromReader(up, req.Body)
 	if err != nil {
@@ -710,6 +710,8 @@ func TestRenterHandlerGetAndPost(t *testing.T) {
 	allowanceValues.Set("period", testPeriod)
 	allowanceValues.Set("renewwindow", testRenewWindow)
 	allowanceValues.Set("hosts", fmt.Sprint(modules.DefaultAllowance.Hosts))
+	allowanceValues.Set("maxstorageprice", "1000000")
+	allowanceValues.Set("maxcontractprice", testFunds)
 	if err = st.stdPostAPI("/renter", allowanceValues); err != nil {
 		t.Fatal(err)
 	}
@@ -741,6 +743,23 @@ func TestRenterHandlerGetAndPost(t *testing.T) {
 	if got := get.Settings.Allowance.RenewWindow; got != expectedRenewWindow {
 		t.Fatalf("expected renew window to be %v; got %v", expectedRenewWindow, got)
 	}
+	// Check the renter's price caps.
+	if got := get.Settings.Allowance.MaxStoragePrice; got.Cmp(types.NewCurrency64(1000000)) != 0 {
+		t.Fatalf("expected max storage price to be 1000000; got %v", got)
+	}
+	if got := get.Settings.Allowance.MaxContractPrice; got.Cmp(expectedFunds) != 0 {
+		t.Fatalf("expected max contract price to be %v; got %v", expectedFunds, got)
+	}
+	if !get.Settings.Allowance.MaxUploadBandwidthPrice.IsZero() {
+		t.Fatal("expected max upload bandwidth price to be unset")
+	}
+	// Try an invalid price cap.
+	allowanceValues.Set("maxstorageprice", "foo")
+	err = st.stdPostAPI("/renter", allowanceValues)
+	if err == nil || !strings.Contains(err.Error(), "unable to parse maxstorageprice") {
+		t.Errorf("expected error to begin with 'unable to parse maxstorageprice'; got %v", err)
+	}
+	allowanceValues.Del("maxstorageprice")
 	// Try an invalid period string.
 	allowanceValues.Set("period", "-1")
 	err = st.stdPostAPI("/renter", allowanceValues)
@@ -1012,6 +1031,172 @@ func TestRenterHandlerDelete(t *testing.T) {
 	}
 }
 
+// TestRenterSyncDirs checks that local directories can be synced through the
+// /renter/syncdirs endpoints.
+func TestRenterSyncDirs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Create a local directory with a file that isn't modified anymore.
+	localDir := filepath.Join(st.dir, "sync")
+	if err := os.MkdirAll(localDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(localDir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative paths are rejected.
+	values := url.Values{}
+	values.Set("localpath", "sync")
+	values.Set("siapath", "synced")
+	if err := st.stdPostAPI("/renter/syncdirs/add", values); err == nil {
+		t.Fatal("relative local path was accepted")
+	}
+	values.Set("localpath", localDir)
+	if err := st.stdPostAPI("/renter/syncdirs/add", values); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file should be uploaded.
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		var rsd RenterSyncDirsGET
+		if err := st.getAPI("/renter/syncdirs", &rsd); err != nil {
+			return err
+		}
+		if len(rsd.SyncDirs) != 1 {
+			return fmt.Errorf("expected 1 sync dir, got %v", len(rsd.SyncDirs))
+		}
+		if sd := rsd.SyncDirs[0]; sd.LocalPath != localDir || sd.SiaPath.String() != "synced" || sd.Uploads != 1 {
+			return fmt.Errorf("unexpected sync dir: %+v", sd)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFile
+	if err := st.getAPI("/renter/file/synced/test.dat", &rf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove the sync dir.
+	values = url.Values{}
+	values.Set("siapath", "synced")
+	if err := st.stdPostAPI("/renter/syncdirs/remove", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdPostAPI("/renter/syncdirs/remove", values); err == nil {
+		t.Fatal("removing an unknown sync dir should fail")
+	}
+	var rsd RenterSyncDirsGET
+	if err := st.getAPI("/renter/syncdirs", &rsd); err != nil {
+		t.Fatal(err)
+	}
+	if len(rsd.SyncDirs) != 0 {
+		t.Fatal("sync dir was not removed")
+	}
+}
+
+// TestRenterShareLoad tests sharing files with /renter/share and
+// /renter/shareascii and loading them with /renter/load and
+// /renter/loadascii.
+func TestRenterShareLoad(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Upload a file.
+	path := filepath.Join(st.dir, "test.dat")
+	if err := createRandFile(path, 1024); err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{}
+	values.Set("source", path)
+	if err := st.stdPostAPI("/renter/upload/shared/test.dat", values); err != nil {
+		t.Fatal(err)
+	}
+
+	// Share the file to disk. The destination must be an absolute path
+	// ending in .sia.
+	dst := filepath.Join(st.dir, "shared.sia")
+	query := url.Values{}
+	query.Set("siapaths", "shared/test.dat")
+	query.Set("password", "foo")
+	query.Set("destination", "shared.sia")
+	if err := st.stdGetAPI("/renter/share?" + query.Encode()); err == nil {
+		t.Fatal("relative destination was accepted")
+	}
+	query.Set("destination", filepath.Join(st.dir, "shared.txt"))
+	if err := st.stdGetAPI("/renter/share?" + query.Encode()); err == nil {
+		t.Fatal("destination without .sia suffix was accepted")
+	}
+	query.Set("destination", dst)
+	if err := st.stdGetAPI("/renter/share?" + query.Encode()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete the file and load the share to restore it.
+	if err := st.stdPostAPI("/renter/delete/shared/test.dat", url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+	values = url.Values{}
+	values.Set("source", dst)
+	var rl RenterLoad
+	if err := st.postAPI("/renter/load", values, &rl); err == nil {
+		t.Fatal("encrypted share was loaded without a password")
+	}
+	values.Set("password", "foo")
+	if err := st.postAPI("/renter/load", values, &rl); err != nil {
+		t.Fatal(err)
+	}
+	if len(rl.FilesAdded) != 1 || rl.FilesAdded[0] != "shared/test.dat" {
+		t.Fatal("unexpected files added:", rl.FilesAdded)
+	}
+	var rf RenterFile
+	if err := st.getAPI("/renter/file/shared/test.dat", &rf); err != nil {
+		t.Fatal(err)
+	}
+	if rf.File.Filesize != 1024 {
+		t.Fatal("unexpected filesize of loaded file:", rf.File.Filesize)
+	}
+
+	// Share and load the file as a string. The renter already has the file,
+	// so it is not added again.
+	query.Del("destination")
+	query.Del("password")
+	var rsa RenterShareASCII
+	if err := st.getAPI("/renter/shareascii?"+query.Encode(), &rsa); err != nil {
+		t.Fatal(err)
+	}
+	values = url.Values{}
+	values.Set("asciisia", rsa.ASCIIsia)
+	if err := st.postAPI("/renter/loadascii", values, &rl); err != nil {
+		t.Fatal(err)
+	}
+	if len(rl.FilesAdded) != 1 || rl.FilesAdded[0] != "shared/test.dat" {
+		t.Fatal("unexpected files added:", rl.FilesAdded)
+	}
+}
+
 // Tests that the /renter/upload call checks for relative paths.
 func TestRenterRelativePathErrorUpload(t *testing.T) {
 	if testing.Short() {
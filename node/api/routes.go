@@ -8,134 +8,161 @@ import (
 	"github.com/julienschmidt/httprouter"
 
 	"gitlab.com/NebulousLabs/Sia/build"
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
 )
 
 // buildHttpRoutes sets up and returns an * httprouter.Router.
 // it connected the Router to the given api using the required
 // parameters: requiredUserAgent and requiredPassword
 func (api *API) buildHTTPRoutes() {
-	router := httprouter.New()
 	requiredPassword := api.requiredPassword
+	router := newSpecRouter(requiredPassword, api.siadConfig)
 	requiredUserAgent := api.requiredUserAgent
 
 	router.NotFound = http.HandlerFunc(UnrecognizedCallHandler)
 	router.RedirectTrailingSlash = false
 
 	// Daemon API Calls
-	router.GET("/daemon/constants", api.daemonConstantsHandler)
-	router.GET("/daemon/version", api.daemonVersionHandler)
-	router.GET("/daemon/update", api.daemonUpdateHandlerGET)
+	router.GET("/daemon/constants", api.daemonConstantsHandler, returns(SiaConstants{}))
+	router.GET("/daemon/version", api.daemonVersionHandler, returns(DaemonVersion{}))
+	router.GET("/daemon/update", api.daemonUpdateHandlerGET, returns(UpdateInfo{}))
 	router.POST("/daemon/update", api.daemonUpdateHandlerPOST)
-	router.GET("/daemon/stop", RequirePassword(api.daemonStopHandler, requiredPassword))
-	router.GET("/daemon/settings", api.daemonSettingsHandlerGET)
-	router.POST("/daemon/settings", api.daemonSettingsHandlerPOST)
+	router.GET("/daemon/stop", api.daemonStopHandler, requires(modules.APIScopeDaemonAdmin))
+	router.GET("/daemon/openapi.json", api.daemonOpenAPIHandler, returns(OpenAPIDocument{}))
+	router.GET("/daemon/settings", api.daemonSettingsHandlerGET, returns(DaemonSettingsGet{}))
+	router.POST("/daemon/settings", api.daemonSettingsHandlerPOST, params("maxdownloadspeed", "maxuploadspeed"))
+	router.GET("/daemon/tokens", api.daemonTokensHandlerGET, requires(modules.APIScopeDaemonAdmin), returns(DaemonTokensGET{}))
+	router.POST("/daemon/tokens", api.daemonTokensHandlerPOST, requires(modules.APIScopeDaemonAdmin), params("name", "scopes", "expires"), returns(DaemonTokensPOST{}))
+	router.POST("/daemon/tokens/:id/revoke", api.daemonTokensRevokeHandler, requires(modules.APIScopeDaemonAdmin))
 
 	// Consensus API Calls
 	if api.cs != nil {
-		router.GET("/consensus", api.consensusHandler)
-		router.GET("/consensus/blocks", api.consensusBlocksHandler)
-		router.POST("/consensus/validate/transactionset", api.consensusValidateTransactionsetHandler)
+		router.GET("/consensus", api.consensusHandler, returns(ConsensusGET{}))
+		router.GET("/consensus/blocks", api.consensusBlocksHandler, params("id", "height"), returns(ConsensusBlocksGet{}))
+		router.POST("/consensus/validate/transactionset", api.consensusValidateTransactionsetHandler, accepts([]types.Transaction{}))
 	}
 
 	// Explorer API Calls
 	if api.explorer != nil {
-		router.GET("/explorer", api.explorerHandler)
-		router.GET("/explorer/blocks/:height", api.explorerBlocksHandler)
-		router.GET("/explorer/hashes/:hash", api.explorerHashHandler)
+		router.GET("/explorer", api.explorerHandler, returns(ExplorerGET{}))
+		router.GET("/explorer/addresses/:address", api.explorerAddressHandler, returns(ExplorerAddressGET{}))
+		router.GET("/explorer/addresses/:address/outputs", api.explorerAddressOutputsHandler, returns(ExplorerAddressOutputsGET{}))
+		router.GET("/explorer/blocks/:height", api.explorerBlocksHandler, returns(ExplorerBlockGET{}))
+		router.GET("/explorer/hashes/:hash", api.explorerHashHandler, returns(ExplorerHashGET{}))
+		router.GET("/explorer/market", api.explorerMarketHandler, params("start", "end", "step"), returns(ExplorerMarketGET{}))
+		router.GET("/explorer/richlist", api.explorerRichListHandler, params("type", "offset", "limit"), returns(ExplorerRichListGET{}))
 	}
 
 	// Gateway API Calls
 	if api.gateway != nil {
-		router.GET("/gateway", api.gatewayHandlerGET)
-		router.POST("/gateway", api.gatewayHandlerPOST)
-		router.POST("/gateway/connect/:netaddress", RequirePassword(api.gatewayConnectHandler, requiredPassword))
-		router.POST("/gateway/disconnect/:netaddress", RequirePassword(api.gatewayDisconnectHandler, requiredPassword))
+		router.GET("/gateway", api.gatewayHandlerGET, returns(GatewayGET{}))
+		router.POST("/gateway", api.gatewayHandlerPOST, params("maxdownloadspeed", "maxuploadspeed"))
+		router.POST("/gateway/connect/:netaddress", api.gatewayConnectHandler, requires(modules.APIScopeGatewayAdmin))
+		router.POST("/gateway/disconnect/:netaddress", api.gatewayDisconnectHandler, requires(modules.APIScopeGatewayAdmin))
 	}
 
 	// Host API Calls
 	if api.host != nil {
 		// Calls directly pertaining to the host.
-		router.GET("/host", api.hostHandlerGET)                                                   // Get the host status.
-		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
-		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
-		router.GET("/host/contracts", api.hostContractInfoHandler)                                // Get info about contracts.
-		router.GET("/host/estimatescore", api.hostEstimateScoreGET)
+		router.GET("/host", api.hostHandlerGET, returns(HostGET{}))                                                       // Get the host status.
+		router.POST("/host", api.hostHandlerPOST, requires(modules.APIScopeHostAdmin), params(hostSettingsParams...))     // Change the settings of the host.
+		router.POST("/host/announce", api.hostAnnounceHandler, requires(modules.APIScopeHostAdmin), params("netaddress")) // Announce the host to the network.
+		router.GET("/host/contracts", api.hostContractInfoHandler, returns(ContractInfoGET{}))                            // Get info about contracts.
+		router.GET("/host/estimatescore", api.hostEstimateScoreGET, params(hostSettingsParams...), returns(HostEstimateScoreGET{}))
 
 		// Calls pertaining to the storage manager that the host uses.
-		router.GET("/host/storage", api.storageHandler)
-		router.POST("/host/storage/folders/add", RequirePassword(api.storageFoldersAddHandler, requiredPassword))
-		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
-		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
-		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
+		router.GET("/host/storage", api.storageHandler, returns(StorageGET{}))
+		router.POST("/host/storage/folders/add", api.storageFoldersAddHandler, requires(modules.APIScopeHostAdmin), params("path", "size"))
+		router.POST("/host/storage/folders/remove", api.storageFoldersRemoveHandler, requires(modules.APIScopeHostAdmin), params("path", "force"))
+		router.POST("/host/storage/folders/resize", api.storageFoldersResizeHandler, requires(modules.APIScopeHostAdmin), params("path", "newsize"))
+		router.POST("/host/storage/sectors/delete/:merkleroot", api.storageSectorsDeleteHandler, requires(modules.APIScopeHostAdmin))
 	}
 
 	// Miner API Calls
 	if api.miner != nil {
-		router.GET("/miner", api.minerHandler)
-		router.GET("/miner/header", RequirePassword(api.minerHeaderHandlerGET, requiredPassword))
-		router.POST("/miner/header", RequirePassword(api.minerHeaderHandlerPOST, requiredPassword))
-		router.GET("/miner/start", RequirePassword(api.minerStartHandler, requiredPassword))
-		router.GET("/miner/stop", RequirePassword(api.minerStopHandler, requiredPassword))
+		router.GET("/miner", api.minerHandler, returns(MinerGET{}))
+		router.POST("/miner", api.minerHandlerPOST, requires(modules.APIScopeMinerAdmin), params("payouts", "coinbasedata"))
+		router.GET("/miner/header", api.minerHeaderHandlerGET, requires(modules.APIScopeMinerAdmin), returnsData("application/octet-stream"))
+		router.POST("/miner/header", api.minerHeaderHandlerPOST, requires(modules.APIScopeMinerAdmin), acceptsData("application/octet-stream"))
+		router.GET("/miner/start", api.minerStartHandler, requires(modules.APIScopeMinerAdmin))
+		router.GET("/miner/stop", api.minerStopHandler, requires(modules.APIScopeMinerAdmin))
+		router.GET("/miner/stratum", api.minerStratumHandlerGET, returns(MinerStratumGET{}))
+		router.POST("/miner/stratum/start", api.minerStratumStartHandler, requires(modules.APIScopeMinerAdmin), params("address", "difficulty"))
+		router.POST("/miner/stratum/stop", api.minerStratumStopHandler, requires(modules.APIScopeMinerAdmin))
 	}
 
 	// Renter API Calls
 	if api.renter != nil {
-		router.GET("/renter", api.renterHandlerGET)
-		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
-		router.GET("/renter/backups", RequirePassword(api.renterBackupsHandlerGET, requiredPassword))
-		router.POST("/renter/backups/create", RequirePassword(api.renterBackupsCreateHandlerPOST, requiredPassword))
-		router.POST("/renter/backups/restore", RequirePassword(api.renterBackupsRestoreHandlerGET, requiredPassword))
-		router.POST("/renter/contract/cancel", RequirePassword(api.renterContractCancelHandler, requiredPassword))
-		router.GET("/renter/contracts", api.renterContractsHandler)
-		router.GET("/renter/downloads", api.renterDownloadsHandler)
-		router.POST("/renter/downloads/clear", RequirePassword(api.renterClearDownloadsHandler, requiredPassword))
-		router.GET("/renter/files", api.renterFilesHandler)
-		router.GET("/renter/file/*siapath", api.renterFileHandlerGET)
-		router.POST("/renter/file/*siapath", RequirePassword(api.renterFileHandlerPOST, requiredPassword))
-		router.GET("/renter/prices", api.renterPricesHandler)
-		router.POST("/renter/recoveryscan", RequirePassword(api.renterRecoveryScanHandlerPOST, requiredPassword))
-		router.GET("/renter/recoveryscan", api.renterRecoveryScanHandlerGET)
-
-		// TODO: re-enable these routes once the new .sia format has been
-		// standardized and implemented.
-		// router.POST("/renter/load", RequirePassword(api.renterLoadHandler, requiredPassword))
-		// router.POST("/renter/loadascii", RequirePassword(api.renterLoadAsciiHandler, requiredPassword))
-		// router.GET("/renter/share", RequirePassword(api.renterShareHandler, requiredPassword))
-		// router.GET("/renter/shareascii", RequirePassword(api.renterShareAsciiHandler, requiredPassword))
-
-		router.POST("/renter/delete/*siapath", RequirePassword(api.renterDeleteHandler, requiredPassword))
-		router.GET("/renter/download/*siapath", RequirePassword(api.renterDownloadHandler, requiredPassword))
-		router.POST("/renter/download/cancel", RequirePassword(api.renterCancelDownloadHandler, requiredPassword))
-		router.GET("/renter/downloadasync/*siapath", RequirePassword(api.renterDownloadAsyncHandler, requiredPassword))
-		router.POST("/renter/rename/*siapath", RequirePassword(api.renterRenameHandler, requiredPassword))
-		router.GET("/renter/stream/*siapath", api.renterStreamHandler)
-		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
-		router.POST("/renter/uploadstream/*siapath", RequirePassword(api.renterUploadStreamHandler, requiredPassword))
-		router.POST("/renter/validatesiapath/*siapath", RequirePassword(api.renterValidateSiaPathHandler, requiredPassword))
+		router.GET("/renter", api.renterHandlerGET, returns(RenterGET{}))
+		router.POST("/renter", api.renterHandlerPOST, requires(modules.APIScopeRenterWrite), params("checkforipviolation", "funds", "hosts", "period", "renewwindow", "expectedstorage", "expectedupload", "expecteddownload", "expectedredundancy", "maxrpcprice", "maxcontractprice", "maxdownloadbandwidthprice", "maxsectoraccessprice", "maxstorageprice", "maxuploadbandwidthprice", "maxdownloadspeed", "maxuploadspeed"))
+		router.GET("/renter/backups", api.renterBackupsHandlerGET, requires(modules.APIScopeRenterRead), params("host"), returns(RenterBackupsGET{}))
+		router.POST("/renter/backups/create", api.renterBackupsCreateHandlerPOST, requires(modules.APIScopeRenterWrite), params("name"))
+		router.POST("/renter/backups/restore", api.renterBackupsRestoreHandlerGET, requires(modules.APIScopeRenterWrite), params("name"))
+		router.POST("/renter/contract/cancel", api.renterContractCancelHandler, requires(modules.APIScopeRenterWrite), params("id"))
+		router.GET("/renter/contracts", api.renterContractsHandler, params("disabled", "expired", "inactive", "recoverable"), returns(RenterContracts{}))
+		router.GET("/renter/drain", api.renterDrainHandlerGET, returns(RenterDrainsGET{}))
+		router.POST("/renter/drain", api.renterDrainHandlerPOST, requires(modules.APIScopeRenterWrite), params("hostkey"))
+		router.GET("/renter/downloads", api.renterDownloadsHandler, returns(RenterDownloadQueue{}))
+		router.POST("/renter/downloads/clear", api.renterClearDownloadsHandler, requires(modules.APIScopeRenterWrite), params("before", "after"))
+		router.GET("/renter/files", api.renterFilesHandler, params("cached"), returns(RenterFiles{}))
+		router.GET("/renter/file/*siapath", api.renterFileHandlerGET, returns(RenterFile{}))
+		router.POST("/renter/file/*siapath", api.renterFileHandlerPOST, requires(modules.APIScopeRenterWrite), params("trackingpath", "stuck", "usermetadata"))
+		router.GET("/renter/find", api.renterFindHandler, params("tag", "usermetadata"), returns(RenterFiles{}))
+		router.GET("/renter/prices", api.renterPricesHandler, params("funds", "hosts", "period", "renewwindow"), returns(RenterPricesGET{}))
+		router.POST("/renter/recoveryscan", api.renterRecoveryScanHandlerPOST, requires(modules.APIScopeRenterWrite))
+		router.GET("/renter/recoveryscan", api.renterRecoveryScanHandlerGET, returns(RenterRecoveryStatusGET{}))
+		router.GET("/renter/syncdirs", api.renterSyncDirsHandlerGET, requires(modules.APIScopeRenterRead), returns(RenterSyncDirsGET{}))
+		router.POST("/renter/syncdirs/add", api.renterSyncDirsAddHandlerPOST, requires(modules.APIScopeRenterWrite), params("localpath", "siapath"))
+		router.POST("/renter/syncdirs/remove", api.renterSyncDirsRemoveHandlerPOST, requires(modules.APIScopeRenterWrite), params("siapath"))
+
+		router.POST("/renter/load", api.renterLoadHandler, requires(modules.APIScopeRenterWrite), params("source", "password"), returns(RenterLoad{}))
+		router.POST("/renter/loadascii", api.renterLoadASCIIHandler, requires(modules.APIScopeRenterWrite), params("asciisia", "password"), returns(RenterLoad{}))
+		router.GET("/renter/share", api.renterShareHandler, requires(modules.APIScopeRenterWrite), params("siapaths", "destination", "password"))
+		router.GET("/renter/shareascii", api.renterShareASCIIHandler, requires(modules.APIScopeRenterWrite), params("siapaths", "password"), returns(RenterShareASCII{}))
+
+		router.POST("/renter/delete/*siapath", api.renterDeleteHandler, requires(modules.APIScopeRenterWrite))
+		router.GET("/renter/download/*siapath", api.renterDownloadHandler, requires(modules.APIScopeRenterWrite), params("destination", "format", "httpresp", "length", "offset"))
+		router.POST("/renter/download/cancel", api.renterCancelDownloadHandler, requires(modules.APIScopeRenterWrite), params("id"))
+		router.GET("/renter/downloadasync/*siapath", api.renterDownloadAsyncHandler, requires(modules.APIScopeRenterWrite), params("destination", "length", "offset"))
+		router.POST("/renter/rename/*siapath", api.renterRenameHandler, requires(modules.APIScopeRenterWrite), params("newsiapath"))
+		router.POST("/renter/copy/*siapath", api.renterCopyHandler, requires(modules.APIScopeRenterWrite), params("newsiapath"))
+		router.GET("/renter/stream/*siapath", api.renterStreamHandler, returnsData("application/octet-stream"))
+		router.POST("/renter/upload/*siapath", api.renterUploadHandler, requires(modules.APIScopeRenterWrite), params("source", "datapieces", "paritypieces", "force", "pack", "usermetadata"))
+		router.POST("/renter/uploadstream/*siapath", api.renterUploadStreamHandler, requires(modules.APIScopeRenterWrite), params("datapieces", "paritypieces", "force", "repair", "usermetadata"), acceptsData("application/octet-stream"))
+		router.POST("/renter/validatesiapath/*siapath", api.renterValidateSiaPathHandler, requires(modules.APIScopeRenterWrite))
+		router.GET("/renter/versions/*siapath", api.renterVersionsHandler, returns(RenterFileVersions{}))
+		router.POST("/renter/restoreversion/*siapath", api.renterRestoreVersionHandler, requires(modules.APIScopeRenterWrite), params("id"))
+		router.GET("/renter/versionpolicies", api.renterVersionPoliciesHandler, returns(RenterVersionPoliciesGET{}))
+		router.POST("/renter/versionpolicy/*siapath", api.renterVersionPolicyHandler, requires(modules.APIScopeRenterWrite), params("maxversions", "maxage", "remove"))
+		router.GET("/renter/trash", api.renterTrashHandlerGET, returns(RenterTrashGET{}))
+		router.POST("/renter/trash/restore", api.renterTrashRestoreHandler, requires(modules.APIScopeRenterWrite), params("id"))
+		router.POST("/renter/trash/purge", api.renterTrashPurgeHandler, requires(modules.APIScopeRenterWrite), params("id", "all"))
+		router.POST("/renter/trash/retention", api.renterTrashRetentionHandler, requires(modules.APIScopeRenterWrite), params("retention"))
 
 		// Directory endpoints
-		router.POST("/renter/dir/*siapath", RequirePassword(api.renterDirHandlerPOST, requiredPassword))
-		router.GET("/renter/dir/*siapath", api.renterDirHandlerGET)
+		router.POST("/renter/dir/*siapath", api.renterDirHandlerPOST, requires(modules.APIScopeRenterWrite), params("action", "newsiapath"))
+		router.GET("/renter/dir/*siapath", api.renterDirHandlerGET, returns(RenterDirectory{}))
 
 		// HostDB endpoints.
-		router.GET("/hostdb", api.hostdbHandler)
-		router.GET("/hostdb/active", api.hostdbActiveHandler)
-		router.GET("/hostdb/all", api.hostdbAllHandler)
-		router.GET("/hostdb/hosts/:pubkey", api.hostdbHostsHandler)
-		router.GET("/hostdb/filtermode", api.hostdbFilterModeHandlerGET)
-		router.POST("/hostdb/filtermode", RequirePassword(api.hostdbFilterModeHandlerPOST, requiredPassword))
+		router.GET("/hostdb", api.hostdbHandler, returns(HostdbGet{}))
+		router.GET("/hostdb/active", api.hostdbActiveHandler, params("numhosts"), returns(HostdbActiveGET{}))
+		router.GET("/hostdb/all", api.hostdbAllHandler, returns(HostdbAllGET{}))
+		router.GET("/hostdb/hosts/:pubkey", api.hostdbHostsHandler, returns(HostdbHostsGET{}))
+		router.GET("/hostdb/filtermode", api.hostdbFilterModeHandlerGET, returns(HostdbFilterModeGET{}))
+		router.POST("/hostdb/filtermode", api.hostdbFilterModeHandlerPOST, requires(modules.APIScopeRenterWrite), accepts(HostdbFilterModePOST{}))
 
 		// Deprecated endpoints.
-		router.POST("/renter/backup", RequirePassword(api.renterBackupHandlerPOST, requiredPassword))
-		router.POST("/renter/recoverbackup", RequirePassword(api.renterLoadBackupHandlerPOST, requiredPassword))
+		router.POST("/renter/backup", api.renterBackupHandlerPOST, requires(modules.APIScopeRenterWrite), params("destination"))
+		router.POST("/renter/recoverbackup", api.renterLoadBackupHandlerPOST, requires(modules.APIScopeRenterWrite), params("source"))
 	}
 
 	// Transaction pool API Calls
 	if api.tpool != nil {
-		router.GET("/tpool/fee", api.tpoolFeeHandlerGET)
-		router.GET("/tpool/raw/:id", api.tpoolRawHandlerGET)
-		router.POST("/tpool/raw", api.tpoolRawHandlerPOST)
-		router.GET("/tpool/confirmed/:id", api.tpoolConfirmedGET)
+		router.GET("/tpool/fee", api.tpoolFeeHandlerGET, returns(TpoolFeeGET{}))
+		router.GET("/tpool/raw/:id", api.tpoolRawHandlerGET, returns(TpoolRawGET{}))
+		router.POST("/tpool/raw", api.tpoolRawHandlerPOST, params("parents", "transaction"))
+		router.GET("/tpool/confirmed/:id", api.tpoolConfirmedGET, returns(TpoolConfirmedGET{}))
 
 		// TODO: re-enable this route once the transaction pool API has been finalized
 		//router.GET("/transactionpool/transactions", api.transactionpoolTransactionsHandler)
@@ -143,38 +170,46 @@ func (api *API) buildHTTPRoutes() {
 
 	// Wallet API Calls
 	if api.wallet != nil {
-		router.GET("/wallet", api.walletHandler)
-		router.POST("/wallet/033x", RequirePassword(api.wallet033xHandler, requiredPassword))
-		router.GET("/wallet/address", RequirePassword(api.walletAddressHandler, requiredPassword))
-		router.GET("/wallet/addresses", api.walletAddressesHandler)
-		router.GET("/wallet/seedaddrs", api.walletSeedAddressesHandler)
-		router.GET("/wallet/backup", RequirePassword(api.walletBackupHandler, requiredPassword))
-		router.POST("/wallet/init", RequirePassword(api.walletInitHandler, requiredPassword))
-		router.POST("/wallet/init/seed", RequirePassword(api.walletInitSeedHandler, requiredPassword))
-		router.POST("/wallet/lock", RequirePassword(api.walletLockHandler, requiredPassword))
-		router.POST("/wallet/seed", RequirePassword(api.walletSeedHandler, requiredPassword))
-		router.GET("/wallet/seeds", RequirePassword(api.walletSeedsHandler, requiredPassword))
-		router.POST("/wallet/siacoins", RequirePassword(api.walletSiacoinsHandler, requiredPassword))
-		router.POST("/wallet/siafunds", RequirePassword(api.walletSiafundsHandler, requiredPassword))
-		router.POST("/wallet/siagkey", RequirePassword(api.walletSiagkeyHandler, requiredPassword))
-		router.POST("/wallet/sweep/seed", RequirePassword(api.walletSweepSeedHandler, requiredPassword))
-		router.GET("/wallet/transaction/:id", api.walletTransactionHandler)
-		router.GET("/wallet/transactions", api.walletTransactionsHandler)
-		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler)
-		router.GET("/wallet/verify/address/:addr", api.walletVerifyAddressHandler)
-		router.POST("/wallet/unlock", RequirePassword(api.walletUnlockHandler, requiredPassword))
-		router.POST("/wallet/changepassword", RequirePassword(api.walletChangePasswordHandler, requiredPassword))
-		router.GET("/wallet/unlockconditions/:addr", RequirePassword(api.walletUnlockConditionsHandlerGET, requiredPassword))
-		router.POST("/wallet/unlockconditions", RequirePassword(api.walletUnlockConditionsHandlerPOST, requiredPassword))
-		router.GET("/wallet/unspent", RequirePassword(api.walletUnspentHandler, requiredPassword))
-		router.POST("/wallet/sign", RequirePassword(api.walletSignHandler, requiredPassword))
-		router.GET("/wallet/watch", RequirePassword(api.walletWatchHandlerGET, requiredPassword))
-		router.POST("/wallet/watch", RequirePassword(api.walletWatchHandlerPOST, requiredPassword))
+		router.GET("/wallet", api.walletHandler, returns(WalletGET{}))
+		router.POST("/wallet/033x", api.wallet033xHandler, requires(modules.APIScopeWalletAdmin), params("source", "encryptionpassword"))
+		router.GET("/wallet/accounts", api.walletAccountsHandlerGET, requires(modules.APIScopeWalletRead), returns(WalletAccountsGET{}))
+		router.GET("/wallet/accounts/:name", api.walletAccountHandlerGET, requires(modules.APIScopeWalletRead), returns(modules.WalletAccount{}))
+		router.POST("/wallet/accounts/:name", api.walletAccountHandlerPOST, requires(modules.APIScopeWalletSpend))
+		router.GET("/wallet/accounts/:name/address", api.walletAccountAddressHandler, requires(modules.APIScopeWalletSpend), returns(WalletAddressGET{}))
+		router.POST("/wallet/accounts/:name/assign", api.walletAccountAssignHandler, requires(modules.APIScopeWalletSpend), accepts(WalletAccountAssignPOST{}))
+		router.POST("/wallet/accounts/:name/delete", api.walletAccountDeleteHandler, requires(modules.APIScopeWalletSpend))
+		router.GET("/wallet/address", api.walletAddressHandler, requires(modules.APIScopeWalletRead), returns(WalletAddressGET{}))
+		router.GET("/wallet/addresses", api.walletAddressesHandler, returns(WalletAddressesGET{}))
+		router.GET("/wallet/seedaddrs", api.walletSeedAddressesHandler, params("count"), returns(WalletAddressesGET{}))
+		router.GET("/wallet/backup", api.walletBackupHandler, requires(modules.APIScopeWalletAdmin), params("destination"))
+		router.POST("/wallet/init", api.walletInitHandler, requires(modules.APIScopeWalletAdmin), params("encryptionpassword", "dictionary", "force"), returns(WalletInitPOST{}))
+		router.POST("/wallet/init/seed", api.walletInitSeedHandler, requires(modules.APIScopeWalletAdmin), params("encryptionpassword", "dictionary", "seed", "force"))
+		router.POST("/wallet/lock", api.walletLockHandler, requires(modules.APIScopeWalletAdmin))
+		router.POST("/wallet/seed", api.walletSeedHandler, requires(modules.APIScopeWalletAdmin), params("encryptionpassword", "dictionary", "seed"))
+		router.GET("/wallet/seeds", api.walletSeedsHandler, requires(modules.APIScopeWalletAdmin), params("dictionary"), returns(WalletSeedsGET{}))
+		router.POST("/wallet/siacoins", api.walletSiacoinsHandler, requires(modules.APIScopeWalletSpend), params("amount", "destination", "outputs", "account"), returns(WalletSiacoinsPOST{}))
+		router.POST("/wallet/siafunds", api.walletSiafundsHandler, requires(modules.APIScopeWalletSpend), params("amount", "destination"), returns(WalletSiafundsPOST{}))
+		router.POST("/wallet/siagkey", api.walletSiagkeyHandler, requires(modules.APIScopeWalletAdmin), params("encryptionpassword", "keyfiles"))
+		router.POST("/wallet/sweep/seed", api.walletSweepSeedHandler, requires(modules.APIScopeWalletSpend), params("dictionary", "seed"), returns(WalletSweepPOST{}))
+		router.GET("/wallet/transaction/:id", api.walletTransactionHandler, returns(WalletTransactionGETid{}))
+		router.GET("/wallet/transactions", api.walletTransactionsHandler, params("startheight", "endheight"), returns(WalletTransactionsGET{}))
+		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler, returns(WalletTransactionsGETaddr{}))
+		router.document("GET", "/wallet/transactions/export", params("startheight", "endheight", "starttime", "endtime", "addresses", "account", "format"), returns(WalletTransactionsExportGET{}))
+		router.GET("/wallet/verify/address/:addr", api.walletVerifyAddressHandler, returns(WalletVerifyAddressGET{}))
+		router.POST("/wallet/unlock", api.walletUnlockHandler, requires(modules.APIScopeWalletAdmin), params("encryptionpassword"))
+		router.POST("/wallet/changepassword", api.walletChangePasswordHandler, requires(modules.APIScopeWalletAdmin), params("encryptionpassword", "newpassword"))
+		router.GET("/wallet/unlockconditions/:addr", api.walletUnlockConditionsHandlerGET, requires(modules.APIScopeWalletRead), returns(WalletUnlockConditionsGET{}))
+		router.POST("/wallet/unlockconditions", api.walletUnlockConditionsHandlerPOST, requires(modules.APIScopeWalletSpend), accepts(WalletUnlockConditionsPOSTParams{}))
+		router.GET("/wallet/unspent", api.walletUnspentHandler, requires(modules.APIScopeWalletRead), returns(WalletUnspentGET{}))
+		router.POST("/wallet/sign", api.walletSignHandler, requires(modules.APIScopeWalletSpend), accepts(WalletSignPOSTParams{}), returns(WalletSignPOSTResp{}))
+		router.GET("/wallet/watch", api.walletWatchHandlerGET, requires(modules.APIScopeWalletRead), returns(WalletWatchGET{}))
+		router.POST("/wallet/watch", api.walletWatchHandlerPOST, requires(modules.APIScopeWalletSpend), accepts(WalletWatchPOST{}))
 	}
 
 	// Apply UserAgent middleware and return the Router
 	api.routerMu.Lock()
 	api.router = cleanCloseHandler(RequireUserAgent(router, requiredUserAgent))
+	api.routes = router.routes
 	api.routerMu.Unlock()
 	return
 }
@@ -241,6 +276,48 @@ func RequirePassword(h httprouter.Handle, password string) httprouter.Handle {
 	}
 }
 
+// RequireScope is middleware that requires a request to authenticate with
+// either the API password or an API token that has the provided scope. Tokens
+// can be passed as the password of HTTP basic auth or as a bearer token.
+// Usernames are ignored. Empty passwords indicate no authentication is
+// required.
+func RequireScope(h httprouter.Handle, password string, cfg *modules.SiadConfig, scope string) httprouter.Handle {
+	// An empty password is equivalent to no password.
+	if password == "" {
+		return h
+	}
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		secret, ok := apiSecret(req)
+		if ok && secret == password {
+			h(w, req, ps)
+			return
+		}
+		token, valid := cfg.AuthenticateAPIToken(secret)
+		if !ok || !valid {
+			w.Header().Set("WWW-Authenticate", "Basic realm=\"SiaAPI\"")
+			WriteError(w, Error{"API authentication failed."}, http.StatusUnauthorized)
+			return
+		}
+		if !token.HasScope(scope) {
+			WriteError(w, Error{"API token does not have the required scope " + scope}, http.StatusForbidden)
+			return
+		}
+		h(w, req, ps)
+	}
+}
+
+// apiSecret returns the password or token a request authenticates with.
+func apiSecret(req *http.Request) (string, bool) {
+	if _, pass, ok := req.BasicAuth(); ok {
+		return pass, true
+	}
+	auth := req.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer "), true
+	}
+	return "", false
+}
+
 // isUnrestricted checks if a request may bypass the useragent check.
 func isUnrestricted(req *http.Request) bool {
 	return strings.HasPrefix(req.URL.Path, "/renter/stream/")
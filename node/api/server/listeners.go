@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/NebulousLabs/errors"
+)
+
+var (
+	// errNoListener is returned when neither a TCP address nor a Unix socket
+	// is provided for the API.
+	errNoListener = errors.New("the API needs either a TCP address or a Unix socket to listen on")
+
+	// errNoTLSKey is returned when only one of the TLS certificate and key is
+	// provided.
+	errNoTLSKey = errors.New("the TLS certificate and key must be provided together")
+
+	// errClientCAWithoutTLS is returned when a client CA is provided without
+	// enabling TLS.
+	errClientCAWithoutTLS = errors.New("client certificate authentication requires TLS")
+)
+
+// ListenerParams contains the parameters of the listeners of the API server
+// in addition to the plain TCP listener.
+type ListenerParams struct {
+	// TLSCertFile and TLSKeyFile are the PEM encoded certificate and key of
+	// the API. If they are set, the TCP listener only accepts TLS
+	// connections.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TLSClientCAFile is a PEM encoded set of certificate authorities. If it
+	// is set, clients have to authenticate with a certificate signed by one
+	// of them.
+	TLSClientCAFile string
+
+	// UnixSocket is the path of a Unix domain socket the API listens on in
+	// addition to the TCP address. UnixSocketMode are the file permissions of
+	// the socket, 0600 if not set.
+	UnixSocket     string
+	UnixSocketMode os.FileMode
+}
+
+// TLSEnabled returns true if the TCP listener only accepts TLS connections.
+func (lp ListenerParams) TLSEnabled() bool {
+	return lp.TLSCertFile != "" || lp.TLSKeyFile != ""
+}
+
+// tlsConfig creates the TLS config of the API from the listener params.
+func (lp ListenerParams) tlsConfig() (*tls.Config, error) {
+	if lp.TLSCertFile == "" || lp.TLSKeyFile == "" {
+		return nil, errNoTLSKey
+	}
+	cert, err := tls.LoadX509KeyPair(lp.TLSCertFile, lp.TLSKeyFile)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to load TLS certificate")
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if lp.TLSClientCAFile == "" {
+		return cfg, nil
+	}
+	pem, err := ioutil.ReadFile(lp.TLSClientCAFile)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to read TLS client CA")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("TLS client CA file does not contain any certificates")
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// listenTCP creates the TCP listener of the API, wrapped in TLS if enabled.
+func (lp ListenerParams) listenTCP(addr string) (net.Listener, error) {
+	if !lp.TLSEnabled() && lp.TLSClientCAFile != "" {
+		return nil, errClientCAWithoutTLS
+	}
+	var cfg *tls.Config
+	if lp.TLSEnabled() {
+		var err error
+		if cfg, err = lp.tlsConfig(); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		l = tls.NewListener(l, cfg)
+	}
+	return l, nil
+}
+
+// unixListener is a Unix socket listener that removes its socket when it is
+// closed. The socket is created under a temporary path, so the listener
+// can't unlink it on its own.
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+// Addr returns the final address of the socket.
+func (l unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+// Close closes the listener and removes its socket.
+func (l unixListener) Close() error {
+	err := l.UnixListener.Close()
+	if rmErr := os.Remove(l.path); rmErr != nil && !os.IsNotExist(rmErr) {
+		err = errors.Compose(err, rmErr)
+	}
+	return err
+}
+
+// listenUnix creates the Unix socket listener of the API. A socket left
+// behind by a previous run is removed first. The socket is created within a
+// private directory and only moved to its final path after its permissions
+// were set, so that it is never reachable with the permissions of the umask.
+func (lp ListenerParams) listenUnix() (net.Listener, error) {
+	if fi, err := os.Stat(lp.UnixSocket); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New("unix socket path exists and is not a socket: " + lp.UnixSocket)
+		}
+		if err := os.Remove(lp.UnixSocket); err != nil {
+			return nil, errors.AddContext(err, "unable to remove stale unix socket")
+		}
+	}
+	dir, err := ioutil.TempDir(filepath.Dir(lp.UnixSocket), ".sock")
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create directory for unix socket")
+	}
+	defer os.RemoveAll(dir)
+	tmpPath := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	ul := l.(*net.UnixListener)
+	ul.SetUnlinkOnClose(false)
+	mode := lp.UnixSocketMode
+	if mode == 0 {
+		mode = 0600
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return nil, errors.Compose(err, ul.Close())
+	}
+	if err := os.Rename(tmpPath, lp.UnixSocket); err != nil {
+		return nil, errors.Compose(err, ul.Close())
+	}
+	return unixListener{ul, lp.UnixSocket}, nil
+}
@@ -31,6 +31,7 @@ type Server struct {
 	apiServer         *http.Server
 	done              chan struct{}
 	listener          net.Listener
+	unixListener      net.Listener
 	node              *node.Node
 	requiredUserAgent string
 	serveErr          error
@@ -39,12 +40,39 @@ type Server struct {
 	closeMu sync.Mutex
 }
 
-// serve listens for and handles API calls. It is a blocking function.
+// serve listens for and handles API calls on all listeners of the server. It
+// is a blocking function.
 func (srv *Server) serve() error {
+	var listeners []net.Listener
+	if srv.listener != nil {
+		listeners = append(listeners, srv.listener)
+	}
+	if srv.unixListener != nil {
+		listeners = append(listeners, srv.unixListener)
+	}
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- srv.serveListener(l)
+		}(l)
+	}
+	// Return the first error that is not caused by shutting down the server.
+	var err error
+	for range listeners {
+		if serveErr := <-errs; err == nil || err == http.ErrServerClosed {
+			err = serveErr
+		}
+	}
+	return err
+}
+
+// serveListener handles API calls on a single listener. It is a blocking
+// function.
+func (srv *Server) serveListener(l net.Listener) error {
 	// The server will run until an error is encountered or the listener is
 	// closed, via either the Close method or by signal handling.  Closing the
 	// listener will result in the benign error handled below.
-	err := srv.apiServer.Serve(srv.listener)
+	err := srv.apiServer.Serve(l)
 	if err != nil && !strings.HasSuffix(err.Error(), "use of closed network connection") {
 		return err
 	}
@@ -69,11 +97,24 @@ func (srv *Server) Close() error {
 	return errors.AddContext(err, "error while closing server")
 }
 
-// APIAddress returns the underlying node's api address
+// APIAddress returns the underlying node's api address. It is empty if the
+// server only listens on a Unix socket.
 func (srv *Server) APIAddress() string {
+	if srv.listener == nil {
+		return ""
+	}
 	return srv.listener.Addr().String()
 }
 
+// APISocket returns the path of the Unix socket the server listens on, if
+// any.
+func (srv *Server) APISocket() string {
+	if srv.unixListener == nil {
+		return ""
+	}
+	return srv.unixListener.Addr().String()
+}
+
 // GatewayAddress returns the underlying node's gateway address
 func (srv *Server) GatewayAddress() modules.NetAddress {
 	return srv.node.Gateway.Address()
@@ -128,10 +169,34 @@ func (srv *Server) Unlock(password string) error {
 // authentication sends passwords in plaintext and should therefore only be
 // used if the APIaddr is localhost.
 func New(APIaddr string, requiredUserAgent string, requiredPassword string, nodeParams node.NodeParams) (*Server, error) {
-	// Create the server listener.
-	listener, err := net.Listen("tcp", APIaddr)
-	if err != nil {
-		return nil, err
+	return NewWithListeners(APIaddr, requiredUserAgent, requiredPassword, ListenerParams{}, nodeParams)
+}
+
+// NewWithListeners creates a new API server like New, but also configures TLS
+// for the TCP listener and an additional Unix socket listener according to the
+// listener params. An empty APIaddr disables the TCP listener, in which case a
+// Unix socket is required.
+func NewWithListeners(APIaddr string, requiredUserAgent string, requiredPassword string, lp ListenerParams, nodeParams node.NodeParams) (*Server, error) {
+	if APIaddr == "" && lp.UnixSocket == "" {
+		return nil, errNoListener
+	}
+	// Create the server listeners.
+	var listener, unixListener net.Listener
+	var err error
+	if APIaddr != "" {
+		listener, err = lp.listenTCP(APIaddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if lp.UnixSocket != "" {
+		unixListener, err = lp.listenUnix()
+		if err != nil {
+			if listener != nil {
+				err = errors.Compose(err, listener.Close())
+			}
+			return nil, err
+		}
 	}
 
 	// Load the config file.
@@ -166,6 +231,7 @@ func New(APIaddr string, requiredUserAgent string, requiredPassword string, node
 		},
 		done:              make(chan struct{}),
 		listener:          listener,
+		unixListener:      unixListener,
 		requiredUserAgent: requiredUserAgent,
 		Dir:               nodeParams.Dir,
 	}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/url"
 	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
 )
 
 // TestExplorerPreset checks that the default configuration for the explorer is
@@ -132,3 +136,107 @@ func TestAuthentication(t *testing.T) {
 		t.Fatal("authenticated API call failed with the correct password")
 	}
 }
+
+// TestAPITokenScopes checks that API tokens can be used instead of the API
+// password and that they only grant access to the routes of their scopes.
+func TestAPITokenScopes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createAuthenticatedServerTester(t.Name(), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	// Create a token using the password.
+	addr := "http://" + st.server.listener.Addr().String()
+	values := url.Values{}
+	values.Set("name", "dashboard")
+	values.Set("scopes", modules.APIScopeWalletRead)
+	resp, err := HttpPOSTAuthenticated(addr+"/daemon/tokens", values.Encode(), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dtp DaemonTokensPOST
+	err = json.NewDecoder(resp.Body).Decode(&dtp)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dtp.Secret == "" || dtp.Token.Name != "dashboard" {
+		t.Fatal("unexpected token", dtp)
+	}
+
+	// The token grants access to the routes of its scope, as basic auth
+	// password and as bearer token.
+	resp, err = HttpGETAuthenticated(addr+"/wallet/address", dtp.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if non2xx(resp.StatusCode) {
+		t.Fatal("API call failed with a token of the required scope", resp.StatusCode)
+	}
+	req, err := http.NewRequest("GET", addr+"/wallet/address", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "Sia-Agent")
+	req.Header.Set("Authorization", "Bearer "+dtp.Secret)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if non2xx(resp.StatusCode) {
+		t.Fatal("API call failed with a bearer token of the required scope", resp.StatusCode)
+	}
+
+	// Routes of other scopes are forbidden.
+	resp, err = HttpGETAuthenticated(addr+"/wallet/seeds", dtp.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("expected 403 for a token without the required scope, got", resp.StatusCode)
+	}
+	resp, err = HttpGETAuthenticated(addr+"/daemon/tokens", dtp.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("expected 403 for a token without the required scope, got", resp.StatusCode)
+	}
+
+	// Routes that change the accounts of the wallet require the spend scope.
+	resp, err = HttpPOSTAuthenticated(addr+"/wallet/accounts/foo", "", dtp.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("expected 403 for creating an account without the spend scope, got", resp.StatusCode)
+	}
+	resp, err = HttpGETAuthenticated(addr+"/wallet/accounts/foo/address", dtp.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatal("expected 403 for generating an account address without the spend scope, got", resp.StatusCode)
+	}
+
+	// Revoked tokens are rejected.
+	resp, err = HttpPOSTAuthenticated(addr+"/daemon/tokens/"+dtp.Token.ID+"/revoke", "", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if non2xx(resp.StatusCode) {
+		t.Fatal("unable to revoke token", resp.StatusCode)
+	}
+	resp, err = HttpGETAuthenticated(addr+"/wallet/address", dtp.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal("expected 401 for a revoked token, got", resp.StatusCode)
+	}
+}
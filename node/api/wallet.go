@@ -1,13 +1,16 @@
 package api
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
@@ -17,6 +20,18 @@ import (
 	"gitlab.com/NebulousLabs/Sia/types"
 )
 
+// ledgerCSVHeader is the header row of a ledger exported as CSV.
+var ledgerCSVHeader = []string{
+	"transactionid",
+	"confirmationheight",
+	"confirmationtime",
+	"category",
+	"incomingvalue",
+	"outgoingvalue",
+	"fees",
+	"netvalue",
+}
+
 type (
 	// WalletGET contains general information about the wallet.
 	WalletGET struct {
@@ -35,6 +50,21 @@ type (
 		DustThreshold types.Currency `json:"dustthreshold"`
 	}
 
+	// WalletAccountsGET contains the accounts of the wallet returned by a GET
+	// call to /wallet/accounts.
+	WalletAccountsGET struct {
+		Accounts []modules.WalletAccount `json:"accounts"`
+	}
+
+	// WalletAccountAssignPOST contains the addresses that are assigned to an
+	// account by a POST call to /wallet/accounts/:name/assign. Either a set of
+	// addresses or a range [start, end) of primary seed indices is assigned.
+	WalletAccountAssignPOST struct {
+		Addresses []types.UnlockHash `json:"addresses"`
+		Start     uint64             `json:"start"`
+		End       uint64             `json:"end"`
+	}
+
 	// WalletAddressGET contains an address returned by a GET call to
 	// /wallet/address.
 	WalletAddressGET struct {
@@ -104,6 +134,12 @@ type (
 		UnconfirmedTransactions []modules.ProcessedTransaction `json:"unconfirmedtransactions"`
 	}
 
+	// WalletTransactionsExportGET contains the ledger entries returned by a
+	// GET call to /wallet/transactions/export.
+	WalletTransactionsExportGET struct {
+		Entries []modules.WalletLedgerEntry `json:"entries"`
+	}
+
 	// WalletTransactionsGETaddr contains the set of wallet transactions
 	// relevant to the input address provided in the call to
 	// /wallet/transaction/:addr
@@ -242,6 +278,86 @@ func (api *API) wallet033xHandler(w http.ResponseWriter, req *http.Request, _ ht
 	WriteError(w, Error{modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
 }
 
+// walletAccountsHandlerGET handles GET calls to /wallet/accounts.
+func (api *API) walletAccountsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	accounts, err := api.wallet.Accounts()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/accounts: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAccountsGET{
+		Accounts: accounts,
+	})
+}
+
+// walletAccountHandlerGET handles GET calls to /wallet/accounts/:name.
+func (api *API) walletAccountHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	account, err := api.wallet.AccountBalance(ps.ByName("name"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/accounts/:name: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, account)
+}
+
+// walletAccountHandlerPOST handles POST calls to /wallet/accounts/:name,
+// which create a new account.
+func (api *API) walletAccountHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if err := api.wallet.CreateAccount(ps.ByName("name")); err != nil {
+		WriteError(w, Error{"error when calling /wallet/accounts/:name: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletAccountAddressHandler handles API calls to
+// /wallet/accounts/:name/address.
+func (api *API) walletAccountAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	unlockConditions, err := api.wallet.AccountAddress(ps.ByName("name"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/accounts/:name/address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAddressGET{
+		Address: unlockConditions.UnlockHash(),
+	})
+}
+
+// walletAccountAssignHandler handles API calls to
+// /wallet/accounts/:name/assign.
+func (api *API) walletAccountAssignHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var wap WalletAccountAssignPOST
+	err := json.NewDecoder(req.Body).Decode(&wap)
+	if err != nil {
+		WriteError(w, Error{"invalid parameters: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	name := ps.ByName("name")
+	if len(wap.Addresses) != 0 && wap.End != 0 {
+		WriteError(w, Error{"cannot assign both addresses and a seed range"}, http.StatusBadRequest)
+		return
+	} else if wap.End != 0 {
+		err = api.wallet.AssignAccountSeedRange(name, wap.Start, wap.End)
+	} else {
+		err = api.wallet.AssignAccountAddresses(name, wap.Addresses)
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to assign addresses: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletAccountDeleteHandler handles API calls to
+// /wallet/accounts/:name/delete.
+func (api *API) walletAccountDeleteHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if err := api.wallet.DeleteAccount(ps.ByName("name")); err != nil {
+		WriteError(w, Error{"error when calling /wallet/accounts/:name/delete: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // walletAddressHandler handles API calls to /wallet/address.
 func (api *API) walletAddressHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	unlockConditions, err := api.wallet.NextAddress()
@@ -489,6 +605,10 @@ func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request,
 			WriteError(w, Error{"cannot supply both 'outputs' and single amount+destination pair"}, http.StatusInternalServerError)
 			return
 		}
+		if req.FormValue("account") != "" {
+			WriteError(w, Error{"cannot supply both 'outputs' and 'account'"}, http.StatusBadRequest)
+			return
+		}
 
 		var outputs []types.SiacoinOutput
 		err := json.Unmarshal([]byte(req.FormValue("outputs")), &outputs)
@@ -514,7 +634,11 @@ func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request,
 			return
 		}
 
-		txns, err = api.wallet.SendSiacoins(amount, dest)
+		if account := req.FormValue("account"); account != "" {
+			txns, err = api.wallet.SendSiacoinsFromAccount(account, amount, dest)
+		} else {
+			txns, err = api.wallet.SendSiacoins(amount, dest)
+		}
 		if err != nil {
 			WriteError(w, Error{"error when calling /wallet/siacoins: " + err.Error()}, http.StatusInternalServerError)
 			return
@@ -652,6 +776,13 @@ func (api *API) walletTransactionsHandler(w http.ResponseWriter, req *http.Reque
 // walletTransactionsAddrHandler handles API calls to
 // /wallet/transactions/:addr.
 func (api *API) walletTransactionsAddrHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// The router can't distinguish /wallet/transactions/export from an
+	// address.
+	if ps.ByName("addr") == "export" {
+		api.walletTransactionsExportHandler(w, req, ps)
+		return
+	}
+
 	// Parse the address being input.
 	jsonAddr := "\"" + ps.ByName("addr") + "\""
 	var addr types.UnlockHash
@@ -677,6 +808,63 @@ func (api *API) walletTransactionsAddrHandler(w http.ResponseWriter, req *http.R
 	})
 }
 
+// walletTransactionsExportHandler handles API calls to
+// /wallet/transactions/export.
+func (api *API) walletTransactionsExportHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var params modules.WalletLedgerParams
+	for _, p := range []struct {
+		name string
+		val  *uint64
+	}{
+		{"startheight", (*uint64)(&params.StartHeight)},
+		{"endheight", (*uint64)(&params.EndHeight)},
+		{"starttime", (*uint64)(&params.StartTime)},
+		{"endtime", (*uint64)(&params.EndTime)},
+	} {
+		if str := req.FormValue(p.name); str != "" {
+			val, err := strconv.ParseUint(str, 10, 64)
+			if err != nil {
+				WriteError(w, Error{fmt.Sprintf("parsing integer value for parameter `%v` failed: %v", p.name, err)}, http.StatusBadRequest)
+				return
+			}
+			*p.val = val
+		}
+	}
+	if addrs := req.FormValue("addresses"); addrs != "" {
+		for _, str := range strings.Split(addrs, ",") {
+			addr, err := scanAddress(str)
+			if err != nil {
+				WriteError(w, Error{"could not read address from call to /wallet/transactions/export: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			params.Addresses = append(params.Addresses, addr)
+		}
+	}
+	params.Account = req.FormValue("account")
+
+	format := req.FormValue("format")
+	if format != "" && format != "json" && format != "csv" {
+		WriteError(w, Error{"format must be 'json' or 'csv'"}, http.StatusBadRequest)
+		return
+	}
+	entries, err := api.wallet.Ledger(params)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/transactions/export: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"ledger.csv\"")
+		if err := WriteLedgerCSV(w, entries); err != nil {
+			WriteError(w, Error{"failed to write ledger: " + err.Error()}, http.StatusInternalServerError)
+		}
+		return
+	}
+	WriteJSON(w, WalletTransactionsExportGET{
+		Entries: entries,
+	})
+}
+
 // walletUnlockHandler handles API calls to /wallet/unlock.
 func (api *API) walletUnlockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	potentialKeys := encryptionKeys(req.FormValue("encryptionpassword"))
@@ -822,3 +1010,29 @@ func (api *API) walletWatchHandlerPOST(w http.ResponseWriter, req *http.Request,
 	}
 	WriteSuccess(w)
 }
+
+// WriteLedgerCSV writes a set of ledger entries to w as CSV, including a
+// header row. Values are given in hastings and times in RFC 3339 format.
+func WriteLedgerCSV(w io.Writer, entries []modules.WalletLedgerEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(ledgerCSVHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err := cw.Write([]string{
+			e.TransactionID.String(),
+			strconv.FormatUint(uint64(e.ConfirmationHeight), 10),
+			time.Unix(int64(e.ConfirmationTimestamp), 0).UTC().Format(time.RFC3339),
+			e.Category,
+			e.IncomingValue.String(),
+			e.OutgoingValue.String(),
+			e.Fees.String(),
+			e.NetValue,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
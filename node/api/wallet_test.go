@@ -1,6 +1,8 @@
 package api
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -1776,3 +1778,34 @@ func TestWalletTransactionsGetAddr(t *testing.T) {
 		t.Errorf("There should be exactly 0 unconfirmed and 1 confirmed related txns")
 	}
 }
+
+// TestWriteLedgerCSV checks that ledgers are written as CSV with a header
+// row.
+func TestWriteLedgerCSV(t *testing.T) {
+	entries := []modules.WalletLedgerEntry{{
+		ConfirmationHeight:    5,
+		ConfirmationTimestamp: 1546300800,
+		Category:              modules.LedgerCategoryTransfer,
+		IncomingValue:         types.NewCurrency64(10),
+		OutgoingValue:         types.NewCurrency64(20),
+		Fees:                  types.NewCurrency64(1),
+		NetValue:              "-10",
+	}}
+	var buf bytes.Buffer
+	if err := WriteLedgerCSV(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(records[0]) != len(ledgerCSVHeader) {
+		t.Fatal("unexpected records", records)
+	}
+	expected := []string{entries[0].TransactionID.String(), "5", "2019-01-01T00:00:00Z", "transfer", "10", "20", "1", "-10"}
+	for i := range expected {
+		if records[1][i] != expected[i] {
+			t.Fatalf("expected %v in column %v, got %v", expected[i], ledgerCSVHeader[i], records[1][i])
+		}
+	}
+}
@@ -92,6 +92,11 @@ type NodeParams struct {
 	HostStorage uint64
 	RPCAddress  string
 
+	// LightConsensus creates a light consensus set, which only tracks the
+	// headers of the blockchain and the transactions relevant to the wallet.
+	// It can not be used with the explorer, host, miner or renter.
+	LightConsensus bool
+
 	// Initialize node from existing seed.
 	PrimarySeed string
 
@@ -213,6 +218,9 @@ func (n *Node) Close() (err error) {
 // themselves).
 func New(params NodeParams) (*Node, error) {
 	dir := params.Dir
+	if params.LightConsensus && (params.CreateExplorer || params.CreateHost || params.CreateMiner || params.CreateRenter) {
+		return nil, errors.New("a light consensus set can only be used with the gateway, transaction pool and wallet")
+	}
 
 	numModules := params.NumModules()
 	i := 1
@@ -252,6 +260,9 @@ func New(params NodeParams) (*Node, error) {
 		}
 		i++
 		printfRelease("(%d/%d) Loading consensus...\n", i, numModules)
+		if params.LightConsensus {
+			return consensus.NewLight(g, filepath.Join(dir, modules.LightConsensusDir))
+		}
 		return consensus.New(g, params.Bootstrap, filepath.Join(dir, modules.ConsensusDir))
 	}()
 	if err != nil {
@@ -66,6 +66,20 @@ var (
 		CreateTransactionPool: true,
 		CreateWallet:          true,
 	}
+	// LightWalletTemplate is a template for a Sia node that has a functioning
+	// wallet backed by a light consensus set. The node has a wallet and all
+	// dependencies, but no other modules.
+	LightWalletTemplate = NodeParams{
+		CreateConsensusSet:    true,
+		CreateExplorer:        false,
+		CreateGateway:         true,
+		CreateHost:            false,
+		CreateMiner:           false,
+		CreateRenter:          false,
+		CreateTransactionPool: true,
+		CreateWallet:          true,
+		LightConsensus:        true,
+	}
 )
 
 // AllModules returns an AllModulesTemplate filled out with the provided dir.
@@ -102,3 +116,10 @@ func Wallet(dir string) NodeParams {
 	template.Dir = dir
 	return template
 }
+
+// LightWallet returns a LightWalletTemplate filled out with the provided dir.
+func LightWallet(dir string) NodeParams {
+	template := LightWalletTemplate
+	template.Dir = dir
+	return template
+}
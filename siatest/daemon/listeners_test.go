@@ -0,0 +1,158 @@
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/Sia/node"
+	"gitlab.com/NebulousLabs/Sia/node/api/client"
+	"gitlab.com/NebulousLabs/Sia/node/api/server"
+)
+
+// writeTestCert creates a certificate signed by parent, or a self-signed CA if
+// parent is nil, and writes it and its key to dir. The paths of the
+// certificate and key are returned.
+func writeTestCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.Subject = pkix.Name{CommonName: name}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, certPath, keyPath
+}
+
+// TestDaemonListeners checks that the API can be served over TLS with client
+// certificates and over a unix socket.
+func TestDaemonListeners(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testDir := daemonTestDir(t.Name())
+
+	// Create a CA that signs both the server and the client certificate.
+	ca, caKey, caPath, _ := writeTestCert(t, testDir, "ca", nil, nil, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	_, _, serverCert, serverKey := writeTestCert(t, testDir, "server", ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	})
+	_, _, clientCert, clientKey := writeTestCert(t, testDir, "client", ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	})
+
+	// Create a server that requires client certificates and also listens on
+	// a unix socket.
+	password := "password"
+	socket := filepath.Join(testDir, "siad.sock")
+	lp := server.ListenerParams{
+		TLSCertFile:     serverCert,
+		TLSKeyFile:      serverKey,
+		TLSClientCAFile: caPath,
+		UnixSocket:      socket,
+		UnixSocketMode:  0660,
+	}
+	srv, err := server.NewWithListeners("127.0.0.1:0", "Sia-Agent", password, lp, node.Gateway(filepath.Join(testDir, "node")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := srv.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Plain HTTP requests are rejected.
+	c := client.New(srv.APIAddress())
+	c.Password = password
+	if _, err := c.DaemonVersionGet(); err == nil {
+		t.Fatal("plain HTTP request succeeded on a TLS listener")
+	}
+
+	// TLS requests without a client certificate are rejected.
+	c.TLSConfig, err = client.NewTLSConfig(caPath, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DaemonVersionGet(); err == nil {
+		t.Fatal("TLS request without a client certificate succeeded")
+	}
+
+	// TLS requests with a client certificate succeed.
+	c.TLSConfig, err = client.NewTLSConfig(caPath, clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DaemonVersionGet(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DaemonTokensGet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests over the unix socket succeed and the socket has the right
+	// permissions.
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0660 {
+		t.Fatalf("expected socket mode %v, got %v", os.FileMode(0660), fi.Mode().Perm())
+	}
+	if tmpDirs, _ := filepath.Glob(filepath.Join(testDir, ".sock*")); len(tmpDirs) != 0 {
+		t.Fatal("temporary socket directory wasn't removed", tmpDirs)
+	}
+	sc := client.Client{UnixSocket: srv.APISocket(), Password: password}
+	if _, err := sc.DaemonVersionGet(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.DaemonTokensGet(); err != nil {
+		t.Fatal(err)
+	}
+}
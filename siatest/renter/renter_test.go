@@ -1,9 +1,14 @@
 package renter
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/url"
@@ -26,6 +31,7 @@ import (
 	"gitlab.com/NebulousLabs/Sia/modules/renter"
 	"gitlab.com/NebulousLabs/Sia/modules/renter/contractor"
 	"gitlab.com/NebulousLabs/Sia/modules/renter/proto"
+	"gitlab.com/NebulousLabs/Sia/modules/renter/siafile"
 	"gitlab.com/NebulousLabs/Sia/node"
 	"gitlab.com/NebulousLabs/Sia/node/api"
 	"gitlab.com/NebulousLabs/Sia/persist"
@@ -115,6 +121,11 @@ func TestRenterTwo(t *testing.T) {
 		{"TestSingleFileGet", testSingleFileGet},
 		{"TestSiaFileTimestamps", testSiafileTimestamps},
 		{"TestZeroByteFile", testZeroByteFile},
+		{"TestDownloadDirArchive", testDownloadDirArchive},
+		{"TestPackedFiles", testPackedFiles},
+		{"TestFileVersions", testFileVersions},
+		{"TestTrash", testTrash},
+		{"TestCopyFile", testCopyFile},
 		{"TestUploadWithAndWithoutForceParameter", testUploadWithAndWithoutForceParameter},
 	}
 
@@ -3179,773 +3190,758 @@ func TestRenterSpendingReporting(t *testing.T) {
 	}
 }
 
-// testZeroByteFile tests uploading and downloading a 0 and 1 byte file
-func testZeroByteFile(t *testing.T, tg *siatest.TestGroup) {
-	if len(tg.Hosts()) < 2 {
-		t.Fatal("This test requires at least 2 hosts")
-	}
-	// Grab renter
+// testDownloadDirArchive tests downloading a directory and its subdirectories
+// as a tar and zip archive.
+func testDownloadDirArchive(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the renter.
 	r := tg.Renters()[0]
 
-	// Create 0 and 1 byte file
-	zeroByteFile := 0
-	oneByteFile := 1
-
-	// Test uploading 0 byte file
-	dataPieces := uint64(1)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-	redundancy := float64((dataPieces + parityPieces) / dataPieces)
-	_, zeroRF, err := r.UploadNewFile(zeroByteFile, dataPieces, parityPieces, false)
+	// Upload a file to a directory and to its subdirectory.
+	ld, err := r.FilesDir().CreateDir("archive" + persist.RandomSuffix())
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Get zerobyte file
-	rf, err := r.File(zeroRF)
+	sub, err := ld.CreateDir("sub")
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Check redundancy and upload progress
-	if rf.Redundancy != redundancy {
-		t.Fatalf("Expected redundancy to be %v, got %v", redundancy, rf.Redundancy)
-	}
-	if rf.UploadProgress != 100 {
-		t.Fatalf("Expected upload progress to be 100, got %v", rf.UploadProgress)
-	}
-	// Check health information
-	if rf.Health != 0 {
-		t.Fatalf("Expected health to be 0, got %v", rf.Health)
-	}
-	if rf.MaxHealth != 0 {
-		t.Fatalf("Expected max health to be 0, got %v", rf.MaxHealth)
-	}
-	if rf.MaxHealthPercent != 100 {
-		t.Fatalf("Expected max health percentage to be 100, got %v", rf.MaxHealthPercent)
+	lf1, err := ld.NewFile(100 + siatest.Fuzz())
+	if err != nil {
+		t.Fatal(err)
 	}
-	if rf.NumStuckChunks != 0 {
-		t.Fatalf("Expected number of stuck chunks to be 0, got %v", rf.NumStuckChunks)
+	lf2, err := sub.NewFile(int(modules.SectorSize) + siatest.Fuzz())
+	if err != nil {
+		t.Fatal(err)
 	}
-	if rf.Stuck {
-		t.Fatalf("Expected file not to be stuck")
+	for _, lf := range []*siatest.LocalFile{lf1, lf2} {
+		if _, err := r.UploadBlocking(lf, 1, 1, false); err != nil {
+			t.Fatal(err)
+		}
 	}
-	if rf.StuckHealth != 0 {
-		t.Fatalf("Expected stuck health to be 0, got %v", rf.StuckHealth)
+	expected := map[string]*siatest.LocalFile{
+		lf1.FileName():          lf1,
+		"sub/" + lf2.FileName(): lf2,
 	}
-	// Get the same file using the /renter/files endpoint with 'cached' set to
-	// true.
-	rfs, err := r.Files(true)
+	siaPath := r.SiaPath(ld.Path())
+
+	// Download the directory as a tar archive and check its contents.
+	archive, err := r.RenterDownloadArchiveGet(siaPath, modules.ArchiveFormatTar)
 	if err != nil {
 		t.Fatal(err)
 	}
-	var rf2 modules.FileInfo
-	var found bool
-	for _, file := range rfs {
-		if file.SiaPath.Equals(rf.SiaPath) {
-			found = true
-			rf2 = file
+	data, err := ioutil.ReadAll(archive)
+	if err := errors.Compose(err, archive.Close()); err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(bytes.NewReader(data))
+	found := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
 			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		fileData, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lf, ok := expected[header.Name]
+		if !ok {
+			t.Fatal("unexpected file in tar archive:", header.Name)
+		}
+		if err := lf.Equal(fileData); err != nil {
+			t.Fatal(err)
 		}
+		found++
 	}
-	if !found {
-		t.Fatal("couldn't find uploaded file using /renter/files endpoint")
+	if found != len(expected) {
+		t.Fatalf("expected %v files in tar archive, got %v", len(expected), found)
 	}
-	// Compare the fields again.
-	if rf.Redundancy != rf2.Redundancy {
-		t.Fatalf("Expected redundancy to be %v, got %v", rf.Redundancy, rf2.Redundancy)
+
+	// Download the directory as a zip archive and check its contents.
+	archive, err = r.RenterDownloadArchiveGet(siaPath, modules.ArchiveFormatZip)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if rf.UploadProgress != rf2.UploadProgress {
-		t.Fatalf("Expected upload progress to be %v, got %v", rf.UploadProgress, rf2.UploadProgress)
+	data, err = ioutil.ReadAll(archive)
+	if err := errors.Compose(err, archive.Close()); err != nil {
+		t.Fatal(err)
 	}
-	if rf.Health != rf2.Health {
-		t.Fatalf("Expected health to be %v, got %v", rf.Health, rf2.Health)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
 	}
-	if rf.MaxHealth != rf2.MaxHealth {
-		t.Fatalf("Expected max health to be %v, got %v", rf.MaxHealth, rf2.MaxHealth)
+	if len(zr.File) != len(expected) {
+		t.Fatalf("expected %v files in zip archive, got %v", len(expected), len(zr.File))
 	}
-	if rf.MaxHealthPercent != rf2.MaxHealthPercent {
-		t.Fatalf("Expected max health percentage to be %v, got %v", rf.MaxHealthPercent, rf2.MaxHealthPercent)
+	for _, file := range zr.File {
+		lf, ok := expected[file.Name]
+		if !ok {
+			t.Fatal("unexpected file in zip archive:", file.Name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileData, err := ioutil.ReadAll(rc)
+		if err := errors.Compose(err, rc.Close()); err != nil {
+			t.Fatal(err)
+		}
+		if err := lf.Equal(fileData); err != nil {
+			t.Fatal(err)
+		}
 	}
-	if rf.NumStuckChunks != rf2.NumStuckChunks {
-		t.Fatalf("Expected number of stuck chunks to be %v, got %v", rf.NumStuckChunks, rf2.NumStuckChunks)
+
+	// Unknown formats are rejected.
+	if _, err := r.RenterDownloadArchiveGet(siaPath, "rar"); err == nil {
+		t.Fatal("unknown archive format was accepted")
 	}
-	if rf.Stuck != rf2.Stuck {
-		t.Fatalf("Expected stuck to be %v, got %v", rf.Stuck, rf2.Stuck)
+}
+
+// testFileVersions tests that overwritten files are kept as old versions that
+// can be restored.
+func testFileVersions(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the renter.
+	r := tg.Renters()[0]
+
+	// Upload a file and overwrite it with a different file.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	size1, size2 := 100+siatest.Fuzz(), 200+siatest.Fuzz()
+	lf1, rf1, err := r.UploadNewFileBlocking(size1, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if rf.StuckHealth != rf2.StuckHealth {
-		t.Fatalf("Expected stuck health to be %v, got %v", rf.StuckHealth, rf2.StuckHealth)
+	lf2, err := r.FilesDir().NewFile(size2)
+	if err != nil {
+		t.Fatal(err)
 	}
+	siaPath := rf1.SiaPath()
 
-	// Test uploading 1 byte file
-	_, oneRF, err := r.UploadNewFileBlocking(oneByteFile, dataPieces, parityPieces, false)
+	// Keep a single version in the directory of the file, so that the next
+	// overwrite replaces the version that is checked below.
+	dir, err := siaPath.Dir()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Test downloading 0 byte file
-	_, err = r.DownloadToDisk(zeroRF, false)
+	if err := r.RenterSetVersionPolicyPost(dir, modules.VersionPolicy{MaxVersions: 1}); err != nil {
+		t.Fatal(err)
+	}
+	rf2, err := r.Upload(lf2, siaPath, dataPieces, parityPieces, true)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := r.WaitForUploadHealth(rf2); err != nil {
+		t.Fatal(err)
+	}
 
-	// Test downloading 1 byte file
-	_, err = r.DownloadToDisk(oneRF, false)
+	// The first file is kept as an old version and counts towards the
+	// version storage.
+	rv, err := r.RenterVersionsGet(siaPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-}
-
-// TestRenterFileChangeDuringDownload confirms that a download will continue and
-// succeed if the file is renamed or deleted after the download has started
-func TestRenterFileChangeDuringDownload(t *testing.T) {
-	if !build.VLONG {
-		t.SkipNow()
+	if len(rv.Versions) != 1 || rv.Versions[0].Filesize != uint64(size1) {
+		t.Fatalf("unexpected versions: %+v", rv.Versions)
 	}
-	t.Parallel()
-
-	// Create a testgroup,
-	groupParams := siatest.GroupParams{
-		Hosts:   2,
-		Renters: 1,
-		Miners:  1,
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	testDir := renterTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if rg.VersionStorage.NumVersions == 0 || rg.VersionStorage.Size < uint64(size1) {
+		t.Fatalf("unexpected version storage: %+v", rg.VersionStorage)
+	}
+	data, err := r.RenterDownloadHTTPResponseGet(siaPath, 0, 0)
 	if err != nil {
-		t.Fatal("Failed to create group: ", err)
+		t.Fatal(err)
+	}
+	if err := lf2.Equal(data); err != nil {
+		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
 
-	// Grab Renter and upload file
-	r := tg.Renters()[0]
-	dataPieces := uint64(1)
-	parityPieces := uint64(1)
-	chunkSize := int64(siatest.ChunkSize(dataPieces, crypto.TypeDefaultRenter))
-	fileSize := 3 * int(chunkSize)
-	_, rf1, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
-	if err != nil {
+	// Restore the old version.
+	if err := r.RenterRestoreVersionPost(siaPath, rv.Versions[0].ID); err != nil {
 		t.Fatal(err)
 	}
-	_, rf2, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	data, err = r.RenterDownloadHTTPResponseGet(siaPath, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, rf3, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err := lf1.Equal(data); err != nil {
+		t.Fatal(err)
+	}
+	rv, err = r.RenterVersionsGet(siaPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, rf4, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if len(rv.Versions) != 1 || rv.Versions[0].Filesize != uint64(size2) {
+		t.Fatalf("unexpected versions after restore: %+v", rv.Versions)
+	}
+
+	// Disabling versioning for the directory deletes the old version.
+	if err := r.RenterSetVersionPolicyPost(dir, modules.VersionPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	rv, err = r.RenterVersionsGet(siaPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, rf5, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if len(rv.Versions) != 0 {
+		t.Fatal("old version wasn't deleted", len(rv.Versions))
+	}
+	rvp, err := r.RenterVersionPoliciesGet()
 	if err != nil {
 		t.Fatal(err)
 	}
+	var found bool
+	for _, p := range rvp.Policies {
+		found = found || (p.SiaPath.Equals(dir) && p.MaxVersions == 0)
+	}
+	if !found {
+		t.Fatalf("version policy wasn't set: %+v", rvp.Policies)
+	}
+	if err := r.RenterRemoveVersionPolicyPost(dir); err != nil {
+		t.Fatal(err)
+	}
+}
 
-	// Set the bandwidth limit to 1 chunk per second.
-	if err := r.RenterPostRateLimit(chunkSize, chunkSize); err != nil {
+// testTrash tests that deleted files are moved to the trash, which is
+// enabled by default, and that they can be restored and purged.
+func testTrash(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the renter.
+	r := tg.Renters()[0]
+
+	// Start with an empty trash that uses a known retention and restore the
+	// default retention once the test is done.
+	rt, err := r.RenterTrashGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.Retention == 0 {
+		t.Fatal("trash should be enabled by default")
+	}
+	defaultRetention := rt.Retention
+	if err := r.RenterTrashPurgeAllPost(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenterTrashRetentionPost(time.Hour); err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err := r.RenterPostRateLimit(0, 0); err != nil {
+		if err := r.RenterTrashRetentionPost(defaultRetention); err != nil {
 			t.Fatal(err)
 		}
 	}()
 
-	// Create Wait group
-	wg := new(sync.WaitGroup)
-
-	// Test Renaming while Downloading and Streaming on 5 files.
-	wg.Add(1)
-	go renameDuringDownloadAndStream(r, rf1, t, wg, time.Second)
-	wg.Add(1)
-	go renameDuringDownloadAndStream(r, rf2, t, wg, time.Second)
-	wg.Add(1)
-	go renameDuringDownloadAndStream(r, rf3, t, wg, time.Second)
-	wg.Add(1)
-	go renameDuringDownloadAndStream(r, rf4, t, wg, time.Second)
-	wg.Add(1)
-	go renameDuringDownloadAndStream(r, rf5, t, wg, time.Second)
-	wg.Wait()
-
-	// Test Deleting while Downloading and Streaming
-	//
-	// Download the file
-	wg.Add(1)
-	go deleteDuringDownloadAndStream(r, rf1, t, wg, time.Second)
-	wg.Add(1)
-	go deleteDuringDownloadAndStream(r, rf2, t, wg, time.Second)
-	wg.Add(1)
-	go deleteDuringDownloadAndStream(r, rf3, t, wg, time.Second)
-	wg.Add(1)
-	go deleteDuringDownloadAndStream(r, rf4, t, wg, time.Second)
-	wg.Add(1)
-	go deleteDuringDownloadAndStream(r, rf5, t, wg, time.Second)
-
-	wg.Wait()
-}
-
-// testSetFileTrackingPath tests if changing the repairPath of a file works.
-func testSetFileTrackingPath(t *testing.T, tg *siatest.TestGroup) {
-	// Grab the first of the group's renters
-	renter := tg.Renters()[0]
-	// Check that we have enough hosts for this test.
-	if len(tg.Hosts()) < 2 {
-		t.Fatal("This test requires at least 2 hosts")
-	}
-	// Set fileSize and redundancy for upload
-	fileSize := int(modules.SectorSize)
+	// Upload a file and delete it.
 	dataPieces := uint64(1)
 	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-
-	// Upload file
-	localFile, remoteFile, err := renter.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	lf, rf, err := r.UploadNewFileBlocking(100+siatest.Fuzz(), dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Move the file to a new location.
-	if err := localFile.Move(); err != nil {
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
 		t.Fatal(err)
 	}
-	// Take down all the hosts.
-	numHosts := len(tg.Hosts())
-	for _, host := range tg.Hosts() {
-		if err := tg.RemoveNode(host); err != nil {
-			t.Fatal("Failed to shutdown host", err)
-		}
+	if _, err := r.RenterFileGet(rf.SiaPath()); err == nil {
+		t.Fatal("deleted file shouldn't exist")
 	}
-	// File should have 0 redundancy now.
-	if err := renter.WaitForDecreasingRedundancy(remoteFile, 0); err != nil {
-		t.Fatal("Redundancy isn't decreasing", err)
+	rt, err = r.RenterTrashGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Rename the repairPath to match the new location.
-	if err := renter.SetFileRepairPath(remoteFile, localFile); err != nil {
-		t.Fatal("Failed to change the repair path", err)
+	if rt.Retention != time.Hour || len(rt.Entries) != 1 || !rt.Entries[0].SiaPath.Equals(rf.SiaPath()) {
+		t.Fatalf("unexpected trash: %+v", rt)
 	}
-	// Create new hosts.
-	_, err = tg.AddNodeN(node.HostTemplate, numHosts)
+
+	// Restore the file and check that it can still be downloaded.
+	if err := r.RenterTrashRestorePost(rt.Entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.DownloadByStream(rf)
 	if err != nil {
-		t.Fatal("Failed to create a new host", err)
+		t.Fatal(err)
 	}
-	// We should reach full health again.
-	if err := renter.WaitForUploadHealth(remoteFile); err != nil {
-		t.Logf("numHosts: %v", len(tg.Hosts()))
-		t.Fatal("File wasn't repaired", err)
+	if err := lf.Equal(data); err != nil {
+		t.Fatal(err)
 	}
-	// We should be able to download
-	if _, err := renter.DownloadByStream(remoteFile); err != nil {
-		t.Fatal("Failed to download file", err)
+	if err := r.RenterTrashRestorePost(rt.Entries[0].ID); err == nil {
+		t.Fatal("restoring an entry twice should fail")
 	}
-	// Create a new file that is smaller than the first one.
-	smallFile, err := renter.FilesDir().NewFile(fileSize - 1)
+
+	// Delete the file again and purge it.
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	rt, err = r.RenterTrashGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Try to change the repairPath of the remote file again. This shouldn't
-	// work.
-	if err := renter.SetFileRepairPath(remoteFile, smallFile); err == nil {
-		t.Fatal("Changing repair path to file of different size shouldn't work")
+	if len(rt.Entries) != 1 {
+		t.Fatalf("unexpected trash: %+v", rt)
 	}
-	// Delete the small file and try again. This also shouldn't work.
-	if err := smallFile.Delete(); err != nil {
+	if err := r.RenterTrashPurgePost(rt.Entries[0].ID); err != nil {
 		t.Fatal(err)
 	}
-	if err := renter.SetFileRepairPath(remoteFile, smallFile); err == nil {
-		t.Fatal("Changing repair path to a nonexistent file shouldn't work")
-	}
-}
-
-// TestRenterFileContractIdentifier checks that the file contract's identifier
-// is set correctly when forming a contract and after renewing it.
-func TestRenterFileContractIdentifier(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
+	if err := r.RenterTrashRestorePost(rt.Entries[0].ID); err == nil {
+		t.Fatal("purged entry shouldn't be restorable")
 	}
-	t.Parallel()
 
-	// Create a testgroup, creating without renter so the renter's
-	// contract transactions can easily be obtained.
-	groupParams := siatest.GroupParams{
-		Hosts:  2,
-		Miners: 1,
-	}
-	testDir := renterTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	// Delete a directory and empty the trash.
+	rd, err := r.UploadNewDirectory()
 	if err != nil {
-		t.Fatal("Failed to create group: ", err)
+		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Add a Renter node
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	nodes, err := tg.AddNodes(renterParams)
-	if err != nil {
+	if err := r.RenterDirDeletePost(rd.SiaPath()); err != nil {
 		t.Fatal(err)
 	}
-	r := nodes[0]
-
-	rcg, err := r.RenterContractsGet()
+	rt, err = r.RenterTrashGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Get the endheight of the contracts.
-	eh := rcg.ActiveContracts[0].EndHeight
-
-	// Get the blockheight.
-	cg, err := tg.Hosts()[0].ConsensusGet()
+	if len(rt.Entries) != 1 || !rt.Entries[0].IsDir {
+		t.Fatalf("unexpected trash: %+v", rt)
+	}
+	if err := r.RenterTrashPurgeAllPost(); err != nil {
+		t.Fatal(err)
+	}
+	rt, err = r.RenterTrashGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	bh := cg.Height
-
-	// Mine blocks until we reach the endheight
-	m := tg.Miners()[0]
-	for i := 0; i < int(eh-bh); i++ {
-		if err := m.MineBlock(); err != nil {
-			t.Fatal(err)
-		}
+	if len(rt.Entries) != 0 {
+		t.Fatal("trash wasn't emptied", len(rt.Entries))
 	}
+}
 
-	// Confirm that the contracts got renewed.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		// Mine a block.
-		if err := m.MineBlock(); err != nil {
-			t.Fatal(err)
-		}
-		// Get the contracts from the renter.
-		rcg, err := r.RenterExpiredContractsGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		// We should have one contract for each host.
-		if len(rcg.ActiveContracts) != len(tg.Hosts()) {
-			return fmt.Errorf("expected %v active contracts but got %v",
-				len(tg.Hosts()), rcg.ActiveContracts)
-		}
-		// We should have one expired contract for each host.
-		if len(rcg.ExpiredContracts) != len(tg.Hosts()) {
-			return fmt.Errorf("expected %v expired contracts but got %v",
-				len(tg.Hosts()), len(rcg.ExpiredContracts))
-		}
-		return nil
-	})
+// testCopyFile tests that a copy of a file can be downloaded after the
+// original was deleted.
+func testCopyFile(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the renter.
+	r := tg.Renters()[0]
+
+	// Upload a file and copy it.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	lf, rf, err := r.UploadNewFileBlocking(100+siatest.Fuzz(), dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Get the transaction which are related to the renter since we started the
-	// renter.
-	txns, err := r.WalletTransactionsGet(0, ^types.BlockHeight(0))
+	copySiaPath, err := modules.NewSiaPath(rf.SiaPath().String() + "-copy")
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Filter out transactions without file contracts.
-	var fcTxns []modules.ProcessedTransaction
-	for _, txn := range txns.ConfirmedTransactions {
-		if len(txn.Transaction.FileContracts) > 0 {
-			fcTxns = append(fcTxns, txn)
-		}
+	if err := r.RenterCopyPost(rf.SiaPath(), copySiaPath); err != nil {
+		t.Fatal(err)
 	}
-	// There should be twice as many transactions with contracts as there are hosts.
-	if len(fcTxns) != 2*len(tg.Hosts()) {
-		t.Fatalf("Expected %v txns but got %v", 2*len(tg.Hosts()), len(fcTxns))
+	if err := r.RenterCopyPost(rf.SiaPath(), copySiaPath); err == nil {
+		t.Fatal("copying to an existing file should fail")
 	}
 
-	// Get the wallet seed of the renter.
-	wsg, err := r.WalletSeedsGet()
+	// The copy is as healthy as the original right away.
+	fi, err := r.RenterFileGet(copySiaPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	seed, err := modules.StringToSeed(wsg.PrimarySeed, "english")
+	if !fi.File.Available || fi.File.Redundancy < float64(parityPieces+dataPieces)/float64(dataPieces) {
+		t.Fatalf("copy isn't fully uploaded: %+v", fi.File)
+	}
+
+	// Delete the original and download the copy.
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.RenterDownloadHTTPResponseGet(copySiaPath, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	renterSeed := proto.DeriveRenterSeed(seed)
-	defer fastrand.Read(renterSeed[:])
-
-	// Check the arbitrary data of each transaction and contract.
-	for _, fcTxn := range fcTxns {
-		txn := fcTxn.Transaction
-		for _, fc := range txn.FileContracts {
-			// Check that the arbitrary data has correct length.
-			if len(txn.ArbitraryData) != 1 {
-				t.Fatal("arbitrary data has wrong length")
-			}
-			csi := proto.ContractSignedIdentifier{}
-			n := copy(csi[:], txn.ArbitraryData[0])
-			encryptedHostKey := txn.ArbitraryData[0][n:]
-			// Calculate the renter seed given the WindowStart of the contract.
-			rs := renterSeed.EphemeralRenterSeed(fc.WindowStart)
-			// Check if the identifier is valid.
-			spk, valid := csi.IsValid(rs, txn, encryptedHostKey)
-			if !valid {
-				t.Fatal("identifier is invalid")
-			}
-			// Check that the host's key is a valid key from the hostb.
-			_, err := r.HostDbHostsGet(spk)
-			if err != nil {
-				t.Fatal("hostKey is invalid", err)
-			}
-		}
+	if err := lf.Equal(data); err != nil {
+		t.Fatal(err)
 	}
 }
 
-// TestUploadAfterDelete tests that rapidly uploading a file to the same
-// siapath as a previously deleted file works.
-func TestUploadAfterDelete(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-	t.Parallel()
+// testPackedFiles tests uploading small files that are packed into a shared
+// chunk, downloading them once the chunk was uploaded and deleting the chunk
+// once all of its files were deleted.
+func testPackedFiles(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the renter.
+	r := tg.Renters()[0]
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:  2,
-		Miners: 1,
-	}
-	testDir := renterTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	// Upload small files with the pack flag.
+	ld, err := r.FilesDir().CreateDir("packed" + persist.RandomSuffix())
 	if err != nil {
-		t.Fatal("Failed to create group: ", err)
+		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
+	var files []*siatest.LocalFile
+	for i := 0; i < 3; i++ {
+		lf, err := ld.NewFile(100 + siatest.Fuzz())
+		if err != nil {
 			t.Fatal(err)
 		}
-	}()
+		if err := r.RenterUploadPackPost(lf.Path(), r.SiaPath(lf.Path())); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, lf)
+	}
 
-	// Add a Renter node
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.RenterDeps = &dependencies.DependencyDisableCloseUploadEntry{}
-	nodes, err := tg.AddNodes(renterParams)
+	// Wait for the pack to be uploaded.
+	packSiaPath, err := modules.NewSiaPath(".packed")
 	if err != nil {
 		t.Fatal(err)
 	}
-	renter := nodes[0]
-
-	// Upload file, creating a piece for each host in the group
-	dataPieces := uint64(1)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-	fileSize := int(modules.SectorSize)
-	localFile, remoteFile, err := renter.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		for _, lf := range files {
+			rf, err := r.RenterFileGet(r.SiaPath(lf.Path()))
+			if err != nil {
+				return err
+			}
+			if !rf.File.Packed {
+				return errors.New("file isn't packed")
+			}
+			if !rf.File.Available {
+				return errors.New("file isn't available yet")
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		t.Fatal("Failed to upload a file for testing: ", err)
+		t.Fatal(err)
 	}
-	// Repeatedly upload and delete a file with the same SiaPath without
-	// closing the entry. That shouldn't cause issues.
-	for i := 0; i < 5; i++ {
-		// Delete the file.
-		if err := renter.RenterDeletePost(remoteFile.SiaPath()); err != nil {
+	rd, err := r.RenterGetDir(packSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Files) != 1 {
+		t.Fatal("expected the files to share 1 pack, got", len(rd.Files))
+	}
+
+	// Download the files by http response and by stream.
+	for _, lf := range files {
+		siaPath := r.SiaPath(lf.Path())
+		data, err := r.RenterDownloadHTTPResponseGet(siaPath, 0, 0)
+		if err != nil {
 			t.Fatal(err)
 		}
-		// Upload the file again right after deleting it.
-		if _, err := renter.UploadBlocking(localFile, dataPieces, parityPieces, false); err != nil {
+		if err := lf.Equal(data); err != nil {
+			t.Fatal(err)
+		}
+		data, err = r.RenterStreamGet(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lf.Equal(data); err != nil {
 			t.Fatal(err)
 		}
 	}
-}
-
-// TestRenterContractRecovery tests that recovering a node from a seed that has
-// contracts associated with it will recover those contracts.
-func TestRenterContractRecovery(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-	t.Parallel()
 
-	// Create a testgroup, creating without renter so the renter's
-	// contract transactions can easily be obtained.
-	groupParams := siatest.GroupParams{
-		Hosts:   2,
-		Miners:  1,
-		Renters: 1,
-	}
-	testDir := renterTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
-	if err != nil {
-		t.Fatal("Failed to create group: ", err)
-	}
-	defer func() {
-		if err := tg.Close(); err != nil {
+	// Deleting the files and purging them from the trash deletes the pack.
+	for _, lf := range files {
+		if err := r.RenterDeletePost(r.SiaPath(lf.Path())); err != nil {
 			t.Fatal(err)
 		}
-	}()
-
-	// Get the renter node and its seed.
-	r := tg.Renters()[0]
-	wsg, err := r.WalletSeedsGet()
-	if err != nil {
+	}
+	if err := r.RenterTrashPurgeAllPost(); err != nil {
 		t.Fatal(err)
 	}
-	seed := wsg.PrimarySeed
-
-	// Upload a file to the renter.
-	dataPieces := uint64(1)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-	fileSize := int(10 * modules.SectorSize)
-	lf, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	rd, err = r.RenterGetDir(packSiaPath)
 	if err != nil {
-		t.Fatal("Failed to upload a file for testing: ", err)
+		t.Fatal(err)
 	}
-
-	// Remember the contracts the renter formed with the hosts.
-	oldContracts := make(map[types.FileContractID]api.RenterContract)
-	rc, err := r.RenterContractsGet()
-	if err != nil {
-		t.Fatal(err)
-	}
-	for _, c := range rc.ActiveContracts {
-		oldContracts[c.ID] = c
+	if len(rd.Files) != 0 {
+		t.Fatal("pack wasn't deleted after all of its files were deleted")
 	}
+}
 
-	// Stop the renter.
-	if err := tg.RemoveNode(r); err != nil {
-		t.Fatal(err)
+// testZeroByteFile tests uploading and downloading a 0 and 1 byte file
+func testZeroByteFile(t *testing.T, tg *siatest.TestGroup) {
+	if len(tg.Hosts()) < 2 {
+		t.Fatal("This test requires at least 2 hosts")
 	}
+	// Grab renter
+	r := tg.Renters()[0]
 
-	// Copy the siafile to the new location.
-	oldPath := filepath.Join(r.Dir, modules.RenterDir, modules.SiapathRoot, lf.FileName()+modules.SiaFileExtension)
-	siaFile, err := ioutil.ReadFile(oldPath)
+	// Create 0 and 1 byte file
+	zeroByteFile := 0
+	oneByteFile := 1
+
+	// Test uploading 0 byte file
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	redundancy := float64((dataPieces + parityPieces) / dataPieces)
+	_, zeroRF, err := r.UploadNewFile(zeroByteFile, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	newRenterDir := filepath.Join(testDir, "renter")
-	newPath := filepath.Join(newRenterDir, modules.RenterDir, modules.SiapathRoot, lf.FileName()+modules.SiaFileExtension)
-	if err := os.MkdirAll(filepath.Dir(newPath), 0777); err != nil {
+	// Get zerobyte file
+	rf, err := r.File(zeroRF)
+	if err != nil {
 		t.Fatal(err)
 	}
-	if err := ioutil.WriteFile(newPath, siaFile, 0777); err != nil {
-		t.Fatal(err)
+	// Check redundancy and upload progress
+	if rf.Redundancy != redundancy {
+		t.Fatalf("Expected redundancy to be %v, got %v", redundancy, rf.Redundancy)
 	}
-
-	// Start a new renter with the same seed.
-	renterParams := node.Renter(newRenterDir)
-	renterParams.PrimarySeed = seed
-	nodes, err := tg.AddNodes(renterParams)
+	if rf.UploadProgress != 100 {
+		t.Fatalf("Expected upload progress to be 100, got %v", rf.UploadProgress)
+	}
+	// Check health information
+	if rf.Health != 0 {
+		t.Fatalf("Expected health to be 0, got %v", rf.Health)
+	}
+	if rf.MaxHealth != 0 {
+		t.Fatalf("Expected max health to be 0, got %v", rf.MaxHealth)
+	}
+	if rf.MaxHealthPercent != 100 {
+		t.Fatalf("Expected max health percentage to be 100, got %v", rf.MaxHealthPercent)
+	}
+	if rf.NumStuckChunks != 0 {
+		t.Fatalf("Expected number of stuck chunks to be 0, got %v", rf.NumStuckChunks)
+	}
+	if rf.Stuck {
+		t.Fatalf("Expected file not to be stuck")
+	}
+	if rf.StuckHealth != 0 {
+		t.Fatalf("Expected stuck health to be 0, got %v", rf.StuckHealth)
+	}
+	// Get the same file using the /renter/files endpoint with 'cached' set to
+	// true.
+	rfs, err := r.Files(true)
 	if err != nil {
 		t.Fatal(err)
 	}
-	newRenter := nodes[0]
+	var rf2 modules.FileInfo
+	var found bool
+	for _, file := range rfs {
+		if file.SiaPath.Equals(rf.SiaPath) {
+			found = true
+			rf2 = file
+			break
+		}
+	}
+	if !found {
+		t.Fatal("couldn't find uploaded file using /renter/files endpoint")
+	}
+	// Compare the fields again.
+	if rf.Redundancy != rf2.Redundancy {
+		t.Fatalf("Expected redundancy to be %v, got %v", rf.Redundancy, rf2.Redundancy)
+	}
+	if rf.UploadProgress != rf2.UploadProgress {
+		t.Fatalf("Expected upload progress to be %v, got %v", rf.UploadProgress, rf2.UploadProgress)
+	}
+	if rf.Health != rf2.Health {
+		t.Fatalf("Expected health to be %v, got %v", rf.Health, rf2.Health)
+	}
+	if rf.MaxHealth != rf2.MaxHealth {
+		t.Fatalf("Expected max health to be %v, got %v", rf.MaxHealth, rf2.MaxHealth)
+	}
+	if rf.MaxHealthPercent != rf2.MaxHealthPercent {
+		t.Fatalf("Expected max health percentage to be %v, got %v", rf.MaxHealthPercent, rf2.MaxHealthPercent)
+	}
+	if rf.NumStuckChunks != rf2.NumStuckChunks {
+		t.Fatalf("Expected number of stuck chunks to be %v, got %v", rf.NumStuckChunks, rf2.NumStuckChunks)
+	}
+	if rf.Stuck != rf2.Stuck {
+		t.Fatalf("Expected stuck to be %v, got %v", rf.Stuck, rf2.Stuck)
+	}
+	if rf.StuckHealth != rf2.StuckHealth {
+		t.Fatalf("Expected stuck health to be %v, got %v", rf.StuckHealth, rf2.StuckHealth)
+	}
 
-	// Make sure that the new renter actually uses the same primary seed.
-	wsg, err = newRenter.WalletSeedsGet()
+	// Test uploading 1 byte file
+	_, oneRF, err := r.UploadNewFileBlocking(oneByteFile, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	newRenterSeed := wsg.PrimarySeed
-	if seed != newRenterSeed {
-		t.Log("old seed", seed)
-		t.Log("new seed", newRenterSeed)
-		t.Fatal("Seeds of new and old renters don't match")
-	}
 
-	// The new renter should have the same active contracts as the old one.
-	miner := tg.Miners()[0]
-	numRetries := 0
-	err = build.Retry(60, time.Second, func() error {
-		if numRetries%10 == 0 {
-			if err := miner.MineBlock(); err != nil {
-				return err
-			}
-		}
-		numRetries++
-		rc, err = newRenter.RenterContractsGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(rc.ActiveContracts) != len(oldContracts) {
-			return fmt.Errorf("Didn't recover the right number of contracts, expected %v but was %v",
-				len(oldContracts), len(rc.ActiveContracts))
-		}
-		for _, c := range rc.ActiveContracts {
-			contract, exists := oldContracts[c.ID]
-			if !exists {
-				return errors.New(fmt.Sprint("Recovered unknown contract", c.ID))
-			}
-			if contract.HostPublicKey.String() != c.HostPublicKey.String() {
-				return errors.New("public keys don't match")
-			}
-			if contract.StartHeight != c.StartHeight {
-				return errors.New("startheights don't match")
-			}
-			if contract.EndHeight != c.EndHeight {
-				return errors.New("endheights don't match")
-			}
-			if c.Fees.Cmp(types.ZeroCurrency) <= 0 {
-				return errors.New("Fees wasn't set")
-			}
-			if contract.GoodForRenew != c.GoodForRenew {
-				return errors.New("GoodForRenew doesn't match")
-			}
-			if contract.GoodForUpload != c.GoodForUpload {
-				return errors.New("GoodForRenew doesn't match")
-			}
-		}
-		return nil
-	})
+	// Test downloading 0 byte file
+	_, err = r.DownloadToDisk(zeroRF, false)
 	if err != nil {
-		rc, _ = newRenter.RenterContractsGet()
-		t.Log("Contracts in total:", len(rc.Contracts))
 		t.Fatal(err)
 	}
-	// Download the whole file again to see if all roots were recovered.
-	_, err = newRenter.DownloadByStream(rf)
+
+	// Test downloading 1 byte file
+	_, err = r.DownloadToDisk(oneRF, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-// TestSiafileCompatCode checks that legacy renters can upgrade to the latest
-// siafile format.
-func TestSiafileCompatCode(t *testing.T) {
-	if testing.Short() {
+// TestRenterFileChangeDuringDownload confirms that a download will continue and
+// succeed if the file is renamed or deleted after the download has started
+func TestRenterFileChangeDuringDownload(t *testing.T) {
+	if !build.VLONG {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Get test directory
+	// Create a testgroup,
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
+	}
 	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
 
-	// The siapath stored in the legacy file.
-	expectedSiaPath, err := modules.NewSiaPath("sub1/sub2/testfile")
+	// Grab Renter and upload file
+	r := tg.Renters()[0]
+	dataPieces := uint64(1)
+	parityPieces := uint64(1)
+	chunkSize := int64(siatest.ChunkSize(dataPieces, crypto.TypeDefaultRenter))
+	fileSize := 3 * int(chunkSize)
+	_, rf1, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Copying legacy file to test directory
-	renterDir := filepath.Join(testDir, "renter")
-	source := filepath.Join("..", "..", "compatibility", "siafile_v1.3.7.sia")
-	destination := filepath.Join(renterDir, "sub1", "sub2", "testfile.sia")
-	if err := copyFile(source, destination); err != nil {
+	_, rf2, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Copy the legacy settings file to the test directory.
-	source2 := "../../compatibility/renter_v137.json"
-	destination2 := filepath.Join(renterDir, "renter.json")
-	if err := copyFile(source2, destination2); err != nil {
+	_, rf3, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Copy the legacy contracts into the test directory.
-	contractsSource := "../../compatibility/contracts_v137"
-	contracts, err := ioutil.ReadDir(contractsSource)
+	_, rf4, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	for _, fi := range contracts {
-		contractDst := filepath.Join(contractsSource, fi.Name())
-		err := copyFile(contractDst, filepath.Join(renterDir, "contracts", fi.Name()))
-		if err != nil {
-			t.Fatal(err)
-		}
+	_, rf5, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// Create new node with legacy sia file.
-	r, err := siatest.NewNode(node.AllModules(testDir))
-	if err != nil {
+	// Set the bandwidth limit to 1 chunk per second.
+	if err := r.RenterPostRateLimit(chunkSize, chunkSize); err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err = r.Close(); err != nil {
+		if err := r.RenterPostRateLimit(0, 0); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	// Make sure the folder containing the legacy file was deleted.
-	if _, err := os.Stat(filepath.Join(renterDir, "sub1")); !os.IsNotExist(err) {
-		t.Fatal("Error should be ErrNotExist but was", err)
-	}
-	// Make sure the siafile is exactly where we would expect it.
-	expectedLocation := filepath.Join(renterDir, "siafiles", "sub1", "sub2", "testfile.sia")
-	if _, err := os.Stat(expectedLocation); err != nil {
-		t.Fatal(err)
-	}
-	// Check that exactly 1 siafile exists and that it's the correct one.
-	fis, err := r.Files(false)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(fis) != 1 {
-		t.Fatal("Expected 1 file but got", len(fis))
+
+	// Create Wait group
+	wg := new(sync.WaitGroup)
+
+	// Test Renaming while Downloading and Streaming on 5 files.
+	wg.Add(1)
+	go renameDuringDownloadAndStream(r, rf1, t, wg, time.Second)
+	wg.Add(1)
+	go renameDuringDownloadAndStream(r, rf2, t, wg, time.Second)
+	wg.Add(1)
+	go renameDuringDownloadAndStream(r, rf3, t, wg, time.Second)
+	wg.Add(1)
+	go renameDuringDownloadAndStream(r, rf4, t, wg, time.Second)
+	wg.Add(1)
+	go renameDuringDownloadAndStream(r, rf5, t, wg, time.Second)
+	wg.Wait()
+
+	// Test Deleting while Downloading and Streaming
+	//
+	// Download the file
+	wg.Add(1)
+	go deleteDuringDownloadAndStream(r, rf1, t, wg, time.Second)
+	wg.Add(1)
+	go deleteDuringDownloadAndStream(r, rf2, t, wg, time.Second)
+	wg.Add(1)
+	go deleteDuringDownloadAndStream(r, rf3, t, wg, time.Second)
+	wg.Add(1)
+	go deleteDuringDownloadAndStream(r, rf4, t, wg, time.Second)
+	wg.Add(1)
+	go deleteDuringDownloadAndStream(r, rf5, t, wg, time.Second)
+
+	wg.Wait()
+}
+
+// testSetFileTrackingPath tests if changing the repairPath of a file works.
+func testSetFileTrackingPath(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	// Check that we have enough hosts for this test.
+	if len(tg.Hosts()) < 2 {
+		t.Fatal("This test requires at least 2 hosts")
 	}
-	if fis[0].SiaPath != expectedSiaPath {
-		t.Fatalf("Siapath should be '%v' but was '%v'",
-			expectedSiaPath, fis[0].SiaPath)
+	// Set fileSize and redundancy for upload
+	fileSize := int(modules.SectorSize)
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+
+	// Upload file
+	localFile, remoteFile, err := renter.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Check the other fields of the files in a loop since the cached fields might
-	// need some time to update.
-	err = build.Retry(100, time.Second, func() error {
-		fis, err := r.Files(false)
-		if err != nil {
-			return err
-		}
-		sf := fis[0]
-		if sf.AccessTime.IsZero() {
-			return errors.New("AccessTime wasn't set correctly")
-		}
-		if sf.ChangeTime.IsZero() {
-			return errors.New("ChangeTime wasn't set correctly")
-		}
-		if sf.CreateTime.IsZero() {
-			return errors.New("CreateTime wasn't set correctly")
-		}
-		if sf.ModTime.IsZero() {
-			return errors.New("ModTime wasn't set correctly")
-		}
-		if sf.Available {
-			return errors.New("File shouldn't be available since we don't know the hosts")
-		}
-		if sf.CipherType != crypto.TypeTwofish.String() {
-			return fmt.Errorf("CipherType should be twofish but was: %v", sf.CipherType)
-		}
-		if sf.Filesize != 4096 {
-			return fmt.Errorf("Filesize should be 4096 but was: %v", sf.Filesize)
-		}
-		if sf.Expiration != 91 {
-			return fmt.Errorf("Expiration should be 91 but was: %v", sf.Expiration)
-		}
-		if sf.LocalPath != "/tmp/SiaTesting/siatest/TestRenterTwo/gctwr-EKYAZSVOZ6U2T4HZYIAQ/files/4096bytes 16951a61" {
-			return errors.New("LocalPath doesn't match")
-		}
-		if sf.Redundancy != 0 {
-			return errors.New("Redundancy should be 0 since we don't know the hosts")
-		}
-		if sf.UploadProgress != 100 {
-			return fmt.Errorf("File was uploaded before so the progress should be 100 but was %v", sf.UploadProgress)
-		}
-		if sf.UploadedBytes != 40960 {
-			return errors.New("Redundancy should be 10/20 so 10x the Filesize = 40960 bytes should be uploaded")
-		}
-		if sf.OnDisk {
-			return errors.New("OnDisk should be false but was true")
-		}
-		if sf.Recoverable {
-			return errors.New("Recoverable should be false but was true")
-		}
-		if !sf.Renewing {
-			return errors.New("Renewing should be true but wasn't")
+	// Move the file to a new location.
+	if err := localFile.Move(); err != nil {
+		t.Fatal(err)
+	}
+	// Take down all the hosts.
+	numHosts := len(tg.Hosts())
+	for _, host := range tg.Hosts() {
+		if err := tg.RemoveNode(host); err != nil {
+			t.Fatal("Failed to shutdown host", err)
 		}
-		return nil
-	})
+	}
+	// File should have 0 redundancy now.
+	if err := renter.WaitForDecreasingRedundancy(remoteFile, 0); err != nil {
+		t.Fatal("Redundancy isn't decreasing", err)
+	}
+	// Rename the repairPath to match the new location.
+	if err := renter.SetFileRepairPath(remoteFile, localFile); err != nil {
+		t.Fatal("Failed to change the repair path", err)
+	}
+	// Create new hosts.
+	_, err = tg.AddNodeN(node.HostTemplate, numHosts)
 	if err != nil {
+		t.Fatal("Failed to create a new host", err)
+	}
+	// We should reach full health again.
+	if err := renter.WaitForUploadHealth(remoteFile); err != nil {
+		t.Logf("numHosts: %v", len(tg.Hosts()))
+		t.Fatal("File wasn't repaired", err)
+	}
+	// We should be able to download
+	if _, err := renter.DownloadByStream(remoteFile); err != nil {
+		t.Fatal("Failed to download file", err)
+	}
+	// Create a new file that is smaller than the first one.
+	smallFile, err := renter.FilesDir().NewFile(fileSize - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Try to change the repairPath of the remote file again. This shouldn't
+	// work.
+	if err := renter.SetFileRepairPath(remoteFile, smallFile); err == nil {
+		t.Fatal("Changing repair path to file of different size shouldn't work")
+	}
+	// Delete the small file and try again. This also shouldn't work.
+	if err := smallFile.Delete(); err != nil {
 		t.Fatal(err)
 	}
+	if err := renter.SetFileRepairPath(remoteFile, smallFile); err == nil {
+		t.Fatal("Changing repair path to a nonexistent file shouldn't work")
+	}
 }
 
-// TestRenterContractInitRecoveryScan tests that a renter which has already
-// scanned the whole blockchain and has lost its contracts, can recover them by
-// triggering a rescan through the API.
-func TestRenterContractInitRecoveryScan(t *testing.T) {
+// TestRenterFileContractIdentifier checks that the file contract's identifier
+// is set correctly when forming a contract and after renewing it.
+func TestRenterFileContractIdentifier(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-	// Create a testgroup.
+
+	// Create a testgroup, creating without renter so the renter's
+	// contract transactions can easily be obtained.
 	groupParams := siatest.GroupParams{
 		Hosts:  2,
 		Miners: 1,
@@ -3963,144 +3959,187 @@ func TestRenterContractInitRecoveryScan(t *testing.T) {
 
 	// Add a Renter node
 	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.ContractorDeps = &dependencies.DependencyDisableRecoveryStatusReset{}
-	_, err = tg.AddNodes(renterParams)
+	nodes, err := tg.AddNodes(renterParams)
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := tg.Renters()[0]
+	r := nodes[0]
 
-	// Upload a file to the renter.
-	dataPieces := uint64(1)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-	fileSize := int(10 * modules.SectorSize)
-	_, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	rcg, err := r.RenterContractsGet()
 	if err != nil {
-		t.Fatal("Failed to upload a file for testing: ", err)
+		t.Fatal(err)
 	}
+	// Get the endheight of the contracts.
+	eh := rcg.ActiveContracts[0].EndHeight
 
-	// Remember the contracts the renter formed with the hosts.
-	oldContracts := make(map[types.FileContractID]api.RenterContract)
-	rc, err := r.RenterContractsGet()
+	// Get the blockheight.
+	cg, err := tg.Hosts()[0].ConsensusGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	for _, c := range rc.ActiveContracts {
-		oldContracts[c.ID] = c
-	}
+	bh := cg.Height
 
-	// Cancel the allowance to avoid new contracts replacing the recoverable
-	// ones.
-	if err := r.RenterCancelAllowance(); err != nil {
-		t.Fatal(err)
+	// Mine blocks until we reach the endheight
+	m := tg.Miners()[0]
+	for i := 0; i < int(eh-bh); i++ {
+		if err := m.MineBlock(); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	// Stop the renter.
-	if err := tg.StopNode(r); err != nil {
+	// Confirm that the contracts got renewed.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		// Mine a block.
+		if err := m.MineBlock(); err != nil {
+			t.Fatal(err)
+		}
+		// Get the contracts from the renter.
+		rcg, err := r.RenterExpiredContractsGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// We should have one contract for each host.
+		if len(rcg.ActiveContracts) != len(tg.Hosts()) {
+			return fmt.Errorf("expected %v active contracts but got %v",
+				len(tg.Hosts()), rcg.ActiveContracts)
+		}
+		// We should have one expired contract for each host.
+		if len(rcg.ExpiredContracts) != len(tg.Hosts()) {
+			return fmt.Errorf("expected %v expired contracts but got %v",
+				len(tg.Hosts()), len(rcg.ExpiredContracts))
+		}
+		return nil
+	})
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Delete the contracts.
-	if err := os.RemoveAll(filepath.Join(r.Dir, modules.RenterDir, "contracts")); err != nil {
+	// Get the transaction which are related to the renter since we started the
+	// renter.
+	txns, err := r.WalletTransactionsGet(0, ^types.BlockHeight(0))
+	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Start the renter again.
-	if err := tg.StartNode(r); err != nil {
-		t.Fatal(err)
+	// Filter out transactions without file contracts.
+	var fcTxns []modules.ProcessedTransaction
+	for _, txn := range txns.ConfirmedTransactions {
+		if len(txn.Transaction.FileContracts) > 0 {
+			fcTxns = append(fcTxns, txn)
+		}
+	}
+	// There should be twice as many transactions with contracts as there are hosts.
+	if len(fcTxns) != 2*len(tg.Hosts()) {
+		t.Fatalf("Expected %v txns but got %v", 2*len(tg.Hosts()), len(fcTxns))
 	}
 
-	// The renter shouldn't have any contracts.
-	rcg, err := r.RenterContractsGet()
+	// Get the wallet seed of the renter.
+	wsg, err := r.WalletSeedsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rcg.ActiveContracts)+len(rcg.InactiveContracts)+len(rcg.ExpiredContracts) > 0 {
-		t.Fatal("There shouldn't be any contracts after deleting them")
+	seed, err := modules.StringToSeed(wsg.PrimarySeed, "english")
+	if err != nil {
+		t.Fatal(err)
 	}
+	renterSeed := proto.DeriveRenterSeed(seed)
+	defer fastrand.Read(renterSeed[:])
 
-	// Trigger a rescan of the blockchain.
-	if err := r.RenterInitContractRecoveryScanPost(); err != nil {
-		t.Fatal(err)
-	}
-
-	// The new renter should have the same active contracts as the old one.
-	miner := tg.Miners()[0]
-	numRetries := 0
-	err = build.Retry(60, time.Second, func() error {
-		if numRetries%10 == 0 {
-			if err := miner.MineBlock(); err != nil {
-				return err
-			}
-		}
-		numRetries++
-		rc, err = r.RenterContractsGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(rc.ActiveContracts) != len(oldContracts) {
-			return fmt.Errorf("Didn't recover the right number of contracts, expected %v but was %v",
-				len(oldContracts), len(rc.ActiveContracts))
-		}
-		for _, c := range rc.ActiveContracts {
-			contract, exists := oldContracts[c.ID]
-			if !exists {
-				return errors.New(fmt.Sprint("Recovered unknown contract", c.ID))
-			}
-			if contract.HostPublicKey.String() != c.HostPublicKey.String() {
-				return errors.New("public keys don't match")
-			}
-			if contract.EndHeight != c.EndHeight {
-				return errors.New("endheights don't match")
+	// Check the arbitrary data of each transaction and contract.
+	for _, fcTxn := range fcTxns {
+		txn := fcTxn.Transaction
+		for _, fc := range txn.FileContracts {
+			// Check that the arbitrary data has correct length.
+			if len(txn.ArbitraryData) != 1 {
+				t.Fatal("arbitrary data has wrong length")
 			}
-			if contract.GoodForRenew != c.GoodForRenew {
-				return errors.New("GoodForRenew doesn't match")
+			csi := proto.ContractSignedIdentifier{}
+			n := copy(csi[:], txn.ArbitraryData[0])
+			encryptedHostKey := txn.ArbitraryData[0][n:]
+			// Calculate the renter seed given the WindowStart of the contract.
+			rs := renterSeed.EphemeralRenterSeed(fc.WindowStart)
+			// Check if the identifier is valid.
+			spk, valid := csi.IsValid(rs, txn, encryptedHostKey)
+			if !valid {
+				t.Fatal("identifier is invalid")
 			}
-			if contract.GoodForUpload != c.GoodForUpload {
-				return errors.New("GoodForRenew doesn't match")
+			// Check that the host's key is a valid key from the hostb.
+			_, err := r.HostDbHostsGet(spk)
+			if err != nil {
+				t.Fatal("hostKey is invalid", err)
 			}
 		}
-		return nil
-	})
+	}
+}
+
+// TestUploadAfterDelete tests that rapidly uploading a file to the same
+// siapath as a previously deleted file works.
+func TestUploadAfterDelete(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
-		rc, _ = r.RenterContractsGet()
-		t.Log("Contracts in total:", len(rc.Contracts))
-		t.Fatal(err)
+		t.Fatal("Failed to create group: ", err)
 	}
-	// Download the whole file again to see if all roots were recovered.
-	_, err = r.DownloadByStream(rf)
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a Renter node
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.RenterDeps = &dependencies.DependencyDisableCloseUploadEntry{}
+	nodes, err := tg.AddNodes(renterParams)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Check that the RecoveryScanStatus was set.
-	rrs, err := r.RenterContractRecoveryProgressGet()
+	renter := nodes[0]
+
+	// Upload file, creating a piece for each host in the group
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	fileSize := int(modules.SectorSize)
+	localFile, remoteFile, err := renter.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Failed to upload a file for testing: ", err)
 	}
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		// Check the recovery progress endpoint.
-		if !rrs.ScanInProgress || rrs.ScannedHeight == 0 {
-			return fmt.Errorf("ScanInProgress and/or ScannedHeight weren't set correctly: %v", rrs)
+	// Repeatedly upload and delete a file with the same SiaPath without
+	// closing the entry. That shouldn't cause issues.
+	for i := 0; i < 5; i++ {
+		// Delete the file.
+		if err := renter.RenterDeletePost(remoteFile.SiaPath()); err != nil {
+			t.Fatal(err)
+		}
+		// Upload the file again right after deleting it.
+		if _, err := renter.UploadBlocking(localFile, dataPieces, parityPieces, false); err != nil {
+			t.Fatal(err)
 		}
-		return nil
-	})
-	if err != nil {
-		t.Fatal(err)
 	}
 }
 
-// TestRenterContractAutomaticRecoveryScan tests that a renter which has already
-// scanned the whole blockchain and has lost its contracts, will recover them
-// automatically during the next contract maintenance.
-func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
+// TestRenterContractRecovery tests that recovering a node from a seed that has
+// contracts associated with it will recover those contracts.
+func TestRenterContractRecovery(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-	// Create a testgroup.
+
+	// Create a testgroup, creating without renter so the renter's
+	// contract transactions can easily be obtained.
 	groupParams := siatest.GroupParams{
-		Hosts:  2,
-		Miners: 1,
+		Hosts:   2,
+		Miners:  1,
+		Renters: 1,
 	}
 	testDir := renterTestDir(t.Name())
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
@@ -4113,20 +4152,19 @@ func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
 		}
 	}()
 
-	// Add a renter node that can't run the automatic contract recovery scan.
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.ContractorDeps = &dependencies.DependencyDisableRecoveryStatusReset{}
-	_, err = tg.AddNodes(renterParams)
+	// Get the renter node and its seed.
+	r := tg.Renters()[0]
+	wsg, err := r.WalletSeedsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := tg.Renters()[0]
+	seed := wsg.PrimarySeed
 
 	// Upload a file to the renter.
 	dataPieces := uint64(1)
 	parityPieces := uint64(len(tg.Hosts())) - dataPieces
 	fileSize := int(10 * modules.SectorSize)
-	_, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	lf, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal("Failed to upload a file for testing: ", err)
 	}
@@ -4141,35 +4179,45 @@ func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
 		oldContracts[c.ID] = c
 	}
 
-	// Cancel the allowance to avoid new contracts replacing the recoverable
-	// ones.
-	if err := r.RenterCancelAllowance(); err != nil {
+	// Stop the renter.
+	if err := tg.RemoveNode(r); err != nil {
 		t.Fatal(err)
 	}
 
-	// Stop the renter.
-	if err := tg.StopNode(r); err != nil {
+	// Copy the siafile to the new location.
+	oldPath := filepath.Join(r.Dir, modules.RenterDir, modules.SiapathRoot, lf.FileName()+modules.SiaFileExtension)
+	siaFile, err := ioutil.ReadFile(oldPath)
+	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Delete the contracts.
-	if err := os.RemoveAll(filepath.Join(r.Dir, modules.RenterDir, "contracts")); err != nil {
+	newRenterDir := filepath.Join(testDir, "renter")
+	newPath := filepath.Join(newRenterDir, modules.RenterDir, modules.SiapathRoot, lf.FileName()+modules.SiaFileExtension)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(newPath, siaFile, 0777); err != nil {
 		t.Fatal(err)
 	}
 
-	// Start the renter again. This time it's unlocked and the automatic recovery
-	// scan isn't disabled.
-	if err := tg.StartNodeCleanDeps(r); err != nil {
+	// Start a new renter with the same seed.
+	renterParams := node.Renter(newRenterDir)
+	renterParams.PrimarySeed = seed
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
 		t.Fatal(err)
 	}
+	newRenter := nodes[0]
 
-	// The renter shouldn't have any contracts.
-	rcg, err := r.RenterContractsGet()
+	// Make sure that the new renter actually uses the same primary seed.
+	wsg, err = newRenter.WalletSeedsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rcg.ActiveContracts)+len(rcg.InactiveContracts)+len(rcg.ExpiredContracts) > 0 {
-		t.Fatal("There shouldn't be any contracts after deleting them")
+	newRenterSeed := wsg.PrimarySeed
+	if seed != newRenterSeed {
+		t.Log("old seed", seed)
+		t.Log("new seed", newRenterSeed)
+		t.Fatal("Seeds of new and old renters don't match")
 	}
 
 	// The new renter should have the same active contracts as the old one.
@@ -4182,7 +4230,7 @@ func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
 			}
 		}
 		numRetries++
-		rc, err = r.RenterContractsGet()
+		rc, err = newRenter.RenterContractsGet()
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -4198,9 +4246,15 @@ func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
 			if contract.HostPublicKey.String() != c.HostPublicKey.String() {
 				return errors.New("public keys don't match")
 			}
+			if contract.StartHeight != c.StartHeight {
+				return errors.New("startheights don't match")
+			}
 			if contract.EndHeight != c.EndHeight {
 				return errors.New("endheights don't match")
 			}
+			if c.Fees.Cmp(types.ZeroCurrency) <= 0 {
+				return errors.New("Fees wasn't set")
+			}
 			if contract.GoodForRenew != c.GoodForRenew {
 				return errors.New("GoodForRenew doesn't match")
 			}
@@ -4211,179 +4265,164 @@ func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
 		return nil
 	})
 	if err != nil {
-		rc, _ = r.RenterContractsGet()
+		rc, _ = newRenter.RenterContractsGet()
 		t.Log("Contracts in total:", len(rc.Contracts))
 		t.Fatal(err)
 	}
 	// Download the whole file again to see if all roots were recovered.
-	_, err = r.DownloadByStream(rf)
+	_, err = newRenter.DownloadByStream(rf)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-// TestCreateLoadBackup tests that creating a backup with the /renter/backup
-// endpoint works as expected and that it can be loaded with the
-// /renter/recoverbackup endpoint.
-func TestCreateLoadBackup(t *testing.T) {
+// TestSiafileCompatCode checks that legacy renters can upgrade to the latest
+// siafile format.
+func TestSiafileCompatCode(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Create a testgroup.
-	groupParams := siatest.GroupParams{
-		Hosts:   2,
-		Miners:  1,
-		Renters: 1,
-	}
+	// Get test directory
 	testDir := renterTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+
+	// The siapath stored in the legacy file.
+	expectedSiaPath, err := modules.NewSiaPath("sub1/sub2/testfile")
 	if err != nil {
-		t.Fatal("Failed to create group: ", err)
+		t.Fatal(err)
 	}
-	defer func() {
-		if err := tg.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-	// Create a subdir in the renter's files folder.
-	r := tg.Renters()[0]
-	subDir, err := r.FilesDir().CreateDir("subDir")
-	if err != nil {
+
+	// Copying legacy file to test directory
+	renterDir := filepath.Join(testDir, "renter")
+	source := filepath.Join("..", "..", "compatibility", "siafile_v1.3.7.sia")
+	destination := filepath.Join(renterDir, "sub1", "sub2", "testfile.sia")
+	if err := copyFile(source, destination); err != nil {
 		t.Fatal(err)
 	}
-	// Add a file to that dir.
-	lf, err := subDir.NewFile(100)
-	if err != nil {
+	// Copy the legacy settings file to the test directory.
+	source2 := "../../compatibility/renter_v137.json"
+	destination2 := filepath.Join(renterDir, "renter.json")
+	if err := copyFile(source2, destination2); err != nil {
 		t.Fatal(err)
 	}
-	// Upload the file.
-	dataPieces := uint64(len(tg.Hosts()) - 1)
-	parityPieces := uint64(1)
-	rf, err := r.UploadBlocking(lf, dataPieces, parityPieces, false)
+	// Copy the legacy contracts into the test directory.
+	contractsSource := "../../compatibility/contracts_v137"
+	contracts, err := ioutil.ReadDir(contractsSource)
 	if err != nil {
-		t.Fatal("Failed to upload a file for testing: ", err)
-	}
-	// Delete the file locally.
-	if err := lf.Delete(); err != nil {
 		t.Fatal(err)
 	}
-	// Create a backup.
-	backupPath := filepath.Join(r.FilesDir().Path(), "test.backup")
-	err = r.RenterCreateLocalBackupPost(backupPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	// Recover the backup into the same renter. Nothing should change.
-	if err := r.RenterRecoverLocalBackupPost(backupPath); err != nil {
-		t.Fatal(err)
-	}
-	files, err := r.Files(false)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(files) != 1 {
-		t.Fatal("expected 1 file but got", len(files))
-	}
-	// Get the renter's seed.
-	wsg, err := r.WalletSeedsGet()
-	if err != nil {
-		t.Fatal(err)
-	}
-	// Shut down the renter.
-	if err := tg.RemoveNode(r); err != nil {
-		t.Fatal(err)
-	}
-	// Start a new renter from the same seed Disable its health and repair loops to
-	// avoid updating the .siadir file.
-	rt := node.RenterTemplate
-	rt.PrimarySeed = wsg.PrimarySeed
-	nodes, err := tg.AddNodes(rt)
-	if err != nil {
-		t.Fatal(err)
-	}
-	r = nodes[0]
-	// Recover the backup.
-	if err := r.RenterRecoverLocalBackupPost(backupPath); err != nil {
-		t.Fatal(err)
-	}
-	// The .siadir file should also be recovered.
-	dirMDPath := filepath.Join(r.Dir, modules.RenterDir, modules.SiapathRoot, "subDir", modules.SiaDirExtension)
-	if _, err := os.Stat(dirMDPath); os.IsNotExist(err) {
-		t.Fatal(".siadir file doesn't exist")
-	}
-	// There shouldn't be a .siadir_1 file as we don't replace existing .siadir
-	// files.
-	if _, err := os.Stat(dirMDPath + "_1"); !os.IsNotExist(err) {
-		t.Fatal(".siadir_1 file does exist")
-	}
-	// The file should be available and ready for download again.
-	if _, err := r.DownloadByStream(rf); err != nil {
-		t.Fatal(err)
-	}
-	// Delete the file and upload another file to the same siapath. This one should
-	// have the same siapath but not the same UID.
-	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
-		t.Fatal(err)
-	}
-	subDir, err = r.FilesDir().CreateDir("subDir")
-	if err != nil {
-		t.Fatal(err)
+	for _, fi := range contracts {
+		contractDst := filepath.Join(contractsSource, fi.Name())
+		err := copyFile(contractDst, filepath.Join(renterDir, "contracts", fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
-	lf, err = subDir.NewFileWithName(lf.FileName(), 100)
+
+	// Create new node with legacy sia file.
+	r, err := siatest.NewNode(node.AllModules(testDir))
 	if err != nil {
 		t.Fatal(err)
 	}
-	rf, err = r.UploadBlocking(lf, dataPieces, parityPieces, false)
-	if err != nil {
-		t.Fatal(err)
+	defer func() {
+		if err = r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Make sure the folder containing the legacy file was deleted.
+	if _, err := os.Stat(filepath.Join(renterDir, "sub1")); !os.IsNotExist(err) {
+		t.Fatal("Error should be ErrNotExist but was", err)
 	}
-	// Recover the backup again. Now there should be another file with a suffix at
-	// the end.
-	if err := r.RenterRecoverLocalBackupPost(backupPath); err != nil {
+	// Make sure the siafile is exactly where we would expect it.
+	expectedLocation := filepath.Join(renterDir, "siafiles", "sub1", "sub2", "testfile.sia")
+	if _, err := os.Stat(expectedLocation); err != nil {
 		t.Fatal(err)
 	}
-	fis, err := r.RenterFilesGet(false)
+	// Check that exactly 1 siafile exists and that it's the correct one.
+	fis, err := r.Files(false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(fis.Files) != 2 {
-		t.Fatalf("Expected 2 files but got %v", len(fis.Files))
+	if len(fis) != 1 {
+		t.Fatal("Expected 1 file but got", len(fis))
 	}
-	sp, err := modules.NewSiaPath(rf.SiaPath().String() + "_1")
-	if err != nil {
-		t.Fatal(err)
+	if fis[0].SiaPath != expectedSiaPath {
+		t.Fatalf("Siapath should be '%v' but was '%v'",
+			expectedSiaPath, fis[0].SiaPath)
 	}
-	_, err = r.RenterFileGet(sp)
+	// Check the other fields of the files in a loop since the cached fields might
+	// need some time to update.
+	err = build.Retry(100, time.Second, func() error {
+		fis, err := r.Files(false)
+		if err != nil {
+			return err
+		}
+		sf := fis[0]
+		if sf.AccessTime.IsZero() {
+			return errors.New("AccessTime wasn't set correctly")
+		}
+		if sf.ChangeTime.IsZero() {
+			return errors.New("ChangeTime wasn't set correctly")
+		}
+		if sf.CreateTime.IsZero() {
+			return errors.New("CreateTime wasn't set correctly")
+		}
+		if sf.ModTime.IsZero() {
+			return errors.New("ModTime wasn't set correctly")
+		}
+		if sf.Available {
+			return errors.New("File shouldn't be available since we don't know the hosts")
+		}
+		if sf.CipherType != crypto.TypeTwofish.String() {
+			return fmt.Errorf("CipherType should be twofish but was: %v", sf.CipherType)
+		}
+		if sf.Filesize != 4096 {
+			return fmt.Errorf("Filesize should be 4096 but was: %v", sf.Filesize)
+		}
+		if sf.Expiration != 91 {
+			return fmt.Errorf("Expiration should be 91 but was: %v", sf.Expiration)
+		}
+		if sf.LocalPath != "/tmp/SiaTesting/siatest/TestRenterTwo/gctwr-EKYAZSVOZ6U2T4HZYIAQ/files/4096bytes 16951a61" {
+			return errors.New("LocalPath doesn't match")
+		}
+		if sf.Redundancy != 0 {
+			return errors.New("Redundancy should be 0 since we don't know the hosts")
+		}
+		if sf.UploadProgress != 100 {
+			return fmt.Errorf("File was uploaded before so the progress should be 100 but was %v", sf.UploadProgress)
+		}
+		if sf.UploadedBytes != 40960 {
+			return errors.New("Redundancy should be 10/20 so 10x the Filesize = 40960 bytes should be uploaded")
+		}
+		if sf.OnDisk {
+			return errors.New("OnDisk should be false but was true")
+		}
+		if sf.Recoverable {
+			return errors.New("Recoverable should be false but was true")
+		}
+		if !sf.Renewing {
+			return errors.New("Renewing should be true but wasn't")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	// The .siadir file should still exist.
-	if _, err := os.Stat(dirMDPath); os.IsNotExist(err) {
-		t.Fatal(".siadir file doesn't exist")
-	}
-	// There shouldn't be a .siadir_1 file as we don't replace existing .siadir
-	// files.
-	if _, err := os.Stat(dirMDPath + "_1"); !os.IsNotExist(err) {
-		t.Fatal(".siadir_1 file does exist")
-	}
 }
 
-// TestRemoveRecoverableContracts makes sure that recoverable contracts which
-// have been reverted by a reorg are removed from the map.
-func TestRemoveRecoverableContracts(t *testing.T) {
+// TestRenterContractInitRecoveryScan tests that a renter which has already
+// scanned the whole blockchain and has lost its contracts, can recover them by
+// triggering a rescan through the API.
+func TestRenterContractInitRecoveryScan(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-
-	// Create a testgroup, creating without renter so the renter's
-	// contract transactions can easily be obtained.
+	// Create a testgroup.
 	groupParams := siatest.GroupParams{
-		Hosts:   2,
-		Miners:  1,
-		Renters: 1,
+		Hosts:  2,
+		Miners: 1,
 	}
 	testDir := renterTestDir(t.Name())
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
@@ -4396,49 +4435,70 @@ func TestRemoveRecoverableContracts(t *testing.T) {
 		}
 	}()
 
-	// Get the renter node and its seed.
-	r := tg.Renters()[0]
-	wsg, err := r.WalletSeedsGet()
+	// Add a Renter node
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.ContractorDeps = &dependencies.DependencyDisableRecoveryStatusReset{}
+	_, err = tg.AddNodes(renterParams)
 	if err != nil {
 		t.Fatal(err)
 	}
-	seed := wsg.PrimarySeed
+	r := tg.Renters()[0]
 
-	// The renter should have one contract with each host.
+	// Upload a file to the renter.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	fileSize := int(10 * modules.SectorSize)
+	_, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+
+	// Remember the contracts the renter formed with the hosts.
+	oldContracts := make(map[types.FileContractID]api.RenterContract)
 	rc, err := r.RenterContractsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rc.ActiveContracts) != len(tg.Hosts()) {
-		t.Fatal("Insufficient active contracts")
+	for _, c := range rc.ActiveContracts {
+		oldContracts[c.ID] = c
+	}
+
+	// Cancel the allowance to avoid new contracts replacing the recoverable
+	// ones.
+	if err := r.RenterCancelAllowance(); err != nil {
+		t.Fatal(err)
 	}
 
 	// Stop the renter.
-	if err := tg.RemoveNode(r); err != nil {
+	if err := tg.StopNode(r); err != nil {
 		t.Fatal(err)
 	}
-	// Bring up new hosts for the new renter to form contracts with, otherwise no
-	// contracts will form because it will not form contracts with hosts it see to
-	// have recoverable contracts with
-	_, err = tg.AddNodeN(node.HostTemplate, 2)
-	if err != nil {
-		t.Fatal("Failed to create a new host", err)
+
+	// Delete the contracts.
+	if err := os.RemoveAll(filepath.Join(r.Dir, modules.RenterDir, "contracts")); err != nil {
+		t.Fatal(err)
 	}
 
-	// Start a new renter with the same seed but disable contract recovery.
-	newRenterDir := filepath.Join(testDir, "renter")
-	renterParams := node.Renter(newRenterDir)
-	renterParams.Allowance = modules.DefaultAllowance
-	renterParams.Allowance.Hosts = 2
-	renterParams.PrimarySeed = seed
-	renterParams.ContractorDeps = &dependencies.DependencyDisableContractRecovery{}
-	nodes, err := tg.AddNodes(renterParams)
+	// Start the renter again.
+	if err := tg.StartNode(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// The renter shouldn't have any contracts.
+	rcg, err := r.RenterContractsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	newRenter := nodes[0]
+	if len(rcg.ActiveContracts)+len(rcg.InactiveContracts)+len(rcg.ExpiredContracts) > 0 {
+		t.Fatal("There shouldn't be any contracts after deleting them")
+	}
 
-	// The new renter should have the right number of recoverable contracts.
+	// Trigger a rescan of the blockchain.
+	if err := r.RenterInitContractRecoveryScanPost(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new renter should have the same active contracts as the old one.
 	miner := tg.Miners()[0]
 	numRetries := 0
 	err = build.Retry(60, time.Second, func() error {
@@ -4448,169 +4508,70 @@ func TestRemoveRecoverableContracts(t *testing.T) {
 			}
 		}
 		numRetries++
-		rc, err = newRenter.RenterRecoverableContractsGet()
+		rc, err = r.RenterContractsGet()
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(rc.RecoverableContracts) != len(tg.Hosts()) {
-			return fmt.Errorf("Don't have enough recoverable contracts, expected %v but was %v",
-				len(tg.Hosts()), len(rc.RecoverableContracts))
-		}
-		return nil
+		if len(rc.ActiveContracts) != len(oldContracts) {
+			return fmt.Errorf("Didn't recover the right number of contracts, expected %v but was %v",
+				len(oldContracts), len(rc.ActiveContracts))
+		}
+		for _, c := range rc.ActiveContracts {
+			contract, exists := oldContracts[c.ID]
+			if !exists {
+				return errors.New(fmt.Sprint("Recovered unknown contract", c.ID))
+			}
+			if contract.HostPublicKey.String() != c.HostPublicKey.String() {
+				return errors.New("public keys don't match")
+			}
+			if contract.EndHeight != c.EndHeight {
+				return errors.New("endheights don't match")
+			}
+			if contract.GoodForRenew != c.GoodForRenew {
+				return errors.New("GoodForRenew doesn't match")
+			}
+			if contract.GoodForUpload != c.GoodForUpload {
+				return errors.New("GoodForRenew doesn't match")
+			}
+		}
+		return nil
 	})
-
-	// Get the current blockheight of the group.
-	cg, err := newRenter.ConsensusGet()
 	if err != nil {
+		rc, _ = r.RenterContractsGet()
+		t.Log("Contracts in total:", len(rc.Contracts))
 		t.Fatal(err)
 	}
-	bh := cg.Height
-
-	// Start a new miner which has a longer chain than the group.
-	newMiner, err := siatest.NewNode(siatest.Miner(filepath.Join(testDir, "miner")))
+	// Download the whole file again to see if all roots were recovered.
+	_, err = r.DownloadByStream(rf)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := newMiner.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-	// Mine a longer chain.
-	for i := types.BlockHeight(0); i < bh+10; i++ {
-		if err := newMiner.MineBlock(); err != nil {
-			t.Fatal(err)
-		}
-	}
-	// Connect the miner to the renter.
-	gg, err := newRenter.GatewayGet()
+	// Check that the RecoveryScanStatus was set.
+	rrs, err := r.RenterContractRecoveryProgressGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := newMiner.GatewayConnectPost(gg.NetAddress); err != nil {
-		t.Fatal(err)
-	}
-	// The recoverable contracts should be gone now after the reorg.
-	err = build.Retry(60, time.Second, func() error {
-		rc, err = newRenter.RenterRecoverableContractsGet()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(rc.RecoverableContracts) != 0 {
-			return fmt.Errorf("Expected no recoverable contracts, but was %v",
-				len(rc.RecoverableContracts))
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		// Check the recovery progress endpoint.
+		if !rrs.ScanInProgress || rrs.ScannedHeight == 0 {
+			return fmt.Errorf("ScanInProgress and/or ScannedHeight weren't set correctly: %v", rrs)
 		}
 		return nil
 	})
-}
-
-// testFileAvailableAndRecoverable checks to make sure that the API properly
-// reports if a file is available and/or recoverable
-func testFileAvailableAndRecoverable(t *testing.T, tg *siatest.TestGroup) {
-	// Grab the first of the group's renters
-	r := tg.Renters()[0]
-
-	// Check that we have 5 hosts for this test so that the redundancy
-	// assumptions work for the test
-	if len(tg.Hosts()) != 5 {
-		t.Fatal("This test requires 5 hosts")
-	}
-
-	// Set fileSize and redundancy for upload
-	fileSize := int(modules.SectorSize)
-	dataPieces := uint64(4)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-
-	// Upload file
-	localFile, remoteFile, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Get the file info and check if it is available and recoverable. File
-	// should be available, recoverable, redundancy >1, and the file should be
-	// on disk
-	fi, err := r.File(remoteFile)
-	if err != nil {
-		t.Fatal("failed to get file info", err)
-	}
-	if fi.Redundancy < 1 {
-		t.Fatal("redundancy of file is less than 1:", fi.Redundancy)
-	}
-	if !fi.OnDisk {
-		t.Fatal("file is not on disk")
-	}
-	if !fi.Available {
-		t.Fatal("file is not available")
-	}
-	if !fi.Recoverable {
-		t.Fatal("file is not recoverable")
-	}
-
-	// Take down two hosts so that the redundancy drops below 1
-	for i := 0; i < 2; i++ {
-		if err := tg.RemoveNode(tg.Hosts()[0]); err != nil {
-			t.Fatal("Failed to shutdown host", err)
-		}
-	}
-	expectedRedundancy := float64(dataPieces+parityPieces-2) / float64(dataPieces)
-	if err := r.WaitForDecreasingRedundancy(remoteFile, expectedRedundancy); err != nil {
-		t.Fatal("Redundancy isn't decreasing", err)
-	}
-
-	// Get file into, file should not be available because the redundancy is  <1
-	// but it should be recoverable because the file is on disk
-	fi, err = r.File(remoteFile)
-	if err != nil {
-		t.Fatal("failed to get file info", err)
-	}
-	if fi.Redundancy >= 1 {
-		t.Fatal("redundancy of file should be less than 1:", fi.Redundancy)
-	}
-	if !fi.OnDisk {
-		t.Fatal("file is not on disk")
-	}
-	if fi.Available {
-		t.Fatal("file should not be available")
-	}
-	if !fi.Recoverable {
-		t.Fatal("file should be recoverable")
-	}
-
-	// Delete the file locally.
-	if err := localFile.Delete(); err != nil {
-		t.Fatal("failed to delete local file", err)
-	}
-
-	// Get file into, file should now not be available or recoverable
-	fi, err = r.File(remoteFile)
-	if err != nil {
-		t.Fatal("failed to get file info", err)
-	}
-	if fi.Redundancy >= 1 {
-		t.Fatal("redundancy of file should be less than 1:", fi.Redundancy)
-	}
-	if fi.OnDisk {
-		t.Fatal("file is still on disk")
-	}
-	if fi.Available {
-		t.Fatal("file should not be available")
-	}
-	if fi.Recoverable {
-		t.Fatal("file should not be recoverable")
-	}
 }
 
-// TestRenterDownloadWithDrainedContract tests if draining a contract below
-// MinContractFundUploadThreshold correctly sets a contract to !GoodForUpload
-// while still being able to download the file.
-func TestRenterDownloadWithDrainedContract(t *testing.T) {
-	if testing.Short() || !build.VLONG {
+// TestRenterContractAutomaticRecoveryScan tests that a renter which has already
+// scanned the whole blockchain and has lost its contracts, will recover them
+// automatically during the next contract maintenance.
+func TestRenterContractAutomaticRecoveryScan(t *testing.T) {
+	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
-
-	// Create a group for testing
+	// Create a testgroup.
 	groupParams := siatest.GroupParams{
 		Hosts:  2,
 		Miners: 1,
@@ -4618,193 +4579,1237 @@ func TestRenterDownloadWithDrainedContract(t *testing.T) {
 	testDir := renterTestDir(t.Name())
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
-		t.Fatal("Failed to create group:", err)
+		t.Fatal("Failed to create group: ", err)
 	}
 	defer func() {
 		if err := tg.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	// Add a renter with a dependency that prevents contract renewals due to
-	// low funds.
+
+	// Add a renter node that can't run the automatic contract recovery scan.
 	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.RenterDeps = &dependencies.DependencyDisableRenewal{}
-	nodes, err := tg.AddNodes(renterParams)
+	renterParams.ContractorDeps = &dependencies.DependencyDisableRecoveryStatusReset{}
+	_, err = tg.AddNodes(renterParams)
 	if err != nil {
 		t.Fatal(err)
 	}
-	renter := nodes[0]
-	miner := tg.Miners()[0]
-	// Drain the contracts until they are supposed to no longer be good for
-	// uploading.
-	_, err = drainContractsByUploading(renter, tg, contractor.MinContractFundUploadThreshold)
+	r := tg.Renters()[0]
+
+	// Upload a file to the renter.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	fileSize := int(10 * modules.SectorSize)
+	_, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Failed to upload a file for testing: ", err)
 	}
-	numRetries := 0
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		// The 2 contracts should no longer be good for upload.
-		rc, err := renter.RenterContractsGet()
-		if err != nil {
-			return err
-		}
-		if numRetries%10 == 0 {
-			if err := miner.MineBlock(); err != nil {
-				return err
-			}
-		}
-		numRetries++
-		if len(rc.Contracts) != len(tg.Hosts()) {
-			return fmt.Errorf("There should be %v contracts but was %v", len(tg.Hosts()), len(rc.Contracts))
-		}
-		for _, c := range rc.Contracts {
-			if c.GoodForUpload || !c.GoodForRenew {
-				return fmt.Errorf("Contract shouldn't be good for uploads but it should be good for renew: %v %v",
-					c.GoodForUpload, c.GoodForRenew)
-			}
-		}
-		return nil
-	})
+
+	// Remember the contracts the renter formed with the hosts.
+	oldContracts := make(map[types.FileContractID]api.RenterContract)
+	rc, err := r.RenterContractsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Choose a random file and download it.
-	files, err := renter.Files(false)
-	if err != nil {
-		t.Fatal(err)
+	for _, c := range rc.ActiveContracts {
+		oldContracts[c.ID] = c
 	}
-	_, err = renter.RenterStreamGet(files[fastrand.Intn(len(files))].SiaPath)
-	if err != nil {
+
+	// Cancel the allowance to avoid new contracts replacing the recoverable
+	// ones.
+	if err := r.RenterCancelAllowance(); err != nil {
 		t.Fatal(err)
 	}
-}
 
-// testSetFileStuck tests that manually setting the 'stuck' field of a file
-// works as expected.
-func testSetFileStuck(t *testing.T, tg *siatest.TestGroup) {
-	// Grab the first of the group's renters
-	r := tg.Renters()[0]
+	// Stop the renter.
+	if err := tg.StopNode(r); err != nil {
+		t.Fatal(err)
+	}
 
-	// Check if there are already uploaded file we can use.
-	rfg, err := r.RenterFilesGet(false)
-	if err != nil {
+	// Delete the contracts.
+	if err := os.RemoveAll(filepath.Join(r.Dir, modules.RenterDir, "contracts")); err != nil {
 		t.Fatal(err)
 	}
-	if len(rfg.Files) == 0 {
-		// Set fileSize and redundancy for upload
-		dataPieces := uint64(len(tg.Hosts()) - 1)
-		parityPieces := uint64(len(tg.Hosts())) - dataPieces
-		fileSize := int(dataPieces * modules.SectorSize)
 
-		// Upload file
-		_, _, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	// Start the renter again. This time it's unlocked and the automatic recovery
+	// scan isn't disabled.
+	if err := tg.StartNodeCleanDeps(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// The renter shouldn't have any contracts.
+	rcg, err := r.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rcg.ActiveContracts)+len(rcg.InactiveContracts)+len(rcg.ExpiredContracts) > 0 {
+		t.Fatal("There shouldn't be any contracts after deleting them")
+	}
+
+	// The new renter should have the same active contracts as the old one.
+	miner := tg.Miners()[0]
+	numRetries := 0
+	err = build.Retry(60, time.Second, func() error {
+		if numRetries%10 == 0 {
+			if err := miner.MineBlock(); err != nil {
+				return err
+			}
+		}
+		numRetries++
+		rc, err = r.RenterContractsGet()
 		if err != nil {
 			t.Fatal(err)
 		}
+		if len(rc.ActiveContracts) != len(oldContracts) {
+			return fmt.Errorf("Didn't recover the right number of contracts, expected %v but was %v",
+				len(oldContracts), len(rc.ActiveContracts))
+		}
+		for _, c := range rc.ActiveContracts {
+			contract, exists := oldContracts[c.ID]
+			if !exists {
+				return errors.New(fmt.Sprint("Recovered unknown contract", c.ID))
+			}
+			if contract.HostPublicKey.String() != c.HostPublicKey.String() {
+				return errors.New("public keys don't match")
+			}
+			if contract.EndHeight != c.EndHeight {
+				return errors.New("endheights don't match")
+			}
+			if contract.GoodForRenew != c.GoodForRenew {
+				return errors.New("GoodForRenew doesn't match")
+			}
+			if contract.GoodForUpload != c.GoodForUpload {
+				return errors.New("GoodForRenew doesn't match")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		rc, _ = r.RenterContractsGet()
+		t.Log("Contracts in total:", len(rc.Contracts))
+		t.Fatal(err)
 	}
-	// Get a file.
-	rfg, err = r.RenterFilesGet(false)
+	// Download the whole file again to see if all roots were recovered.
+	_, err = r.DownloadByStream(rf)
 	if err != nil {
 		t.Fatal(err)
 	}
-	f := rfg.Files[0]
-	// Set stuck to the opposite value it had before.
-	if err := r.RenterSetFileStuckPost(f.SiaPath, !f.Stuck); err != nil {
+}
+
+// TestCreateLoadBackup tests that creating a backup with the /renter/backup
+// endpoint works as expected and that it can be loaded with the
+// /renter/recoverbackup endpoint.
+func TestCreateLoadBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Miners:  1,
+		Renters: 1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Create a subdir in the renter's files folder.
+	r := tg.Renters()[0]
+	subDir, err := r.FilesDir().CreateDir("subDir")
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Check if it was set correctly.
-	fi, err := r.RenterFileGet(f.SiaPath)
+	// Add a file to that dir.
+	lf, err := subDir.NewFile(100)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if fi.File.Stuck == f.Stuck {
-		t.Fatalf("Stuck field should be %v but was %v", !f.Stuck, fi.File.Stuck)
+	// Upload the file.
+	dataPieces := uint64(len(tg.Hosts()) - 1)
+	parityPieces := uint64(1)
+	rf, err := r.UploadBlocking(lf, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
 	}
-	// Set stuck to the original value.
-	if err := r.RenterSetFileStuckPost(f.SiaPath, f.Stuck); err != nil {
+	// Delete the file locally.
+	if err := lf.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	// Create a backup.
+	backupPath := filepath.Join(r.FilesDir().Path(), "test.backup")
+	err = r.RenterCreateLocalBackupPost(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recover the backup into the same renter. Nothing should change.
+	if err := r.RenterRecoverLocalBackupPost(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	files, err := r.Files(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatal("expected 1 file but got", len(files))
+	}
+	// Get the renter's seed.
+	wsg, err := r.WalletSeedsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shut down the renter.
+	if err := tg.RemoveNode(r); err != nil {
+		t.Fatal(err)
+	}
+	// Start a new renter from the same seed Disable its health and repair loops to
+	// avoid updating the .siadir file.
+	rt := node.RenterTemplate
+	rt.PrimarySeed = wsg.PrimarySeed
+	nodes, err := tg.AddNodes(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = nodes[0]
+	// Recover the backup.
+	if err := r.RenterRecoverLocalBackupPost(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	// The .siadir file should also be recovered.
+	dirMDPath := filepath.Join(r.Dir, modules.RenterDir, modules.SiapathRoot, "subDir", modules.SiaDirExtension)
+	if _, err := os.Stat(dirMDPath); os.IsNotExist(err) {
+		t.Fatal(".siadir file doesn't exist")
+	}
+	// There shouldn't be a .siadir_1 file as we don't replace existing .siadir
+	// files.
+	if _, err := os.Stat(dirMDPath + "_1"); !os.IsNotExist(err) {
+		t.Fatal(".siadir_1 file does exist")
+	}
+	// The file should be available and ready for download again.
+	if _, err := r.DownloadByStream(rf); err != nil {
+		t.Fatal(err)
+	}
+	// Delete the file and upload another file to the same siapath. This one should
+	// have the same siapath but not the same UID.
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	subDir, err = r.FilesDir().CreateDir("subDir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf, err = subDir.NewFileWithName(lf.FileName(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf, err = r.UploadBlocking(lf, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recover the backup again. Now there should be another file with a suffix at
+	// the end.
+	if err := r.RenterRecoverLocalBackupPost(backupPath); err != nil {
+		t.Fatal(err)
+	}
+	fis, err := r.RenterFilesGet(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis.Files) != 2 {
+		t.Fatalf("Expected 2 files but got %v", len(fis.Files))
+	}
+	sp, err := modules.NewSiaPath(rf.SiaPath().String() + "_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.RenterFileGet(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The .siadir file should still exist.
+	if _, err := os.Stat(dirMDPath); os.IsNotExist(err) {
+		t.Fatal(".siadir file doesn't exist")
+	}
+	// There shouldn't be a .siadir_1 file as we don't replace existing .siadir
+	// files.
+	if _, err := os.Stat(dirMDPath + "_1"); !os.IsNotExist(err) {
+		t.Fatal(".siadir_1 file does exist")
+	}
+}
+
+// TestRemoveRecoverableContracts makes sure that recoverable contracts which
+// have been reverted by a reorg are removed from the map.
+func TestRemoveRecoverableContracts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup, creating without renter so the renter's
+	// contract transactions can easily be obtained.
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Miners:  1,
+		Renters: 1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Get the renter node and its seed.
+	r := tg.Renters()[0]
+	wsg, err := r.WalletSeedsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := wsg.PrimarySeed
+
+	// The renter should have one contract with each host.
+	rc, err := r.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.ActiveContracts) != len(tg.Hosts()) {
+		t.Fatal("Insufficient active contracts")
+	}
+
+	// Stop the renter.
+	if err := tg.RemoveNode(r); err != nil {
+		t.Fatal(err)
+	}
+	// Bring up new hosts for the new renter to form contracts with, otherwise no
+	// contracts will form because it will not form contracts with hosts it see to
+	// have recoverable contracts with
+	_, err = tg.AddNodeN(node.HostTemplate, 2)
+	if err != nil {
+		t.Fatal("Failed to create a new host", err)
+	}
+
+	// Start a new renter with the same seed but disable contract recovery.
+	newRenterDir := filepath.Join(testDir, "renter")
+	renterParams := node.Renter(newRenterDir)
+	renterParams.Allowance = modules.DefaultAllowance
+	renterParams.Allowance.Hosts = 2
+	renterParams.PrimarySeed = seed
+	renterParams.ContractorDeps = &dependencies.DependencyDisableContractRecovery{}
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRenter := nodes[0]
+
+	// The new renter should have the right number of recoverable contracts.
+	miner := tg.Miners()[0]
+	numRetries := 0
+	err = build.Retry(60, time.Second, func() error {
+		if numRetries%10 == 0 {
+			if err := miner.MineBlock(); err != nil {
+				return err
+			}
+		}
+		numRetries++
+		rc, err = newRenter.RenterRecoverableContractsGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rc.RecoverableContracts) != len(tg.Hosts()) {
+			return fmt.Errorf("Don't have enough recoverable contracts, expected %v but was %v",
+				len(tg.Hosts()), len(rc.RecoverableContracts))
+		}
+		return nil
+	})
+
+	// Get the current blockheight of the group.
+	cg, err := newRenter.ConsensusGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bh := cg.Height
+
+	// Start a new miner which has a longer chain than the group.
+	newMiner, err := siatest.NewNode(siatest.Miner(filepath.Join(testDir, "miner")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := newMiner.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Mine a longer chain.
+	for i := types.BlockHeight(0); i < bh+10; i++ {
+		if err := newMiner.MineBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Connect the miner to the renter.
+	gg, err := newRenter.GatewayGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newMiner.GatewayConnectPost(gg.NetAddress); err != nil {
+		t.Fatal(err)
+	}
+	// The recoverable contracts should be gone now after the reorg.
+	err = build.Retry(60, time.Second, func() error {
+		rc, err = newRenter.RenterRecoverableContractsGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rc.RecoverableContracts) != 0 {
+			return fmt.Errorf("Expected no recoverable contracts, but was %v",
+				len(rc.RecoverableContracts))
+		}
+		return nil
+	})
+}
+
+// testFileAvailableAndRecoverable checks to make sure that the API properly
+// reports if a file is available and/or recoverable
+func testFileAvailableAndRecoverable(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Check that we have 5 hosts for this test so that the redundancy
+	// assumptions work for the test
+	if len(tg.Hosts()) != 5 {
+		t.Fatal("This test requires 5 hosts")
+	}
+
+	// Set fileSize and redundancy for upload
+	fileSize := int(modules.SectorSize)
+	dataPieces := uint64(4)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+
+	// Upload file
+	localFile, remoteFile, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get the file info and check if it is available and recoverable. File
+	// should be available, recoverable, redundancy >1, and the file should be
+	// on disk
+	fi, err := r.File(remoteFile)
+	if err != nil {
+		t.Fatal("failed to get file info", err)
+	}
+	if fi.Redundancy < 1 {
+		t.Fatal("redundancy of file is less than 1:", fi.Redundancy)
+	}
+	if !fi.OnDisk {
+		t.Fatal("file is not on disk")
+	}
+	if !fi.Available {
+		t.Fatal("file is not available")
+	}
+	if !fi.Recoverable {
+		t.Fatal("file is not recoverable")
+	}
+
+	// Take down two hosts so that the redundancy drops below 1
+	for i := 0; i < 2; i++ {
+		if err := tg.RemoveNode(tg.Hosts()[0]); err != nil {
+			t.Fatal("Failed to shutdown host", err)
+		}
+	}
+	expectedRedundancy := float64(dataPieces+parityPieces-2) / float64(dataPieces)
+	if err := r.WaitForDecreasingRedundancy(remoteFile, expectedRedundancy); err != nil {
+		t.Fatal("Redundancy isn't decreasing", err)
+	}
+
+	// Get file into, file should not be available because the redundancy is  <1
+	// but it should be recoverable because the file is on disk
+	fi, err = r.File(remoteFile)
+	if err != nil {
+		t.Fatal("failed to get file info", err)
+	}
+	if fi.Redundancy >= 1 {
+		t.Fatal("redundancy of file should be less than 1:", fi.Redundancy)
+	}
+	if !fi.OnDisk {
+		t.Fatal("file is not on disk")
+	}
+	if fi.Available {
+		t.Fatal("file should not be available")
+	}
+	if !fi.Recoverable {
+		t.Fatal("file should be recoverable")
+	}
+
+	// Delete the file locally.
+	if err := localFile.Delete(); err != nil {
+		t.Fatal("failed to delete local file", err)
+	}
+
+	// Get file into, file should now not be available or recoverable
+	fi, err = r.File(remoteFile)
+	if err != nil {
+		t.Fatal("failed to get file info", err)
+	}
+	if fi.Redundancy >= 1 {
+		t.Fatal("redundancy of file should be less than 1:", fi.Redundancy)
+	}
+	if fi.OnDisk {
+		t.Fatal("file is still on disk")
+	}
+	if fi.Available {
+		t.Fatal("file should not be available")
+	}
+	if fi.Recoverable {
+		t.Fatal("file should not be recoverable")
+	}
+}
+
+// TestRenterDownloadWithDrainedContract tests if draining a contract below
+// MinContractFundUploadThreshold correctly sets a contract to !GoodForUpload
+// while still being able to download the file.
+func TestRenterDownloadWithDrainedContract(t *testing.T) {
+	if testing.Short() || !build.VLONG {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a group for testing
+	groupParams := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group:", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Add a renter with a dependency that prevents contract renewals due to
+	// low funds.
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.RenterDeps = &dependencies.DependencyDisableRenewal{}
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter := nodes[0]
+	miner := tg.Miners()[0]
+	// Drain the contracts until they are supposed to no longer be good for
+	// uploading.
+	_, err = drainContractsByUploading(renter, tg, contractor.MinContractFundUploadThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numRetries := 0
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		// The 2 contracts should no longer be good for upload.
+		rc, err := renter.RenterContractsGet()
+		if err != nil {
+			return err
+		}
+		if numRetries%10 == 0 {
+			if err := miner.MineBlock(); err != nil {
+				return err
+			}
+		}
+		numRetries++
+		if len(rc.Contracts) != len(tg.Hosts()) {
+			return fmt.Errorf("There should be %v contracts but was %v", len(tg.Hosts()), len(rc.Contracts))
+		}
+		for _, c := range rc.Contracts {
+			if c.GoodForUpload || !c.GoodForRenew {
+				return fmt.Errorf("Contract shouldn't be good for uploads but it should be good for renew: %v %v",
+					c.GoodForUpload, c.GoodForRenew)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Choose a random file and download it.
+	files, err := renter.Files(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = renter.RenterStreamGet(files[fastrand.Intn(len(files))].SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSetFileStuck tests that manually setting the 'stuck' field of a file
+// works as expected.
+func testSetFileStuck(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Check if there are already uploaded file we can use.
+	rfg, err := r.RenterFilesGet(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rfg.Files) == 0 {
+		// Set fileSize and redundancy for upload
+		dataPieces := uint64(len(tg.Hosts()) - 1)
+		parityPieces := uint64(len(tg.Hosts())) - dataPieces
+		fileSize := int(dataPieces * modules.SectorSize)
+
+		// Upload file
+		_, _, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Get a file.
+	rfg, err = r.RenterFilesGet(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := rfg.Files[0]
+	// Set stuck to the opposite value it had before.
+	if err := r.RenterSetFileStuckPost(f.SiaPath, !f.Stuck); err != nil {
+		t.Fatal(err)
+	}
+	// Check if it was set correctly.
+	fi, err := r.RenterFileGet(f.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.File.Stuck == f.Stuck {
+		t.Fatalf("Stuck field should be %v but was %v", !f.Stuck, fi.File.Stuck)
+	}
+	// Set stuck to the original value.
+	if err := r.RenterSetFileStuckPost(f.SiaPath, f.Stuck); err != nil {
+		t.Fatal(err)
+	}
+	// Check if it was set correctly.
+	fi, err = r.RenterFileGet(f.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.File.Stuck != f.Stuck {
+		t.Fatalf("Stuck field should be %v but was %v", f.Stuck, fi.File.Stuck)
+	}
+}
+
+// testStreamRepair tests if repairing a file using the streaming endpoint
+// works.
+func testStreamRepair(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Check that we have enough hosts for this test.
+	if len(tg.Hosts()) < 2 {
+		t.Fatal("This test requires at least 2 hosts")
+	}
+
+	// Set fileSize and redundancy for upload
+	fileSize := int(5 * modules.SectorSize)
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+
+	// Upload file
+	localFile, remoteFile, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the file locally to make sure the repair loop can't find it.
+	if err := localFile.Move(); err != nil {
+		t.Fatal("failed to delete local file", err)
+	}
+
+	// Take down all of the hosts and check if redundancy decreases.
+	hostsRemoved := 0
+	for i := uint64(0); i < parityPieces+dataPieces; i++ {
+		if err := tg.RemoveNode(tg.Hosts()[0]); err != nil {
+			t.Fatal("Failed to shutdown host", err)
+		}
+		hostsRemoved++
+	}
+	if err := r.WaitForDecreasingRedundancy(remoteFile, 0); err != nil {
+		t.Fatal("Redundancy isn't decreasing", err)
+	}
+	// Bring up hosts to replace the ones that went offline.
+	for hostsRemoved > 0 {
+		hostsRemoved--
+		_, err = tg.AddNodes(node.HostTemplate)
+		if err != nil {
+			t.Fatal("Failed to create a new host", err)
+		}
+	}
+	// Use the streaming endpoint to repair the file. It should always reach 100%.
+	b, err := ioutil.ReadFile(localFile.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenterUploadStreamRepairPost(bytes.NewReader(b), remoteFile.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WaitForUploadHealth(remoteFile); err != nil {
+		t.Fatal("File wasn't repaired", err)
+	}
+	// We should be able to download
+	if _, err := r.DownloadByStream(remoteFile); err != nil {
+		t.Fatal("Failed to download file", err)
+	}
+	// Repair the file again to make sure we don't get stuck on chunks that are
+	// already repaired. Datapieces and paritypieces can be set to 0 as long as
+	// repair is true.
+	if err := r.RenterUploadStreamRepairPost(bytes.NewReader(b), remoteFile.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRemoteBackup tests creating and loading remote backups.
+func TestRemoteBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a testgroup.
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Miners:  1,
+		Renters: 1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group: ", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Create a subdir in the renter's files folder.
+	r := tg.Renters()[0]
+	subDir, err := r.FilesDir().CreateDir("subDir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Add a file to that dir.
+	lf, err := subDir.NewFile(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Upload the file.
+	dataPieces := uint64(len(tg.Hosts()) - 1)
+	parityPieces := uint64(1)
+	rf, err := r.UploadBlocking(lf, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+	// Create a snapshot.
+	createSnapshot := func(name string) error {
+		if err := r.RenterCreateBackupPost(name); err != nil {
+			return err
+		}
+		// wait for backup to upload
+		return build.Retry(60, time.Second, func() error {
+			ubs, _ := r.RenterBackups()
+			for _, ub := range ubs.Backups {
+				if ub.Name != name {
+					continue
+				} else if ub.UploadProgress != 100 {
+					return fmt.Errorf("backup not uploaded: %v", ub.UploadProgress)
+				}
+				return nil
+			}
+			return errors.New("backup not found")
+		})
+	}
+	if err := createSnapshot("foo"); err != nil {
+		t.Fatal(err)
+	}
+	// Delete the file locally.
+	if err := lf.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload another file and take another snapshot.
+	lf2, err := subDir.NewFile(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf2, err := r.UploadBlocking(lf2, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+	if err := createSnapshot("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lf2.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both snapshots should be listed.
+	ubs, err := r.RenterBackups()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ubs.Backups) != 2 {
+		t.Fatal("expected two backups, got", ubs)
+	}
+
+	// Delete both files and restore the first snapshot.
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenterDeletePost(rf2.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenterRecoverBackupPost("foo"); err != nil {
+		t.Fatal(err)
+	}
+	// We should be able to download the first file.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		_, err = r.DownloadToDisk(rf, false)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second file should still fail.
+	if _, err := r.DownloadToDisk(rf2, false); err == nil {
+		t.Fatal("expected second file to be unavailable")
+	}
+	// Delete the first file again.
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the second snapshot.
+	if err := r.RenterRecoverBackupPost("bar"); err != nil {
+		t.Fatal(err)
+	}
+	// We should be able to download both files now.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		_, err = r.DownloadToDisk(rf, false)
+		if err != nil {
+			return err
+		}
+		_, err = r.DownloadToDisk(rf2, false)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm siadir exists by querying directory
+	rd, err := r.RenterGetDir(modules.RootSiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Directories) != 2 {
+		t.Fatal("Expected root and 1 subdirectory but got", rd.Directories)
+	}
+	if len(rd.Files) != 0 {
+		t.Fatal("Expected 0 files but got", rd.Files)
+	}
+	rd, err = r.RenterGetDir(rd.Directories[1].SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Directories) != 1 {
+		t.Fatal("expected only root directory but got", rd.Directories)
+	}
+	if len(rd.Files) != 2 {
+		t.Fatal("Expected 2 files but got", rd.Files)
+	}
+
+	// Delete the renter entirely and create a new renter with the same seed.
+	wsg, err := r.WalletSeedsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := wsg.PrimarySeed
+	if err := tg.RemoveNode(r); err != nil {
+		t.Fatal(err)
+	}
+	renterParams := node.Renter(filepath.Join(testDir, "renter"))
+	renterParams.PrimarySeed = seed
+	nodes, err := tg.AddNodes(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = nodes[0]
+
+	// Wait for the recovery process to complete.
+	err = build.Retry(60, time.Second, func() error {
+		// Both snapshots should be listed.
+		ubs, err = r.RenterBackups()
+		if err != nil {
+			return err
+		} else if len(ubs.Backups) != 2 {
+			return fmt.Errorf("expected two backups, got %v", ubs.Backups)
+		} else if len(ubs.SyncedHosts) != 2 {
+			return fmt.Errorf("expected two synced hosts, got %v", len(ubs.SyncedHosts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the second snapshot.
+	if err := r.RenterRecoverBackupPost("bar"); err != nil {
+		t.Fatal(err)
+	}
+	// We should be able to download both files now.
+	if _, err := r.DownloadToDisk(rf, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DownloadToDisk(rf2, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm siadir exists by querying directory
+	rd, err = r.RenterGetDir(modules.RootSiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Directories) != 2 {
+		t.Fatal("Expected root and 1 subdirectory but got", rd.Directories)
+	}
+	if len(rd.Files) != 0 {
+		t.Fatal("Expected 0 files but got", rd.Files)
+	}
+	rd, err = r.RenterGetDir(rd.Directories[1].SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rd.Directories) != 1 {
+		t.Fatal("expected only root directory but got", rd.Directories)
+	}
+	if len(rd.Files) != 2 {
+		t.Fatal("Expected 2 files but got", rd.Files)
+	}
+}
+
+// testEscapeSiaPath tests that SiaPaths are escaped correctly to handle escape
+// characters
+func testEscapeSiaPath(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Check that we have enough hosts for this test.
+	if len(tg.Hosts()) < 2 {
+		t.Fatal("This test requires at least 2 hosts")
+	}
+
+	// Set fileSize and redundancy for upload
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+
+	// Create Local File
+	lf, err := r.FilesDir().NewFile(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// File names to tests
+	names := []string{
+		"dollar$sign",
+		"and&sign",
+		"single`quote",
+		"full:colon",
+		"semi;colon",
+		"hash#tag",
+		"percent%sign",
+		"at@sign",
+		"less<than",
+		"greater>than",
+		"equal=to",
+		"question?mark",
+		"open[bracket",
+		"close]bracket",
+		"open{bracket",
+		"close}bracket",
+		"carrot^top",
+		"pipe|pipe",
+		"tilda~tilda",
+		"plus+sign",
+		"minus-sign",
+		"under_score",
+		"comma,comma",
+		"apostrophy's",
+		`quotation"marks`,
+	}
+	for _, s := range names {
+		// Create SiaPath
+		siaPath, err := modules.NewSiaPath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Upload file
+		_, err = r.Upload(lf, siaPath, dataPieces, parityPieces, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Confirm we can get file
+		_, err = r.RenterFileGet(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testValidateSiaPath tests the validate siapath endpoint
+func testValidateSiaPath(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Create siapaths to test
+	var pathTests = []struct {
+		path  string
+		valid bool
+	}{
+		{"valid/siapath", true},
+		{"\\some\\windows\\path", true}, // clean converts OS separators
+		{"../../../directory/traversal", false},
+		{"testpath", true},
+		{"valid/siapath/../with/directory/traversal", false},
+		{"validpath/test", true},
+		{"..validpath/..test", true},
+		{"./invalid/path", false},
+		{".../path", true},
+		{"valid./path", true},
+		{"valid../path", true},
+		{"valid/path./test", true},
+		{"valid/path../test", true},
+		{"test/path", true},
+		{"/leading/slash", false}, // this is not valid through the api because a leading slash is added by the api call so this turns into 2 leading slashes
+		{"foo/./bar", false},
+		{"", false},
+		{"blank/end/", true}, // clean will trim trailing slashes so this is a valid input
+		{"double//dash", false},
+		{"../", false},
+		{"./", false},
+		{".", false},
+	}
+	// Test all siapaths
+	for _, pathTest := range pathTests {
+		err := r.RenterValidateSiaPathPost(pathTest.path)
+		// Verify expected Error
+		if err != nil && pathTest.valid {
+			t.Fatal("validateSiapath failed on valid path: ", pathTest.path)
+		}
+		if err == nil && !pathTest.valid {
+			t.Fatal("validateSiapath succeeded on invalid path: ", pathTest.path)
+		}
+	}
+
+	// Create SiaPaths that contain escape characters
+	var escapeCharTests = []struct {
+		path  string
+		valid bool
+	}{
+		{"dollar$sign", true},
+		{"and&sign", true},
+		{"single`quote", true},
+		{"full:colon", true},
+		{"semi;colon", true},
+		{"hash#tag", true},
+		{"percent%sign", true},
+		{"at@sign", true},
+		{"less<than", true},
+		{"greater>than", true},
+		{"equal=to", true},
+		{"question?mark", true},
+		{"open[bracket", true},
+		{"close]bracket", true},
+		{"open{bracket", true},
+		{"close}bracket", true},
+		{"carrot^top", true},
+		{"pipe|pipe", true},
+		{"tilda~tilda", true},
+		{"plus+sign", true},
+		{"minus-sign", true},
+		{"under_score", true},
+		{"comma,comma", true},
+		{"apostrophy's", true},
+		{`quotation"marks`, true},
+	}
+	// Test all escape charcter siapaths
+	for _, escapeCharTest := range escapeCharTests {
+		path := url.PathEscape(escapeCharTest.path)
+		err := r.RenterValidateSiaPathPost(path)
+		// Verify expected Error
+		if err != nil && escapeCharTest.valid {
+			t.Fatalf("validateSiapath failed on valid path %v, escaped %v ", escapeCharTest.path, path)
+		}
+		if err == nil && !escapeCharTest.valid {
+			t.Fatalf("validateSiapath succeeded on invalid path %v, escaped %v ", escapeCharTest.path, path)
+		}
+	}
+}
+
+// TestOutOfStorageHandling makes sure that we form a new contract to replace a
+// host that has run out of storage while still keeping it around as
+// goodForRenew.
+func TestOutOfStorageHandling(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a group with 1 default host.
+	gp := siatest.GroupParams{
+		Hosts:  1,
+		Miners: 1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Prepare a host that offers the minimum storage possible.
+	hostTemplate := node.Host(filepath.Join(testDir, "host1"))
+	hostTemplate.HostStorage = modules.SectorSize * contractmanager.MinimumSectorsPerStorageFolder
+
+	// Prepare a renter that expects to upload 1 Sector of data to 2 hosts at a 2x
+	// redundancy. We set the ExpectedStorage lower than the available storage on
+	// the host to make sure it's not penalized.
+	renterTemplate := node.Renter(filepath.Join(testDir, "renter"))
+	dataPieces := uint64(1)
+	parityPieces := uint64(1)
+	allowance := siatest.DefaultAllowance
+	allowance.ExpectedRedundancy = float64(dataPieces+parityPieces) / float64(dataPieces)
+	allowance.ExpectedStorage = modules.SectorSize // 4 KiB
+	allowance.Hosts = 2
+	renterTemplate.Allowance = allowance
+
+	// Add the host and renter to the group.
+	nodes, err := tg.AddNodes(hostTemplate)
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Check if it was set correctly.
-	fi, err = r.RenterFileGet(f.SiaPath)
+	host := nodes[0]
+	nodes, err = tg.AddNodes(renterTemplate)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if fi.File.Stuck != f.Stuck {
-		t.Fatalf("Stuck field should be %v but was %v", f.Stuck, fi.File.Stuck)
-	}
-}
-
-// testStreamRepair tests if repairing a file using the streaming endpoint
-// works.
-func testStreamRepair(t *testing.T, tg *siatest.TestGroup) {
-	// Grab the first of the group's renters
-	r := tg.Renters()[0]
-
-	// Check that we have enough hosts for this test.
-	if len(tg.Hosts()) < 2 {
-		t.Fatal("This test requires at least 2 hosts")
-	}
-
-	// Set fileSize and redundancy for upload
-	fileSize := int(5 * modules.SectorSize)
-	dataPieces := uint64(1)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	renter := nodes[0]
 
-	// Upload file
-	localFile, remoteFile, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	// Upload a file to fill up the host.
+	_, _, err = renter.UploadNewFileBlocking(int(hostTemplate.HostStorage), dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Move the file locally to make sure the repair loop can't find it.
-	if err := localFile.Move(); err != nil {
-		t.Fatal("failed to delete local file", err)
-	}
-
-	// Take down all of the hosts and check if redundancy decreases.
-	hostsRemoved := 0
-	for i := uint64(0); i < parityPieces+dataPieces; i++ {
-		if err := tg.RemoveNode(tg.Hosts()[0]); err != nil {
-			t.Fatal("Failed to shutdown host", err)
-		}
-		hostsRemoved++
+	// Make sure the host is full.
+	hg, err := host.HostGet()
+	if hg.ExternalSettings.RemainingStorage != 0 {
+		t.Fatal("Expected remaining storage to be 0 but was", hg.ExternalSettings.RemainingStorage)
 	}
-	if err := r.WaitForDecreasingRedundancy(remoteFile, 0); err != nil {
-		t.Fatal("Redundancy isn't decreasing", err)
+	// Start uploading another file in the background to trigger the OOS error.
+	_, rf, err := renter.UploadNewFile(int(2*modules.SectorSize), dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Bring up hosts to replace the ones that went offline.
-	for hostsRemoved > 0 {
-		hostsRemoved--
-		_, err = tg.AddNodes(node.HostTemplate)
+	// Make sure the host's contract is no longer good for upload but still good
+	// for renew.
+	err = build.Retry(10, time.Second, func() error {
+		if err := tg.Miners()[0].MineBlock(); err != nil {
+			t.Fatal(err)
+		}
+		hpk, err := host.HostPublicKey()
 		if err != nil {
-			t.Fatal("Failed to create a new host", err)
+			return err
+		}
+		rcg, err := renter.RenterContractsGet()
+		if err != nil {
+			return err
+		}
+		// One contract should be good for uploads and renewal and is therefore
+		// active.
+		if len(rcg.ActiveContracts) != 1 {
+			return fmt.Errorf("Expected 1 active contract but got %v", len(rcg.ActiveContracts))
+		}
+		// One contract should be good for renewal but not uploading and is therefore
+		// passive.
+		if len(rcg.PassiveContracts) != 1 {
+			return fmt.Errorf("Expected 1 passive contract but got %v", len(rcg.PassiveContracts))
+		}
+		hostContract := rcg.PassiveContracts[0]
+		if hostContract.HostPublicKey.String() != hpk.String() {
+			return errors.New("Passive contract doesn't belong to the host")
 		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Use the streaming endpoint to repair the file. It should always reach 100%.
-	b, err := ioutil.ReadFile(localFile.Path())
+
+	// Add a new host for the renter to replace the old one with.
+	_, err = tg.AddNodes(node.Host(filepath.Join(testDir, "host2")))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := r.RenterUploadStreamRepairPost(bytes.NewReader(b), remoteFile.SiaPath()); err != nil {
+	// The file should reach full health now.
+	if err := renter.WaitForUploadHealth(rf); err != nil {
 		t.Fatal(err)
 	}
-	if err := r.WaitForUploadHealth(remoteFile); err != nil {
-		t.Fatal("File wasn't repaired", err)
+	// There should be 2 active contracts now and 1 passive one.
+	rcg, err := renter.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	// We should be able to download
-	if _, err := r.DownloadByStream(remoteFile); err != nil {
-		t.Fatal("Failed to download file", err)
+	if len(rcg.ActiveContracts) != 2 {
+		t.Fatal("Expected 2 active contracts but got", len(rcg.ActiveContracts))
 	}
-	// Repair the file again to make sure we don't get stuck on chunks that are
-	// already repaired. Datapieces and paritypieces can be set to 0 as long as
-	// repair is true.
-	if err := r.RenterUploadStreamRepairPost(bytes.NewReader(b), remoteFile.SiaPath()); err != nil {
+	if len(rcg.PassiveContracts) != 1 {
+		t.Fatal("Expected 1 passive contract but got", len(rcg.PassiveContracts))
+	}
+	// After a while we give the host a new chance and it should be active again.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if err := tg.Miners()[0].MineBlock(); err != nil {
+			t.Fatal(err)
+		}
+		rcg, err = renter.RenterContractsGet()
+		if err != nil {
+			return err
+		}
+		if len(rcg.ActiveContracts) != 3 {
+			return fmt.Errorf("Expected 3 active contracts but got %v", len(rcg.ActiveContracts))
+		}
+		return nil
+	})
+	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-// TestRemoteBackup tests creating and loading remote backups.
-func TestRemoteBackup(t *testing.T) {
+// TestInterruptBackup tests that the renter can resume uploading a backup after
+// restarting.
+func TestInterruptBackup(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
@@ -4840,453 +5845,433 @@ func TestRemoteBackup(t *testing.T) {
 	// Upload the file.
 	dataPieces := uint64(len(tg.Hosts()) - 1)
 	parityPieces := uint64(1)
-	rf, err := r.UploadBlocking(lf, dataPieces, parityPieces, false)
+	_, err = r.UploadBlocking(lf, dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal("Failed to upload a file for testing: ", err)
 	}
-	// Create a snapshot.
-	createSnapshot := func(name string) error {
-		if err := r.RenterCreateBackupPost(name); err != nil {
-			return err
-		}
-		// wait for backup to upload
-		return build.Retry(60, time.Second, func() error {
-			ubs, _ := r.RenterBackups()
-			for _, ub := range ubs.Backups {
-				if ub.Name != name {
-					continue
-				} else if ub.UploadProgress != 100 {
-					return fmt.Errorf("backup not uploaded: %v", ub.UploadProgress)
-				}
-				return nil
-			}
-			return errors.New("backup not found")
-		})
-	}
-	if err := createSnapshot("foo"); err != nil {
-		t.Fatal(err)
-	}
-	// Delete the file locally.
-	if err := lf.Delete(); err != nil {
-		t.Fatal(err)
-	}
 
-	// Upload another file and take another snapshot.
-	lf2, err := subDir.NewFile(100)
-	if err != nil {
+	// Create a snapshot.
+	if err := r.RenterCreateBackupPost("foo"); err != nil {
 		t.Fatal(err)
 	}
-	rf2, err := r.UploadBlocking(lf2, dataPieces, parityPieces, false)
+	// The snapshot should be listed and not 100% uploaded.
+	ubs, err := r.RenterBackups()
 	if err != nil {
-		t.Fatal("Failed to upload a file for testing: ", err)
-	}
-	if err := createSnapshot("bar"); err != nil {
 		t.Fatal(err)
+	} else if len(ubs.Backups) != 1 {
+		t.Fatal("expected one backup, got", ubs)
+	} else if ubs.Backups[0].UploadProgress == 100 {
+		t.Fatal("backup should not be 100% uploaded")
 	}
-	if err := lf2.Delete(); err != nil {
+
+	// Restart the renter node.
+	if err := r.RestartNode(); err != nil {
 		t.Fatal(err)
 	}
 
-	// Both snapshots should be listed.
-	ubs, err := r.RenterBackups()
+	// The snapshot should still be listed and incomplete.
+	ubs, err = r.RenterBackups()
 	if err != nil {
 		t.Fatal(err)
-	} else if len(ubs.Backups) != 2 {
-		t.Fatal("expected two backups, got", ubs)
+	} else if len(ubs.Backups) != 1 {
+		t.Fatal("expected one backup, got", ubs)
+	} else if ubs.Backups[0].UploadProgress == 100 {
+		t.Fatal("backup should not be 100% uploaded")
 	}
 
-	// Delete both files and restore the first snapshot.
-	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
-		t.Fatal(err)
-	}
-	if err := r.RenterDeletePost(rf2.SiaPath()); err != nil {
-		t.Fatal(err)
-	}
-	if err := r.RenterRecoverBackupPost("foo"); err != nil {
-		t.Fatal(err)
-	}
-	// We should be able to download the first file.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		_, err = r.DownloadToDisk(rf, false)
-		return err
+	// Wait for the snapshot to finish uploading.
+	err = build.Retry(60, time.Second, func() error {
+		ubs, _ := r.RenterBackups()
+		if len(ubs.Backups) != 1 {
+			return errors.New("expected one backup")
+		}
+		if ubs.Backups[0].UploadProgress != 100 {
+			return errors.New("backup not uploaded")
+		}
+		return nil
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	// The second file should still fail.
-	if _, err := r.DownloadToDisk(rf2, false); err == nil {
-		t.Fatal("expected second file to be unavailable")
-	}
-	// Delete the first file again.
-	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
-		t.Fatal(err)
+}
+
+// TestRenterDrainHost tests that draining a host migrates its pieces to
+// another host and cancels its contract afterwards.
+func TestRenterDrainHost(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
 	}
+	t.Parallel()
 
-	// Restore the second snapshot.
-	if err := r.RenterRecoverBackupPost("bar"); err != nil {
-		t.Fatal(err)
+	// Create a group with one host more than the file needs.
+	groupParams := siatest.GroupParams{
+		Hosts:   3,
+		Renters: 1,
+		Miners:  1,
 	}
-	// We should be able to download both files now.
-	err = build.Retry(100, 100*time.Millisecond, func() error {
-		_, err = r.DownloadToDisk(rf, false)
-		if err != nil {
-			return err
-		}
-		_, err = r.DownloadToDisk(rf2, false)
-		if err != nil {
-			return err
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group:", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
 		}
-		return nil
-	})
+	}()
+	r := tg.Renters()[0]
+
+	// Upload a file to 2 of the 3 hosts.
+	lf, rf, err := r.UploadNewFileBlocking(100+siatest.Fuzz(), 1, 1, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Confirm siadir exists by querying directory
-	rd, err := r.RenterGetDir(modules.RootSiaPath())
+	// Drain one of the hosts that store a piece of the file.
+	rc, err := r.RenterContractsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rd.Directories) != 2 {
-		t.Fatal("Expected root and 1 subdirectory but got", rd.Directories)
+	var hostKey types.SiaPublicKey
+	for _, c := range rc.ActiveContracts {
+		if c.Size > 0 {
+			hostKey = c.HostPublicKey
+			break
+		}
 	}
-	if len(rd.Files) != 0 {
-		t.Fatal("Expected 0 files but got", rd.Files)
+	if hostKey.Key == nil {
+		t.Fatal("no contract stores any data")
 	}
-	rd, err = r.RenterGetDir(rd.Directories[1].SiaPath)
-	if err != nil {
+	if err := r.RenterDrainPost(hostKey); err != nil {
 		t.Fatal(err)
 	}
-	if len(rd.Directories) != 1 {
-		t.Fatal("expected only root directory but got", rd.Directories)
-	}
-	if len(rd.Files) != 2 {
-		t.Fatal("Expected 2 files but got", rd.Files)
+	if err := r.RenterDrainPost(hostKey); err == nil {
+		t.Fatal("draining a host twice should fail")
 	}
 
-	// Delete the renter entirely and create a new renter with the same seed.
-	wsg, err := r.WalletSeedsGet()
+	// Wait for the drain to finish.
+	var drain modules.HostDrainInfo
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rd, err := r.RenterDrainGet()
+		if err != nil {
+			return err
+		}
+		if len(rd.Drains) != 1 {
+			return fmt.Errorf("expected 1 drain, got %v", len(rd.Drains))
+		}
+		drain = rd.Drains[0]
+		if !drain.Finished {
+			return errors.New("drain isn't finished")
+		}
+		return nil
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	seed := wsg.PrimarySeed
-	if err := tg.RemoveNode(r); err != nil {
-		t.Fatal(err)
+	if drain.Error != "" || !drain.ContractCanceled {
+		t.Fatalf("drain failed: %+v", drain)
 	}
-	renterParams := node.Renter(filepath.Join(testDir, "renter"))
-	renterParams.PrimarySeed = seed
-	nodes, err := tg.AddNodes(renterParams)
+	if drain.Pieces == 0 || drain.MigratedPieces != drain.Pieces || drain.FailedPieces != 0 {
+		t.Fatalf("not all pieces were migrated: %+v", drain)
+	}
+
+	// The contract with the host should be inactive now and the file should
+	// still be fully redundant.
+	rc, err = r.RenterInactiveContractsGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-	r = nodes[0]
-
-	// Wait for the recovery process to complete.
-	err = build.Retry(60, time.Second, func() error {
-		// Both snapshots should be listed.
-		ubs, err = r.RenterBackups()
+	for _, c := range rc.ActiveContracts {
+		if c.HostPublicKey.String() == hostKey.String() {
+			t.Fatal("contract with drained host is still active")
+		}
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		fi, err := r.RenterFileGet(rf.SiaPath())
 		if err != nil {
 			return err
-		} else if len(ubs.Backups) != 2 {
-			return fmt.Errorf("expected two backups, got %v", ubs.Backups)
-		} else if len(ubs.SyncedHosts) != 2 {
-			return fmt.Errorf("expected two synced hosts, got %v", len(ubs.SyncedHosts))
+		}
+		if fi.File.Redundancy < 2 {
+			return fmt.Errorf("expected redundancy 2, got %v", fi.File.Redundancy)
 		}
 		return nil
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Restore the second snapshot.
-	if err := r.RenterRecoverBackupPost("bar"); err != nil {
-		t.Fatal(err)
-	}
-	// We should be able to download both files now.
-	if _, err := r.DownloadToDisk(rf, false); err != nil {
+	data, err := r.RenterDownloadHTTPResponseGet(rf.SiaPath(), 0, 0)
+	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err := r.DownloadToDisk(rf2, false); err != nil {
+	if err := lf.Equal(data); err != nil {
 		t.Fatal(err)
 	}
+}
 
-	// Confirm siadir exists by querying directory
-	rd, err = r.RenterGetDir(modules.RootSiaPath())
-	if err != nil {
-		t.Fatal(err)
+// TestRenterChunkCache tests that downloads are served from the chunk cache
+// once the chunks were downloaded and that deleting a file removes its chunks
+// from the cache.
+func TestRenterChunkCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
 	}
-	if len(rd.Directories) != 2 {
-		t.Fatal("Expected root and 1 subdirectory but got", rd.Directories)
+	t.Parallel()
+
+	// Create a group for testing.
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
 	}
-	if len(rd.Files) != 0 {
-		t.Fatal("Expected 0 files but got", rd.Files)
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group:", err)
 	}
-	rd, err = r.RenterGetDir(rd.Directories[1].SiaPath)
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := tg.Renters()[0]
+
+	// Upload a file with multiple chunks and enable the cache.
+	chunkSize := int(siatest.ChunkSize(1, crypto.TypeDefaultRenter))
+	lf, rf, err := r.UploadNewFileBlocking(3*chunkSize+2+siatest.Fuzz(), 1, 1, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rd.Directories) != 1 {
-		t.Fatal("expected only root directory but got", rd.Directories)
-	}
-	if len(rd.Files) != 2 {
-		t.Fatal("Expected 2 files but got", rd.Files)
+	if err := r.RenterPostChunkCacheSize(modules.SectorSize * 100); err != nil {
+		t.Fatal(err)
 	}
-}
-
-// testEscapeSiaPath tests that SiaPaths are escaped correctly to handle escape
-// characters
-func testEscapeSiaPath(t *testing.T, tg *siatest.TestGroup) {
-	// Grab the first of the group's renters
-	r := tg.Renters()[0]
 
-	// Check that we have enough hosts for this test.
-	if len(tg.Hosts()) < 2 {
-		t.Fatal("This test requires at least 2 hosts")
+	// Download the file twice. The first download should add all chunks to
+	// the cache and the second download should be served from the cache.
+	if _, err := r.DownloadByStream(rf); err != nil {
+		t.Fatal(err)
 	}
-
-	// Set fileSize and redundancy for upload
-	dataPieces := uint64(1)
-	parityPieces := uint64(len(tg.Hosts())) - dataPieces
-
-	// Create Local File
-	lf, err := r.FilesDir().NewFile(100)
+	rg, err := r.RenterGet()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// File names to tests
-	names := []string{
-		"dollar$sign",
-		"and&sign",
-		"single`quote",
-		"full:colon",
-		"semi;colon",
-		"hash#tag",
-		"percent%sign",
-		"at@sign",
-		"less<than",
-		"greater>than",
-		"equal=to",
-		"question?mark",
-		"open[bracket",
-		"close]bracket",
-		"open{bracket",
-		"close}bracket",
-		"carrot^top",
-		"pipe|pipe",
-		"tilda~tilda",
-		"plus+sign",
-		"minus-sign",
-		"under_score",
-		"comma,comma",
-		"apostrophy's",
-		`quotation"marks`,
+	if rg.ChunkCache.Chunks != 4 || rg.ChunkCache.Hits != 0 || rg.ChunkCache.Misses != 4 {
+		t.Fatalf("unexpected chunk cache stats: %+v", rg.ChunkCache)
+	}
+	if rg.Settings.ChunkCacheSize != modules.SectorSize*100 {
+		t.Fatal("chunk cache size wasn't set", rg.Settings.ChunkCacheSize)
 	}
-	for _, s := range names {
-		// Create SiaPath
-		siaPath, err := modules.NewSiaPath(s)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		// Upload file
-		_, err = r.Upload(lf, siaPath, dataPieces, parityPieces, false)
-		if err != nil {
-			t.Fatal(err)
-		}
 
-		// Confirm we can get file
-		_, err = r.RenterFileGet(siaPath)
-		if err != nil {
+	// Remove the hosts. Streaming the file should still work.
+	for _, h := range tg.Hosts() {
+		if err := tg.RemoveNode(h); err != nil {
 			t.Fatal(err)
 		}
 	}
-}
-
-// testValidateSiaPath tests the validate siapath endpoint
-func testValidateSiaPath(t *testing.T, tg *siatest.TestGroup) {
-	// Grab the first of the group's renters
-	r := tg.Renters()[0]
-
-	// Create siapaths to test
-	var pathTests = []struct {
-		path  string
-		valid bool
-	}{
-		{"valid/siapath", true},
-		{"\\some\\windows\\path", true}, // clean converts OS separators
-		{"../../../directory/traversal", false},
-		{"testpath", true},
-		{"valid/siapath/../with/directory/traversal", false},
-		{"validpath/test", true},
-		{"..validpath/..test", true},
-		{"./invalid/path", false},
-		{".../path", true},
-		{"valid./path", true},
-		{"valid../path", true},
-		{"valid/path./test", true},
-		{"valid/path../test", true},
-		{"test/path", true},
-		{"/leading/slash", false}, // this is not valid through the api because a leading slash is added by the api call so this turns into 2 leading slashes
-		{"foo/./bar", false},
-		{"", false},
-		{"blank/end/", true}, // clean will trim trailing slashes so this is a valid input
-		{"double//dash", false},
-		{"../", false},
-		{"./", false},
-		{".", false},
+	if _, err := r.DownloadByStream(rf); err != nil {
+		t.Fatal(err)
 	}
-	// Test all siapaths
-	for _, pathTest := range pathTests {
-		err := r.RenterValidateSiaPathPost(pathTest.path)
-		// Verify expected Error
-		if err != nil && pathTest.valid {
-			t.Fatal("validateSiapath failed on valid path: ", pathTest.path)
-		}
-		if err == nil && !pathTest.valid {
-			t.Fatal("validateSiapath succeeded on invalid path: ", pathTest.path)
-		}
+	if _, err := r.StreamPartial(rf, lf, uint64(chunkSize)/2, uint64(2*chunkSize)); err != nil {
+		t.Fatal(err)
 	}
-
-	// Create SiaPaths that contain escape characters
-	var escapeCharTests = []struct {
-		path  string
-		valid bool
-	}{
-		{"dollar$sign", true},
-		{"and&sign", true},
-		{"single`quote", true},
-		{"full:colon", true},
-		{"semi;colon", true},
-		{"hash#tag", true},
-		{"percent%sign", true},
-		{"at@sign", true},
-		{"less<than", true},
-		{"greater>than", true},
-		{"equal=to", true},
-		{"question?mark", true},
-		{"open[bracket", true},
-		{"close]bracket", true},
-		{"open{bracket", true},
-		{"close}bracket", true},
-		{"carrot^top", true},
-		{"pipe|pipe", true},
-		{"tilda~tilda", true},
-		{"plus+sign", true},
-		{"minus-sign", true},
-		{"under_score", true},
-		{"comma,comma", true},
-		{"apostrophy's", true},
-		{`quotation"marks`, true},
+	rg, err = r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Test all escape charcter siapaths
-	for _, escapeCharTest := range escapeCharTests {
-		path := url.PathEscape(escapeCharTest.path)
-		err := r.RenterValidateSiaPathPost(path)
-		// Verify expected Error
-		if err != nil && escapeCharTest.valid {
-			t.Fatalf("validateSiapath failed on valid path %v, escaped %v ", escapeCharTest.path, path)
-		}
-		if err == nil && !escapeCharTest.valid {
-			t.Fatalf("validateSiapath succeeded on invalid path %v, escaped %v ", escapeCharTest.path, path)
-		}
+	if rg.ChunkCache.Hits < 4 {
+		t.Fatalf("chunks weren't served from the cache: %+v", rg.ChunkCache)
+	}
+
+	// Delete the file and purge it from the trash. Its chunks should be
+	// removed from the cache.
+	if err := r.RenterDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenterTrashPurgeAllPost(); err != nil {
+		t.Fatal(err)
+	}
+	rg, err = r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rg.ChunkCache.Chunks != 0 || rg.ChunkCache.Size != 0 {
+		t.Fatalf("chunks of deleted file weren't removed from the cache: %+v", rg.ChunkCache)
 	}
 }
 
-// TestOutOfStorageHandling makes sure that we form a new contract to replace a
-// host that has run out of storage while still keeping it around as
-// goodForRenew.
-func TestOutOfStorageHandling(t *testing.T) {
+// TestRenterUserMetadata checks that user metadata can be set on upload and
+// through the API, is returned as part of the file info, is used as the
+// content type of streams and can be searched.
+func TestRenterUserMetadata(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Create a group with 1 default host.
-	gp := siatest.GroupParams{
-		Hosts:  1,
-		Miners: 1,
+	// Create a group for testing.
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
 	}
 	testDir := renterTestDir(t.Name())
-	tg, err := siatest.NewGroupFromTemplate(testDir, gp)
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatal("Failed to create group:", err)
 	}
 	defer func() {
 		if err := tg.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	// Prepare a host that offers the minimum storage possible.
-	hostTemplate := node.Host(filepath.Join(testDir, "host1"))
-	hostTemplate.HostStorage = modules.SectorSize * contractmanager.MinimumSectorsPerStorageFolder
-
-	// Prepare a renter that expects to upload 1 Sector of data to 2 hosts at a 2x
-	// redundancy. We set the ExpectedStorage lower than the available storage on
-	// the host to make sure it's not penalized.
-	renterTemplate := node.Renter(filepath.Join(testDir, "renter"))
-	dataPieces := uint64(1)
-	parityPieces := uint64(1)
-	allowance := siatest.DefaultAllowance
-	allowance.ExpectedRedundancy = float64(dataPieces+parityPieces) / float64(dataPieces)
-	allowance.ExpectedStorage = modules.SectorSize // 4 KiB
-	allowance.Hosts = 2
-	renterTemplate.Allowance = allowance
+	r := tg.Renters()[0]
 
-	// Add the host and renter to the group.
-	nodes, err := tg.AddNodes(hostTemplate)
+	// Upload a file with user metadata.
+	lf, err := r.FilesDir().NewFile(100 + siatest.Fuzz())
 	if err != nil {
 		t.Fatal(err)
 	}
-	host := nodes[0]
-	nodes, err = tg.AddNodes(renterTemplate)
+	siaPath, err := modules.NewSiaPath(lf.FileName())
 	if err != nil {
 		t.Fatal(err)
 	}
-	renter := nodes[0]
+	md := map[string]string{
+		modules.UserMetadataContentType: "text/plain",
+		modules.UserMetadataTags:        "docs,backup",
+	}
+	if err := r.RenterUploadMetadataPost(lf.Path(), siaPath, md); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := r.RenterFileGet(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rf.File.UserMetadata, md) {
+		t.Fatal("user metadata wasn't set on upload", rf.File.UserMetadata)
+	}
+	// Invalid metadata should be rejected.
+	if err := r.RenterSetFileUserMetadataPost(siaPath, map[string]string{"": "foo"}); err == nil {
+		t.Fatal("empty user metadata key was accepted")
+	}
 
-	// Upload a file to fill up the host.
-	_, _, err = renter.UploadNewFileBlocking(int(hostTemplate.HostStorage), dataPieces, parityPieces, false)
+	// Upload a second file without metadata. Only the first file should be
+	// found.
+	_, rf2, err := r.UploadNewFileBlocking(100+siatest.Fuzz(), 1, 1, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Make sure the host is full.
-	hg, err := host.HostGet()
-	if hg.ExternalSettings.RemainingStorage != 0 {
-		t.Fatal("Expected remaining storage to be 0 but was", hg.ExternalSettings.RemainingStorage)
+	found, err := r.RenterFindGet("backup", nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	// Start uploading another file in the background to trigger the OOS error.
-	_, rf, err := renter.UploadNewFile(int(2*modules.SectorSize), dataPieces, parityPieces, false)
+	if len(found.Files) != 1 || found.Files[0].SiaPath != siaPath {
+		t.Fatal("unexpected files found", found.Files)
+	}
+	found, err = r.RenterFindGet("", map[string]string{modules.UserMetadataContentType: "text/plain"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Make sure the host's contract is no longer good for upload but still good
-	// for renew.
-	err = build.Retry(10, time.Second, func() error {
-		if err := tg.Miners()[0].MineBlock(); err != nil {
-			t.Fatal(err)
-		}
-		hpk, err := host.HostPublicKey()
+	if len(found.Files) != 1 || found.Files[0].SiaPath != siaPath {
+		t.Fatal("unexpected files found", found.Files)
+	}
+	if _, err := r.RenterFindGet("", nil); err == nil {
+		t.Fatal("find without tag and query should fail")
+	}
+
+	// Tag the second file through the API. Both files should be found.
+	if err := r.RenterSetFileUserMetadataPost(rf2.SiaPath(), map[string]string{modules.UserMetadataTags: "backup"}); err != nil {
+		t.Fatal(err)
+	}
+	found, err = r.RenterFindGet("backup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Files) != 2 {
+		t.Fatal("expected 2 files but found", len(found.Files))
+	}
+
+	// Streaming the first file should use its content type.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rf, err := r.RenterFileGet(siaPath)
 		if err != nil {
 			return err
 		}
-		rcg, err := renter.RenterContractsGet()
-		if err != nil {
-			return err
+		if !rf.File.Available {
+			return errors.New("file isn't available yet")
 		}
-		// One contract should be good for uploads and renewal and is therefore
-		// active.
-		if len(rcg.ActiveContracts) != 1 {
-			return fmt.Errorf("Expected 1 active contract but got %v", len(rcg.ActiveContracts))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, data, err := r.RenterStreamHeaderGet(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Equal(data); err != nil {
+		t.Fatal(err)
+	}
+	if ct := header.Get("Content-Type"); ct != "text/plain" {
+		t.Fatal("wrong content type", ct)
+	}
+
+	// Clearing the metadata should remove the first file from the results.
+	if err := r.RenterSetFileUserMetadataPost(siaPath, nil); err != nil {
+		t.Fatal(err)
+	}
+	found, err = r.RenterFindGet("docs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Files) != 0 {
+		t.Fatal("expected no files but found", len(found.Files))
+	}
+}
+
+// TestRenterChecksums checks that the checksums of uploaded files are computed
+// and that downloads of files with checksums succeed.
+func TestRenterChecksums(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a group for testing.
+	groupParams := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal("Failed to create group:", err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
 		}
-		// One contract should be good for renewal but not uploading and is therefore
-		// passive.
-		if len(rcg.PassiveContracts) != 1 {
-			return fmt.Errorf("Expected 1 passive contract but got %v", len(rcg.PassiveContracts))
+	}()
+	r := tg.Renters()[0]
+
+	// Upload a file with multiple chunks from disk. Its checksum should be
+	// computed once all chunks were read.
+	chunkSize := int(siatest.ChunkSize(1, crypto.TypeDefaultRenter))
+	lf, rf, err := r.UploadNewFileBlocking(2*chunkSize+siatest.Fuzz(), 1, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(lf.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fileChecksum(data)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		file, err := r.File(rf)
+		if err != nil {
+			return err
 		}
-		hostContract := rcg.PassiveContracts[0]
-		if hostContract.HostPublicKey.String() != hpk.String() {
-			return errors.New("Passive contract doesn't belong to the host")
+		if file.Checksum != expected {
+			return fmt.Errorf("wrong checksum %v", file.Checksum)
 		}
 		return nil
 	})
@@ -5294,129 +6279,185 @@ func TestOutOfStorageHandling(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Add a new host for the renter to replace the old one with.
-	_, err = tg.AddNodes(node.Host(filepath.Join(testDir, "host2")))
+	// Downloading and streaming the file should succeed.
+	if _, err := r.DownloadToDisk(rf, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DownloadByStream(rf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.StreamPartial(rf, lf, uint64(chunkSize)/2, uint64(chunkSize)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A ranged download fetches the whole chunk to verify it.
+	partial, err := r.DownloadToDiskPartial(rf, lf, false, uint64(chunkSize)/2, 100)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// The file should reach full health now.
-	if err := renter.WaitForUploadHealth(rf); err != nil {
+	rdq, err := r.RenterDownloadsGet()
+	if err != nil {
 		t.Fatal(err)
 	}
-	// There should be 2 active contracts now and 1 passive one.
-	rcg, err := renter.RenterContractsGet()
+	found := false
+	for _, d := range rdq.Downloads {
+		if d.Destination != partial.Path() {
+			continue
+		}
+		found = true
+		if d.TotalDataTransferred < uint64(chunkSize) {
+			t.Fatalf("only %v bytes of the chunk were fetched", d.TotalDataTransferred)
+		}
+	}
+	if !found {
+		t.Fatal("ranged download wasn't found")
+	}
+
+	// The checksum of a stream upload should be known once the upload
+	// finished.
+	data = fastrand.Bytes(chunkSize + siatest.Fuzz())
+	siaPath, err := modules.NewSiaPath("stream")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(rcg.ActiveContracts) != 2 {
-		t.Fatal("Expected 2 active contracts but got", len(rcg.ActiveContracts))
+	if err := r.RenterUploadStreamPost(bytes.NewReader(data), siaPath, 1, 1, false); err != nil {
+		t.Fatal(err)
 	}
-	if len(rcg.PassiveContracts) != 1 {
-		t.Fatal("Expected 1 passive contract but got", len(rcg.PassiveContracts))
+	file, err := r.RenterFileGet(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fileChecksum(data); file.File.Checksum != expected {
+		t.Fatal("wrong checksum of stream upload", file.File.Checksum)
 	}
-	// After a while we give the host a new chance and it should be active again.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
-		if err := tg.Miners()[0].MineBlock(); err != nil {
-			t.Fatal(err)
-		}
-		rcg, err = renter.RenterContractsGet()
+		file, err := r.RenterFileGet(siaPath)
 		if err != nil {
 			return err
 		}
-		if len(rcg.ActiveContracts) != 3 {
-			return fmt.Errorf("Expected 3 active contracts but got %v", len(rcg.ActiveContracts))
+		if !file.File.Available {
+			return errors.New("file isn't available yet")
 		}
 		return nil
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	streamed, err := r.RenterStreamGet(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed, data) {
+		t.Fatal("streamed data doesn't match")
+	}
+
+	// Repairing the stream upload with different data should fail.
+	if err := r.RenterUploadStreamRepairPost(bytes.NewReader(fastrand.Bytes(len(data))), siaPath); err == nil {
+		t.Fatal("repair with different data should fail")
+	}
 }
 
-// TestInterruptBackup tests that the renter can resume uploading a backup after
-// restarting.
-func TestInterruptBackup(t *testing.T) {
+// fileChecksum returns the hex encoded SHA-256 checksum of data, which is
+// what sha256sum prints.
+func fileChecksum(data []byte) string {
+	checksum := sha256.Sum256(data)
+	return hex.EncodeToString(checksum[:])
+}
+
+// TestRenterAuditLostSector tests that a piece is removed from a file once the
+// host storing it has lost the sector, even though the host is still online,
+// and that the piece is repaired afterwards.
+func TestRenterAuditLostSector(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
-	// Create a testgroup.
+	// Create a group for testing.
 	groupParams := siatest.GroupParams{
 		Hosts:   2,
-		Miners:  1,
 		Renters: 1,
+		Miners:  1,
 	}
 	testDir := renterTestDir(t.Name())
 	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
 	if err != nil {
-		t.Fatal("Failed to create group: ", err)
+		t.Fatal("Failed to create group:", err)
 	}
 	defer func() {
 		if err := tg.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	// Create a subdir in the renter's files folder.
 	r := tg.Renters()[0]
-	subDir, err := r.FilesDir().CreateDir("subDir")
+
+	// Upload a file with a piece on each host.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	lf, rf, err := r.UploadNewFileBlocking(100+siatest.Fuzz(), dataPieces, parityPieces, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Add a file to that dir.
-	lf, err := subDir.NewFile(100)
+
+	// Find the sector that the first host stores for the file.
+	host := tg.Hosts()[0]
+	pk, err := host.HostPublicKey()
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Upload the file.
-	dataPieces := uint64(len(tg.Hosts()) - 1)
-	parityPieces := uint64(1)
-	_, err = r.UploadBlocking(lf, dataPieces, parityPieces, false)
+	sf, err := siafile.LoadSiaFile(rf.SiaPath().SiaFileSysPath(r.RenterFilesDir()), nil)
 	if err != nil {
-		t.Fatal("Failed to upload a file for testing: ", err)
-	}
-
-	// Create a snapshot.
-	if err := r.RenterCreateBackupPost("foo"); err != nil {
 		t.Fatal(err)
 	}
-	// The snapshot should be listed and not 100% uploaded.
-	ubs, err := r.RenterBackups()
+	pieces, err := sf.Pieces(0)
 	if err != nil {
 		t.Fatal(err)
-	} else if len(ubs.Backups) != 1 {
-		t.Fatal("expected one backup, got", ubs)
-	} else if ubs.Backups[0].UploadProgress == 100 {
-		t.Fatal("backup should not be 100% uploaded")
 	}
-
-	// Restart the renter node.
-	if err := r.RestartNode(); err != nil {
-		t.Fatal(err)
+	var roots []crypto.Hash
+	for _, pieceSet := range pieces {
+		for _, piece := range pieceSet {
+			if piece.HostPubKey.String() == pk.String() {
+				roots = append(roots, piece.MerkleRoot)
+			}
+		}
+	}
+	if len(roots) != 1 {
+		t.Fatal("expected the host to store one piece of the file, got", len(roots))
 	}
 
-	// The snapshot should still be listed and incomplete.
-	ubs, err = r.RenterBackups()
+	// Make the host drop the sector. The host stays online, so the piece is
+	// only removed from the file once it fails its audit. The renter then
+	// uploads the piece again, and the only host without a piece of the file
+	// is the host that lost it.
+	sg, err := host.HostStorageGet()
 	if err != nil {
 		t.Fatal(err)
-	} else if len(ubs.Backups) != 1 {
-		t.Fatal("expected one backup, got", ubs)
-	} else if ubs.Backups[0].UploadProgress == 100 {
-		t.Fatal("backup should not be 100% uploaded")
 	}
-
-	// Wait for the snapshot to finish uploading.
-	err = build.Retry(60, time.Second, func() error {
-		ubs, _ := r.RenterBackups()
-		if len(ubs.Backups) != 1 {
-			return errors.New("expected one backup")
+	remaining := sg.Folders[0].CapacityRemaining
+	if err := host.HostStorageSectorsDeletePost(roots[0]); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(600, 100*time.Millisecond, func() error {
+		sg, err := host.HostStorageGet()
+		if err != nil {
+			return err
 		}
-		if ubs.Backups[0].UploadProgress != 100 {
-			return errors.New("backup not uploaded")
+		if sg.Folders[0].CapacityRemaining > remaining {
+			return errors.New("host doesn't store the piece again")
 		}
 		return nil
 	})
 	if err != nil {
+		t.Fatal("lost piece wasn't repaired:", err)
+	}
+	if err := r.WaitForUploadHealth(rf); err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.DownloadByStream(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Equal(data); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -604,3 +604,133 @@ func TestWalletSendUnsynced(t *testing.T) {
 		t.Fatal("expected to get synced error but got:", err)
 	}
 }
+
+// TestWalletAccounts tests the /wallet/accounts endpoints and sending siacoins
+// from an account.
+func TestWalletAccounts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a new server
+	testNode, err := siatest.NewNode(node.AllModules(walletTestDir(t.Name())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := testNode.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create an account and fund it.
+	if err := testNode.WalletAccountCreatePost("allowance"); err != nil {
+		t.Fatal(err)
+	}
+	wag, err := testNode.WalletAccountAddressGet("allowance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fund := types.SiacoinPrecision.Mul64(100)
+	if _, err := testNode.WalletSiacoinsPost(fund, wag.Address); err != nil {
+		t.Fatal(err)
+	}
+	if err := testNode.MineBlock(); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := testNode.WalletAccountGet("allowance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acc.ConfirmedSiacoinBalance.Equals(fund) {
+		t.Fatalf("expected account balance %v, got %v", fund, acc.ConfirmedSiacoinBalance)
+	}
+
+	// Sending more than the account balance fails.
+	if _, err := testNode.WalletSiacoinsAccountPost("allowance", fund.Mul64(2), types.UnlockHash{}); err == nil {
+		t.Fatal("account funded a transaction larger than its balance")
+	}
+	// Sending from an account the balance covers succeeds and the account
+	// balance decreases.
+	if _, err := testNode.WalletSiacoinsAccountPost("allowance", fund.Div64(2), types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testNode.MineBlock(); err != nil {
+		t.Fatal(err)
+	}
+	wasg, err := testNode.WalletAccountsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wasg.Accounts) != 1 || wasg.Accounts[0].Name != "allowance" {
+		t.Fatal("unexpected accounts", wasg.Accounts)
+	}
+	if wasg.Accounts[0].ConfirmedSiacoinBalance.Cmp(fund.Div64(2)) >= 0 || wasg.Accounts[0].ConfirmedSiacoinBalance.IsZero() {
+		t.Fatal("unexpected account balance after send", wasg.Accounts[0].ConfirmedSiacoinBalance)
+	}
+
+	// Delete the account.
+	if err := testNode.WalletAccountDeletePost("allowance"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testNode.WalletAccountGet("allowance"); err == nil {
+		t.Fatal("deleted account still exists")
+	}
+}
+
+// TestWalletTransactionsExport tests the /wallet/transactions/export endpoint.
+func TestWalletTransactionsExport(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a new server
+	testNode, err := siatest.NewNode(node.AllModules(walletTestDir(t.Name())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := testNode.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Send coins to an address of the wallet.
+	wag, err := testNode.WalletAddressGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := types.SiacoinPrecision.Mul64(50)
+	if _, err := testNode.WalletSiacoinsPost(value, wag.Address); err != nil {
+		t.Fatal(err)
+	}
+	if err := testNode.MineBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ledger of the address contains a single transfer of the value.
+	params := modules.WalletLedgerParams{Addresses: []types.UnlockHash{wag.Address}}
+	wteg, err := testNode.WalletTransactionsExportGet(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wteg.Entries) != 1 {
+		t.Fatalf("expected 1 ledger entry, got %v", len(wteg.Entries))
+	}
+	e := wteg.Entries[0]
+	if e.Category != modules.LedgerCategoryTransfer || !e.IncomingValue.Equals(value) || e.NetValue != value.String() {
+		t.Fatal("unexpected ledger entry", e)
+	}
+
+	// The CSV ledger contains a header row and the entry.
+	csv, err := testNode.WalletTransactionsExportCSVGet(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(csv)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], e.TransactionID.String()) {
+		t.Fatal("unexpected CSV ledger", string(csv))
+	}
+}
@@ -147,8 +147,14 @@ func (b Block) MerkleRoot() crypto.Hash {
 // is calculated by hashing the concatenation of the BlockID and the payout
 // index.
 func (b Block) MinerPayoutID(i uint64) SiacoinOutputID {
+	return b.ID().MinerPayoutID(i)
+}
+
+// MinerPayoutID returns the ID of the miner payout at the given index of the
+// block with this ID.
+func (bid BlockID) MinerPayoutID(i uint64) SiacoinOutputID {
 	return SiacoinOutputID(crypto.HashAll(
-		b.ID(),
+		bid,
 		i,
 	))
 }
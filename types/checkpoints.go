@@ -0,0 +1,56 @@
+package types
+
+// checkpoints.go contains the hardcoded checkpoints of the blockchain. A
+// checkpoint is a block that is known to be part of the canonical chain. The
+// consensus set refuses any block that conflicts with a checkpoint, which
+// protects a syncing node from being fed a long, low-work fork.
+
+import (
+	"gitlab.com/NebulousLabs/Sia/build"
+)
+
+var (
+	// Checkpoints maps block heights to the IDs of the blocks that are known
+	// to be at those heights in the canonical chain. The genesis block is
+	// always a checkpoint. Checkpoints is initialized in the init function of
+	// constants.go, after the genesis block has been created.
+	Checkpoints map[BlockHeight]BlockID
+
+	// StandardCheckpoints contains the checkpoints of the Standard build in
+	// addition to the genesis block. Checkpoints should only be added for
+	// blocks that are buried deep enough that a reorg past them is not
+	// feasible.
+	StandardCheckpoints = map[BlockHeight]BlockID{
+		20032: {0, 0, 0, 0, 0, 0, 51, 185, 235, 87, 250, 99, 165, 26, 222, 234, 133, 126, 112, 246, 65, 94, 187, 254, 93, 242, 160, 31, 13, 4, 119, 244},
+	}
+
+	// releaseCheckpoints contains the checkpoints of the current release in
+	// addition to the genesis block.
+	releaseCheckpoints = build.Select(build.Var{
+		Standard: StandardCheckpoints,
+		Dev:      map[BlockHeight]BlockID{},
+		Testing:  map[BlockHeight]BlockID{},
+	}).(map[BlockHeight]BlockID)
+)
+
+// initCheckpoints initializes the Checkpoints map using the genesis ID and
+// the checkpoints of the current release.
+func initCheckpoints() {
+	Checkpoints = map[BlockHeight]BlockID{
+		0: GenesisID,
+	}
+	for height, id := range releaseCheckpoints {
+		Checkpoints[height] = id
+	}
+}
+
+// LastCheckpointHeight returns the height of the highest checkpoint.
+func LastCheckpointHeight() BlockHeight {
+	var last BlockHeight
+	for height := range Checkpoints {
+		if height > last {
+			last = height
+		}
+	}
+	return last
+}
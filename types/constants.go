@@ -601,4 +601,7 @@ func init() {
 	}
 	// Calculate the genesis ID.
 	GenesisID = GenesisBlock.ID()
+
+	// Initialize the checkpoints, which depend on the genesis ID.
+	initCheckpoints()
 }
@@ -292,6 +292,22 @@ func (t Transaction) validUnlockConditions(currentHeight BlockHeight) (err error
 // transaction. StandaloneValid will not check that all outputs being spent are
 // legal outputs, as it has no confirmed or unconfirmed set to look at.
 func (t Transaction) StandaloneValid(currentHeight BlockHeight) (err error) {
+	err = t.StandaloneValidWithoutSignatures(currentHeight)
+	if err != nil {
+		return
+	}
+	err = t.validSignatures(currentHeight)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// StandaloneValidWithoutSignatures performs the same checks as
+// StandaloneValid, except for the expensive signature verification. It
+// should only be used for transactions that are known to be valid, such as
+// transactions in blocks below the last checkpoint.
+func (t Transaction) StandaloneValidWithoutSignatures(currentHeight BlockHeight) (err error) {
 	err = t.fitsInABlock(currentHeight)
 	if err != nil {
 		return
@@ -320,9 +336,5 @@ func (t Transaction) StandaloneValid(currentHeight BlockHeight) (err error) {
 	if err != nil {
 		return
 	}
-	err = t.validSignatures(currentHeight)
-	if err != nil {
-		return
-	}
 	return
 }